@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+)
+
+// completionScripts holds the shell completion scripts of the CLI flags and
+// subcommands, by shell name.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for kubesql
+# Load with: source <(kubesql completion bash)
+_kubesql() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local cmd=""
+    if [[ ${COMP_CWORD} -gt 1 ]]; then
+        cmd="${COMP_WORDS[1]}"
+    fi
+
+    case "${prev}" in
+        -format)
+            COMPREPLY=($(compgen -W "json yaml text" -- "${cur}"))
+            return
+            ;;
+        -file|-load|-schemas|-data|-history|-kubeconfig)
+            COMPREPLY=($(compgen -f -- "${cur}"))
+            return
+            ;;
+        -context)
+            return
+            ;;
+    esac
+
+    case "${cmd}" in
+        repl)
+            COMPREPLY=($(compgen -W "-data -schemas -format -history" -- "${cur}"))
+            return
+            ;;
+        completion)
+            COMPREPLY=($(compgen -W "bash zsh fish" -- "${cur}"))
+            return
+            ;;
+        lsp)
+            COMPREPLY=($(compgen -W "-schemas" -- "${cur}"))
+            return
+            ;;
+    esac
+
+    if [[ ${COMP_CWORD} -eq 1 ]]; then
+        COMPREPLY=($(compgen -W "repl completion lsp schema -format -file -load -data -run -watch -kubeconfig -context -validate -schemas -help" -- "${cur}"))
+    else
+        COMPREPLY=($(compgen -W "-format -file -load -data -run -watch -kubeconfig -context -validate -schemas -help" -- "${cur}"))
+    fi
+}
+complete -o default -F _kubesql kubesql
+`,
+	"zsh": `#compdef kubesql
+# zsh completion for kubesql
+# Load with: source <(kubesql completion zsh)
+_kubesql() {
+    case "${words[2]}" in
+        repl)
+            _arguments \
+                '-data[JSON or YAML files and directories of objects to query]:file:_files' \
+                '-schemas[OpenAPI or CRD schema files and directories used to validate queries]:file:_files' \
+                '-format[Output format]:format:(json yaml text)' \
+                '-history[File the input history is kept in]:file:_files'
+            ;;
+        completion)
+            _arguments '2:shell:(bash zsh fish)'
+            ;;
+        lsp)
+            _arguments '-schemas[OpenAPI or CRD schema files and directories used to validate and complete queries]:file:_files'
+            ;;
+        *)
+            _arguments \
+                '-format[Output format]:format:(json yaml text)' \
+                '-file[Parse a script of queries from a file]:file:_files' \
+                '-load[Load structured queries written as JSON or YAML]:file:_files' \
+                '-data[JSON or YAML files and directories of objects to run the queries against]:file:_files' \
+                '-run[Run the queries against the cluster of the kubeconfig context]' \
+                '-watch[Print the changes of the rows of a query until interrupted]' \
+                '-kubeconfig[Kubeconfig file used by -run]:file:_files' \
+                '-context[Kubeconfig context used by -run]:context: ' \
+                '-validate[Validate resources and fields of the parsed queries]' \
+                '-schemas[OpenAPI or CRD schema files and directories used by -validate]:file:_files' \
+                '-help[Show help message]' \
+                '1:command or query:((repl\:"Run queries interactively against a snapshot" completion\:"Print a shell completion script" lsp\:"Run the language server for .ksql files" schema\:"Print the JSON Schema of queries"))' \
+                '*:query: '
+            ;;
+    esac
+}
+
+if [ "$funcstack[1]" = "_kubesql" ]; then
+    _kubesql "$@"
+else
+    compdef _kubesql kubesql
+fi
+`,
+	"fish": `# fish completion for kubesql
+# Load with: kubesql completion fish | source
+complete -c kubesql -f
+complete -c kubesql -n __fish_use_subcommand -a repl -d 'Run queries interactively against a snapshot'
+complete -c kubesql -n __fish_use_subcommand -a completion -d 'Print a shell completion script'
+complete -c kubesql -n __fish_use_subcommand -a lsp -d 'Run the language server for .ksql files'
+complete -c kubesql -n __fish_use_subcommand -a schema -d 'Print the JSON Schema of queries'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o format -x -a 'json yaml text' -d 'Output format'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o file -r -F -d 'Parse a script of queries from a file'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o load -r -F -d 'Load structured queries written as JSON or YAML'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o data -r -F -d 'JSON or YAML files and directories of objects to run the queries against'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o run -d 'Run the queries against the cluster of the kubeconfig context'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o watch -d 'Print the changes of the rows of a query until interrupted'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o kubeconfig -r -F -d 'Kubeconfig file used by -run'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o context -x -d 'Kubeconfig context used by -run'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o validate -d 'Validate resources and fields of the parsed queries'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o schemas -r -F -d 'OpenAPI or CRD schema files and directories used by -validate'
+complete -c kubesql -n 'not __fish_seen_subcommand_from repl completion lsp schema' -o help -d 'Show help message'
+complete -c kubesql -n '__fish_seen_subcommand_from repl' -o data -r -F -d 'JSON or YAML files and directories of objects to query'
+complete -c kubesql -n '__fish_seen_subcommand_from repl' -o schemas -r -F -d 'OpenAPI or CRD schema files and directories used to validate queries'
+complete -c kubesql -n '__fish_seen_subcommand_from repl' -o format -x -a 'json yaml text' -d 'Output format'
+complete -c kubesql -n '__fish_seen_subcommand_from repl' -o history -r -F -d 'File the input history is kept in'
+complete -c kubesql -n '__fish_seen_subcommand_from lsp' -o schemas -r -F -d 'OpenAPI or CRD schema files and directories used to validate and complete queries'
+complete -c kubesql -n '__fish_seen_subcommand_from completion' -x -a 'bash zsh fish'
+`,
+}
+
+// runCompletion runs "kubesql completion SHELL", printing the completion
+// script of a shell.
+func runCompletion(args []string) {
+	if len(args) != 1 || completionScripts[args[0]] == "" {
+		fmt.Fprintf(os.Stderr, "Usage: kubesql completion bash|zsh|fish\n")
+		os.Exit(1)
+	}
+	fmt.Print(completionScripts[args[0]])
+}
+
+// completeLine completes the word before pos in a REPL input line, where
+// pending holds the previous lines of the statement being written. A
+// single suggestion, or the common prefix of several, is inserted; when
+// there is nothing new to insert the suggestions are listed.
+func (r *repl) completeLine(pending, line string, pos int) (string, int, bool) {
+	text := pending + line[:pos]
+	suggestions := r.completer.Complete(text, len(text))
+	if len(suggestions) == 0 {
+		return "", 0, false
+	}
+
+	// Offset of the completed word in line
+	start := suggestions[0].Start - len(pending)
+	if start < 0 {
+		return "", 0, false
+	}
+
+	insert := suggestions[0].Text
+	for _, suggestion := range suggestions[1:] {
+		insert = commonPrefix(insert, suggestion.Text)
+	}
+	if len(suggestions) > 1 && len(insert) <= pos-start {
+		texts := make([]string, len(suggestions))
+		for i, suggestion := range suggestions {
+			texts[i] = suggestion.Text
+		}
+		fmt.Fprintln(r.out, strings.Join(texts, "  "))
+		return "", 0, false
+	}
+	if len(suggestions) == 1 && suggestions[0].Kind != kubesql.FieldSuggestion {
+		// Fields may continue with a dot or an index
+		insert += " "
+	}
+
+	newLine := line[:start] + insert + line[pos:]
+	return newLine, start + len(insert), true
+}
+
+// commonPrefix returns the longest common prefix of a and b, ignoring case
+// and keeping the case of a.
+func commonPrefix(a, b string) string {
+	n := 0
+	for n < len(a) && n < len(b) && strings.EqualFold(a[n:n+1], b[n:n+1]) {
+		n++
+	}
+	return a[:n]
+}
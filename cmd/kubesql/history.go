@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxHistory is the number of input lines kept in the REPL history and in
+// its file.
+const maxHistory = 1000
+
+// fileHistory is a line editing history that is kept in a file, so it
+// persists between REPL sessions. It implements term.History.
+type fileHistory struct {
+	path    string   // History file, empty to keep the history in memory only
+	entries []string // History lines, the most recent last
+	lines   int      // Number of lines in the history file
+}
+
+// defaultHistoryFile returns the default history file, ~/.kubesql_history.
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kubesql_history")
+}
+
+// loadHistory reads the history file at path. A missing file starts an
+// empty history.
+func loadHistory(path string) (*fileHistory, error) {
+	history := &fileHistory{path: path}
+	if path == "" {
+		return history, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		history.lines++
+		if line := scanner.Text(); strings.TrimSpace(line) != "" {
+			history.entries = append(history.entries, line)
+		}
+	}
+	if len(history.entries) > maxHistory {
+		history.entries = history.entries[len(history.entries)-maxHistory:]
+	}
+	return history, scanner.Err()
+}
+
+// Add implements term.History. Empty lines and repeats of the previous line
+// are not recorded. Entries are appended to the history file, which is
+// rewritten with the kept entries once it holds more than maxHistory lines.
+func (h *fileHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+	}
+
+	if h.path == "" {
+		return
+	}
+	if h.lines >= maxHistory {
+		_ = h.save()
+		return
+	}
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintln(file, entry); err == nil {
+		h.lines++
+	}
+}
+
+// save replaces the history file with the kept entries. The entries are
+// written to a temporary file first, so an error keeps the previous file.
+func (h *fileHistory) save() error {
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	h.lines = len(h.entries)
+	return nil
+}
+
+// Len implements term.History.
+func (h *fileHistory) Len() int {
+	return len(h.entries)
+}
+
+// At implements term.History, index 0 is the most recent entry.
+func (h *fileHistory) At(idx int) string {
+	if idx < 0 || idx >= len(h.entries) {
+		panic(fmt.Sprintf("history index %d out of range [0,%d)", idx, len(h.entries)))
+	}
+	return h.entries[len(h.entries)-1-idx]
+}
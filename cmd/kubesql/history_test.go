@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadHistory(t *testing.T) {
+	long := make([]string, maxHistory+5)
+	for i := range long {
+		long[i] = fmt.Sprintf("SELECT %d FROM pods;", i)
+	}
+
+	testCases := []struct {
+		content  string
+		expected []string
+	}{
+		{"", nil},
+		{"FROM pods;\n\n  \nFROM nodes;\n", []string{"FROM pods;", "FROM nodes;"}},
+		{strings.Join(long, "\n"), long[5:]},
+	}
+
+	for _, tc := range testCases {
+		path := filepath.Join(t.TempDir(), "history")
+		if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+			t.Fatalf("Error writing history: %v", err)
+		}
+
+		history, err := loadHistory(path)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.content, err)
+			continue
+		}
+		if strings.Join(history.entries, "\n") != strings.Join(tc.expected, "\n") {
+			t.Errorf("For input '%s', expected %d entries, got %d", tc.content, len(tc.expected), len(history.entries))
+		}
+	}
+
+	history, err := loadHistory(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || history.Len() != 0 {
+		t.Errorf("Expected an empty history for a missing file, got %d entries and %v", history.Len(), err)
+	}
+}
+
+func TestHistoryAdd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	history, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("Error loading history: %v", err)
+	}
+
+	for _, entry := range []string{"FROM pods;", "", "FROM pods;", "FROM nodes;"} {
+		history.Add(entry)
+	}
+	if history.Len() != 2 || history.At(0) != "FROM nodes;" || history.At(1) != "FROM pods;" {
+		t.Errorf("Expected the entries without empty and repeated lines, got %q", history.entries)
+	}
+
+	// A new session sees the entries of the previous one
+	reloaded, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("Error loading history: %v", err)
+	}
+	if strings.Join(reloaded.entries, "\n") != "FROM pods;\nFROM nodes;" {
+		t.Errorf("Expected the saved entries, got %q", reloaded.entries)
+	}
+}
+
+func TestHistoryTrim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	lines := make([]string, maxHistory)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("SELECT %d FROM pods;", i)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("Error writing history: %v", err)
+	}
+
+	history, err := loadHistory(path)
+	if err != nil {
+		t.Fatalf("Error loading history: %v", err)
+	}
+	history.Add("FROM nodes;")
+	history.Add("FROM services;")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error reading history: %v", err)
+	}
+	saved := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(saved) != maxHistory {
+		t.Fatalf("Expected %d lines in the history file, got %d", maxHistory, len(saved))
+	}
+	if saved[0] != lines[2] || saved[len(saved)-2] != "FROM nodes;" || saved[len(saved)-1] != "FROM services;" {
+		t.Errorf("Expected the oldest lines to be dropped, got %q ... %q", saved[0], saved[len(saved)-2:])
+	}
+}
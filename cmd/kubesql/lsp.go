@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+	"github.com/yaacov/kubesql-interpreter/pkg/lsp"
+)
+
+// runLsp runs "kubesql lsp": a language server for .ksql files speaking
+// the Language Server Protocol over the standard input and output.
+func runLsp(args []string) {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	schemaPaths := flags.String("schemas", "", "Comma separated OpenAPI or CRD schema files and directories used to validate and complete queries")
+	_ = flags.Parse(args)
+
+	resolver := kubesql.DefaultResolver()
+	var schemas kubesql.SchemaProvider
+	if *schemaPaths != "" {
+		loaded, err := kubesql.LoadSchemas(strings.Split(*schemaPaths, ",")...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading schemas: %v\n", err)
+			os.Exit(1)
+		}
+		resolver.Add(loaded.Resources()...)
+		schemas = loaded
+	}
+
+	// Standard output carries the protocol, errors go to standard error
+	if err := lsp.NewServer(resolver, schemas).Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
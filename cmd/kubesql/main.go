@@ -1,23 +1,54 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
 
+	"github.com/yaacov/kubesql-interpreter/pkg/kube"
 	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
 	"gopkg.in/yaml.v3"
 )
 
 var (
-	outputFormat = flag.String("format", "json", "Output format: json or yaml")
+	outputFormat = flag.String("format", "json", "Output format: json, yaml or text")
+	scriptFile   = flag.String("file", "", "Parse a script of ';' separated queries from a file ('-' for stdin)")
+	loadFile     = flag.String("load", "", "Load structured queries, as written by -format json or yaml, from a file ('-' for stdin)")
+	dataPaths    = flag.String("data", "", "Comma separated JSON or YAML files and directories of objects to run the queries against, CLUSTER=PATH for the objects of a cluster")
+	runFlag      = flag.Bool("run", false, "Run the queries against the cluster of the kubeconfig context")
+	watchFlag    = flag.Bool("watch", false, "Run a query against the cluster, then print the changes of its rows until interrupted")
+	kubeconfig   = flag.String("kubeconfig", "", "Kubeconfig file used by -run and -watch, KUBECONFIG or ~/.kube/config by default")
+	kubeContext  = flag.String("context", "", "Kubeconfig context used by -run and -watch, the current context by default")
+	validate     = flag.Bool("validate", false, "Validate resources and fields of the parsed queries")
+	schemaPaths  = flag.String("schemas", "", "Comma separated OpenAPI or CRD schema files and directories used by -validate")
 	helpFlag     = flag.Bool("help", false, "Show help message")
 )
 
 func main() {
+	// Subcommands come before any flag
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "repl":
+			runRepl(os.Args[2:])
+			return
+		case "completion":
+			runCompletion(os.Args[2:])
+			return
+		case "lsp":
+			runLsp(os.Args[2:])
+			return
+		case "schema":
+			runSchema(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
 	if *helpFlag {
@@ -25,6 +56,44 @@ func main() {
 		return
 	}
 
+	// Load structured queries, printed back or run like parsed queries
+	if *loadFile != "" {
+		data, err := readScript(*loadFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading queries: %v\n", err)
+			os.Exit(1)
+		}
+
+		queries, err := kubesql.UnmarshalQueries([]byte(data))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading queries from %s: %v\n", *loadFile, err)
+			os.Exit(1)
+		}
+
+		validateQueries(queries...)
+		handleQueries(queries...)
+		return
+	}
+
+	// Parse a whole script when a script file is given
+	if *scriptFile != "" {
+		script, err := readScript(*scriptFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading script: %v\n", err)
+			os.Exit(1)
+		}
+
+		queries, err := kubesql.ParseScript(script)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing script %s: %v\n", *scriptFile, err)
+			os.Exit(1)
+		}
+
+		validateQueries(queries...)
+		handleQueries(queries...)
+		return
+	}
+
 	// Get the KubeSQL query from command line arguments
 	args := flag.Args()
 	if len(args) == 0 {
@@ -43,43 +112,279 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Convert to the desired output format
-	var output []byte
-	switch strings.ToLower(*outputFormat) {
+	validateQueries(result)
+	handleQueries(result)
+}
+
+// handleQueries runs the queries against the -data objects or the cluster
+// with -run, watches a query with -watch, or prints them. Scripts and loaded
+// lists of several queries are printed as a list.
+func handleQueries(queries ...*kubesql.Query) {
+	if *watchFlag {
+		if len(queries) != 1 {
+			fmt.Fprintf(os.Stderr, "Error: -watch runs a single query, got %d\n", len(queries))
+			os.Exit(1)
+		}
+		watchQuery(queries[0])
+		return
+	}
+	if *dataPaths != "" || *runFlag {
+		runQueries(queries)
+		return
+	}
+
+	if len(queries) == 1 && *scriptFile == "" {
+		writeOutput(outputValue(queries[0]))
+		return
+	}
+	values := make([]any, len(queries))
+	for i, query := range queries {
+		values[i] = outputValue(query)
+	}
+	writeOutput(values)
+}
+
+// runQueries runs queries against the objects of the -data files, or the
+// cluster with -run, printing the results of each as a table in text format
+// and as records otherwise. EXPLAIN statements print their plan.
+func runQueries(queries []*kubesql.Query) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	resolver, _ := loadResolver()
+	runner := loadRunner(resolver)
+
+	for _, query := range queries {
+		if query.Explain {
+			writeOutput(outputValue(query))
+			continue
+		}
+		result, err := runner.Run(ctx, query)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error running query: %v\n", err)
+			os.Exit(1)
+		}
+		// Rows of the other clusters are printed
+		for _, err := range result.Errors {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if strings.EqualFold(*outputFormat, "text") {
+			writeTable(os.Stdout, result)
+		} else {
+			writeOutput(resultRecords(result))
+		}
+	}
+}
+
+// loadRunner returns the runner of queries: over the objects of the -data
+// files, where CLUSTER=PATH files hold the objects of a cluster, or over the
+// cluster of the kubeconfig context, with the other contexts as clusters.
+func loadRunner(resolver kubesql.Resolver) *kubesql.Runner {
+	if *dataPaths != "" {
+		var paths []string
+		clusterPaths := map[string][]string{}
+		for _, path := range strings.Split(*dataPaths, ",") {
+			if cluster, clusterPath, ok := strings.Cut(path, "="); ok {
+				clusterPaths[cluster] = append(clusterPaths[cluster], clusterPath)
+			} else {
+				paths = append(paths, path)
+			}
+		}
+
+		runner := kubesql.NewRunner(nil, resolver)
+		if len(paths) > 0 {
+			runner.Source = loadDataset(paths)
+		}
+		clusters := kubesql.StaticClusters{}
+		for cluster, paths := range clusterPaths {
+			clusters[cluster] = loadDataset(paths)
+		}
+		runner.Clusters = clusters
+		return runner
+	}
+
+	var paths []string
+	if *kubeconfig != "" {
+		paths = []string{*kubeconfig}
+	}
+	config, err := kube.LoadKubeconfig(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+	source, err := config.Source(*kubeContext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to cluster: %v\n", err)
+		os.Exit(1)
+	}
+	runner := kubesql.NewRunner(source, resolver)
+	runner.Clusters = config
+	return runner
+}
+
+// loadDataset loads the objects of -data files.
+func loadDataset(paths []string) *kubesql.Dataset {
+	dataset, err := kubesql.LoadDataset(paths...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading data: %v\n", err)
+		os.Exit(1)
+	}
+	return dataset
+}
+
+// loadResolver returns the resolver of the built-in resources and of the
+// resources of the -schemas files, and the loaded schemas, nil without
+// -schemas.
+func loadResolver() (*kubesql.StaticResolver, kubesql.SchemaProvider) {
+	resolver := kubesql.DefaultResolver()
+	if *schemaPaths == "" {
+		return resolver, nil
+	}
+
+	schemas, err := kubesql.LoadSchemas(strings.Split(*schemaPaths, ",")...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading schemas: %v\n", err)
+		os.Exit(1)
+	}
+	resolver.Add(schemas.Resources()...)
+	return resolver, schemas
+}
+
+// outputValue returns what is printed for a parsed query: the plan of an
+// EXPLAIN statement, or the query itself.
+func outputValue(query *kubesql.Query) any {
+	if !query.Explain {
+		return query
+	}
+
+	resolver, _ := loadResolver()
+	plan, err := kubesql.NewPlanner(resolver).Plan(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error planning query: %v\n", err)
+		os.Exit(1)
+	}
+	return plan
+}
+
+// validateQueries checks the queries against the known resources and the
+// loaded schemas when -validate is set, and exits on the first invalid query.
+func validateQueries(queries ...*kubesql.Query) {
+	if !*validate {
+		return
+	}
+
+	validator := kubesql.NewValidator(loadResolver())
+
+	for _, query := range queries {
+		if err := validator.Validate(query); err != nil {
+			var validationErrs kubesql.ValidationErrors
+			if errors.As(err, &validationErrs) {
+				for _, validationErr := range validationErrs {
+					fmt.Fprintf(os.Stderr, "Error validating query: %v\n", validationErr)
+				}
+			} else {
+				fmt.Fprintf(os.Stderr, "Error validating query: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	}
+}
+
+// readScript reads a script from the named file, or from stdin for "-".
+func readScript(name string) (string, error) {
+	var data []byte
+	var err error
+	if name == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	return string(data), err
+}
+
+// writeOutput prints value to stdout in the selected output format.
+func writeOutput(value any) {
+	if err := encodeOutput(os.Stdout, *outputFormat, value); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// encodeOutput writes value to out as JSON, YAML or text. The text form of
+// queries and plans is their String form, one value after the other.
+func encodeOutput(out io.Writer, format string, value any) error {
+	switch strings.ToLower(format) {
+	case "text":
+		values, ok := value.([]any)
+		if !ok {
+			values = []any{value}
+		}
+		for _, value := range values {
+			text := fmt.Sprint(value)
+			if _, ok := value.(*kubesql.Query); ok {
+				text += ";"
+			}
+			if _, err := fmt.Fprintln(out, strings.TrimSuffix(text, "\n")); err != nil {
+				return err
+			}
+		}
+		return nil
 	case "json":
-		encoder := json.NewEncoder(os.Stdout)
+		encoder := json.NewEncoder(out)
 		encoder.SetIndent("", "  ")
 		encoder.SetEscapeHTML(false)
-		err = encoder.Encode(result)
-		if err != nil {
-			log.Fatalf("Error marshaling to JSON: %v", err)
+		if err := encoder.Encode(value); err != nil {
+			return fmt.Errorf("error marshaling to JSON: %w", err)
 		}
-		return
+		return nil
 	case "yaml":
-		output, err = yaml.Marshal(result)
+		output, err := yaml.Marshal(value)
 		if err != nil {
-			log.Fatalf("Error marshaling to YAML: %v", err)
+			return fmt.Errorf("error marshaling to YAML: %w", err)
 		}
-	default:
-		fmt.Fprintf(os.Stderr, "Error: Unsupported output format '%s'. Supported formats: json, yaml\n", *outputFormat)
-		os.Exit(1)
+		_, err = out.Write(output)
+		return err
 	}
-
-	fmt.Print(string(output))
+	return fmt.Errorf("unsupported output format '%s'. Supported formats: json, yaml, text", format)
 }
 
 func showHelp() {
 	fmt.Printf(`KubeSQL Parser Command Line Tool
 
 DESCRIPTION:
-    Parse KubeSQL queries and output the result as JSON or YAML.
+    Parse KubeSQL queries and output the result as JSON or YAML, or run them
+    against a cluster with -run or against saved objects with -data.
     
 USAGE:
     sql [OPTIONS] <SQL_QUERY>
+    sql [OPTIONS] -file <SCRIPT_FILE>
+    sql [OPTIONS] -load <QUERY_FILE>
+    sql repl [-data FILES] [-schemas FILES] [-format text|json|yaml] [-history FILE]
+    sql completion bash|zsh|fish
+    sql lsp [-schemas FILES]
+    sql schema
 
 OPTIONS:
     -format string
-            Output format: json or yaml (default "json")
+            Output format: json, yaml or text (default "json")
+    -file string
+            Parse a script of ';' separated queries from a file ('-' for stdin)
+    -load string
+            Load structured queries, as written by -format json or yaml, from a file ('-' for stdin)
+    -data string
+            Comma separated JSON or YAML files and directories of objects to run the queries against, CLUSTER=PATH for the objects of a cluster
+    -run
+            Run the queries against the cluster of the kubeconfig context
+    -watch
+            Run a query against the cluster, then print the changes of its rows until interrupted
+    -kubeconfig string
+            Kubeconfig file used by -run and -watch, KUBECONFIG or ~/.kube/config by default
+    -context string
+            Kubeconfig context used by -run and -watch, the current context by default
+    -validate
+            Validate resources and fields of the parsed queries
+    -schemas string
+            Comma separated OpenAPI or CRD schema files and directories used by -validate
     -help
             Show this help message
     -version
@@ -98,12 +403,67 @@ EXAMPLES:
     # Query using quotes to handle special characters
     sql "SELECT name, type, clusterIP FROM services WHERE namespace='default'"
 
+    # Parse every query of a runbook script, comments are kept
+    sql -file runbook.ksql
+
+    # Turn a query saved as YAML back into SQL
+    sql -format yaml "SELECT name FROM pods WHERE status.phase='Running'" > query.yaml
+    sql -format text -load query.yaml
+
+    # Run a query against the cluster of the current context, printing a table
+    sql -run -format text "SELECT name, status.phase FROM kube-system/pods"
+
+    # Run a query against another context, label conditions become selectors
+    sql -run -context prod "SELECT name FROM deployments WHERE labels.app='web'"
+
+    # Query the contexts matching prod-*, a failing cluster prints a warning
+    sql -run -format text "SELECT cluster, name FROM prod-*:kube-system/pods WHERE status.phase != 'Running' ORDER BY cluster"
+
+    # Watch a rollout, printing rows as they are added, updated and removed
+    sql -watch -format text "SELECT name, status.phase FROM pods WHERE labels.app='web' ORDER BY name"
+
+    # Run a saved query against a cluster snapshot, printing a table
+    kubectl get pods -A -o yaml > pods.yaml
+    sql -format text -load query.yaml -data pods.yaml
+
+    # Compare snapshots of two clusters
+    sql -format text -data prod=prod-pods.yaml,staging=staging-pods.yaml "SELECT cluster, name, status.phase FROM *:pods ORDER BY name, cluster"
+
+    # Check fields and value types against a saved OpenAPI document
+    kubectl get --raw /openapi/v3/api/v1 > core.json
+    sql -validate -schemas core.json "SELECT name FROM pods WHERE spec.replicas > 1"
+
+    # Explore a saved cluster snapshot interactively
+    kubectl get pods,deployments -A -o yaml > snapshot.yaml
+    sql repl -data snapshot.yaml
+
+    # Show how a query runs: selectors sent to the API server, client side
+    # filtering and sorting, and estimated cost
+    sql -format text "EXPLAIN SELECT name FROM pods WHERE labels.app='web' ORDER BY created"
+
+    # Enable shell completion of flags and subcommands
+    source <(sql completion bash)
+
+    # Run the language server for .ksql files, started by an editor
+    sql lsp -schemas core.json
+
+    # Print the JSON Schema of the JSON output, versioned by its apiVersion
+    sql schema > query.schema.json
+
 SUPPORTED SQL FEATURES:
     - SELECT with field selection and aliases
     - FROM with Kubernetes resource types
     - WHERE with filter conditions
     - ORDER BY with ASC/DESC sorting
     - LIMIT for result count restriction
+    - EXPLAIN to show the query plan instead of the parsed query
+    - -- line comments, /* block comments */ and ';' separated scripts
+
+REPL:
+    Queries end with ';' and may span several lines. Tab completes keywords,
+    resources and fields. The history is kept in ~/.kubesql_history.
+    Meta-commands:
+    \format text|json|yaml, \source FILE, \load FILE, \explain QUERY, \help, \q
 
 `)
 }
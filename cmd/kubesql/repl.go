@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+	"golang.org/x/term"
+)
+
+const (
+	replPrompt             = "kubesql> "
+	replContinuationPrompt = "     -> "
+)
+
+// lineReader reads the REPL input line by line.
+type lineReader interface {
+	ReadLine() (string, error)
+	SetPrompt(prompt string)
+}
+
+// scanReader reads lines from a non interactive input, such as a pipe.
+type scanReader struct {
+	scanner *bufio.Scanner
+}
+
+// ReadLine implements lineReader.
+func (r *scanReader) ReadLine() (string, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// SetPrompt implements lineReader, prompts are not shown for piped input.
+func (r *scanReader) SetPrompt(string) {}
+
+// repl is an interactive session running queries against a loaded dataset.
+type repl struct {
+	out       io.Writer
+	format    string
+	dataset   *kubesql.Dataset
+	resolver  *kubesql.StaticResolver
+	validator *kubesql.Validator
+	completer *kubesql.Completer
+	pending   strings.Builder // Lines of the statement being written
+}
+
+// runRepl runs "kubesql repl": it reads queries terminated by ';' and runs
+// them against the objects loaded with -data.
+func runRepl(args []string) {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	dataPaths := flags.String("data", "", "Comma separated JSON or YAML files and directories of objects to query")
+	schemaPaths := flags.String("schemas", "", "Comma separated OpenAPI or CRD schema files and directories used to validate queries")
+	format := flags.String("format", "text", "Output format: text, json or yaml")
+	historyFile := flags.String("history", defaultHistoryFile(), "File the input history is kept in, empty to disable")
+	_ = flags.Parse(args)
+
+	r := &repl{out: os.Stdout, dataset: kubesql.NewDataset(), resolver: kubesql.DefaultResolver()}
+	if err := r.setFormat(*format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *dataPaths != "" {
+		dataset, err := kubesql.LoadDataset(strings.Split(*dataPaths, ",")...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading data: %v\n", err)
+			os.Exit(1)
+		}
+		r.dataset = dataset
+	}
+
+	var schemas kubesql.SchemaProvider
+	if *schemaPaths != "" {
+		loaded, err := kubesql.LoadSchemas(strings.Split(*schemaPaths, ",")...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading schemas: %v\n", err)
+			os.Exit(1)
+		}
+		r.resolver.Add(loaded.Resources()...)
+		schemas = loaded
+	}
+	r.validator = kubesql.NewValidator(r.resolver, schemas)
+	r.completer = kubesql.NewCompleter(r.resolver, schemas)
+
+	// Edit lines in raw mode when the input is a terminal
+	var reader lineReader = &scanReader{scanner: bufio.NewScanner(os.Stdin)}
+	interactive := term.IsTerminal(int(os.Stdin.Fd()))
+	if interactive {
+		state, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error setting up the terminal: %v\n", err)
+			os.Exit(1)
+		}
+		defer term.Restore(int(os.Stdin.Fd()), state)
+
+		history, err := loadHistory(*historyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading history: %v\n", err)
+			history = &fileHistory{path: *historyFile}
+		}
+		terminal := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, replPrompt)
+		terminal.History = history
+		terminal.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+			if key != '\t' {
+				return "", 0, false
+			}
+			return r.completeLine(r.pending.String(), line, pos)
+		}
+		r.out = terminal
+		reader = terminal
+
+		fmt.Fprintf(r.out, "kubesql repl, %d objects loaded. Type \\help for help, \\q to quit.\n", r.dataset.Len())
+	}
+
+	r.loop(reader, interactive)
+}
+
+// loop reads and runs input until the end of the input or \q. Statements
+// may span several lines and run once terminated by ';'. In interactive
+// mode Ctrl-C discards a pending statement.
+func (r *repl) loop(reader lineReader, interactive bool) {
+	pending := &r.pending
+	for {
+		if pending.Len() == 0 {
+			reader.SetPrompt(replPrompt)
+		} else {
+			reader.SetPrompt(replContinuationPrompt)
+		}
+
+		line, err := reader.ReadLine()
+		if errors.Is(err, io.EOF) {
+			if pending.Len() == 0 {
+				return
+			}
+			if !interactive {
+				// Run an unterminated last statement of a piped script
+				r.run(pending.String())
+				return
+			}
+			pending.Reset()
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(r.out, "Error reading input: %v\n", err)
+			return
+		}
+
+		if pending.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), `\`) {
+			if quit := r.meta(strings.TrimSpace(line)); quit {
+				return
+			}
+			continue
+		}
+
+		pending.WriteString(line)
+		pending.WriteString("\n")
+		if kubesql.ScriptComplete(pending.String()) {
+			r.run(pending.String())
+			pending.Reset()
+		}
+	}
+}
+
+// meta runs a meta-command such as \format or \source, and reports whether
+// the session should end.
+func (r *repl) meta(line string) bool {
+	command, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch command {
+	case `\q`, `\quit`:
+		return true
+	case `\h`, `\help`, `\?`:
+		r.help()
+	case `\format`:
+		if arg == "" {
+			fmt.Fprintf(r.out, "Output format is %s\n", r.format)
+		} else if err := r.setFormat(arg); err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+		}
+	case `\source`, `\i`:
+		script, err := os.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintf(r.out, "Error reading script: %v\n", err)
+			break
+		}
+		r.run(string(script))
+	case `\load`:
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintf(r.out, "Error reading queries: %v\n", err)
+			break
+		}
+		queries, err := kubesql.UnmarshalQueries(data)
+		if err != nil {
+			fmt.Fprintf(r.out, "Error loading queries: %v\n", err)
+			break
+		}
+		r.runQueries(queries)
+	case `\explain`:
+		r.explain(arg)
+	default:
+		fmt.Fprintf(r.out, "Error: unknown command '%s', type \\help for help\n", command)
+	}
+	return false
+}
+
+// setFormat sets the output format of query results.
+func (r *repl) setFormat(format string) error {
+	switch format = strings.ToLower(format); format {
+	case "text", "json", "yaml":
+		r.format = format
+		return nil
+	}
+	return fmt.Errorf("unsupported output format '%s', supported formats: text, json, yaml", format)
+}
+
+// run parses, validates and runs the statements of a script, printing the
+// results of each.
+func (r *repl) run(script string) {
+	queries, err := kubesql.ParseScript(script)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error parsing query: %v\n", err)
+		return
+	}
+	r.runQueries(queries)
+}
+
+// runQueries validates and runs queries, printing the results of each.
+func (r *repl) runQueries(queries []*kubesql.Query) {
+	for _, query := range queries {
+		if !r.validate(query) {
+			continue
+		}
+		if query.Explain {
+			r.writePlan(query)
+			continue
+		}
+		result, err := r.dataset.Execute(query, r.resolver)
+		if err != nil {
+			fmt.Fprintf(r.out, "Error running query: %v\n", err)
+			continue
+		}
+
+		if r.format == "text" {
+			writeTable(r.out, result)
+		} else if err := encodeOutput(r.out, r.format, resultRecords(result)); err != nil {
+			fmt.Fprintf(r.out, "Error: %v\n", err)
+		}
+	}
+}
+
+// validate validates a query, printing the problems found, and reports
+// whether it is valid.
+func (r *repl) validate(query *kubesql.Query) bool {
+	err := r.validator.Validate(query)
+	if err == nil {
+		return true
+	}
+
+	var validationErrs kubesql.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		for _, validationErr := range validationErrs {
+			fmt.Fprintf(r.out, "Error validating query: %v\n", validationErr)
+		}
+	} else {
+		fmt.Fprintf(r.out, "Error validating query: %v\n", err)
+	}
+	return false
+}
+
+// writePlan prints the plan of a query, estimated over the loaded objects.
+func (r *repl) writePlan(query *kubesql.Query) {
+	planner := kubesql.NewPlanner(r.resolver)
+	planner.Counts = func(resource kubesql.ResourceInfo) int {
+		return len(r.dataset.Objects(resource))
+	}
+	plan, err := planner.Plan(query)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error planning query: %v\n", err)
+		return
+	}
+
+	if r.format == "text" {
+		fmt.Fprint(r.out, plan)
+	} else if err := encodeOutput(r.out, r.format, plan); err != nil {
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+	}
+}
+
+// explain describes how a query is understood: its canonical form, the
+// resolved resource, the typed result columns and the query plan.
+func (r *repl) explain(text string) {
+	query, err := kubesql.NewParser(text).Parse()
+	if err != nil {
+		fmt.Fprintf(r.out, "Error parsing query: %v\n", err)
+		return
+	}
+
+	fmt.Fprintf(r.out, "Query:    %s\n", query)
+	if ref, err := kubesql.ParseResourceRef(query.From); err == nil {
+		if resource, err := r.resolver.Resolve(ref.Resource); err == nil {
+			scope := "all namespaces"
+			switch {
+			case !resource.Namespaced:
+				scope = "cluster scoped"
+			case ref.Namespace != "":
+				scope = "namespace " + ref.Namespace
+			}
+			version := resource.Version
+			if resource.Group != "" {
+				version = resource.Group + "/" + version
+			}
+			fmt.Fprintf(r.out, "Resource: %s (%s %s, %s)\n", resource.Name, version, resource.Kind, scope)
+		}
+	}
+
+	valid := r.validate(query)
+	if len(query.Columns) > 0 {
+		fmt.Fprintln(r.out, "Columns:")
+		for _, column := range query.Columns {
+			fmt.Fprintf(r.out, "  %s %s\n", column.Name, column.Type)
+		}
+	}
+	if valid {
+		fmt.Fprintln(r.out, "Query is valid")
+		fmt.Fprintln(r.out, "Plan:")
+		r.writePlan(query)
+	}
+}
+
+// help prints the REPL usage.
+func (r *repl) help() {
+	fmt.Fprint(r.out, `Enter queries terminated by ';', they may span several lines.
+
+Commands:
+  \format [text|json|yaml]   Show or set the output format
+  \source FILE               Run the queries of a script file
+  \load FILE                 Run the structured queries of a JSON or YAML file
+  \explain QUERY             Show how a query is resolved, typed and run
+  \help                      Show this help
+  \q                         Quit
+`)
+}
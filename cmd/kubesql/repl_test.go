@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+)
+
+// newTestRepl returns a session over two pods, writing to out.
+func newTestRepl(out *bytes.Buffer) *repl {
+	r := &repl{out: out, format: "text", dataset: kubesql.NewDataset(), resolver: kubesql.DefaultResolver()}
+	r.dataset.Add(
+		map[string]any{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]any{"name": "web", "namespace": "default"}},
+		map[string]any{"apiVersion": "v1", "kind": "Pod", "metadata": map[string]any{"name": "db", "namespace": "default"}},
+	)
+	r.validator = kubesql.NewValidator(r.resolver, nil)
+	return r
+}
+
+func TestReplMeta(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "pods.ksql")
+	if err := os.WriteFile(script, []byte("SELECT name FROM pods ORDER BY name;"), 0o600); err != nil {
+		t.Fatalf("Error writing script: %v", err)
+	}
+	queries := filepath.Join(dir, "pods.yaml")
+	if err := os.WriteFile(queries, []byte("apiVersion: kubesql/v1\nselect:\n- field: name\nfrom: pods\nwhere: name = 'db'\n"), 0o600); err != nil {
+		t.Fatalf("Error writing queries: %v", err)
+	}
+
+	testCases := []struct {
+		line     string
+		expected string
+		format   string
+		quit     bool
+	}{
+		{`\format`, "Output format is text\n", "text", false},
+		{`\format JSON`, "", "json", false},
+		{`\format table`, "Error: unsupported output format 'table', supported formats: text, json, yaml\n", "text", false},
+		{`\help`, `\format [text|json|yaml]`, "text", false},
+		{`\source ` + script, "NAME\ndb\nweb\n(2 rows)\n", "text", false},
+		{`\source missing.ksql`, "Error reading script: ", "text", false},
+		{`\load ` + queries, "NAME\ndb\n(1 row)\n", "text", false},
+		{`\explain SELECT name FROM pods`, "Query:    SELECT name FROM pods\n", "text", false},
+		{`\nope`, "Error: unknown command '\\nope', type \\help for help\n", "text", false},
+		{`\q`, "", "text", true},
+		{`\quit`, "", "text", true},
+	}
+
+	for _, tc := range testCases {
+		out := &bytes.Buffer{}
+		r := newTestRepl(out)
+
+		if quit := r.meta(tc.line); quit != tc.quit {
+			t.Errorf("For input '%s', expected quit %v, got %v", tc.line, tc.quit, quit)
+		}
+		if !strings.Contains(out.String(), tc.expected) || (tc.expected == "" && out.Len() > 0) {
+			t.Errorf("For input '%s', expected output containing %q, got %q", tc.line, tc.expected, out.String())
+		}
+		if r.format != tc.format {
+			t.Errorf("For input '%s', expected format %s, got %s", tc.line, tc.format, r.format)
+		}
+	}
+}
+
+func TestReplLoop(t *testing.T) {
+	input := "SELECT name\n  FROM pods\n  WHERE name = 'web';\n\\format yaml\nSELECT name FROM pods WHERE name = 'db'"
+	out := &bytes.Buffer{}
+	r := newTestRepl(out)
+
+	r.loop(&scanReader{scanner: bufio.NewScanner(strings.NewReader(input))}, false)
+
+	expected := "NAME\nweb\n(1 row)\n- name: db\n"
+	if out.String() != expected {
+		t.Errorf("Expected output %q, got %q", expected, out.String())
+	}
+}
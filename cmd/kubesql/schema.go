@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+)
+
+// runSchema runs "kubesql schema": it prints the JSON Schema of the JSON
+// form of queries written by the CLI.
+func runSchema(args []string) {
+	if len(args) != 0 {
+		fmt.Fprintf(os.Stderr, "Usage: kubesql schema\n")
+		os.Exit(1)
+	}
+	os.Stdout.Write(kubesql.QueryJSONSchema())
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+)
+
+// writeTable prints a query result as a table, with a header of the
+// upper case column names, followed by the number of rows.
+func writeTable(out io.Writer, result *kubesql.Result) {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, strings.Join(tableHeaders(result.Columns), "\t"))
+	for _, row := range result.Rows {
+		fmt.Fprintln(w, strings.Join(tableCells(result.Columns, row), "\t"))
+	}
+	w.Flush()
+
+	if len(result.Rows) == 1 {
+		fmt.Fprintln(out, "(1 row)")
+	} else {
+		fmt.Fprintf(out, "(%d rows)\n", len(result.Rows))
+	}
+}
+
+// tableHeaders returns the upper case column names of a table.
+func tableHeaders(columns []kubesql.Column) []string {
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = strings.ToUpper(column.Name)
+		if column.Name == "*" {
+			headers[i] = "NAMESPACE/NAME"
+		}
+	}
+	return headers
+}
+
+// tableCells returns the cells of a row of a table, whole objects are
+// shown by name.
+func tableCells(columns []kubesql.Column, row []any) []string {
+	cells := make([]string, len(row))
+	for i, value := range row {
+		if columns[i].Name == "*" {
+			cells[i] = objectName(value)
+		} else {
+			cells[i] = formatCell(value)
+		}
+	}
+	return cells
+}
+
+// formatCell formats a value for a table cell. Lists and maps are shown as
+// compact JSON and missing values as <none>, like kubectl does.
+func formatCell(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "<none>"
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case time.Time:
+		return value.Format(time.RFC3339)
+	case time.Duration:
+		return value.String()
+	case map[string]any, []any:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprint(value)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// objectName returns the namespace/name of a whole object, or its name for
+// cluster scoped objects.
+func objectName(value any) string {
+	object, _ := value.(map[string]any)
+	metadata, _ := object["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	if namespace, _ := metadata["namespace"].(string); namespace != "" {
+		return namespace + "/" + name
+	}
+	return name
+}
+
+// resultRecords converts result rows into records keyed by column name,
+// for JSON and YAML output. Durations are written as text, e.g. "1h30m0s".
+func resultRecords(result *kubesql.Result) []map[string]any {
+	records := make([]map[string]any, len(result.Rows))
+	for i, row := range result.Rows {
+		records[i] = rowRecord(result.Columns, row)
+	}
+	return records
+}
+
+// rowRecord converts a row into a record keyed by column name.
+func rowRecord(columns []kubesql.Column, row []any) map[string]any {
+	record := make(map[string]any, len(row))
+	for j, value := range row {
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		record[columns[j].Name] = value
+	}
+	return record
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+	"gopkg.in/yaml.v3"
+)
+
+// watchQuery runs a query against the cluster, then prints the changes of
+// its rows as the objects change, until interrupted. The text format prints
+// a table with the type of each change, like kubectl get --watch; JSON
+// prints one change per line and YAML one document per change.
+func watchQuery(query *kubesql.Query) {
+	if query.Explain {
+		fmt.Fprintf(os.Stderr, "Error: can not watch an EXPLAIN statement\n")
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	resolver, _ := loadResolver()
+	runner := loadRunner(resolver)
+	view, err := kubesql.NewView(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running query: %v\n", err)
+		os.Exit(1)
+	}
+
+	writer := &changeWriter{out: os.Stdout, format: strings.ToLower(*outputFormat), columns: view.Columns}
+	err = runner.Watch(ctx, query, writer.write)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "Error watching query: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// changeWriter prints the changes of the rows of a watched query.
+type changeWriter struct {
+	out     io.Writer
+	format  string
+	columns []kubesql.Column
+	header  bool // Whether the table header was printed
+}
+
+// write prints a batch of changes. Text tables are aligned per batch.
+func (w *changeWriter) write(changes []kubesql.Change) error {
+	switch w.format {
+	case "text":
+		table := tabwriter.NewWriter(w.out, 0, 0, 3, ' ', 0)
+		if !w.header {
+			fmt.Fprintln(table, strings.Join(append([]string{"CHANGE", "INDEX"}, tableHeaders(w.columns)...), "\t"))
+			w.header = true
+		}
+		for _, change := range changes {
+			cells := append([]string{string(change.Type), strconv.Itoa(change.Index)}, tableCells(w.columns, change.Row)...)
+			fmt.Fprintln(table, strings.Join(cells, "\t"))
+		}
+		return table.Flush()
+	case "json":
+		encoder := json.NewEncoder(w.out)
+		encoder.SetEscapeHTML(false)
+		for _, change := range changes {
+			if err := encoder.Encode(w.record(change)); err != nil {
+				return fmt.Errorf("error marshaling to JSON: %w", err)
+			}
+		}
+		return nil
+	case "yaml":
+		for _, change := range changes {
+			output, err := yaml.Marshal(w.record(change))
+			if err != nil {
+				return fmt.Errorf("error marshaling to YAML: %w", err)
+			}
+			if _, err := fmt.Fprintf(w.out, "---\n%s", output); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported output format '%s'. Supported formats: json, yaml, text", w.format)
+}
+
+// record returns the JSON and YAML form of a change, with its row as a
+// record keyed by column name.
+func (w *changeWriter) record(change kubesql.Change) map[string]any {
+	return map[string]any{
+		"type":  change.Type,
+		"key":   change.Key,
+		"index": change.Index,
+		"row":   rowRecord(w.columns, change.Row),
+	}
+}
@@ -1,8 +1,14 @@
 module github.com/yaacov/kubesql-interpreter
 
-go 1.24
+go 1.25.0
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	golang.org/x/term v0.45.0
+	google.golang.org/protobuf v1.36.9
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.47.0 // indirect
 
 // This module provides a SQL-like parser for kubectl queries
 // enabling SQL syntax for querying Kubernetes resources
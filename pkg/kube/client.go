@@ -0,0 +1,373 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+)
+
+// DefaultTimeout is the timeout of requests to the API server, except
+// watches.
+const DefaultTimeout = 30 * time.Second
+
+// WatchTimeout is the time after which the API server ends a watch.
+const WatchTimeout = 5 * time.Minute
+
+// Client lists and watches objects from the API server of a cluster. It
+// implements kubesql.Watcher.
+type Client struct {
+	Config     *Config      // Connection to the cluster
+	HTTPClient *http.Client // Sends the requests, with the TLS settings of Config
+
+	mu         sync.Mutex
+	credential *execCredential // Credential of the exec plugin, until it expires
+}
+
+// NewClient creates a client for a cluster connection.
+func NewClient(config *Config) (*Client, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: config.Insecure,
+		ServerName:         config.TLSServerName,
+	}
+	if len(config.CAData) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(config.CAData) {
+			return nil, errors.New("invalid certificate authority data")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(config.CertData) > 0 || len(config.KeyData) > 0 {
+		cert, err := tls.X509KeyPair(config.CertData, config.KeyData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	client := &Client{Config: config}
+	if config.Exec != nil {
+		// Exec plugins may issue a client certificate instead of a token
+		tlsConfig.GetClientCertificate = client.clientCertificate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	client.HTTPClient = &http.Client{Transport: transport, Timeout: DefaultTimeout}
+	return client, nil
+}
+
+// objectList is the API form of a list of objects.
+type objectList struct {
+	Kind       string           `json:"kind"`
+	APIVersion string           `json:"apiVersion"`
+	Items      []map[string]any `json:"items"`
+	Metadata   struct {
+		Continue        string `json:"continue"`
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+}
+
+// status is the API form of a failed request.
+type status struct {
+	Message string `json:"message"`
+	Reason  string `json:"reason"`
+}
+
+// statusError is the error of a failed request, with the Status returned
+// by the API server.
+type statusError struct {
+	code    int    // HTTP status code, e.g. 410
+	status  string // HTTP status, e.g. "410 Gone"
+	failure status
+}
+
+func (e *statusError) Error() string {
+	if e.failure.Message != "" {
+		return fmt.Sprintf("%s: %s", e.status, e.failure.Message)
+	}
+	return e.status
+}
+
+// List implements kubesql.DataSource, listing a page of the objects of a
+// resource. Items of API lists have no kind and apiVersion, they are set
+// from the list.
+func (c *Client) List(ctx context.Context, resource kubesql.ResourceInfo, opts kubesql.ListOptions) (*kubesql.ObjectList, error) {
+	response, err := c.get(ctx, c.HTTPClient, c.resourceURL(resource, opts, false))
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var list objectList
+	if err := json.NewDecoder(response.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("error decoding list: %w", err)
+	}
+	kind := strings.TrimSuffix(list.Kind, "List")
+	if kind == "" {
+		kind = resource.Kind
+	}
+	for _, item := range list.Items {
+		setType(item, kind, list.APIVersion)
+	}
+	return &kubesql.ObjectList{Items: list.Items, Continue: list.Metadata.Continue, ResourceVersion: list.Metadata.ResourceVersion}, nil
+}
+
+// watchEvent is the API form of a watch event.
+type watchEvent struct {
+	Type   kubesql.EventType `json:"type"`
+	Object map[string]any    `json:"object"`
+}
+
+// Watch implements kubesql.Watcher, streaming the changes of the objects of
+// a resource after opts.ResourceVersion, with bookmarks. The server ends
+// the watch after WatchTimeout. A stream that can not be read, e.g. when
+// the connection drops, ends with an ERROR event, and so does a watch the
+// server rejects because opts.ResourceVersion is too old.
+func (c *Client) Watch(ctx context.Context, resource kubesql.ResourceInfo, opts kubesql.ListOptions) (<-chan kubesql.Event, error) {
+	// Watches last longer than the timeout of other requests
+	client := *c.HTTPClient
+	client.Timeout = 0
+	response, err := c.get(ctx, &client, c.resourceURL(resource, opts, true))
+	var failure *statusError
+	if errors.As(err, &failure) && failure.code == http.StatusGone {
+		events := make(chan kubesql.Event, 1)
+		events <- kubesql.Event{Type: kubesql.EventError, Object: map[string]any{
+			"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "Expired",
+			"message": failure.failure.Message, "code": float64(failure.code),
+		}}
+		close(events)
+		return events, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan kubesql.Event)
+	go func() {
+		defer close(events)
+		defer response.Body.Close()
+		decoder := json.NewDecoder(response.Body)
+		for {
+			var event watchEvent
+			if err := decoder.Decode(&event); err != nil {
+				if errors.Is(err, io.EOF) || ctx.Err() != nil {
+					return
+				}
+				status := map[string]any{
+					"kind": "Status", "apiVersion": "v1", "status": "Failure", "reason": "InternalError",
+					"message": fmt.Sprintf("error decoding watch event: %v", err), "code": float64(http.StatusInternalServerError),
+				}
+				select {
+				case events <- kubesql.Event{Type: kubesql.EventError, Object: status}:
+				case <-ctx.Done():
+				}
+				return
+			}
+			if event.Type != kubesql.EventError && event.Type != kubesql.EventBookmark {
+				setType(event.Object, resource.Kind, apiVersion(resource))
+			}
+			select {
+			case events <- kubesql.Event{Type: event.Type, Object: event.Object}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// get sends a GET request, and returns a statusError for a failed
+// response.
+func (c *Client) get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if err := c.authorize(ctx, request); err != nil {
+		return nil, err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 200 && response.StatusCode <= 299 {
+		return response, nil
+	}
+	defer response.Body.Close()
+	failure := &statusError{code: response.StatusCode, status: response.Status}
+	if body, err := io.ReadAll(response.Body); err == nil {
+		json.Unmarshal(body, &failure.failure)
+	}
+	return nil, failure
+}
+
+// setType sets the kind and apiVersion of an object when missing.
+func setType(object map[string]any, kind, apiVersion string) {
+	if _, ok := object["kind"]; !ok && kind != "" {
+		object["kind"] = kind
+	}
+	if _, ok := object["apiVersion"]; !ok && apiVersion != "" {
+		object["apiVersion"] = apiVersion
+	}
+}
+
+// apiVersion returns the apiVersion of the objects of a resource, e.g.
+// "apps/v1".
+func apiVersion(resource kubesql.ResourceInfo) string {
+	if resource.Group == "" {
+		return resource.Version
+	}
+	return resource.Group + "/" + resource.Version
+}
+
+// resourceURL returns the URL listing or watching the objects of a
+// resource, e.g. /apis/apps/v1/namespaces/default/deployments?limit=500.
+func (c *Client) resourceURL(resource kubesql.ResourceInfo, opts kubesql.ListOptions, watch bool) string {
+	path := "/api/" + resource.Version
+	if resource.Group != "" {
+		path = "/apis/" + apiVersion(resource)
+	}
+	if resource.Namespaced && opts.Namespace != "" {
+		path += "/namespaces/" + url.PathEscape(opts.Namespace)
+	}
+	path += "/" + resource.Name
+
+	query := url.Values{}
+	if opts.LabelSelector != "" {
+		query.Set("labelSelector", opts.LabelSelector)
+	}
+	if opts.FieldSelector != "" {
+		query.Set("fieldSelector", opts.FieldSelector)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Continue != "" {
+		query.Set("continue", opts.Continue)
+	}
+	if opts.ResourceVersion != "" {
+		query.Set("resourceVersion", opts.ResourceVersion)
+	}
+	if watch {
+		query.Set("watch", "true")
+		query.Set("allowWatchBookmarks", "true")
+		query.Set("timeoutSeconds", strconv.Itoa(int(WatchTimeout.Seconds())))
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return strings.TrimSuffix(c.Config.Server, "/") + path
+}
+
+// authorize sets the credentials of a request: a bearer token, from the
+// config, its token file or the exec plugin, or basic authentication.
+func (c *Client) authorize(ctx context.Context, request *http.Request) error {
+	token := c.Config.Token
+	if token == "" && c.Config.TokenFile != "" {
+		data, err := os.ReadFile(c.Config.TokenFile)
+		if err != nil {
+			return fmt.Errorf("error reading token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token == "" && c.Config.Exec != nil {
+		credential, err := c.execCredential(ctx)
+		if err != nil {
+			return err
+		}
+		token = credential.Status.Token
+	}
+
+	switch {
+	case token != "":
+		request.Header.Set("Authorization", "Bearer "+token)
+	case c.Config.Username != "":
+		request.SetBasicAuth(c.Config.Username, c.Config.Password)
+	}
+	return nil
+}
+
+// execCredential is the credential printed by an exec plugin.
+type execCredential struct {
+	Status struct {
+		Token                 string    `json:"token"`
+		ClientCertificateData string    `json:"clientCertificateData"`
+		ClientKeyData         string    `json:"clientKeyData"`
+		ExpirationTimestamp   time.Time `json:"expirationTimestamp"`
+	} `json:"status"`
+}
+
+// execCredential returns the credential of the exec plugin, running it when
+// there is none or the last one expired.
+func (c *Client) execCredential(ctx context.Context) (*execCredential, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.credential != nil && (c.credential.Status.ExpirationTimestamp.IsZero() || time.Now().Before(c.credential.Status.ExpirationTimestamp)) {
+		return c.credential, nil
+	}
+
+	plugin := c.Config.Exec
+	apiVersion := plugin.APIVersion
+	if apiVersion == "" {
+		apiVersion = "client.authentication.k8s.io/v1"
+	}
+	command := exec.CommandContext(ctx, plugin.Command, plugin.Args...)
+	command.Env = os.Environ()
+	for _, env := range plugin.Env {
+		command.Env = append(command.Env, env.Name+"="+env.Value)
+	}
+	// The plugin gets the request as JSON, with the same apiVersion
+	request := fmt.Sprintf(`{"apiVersion":%q,"kind":"ExecCredential","spec":{"interactive":false}}`, apiVersion)
+	command.Env = append(command.Env, "KUBERNETES_EXEC_INFO="+request)
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+
+	output, err := command.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running credential plugin %s: %w: %s", plugin.Command, err, strings.TrimSpace(stderr.String()))
+	}
+	var credential execCredential
+	if err := json.Unmarshal(output, &credential); err != nil {
+		return nil, fmt.Errorf("error decoding credential of plugin %s: %w", plugin.Command, err)
+	}
+	if credential.Status.Token == "" && credential.Status.ClientCertificateData == "" {
+		return nil, fmt.Errorf("credential plugin %s returned no token or client certificate", plugin.Command)
+	}
+	c.credential = &credential
+	return c.credential, nil
+}
+
+// clientCertificate returns the client certificate of the TLS handshake:
+// the one of the config, or the one issued by the exec plugin.
+func (c *Client) clientCertificate(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if len(c.Config.CertData) > 0 {
+		cert, err := tls.X509KeyPair(c.Config.CertData, c.Config.KeyData)
+		return &cert, err
+	}
+	credential, err := c.execCredential(info.Context())
+	if err != nil {
+		return nil, err
+	}
+	if credential.Status.ClientCertificateData == "" {
+		// No certificate, the plugin issued a token
+		return &tls.Certificate{}, nil
+	}
+	cert, err := tls.X509KeyPair([]byte(credential.Status.ClientCertificateData), []byte(credential.Status.ClientKeyData))
+	return &cert, err
+}
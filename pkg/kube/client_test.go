@@ -0,0 +1,408 @@
+package kube
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+)
+
+// page1Continue is the continue token of testdata/pods-page-1.json.
+const page1Continue = "eyJ2IjoibWV0YS5rOHMuaW8vdjEiLCJydiI6MTA0Miwic3RhcnQiOiJkZWZhdWx0L3dlYi0yXHUwMDAwIn0"
+
+// recordedResponses are the files of testdata served by the fake API
+// server, by request URL.
+var recordedResponses = map[string]string{
+	"/api/v1/pods?limit=2":                                 "pods-page-1.json",
+	"/api/v1/pods?continue=" + page1Continue + "&limit=2":  "pods-page-2.json",
+	"/api/v1/pods?labelSelector=app%3Dweb&limit=2":         "pods-web.json",
+	"/apis/apps/v1/namespaces/default/deployments?limit=2": "deployments-default.json",
+	"/api/v1/secrets?limit=2":                              "forbidden.json",
+
+	"/api/v1/pods?allowWatchBookmarks=true&labelSelector=app%3Dweb&resourceVersion=1042&timeoutSeconds=300&watch=true": "pods-watch.json",
+	"/api/v1/pods?allowWatchBookmarks=true&labelSelector=app%3Dweb&resourceVersion=1046&timeoutSeconds=300&watch=true": "watch-expired.json",
+}
+
+// fakeAPIServer is a TLS API server serving recorded list responses to
+// requests with the bearer token, and recording the requested URLs.
+// Requests may be concurrent, e.g. for several contexts.
+func fakeAPIServer(t *testing.T, token string) (*httptest.Server, *[]string) {
+	var requests []string
+	var mu sync.Mutex
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.String())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","message":"Unauthorized","reason":"Unauthorized","code":401}`))
+			return
+		}
+		file, ok := recordedResponses[r.URL.String()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","message":"the server could not find the requested resource","reason":"NotFound","code":404}`))
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Errorf("Expected no error reading %s, got %v", file, err)
+		}
+		if file == "forbidden.json" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// serverCA returns the PEM certificate of a test server.
+func serverCA(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+func TestClientRun(t *testing.T) {
+	server, requests := fakeAPIServer(t, "secret-token")
+	client, err := NewClient(&Config{Server: server.URL, CAData: serverCA(server), Token: "secret-token"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected [][]any
+		requests []string
+	}{
+		{
+			"SELECT name, namespace FROM pods ORDER BY name",
+			[][]any{{"coredns", "kube-system"}, {"web-1", "default"}, {"web-2", "default"}},
+			[]string{"/api/v1/pods?limit=2", "/api/v1/pods?continue=" + page1Continue + "&limit=2"},
+		},
+		{
+			"SELECT name, kind, apiVersion FROM pods WHERE labels.app = 'web'",
+			[][]any{{"web-1", "Pod", "v1"}, {"web-2", "Pod", "v1"}},
+			[]string{"/api/v1/pods?labelSelector=app%3Dweb&limit=2"},
+		},
+		{
+			"SELECT name, kind, apiVersion, spec.replicas FROM default/deployments",
+			[][]any{{"web", "Deployment", "apps/v1", float64(2)}},
+			[]string{"/apis/apps/v1/namespaces/default/deployments?limit=2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		query, err := kubesql.NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		*requests = nil
+		runner := kubesql.NewRunner(client, kubesql.DefaultResolver())
+		runner.PageSize = 2
+
+		result, err := runner.Run(context.Background(), query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Rows, tc.expected) {
+			t.Errorf("For input '%s', expected rows %v, got %v", tc.input, tc.expected, result.Rows)
+		}
+		if !reflect.DeepEqual(*requests, tc.requests) {
+			t.Errorf("For input '%s', expected requests %v, got %v", tc.input, tc.requests, *requests)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	server, _ := fakeAPIServer(t, "secret-token")
+
+	testCases := []struct {
+		config   *Config
+		input    string
+		expected string
+	}{
+		{
+			&Config{Server: server.URL, CAData: serverCA(server), Token: "secret-token"},
+			"SELECT name FROM secrets",
+			"secrets is forbidden",
+		},
+		{
+			&Config{Server: server.URL, CAData: serverCA(server), Token: "secret-token"},
+			"SELECT name FROM services",
+			"the server could not find the requested resource",
+		},
+		{
+			&Config{Server: server.URL, CAData: serverCA(server), Token: "wrong-token"},
+			"SELECT name FROM pods",
+			"401 Unauthorized",
+		},
+		{
+			&Config{Server: server.URL, Token: "secret-token"},
+			"SELECT name FROM pods",
+			"certificate",
+		},
+	}
+
+	for _, tc := range testCases {
+		client, err := NewClient(tc.config)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		query, err := kubesql.NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		runner := kubesql.NewRunner(client, kubesql.DefaultResolver())
+		runner.PageSize = 2
+
+		_, err = runner.Run(context.Background(), query)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("For input '%s', expected error containing '%s', got %v", tc.input, tc.expected, err)
+		}
+	}
+
+	if _, err := NewClient(&Config{Server: server.URL, CAData: []byte("not a certificate")}); err == nil {
+		t.Errorf("Expected an error for invalid certificate authority data")
+	}
+}
+
+func TestClientExecCredential(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential plugin is a shell script")
+	}
+	server, _ := fakeAPIServer(t, "plugin-token")
+
+	dir := t.TempDir()
+	plugin := filepath.Join(dir, "plugin.sh")
+	script := "#!/bin/sh\necho '{\"apiVersion\":\"client.authentication.k8s.io/v1\",\"kind\":\"ExecCredential\",\"status\":{\"token\":\"'$PLUGIN_TOKEN'\"}}'\n"
+	if err := os.WriteFile(plugin, []byte(script), 0o755); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	client, err := NewClient(&Config{
+		Server: server.URL,
+		CAData: serverCA(server),
+		Exec:   &ExecConfig{Command: plugin, Env: []ExecEnvVar{{Name: "PLUGIN_TOKEN", Value: "plugin-token"}}},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	pods, _ := kubesql.DefaultResolver().Resolve("pods")
+	list, err := client.List(context.Background(), pods, kubesql.ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(list.Items) != 2 || list.Continue != page1Continue {
+		t.Errorf("Expected 2 pods and continue '%s', got %d and '%s'", page1Continue, len(list.Items), list.Continue)
+	}
+}
+
+func TestClientWatch(t *testing.T) {
+	server, requests := fakeAPIServer(t, "secret-token")
+	client, err := NewClient(&Config{Server: server.URL, CAData: serverCA(server), Token: "secret-token"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	query, err := kubesql.NewParser("SELECT name, status.phase FROM pods WHERE labels.app = 'web'").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	runner := kubesql.NewRunner(client, kubesql.DefaultResolver())
+	runner.PageSize = 2
+	runner.WatchBackoff = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var batches [][]kubesql.Change
+	err = runner.Watch(ctx, query, func(changes []kubesql.Change) error {
+		batches = append(batches, changes)
+		if len(batches) == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the watch to end with the context, got %v", err)
+	}
+
+	// The watch resumes after the last event, then expires and the pods are
+	// listed again
+	expected := [][]kubesql.Change{
+		{
+			{Type: kubesql.RowAdded, Key: "default/web-1", Index: 0, Row: []any{"web-1", "Running"}},
+			{Type: kubesql.RowAdded, Key: "default/web-2", Index: 1, Row: []any{"web-2", "Pending"}},
+		},
+		{{Type: kubesql.RowUpdated, Key: "default/web-2", Index: 1, Row: []any{"web-2", "Running"}}},
+		{{Type: kubesql.RowAdded, Key: "default/web-3", Index: 2, Row: []any{"web-3", "Pending"}}},
+		{{Type: kubesql.RowRemoved, Key: "default/web-1", Index: 0, Row: []any{"web-1", "Running"}}},
+		{
+			{Type: kubesql.RowRemoved, Key: "default/web-3", Index: 1, Row: []any{"web-3", "Pending"}},
+			{Type: kubesql.RowAdded, Key: "default/web-1", Index: 0, Row: []any{"web-1", "Running"}},
+			{Type: kubesql.RowUpdated, Key: "default/web-2", Index: 1, Row: []any{"web-2", "Pending"}},
+		},
+	}
+	if !reflect.DeepEqual(batches, expected) {
+		t.Errorf("Expected changes %+v, got %+v", expected, batches)
+	}
+	if len(*requests) != 4 {
+		t.Errorf("Expected a list, two watches and a list, got %v", *requests)
+	}
+}
+
+func TestClientWatchBrokenStream(t *testing.T) {
+	// The connection drops in the middle of the second event
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"type":"BOOKMARK","object":{"metadata":{"resourceVersion":"7"}}}` + "\n" + `{"type":"ADDED","obj`))
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewClient(&Config{Server: server.URL, CAData: serverCA(server)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	events, err := client.Watch(context.Background(), kubesql.ResourceInfo{Name: "pods", Version: "v1", Kind: "Pod", Namespaced: true}, kubesql.ListOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var types []kubesql.EventType
+	var message string
+	for event := range events {
+		types = append(types, event.Type)
+		if event.Type == kubesql.EventError {
+			message, _ = event.Object["message"].(string)
+		}
+	}
+	if !reflect.DeepEqual(types, []kubesql.EventType{kubesql.EventBookmark, kubesql.EventError}) {
+		t.Errorf("Expected a bookmark then an error, got %v", types)
+	}
+	if !strings.Contains(message, "error decoding watch event: unexpected EOF") {
+		t.Errorf("Expected a decoding error, got '%s'", message)
+	}
+}
+
+func TestClientWatchGone(t *testing.T) {
+	// Every watch is rejected, the resource version being too old
+	var requests []string
+	var mu sync.Mutex
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.URL.String())
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.WriteHeader(http.StatusGone)
+			w.Write([]byte(`{"kind":"Status","apiVersion":"v1","status":"Failure","message":"too old resource version: 7 (9)","reason":"Expired","code":410}`))
+			return
+		}
+		w.Write([]byte(`{"kind":"PodList","apiVersion":"v1","metadata":{"resourceVersion":"9"},"items":[{"metadata":{"name":"web-1","namespace":"default"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewClient(&Config{Server: server.URL, CAData: serverCA(server)})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	events, err := client.Watch(context.Background(), kubesql.ResourceInfo{Name: "pods", Version: "v1", Kind: "Pod", Namespaced: true}, kubesql.ListOptions{ResourceVersion: "7"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var received []kubesql.Event
+	for event := range events {
+		received = append(received, event)
+	}
+	if len(received) != 1 || received[0].Type != kubesql.EventError || received[0].Object["code"] != float64(410) {
+		t.Errorf("Expected an expired ERROR event, got %+v", received)
+	}
+
+	// The runner lists the objects again instead of failing
+	query, err := kubesql.NewParser("SELECT name FROM pods").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	runner := kubesql.NewRunner(client, kubesql.DefaultResolver())
+	runner.WatchBackoff = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lists := 0
+	err = runner.Watch(ctx, query, func(changes []kubesql.Change) error {
+		if lists++; lists == 2 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the watch to end with the context, got %v", err)
+	}
+	if lists != 2 {
+		t.Errorf("Expected the pods to be listed twice, got %d lists, requests %v", lists, requests)
+	}
+}
+
+func TestKubeconfigClusters(t *testing.T) {
+	server, _ := fakeAPIServer(t, "secret-token")
+	dir := t.TempDir()
+	writeKubeconfig(t, dir, "ca.crt", string(serverCA(server)))
+	path := writeKubeconfig(t, dir, "config", `apiVersion: v1
+kind: Config
+current-context: staging
+clusters:
+  - name: fake
+    cluster:
+      server: `+server.URL+`
+      certificate-authority: ca.crt
+contexts:
+  - {name: staging, context: {cluster: fake, user: admin}}
+  - {name: prod-us, context: {cluster: fake, user: admin}}
+  - {name: prod-eu, context: {cluster: fake, user: admin}}
+  - {name: prod-old, context: {cluster: fake, user: revoked}}
+users:
+  - {name: admin, user: {token: secret-token}}
+  - {name: revoked, user: {token: wrong-token}}
+`)
+
+	kubeconfig, err := LoadKubeconfig(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	contexts := []string{"prod-eu", "prod-old", "prod-us", "staging"}
+	if !reflect.DeepEqual(kubeconfig.Clusters(), contexts) {
+		t.Errorf("Expected clusters %v, got %v", contexts, kubeconfig.Clusters())
+	}
+
+	query, err := kubesql.NewParser("SELECT cluster, name, spec.replicas FROM prod-*:default/deployments").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	runner := kubesql.NewRunner(nil, kubesql.DefaultResolver())
+	runner.Clusters = kubeconfig
+	runner.PageSize = 2
+
+	result, err := runner.Run(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := [][]any{{"prod-eu", "web", float64(2)}, {"prod-us", "web", float64(2)}}
+	if !reflect.DeepEqual(result.Rows, expected) {
+		t.Errorf("Expected rows %v, got %v", expected, result.Rows)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].Cluster != "prod-old" || !strings.Contains(result.Errors[0].Error(), "401 Unauthorized") {
+		t.Errorf("Expected the error of cluster prod-old, got %v", result.Errors)
+	}
+
+	if _, err := kubeconfig.Source("missing"); err == nil {
+		t.Errorf("Expected an error for a missing context")
+	}
+}
@@ -0,0 +1,332 @@
+// Package kube runs KubeSQL queries against the API server of a cluster. It
+// reads kubeconfig files and lists objects over the REST API, implementing
+// kubesql.DataSource, and queries the clusters of several contexts,
+// implementing kubesql.ClusterSet.
+package kube
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the connection to a cluster, resolved from a kubeconfig context.
+type Config struct {
+	Context   string // Name of the kubeconfig context
+	Server    string // URL of the API server, e.g. "https://127.0.0.1:6443"
+	Namespace string // Namespace of the context, empty when not set
+
+	CAData        []byte // PEM certificates of the server CA, system roots when empty
+	Insecure      bool   // Skip verifying the server certificate
+	TLSServerName string // Server name used to verify the server certificate
+
+	CertData  []byte      // PEM client certificate
+	KeyData   []byte      // PEM client key
+	Token     string      // Bearer token
+	TokenFile string      // File the bearer token is read from, used when Token is empty
+	Username  string      // Basic authentication user
+	Password  string      // Basic authentication password
+	Exec      *ExecConfig // Credential plugin, run to get a token or client certificate
+}
+
+// ExecConfig is a client-go credential plugin, such as the ones cloud
+// providers use to issue short lived tokens.
+type ExecConfig struct {
+	Command    string       `yaml:"command"`    // Command to run, relative paths with a slash are relative to the kubeconfig file
+	Args       []string     `yaml:"args"`       // Arguments of the command
+	Env        []ExecEnvVar `yaml:"env"`        // Environment variables added for the command
+	APIVersion string       `yaml:"apiVersion"` // Version of the ExecCredential printed by the command
+}
+
+// ExecEnvVar is an environment variable set for a credential plugin.
+type ExecEnvVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// kubeconfig is the file format of kubeconfig files.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"` // Base64 encoded
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+			TLSServerName            string `yaml:"tls-server-name"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			ClientCertificate     string      `yaml:"client-certificate"`
+			ClientCertificateData string      `yaml:"client-certificate-data"` // Base64 encoded
+			ClientKey             string      `yaml:"client-key"`
+			ClientKeyData         string      `yaml:"client-key-data"` // Base64 encoded
+			Token                 string      `yaml:"token"`
+			TokenFile             string      `yaml:"tokenFile"`
+			Username              string      `yaml:"username"`
+			Password              string      `yaml:"password"`
+			Exec                  *ExecConfig `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// DefaultConfigPaths returns the kubeconfig files used when none is given:
+// the files of the KUBECONFIG environment variable, or ~/.kube/config.
+func DefaultConfigPaths() []string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		return filepath.SplitList(env)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// Kubeconfig is the merged content of kubeconfig files. It implements
+// kubesql.ClusterSet, with a cluster per context.
+type Kubeconfig struct {
+	CurrentContext string // Context used when none is named
+
+	merged kubeconfig
+	dirs   struct{ clusters, users map[string]string } // Directories of the files defining each cluster and user
+
+	mu      sync.Mutex
+	clients map[string]*Client // Clients of the contexts used as data sources
+}
+
+// LoadKubeconfig reads and merges kubeconfig files, DefaultConfigPaths when
+// none is given. Files are merged like kubectl does: the first file
+// defining a name wins, and missing files of the KUBECONFIG list are
+// skipped. Relative file paths are relative to the file they are found in.
+func LoadKubeconfig(paths ...string) (*Kubeconfig, error) {
+	if len(paths) == 0 {
+		paths = DefaultConfigPaths()
+	}
+
+	k := &Kubeconfig{clients: map[string]*Client{}}
+	k.dirs.clusters, k.dirs.users = map[string]string{}, map[string]string{}
+	contexts := map[string]bool{}
+	loaded := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) && len(paths) > 1 {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading kubeconfig: %w", err)
+		}
+		var file kubeconfig
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("error parsing kubeconfig %s: %w", path, err)
+		}
+		loaded++
+
+		dir := filepath.Dir(path)
+		if k.CurrentContext == "" {
+			k.CurrentContext = file.CurrentContext
+		}
+		for _, cluster := range file.Clusters {
+			if _, ok := k.dirs.clusters[cluster.Name]; !ok {
+				k.dirs.clusters[cluster.Name] = dir
+				k.merged.Clusters = append(k.merged.Clusters, cluster)
+			}
+		}
+		for _, user := range file.Users {
+			if _, ok := k.dirs.users[user.Name]; !ok {
+				k.dirs.users[user.Name] = dir
+				k.merged.Users = append(k.merged.Users, user)
+			}
+		}
+		for _, context := range file.Contexts {
+			if !contexts[context.Name] {
+				contexts[context.Name] = true
+				k.merged.Contexts = append(k.merged.Contexts, context)
+			}
+		}
+	}
+	if loaded == 0 {
+		return nil, errors.New("no kubeconfig file found")
+	}
+	return k, nil
+}
+
+// LoadConfig reads the connection of a context from kubeconfig files, the
+// current context when context is empty. Files are merged as by
+// LoadKubeconfig.
+func LoadConfig(context string, paths ...string) (*Config, error) {
+	k, err := LoadKubeconfig(paths...)
+	if err != nil {
+		return nil, err
+	}
+	return k.Config(context)
+}
+
+// Contexts returns the names of the contexts, sorted.
+func (k *Kubeconfig) Contexts() []string {
+	names := make([]string, len(k.merged.Contexts))
+	for i, context := range k.merged.Contexts {
+		names[i] = context.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Config returns the connection of a context, the current context when
+// context is empty.
+func (k *Kubeconfig) Config(context string) (*Config, error) {
+	if context == "" {
+		context = k.CurrentContext
+	}
+	if context == "" {
+		return nil, errors.New("no current context set in kubeconfig")
+	}
+	config := &Config{Context: context}
+
+	found := false
+	var clusterName, userName string
+	for _, c := range k.merged.Contexts {
+		if c.Name == context {
+			found = true
+			clusterName, userName, config.Namespace = c.Context.Cluster, c.Context.User, c.Context.Namespace
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("context '%s' not found in kubeconfig", context)
+	}
+
+	found = false
+	for _, c := range k.merged.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		found = true
+		config.Server = c.Cluster.Server
+		config.Insecure = c.Cluster.InsecureSkipTLSVerify
+		config.TLSServerName = c.Cluster.TLSServerName
+		data, err := decodeData(c.Cluster.CertificateAuthorityData)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding certificate-authority-data of cluster '%s': %w", clusterName, err)
+		}
+		config.CAData = data
+		if len(config.CAData) == 0 && c.Cluster.CertificateAuthority != "" {
+			data, err := os.ReadFile(resolvePath(k.dirs.clusters[clusterName], c.Cluster.CertificateAuthority))
+			if err != nil {
+				return nil, fmt.Errorf("error reading certificate authority of cluster '%s': %w", clusterName, err)
+			}
+			config.CAData = data
+		}
+		break
+	}
+	if !found {
+		return nil, fmt.Errorf("cluster '%s' of context '%s' not found in kubeconfig", clusterName, context)
+	}
+	if config.Server == "" {
+		return nil, fmt.Errorf("cluster '%s' has no server", clusterName)
+	}
+
+	for _, u := range k.merged.Users {
+		if u.Name != userName {
+			continue
+		}
+		dir := k.dirs.users[userName]
+		user := u.User
+		config.Token, config.Username, config.Password = user.Token, user.Username, user.Password
+		if user.TokenFile != "" {
+			config.TokenFile = resolvePath(dir, user.TokenFile)
+		}
+		if user.Exec != nil {
+			exec := *user.Exec
+			if strings.Contains(exec.Command, "/") {
+				exec.Command = resolvePath(dir, exec.Command)
+			}
+			config.Exec = &exec
+		}
+
+		for _, file := range []struct {
+			name, path, encoded string
+			data                *[]byte
+		}{
+			{"client-certificate-data", user.ClientCertificate, user.ClientCertificateData, &config.CertData},
+			{"client-key-data", user.ClientKey, user.ClientKeyData, &config.KeyData},
+		} {
+			data, err := decodeData(file.encoded)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding %s of user '%s': %w", file.name, userName, err)
+			}
+			*file.data = data
+			if len(*file.data) > 0 || file.path == "" {
+				continue
+			}
+			data, err = os.ReadFile(resolvePath(dir, file.path))
+			if err != nil {
+				return nil, fmt.Errorf("error reading credentials of user '%s': %w", userName, err)
+			}
+			*file.data = data
+		}
+		break
+	}
+	return config, nil
+}
+
+// decodeData decodes the base64 value of a *-data kubeconfig field, nil
+// when not set.
+func decodeData(encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
+
+// Clusters implements kubesql.ClusterSet, returning the contexts.
+func (k *Kubeconfig) Clusters() []string {
+	return k.Contexts()
+}
+
+// Source implements kubesql.ClusterSet, returning the client of a context.
+// Clients are reused, keeping the credentials of their exec plugins.
+func (k *Kubeconfig) Source(context string) (kubesql.DataSource, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if client, ok := k.clients[context]; ok {
+		return client, nil
+	}
+	config, err := k.Config(context)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	k.clients[context] = client
+	return client, nil
+}
+
+// resolvePath returns path relative to dir, unless it is absolute.
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
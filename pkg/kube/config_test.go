@@ -0,0 +1,201 @@
+package kube
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: dev
+clusters:
+  - name: dev
+    cluster:
+      server: https://dev.example.com:6443
+      certificate-authority: ca.crt
+  - name: prod
+    cluster:
+      server: https://prod.example.com:6443
+      insecure-skip-tls-verify: true
+contexts:
+  - name: dev
+    context:
+      cluster: dev
+      user: dev-user
+      namespace: team-a
+  - name: prod
+    context:
+      cluster: prod
+      user: prod-user
+  - name: broken
+    context:
+      cluster: missing
+      user: dev-user
+users:
+  - name: dev-user
+    user:
+      tokenFile: token
+  - name: prod-user
+    user:
+      exec:
+        apiVersion: client.authentication.k8s.io/v1
+        command: ./bin/credentials
+        args: [get-token]
+`
+
+const overrideKubeconfig = `apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+  - name: dev
+    cluster:
+      server: https://ignored.example.com
+users:
+  - name: admin
+    user:
+      username: admin
+      password: secret
+contexts:
+  - name: local
+    context:
+      cluster: dev
+      user: admin
+`
+
+// embeddedKubeconfig embeds the certificates, like the kubeconfig files of
+// kind, minikube and cloud providers.
+const embeddedKubeconfig = `apiVersion: v1
+kind: Config
+current-context: kind
+clusters:
+  - name: kind
+    cluster:
+      server: https://127.0.0.1:6443
+      certificate-authority-data: Q0EgZGF0YQ==
+  - name: bad-ca
+    cluster:
+      server: https://127.0.0.1:6443
+      certificate-authority-data: not base64
+contexts:
+  - name: kind
+    context:
+      cluster: kind
+      user: kind-user
+  - name: bad-ca
+    context:
+      cluster: bad-ca
+      user: kind-user
+  - name: bad-key
+    context:
+      cluster: kind
+      user: bad-key
+users:
+  - name: kind-user
+    user:
+      client-certificate-data: Y2VydCBkYXRh
+      client-key-data: a2V5IGRhdGE=
+  - name: bad-key
+    user:
+      client-certificate-data: Y2VydCBkYXRh
+      client-key-data: "%%%"
+`
+
+// writeKubeconfig writes a file into dir and returns its path.
+func writeKubeconfig(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := writeKubeconfig(t, dir, "config", testKubeconfig)
+	writeKubeconfig(t, dir, "ca.crt", "CA")
+	override := writeKubeconfig(t, t.TempDir(), "override", overrideKubeconfig)
+	embedded := writeKubeconfig(t, dir, "embedded", embeddedKubeconfig)
+	missing := filepath.Join(dir, "missing")
+
+	testCases := []struct {
+		context  string
+		paths    []string
+		expected *Config
+	}{
+		{
+			"", []string{config},
+			&Config{Context: "dev", Server: "https://dev.example.com:6443", Namespace: "team-a", CAData: []byte("CA"), TokenFile: filepath.Join(dir, "token")},
+		},
+		{
+			"prod", []string{config},
+			&Config{
+				Context: "prod", Server: "https://prod.example.com:6443", Insecure: true,
+				Exec: &ExecConfig{Command: filepath.Join(dir, "bin/credentials"), Args: []string{"get-token"}, APIVersion: "client.authentication.k8s.io/v1"},
+			},
+		},
+		{
+			"local", []string{missing, config, override},
+			&Config{Context: "local", Server: "https://dev.example.com:6443", CAData: []byte("CA"), Username: "admin", Password: "secret"},
+		},
+		{
+			"", []string{override, config},
+			&Config{
+				Context: "prod", Server: "https://prod.example.com:6443", Insecure: true,
+				Exec: &ExecConfig{Command: filepath.Join(dir, "bin/credentials"), Args: []string{"get-token"}, APIVersion: "client.authentication.k8s.io/v1"},
+			},
+		},
+		{
+			"", []string{embedded},
+			&Config{Context: "kind", Server: "https://127.0.0.1:6443", CAData: []byte("CA data"), CertData: []byte("cert data"), KeyData: []byte("key data")},
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := LoadConfig(tc.context, tc.paths...)
+		if err != nil {
+			t.Errorf("For context '%s', expected no error, got %v", tc.context, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("For context '%s', expected %+v, got %+v", tc.context, tc.expected, result)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	config := writeKubeconfig(t, dir, "config", testKubeconfig)
+	noContext := writeKubeconfig(t, dir, "empty", "apiVersion: v1\nkind: Config\n")
+	invalid := writeKubeconfig(t, dir, "invalid", "clusters: {")
+	embedded := writeKubeconfig(t, dir, "embedded", embeddedKubeconfig)
+
+	testCases := []struct {
+		context  string
+		paths    []string
+		expected string
+	}{
+		{"staging", []string{config}, "context 'staging' not found"},
+		{"broken", []string{config}, "cluster 'missing' of context 'broken' not found"},
+		{"", []string{noContext}, "no current context"},
+		{"", []string{invalid}, "error parsing kubeconfig"},
+		{"", []string{filepath.Join(dir, "missing")}, "error reading kubeconfig"},
+		{"", []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, "no kubeconfig file found"},
+		{"bad-ca", []string{embedded}, "error decoding certificate-authority-data of cluster 'bad-ca'"},
+		{"bad-key", []string{embedded}, "error decoding client-key-data of user 'bad-key'"},
+	}
+
+	for _, tc := range testCases {
+		_, err := LoadConfig(tc.context, tc.paths...)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("For context '%s' and paths %v, expected error containing '%s', got %v", tc.context, tc.paths, tc.expected, err)
+		}
+	}
+
+	// The CA file of the dev cluster is missing
+	if _, err := LoadConfig("dev", config); err == nil || !strings.Contains(err.Error(), "certificate authority") {
+		t.Errorf("Expected an error reading the certificate authority, got %v", err)
+	}
+}
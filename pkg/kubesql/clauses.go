@@ -2,40 +2,109 @@ package kubesql
 
 import (
 	"fmt"
-	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
-// clausePattern represents a SQL clause pattern for regex matching
-type clausePattern struct {
-	name    string // The clause name (SELECT, FROM, etc.)
-	pattern string // The regex pattern to match the clause
+// clauseKeywords lists the clause keywords in the order the clauses must appear.
+var clauseKeywords = []string{SelectKeyword, FromKeyword, WhereKeyword, OrderByKeyword, LimitKeyword}
+
+// section holds the content of a single SQL clause and its offset in the query.
+type section struct {
+	text   string  // The clause content, without the clause keyword
+	pos    int     // Offset of the clause content in the query
+	tokens []token // Tokens of the clause content
+}
+
+// clauseKeywordAt returns the clause keyword starting at tokens[i] and the
+// number of tokens it spans, or an empty name if there is none.
+func clauseKeywordAt(tokens []token, i int) (string, int) {
+	switch {
+	case tokens[i].isKeyword(SelectKeyword), tokens[i].isKeyword(FromKeyword),
+		tokens[i].isKeyword(WhereKeyword), tokens[i].isKeyword(LimitKeyword):
+		return strings.ToUpper(tokens[i].text), 1
+	case tokens[i].isKeyword("ORDER") && i+1 < len(tokens) && tokens[i+1].isKeyword("BY"):
+		return OrderByKeyword, 2
+	}
+	return "", 0
 }
 
-// splitIntoSections splits the normalized query into its constituent SQL clauses.
-func (p *Parser) splitIntoSections(query string) (map[string]string, error) {
-	sections := make(map[string]string)
+// splitIntoSections splits the query tokens into its constituent SQL clauses.
+// Only keywords outside of parentheses and quotes start a clause, and clauses
+// must appear in order, each one at most once.
+func (p *Parser) splitIntoSections(tokens []token) (map[string]section, error) {
+	sections := make(map[string]section)
+
+	current := -1     // Index in clauseKeywords of the clause being read
+	var keyword token // The keyword that started the current clause
+	start := 0        // Index of the first token of the current clause content
+	depth := 0
 
-	// Define SQL clause patterns in the order they typically appear
-	// Each pattern captures the content of the clause while looking ahead
-	// for the next clause or end of string
-	clausePatterns := []clausePattern{
-		{SelectKeyword, `(?i)^select\s+(.+?)(?:\s+from\s+|\s*$)`},
-		{FromKeyword, `(?i)\bfrom\s+([^\s]+)(?:\s+where\s+|\s+order\s+by\s+|\s+limit\s+|\s*$)`},
-		{WhereKeyword, `(?i)\bwhere\s+(.+?)(?:\s+order\s+by\s+|\s+limit\s+|\s*$)`},
-		{OrderByKeyword, `(?i)\border\s+by\s+(.+?)(?:\s+limit\s+|\s*$)`},
-		{LimitKeyword, `(?i)\blimit\s+(\d+)\s*$`},
+	closeSection := func(end int) error {
+		if current < 0 {
+			return nil
+		}
+		name := clauseKeywords[current]
+		content := tokens[start:end]
+		if len(content) == 0 {
+			return p.errorf(keyword.end(), "%s clause cannot be empty", name)
+		}
+		sections[name] = section{text: joinTokens(upperBooleans(content)), pos: content[0].pos, tokens: content}
+		return nil
 	}
 
-	// Apply each pattern to extract clause content
-	for _, clause := range clausePatterns {
-		re := regexp.MustCompile(clause.pattern)
-		matches := re.FindStringSubmatch(query)
-		if len(matches) > 1 {
-			sections[clause.name] = strings.TrimSpace(matches[1])
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i].kind {
+		case tokenLParen, tokenLBracket:
+			depth++
+		case tokenRParen, tokenRBracket:
+			depth--
+		}
+		if depth != 0 {
+			continue
+		}
+
+		name, width := clauseKeywordAt(tokens, i)
+		if name == "" {
+			if current < 0 {
+				parseErr := p.errorf(tokens[i].pos, "query must start with SELECT or FROM")
+				if tokens[i].kind == tokenIdent {
+					candidates := []string{SelectKeyword, FromKeyword}
+					if i == 0 {
+						candidates = append(candidates, ExplainKeyword)
+					}
+					err := suggestf(strings.ToUpper(tokens[i].text), candidates, "%s", parseErr.Message)
+					parseErr.Message, parseErr.Suggestion = err.Error(), suggestionOf(err)
+				}
+				return nil, parseErr
+			}
+			continue
+		}
+
+		index := 0
+		for index < len(clauseKeywords) && clauseKeywords[index] != name {
+			index++
+		}
+		switch {
+		case current < 0 && index > 1:
+			return nil, p.errorf(tokens[i].pos, "query must start with SELECT or FROM")
+		case index == current:
+			return nil, p.errorf(tokens[i].pos, "duplicate %s clause", name)
+		case index < current:
+			return nil, p.errorf(tokens[i].pos, "unexpected %s clause after %s clause", name, clauseKeywords[current])
 		}
+
+		if err := closeSection(i); err != nil {
+			return nil, err
+		}
+		current, keyword, start = index, tokens[i+width-1], i+width
+		i += width - 1
 	}
 
+	if err := closeSection(len(tokens)); err != nil {
+		return nil, err
+	}
 	return sections, nil
 }
 
@@ -45,31 +114,39 @@ func (p *Parser) splitIntoSections(query string) (map[string]string, error) {
 //   - "name, namespace" -> [{Expression: "name"}, {Expression: "namespace"}]
 //   - "name AS pod_name" -> [{Expression: "name", Alias: "pod_name"}]
 func (p *Parser) parseSelectClause(selectClause string) ([]SelectField, error) {
-	if selectClause == "" {
-		return nil, nil
+	if strings.TrimSpace(selectClause) == "" {
+		return nil, fmt.Errorf("SELECT clause cannot be empty")
+	}
+
+	tokens, err := tokenize(selectClause)
+	if err != nil {
+		return nil, err
 	}
 
 	var fields []SelectField
 
-	// Split by comma, respecting parentheses for complex expressions
-	parts := p.smartSplit(selectClause, ',')
+	// Split by comma, respecting parentheses and quotes for complex expressions
+	for _, part := range splitTokens(tokens, tokenComma) {
+		if len(part) == 0 {
+			return nil, fmt.Errorf("empty field in SELECT list '%s'", selectClause)
+		}
 
-	for _, part := range parts {
+		// Check for alias using AS keyword (case insensitive), the alias
+		// may be a quoted identifier
 		field := SelectField{}
-		part = strings.TrimSpace(part)
-
-		// Check for alias using AS keyword (case insensitive)
-		asRegex := regexp.MustCompile(`(?i)^(.+?)\s+as\s+([^\s]+)$`)
-		matches := asRegex.FindStringSubmatch(part)
-
-		if len(matches) == 3 {
+		n := len(part)
+		if n >= 3 && part[n-2].isKeyword("AS") && (part[n-1].kind == tokenIdent || part[n-1].kind == tokenQuotedIdent) {
 			// Found an alias
-			field.Field = TSLQuery(strings.TrimSpace(matches[1]))
-			field.Alias = strings.TrimSpace(matches[2])
-		} else {
-			// No alias, just the expression
-			field.Field = TSLQuery(part)
+			field.Alias = part[n-1].value
+			part = part[:n-2]
+		}
+
+		for _, tok := range part {
+			if tok.isKeyword("AS") {
+				return nil, fmt.Errorf("invalid alias in SELECT field '%s': expected 'expression AS alias'", joinTokens(part))
+			}
 		}
+		field.Field = TSLQuery(joinTokens(part))
 
 		fields = append(fields, field)
 	}
@@ -77,74 +154,240 @@ func (p *Parser) parseSelectClause(selectClause string) ([]SelectField, error) {
 	return fields, nil
 }
 
-// parseFromClause parses the FROM clause to extract the Kubernetes resource name.
-func (p *Parser) parseFromClause(fromClause string) (string, error) {
-	resource := strings.TrimSpace(fromClause)
-	if resource == "" {
-		return "", fmt.Errorf("FROM clause cannot be empty")
+// parseFromClause parses the FROM clause: the Kubernetes resource with an
+// optional alias, then the joined resources.
+// Examples:
+//   - "mynamespace/services" -> "mynamespace/services", no alias or joins
+//   - "pods p JOIN nodes n ON p.spec.nodeName = n.name" -> "pods", alias "p",
+//     [{Type: "INNER", From: "nodes", Alias: "n", On: "p.spec.nodeName = n.name"}]
+func (p *Parser) parseFromClause(fromClause string) (string, string, []Join, error) {
+	tokens, err := tokenize(fromClause)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(tokens) == 0 {
+		return "", "", nil, fmt.Errorf("FROM clause cannot be empty")
+	}
+
+	// Joins start with their type and the JOIN keyword, outside of parentheses
+	var starts []int
+	depth := 0
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokenLParen, tokenLBracket:
+			depth++
+		case tokenRParen, tokenRBracket:
+			depth--
+		}
+		if depth != 0 || !tok.isKeyword(JoinKeyword) || isFunctionCall(tokens, i) {
+			continue
+		}
+		start := i
+		if start > 0 && tokens[start-1].isKeyword("OUTER") {
+			start--
+		}
+		if start > 0 && (tokens[start-1].isKeyword(InnerJoin) || tokens[start-1].isKeyword(LeftJoin)) {
+			start--
+		}
+		starts = append(starts, start)
+	}
+
+	end := len(tokens)
+	if len(starts) > 0 {
+		end = starts[0]
+	}
+	if end == 0 {
+		return "", "", nil, fmt.Errorf("missing resource before %s", joinTokens(tokens[:1]))
+	}
+	from, alias, err := parseTable(tokens[:end])
+	if err != nil {
+		return "", "", nil, err
 	}
-	return resource, nil
+
+	names := []string{tableName(ResourceRef{Resource: from}, alias)}
+	if ref, err := ParseResourceRef(from); err == nil {
+		names[0] = tableName(ref, alias)
+	}
+	var joins []Join
+	for k, start := range starts {
+		end := len(tokens)
+		if k+1 < len(starts) {
+			end = starts[k+1]
+		}
+		join, err := parseJoin(tokens[start:end])
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		name := join.Alias
+		if ref, err := ParseResourceRef(join.From); err == nil {
+			name = tableName(ref, join.Alias)
+		}
+		if slices.Contains(names, name) {
+			return "", "", nil, fmt.Errorf("table '%s' is used twice, give the joined resources different aliases", name)
+		}
+		names = append(names, name)
+		joins = append(joins, join)
+	}
+	return from, alias, joins, nil
+}
+
+// parseTable parses a resource of the FROM clause, a single word, and its
+// optional alias, e.g. "pods p" or "kube-system/pods AS p".
+func parseTable(tokens []token) (string, string, error) {
+	words := splitWords(tokens)
+	resource := joinTokens(words[0])
+	rest := words[1:]
+	if len(rest) > 0 && len(rest[0]) == 1 && rest[0][0].isKeyword("AS") {
+		if len(rest) != 2 || !isAlias(rest[1]) {
+			return "", "", fmt.Errorf("invalid alias of resource '%s': expected 'resource AS alias'", resource)
+		}
+		return resource, rest[1][0].value, nil
+	}
+	switch {
+	case len(rest) == 0:
+		return resource, "", nil
+	case len(rest) == 1 && isAlias(rest[0]):
+		return resource, rest[0][0].value, nil
+	}
+	return "", "", suggestClausef(joinTokens(rest[0]), "unexpected '%s' after resource '%s'", joinTokens(tokens[len(words[0]):]), resource)
+}
+
+// isAlias reports whether a word is a table alias, an identifier that is not
+// a keyword or a quoted identifier.
+func isAlias(word []token) bool {
+	return len(word) == 1 && (word[0].kind == tokenQuotedIdent || word[0].kind == tokenIdent && !isReservedKeyword(word[0].text))
+}
+
+// parseJoin parses a join of the FROM clause, e.g.
+// "LEFT JOIN nodes n ON p.spec.nodeName = n.name".
+func parseJoin(tokens []token) (Join, error) {
+	join := Join{Type: InnerJoin}
+	switch {
+	case tokens[0].isKeyword(LeftJoin):
+		join.Type = LeftJoin
+		tokens = tokens[1:]
+		if tokens[0].isKeyword("OUTER") {
+			tokens = tokens[1:]
+		}
+	case tokens[0].isKeyword(InnerJoin):
+		tokens = tokens[1:]
+	case tokens[0].isKeyword("OUTER"):
+		return Join{}, fmt.Errorf("unexpected OUTER, expected LEFT OUTER JOIN")
+	}
+	tokens = tokens[1:]
+
+	// The condition follows the first ON outside of parentheses
+	on, depth := -1, 0
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokenLParen, tokenLBracket:
+			depth++
+		case tokenRParen, tokenRBracket:
+			depth--
+		}
+		if depth == 0 && tok.isKeyword(OnKeyword) {
+			on = i
+			break
+		}
+	}
+	switch {
+	case on == 0 || len(tokens) == 0:
+		return Join{}, fmt.Errorf("missing resource after %s", JoinKeyword)
+	case on < 0:
+		return Join{}, fmt.Errorf("missing ON condition of %s %s", JoinKeyword, joinTokens(tokens))
+	case on == len(tokens)-1:
+		return Join{}, fmt.Errorf("ON condition of %s %s cannot be empty", JoinKeyword, joinTokens(tokens[:on]))
+	}
+
+	var err error
+	if join.From, join.Alias, err = parseTable(tokens[:on]); err != nil {
+		return Join{}, err
+	}
+	join.On = TSLQuery(joinTokens(tokens[on+1:]))
+	return join, nil
 }
 
 // parseWhereClause returns the WHERE clause content.
-func (p *Parser) parseWhereClause(whereClause string) string {
-	return strings.TrimSpace(whereClause)
+func (p *Parser) parseWhereClause(whereClause string) (string, error) {
+	condition := strings.TrimSpace(whereClause)
+	if condition == "" {
+		return "", fmt.Errorf("WHERE clause cannot be empty")
+	}
+	return condition, nil
 }
 
-// parseOrderByClause parses the ORDER BY clause into fields and sort directions.
-// It handles comma-separated field lists with optional ASC/DESC directions.
+// parseOrderByClause parses the ORDER BY clause into keys and sort directions.
+// Each comma-separated key is an expression with an optional ASC/DESC direction.
 // Examples:
 //   - "name" -> [{Field: "name", Direction: "ASC"}]
 //   - "name DESC, namespace ASC" -> [{Field: "name", Direction: "DESC"}, {Field: "namespace", Direction: "ASC"}]
+//   - "spec.replicas * 2 DESC" -> [{Field: "spec.replicas * 2", Direction: "DESC"}]
 func (p *Parser) parseOrderByClause(orderByClause string) ([]OrderByField, error) {
-	var fields []OrderByField
+	if strings.TrimSpace(orderByClause) == "" {
+		return nil, fmt.Errorf("ORDER BY clause cannot be empty")
+	}
 
-	// Split by comma, respecting parentheses
-	parts := p.smartSplit(orderByClause, ',')
+	tokens, err := tokenize(orderByClause)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
-		}
+	var fields []OrderByField
 
-		// Split by whitespace to separate field from direction
-		tokens := strings.Fields(part)
-		if len(tokens) == 0 {
+	// Split by comma, respecting parentheses and quotes
+	for _, part := range splitTokens(tokens, tokenComma) {
+		if len(part) == 0 {
 			continue
 		}
 
-		field := OrderByField{
-			Field:     TSLQuery(tokens[0]),
-			Direction: DefaultSortDirection, // Default to ASC
+		// The direction is the last word, when it is ASC or DESC
+		field := OrderByField{Direction: DefaultSortDirection}
+		key := part
+		if n := len(key); n > 1 && (key[n-1].isKeyword("ASC") || key[n-1].isKeyword("DESC")) {
+			field.Direction = strings.ToUpper(key[n-1].text)
+			key = key[:n-1]
 		}
 
-		// Check for explicit direction
-		if len(tokens) > 1 {
-			// Validate that there are exactly 2 tokens (field and direction)
-			if len(tokens) > 2 {
-				return nil, fmt.Errorf("invalid ORDER BY field '%s': expected 'field [ASC|DESC]' but found multiple directions", part)
-			}
-
-			direction := strings.ToUpper(tokens[1])
-			if direction == "DESC" || direction == "ASC" {
-				field.Direction = direction
-			} else {
-				return nil, fmt.Errorf("invalid sort direction '%s': must be 'ASC' or 'DESC'", tokens[1])
+		// Words after a complete expression are misspelled directions or
+		// clause keywords. Keys that do not parse at all are reported by the
+		// validator.
+		if _, err := ParseExpr(TSLQuery(joinTokens(key))); err != nil {
+			if k := orderByKeyEnd(key); k > 0 {
+				rest := part[k:]
+				if len(rest) > 1 && (rest[0].isKeyword("ASC") || rest[0].isKeyword("DESC")) {
+					return nil, suggestClausef(rest[1].text, "invalid ORDER BY field '%s': expected 'expression [ASC|DESC]' but found multiple directions", joinTokens(part))
+				}
+				direction := rest[0].text
+				return nil, suggestf(strings.ToUpper(direction), []string{"ASC", "DESC", LimitKeyword}, "invalid sort direction '%s': must be 'ASC' or 'DESC'", direction)
 			}
 		}
 
+		field.Field = TSLQuery(joinTokens(key))
 		fields = append(fields, field)
 	}
 
 	return fields, nil
 }
 
+// orderByKeyEnd returns the number of tokens of the longest expression
+// starting an ORDER BY key that is followed by a separate word, 0 if none.
+func orderByKeyEnd(key []token) int {
+	for k := len(key) - 1; k > 0; k-- {
+		if key[k].kind != tokenIdent || key[k].pos == key[k-1].end() {
+			continue
+		}
+		if _, err := ParseExpr(TSLQuery(joinTokens(key[:k]))); err == nil {
+			return k
+		}
+	}
+	return 0
+}
+
 // parseLimitClause parses the LIMIT clause to extract the maximum number of results.
 func (p *Parser) parseLimitClause(limitClause string) (int, error) {
 	limitStr := strings.TrimSpace(limitClause)
-	var limit int
-	_, err := fmt.Sscanf(limitStr, "%d", &limit)
+	limit, err := strconv.Atoi(limitStr)
 	if err != nil {
 		return DefaultLimit, fmt.Errorf("invalid LIMIT value: %s", limitStr)
 	}
@@ -37,6 +37,19 @@ func TestParseSelectClause(t *testing.T) {
 				{Field: "COUNT(*)", Alias: "total"},
 			},
 		},
+		{
+			"`select` AS `order`, \"my field\" AS ok",
+			[]SelectField{
+				{Field: "`select`", Alias: "order"},
+				{Field: `"my field"`, Alias: "ok"},
+			},
+		},
+		{
+			"join(names, ', ') AS names",
+			[]SelectField{
+				{Field: "join(names, ', ')", Alias: "names"},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -87,6 +100,13 @@ func TestParseOrderByClause(t *testing.T) {
 				{Field: "created_at", Direction: "ASC"},
 			},
 		},
+		{
+			"`my field` DESC, metadata.labels.\"app.kubernetes.io/name\"",
+			[]OrderByField{
+				{Field: "`my field`", Direction: "DESC"},
+				{Field: `metadata.labels."app.kubernetes.io/name"`, Direction: "ASC"},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -0,0 +1,547 @@
+package kubesql
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SuggestionKind identifies what a completion suggestion stands for.
+type SuggestionKind int
+
+// Suggestion kinds.
+const (
+	KeywordSuggestion  SuggestionKind = iota // A keyword, e.g. WHERE or ORDER BY
+	ResourceSuggestion                       // A resource name, e.g. pods
+	FieldSuggestion                          // A field name, e.g. metadata or phase
+	FunctionSuggestion                       // A function name, e.g. len
+)
+
+var suggestionKindNames = []string{"keyword", "resource", "field", "function"}
+
+// String returns the lower case name of the suggestion kind.
+func (k SuggestionKind) String() string {
+	if k < 0 || int(k) >= len(suggestionKindNames) {
+		return "unknown"
+	}
+	return suggestionKindNames[k]
+}
+
+// Suggestion is a possible completion of the word at the cursor.
+type Suggestion struct {
+	Text   string         // Text replacing the query from Start to the cursor
+	Kind   SuggestionKind // What the suggestion stands for
+	Detail string         // Short description, e.g. the type of a field or the kind of a resource
+	Start  int            // Byte offset where the completed word starts
+}
+
+// Completer proposes completions of partially written queries.
+type Completer struct {
+	Resolver Resolver       // Provides the resource names, required
+	Schemas  SchemaProvider // Provides the field names, optional
+}
+
+// NewCompleter creates a completer. schemas may be nil, in which case only
+// the short field names and top level object fields are proposed.
+func NewCompleter(resolver Resolver, schemas SchemaProvider) *Completer {
+	return &Completer{Resolver: resolver, Schemas: schemas}
+}
+
+// Complete proposes completions for the word before the cursor, a byte
+// offset in query, using the built in resources and no schemas.
+func Complete(query string, cursor int) []Suggestion {
+	return NewCompleter(DefaultResolver(), nil).Complete(query, cursor)
+}
+
+// topLevelFields are the fields proposed for objects without a schema.
+var topLevelFields = []string{"apiVersion", "kind", "metadata", "spec", "status"}
+
+// Complete proposes completions for the word before the cursor, a byte
+// offset in query. The clause the cursor is in decides what is proposed:
+// clause keywords, resource names after FROM, or fields, functions and
+// operators in expressions. Within a field path such as spec.containers[0].
+// the fields of the schema at that path are proposed.
+//
+// Suggestions start with the word before the cursor, ignoring case, and
+// keywords follow the case of that word. Nothing is proposed inside string
+// literals and comments.
+func (c *Completer) Complete(query string, cursor int) []Suggestion {
+	cursor = max(0, min(cursor, len(query)))
+	tokens, comments, err := tokenizeWithComments(query[:cursor])
+	if err != nil {
+		// Unterminated string, quoted identifier or comment
+		return nil
+	}
+	if n := len(comments); n > 0 && comments[n-1].end() == cursor && strings.HasPrefix(comments[n-1].text, "--") {
+		return nil
+	}
+
+	// The statement the cursor is in
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].kind == tokenSemicolon {
+			tokens = tokens[i+1:]
+			break
+		}
+	}
+
+	// The word being completed, empty after a space or an operator
+	ctx := completion{completer: c, start: cursor}
+	if n := len(tokens); n > 0 && tokens[n-1].end() == cursor {
+		switch tokens[n-1].kind {
+		case tokenIdent:
+			ctx.word = tokens[n-1].text
+			ctx.start = tokens[n-1].pos
+			tokens = tokens[:n-1]
+		case tokenString, tokenQuotedIdent, tokenNumber:
+			return nil
+		}
+	}
+	var ok bool
+	if tokens, ctx.with, ok = withScope(tokens, cursor); !ok {
+		// A common table expression being named
+		if n := len(tokens); n > 0 && (tokens[n-1].kind == tokenIdent || tokens[n-1].kind == tokenQuotedIdent) && !isReservedKeyword(tokens[n-1].text) {
+			ctx.addKeywords("AS")
+		}
+		return ctx.result()
+	}
+	ctx.tokens, ctx.setOp = compoundScope(tokens, cursor)
+	ctx.tables, ctx.resources = c.statementTables(query, cursor)
+
+	return ctx.propose()
+}
+
+// statementTables resolves the tables of the statement the cursor is in,
+// which may come after the cursor: the FROM resource first, then the
+// joined ones, nil when unknown. names are the names qualifying fields,
+// see rowPath.
+func (c *Completer) statementTables(query string, cursor int) (names []string, resources []*ResourceInfo) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		// Only the text before the cursor is known to be well formed
+		if tokens, err = tokenize(query[:cursor]); err != nil {
+			return nil, nil
+		}
+	}
+
+	for _, stmt := range splitStatements(tokens, len(query)) {
+		if stmt.end < cursor || len(stmt.tokens) == 0 || stmt.tokens[0].pos > cursor {
+			continue
+		}
+		tokens, with, _ := withScope(stmt.tokens, cursor)
+		tokens, _ = compoundScope(tokens, cursor)
+		var tables []table
+		for i, tok := range tokens {
+			if !tok.isKeyword(FromKeyword) && (!tok.isKeyword(JoinKeyword) || isFunctionCall(tokens, i)) || i+1 == len(tokens) {
+				continue
+			}
+			words := splitWords(tokens[i+1:])
+			ref, err := ParseResourceRef(joinTokens(words[0]))
+			if err != nil {
+				break
+			}
+			var resource *ResourceInfo
+			if info, err := c.Resolver.Resolve(ref.Resource); err == nil && cteName(ref, with) == "" {
+				resource = &info
+			}
+			alias := ""
+			if len(words) > 2 && len(words[1]) == 1 && words[1][0].isKeyword("AS") && isAlias(words[2]) {
+				alias = words[2][0].value
+			} else if len(words) > 1 && isAlias(words[1]) {
+				alias = words[1][0].value
+			}
+			tables = append(tables, table{name: tableName(ref, alias), ref: ref})
+			resources = append(resources, resource)
+		}
+		return tableNames(tables), resources
+	}
+	return nil, nil
+}
+
+// withScope returns the tokens of the query an offset is in, in a statement
+// with a WITH clause: the tokens of the common table expression around the
+// offset, or the ones of the query after the WITH clause, with the names of
+// the common table expressions that query sees. ok is false when the offset
+// is in the WITH clause, but not in one of its queries.
+func withScope(tokens []token, offset int) (scope []token, with []string, ok bool) {
+	i := 0
+	if len(tokens) > 0 && tokens[0].isKeyword(ExplainKeyword) {
+		i++
+	}
+	if i == len(tokens) || !tokens[i].isKeyword(WithKeyword) {
+		return tokens, nil, true
+	}
+	for i++; i+2 < len(tokens) && tokens[i+1].isKeyword("AS") && tokens[i+2].kind == tokenLParen; i++ {
+		// The query ends at the closing parenthesis, or is being written
+		end, depth := i+3, 1
+		for ; end < len(tokens); end++ {
+			if kind := tokens[end].kind; kind == tokenLParen {
+				depth++
+			} else if kind == tokenRParen {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		if offset >= tokens[i+2].end() && (end == len(tokens) || offset <= tokens[end].pos) {
+			return tokens[i+3 : end], with, true
+		}
+		with = append(with, tokens[i].value)
+		i = end + 1
+		if i == len(tokens) || tokens[i].kind != tokenComma {
+			return tokens[min(i, len(tokens)):], with, true
+		}
+	}
+	return tokens[min(i, len(tokens)):], with, false
+}
+
+// compoundScope returns the tokens of the query an offset is in, in a
+// statement combining queries with set operations, and the keywords of the
+// set operation before that query, e.g. "UNION ALL", empty for the first
+// query.
+func compoundScope(tokens []token, offset int) (scope []token, op string) {
+	start, end, depth := 0, len(tokens), 0
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokenLParen, tokenLBracket:
+			depth++
+		case tokenRParen, tokenRBracket:
+			depth--
+		}
+		if depth != 0 || !isSetOperation(tok) {
+			continue
+		}
+		if tok.pos >= offset {
+			end = i
+			break
+		}
+		start, op = i+1, strings.ToUpper(tok.text)
+		if start < len(tokens) && tokens[start].isKeyword(AllKeyword) {
+			start, op = start+1, op+" "+AllKeyword
+		}
+	}
+	return tokens[min(start, end):end], op
+}
+
+// completion holds the context of a single completion request.
+type completion struct {
+	completer   *Completer
+	tokens      []token         // Tokens of the statement before the completed word
+	word        string          // The partial word being completed
+	start       int             // Offset of the completed word
+	tables      []string        // Names qualifying fields, see rowPath
+	resources   []*ResourceInfo // The statement resources, the FROM one first, nil if unknown
+	with        []string        // Names of the common table expressions the query sees
+	setOp       string          // Keywords of the set operation before the query, empty for the first one
+	suggestions []Suggestion    // Suggestions found so far
+}
+
+// propose proposes completions for the context.
+func (c *completion) propose() []Suggestion {
+	clause, clauseStart := "", 0
+	depth := 0
+	for i, tok := range c.tokens {
+		switch tok.kind {
+		case tokenLParen, tokenLBracket:
+			depth++
+		case tokenRParen, tokenRBracket:
+			depth--
+		case tokenIdent:
+			if depth > 0 || isFunctionCall(c.tokens, i) {
+				continue
+			}
+			for _, keyword := range []string{SelectKeyword, FromKeyword, JoinKeyword, OnKeyword, WhereKeyword, "ORDER", LimitKeyword} {
+				if tok.isKeyword(keyword) {
+					clause, clauseStart = keyword, i+1
+				}
+			}
+		}
+	}
+	clauseTokens := c.tokens[clauseStart:]
+
+	// A field path being written, e.g. spec.containers[0].na
+	if path, ok := c.fieldPath(); ok {
+		if clause == SelectKeyword || clause == OnKeyword || clause == WhereKeyword || clause == "ORDER" {
+			c.addFields(path)
+		}
+		return c.result()
+	}
+
+	switch clause {
+	case "":
+		c.addKeywords(SelectKeyword, FromKeyword)
+		switch {
+		case c.setOp != "":
+			if len(c.tokens) == 0 && !strings.HasSuffix(c.setOp, AllKeyword) {
+				c.addKeywords(AllKeyword)
+			}
+		case len(c.tokens) == 0 && c.with == nil:
+			c.addKeywords(ExplainKeyword, WithKeyword)
+		case len(c.tokens) == 1 && c.tokens[0].isKeyword(ExplainKeyword):
+			c.addKeywords(WithKeyword)
+		}
+	case SelectKeyword:
+		if n := len(clauseTokens); n > 0 && clauseTokens[n-1].isKeyword("AS") {
+			// Aliases are new names
+			break
+		}
+		if expectsOperand(clauseTokens) {
+			c.addFields(nil)
+			c.addFunctions()
+			break
+		}
+		c.addKeywords("AS", FromKeyword)
+	case FromKeyword, JoinKeyword:
+		words := splitWords(clauseTokens)
+		last := len(clauseTokens) - 1
+		// Resources follow the namespace and the clusters
+		if len(words) == 0 || len(words) == 1 && c.start == clauseTokens[last].end() && (clauseTokens[last].text == "/" || clauseTokens[last].kind == tokenColon) {
+			c.addResources()
+			break
+		}
+		if clauseTokens[last].isKeyword("AS") || c.addJoinKeywords(clauseTokens) {
+			// Aliases are new names
+			break
+		}
+		if clause == JoinKeyword {
+			c.addKeywords(OnKeyword)
+			break
+		}
+		c.addKeywords(JoinKeyword, LeftJoin, InnerJoin, WhereKeyword, OrderByKeyword, LimitKeyword, UnionKeyword, IntersectKeyword, ExceptKeyword)
+	case WhereKeyword, OnKeyword:
+		if n := len(clauseTokens); n > 0 && clauseTokens[n-1].isKeyword("IS") {
+			c.addKeywords("NOT", "NULL")
+			break
+		}
+		if expectsOperand(clauseTokens) {
+			c.addFields(nil)
+			c.addFunctions()
+			c.addKeywords("NOT", "NULL", "TRUE", "FALSE")
+			if clause == WhereKeyword {
+				c.addKeywords("EXISTS")
+			}
+			break
+		}
+		if clause == OnKeyword && c.addJoinKeywords(clauseTokens) {
+			break
+		}
+		c.addKeywords("AND", "OR", "NOT", "IN", "LIKE", "ILIKE", "BETWEEN", "IS")
+		if clause == OnKeyword {
+			c.addKeywords(JoinKeyword, LeftJoin, InnerJoin, WhereKeyword)
+		}
+		c.addKeywords(OrderByKeyword, LimitKeyword, UnionKeyword, IntersectKeyword, ExceptKeyword)
+	case "ORDER":
+		if len(clauseTokens) == 0 {
+			c.addKeywords("BY")
+			break
+		}
+		if expectsOperand(clauseTokens[1:]) {
+			c.addAliases()
+			c.addFields(nil)
+			c.addFunctions()
+			break
+		}
+		c.addKeywords("ASC", "DESC", LimitKeyword)
+	}
+
+	return c.result()
+}
+
+// expectsOperand reports whether the next token of an expression is an
+// operand, as at its start or after an operator, rather than an operator.
+func expectsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	switch last.kind {
+	case tokenOperator, tokenComma, tokenLParen, tokenLBracket:
+		return true
+	case tokenIdent:
+		for _, keyword := range []string{"AND", "OR", "NOT", "IN", "LIKE", "ILIKE", "BETWEEN", "IS", "BY"} {
+			if last.isKeyword(keyword) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fieldPath returns the path of a field being written before the completed
+// word, e.g. spec.containers[0] for "spec.containers[0].na". It reports
+// false when the word does not follow a dot.
+func (c *completion) fieldPath() ([]Segment, bool) {
+	tokens := c.tokens
+	end := c.start
+	var path []Segment
+
+	for {
+		n := len(tokens)
+		if n == 0 || tokens[n-1].kind != tokenDot || tokens[n-1].end() != end {
+			break
+		}
+		tokens = tokens[:n-1]
+
+		// Index segments, e.g. [0] or [*], before the dot
+		var indexes []Segment
+		for len(tokens) >= 3 && tokens[len(tokens)-1].kind == tokenRBracket && tokens[len(tokens)-3].kind == tokenLBracket {
+			index := -1
+			if tokens[len(tokens)-2].kind == tokenNumber {
+				index, _ = strconv.Atoi(tokens[len(tokens)-2].text)
+			}
+			indexes = append([]Segment{{Index: index, Array: true}}, indexes...)
+			tokens = tokens[:len(tokens)-3]
+		}
+
+		n = len(tokens)
+		if n == 0 || (tokens[n-1].kind != tokenIdent && tokens[n-1].kind != tokenQuotedIdent) {
+			return nil, false
+		}
+		path = append(append([]Segment{{Name: tokens[n-1].value}}, indexes...), path...)
+		end = tokens[n-1].pos
+		tokens = tokens[:n-1]
+	}
+
+	return path, len(path) > 0
+}
+
+// addKeywords adds keyword suggestions.
+func (c *completion) addKeywords(keywords ...string) {
+	for _, keyword := range keywords {
+		if c.word != "" && c.word == strings.ToLower(c.word) {
+			keyword = strings.ToLower(keyword)
+		}
+		c.add(Suggestion{Text: keyword, Kind: KeywordSuggestion, Detail: "keyword"})
+	}
+}
+
+// addJoinKeywords adds the keywords completing LEFT, LEFT OUTER or INNER
+// into a join, and reports whether tokens end with one of them.
+func (c *completion) addJoinKeywords(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	switch last := tokens[len(tokens)-1]; {
+	case last.isKeyword(LeftJoin):
+		c.addKeywords("OUTER", JoinKeyword)
+	case last.isKeyword(InnerJoin), last.isKeyword("OUTER"):
+		c.addKeywords(JoinKeyword)
+	default:
+		return false
+	}
+	return true
+}
+
+// addAliases adds the SELECT aliases of the statement, ORDER BY keys may
+// refer to them.
+func (c *completion) addAliases() {
+	for i := 1; i < len(c.tokens); i++ {
+		if c.tokens[i-1].isKeyword("AS") && (c.tokens[i].kind == tokenIdent || c.tokens[i].kind == tokenQuotedIdent) {
+			c.add(Suggestion{Text: quoteIdentifier(c.tokens[i].value), Kind: FieldSuggestion, Detail: "alias"})
+		}
+	}
+}
+
+// addResources adds the names of the common table expressions the query
+// sees and of every known resource.
+func (c *completion) addResources() {
+	for _, name := range c.with {
+		c.add(Suggestion{Text: quoteIdentifier(name), Kind: ResourceSuggestion, Detail: "common table expression"})
+	}
+	resources := c.completer.Resolver.Resources()
+	for _, resource := range resources {
+		detail := resource.Kind
+		if resource.Group != "" {
+			detail += " (" + resource.Group + ")"
+		}
+		for _, name := range resource.Names() {
+			c.add(Suggestion{Text: name, Kind: ResourceSuggestion, Detail: detail})
+		}
+	}
+}
+
+// addFields adds the fields of the object at path of the FROM resource, or
+// of the table qualifying path. Without a path, the table names are added
+// too.
+func (c *completion) addFields(path []Segment) {
+	var resource *ResourceInfo
+	if len(c.resources) > 0 {
+		resource = c.resources[0]
+	}
+	if len(path) == 0 {
+		for _, name := range c.tables {
+			c.add(Suggestion{Text: quoteIdentifier(name), Kind: FieldSuggestion, Detail: "table"})
+		}
+	} else if i := slices.Index(c.tables, path[0].Name); i >= 0 && !path[0].Array {
+		resource, path = c.resources[i], path[1:]
+	}
+	var schema *Schema
+	if resource != nil && c.completer.Schemas != nil {
+		schema, _ = c.completer.Schemas.Schema(*resource)
+	}
+
+	if len(path) == 0 {
+		names := topLevelFields
+		if schema != nil {
+			names = schema.FieldNames()
+		}
+		for _, name := range append(fieldAliasNames(), ClusterField) {
+			field, _ := lookupField(schema, []Segment{{Name: name}})
+			c.add(Suggestion{Text: name, Kind: FieldSuggestion, Detail: fieldDetail(field, schema != nil)})
+		}
+		for _, name := range names {
+			field, _ := schema.Field(name)
+			c.add(Suggestion{Text: name, Kind: FieldSuggestion, Detail: fieldDetail(field, schema != nil)})
+		}
+		return
+	}
+
+	if schema == nil {
+		return
+	}
+	parent, err := lookupField(schema, path)
+	if err != nil || parent == nil {
+		return
+	}
+	for _, name := range parent.FieldNames() {
+		field, _ := parent.Field(name)
+		c.add(Suggestion{Text: name, Kind: FieldSuggestion, Detail: fieldDetail(field, true)})
+	}
+}
+
+// fieldDetail describes the type of a field suggestion.
+func fieldDetail(field *Schema, known bool) string {
+	if !known {
+		return "field"
+	}
+	return schemaType(field).String()
+}
+
+// addFunctions adds the names of the known functions.
+func (c *completion) addFunctions() {
+	for _, name := range functionNames() {
+		c.add(Suggestion{Text: name, Kind: FunctionSuggestion, Detail: "function"})
+	}
+}
+
+// add adds a suggestion matching the completed word.
+func (c *completion) add(suggestion Suggestion) {
+	if !strings.HasPrefix(strings.ToLower(suggestion.Text), strings.ToLower(c.word)) {
+		return
+	}
+	suggestion.Start = c.start
+	c.suggestions = append(c.suggestions, suggestion)
+}
+
+// result returns the suggestions without duplicates, in the order they
+// were added.
+func (c *completion) result() []Suggestion {
+	seen := make(map[string]bool)
+	var result []Suggestion
+	for _, suggestion := range c.suggestions {
+		if !seen[suggestion.Text] {
+			seen[suggestion.Text] = true
+			result = append(result, suggestion)
+		}
+	}
+	return result
+}
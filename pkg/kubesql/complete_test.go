@@ -0,0 +1,138 @@
+package kubesql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// completeAt completes input at the position of its '|' marker and returns
+// the suggested texts.
+func completeAt(c *Completer, input string) []string {
+	cursor := strings.Index(input, "|")
+	query := input[:cursor] + input[cursor+1:]
+
+	var texts []string
+	for _, suggestion := range c.Complete(query, cursor) {
+		texts = append(texts, suggestion.Text)
+	}
+	return texts
+}
+
+func TestComplete(t *testing.T) {
+	validator := newTestValidator(t)
+	completer := NewCompleter(validator.Resolver, validator.Schemas)
+
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"|", []string{"SELECT", "FROM", "EXPLAIN", "WITH"}},
+		{"sel|", []string{"select"}},
+		{"EXPLAIN |", []string{"SELECT", "FROM", "WITH"}},
+		{"WITH |", nil},
+		{"WITH wide |", []string{"AS"}},
+		{"WITH wide AS (SELECT name FROM wid|", []string{"widgets", "widget", "widgets.example.com"}},
+		{"WITH wide AS (FROM widgets) |", []string{"SELECT", "FROM"}},
+		{"WITH wide AS (FROM widgets) SELECT name FROM wi|", []string{"wide", "widgets", "widget", "widgets.example.com"}},
+		{"WITH wide AS (FROM widgets), narrow AS (FROM wi|", []string{"wide", "widgets", "widget", "widgets.example.com"}},
+		{"WITH wide AS (FROM widgets) SELECT nam| FROM wide", []string{"name", "namespace"}},
+		{"SELECT name FROM pods UNION |", []string{"SELECT", "FROM", "ALL"}},
+		{"SELECT name FROM pods intersect all |", []string{"SELECT", "FROM"}},
+		{"SELECT name FROM pods EXCEPT SELECT spec.si| FROM widgets", []string{"size"}},
+		{"WITH wide AS (FROM widgets) SELECT name FROM pods UNION SELECT name FROM wi|", []string{"wide", "widgets", "widget", "widgets.example.com"}},
+		{"SELECT name |", []string{"AS", "FROM"}},
+		{"SELECT name f|", []string{"from"}},
+		{"SELECT name AS |", nil},
+		{"SELECT name FROM wid|", []string{"widgets", "widget", "widgets.example.com"}},
+		{"SELECT name FROM kube-system/pod|", []string{"poddisruptionbudgets", "poddisruptionbudget", "poddisruptionbudgets.policy", "pods", "pod"}},
+		{"SELECT name FROM prod-*:kube-system/pod|", []string{"poddisruptionbudgets", "poddisruptionbudget", "poddisruptionbudgets.policy", "pods", "pod"}},
+		{"SELECT name FROM prod:wid|", []string{"widgets", "widget", "widgets.example.com"}},
+		{"SELECT name FROM pods |", []string{"JOIN", "LEFT", "INNER", "WHERE", "ORDER BY", "LIMIT", "UNION", "INTERSECT", "EXCEPT"}},
+		{"SELECT name FROM pods p LEFT |", []string{"OUTER", "JOIN"}},
+		{"SELECT name FROM pods p JOIN no|", []string{"nodes", "node", "no"}},
+		{"SELECT name FROM pods p JOIN nodes n |", []string{"ON"}},
+		{"SELECT name FROM pods p JOIN widgets w ON w.spec.co|", []string{"color", "config"}},
+		{"SELECT name FROM pods p JOIN nodes n ON p.spec.node| = n.name", []string{"nodeName", "nodeSelector"}},
+		{"SELECT name FROM pods p JOIN nodes n ON n|", []string{"n", "name", "namespace", "now", "not", "null"}},
+		{"SELECT name FROM pods p JOIN nodes n ON p.name = n.name |", []string{"AND", "OR", "NOT", "IN", "LIKE", "ILIKE", "BETWEEN", "IS", "JOIN", "LEFT", "INNER", "WHERE", "ORDER BY", "LIMIT", "UNION", "INTERSECT", "EXCEPT"}},
+		{"SELECT p.st| FROM pods p JOIN nodes n ON p.spec.nodeName = n.name", []string{"status"}},
+		{"SELECT name FROM pods o|", []string{"order by"}},
+		{"SELECT st| FROM pods", []string{"status"}},
+		{"SELECT status.p| FROM pods", []string{"phase", "podIP"}},
+		{"SELECT spec.containers[0].| FROM pods", []string{"image", "name", "ports", "resources"}},
+		{"SELECT spec.containers[*].resources.l| FROM pods", []string{"limits"}},
+		{"SELECT spec.unknown.| FROM pods", nil},
+		{"SELECT spec.size, spec.co| FROM widgets", []string{"color", "config"}},
+		{"SELECT co| FROM widgets", []string{"count"}},
+		{"FROM pods WHERE na|", []string{"name", "namespace"}},
+		{"FROM prod-*:pods WHERE cl|", []string{"cluster"}},
+		{"FROM pods WHERE name |", []string{"AND", "OR", "NOT", "IN", "LIKE", "ILIKE", "BETWEEN", "IS", "ORDER BY", "LIMIT", "UNION", "INTERSECT", "EXCEPT"}},
+		{"FROM pods WHERE name = 'a' a|", []string{"and"}},
+		{"FROM pods WHERE deleted IS |", []string{"NOT", "NULL"}},
+		{"FROM pods WHERE NOT EX|", []string{"EXISTS"}},
+		{"FROM pods WHERE name = 'a|", nil},
+		{"FROM pods WHERE name = 'a' -- na|", nil},
+		{"FROM pods ORDER |", []string{"BY"}},
+		{"SELECT name AS n FROM pods ORDER BY |", nil},
+		{"SELECT name AS pod FROM pods ORDER BY po|", []string{"pod"}},
+		{"FROM pods ORDER BY name |", []string{"ASC", "DESC", "LIMIT"}},
+		{"FROM pods LIMIT 10; SELECT na|", []string{"name", "namespace"}},
+		{"FROM pods LIMIT 10; SELECT spec.node| FROM pods", []string{"nodeName", "nodeSelector"}},
+	}
+
+	for _, tc := range testCases {
+		result := completeAt(completer, tc.input)
+		if tc.expected == nil && strings.Contains(tc.input, "ORDER BY |") {
+			// Every field is proposed, only check the alias comes first
+			if len(result) == 0 || result[0] != "n" {
+				t.Errorf("For input '%s', expected the alias first, got %v", tc.input, result)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
+		}
+	}
+}
+
+func TestCompleteDetails(t *testing.T) {
+	validator := newTestValidator(t)
+	completer := NewCompleter(validator.Resolver, validator.Schemas)
+
+	query := "SELECT spec.containers[0].resources.limits.memory, spec.pri FROM pods"
+	cursor := strings.Index(query, " FROM")
+	suggestions := completer.Complete(query, cursor)
+
+	expected := []Suggestion{{Text: "priority", Kind: FieldSuggestion, Detail: "int", Start: cursor - 3}}
+	if !reflect.DeepEqual(suggestions, expected) {
+		t.Errorf("expected %+v, got %+v", expected, suggestions)
+	}
+
+	suggestions = completer.Complete("FROM deploy", 11)
+	if len(suggestions) == 0 || suggestions[0].Kind != ResourceSuggestion || suggestions[0].Detail != "Deployment (apps)" {
+		t.Errorf("expected a Deployment resource suggestion, got %+v", suggestions)
+	}
+}
+
+func TestCompleteWithoutSchemas(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"SELECT s| FROM pods", []string{"spec", "status", "selects", "sum"}},
+		{"SELECT spec.| FROM pods", nil},
+		{"SELECT name FROM no|", []string{"nodes", "node", "no"}},
+	}
+
+	completer := NewCompleter(DefaultResolver(), nil)
+	for _, tc := range testCases {
+		if result := completeAt(completer, tc.input); !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
+		}
+	}
+
+	if result := Complete("FROM pods WH", 12); len(result) != 1 || result[0].Text != "WHERE" || result[0].Start != 10 {
+		t.Errorf("expected WHERE starting at 10, got %+v", result)
+	}
+}
@@ -0,0 +1,251 @@
+package kubesql
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// parse parses the query of a common table expression.
+func (c CTE) parse() (*Query, error) {
+	q, err := NewParser(string(c.Query)).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing common table expression '%s': %w", c.Name, err)
+	}
+	if q.Explain || len(q.With) > 0 || len(q.Compound) > 0 {
+		return nil, fmt.Errorf("common table expression '%s' must be a plain query", c.Name)
+	}
+	return q, nil
+}
+
+// withNames returns the names of common table expressions.
+func withNames(ctes []CTE) []string {
+	names := make([]string, len(ctes))
+	for i, cte := range ctes {
+		names[i] = cte.Name
+	}
+	return names
+}
+
+// cteName returns the name, among with, of the common table expression a
+// resource reference reads, or "" when it reads a resource. References with
+// a namespace or clusters always read resources.
+func cteName(ref ResourceRef, with []string) string {
+	if ref.Namespace != "" || len(ref.Clusters) > 0 {
+		return ""
+	}
+	if i := slices.IndexFunc(with, func(name string) bool { return strings.EqualFold(name, ref.Resource) }); i >= 0 {
+		return with[i]
+	}
+	return ""
+}
+
+// bindTables sets the common table expression, among with, each table
+// reads.
+func bindTables(tables []table, with []string) {
+	for i := range tables {
+		tables[i].cte = cteName(tables[i].ref, with)
+	}
+}
+
+// withExecutions compiles the common table expressions of a query, each
+// seeing the ones before it. They share the evaluator of the query.
+func withExecutions(q *Query, parent *evaluator) ([]*execution, error) {
+	executions := make([]*execution, len(q.With))
+	for i, cte := range q.With {
+		cq, err := cte.parse()
+		if err != nil {
+			return nil, err
+		}
+		if executions[i], err = newExecution(cq, nil, parent, withNames(q.With[:i])); err != nil {
+			return nil, fmt.Errorf("error in common table expression '%s': %w", cte.Name, err)
+		}
+	}
+	return executions, nil
+}
+
+// cteObjects converts the rows of the result of a common table expression
+// into the objects the queries using it read. Selected fields are set at
+// their path in their table, see ctePath, so the same field names read
+// them; the objects of SELECT * are copied, and other columns are set by
+// column name.
+func cteObjects(q *Query, selects []Expr, names []string, result *Result) []map[string]any {
+	objects := make([]map[string]any, len(result.Rows))
+	for r, row := range result.Rows {
+		object := make(map[string]any)
+		for i, value := range row {
+			if i >= len(selects) {
+				break
+			}
+			if _, ok := selects[i].(*StarExpr); ok {
+				if fields, ok := value.(map[string]any); ok {
+					maps.Copy(object, fields)
+				}
+				continue
+			}
+			path := []Segment{{Name: result.Columns[i].Name}}
+			if ref, ok := selects[i].(*FieldRef); ok && (i >= len(q.Select) || q.Select[i].Alias == "") && isNamePath(ref.Path) {
+				path = ctePath(ref, names)
+			}
+			setPath(object, path, value)
+		}
+		objects[r] = object
+	}
+	return objects
+}
+
+// ctePath returns the path a selected field is set at in the objects of a
+// common table expression: its full path in its table, without the name
+// qualifying it among names, e.g. metadata.name for p.name.
+func ctePath(ref *FieldRef, names []string) []Segment {
+	path := ref.Path
+	if len(path) > 1 && tableOf(path, names) >= 0 {
+		path = path[1:]
+	}
+	return resolveAlias(path)
+}
+
+// isNamePath reports whether a field path only has object field names.
+func isNamePath(path []Segment) bool {
+	return !slices.ContainsFunc(path, func(segment Segment) bool { return segment.Array })
+}
+
+// setPath sets a field of an object, creating the objects along its path,
+// and copying the ones it shares with other objects.
+func setPath(object map[string]any, path []Segment, value any) {
+	for _, segment := range path[:len(path)-1] {
+		inner, ok := object[segment.Name].(map[string]any)
+		if ok {
+			inner = maps.Clone(inner)
+		} else {
+			inner = make(map[string]any)
+		}
+		object[segment.Name] = inner
+		object = inner
+	}
+	object[path[len(path)-1].Name] = value
+}
+
+// cteSchema is the schema of the rows of a common table expression, nil
+// when unknown.
+type cteSchema struct {
+	name   string
+	schema *Schema
+}
+
+// withNames returns the names of the common table expressions a query
+// sees.
+func (c *validation) withNames() []string {
+	names := make([]string, len(c.with))
+	for i, cte := range c.with {
+		names[i] = cte.name
+	}
+	return names
+}
+
+// withSchema returns the schema of the rows of a common table expression
+// the query sees.
+func (c *validation) withSchema(name string) *Schema {
+	for _, cte := range c.with {
+		if cte.name == name {
+			return cte.schema
+		}
+	}
+	return nil
+}
+
+// validateCTE checks a common table expression, seeing the ones before it,
+// and returns the schema of its rows. Its problems are reported with the
+// ones of the query, naming it, and located with source.
+func (v *Validator) validateCTE(check *validation, cte CTE, source *querySource) *Schema {
+	q, err := cte.parse()
+	if err != nil {
+		check.report(WithKeyword, string(cte.Query), "%v", err)
+		return nil
+	}
+	inner := &validation{validator: v, seen: make(map[string]struct{}), with: check.with, source: source}
+	columns := v.validate(inner, q)
+	for _, err := range inner.errors {
+		err.Message = fmt.Sprintf("in common table expression '%s': %s", cte.Name, err.Message)
+		check.errors = append(check.errors, err)
+	}
+	selects, err := parseSelect(q)
+	if err != nil {
+		return nil
+	}
+
+	// The rows have the fields cteObjects sets
+	schema := &Schema{Type: SchemaObject, Properties: make(map[string]*Schema)}
+	for i, expr := range selects {
+		if _, ok := expr.(*StarExpr); ok {
+			if len(inner.tables) > 1 {
+				for j, name := range inner.tables {
+					schema.Properties[name] = cmp.Or(inner.schemas[j], &Schema{})
+				}
+				continue
+			}
+			if len(inner.schemas) == 0 || inner.schemas[0] == nil {
+				return nil
+			}
+			maps.Copy(schema.Properties, inner.schemas[0].Properties)
+			schema.PreserveUnknown = inner.schemas[0].PreserveUnknown
+			continue
+		}
+		if ref, ok := expr.(*FieldRef); ok && q.Select[i].Alias == "" && isNamePath(ref.Path) {
+			field, err := lookupTableField(inner.schemas, inner.tables, ref.Path)
+			if err != nil || field == nil {
+				field = &Schema{}
+			}
+			setSchemaPath(schema, ctePath(ref, inner.tables), field)
+		} else if i < len(columns) {
+			schema.Properties[columns[i].Name] = typeSchema(columns[i].Type)
+		}
+	}
+	return schema
+}
+
+// setSchemaPath sets the schema of a field of an object schema, creating
+// the object schemas along its path, and copying the ones it shares.
+func setSchemaPath(schema *Schema, path []Segment, field *Schema) {
+	for _, segment := range path[:len(path)-1] {
+		inner := &Schema{Type: SchemaObject}
+		if existing := schema.Properties[segment.Name]; existing != nil {
+			copied := *existing
+			inner = &copied
+		}
+		inner.Properties = maps.Clone(inner.Properties)
+		if inner.Properties == nil {
+			inner.Properties = make(map[string]*Schema)
+		}
+		schema.Properties[segment.Name] = inner
+		schema = inner
+	}
+	schema.Properties[path[len(path)-1].Name] = field
+}
+
+// typeSchema returns a schema of the values of a type.
+func typeSchema(t Type) *Schema {
+	switch t {
+	case TypeString:
+		return &Schema{Type: SchemaString}
+	case TypeInt:
+		return &Schema{Type: SchemaInteger}
+	case TypeFloat:
+		return &Schema{Type: SchemaNumber}
+	case TypeBool:
+		return &Schema{Type: SchemaBoolean}
+	case TypeQuantity:
+		return &Schema{Type: SchemaString, Format: "quantity"}
+	case TypeDuration:
+		return &Schema{Type: SchemaString, Format: "duration"}
+	case TypeTimestamp:
+		return &Schema{Type: SchemaString, Format: "date-time"}
+	case TypeList:
+		return &Schema{Type: SchemaArray}
+	case TypeMap:
+		return &Schema{Type: SchemaObject}
+	}
+	return &Schema{}
+}
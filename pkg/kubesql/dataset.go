@@ -0,0 +1,210 @@
+package kubesql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Dataset holds a snapshot of Kubernetes objects that queries can be run
+// against, such as the output of "kubectl get all -A -o yaml".
+type Dataset struct {
+	objects []map[string]any
+}
+
+// NewDataset creates an empty dataset.
+func NewDataset() *Dataset {
+	return &Dataset{}
+}
+
+// LoadDataset loads the objects of JSON or YAML files. A path may be a
+// directory, in which case every .json, .yaml and .yml file in it is loaded.
+func LoadDataset(paths ...string) (*Dataset, error) {
+	dataset := NewDataset()
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		files := []string{path}
+		if info.IsDir() {
+			files = nil
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				switch strings.ToLower(filepath.Ext(entry.Name())) {
+				case ".json", ".yaml", ".yml":
+					if !entry.IsDir() {
+						files = append(files, filepath.Join(path, entry.Name()))
+					}
+				}
+			}
+		}
+
+		for _, file := range files {
+			if err := dataset.LoadFile(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return dataset, nil
+}
+
+// LoadFile loads the objects of a JSON or YAML file. YAML files may contain
+// several documents.
+func (d *Dataset) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := d.Load(data); err != nil {
+		return fmt.Errorf("error loading objects from %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load loads objects from JSON or YAML data. Documents may be single
+// objects or lists, such as the output of kubectl get -o json.
+func (d *Dataset) Load(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var document map[string]any
+		if err := json.Unmarshal(trimmed, &document); err != nil {
+			return err
+		}
+		d.Add(document)
+		return nil
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var document map[string]any
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if document != nil {
+			d.Add(document)
+		}
+	}
+}
+
+// Add adds objects to the dataset. The items of lists are added instead of
+// the lists themselves, taking their kind and apiVersion from the list when
+// they don't have one.
+func (d *Dataset) Add(objects ...map[string]any) {
+	for _, object := range objects {
+		object, _ = normalizeValue(object).(map[string]any)
+		if object == nil {
+			continue
+		}
+
+		kind, _ := object["kind"].(string)
+		items, isList := object["items"].([]any)
+		if !isList || !strings.HasSuffix(kind, "List") {
+			d.objects = append(d.objects, object)
+			continue
+		}
+
+		for _, item := range items {
+			item, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			if _, ok := item["kind"]; !ok && kind != "List" {
+				item["kind"] = strings.TrimSuffix(kind, "List")
+				item["apiVersion"] = object["apiVersion"]
+			}
+			d.Add(item)
+		}
+	}
+}
+
+// Len returns the number of objects in the dataset.
+func (d *Dataset) Len() int {
+	return len(d.objects)
+}
+
+// Objects returns the objects of a resource type, matched by kind and API
+// group.
+func (d *Dataset) Objects(resource ResourceInfo) []map[string]any {
+	var objects []map[string]any
+	for _, object := range d.objects {
+		kind, _ := object["kind"].(string)
+		apiVersion, _ := object["apiVersion"].(string)
+		group := ""
+		if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+			group = apiVersion[:i]
+		}
+		if strings.EqualFold(kind, resource.Kind) && strings.EqualFold(group, resource.Group) {
+			objects = append(objects, object)
+		}
+	}
+	return objects
+}
+
+// Execute resolves the resources of a query and of its subqueries, and runs
+// the query over the objects of those resources.
+func (d *Dataset) Execute(q *Query, resolver Resolver) (*Result, error) {
+	tables, err := allTables(q)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([][]map[string]any, len(tables))
+	for i, t := range tables {
+		resource, err := resolver.Resolve(t.ref.Resource)
+		if err != nil {
+			return nil, err
+		}
+		objects[i] = d.Objects(resource)
+	}
+	return ExecuteJoin(q, objects)
+}
+
+// normalizeValue converts decoded YAML values to the values produced by
+// decoding JSON, which the evaluator works with: numbers are float64 and
+// timestamps are RFC 3339 strings.
+func normalizeValue(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for key, item := range value {
+			value[key] = normalizeValue(item)
+		}
+		return value
+	case map[any]any:
+		object := make(map[string]any, len(value))
+		for key, item := range value {
+			object[fmt.Sprint(key)] = normalizeValue(item)
+		}
+		return object
+	case []any:
+		for i, item := range value {
+			value[i] = normalizeValue(item)
+		}
+		return value
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case uint64:
+		return float64(value)
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	}
+	return value
+}
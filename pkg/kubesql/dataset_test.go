@@ -0,0 +1,85 @@
+package kubesql
+
+import (
+	"testing"
+)
+
+func TestLoadDataset(t *testing.T) {
+	dataset := testSnapshot(t)
+
+	// Three pods from a list, a namespace and a deployment
+	if dataset.Len() != 5 {
+		t.Errorf("expected 5 objects, got %d", dataset.Len())
+	}
+
+	testCases := []struct {
+		resource ResourceInfo
+		expected int
+	}{
+		{ResourceInfo{Name: "pods", Kind: "Pod"}, 3},
+		{ResourceInfo{Name: "namespaces", Kind: "Namespace"}, 1},
+		{ResourceInfo{Name: "deployments", Kind: "Deployment", Group: "apps"}, 1},
+		{ResourceInfo{Name: "deployments", Kind: "Deployment", Group: "extensions"}, 0},
+	}
+
+	for _, tc := range testCases {
+		if objects := dataset.Objects(tc.resource); len(objects) != tc.expected {
+			t.Errorf("For resource '%s.%s', expected %d objects, got %d", tc.resource.Name, tc.resource.Group, tc.expected, len(objects))
+		}
+	}
+}
+
+func TestDatasetLoadNormalizesValues(t *testing.T) {
+	dataset := NewDataset()
+	err := dataset.Load([]byte(`
+apiVersion: v1
+kind: Pod
+metadata:
+  name: a
+  creationTimestamp: 2024-01-01T10:00:00Z
+spec:
+  priority: 7
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	object := dataset.Objects(ResourceInfo{Kind: "Pod"})[0]
+	if value := fieldValue(object, []Segment{{Name: "spec"}, {Name: "priority"}}); value != float64(7) {
+		t.Errorf("expected priority float64(7), got %#v", value)
+	}
+	if value := fieldValue(object, []Segment{{Name: "metadata"}, {Name: "creationTimestamp"}}); value != "2024-01-01T10:00:00Z" {
+		t.Errorf("expected the creation timestamp as a string, got %#v", value)
+	}
+}
+
+func TestLoadDatasetErrors(t *testing.T) {
+	if _, err := LoadDataset("testdata/missing"); err == nil {
+		t.Errorf("expected an error loading a missing path")
+	}
+	if err := NewDataset().Load([]byte("{not json")); err == nil {
+		t.Errorf("expected an error loading invalid JSON")
+	}
+}
+
+func TestScriptComplete(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected bool
+	}{
+		{"", false},
+		{"SELECT name FROM pods", false},
+		{"SELECT name FROM pods;", true},
+		{"SELECT name\nFROM pods ;  ", true},
+		{"SELECT name FROM pods; -- done", true},
+		{"SELECT name FROM pods WHERE name = 'a;", false},
+		{"SELECT name FROM pods /* ; */", false},
+		{"SELECT name FROM pods; FROM nodes", false},
+	}
+
+	for _, tc := range testCases {
+		if result := ScriptComplete(tc.input); result != tc.expected {
+			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package kubesql
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Description documents a word of a query, e.g. for editor hovers.
+type Description struct {
+	Kind   SuggestionKind // What the word stands for
+	Name   string         // The word, the whole field path up to it for fields
+	Detail string         // Type of a field, signature of a function or kind of a resource
+	Doc    string         // Documentation, empty if none is known
+	Start  int            // Byte offset of the start of the word
+	End    int            // Byte offset just past the end of the word
+}
+
+// functionDocs holds the signature and documentation of the functions.
+var functionDocs = map[string][2]string{
+	"count":   {"count(*), count(expr) int", "Number of matching objects, or of the non NULL values of expr."},
+	"sum":     {"sum(expr)", "Sum of the non NULL values of expr over the matching objects."},
+	"avg":     {"avg(expr) float", "Average of the non NULL values of expr over the matching objects."},
+	"min":     {"min(expr)", "Smallest value of expr over the matching objects."},
+	"max":     {"max(expr)", "Largest value of expr over the matching objects."},
+	"len":     {"len(value) int", "Length of a string, or number of items of a list or map."},
+	"length":  {"length(value) int", "Length of a string, or number of items of a list or map."},
+	"lower":   {"lower(text) string", "Text converted to lower case."},
+	"upper":   {"upper(text) string", "Text converted to upper case."},
+	"trim":    {"trim(text) string", "Text without leading and trailing white space."},
+	"join":    {"join(list, separator) string", "Items of a list joined into a string, separated by separator or ','."},
+	"now":     {"now() timestamp", "Current time, the same for the whole query."},
+	"owns":    {"owns(owner, object) bool", "Whether object has an owner reference to owner, e.g. JOIN replicasets rs ON owns(rs, p)."},
+	"selects": {"selects(selector, object) bool", "Whether the label selector of a service, workload, network policy or disruption budget matches object, in its namespace."},
+	"mounts":  {"mounts(pod, object) bool", "Whether a volume of a pod or pod template uses a configmap, secret or persistent volume claim."},
+}
+
+// keywordDocs holds the documentation of the main keywords.
+var keywordDocs = map[string]string{
+	"SELECT":    "Fields and expressions to return, e.g. SELECT name, status.phase AS phase.",
+	"FROM":      "Resource to query, optionally in a namespace, e.g. FROM kube-system/pods.",
+	"JOIN":      "Pairs the objects with the objects of another resource matching the ON condition, e.g. JOIN nodes n ON p.spec.nodeName = n.name.",
+	"ON":        "Condition the joined objects match, fields are qualified by their resource name or alias.",
+	"INNER":     "INNER JOIN, the default, keeps only the objects matching an object of the joined resource.",
+	"LEFT":      "LEFT JOIN also keeps the objects matching no object of the joined resource, whose fields are NULL.",
+	"OUTER":     "LEFT OUTER JOIN is the same as LEFT JOIN.",
+	"WHERE":     "Condition the returned objects match, e.g. WHERE status.phase = 'Running'.",
+	"ORDER":     "Sort keys of the results, e.g. ORDER BY created DESC.",
+	"BY":        "Sort keys of the results, e.g. ORDER BY created DESC.",
+	"LIMIT":     "Maximum number of results.",
+	"AS":        "Names a SELECT field, the name can be used in ORDER BY.",
+	"LIKE":      "Pattern match, % matches any text and _ any single character.",
+	"ILIKE":     "Case insensitive pattern match, % matches any text and _ any single character.",
+	"IN":        "Matches any value of a list, e.g. phase IN ('Pending', 'Failed'), or of the field a subquery selects, e.g. spec.nodeName IN (SELECT name FROM nodes).",
+	"EXISTS":    "True when a subquery returns a row, e.g. EXISTS (SELECT * FROM services s WHERE selects(s, pods)).",
+	"BETWEEN":   "Range match including both bounds, e.g. priority BETWEEN 1 AND 10.",
+	"IS":        "NULL check, e.g. deleted IS NULL or deleted IS NOT NULL.",
+	"WITH":      "Names queries the statement reads like resources, e.g. WITH failing AS (SELECT name FROM pods WHERE status.phase = 'Failed') SELECT count(*) FROM failing.",
+	"UNION":     "Combines the rows of two queries selecting as many columns, without duplicates unless UNION ALL, e.g. SELECT name FROM deployments UNION SELECT name FROM statefulsets.",
+	"INTERSECT": "Keeps the rows both queries return, binds tighter than UNION and EXCEPT, INTERSECT ALL keeps duplicates.",
+	"EXCEPT":    "Keeps the rows of the first query the second one does not return, EXCEPT ALL removes one row per matching row.",
+	"EXPLAIN":   "Shows how the statement would run instead of running it: the selectors sent to the API server, the client side filtering and sorting, and the estimated cost.",
+}
+
+// Describe documents the word at offset in query: a keyword, a resource
+// after FROM, a function, or a field with its type and schema description.
+// It reports false when there is nothing to document at offset.
+func (c *Completer) Describe(query string, offset int) (Description, bool) {
+	tokens, err := tokenize(query)
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		tokens, _ = tokenize(query[:parseErr.Pos.Offset])
+	}
+
+	// The word at offset, the end of a word counts as on it
+	k := -1
+	for i, tok := range tokens {
+		if (tok.kind == tokenIdent || tok.kind == tokenQuotedIdent) && tok.pos <= offset && offset <= tok.end() {
+			k = i
+		}
+	}
+	if k < 0 {
+		return Description{}, false
+	}
+	tok := tokens[k]
+	description := Description{Name: tok.value, Start: tok.pos, End: tok.end()}
+
+	// The clause the word is in
+	clause := ""
+	for i := k - 1; i >= 0 && clause == "" && tokens[i].kind != tokenSemicolon; i-- {
+		for _, keyword := range []string{SelectKeyword, FromKeyword, JoinKeyword, OnKeyword, WhereKeyword, "ORDER", LimitKeyword} {
+			if tokens[i].isKeyword(keyword) && !isFunctionCall(tokens, i) {
+				clause = keyword
+			}
+		}
+	}
+
+	switch {
+	case tok.kind == tokenIdent && isReservedKeyword(tok.text) && !isFunctionCall(tokens, k):
+		doc, ok := keywordDocs[strings.ToUpper(tok.text)]
+		if !ok {
+			return Description{}, false
+		}
+		description.Kind, description.Name, description.Detail, description.Doc = KeywordSuggestion, strings.ToUpper(tok.text), "keyword", doc
+	case (clause == FromKeyword || clause == JoinKeyword) && isCTEName(tokens, k):
+		description.Kind, description.Detail = ResourceSuggestion, "common table expression"
+		description.Doc = fmt.Sprintf("Rows of the query named %s in the WITH clause.", tok.value)
+	case clause == FromKeyword || clause == JoinKeyword:
+		resource, err := c.Resolver.Resolve(tok.value)
+		if err != nil {
+			return Description{}, false
+		}
+		description.Kind, description.Name = ResourceSuggestion, resource.Name
+		description.Detail, description.Doc = describeResource(resource)
+	case k+1 < len(tokens) && tokens[k+1].kind == tokenLParen:
+		doc, ok := functionDocs[strings.ToLower(tok.value)]
+		if !ok {
+			return Description{}, false
+		}
+		description.Kind, description.Name, description.Detail, description.Doc = FunctionSuggestion, strings.ToLower(tok.value), doc[0], doc[1]
+	default:
+		path := fieldPathAt(tokens, k)
+		description.Kind = FieldSuggestion
+		description.Name = (&FieldRef{Path: path}).String()
+		description.Detail = "field"
+
+		names, resources := c.statementTables(query, tok.pos)
+		if i := slices.Index(names, tok.value); i >= 0 && len(path) == 1 {
+			description.Detail = "table"
+			if resources[i] != nil {
+				description.Doc = fmt.Sprintf("Objects of %s.", resources[i].Name)
+			}
+			break
+		}
+		schemas := make([]*Schema, len(resources))
+		for i, resource := range resources {
+			if resource != nil && c.Schemas != nil {
+				schemas[i], _ = c.Schemas.Schema(*resource)
+			}
+		}
+		// Short names are expanded within the table of the field
+		var schema *Schema
+		inTable := path
+		i := tableOf(path, names)
+		if i >= 0 {
+			inTable = path[1:]
+		}
+		if len(schemas) > 0 {
+			schema = schemas[max(i, 0)]
+		}
+		if full := resolveAlias(inTable); len(full) != len(inTable) {
+			description.Doc = fmt.Sprintf("Short name of %s.", (&FieldRef{Path: full}).Name())
+		}
+		if schema != nil {
+			field, err := lookupTableField(schemas, names, path)
+			if err != nil {
+				description.Detail = "unknown field"
+				break
+			}
+			description.Detail = schemaType(field).String()
+			if field != nil && field.Description != "" {
+				description.Doc = strings.TrimSpace(description.Doc + "\n\n" + field.Description)
+			}
+		}
+	}
+
+	return description, true
+}
+
+// isCTEName reports whether the resource name at token k reads a common
+// table expression of its statement.
+func isCTEName(tokens []token, k int) bool {
+	if k > 0 && (tokens[k-1].text == "/" || tokens[k-1].kind == tokenColon) {
+		return false
+	}
+	start := k
+	for start > 0 && tokens[start-1].kind != tokenSemicolon {
+		start--
+	}
+	_, with, ok := withScope(tokens[start:], tokens[k].pos)
+	return ok && cteName(ResourceRef{Resource: tokens[k].value}, with) != ""
+}
+
+// describeResource returns the detail and documentation of a resource.
+func describeResource(resource ResourceInfo) (string, string) {
+	version := resource.Version
+	if resource.Group != "" {
+		version = resource.Group + "/" + version
+	}
+
+	scope := "Namespaced"
+	if !resource.Namespaced {
+		scope = "Cluster scoped"
+	}
+	doc := scope + " resource."
+	if names := resource.Names()[1:]; len(names) > 0 {
+		doc += " Also known as " + strings.Join(names, ", ") + "."
+	}
+	return fmt.Sprintf("%s (%s)", resource.Kind, version), doc
+}
+
+// fieldPathAt returns the field path ending with the identifier at index k
+// of tokens, e.g. spec.containers[0].name for the name token.
+func fieldPathAt(tokens []token, k int) []Segment {
+	path := []Segment{{Name: tokens[k].value}}
+	for i := k - 1; i >= 1 && tokens[i].kind == tokenDot && tokens[i].end() == tokens[i+1].pos; i-- {
+		// Index segments, e.g. [0] or [*], before the dot
+		j := i - 1
+		var indexes []Segment
+		for j >= 2 && tokens[j].kind == tokenRBracket && tokens[j-2].kind == tokenLBracket {
+			index := -1
+			if tokens[j-1].kind == tokenNumber {
+				index, _ = strconv.Atoi(tokens[j-1].text)
+			}
+			indexes = append([]Segment{{Index: index, Array: true}}, indexes...)
+			j -= 3
+		}
+		if j < 0 || (tokens[j].kind != tokenIdent && tokens[j].kind != tokenQuotedIdent) {
+			break
+		}
+		path = append(append([]Segment{{Name: tokens[j].value}}, indexes...), path...)
+		i = j
+	}
+	return path
+}
@@ -0,0 +1,65 @@
+package kubesql
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescribe(t *testing.T) {
+	validator := newTestValidator(t)
+	completer := NewCompleter(validator.Resolver, validator.Schemas)
+
+	testCases := []struct {
+		input  string
+		kind   SuggestionKind
+		name   string
+		detail string
+		doc    string
+	}{
+		{"SEL|ECT name FROM pods", KeywordSuggestion, "SELECT", "keyword", "Fields and expressions to return"},
+		{"SELECT name FROM po|ds", ResourceSuggestion, "pods", "Pod (v1)", "Namespaced resource. Also known as pod, po."},
+		{"FROM kube-system/po| WHERE name = 'a'", ResourceSuggestion, "pods", "Pod (v1)", "Namespaced resource."},
+		{"SELECT le|n(name) FROM pods", FunctionSuggestion, "len", "len(value) int", "Length of a string"},
+		{"SELECT spec.containers[1].ima|ge FROM pods", FieldSuggestion, "spec.containers[1].image", "string", ""},
+		{"SELECT status|.phase FROM pods", FieldSuggestion, "status", "map", ""},
+		{"SELECT name| FROM pods", FieldSuggestion, "name", "string", "Short name of metadata.name."},
+		{"SELECT spec.nothing| FROM pods", FieldSuggestion, "spec.nothing", "unknown field", ""},
+		{"SELECT spec.size| FROM widgets", FieldSuggestion, "spec.size", "int", ""},
+		{"SELECT x| FROM unknown", FieldSuggestion, "x", "field", ""},
+		{"SELECT p.name FROM pods p LEFT JO|IN nodes n ON p.spec.nodeName = n.name", KeywordSuggestion, "JOIN", "keyword", "Pairs the objects"},
+		{"SELECT jo|in(labels.tier) FROM pods", FunctionSuggestion, "join", "join(list, separator) string", "Items of a list"},
+		{"SELECT p.name FROM pods p JOIN no|des n ON p.spec.nodeName = n.name", ResourceSuggestion, "nodes", "Node (v1)", "Cluster scoped resource."},
+		{"SELECT p.spec.nodeNa|me FROM pods p JOIN nodes n ON p.spec.nodeName = n.name", FieldSuggestion, "p.spec.nodeName", "string", ""},
+		{"SELECT p.na|me FROM pods p JOIN nodes n ON p.spec.nodeName = n.name", FieldSuggestion, "p.name", "string", "Short name of metadata.name."},
+		{"SELECT n| FROM pods p JOIN nodes n ON p.spec.nodeName = n.name", FieldSuggestion, "n", "table", "Objects of nodes."},
+		{"WI|TH bad AS (FROM pods) SELECT name FROM bad", KeywordSuggestion, "WITH", "keyword", "Names queries"},
+		{"WITH pending AS (FROM pods) SELECT name FROM pen|ding", ResourceSuggestion, "pending", "common table expression", "Rows of the query named pending"},
+		{"WITH pods AS (FROM po|ds) SELECT name FROM pods", ResourceSuggestion, "pods", "Pod (v1)", "Namespaced resource."},
+		{"SELECT name FROM pods UNI|ON ALL SELECT name FROM nodes", KeywordSuggestion, "UNION", "keyword", "Combines the rows"},
+		{"SELECT name FROM pods EXCEPT SELECT spec.si|ze FROM widgets", FieldSuggestion, "spec.size", "int", ""},
+	}
+
+	for _, tc := range testCases {
+		cursor := strings.Index(tc.input, "|")
+		query := tc.input[:cursor] + tc.input[cursor+1:]
+		description, ok := completer.Describe(query, cursor)
+		if !ok {
+			t.Errorf("For input '%s', expected a description", tc.input)
+			continue
+		}
+		if description.Kind != tc.kind || description.Name != tc.name || description.Detail != tc.detail || !strings.HasPrefix(description.Doc, tc.doc) {
+			t.Errorf("For input '%s', expected %v %s (%s) %q, got %v %s (%s) %q", tc.input,
+				tc.kind, tc.name, tc.detail, tc.doc, description.Kind, description.Name, description.Detail, description.Doc)
+		}
+		if description.Start > cursor || description.End < cursor {
+			t.Errorf("For input '%s', expected the word around %d, got %d-%d", tc.input, cursor, description.Start, description.End)
+		}
+	}
+
+	for _, input := range []string{"SELECT name FROM pods WHERE x = '|a'", "SELECT name FROM pods LIMIT 1|0", "SELECT | FROM pods"} {
+		cursor := strings.Index(input, "|")
+		if description, ok := completer.Describe(input[:cursor]+input[cursor+1:], cursor); ok {
+			t.Errorf("For input '%s', expected no description, got %+v", input, description)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package kubesql
+
+import (
+	"fmt"
+)
+
+// Position describes a location in the query text.
+type Position struct {
+	Offset int `json:"offset" yaml:"offset"` // Byte offset from the start of the query, starting at 0
+	Line   int `json:"line" yaml:"line"`     // Line number, starting at 1
+	Column int `json:"column" yaml:"column"` // Column number in bytes, starting at 1
+}
+
+// String returns the position in "line:column" form.
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// ParseError describes a syntax error in a KubeSQL query together with the
+// position where it was detected.
+type ParseError struct {
+	Pos        Position // Location of the error in the query text
+	Message    string   // Human readable description of the error
+	Suggestion string   // Likely intended keyword for a misspelled word, empty if none
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s (at line %d, column %d)", e.Message, e.Pos.Line, e.Pos.Column)
+}
+
+// positionAt converts a byte offset in text into a Position.
+// Offsets outside of the text are clamped to its bounds.
+func positionAt(text string, offset int) Position {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(text) {
+		offset = len(text)
+	}
+
+	pos := Position{Offset: offset, Line: 1, Column: 1}
+	for i := 0; i < offset; i++ {
+		if text[i] == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
@@ -0,0 +1,714 @@
+package kubesql
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// wildcard holds the values selected by a field path with a [*] wildcard.
+// Conditions on a wildcard hold when they hold for any of its values.
+type wildcard []any
+
+// aggregateFunctions lists the functions computed over all result rows.
+var aggregateFunctions = map[string]bool{"count": true, "sum": true, "avg": true, "min": true, "max": true}
+
+// isAggregate reports whether expr is a call of an aggregate function.
+func isAggregate(expr Expr) bool {
+	call, ok := expr.(*CallExpr)
+	return ok && aggregateFunctions[strings.ToLower(call.Func)]
+}
+
+// evaluator evaluates expressions over Kubernetes objects decoded from JSON
+// or YAML. Values are nil, bool, float64, string, time.Time, time.Duration,
+// map[string]any, []any or wildcard.
+type evaluator struct {
+	now        time.Time                   // Value of now(), fixed for a whole query
+	patterns   map[string]*regexp.Regexp   // Compiled LIKE and regular expression patterns
+	aggregates map[*CallExpr]any           // Precomputed aggregate function values
+	tables     []string                    // Names qualifying fields, see rowPath
+	scope      []string                    // Names of the tables subqueries see, see visibleNames
+	subqueries map[*Statement]*execution   // Compiled subqueries of the WHERE condition
+	ctes       map[string][]map[string]any // Objects of the common table expressions run, by lower case name
+}
+
+// newEvaluator creates an evaluator.
+func newEvaluator() *evaluator {
+	return &evaluator{now: time.Now().UTC(), patterns: make(map[string]*regexp.Regexp)}
+}
+
+// Eval evaluates an expression over a single object, e.g. a WHERE condition
+// over a pod. Fields missing from the object evaluate to nil (NULL).
+// Aggregate functions can not be evaluated over a single object.
+func Eval(expr Expr, object map[string]any) (any, error) {
+	value, err := newEvaluator().eval(expr, object)
+	if w, ok := value.(wildcard); ok {
+		return []any(w), err
+	}
+	return value, err
+}
+
+// eval evaluates expr over object.
+func (e *evaluator) eval(expr Expr, object map[string]any) (any, error) {
+	switch expr := expr.(type) {
+	case *Literal:
+		return literalValue(expr)
+	case *StarExpr:
+		return object, nil
+	case *FieldRef:
+		return fieldValue(object, rowPath(expr.Path, e.tables)), nil
+	case *ParenExpr:
+		return e.eval(expr.X, object)
+	case *UnaryExpr:
+		x, err := e.eval(expr.X, object)
+		if err != nil {
+			return nil, err
+		}
+		if expr.Op == "NOT" {
+			if x == nil {
+				return nil, nil
+			}
+			return !truthy(x), nil
+		}
+		return negate(x), nil
+	case *BinaryExpr:
+		return e.evalBinary(expr, object)
+	case *InExpr:
+		x, err := e.eval(expr.X, object)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]any, len(expr.List))
+		for i, item := range expr.List {
+			if items[i], err = e.eval(item, object); err != nil {
+				return nil, err
+			}
+		}
+		if expr.Subquery != nil {
+			if items, err = e.subqueryValues(expr.Subquery, object); err != nil {
+				return nil, err
+			}
+		}
+		return anyValue(x, func(value any) bool {
+			for _, item := range items {
+				if c, ok := compareValues(value, item); ok && c == 0 {
+					return !expr.Not
+				}
+			}
+			return expr.Not
+		}), nil
+	case *ExistsExpr:
+		result, err := e.subquery(expr.Subquery, object)
+		if err != nil {
+			return nil, err
+		}
+		return len(result.Rows) > 0, nil
+	case *BetweenExpr:
+		values, err := e.evalAll(object, expr.X, expr.Low, expr.High)
+		if err != nil {
+			return nil, err
+		}
+		return anyValue(values[0], func(value any) bool {
+			low, okLow := compareValues(value, values[1])
+			high, okHigh := compareValues(value, values[2])
+			return (okLow && okHigh && low >= 0 && high <= 0) != expr.Not
+		}), nil
+	case *IsNullExpr:
+		x, err := e.eval(expr.X, object)
+		if err != nil {
+			return nil, err
+		}
+		// A wildcard is NULL when none of its values is set
+		isNull := !anyValue(x, func(value any) bool { return value != nil })
+		return isNull != expr.Not, nil
+	case *CallExpr:
+		return e.evalCall(expr, object)
+	}
+	return nil, fmt.Errorf("can not evaluate '%s'", expr)
+}
+
+// evalAll evaluates several expressions over object.
+func (e *evaluator) evalAll(object map[string]any, exprs ...Expr) ([]any, error) {
+	values := make([]any, len(exprs))
+	for i, expr := range exprs {
+		value, err := e.eval(expr, object)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (e *evaluator) evalBinary(expr *BinaryExpr, object map[string]any) (any, error) {
+	// AND and OR evaluate their right operand only when needed
+	switch expr.Op {
+	case "AND", "OR":
+		x, err := e.eval(expr.X, object)
+		if err != nil {
+			return nil, err
+		}
+		if truthy(x) == (expr.Op == "OR") {
+			return expr.Op == "OR", nil
+		}
+		y, err := e.eval(expr.Y, object)
+		if err != nil {
+			return nil, err
+		}
+		return truthy(y), nil
+	}
+
+	values, err := e.evalAll(object, expr.X, expr.Y)
+	if err != nil {
+		return nil, err
+	}
+	x, y := values[0], values[1]
+
+	switch expr.Op {
+	case "LIKE", "ILIKE", "~=", "!~":
+		pattern, ok := y.(string)
+		if !ok {
+			return false, nil
+		}
+		re, err := e.pattern(expr.Op, pattern)
+		if err != nil {
+			return nil, err
+		}
+		return anyValue(x, func(value any) bool {
+			s, ok := value.(string)
+			return ok && re.MatchString(s) != (expr.Op == "!~")
+		}), nil
+	case "+", "-", "*", "/", "%":
+		return arithmetic(expr.Op, x, y), nil
+	}
+
+	return anyValue(x, func(value any) bool {
+		c, ok := compareValues(value, y)
+		switch expr.Op {
+		case "=":
+			return ok && c == 0
+		case "!=":
+			return !ok || c != 0
+		case "<":
+			return ok && c < 0
+		case "<=":
+			return ok && c <= 0
+		case ">":
+			return ok && c > 0
+		case ">=":
+			return ok && c >= 0
+		}
+		return false
+	}), nil
+}
+
+// pattern returns the compiled regular expression of a LIKE, ILIKE or
+// regular expression match.
+func (e *evaluator) pattern(op, pattern string) (*regexp.Regexp, error) {
+	key := op + " " + pattern
+	if re, ok := e.patterns[key]; ok {
+		return re, nil
+	}
+
+	source := pattern
+	if op == "LIKE" || op == "ILIKE" {
+		var builder strings.Builder
+		if op == "ILIKE" {
+			builder.WriteString("(?i)")
+		}
+		builder.WriteString("^")
+		for _, char := range pattern {
+			switch char {
+			case '%':
+				builder.WriteString(".*")
+			case '_':
+				builder.WriteString(".")
+			default:
+				builder.WriteString(regexp.QuoteMeta(string(char)))
+			}
+		}
+		builder.WriteString("$")
+		source = builder.String()
+	}
+
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s': %v", pattern, err)
+	}
+	e.patterns[key] = re
+	return re, nil
+}
+
+func (e *evaluator) evalCall(expr *CallExpr, object map[string]any) (any, error) {
+	name := strings.ToLower(expr.Func)
+	if aggregateFunctions[name] {
+		if value, ok := e.aggregates[expr]; ok {
+			return value, nil
+		}
+		return nil, fmt.Errorf("aggregate function %s can only be used in SELECT", expr.Func)
+	}
+
+	args, err := e.evalAll(object, expr.Args...)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := functions[name]
+	if !ok {
+		return nil, suggestf(name, functionNames(), "unknown function '%s'", expr.Func)
+	}
+	if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+		return nil, fmt.Errorf("function %s expects %d to %d arguments, got %d", expr.Func, fn.minArgs, fn.maxArgs, len(args))
+	}
+
+	switch name {
+	case "now":
+		return e.now, nil
+	case "len", "length":
+		switch value := unwrap(args[0]).(type) {
+		case string:
+			return float64(utf8.RuneCountInString(value)), nil
+		case []any:
+			return float64(len(value)), nil
+		case map[string]any:
+			return float64(len(value)), nil
+		}
+		return nil, nil
+	case "lower", "upper", "trim":
+		value, ok := args[0].(string)
+		if !ok {
+			return nil, nil
+		}
+		switch name {
+		case "lower":
+			return strings.ToLower(value), nil
+		case "upper":
+			return strings.ToUpper(value), nil
+		}
+		return strings.TrimSpace(value), nil
+	case "join":
+		separator := ","
+		if len(args) == 2 {
+			if s, ok := args[1].(string); ok {
+				separator = s
+			}
+		}
+		list, ok := unwrap(args[0]).([]any)
+		if !ok {
+			return nil, nil
+		}
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = formatValue(item)
+		}
+		return strings.Join(parts, separator), nil
+	case "owns", "selects", "mounts":
+		x, okX := args[0].(map[string]any)
+		y, okY := args[1].(map[string]any)
+		if !okX || !okY {
+			return nil, nil
+		}
+		return relations[name](x, y), nil
+	}
+	return nil, fmt.Errorf("function %s can not be evaluated", expr.Func)
+}
+
+// aggregate computes an aggregate function call over a set of objects.
+func (e *evaluator) aggregate(call *CallExpr, objects []map[string]any) (any, error) {
+	name := strings.ToLower(call.Func)
+	if name == "count" && (len(call.Args) == 0 || isStar(call.Args[0])) {
+		return float64(len(objects)), nil
+	}
+	if len(call.Args) != 1 {
+		return nil, fmt.Errorf("function %s expects 1 argument, got %d", call.Func, len(call.Args))
+	}
+
+	// Collect the non NULL values, expanding wildcards
+	var values []any
+	for _, object := range objects {
+		value, err := e.eval(call.Args[0], object)
+		if err != nil {
+			return nil, err
+		}
+		if w, ok := value.(wildcard); ok {
+			for _, item := range w {
+				if item != nil {
+					values = append(values, item)
+				}
+			}
+		} else if value != nil {
+			values = append(values, value)
+		}
+	}
+
+	switch name {
+	case "count":
+		return float64(len(values)), nil
+	case "min", "max":
+		var result any
+		for _, value := range values {
+			c, ok := compareValues(value, result)
+			if result == nil || ok && (c < 0) == (name == "min") && c != 0 {
+				result = value
+			}
+		}
+		return result, nil
+	}
+
+	// sum and avg
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var total any = values[0]
+	for _, value := range values[1:] {
+		total = arithmetic("+", total, value)
+	}
+	if name == "avg" {
+		return arithmetic("/", total, float64(len(values))), nil
+	}
+	return total, nil
+}
+
+// isStar reports whether expr is the * of count(*).
+func isStar(expr Expr) bool {
+	_, ok := expr.(*StarExpr)
+	return ok
+}
+
+// literalValue returns the value of a literal. Quantities evaluate to their
+// value in base units and durations to a time.Duration.
+func literalValue(literal *Literal) (any, error) {
+	switch literal.Kind {
+	case StringLiteral:
+		return literal.Value, nil
+	case BoolLiteral:
+		return strings.EqualFold(literal.Value, "TRUE"), nil
+	case NullLiteral:
+		return nil, nil
+	}
+
+	t, err := numberType(literal.Value)
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case TypeDuration:
+		return parseDuration(literal.Value)
+	default:
+		return parseQuantity(literal.Value)
+	}
+}
+
+// fieldValue returns the value at a field path of an object, nil if the
+// path does not exist. A [*] segment selects the values of every item.
+func fieldValue(value any, path []Segment) any {
+	for i, segment := range path {
+		switch {
+		case segment.Array && segment.Index < 0:
+			list, _ := value.([]any)
+			values := wildcard{}
+			for _, item := range list {
+				if w, ok := fieldValue(item, path[i+1:]).(wildcard); ok {
+					values = append(values, w...)
+				} else {
+					values = append(values, fieldValue(item, path[i+1:]))
+				}
+			}
+			return values
+		case segment.Array:
+			list, ok := value.([]any)
+			if !ok || segment.Index >= len(list) {
+				return nil
+			}
+			value = list[segment.Index]
+		default:
+			object, ok := value.(map[string]any)
+			if !ok {
+				return nil
+			}
+			value = object[segment.Name]
+		}
+	}
+	return value
+}
+
+// anyValue applies a condition to a value, or to every value of a
+// wildcard, and reports whether it holds for any of them.
+func anyValue(value any, condition func(any) bool) bool {
+	if w, ok := value.(wildcard); ok {
+		for _, item := range w {
+			if condition(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return condition(value)
+}
+
+// unwrap converts a wildcard into a plain list.
+func unwrap(value any) any {
+	if w, ok := value.(wildcard); ok {
+		return []any(w)
+	}
+	return value
+}
+
+// truthy reports whether a value counts as true in a condition.
+func truthy(value any) bool {
+	switch value := value.(type) {
+	case bool:
+		return value
+	case wildcard:
+		return anyValue(value, truthy)
+	}
+	return false
+}
+
+// compareValues compares two values, converting strings to the type of the
+// other value (a quantity, timestamp or duration) when needed. It returns
+// false when the values can not be compared; NULL only equals NULL.
+func compareValues(a, b any) (int, bool) {
+	if a == nil || b == nil {
+		return 0, a == nil && b == nil
+	}
+
+	// Compare strings with the other value converted from string
+	if _, ok := a.(string); ok {
+		if _, ok := b.(string); !ok {
+			c, ok := compareValues(b, a)
+			return -c, ok
+		}
+	}
+
+	switch x := a.(type) {
+	case string:
+		y := b.(string)
+		if tx, err := parseTimestamp(x); err == nil {
+			if ty, err := parseTimestamp(y); err == nil {
+				return tx.Compare(ty), true
+			}
+		}
+		return strings.Compare(x, y), true
+	case float64:
+		switch y := b.(type) {
+		case float64:
+			return compareNumbers(x, y), true
+		case string:
+			if q, err := parseQuantity(y); err == nil {
+				return compareNumbers(x, q), true
+			}
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0, true
+			case !x:
+				return -1, true
+			}
+			return 1, true
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			return x.Compare(y), true
+		case string:
+			if t, err := parseTimestamp(y); err == nil {
+				return x.Compare(t), true
+			}
+		}
+	case time.Duration:
+		switch y := b.(type) {
+		case time.Duration:
+			return compareNumbers(float64(x), float64(y)), true
+		case string:
+			if d, err := parseDuration(y); err == nil {
+				return compareNumbers(float64(x), float64(d)), true
+			}
+		}
+	case map[string]any, []any:
+		if reflect.DeepEqual(a, b) {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// compareNumbers returns -1, 0 or 1 as a is less than, equal to or greater
+// than b.
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// negate returns the negation of a number or duration, nil otherwise.
+func negate(value any) any {
+	switch value := value.(type) {
+	case float64:
+		return -value
+	case time.Duration:
+		return -value
+	case string:
+		if q, err := parseQuantity(value); err == nil {
+			return -q
+		}
+	}
+	return nil
+}
+
+// arithmetic applies an arithmetic operator. Strings holding quantities,
+// timestamps or durations are converted to match the other operand.
+// Undefined operations, such as a division by zero, return nil.
+func arithmetic(op string, x, y any) any {
+	x, y = unwrap(x), unwrap(y)
+	if x == nil || y == nil {
+		return nil
+	}
+
+	// Concatenation, timestamp difference, or conversion of strings to the
+	// other operand type
+	if sx, ok := x.(string); ok {
+		if sy, ok := y.(string); ok {
+			if op == "+" {
+				return sx + sy
+			}
+			if tx, err := parseTimestamp(sx); err == nil && op == "-" {
+				if ty, err := parseTimestamp(sy); err == nil {
+					return tx.Sub(ty)
+				}
+			}
+			return nil
+		}
+		if x = convertString(sx, y); x == nil {
+			return nil
+		}
+	} else if sy, ok := y.(string); ok {
+		if y = convertString(sy, x); y == nil {
+			return nil
+		}
+	}
+
+	switch a := x.(type) {
+	case float64:
+		switch b := y.(type) {
+		case float64:
+			switch op {
+			case "+":
+				return a + b
+			case "-":
+				return a - b
+			case "*":
+				return a * b
+			case "/":
+				if b == 0 {
+					return nil
+				}
+				return a / b
+			case "%":
+				if b == 0 {
+					return nil
+				}
+				return math.Mod(a, b)
+			}
+		case time.Duration:
+			if op == "*" {
+				return time.Duration(a * float64(b))
+			}
+		}
+	case time.Duration:
+		switch b := y.(type) {
+		case time.Duration:
+			switch op {
+			case "+":
+				return a + b
+			case "-":
+				return a - b
+			case "/":
+				if b == 0 {
+					return nil
+				}
+				return float64(a) / float64(b)
+			}
+		case float64:
+			switch op {
+			case "*":
+				return time.Duration(float64(a) * b)
+			case "/":
+				if b == 0 {
+					return nil
+				}
+				return time.Duration(float64(a) / b)
+			}
+		case time.Time:
+			if op == "+" {
+				return b.Add(a)
+			}
+		}
+	case time.Time:
+		switch b := y.(type) {
+		case time.Time:
+			if op == "-" {
+				return a.Sub(b)
+			}
+		case time.Duration:
+			switch op {
+			case "+":
+				return a.Add(b)
+			case "-":
+				return a.Add(-b)
+			}
+		}
+	}
+	return nil
+}
+
+// convertString converts a string operand to the kind of value it is
+// combined with: a quantity for numbers, a timestamp for times, and a
+// timestamp or a duration for durations. It returns nil if it can't.
+func convertString(s string, other any) any {
+	switch other.(type) {
+	case float64:
+		if q, err := parseQuantity(s); err == nil {
+			return q
+		}
+	case time.Time:
+		if t, err := parseTimestamp(s); err == nil {
+			return t
+		}
+	case time.Duration:
+		if t, err := parseTimestamp(s); err == nil {
+			return t
+		}
+		if d, err := parseDuration(s); err == nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// formatValue formats a value for display.
+func formatValue(value any) string {
+	switch value := unwrap(value).(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+			return fmt.Sprintf("%d", int64(value))
+		}
+		return fmt.Sprintf("%g", value)
+	case time.Time:
+		return value.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,139 @@
+package kubesql
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testPod returns a pod object as decoded from JSON.
+func testPod() map[string]any {
+	return map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]any{
+			"name":              "web-1",
+			"namespace":         "default",
+			"labels":            map[string]any{"app": "web"},
+			"creationTimestamp": "2024-01-01T10:00:00Z",
+		},
+		"spec": map[string]any{
+			"priority": float64(10),
+			"containers": []any{
+				map[string]any{"name": "nginx", "image": "nginx:1.25", "resources": map[string]any{"limits": map[string]any{"memory": "256Mi"}}},
+				map[string]any{"name": "sidecar", "image": "envoy:1.29"},
+			},
+		},
+		"status": map[string]any{"phase": "Running"},
+	}
+}
+
+func TestEval(t *testing.T) {
+	testCases := []struct {
+		input    TSLQuery
+		expected any
+	}{
+		{"name", "web-1"},
+		{"metadata.namespace", "default"},
+		{"labels.app", "web"},
+		{"labels.missing", nil},
+		{"spec.containers[1].name", "sidecar"},
+		{"spec.containers[5].name", nil},
+		{"spec.containers[*].name", []any{"nginx", "sidecar"}},
+		{"spec.priority + 1", float64(11)},
+		{"spec.priority / 4", 2.5},
+		{"spec.priority / 0", nil},
+		{"-spec.priority", float64(-10)},
+		{"name + '-x'", "web-1-x"},
+		{"512Mi * 2", float64(1 << 30)},
+		{"1h + 90s", 61*time.Minute + 30*time.Second},
+		{"created + 1h", time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"created - '2024-01-01'", 10 * time.Hour},
+		{"upper(name)", "WEB-1"},
+		{"len(spec.containers)", float64(2)},
+		{"join(spec.containers[*].name, '/')", "nginx/sidecar"},
+		{"name = 'web-1'", true},
+		{"name != 'web-1'", false},
+		{"spec.priority > 5", true},
+		{"spec.priority BETWEEN 1 AND 9", false},
+		{"status.phase IN ('Running', 'Pending')", true},
+		{"status.phase NOT IN ('Running', 'Pending')", false},
+		{"name LIKE 'web-%'", true},
+		{"name LIKE 'web_'", false},
+		{"name ILIKE 'WEB-_'", true},
+		{"name ~= '^w.b'", true},
+		{"name !~ '^w.b'", false},
+		{"spec.containers[*].name = 'sidecar'", true},
+		{"spec.containers[*].image LIKE 'redis%'", false},
+		{"spec.containers[0].resources.limits.memory >= 256Mi", true},
+		{"spec.containers[0].resources.limits.memory < 0.2Gi", false},
+		{"created > '2023-12-31'", true},
+		{"created < now()", true},
+		{"deleted IS NULL", true},
+		{"labels IS NOT NULL", true},
+		{"spec.containers[*].resources IS NOT NULL", true},
+		{"spec.containers[*].missing IS NULL", true},
+		{"labels.missing = 'x'", false},
+		{"labels.missing = NULL", true},
+		{"name = 'web-1' AND NOT spec.priority < 5", true},
+		{"name = 'other' OR spec.priority = 10", true},
+	}
+
+	for _, tc := range testCases {
+		expr, err := ParseExpr(tc.input)
+		if err != nil {
+			t.Fatalf("For input '%s', unexpected parse error: %v", tc.input, err)
+		}
+		value, err := Eval(expr, testPod())
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, tc.expected) {
+			t.Errorf("For input '%s', expected %#v, got %#v", tc.input, tc.expected, value)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	testCases := []struct {
+		input    TSLQuery
+		expected string
+	}{
+		{"count(*)", "aggregate function count can only be used in SELECT"},
+		{"name ~= '('", "invalid pattern '(': error parsing regexp: missing closing ): `(`"},
+		{"lenght(name)", "unknown function 'lenght', did you mean 'length'?"},
+	}
+
+	for _, tc := range testCases {
+		expr, err := ParseExpr(tc.input)
+		if err != nil {
+			t.Fatalf("For input '%s', unexpected parse error: %v", tc.input, err)
+		}
+		_, err = Eval(expr, testPod())
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("For input '%s', expected error '%s', got %v", tc.input, tc.expected, err)
+		}
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	testCases := []struct {
+		input    any
+		expected string
+	}{
+		{nil, ""},
+		{"text", "text"},
+		{float64(42), "42"},
+		{2.5, "2.5"},
+		{true, "true"},
+		{90 * time.Minute, "1h30m0s"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02T03:04:05Z"},
+	}
+
+	for _, tc := range testCases {
+		if result := formatValue(tc.input); result != tc.expected {
+			t.Errorf("For input %#v, expected '%s', got '%s'", tc.input, tc.expected, result)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+package kubesql
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+)
+
+// Result holds the rows returned by a query.
+type Result struct {
+	Columns []Column        // Result columns, one per SELECT field
+	Rows    [][]any         // Row values, in column order
+	Errors  []*ClusterError // Clusters that failed, whose rows are missing, set by Runner
+}
+
+// Execute runs a query over objects of the queried resource, such as the
+// items of a pod list. Objects are decoded JSON or YAML maps. The namespace
+// of the FROM clause, WHERE, ORDER BY and LIMIT are applied, then the SELECT
+// fields are evaluated. A query without SELECT, or SELECT *, returns the
+// whole objects.
+//
+// Queries selecting only aggregate functions, e.g. SELECT count(*), return
+// a single row computed over all matching objects.
+func Execute(q *Query, objects []map[string]any) (*Result, error) {
+	return ExecuteJoin(q, [][]map[string]any{objects})
+}
+
+// ExecuteJoin runs a query over the objects of each of its resources: the
+// FROM resource first, then the joined resources in order, then the
+// resources of each subquery of the WHERE clause, see allTables. The rows of
+// joins map the name of each table, its alias or resource name, to one of
+// its objects, and are returned by SELECT *. Fields qualified by a table
+// name, e.g. p.spec.nodeName, are read from the object of that table, and
+// other fields from the object of the FROM resource. A LEFT join keeps the
+// rows matching no object, whose fields of the joined table are NULL.
+//
+// Subqueries also see the tables of the queries using them, unless one of
+// their own tables has the same name. Subqueries using such tables run for
+// every row, the others run once.
+//
+// Common table expressions of the WITH clause run first, in order, over the
+// objects of their own resources, which come before the ones of the query.
+// Their result rows are read like objects by the tables naming them.
+//
+// Queries combined by UNION, INTERSECT and EXCEPT run in turn, over the
+// objects of their resources in order, and their rows are combined; ORDER
+// BY keys name the columns of the combined rows.
+func ExecuteJoin(q *Query, tables [][]map[string]any) (*Result, error) {
+	e := newEvaluator()
+	e.ctes = make(map[string][]map[string]any, len(q.With))
+	with, err := withExecutions(q, e)
+	if err != nil {
+		return nil, err
+	}
+	queries, err := operands(q)
+	if err != nil {
+		return nil, err
+	}
+	xs := make([]*execution, len(queries))
+	for i, operand := range queries {
+		if xs[i], err = newExecution(operand, nil, e, withNames(q.With)); err != nil {
+			if len(queries) > 1 {
+				return nil, fmt.Errorf("error in query %d: %w", i+1, err)
+			}
+			return nil, err
+		}
+	}
+	all, count := append(with, xs...), 0
+	for _, x := range all {
+		count += x.tableCount()
+	}
+	if len(tables) != count {
+		return nil, fmt.Errorf("query has %d resources, got the objects of %d", count, len(tables))
+	}
+	for _, x := range all {
+		tables = x.setObjects(tables)
+	}
+
+	for i, w := range with {
+		result, err := w.run(nil)
+		if err != nil {
+			return nil, fmt.Errorf("error running common table expression '%s': %w", q.With[i].Name, err)
+		}
+		e.ctes[strings.ToLower(q.With[i].Name)] = cteObjects(w.q, w.selects, w.e.tables, result)
+	}
+	if len(q.Compound) > 0 {
+		return runCompound(q, xs)
+	}
+	return xs[0].run(nil)
+}
+
+// run runs a query, or a subquery for a row of the enclosing query mapping
+// the tables it sees to their objects.
+func (x *execution) run(outer map[string]any) (*Result, error) {
+	q, e := x.q, x.e
+	for i, t := range x.tables {
+		if t.cte != "" {
+			x.objects[i] = e.ctes[strings.ToLower(t.cte)]
+		}
+	}
+
+	// Join the objects of each resource
+	objects := x.objects[0]
+	if len(e.tables) > 1 {
+		rows := make([]map[string]any, len(objects))
+		for i, object := range objects {
+			rows[i] = make(map[string]any, len(outer)+1)
+			maps.Copy(rows[i], outer)
+			rows[i][x.tables[0].name] = object
+		}
+		for i, t := range x.tables[1:] {
+			var err error
+			if rows, err = e.joinRows(rows, t, x.objects[i+1]); err != nil {
+				return nil, err
+			}
+		}
+		objects = rows
+	}
+
+	// Filter the objects by WHERE condition
+	var matches []map[string]any
+	for _, object := range objects {
+		if x.where != nil {
+			value, err := e.eval(x.where, object)
+			if err != nil {
+				return nil, fmt.Errorf("error evaluating %s clause: %w", WhereKeyword, err)
+			}
+			if !truthy(value) {
+				continue
+			}
+		}
+		matches = append(matches, object)
+	}
+
+	result := &Result{Columns: resultColumns(q, x.selects)}
+	if isAggregateQuery(x.selects) {
+		row, err := e.aggregateRow(x.selects, matches)
+		if err != nil {
+			return nil, err
+		}
+		if q.Limit != 0 {
+			result.Rows = [][]any{row}
+		}
+		return result, nil
+	}
+
+	// Evaluate the SELECT fields of every object
+	rows := make([][]any, len(matches))
+	for i, object := range matches {
+		var err error
+		if rows[i], err = e.row(x.selects, object); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.sortRows(q, result.Columns, matches, rows); err != nil {
+		return nil, err
+	}
+
+	if q.Limit >= 0 && q.Limit < len(rows) {
+		rows = rows[:q.Limit]
+	}
+	result.Rows = rows
+	return result, nil
+}
+
+// inNamespace returns the objects in a namespace, all objects when namespace
+// is empty.
+func inNamespace(objects []map[string]any, namespace string) []map[string]any {
+	if namespace == "" {
+		return objects
+	}
+	var result []map[string]any
+	for _, object := range objects {
+		if objectNamespace(object) == namespace {
+			result = append(result, object)
+		}
+	}
+	return result
+}
+
+// parseSelect parses the SELECT fields of a query. A query without SELECT
+// selects the whole objects.
+func parseSelect(q *Query) ([]Expr, error) {
+	if len(q.Select) == 0 {
+		return []Expr{&StarExpr{}}, nil
+	}
+
+	selects := make([]Expr, len(q.Select))
+	for i, field := range q.Select {
+		expr, err := ParseExpr(field.Field)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s clause: %w", SelectKeyword, err)
+		}
+		selects[i] = expr
+	}
+	return selects, nil
+}
+
+// resultColumns returns the columns of a query result, using the types
+// recorded by the validator when available.
+func resultColumns(q *Query, selects []Expr) []Column {
+	if len(q.Columns) == len(selects) {
+		return q.Columns
+	}
+
+	columns := make([]Column, len(selects))
+	for i, expr := range selects {
+		columns[i] = Column{Name: expr.String(), Type: TypeUnknown}
+		if i < len(q.Select) && q.Select[i].Alias != "" {
+			columns[i].Name = q.Select[i].Alias
+		}
+		if _, ok := expr.(*StarExpr); ok {
+			columns[i].Type = TypeMap
+		} else if t, err := InferType(expr, nil); err == nil {
+			columns[i].Type = t
+		}
+	}
+	return columns
+}
+
+// isAggregateQuery reports whether a SELECT list uses aggregate functions.
+func isAggregateQuery(selects []Expr) bool {
+	found := false
+	for _, expr := range selects {
+		Inspect(expr, func(node Node) bool {
+			call, ok := node.(*CallExpr)
+			found = found || (ok && isAggregate(call))
+			return !found
+		})
+	}
+	return found
+}
+
+// aggregateRow computes the single row of an aggregate query. There is no
+// GROUP BY, so fields may only be used inside aggregate functions.
+func (e *evaluator) aggregateRow(selects []Expr, objects []map[string]any) ([]any, error) {
+	e.aggregates = make(map[*CallExpr]any)
+	for _, expr := range selects {
+		var err error
+		Inspect(expr, func(node Node) bool {
+			if err != nil {
+				return false
+			}
+			switch node := node.(type) {
+			case *CallExpr:
+				if isAggregate(node) {
+					e.aggregates[node], err = e.aggregate(node, objects)
+					return false
+				}
+			case *FieldRef:
+				err = fmt.Errorf("field '%s' must be used in an aggregate function, there is no GROUP BY", node.Name())
+			case *StarExpr:
+				err = fmt.Errorf("can not select * with aggregate functions")
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e.row(selects, nil)
+}
+
+// row evaluates the SELECT fields over an object.
+func (e *evaluator) row(selects []Expr, object map[string]any) ([]any, error) {
+	row := make([]any, len(selects))
+	for i, expr := range selects {
+		value, err := e.eval(expr, object)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating %s clause: %w", SelectKeyword, err)
+		}
+		row[i] = unwrap(value)
+	}
+	return row, nil
+}
+
+// sortRows sorts the rows by the ORDER BY keys, evaluated over the objects
+// the rows were computed from. A key naming a SELECT alias sorts by that
+// column. NULL values sort first.
+func (e *evaluator) sortRows(q *Query, columns []Column, objects []map[string]any, rows [][]any) error {
+	if len(q.OrderBy) == 0 {
+		return nil
+	}
+	order, err := parseOrderBy(q, columns)
+	if err != nil {
+		return err
+	}
+	return e.sortByKeys(order, objects, rows)
+}
+
+// sortByKeys sorts the rows by ORDER BY keys, evaluated over the objects
+// the rows were computed from, nil when every key is a column.
+func (e *evaluator) sortByKeys(order []sortField, objects []map[string]any, rows [][]any) error {
+	if len(order) == 0 {
+		return nil
+	}
+
+	// Compute the sort keys of every row
+	keys := make([][]any, len(rows))
+	for i := range keys {
+		var object map[string]any
+		if objects != nil {
+			object = objects[i]
+		}
+		var err error
+		if keys[i], err = e.sortKey(order, object, rows[i]); err != nil {
+			return err
+		}
+	}
+
+	indexes := make([]int, len(rows))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return compareSortKeys(order, keys[indexes[a]], keys[indexes[b]]) < 0
+	})
+
+	sorted := make([][]any, len(rows))
+	for i, n := range indexes {
+		sorted[i] = rows[n]
+	}
+	copy(rows, sorted)
+	return nil
+}
+
+// sortField is a parsed ORDER BY field.
+type sortField struct {
+	expr   Expr
+	column int // Index of the SELECT column the field is an alias of, -1 for none
+	desc   bool
+}
+
+// parseOrderBy parses the ORDER BY fields of a query.
+func parseOrderBy(q *Query, columns []Column) ([]sortField, error) {
+	order := make([]sortField, len(q.OrderBy))
+	for k, field := range q.OrderBy {
+		expr, err := ParseExpr(field.Field)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s clause: %w", OrderByKeyword, err)
+		}
+
+		column := -1
+		if ref, ok := expr.(*FieldRef); ok && len(ref.Path) == 1 {
+			for i, field := range q.Select {
+				if field.Alias != "" && field.Alias == ref.Path[0].Name && i < len(columns) {
+					column = i
+				}
+			}
+		}
+		order[k] = sortField{expr: expr, column: column, desc: field.Direction == "DESC"}
+	}
+	return order, nil
+}
+
+// sortKey returns the ORDER BY values of a row and the object it was
+// computed from.
+func (e *evaluator) sortKey(order []sortField, object map[string]any, row []any) ([]any, error) {
+	key := make([]any, len(order))
+	for k, field := range order {
+		if field.column >= 0 {
+			key[k] = row[field.column]
+			continue
+		}
+		value, err := e.eval(field.expr, object)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating %s clause: %w", OrderByKeyword, err)
+		}
+		key[k] = unwrap(value)
+	}
+	return key, nil
+}
+
+// compareSortKeys compares the ORDER BY values of two rows, in the
+// direction of each field.
+func compareSortKeys(order []sortField, a, b []any) int {
+	for k, field := range order {
+		c := compareKeys(a[k], b[k])
+		if c == 0 {
+			continue
+		}
+		if field.desc {
+			return -c
+		}
+		return c
+	}
+	return 0
+}
+
+// compareKeys compares two sort keys. NULL sorts before any value, and
+// values that can not be compared are ordered by their text.
+func compareKeys(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if c, ok := compareValues(a, b); ok {
+		return c
+	}
+	x, y := formatValue(a), formatValue(b)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,471 @@
+package kubesql
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testSnapshot loads the objects of testdata/snapshot.
+func testSnapshot(t *testing.T) *Dataset {
+	t.Helper()
+
+	dataset, err := LoadDataset("testdata/snapshot")
+	if err != nil {
+		t.Fatalf("error loading dataset: %v", err)
+	}
+	return dataset
+}
+
+func TestExecute(t *testing.T) {
+	dataset := testSnapshot(t)
+
+	testCases := []struct {
+		input    string
+		columns  []string
+		expected [][]any
+	}{
+		{
+			input:    "SELECT name FROM pods ORDER BY name",
+			columns:  []string{"name"},
+			expected: [][]any{{"coredns"}, {"web-1"}, {"web-2"}},
+		},
+		{
+			input:    "SELECT name, status.phase AS phase FROM default/pods ORDER BY phase DESC, name",
+			columns:  []string{"name", "phase"},
+			expected: [][]any{{"web-1", "Running"}, {"web-2", "Pending"}},
+		},
+		{
+			input:    "SELECT name FROM pods WHERE spec.containers[*].resources.limits.memory > 512Mi",
+			columns:  []string{"name"},
+			expected: [][]any{{"web-1"}},
+		},
+		{
+			input:    "SELECT name, spec.priority FROM pods ORDER BY spec.priority DESC, name LIMIT 2",
+			columns:  []string{"name", "spec.priority"},
+			expected: [][]any{{"coredns", float64(2000000000)}, {"web-1", nil}},
+		},
+		{
+			input:    "SELECT name FROM pods WHERE created < '2024-01-02' ORDER BY created",
+			columns:  []string{"name"},
+			expected: [][]any{{"coredns"}, {"web-1"}},
+		},
+		{
+			input:    "SELECT spec.containers[*].name AS containers FROM po WHERE name = 'web-1'",
+			columns:  []string{"containers"},
+			expected: [][]any{{[]any{"nginx", "sidecar"}}},
+		},
+		{
+			input:    "SELECT count(*) AS pods, count(spec.priority), max(created) FROM pods WHERE labels.app = 'web'",
+			columns:  []string{"pods", "count(spec.priority)", "max(created)"},
+			expected: [][]any{{float64(2), float64(0), "2024-01-03T10:00:00Z"}},
+		},
+		{
+			input:    "SELECT sum(len(spec.containers)) AS containers FROM pods",
+			columns:  []string{"containers"},
+			expected: [][]any{{float64(4)}},
+		},
+		{
+			input:    "SELECT name, spec.replicas FROM deployments.apps",
+			columns:  []string{"name", "spec.replicas"},
+			expected: [][]any{{"web", float64(2)}},
+		},
+		{
+			input:    "SELECT name FROM pods LIMIT 0",
+			columns:  []string{"name"},
+			expected: [][]any{},
+		},
+		{
+			input:    "SELECT name FROM services",
+			columns:  []string{"name"},
+			expected: [][]any{},
+		},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', unexpected parse error: %v", tc.input, err)
+		}
+		result, err := dataset.Execute(query, DefaultResolver())
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+
+		var columns []string
+		for _, column := range result.Columns {
+			columns = append(columns, column.Name)
+		}
+		if !reflect.DeepEqual(columns, tc.columns) {
+			t.Errorf("For input '%s', expected columns %v, got %v", tc.input, tc.columns, columns)
+		}
+		rows := result.Rows
+		if rows == nil {
+			rows = [][]any{}
+		}
+		if !reflect.DeepEqual(rows, tc.expected) {
+			t.Errorf("For input '%s', expected rows %v, got %v", tc.input, tc.expected, rows)
+		}
+	}
+}
+
+func TestExecuteJoin(t *testing.T) {
+	dataset, err := LoadDataset("testdata/cluster")
+	if err != nil {
+		t.Fatalf("error loading dataset: %v", err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected [][]any
+	}{
+		{
+			input:    "SELECT p.name, n.name FROM pods p JOIN nodes n ON p.spec.nodeName = n.name ORDER BY p.name",
+			expected: [][]any{{"web-1", "node-a"}, {"web-2", "node-b"}},
+		},
+		{
+			input:    "SELECT p.name, n.labels.role FROM pods AS p LEFT JOIN nodes AS n ON n.name = p.spec.nodeName ORDER BY p.name",
+			expected: [][]any{{"db-1", nil}, {"queued", nil}, {"web-1", "worker"}, {"web-2", "control-plane"}},
+		},
+		{
+			input:    "SELECT name FROM pods p LEFT OUTER JOIN nodes n ON p.spec.nodeName = n.name WHERE n.name IS NULL AND spec.nodeName IS NOT NULL",
+			expected: [][]any{{"db-1"}},
+		},
+		{
+			input:    "SELECT pods.name FROM pods INNER JOIN nodes ON nodes.labels.role = 'worker' WHERE pods.labels.app = 'web' ORDER BY pods.name",
+			expected: [][]any{{"queued"}, {"web-1"}, {"web-2"}},
+		},
+		{
+			// Quantities equal numbers, hash keys match them
+			input:    "SELECT p.name, n.name FROM pods p JOIN nodes n ON p.spec.containers[*].resources.limits.memory = n.status.allocatable.memory ORDER BY n.name",
+			expected: [][]any{{"web-2", "node-a"}, {"web-2", "node-b"}},
+		},
+		{
+			input:    "SELECT s.name, p.name, n.labels.role FROM services s JOIN pods p ON p.labels.app = s.spec.selector.app AND p.namespace = s.namespace LEFT JOIN nodes n ON n.name = p.spec.nodeName ORDER BY p.name",
+			expected: [][]any{{"web", "web-1", "worker"}, {"web", "web-2", "control-plane"}},
+		},
+		{
+			input:    "SELECT s.name FROM default/services s LEFT JOIN default/pods p ON p.labels.app = s.spec.selector.app WHERE p.name IS NULL",
+			expected: [][]any{{"cache"}},
+		},
+		{
+			input:    "SELECT count(*) AS pods, count(n.name) AS scheduled FROM pods p LEFT JOIN nodes n ON p.spec.nodeName = n.name",
+			expected: [][]any{{float64(4), float64(2)}},
+		},
+		{
+			input:    "SELECT p.name FROM batch/pods p WHERE p.labels.app = 'web'",
+			expected: [][]any{{"queued"}},
+		},
+		{
+			// Owner chains join one owner at a time
+			input:    "SELECT p.name, rs.name, d.name FROM pods p JOIN replicasets rs ON owns(rs, p) JOIN deployments d ON owns(d, rs) ORDER BY p.name",
+			expected: [][]any{{"web-1", "web-7d4", "web"}, {"web-2", "web-7d4", "web"}},
+		},
+		{
+			input:    "SELECT rs.name FROM deployments d JOIN replicasets rs ON owns(d, rs) LEFT JOIN pods p ON owns(rs, p) WHERE p.name IS NULL",
+			expected: [][]any{{"web-5c9"}},
+		},
+		{
+			input:    "SELECT s.name, p.name FROM services s LEFT JOIN pods p ON selects(s, p) ORDER BY s.name, p.name",
+			expected: [][]any{{"cache", nil}, {"web", "web-1"}, {"web", "web-2"}},
+		},
+		{
+			// Empty label selectors select every pod of their namespace
+			input:    "SELECT np.name, p.name FROM networkpolicies np JOIN pods p ON selects(np, p) ORDER BY p.name",
+			expected: [][]any{{"deny-all", "queued"}, {"allow-web", "web-1"}, {"allow-web", "web-2"}},
+		},
+		{
+			input:    "SELECT pdb.name, p.name FROM poddisruptionbudgets pdb JOIN pods p ON selects(pdb, p)",
+			expected: [][]any{{"db", "db-1"}},
+		},
+		{
+			input:    "SELECT p.name, c.name FROM pods p JOIN configmaps c ON mounts(p, c) ORDER BY p.name",
+			expected: [][]any{{"web-1", "web-config"}, {"web-2", "web-config"}},
+		},
+		{
+			input:    "SELECT p.name FROM pods p JOIN secrets s ON mounts(p, s) JOIN persistentvolumeclaims pvc ON pvc.namespace = p.namespace",
+			expected: [][]any{{"web-1"}},
+		},
+		{
+			input:    "SELECT c.name FROM configmaps c LEFT JOIN deployments d ON mounts(d, c) WHERE d.name IS NULL",
+			expected: [][]any{{"unused-config"}},
+		},
+		{
+			input:    "SELECT p.name FROM pods p LEFT JOIN persistentvolumeclaims pvc ON mounts(p, pvc) WHERE pvc.name IS NOT NULL",
+			expected: [][]any{{"db-1"}},
+		},
+		{
+			input:    "SELECT name FROM pods WHERE spec.nodeName IN (SELECT metadata.name FROM nodes WHERE spec.unschedulable = true)",
+			expected: [][]any{{"web-2"}},
+		},
+		{
+			input:    "SELECT name FROM pods WHERE spec.nodeName NOT IN (SELECT name FROM nodes) ORDER BY name",
+			expected: [][]any{{"db-1"}, {"queued"}},
+		},
+		{
+			// Correlated subqueries see the tables of the enclosing query
+			input:    "SELECT name FROM pods p WHERE EXISTS (SELECT name FROM nodes WHERE name = p.spec.nodeName) ORDER BY name",
+			expected: [][]any{{"web-1"}, {"web-2"}},
+		},
+		{
+			input:    "SELECT name FROM pods p WHERE NOT EXISTS (SELECT name FROM nodes n WHERE n.name = p.spec.nodeName) ORDER BY name",
+			expected: [][]any{{"db-1"}, {"queued"}},
+		},
+		{
+			// Unqualified fields belong to the innermost FROM
+			input:    "SELECT name FROM nodes WHERE name IN (SELECT spec.nodeName FROM pods WHERE labels.app IN (SELECT spec.selector.app FROM services)) ORDER BY name",
+			expected: [][]any{{"node-a"}, {"node-b"}},
+		},
+		{
+			input:    "SELECT p.name FROM pods p WHERE EXISTS (SELECT name FROM services s WHERE s.spec.selector.app = p.labels.app AND EXISTS (SELECT name FROM nodes n WHERE n.name = p.spec.nodeName AND n.labels.role = 'worker'))",
+			expected: [][]any{{"web-1"}},
+		},
+		{
+			input:    "SELECT p.name, n.name FROM pods p JOIN nodes n ON p.spec.nodeName = n.name WHERE n.name IN (SELECT name FROM nodes WHERE labels.role = 'worker')",
+			expected: [][]any{{"web-1", "node-a"}},
+		},
+		{
+			// Common table expressions read the ones before them
+			input:    "WITH unscheduled AS (SELECT name, namespace FROM pods WHERE spec.nodeName NOT IN (SELECT name FROM nodes)), batch AS (FROM unscheduled WHERE namespace = 'batch') SELECT name FROM batch",
+			expected: [][]any{{"queued"}},
+		},
+		{
+			input:    "WITH web AS (SELECT name, spec.nodeName AS node FROM pods WHERE labels.app = 'web') SELECT w.name, n.labels.role FROM web w JOIN nodes n ON n.name = w.node ORDER BY w.name",
+			expected: [][]any{{"web-1", "worker"}, {"web-2", "control-plane"}},
+		},
+		{
+			input:    "WITH running AS (SELECT count(*) AS total FROM pods WHERE status.phase = 'Running') SELECT total * 10 FROM running",
+			expected: [][]any{{float64(30)}},
+		},
+		{
+			// Fields selected from a join are read without their table name
+			input:    "WITH placed AS (SELECT p.name, n.labels.role FROM pods p JOIN nodes n ON n.name = p.spec.nodeName) SELECT name, labels.role FROM placed ORDER BY name",
+			expected: [][]any{{"web-1", "worker"}, {"web-2", "control-plane"}},
+		},
+		{
+			// A common table expression may take the name of a resource
+			input:    "WITH pods AS (SELECT * FROM pods WHERE namespace = 'batch') SELECT name, status.phase FROM pods",
+			expected: [][]any{{"queued", "Pending"}},
+		},
+		{
+			// Subqueries see the common table expressions too
+			input:    "WITH used AS (SELECT spec.nodeName FROM pods p WHERE EXISTS (SELECT name FROM nodes WHERE name = p.spec.nodeName)) SELECT name FROM nodes WHERE name IN (SELECT spec.nodeName FROM used) ORDER BY name",
+			expected: [][]any{{"node-a"}, {"node-b"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', unexpected parse error: %v", tc.input, err)
+		}
+		result, err := dataset.Execute(query, DefaultResolver())
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Rows, tc.expected) {
+			t.Errorf("For input '%s', expected rows %v, got %v", tc.input, tc.expected, result.Rows)
+		}
+	}
+}
+
+func TestExecuteCompound(t *testing.T) {
+	dataset, err := LoadDataset("testdata/cluster")
+	if err != nil {
+		t.Fatalf("error loading dataset: %v", err)
+	}
+
+	testCases := []struct {
+		input    string
+		columns  []string
+		expected [][]any
+	}{
+		{
+			input:    "SELECT labels.app AS app FROM pods UNION SELECT name FROM deployments ORDER BY app",
+			columns:  []string{"app"},
+			expected: [][]any{{"db"}, {"web"}},
+		},
+		{
+			input:    "SELECT labels.app AS app FROM pods UNION ALL SELECT name FROM deployments ORDER BY app DESC LIMIT 4",
+			columns:  []string{"app"},
+			expected: [][]any{{"web"}, {"web"}, {"web"}, {"web"}},
+		},
+		{
+			input:    "SELECT labels.app FROM pods EXCEPT SELECT labels.app FROM pods WHERE name = 'web-1'",
+			columns:  []string{"labels.app"},
+			expected: [][]any{{"db"}},
+		},
+		{
+			// EXCEPT ALL removes a row per matching row
+			input:    "SELECT labels.app FROM pods EXCEPT ALL SELECT labels.app FROM pods WHERE name = 'web-1' ORDER BY labels.app",
+			columns:  []string{"labels.app"},
+			expected: [][]any{{"db"}, {"web"}, {"web"}},
+		},
+		{
+			input:    "SELECT labels.app FROM pods INTERSECT ALL SELECT labels.app FROM pods WHERE namespace = 'default' ORDER BY labels.app",
+			columns:  []string{"labels.app"},
+			expected: [][]any{{"db"}, {"web"}, {"web"}},
+		},
+		{
+			// INTERSECT binds tighter than UNION
+			input:    "SELECT name FROM pods UNION SELECT name FROM nodes INTERSECT SELECT name FROM nodes WHERE name = 'node-a' ORDER BY name",
+			columns:  []string{"name"},
+			expected: [][]any{{"db-1"}, {"node-a"}, {"queued"}, {"web-1"}, {"web-2"}},
+		},
+		{
+			// Deployments without a PodDisruptionBudget of the same name
+			input:    "SELECT name, namespace, kind FROM deployments EXCEPT SELECT name, namespace, 'Deployment' FROM poddisruptionbudgets",
+			columns:  []string{"name", "namespace", "kind"},
+			expected: [][]any{{"web", "default", "Deployment"}},
+		},
+		{
+			input:    "SELECT count(*) AS total FROM pods UNION ALL SELECT count(*) FROM nodes ORDER BY total",
+			columns:  []string{"total"},
+			expected: [][]any{{float64(2)}, {float64(4)}},
+		},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', unexpected parse error: %v", tc.input, err)
+		}
+		result, err := dataset.Execute(query, DefaultResolver())
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+
+		var columns []string
+		for _, column := range result.Columns {
+			columns = append(columns, column.Name)
+		}
+		if !reflect.DeepEqual(columns, tc.columns) {
+			t.Errorf("For input '%s', expected columns %v, got %v", tc.input, tc.columns, columns)
+		}
+		if !reflect.DeepEqual(result.Rows, tc.expected) {
+			t.Errorf("For input '%s', expected rows %v, got %v", tc.input, tc.expected, result.Rows)
+		}
+	}
+}
+
+func TestExecuteJoinRows(t *testing.T) {
+	query, err := NewParser("SELECT * FROM pods p LEFT JOIN nodes n ON p.spec.nodeName = n.name").Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	pod := map[string]any{"metadata": map[string]any{"name": "a"}, "spec": map[string]any{"nodeName": "x"}}
+	node := map[string]any{"metadata": map[string]any{"name": "x"}}
+
+	result, err := ExecuteJoin(query, [][]map[string]any{{pod}, {node}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := [][]any{{map[string]any{"p": pod, "n": node}}}
+	if !reflect.DeepEqual(result.Rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, result.Rows)
+	}
+
+	// Rows matching no object have no joined object
+	result, err = ExecuteJoin(query, [][]map[string]any{{pod}, {}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = [][]any{{map[string]any{"p": pod}}}
+	if !reflect.DeepEqual(result.Rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, result.Rows)
+	}
+
+	if _, err := ExecuteJoin(query, [][]map[string]any{{pod}}); err == nil || err.Error() != "query has 2 resources, got the objects of 1" {
+		t.Errorf("expected an error for missing objects, got %v", err)
+	}
+
+	// Subqueries take the objects of their tables after the query
+	query, err = NewParser("SELECT name FROM pods WHERE spec.nodeName IN (SELECT name FROM nodes)").Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	result, err = ExecuteJoin(query, [][]map[string]any{{pod}, {node}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected = [][]any{{"a"}}; !reflect.DeepEqual(result.Rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, result.Rows)
+	}
+
+	// Common table expressions take the objects of their tables first, and
+	// their selected fields are read at their path
+	query, err = NewParser("WITH n AS (SELECT name, len(name) AS size FROM nodes) SELECT p.name, n.size FROM pods p JOIN n ON n.name = p.spec.nodeName").Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	result, err = ExecuteJoin(query, [][]map[string]any{{node}, {pod}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected = [][]any{{"a", float64(1)}}; !reflect.DeepEqual(result.Rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, result.Rows)
+	}
+	if _, err := ExecuteJoin(query, [][]map[string]any{{node}, {pod}, {node}}); err == nil || err.Error() != "query has 2 resources, got the objects of 3" {
+		t.Errorf("expected an error for extra objects, got %v", err)
+	}
+}
+
+func TestExecuteWholeObjects(t *testing.T) {
+	query, err := NewParser("FROM kube-system/pods").Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	result, err := Execute(query, testSnapshot(t).Objects(ResourceInfo{Kind: "Pod"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(result.Columns) != 1 || result.Columns[0].Name != "*" || result.Columns[0].Type != TypeMap {
+		t.Errorf("expected a single * map column, got %v", result.Columns)
+	}
+	if len(result.Rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(result.Rows))
+	}
+	object := result.Rows[0][0].(map[string]any)
+	if name := fieldValue(object, []Segment{{Name: "metadata"}, {Name: "name"}}); name != "coredns" {
+		t.Errorf("expected object coredns, got %v", name)
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"SELECT name, count(*) FROM pods", "field 'name' must be used in an aggregate function, there is no GROUP BY"},
+		{"SELECT name FROM pods WHERE sum(spec.priority) > 1", "error evaluating WHERE clause: aggregate function sum can only be used in SELECT"},
+		{"SELECT name FROM widgets", "unknown resource 'widgets'"},
+		{"SELECT name FROM pods p JOIN nodes n ON s.name = n.name JOIN services s ON s.name = p.name", "ON condition of 'n' uses 's', which is joined after it"},
+		{"SELECT name FROM pods p JOIN widgets w ON w.name = p.name", "unknown resource 'widgets'"},
+		{"SELECT name FROM pods WHERE name IN (SELECT name, namespace FROM nodes)", "the subquery of IN must select a single field"},
+		{"SELECT name FROM pods WHERE name IN (FROM nodes)", "the subquery of IN must select a single field"},
+		{"SELECT EXISTS (SELECT name FROM nodes) FROM pods", "subqueries can only be used in the WHERE clause"},
+		{"SELECT name FROM pods WHERE EXISTS (SELECT name FROM widgets)", "unknown resource 'widgets'"},
+		{"WITH w AS (FROM widgets) FROM w", "unknown resource 'widgets'"},
+		{"WITH a AS (FROM b), b AS (FROM pods) FROM a", "unknown resource 'b'"},
+		{"WITH a AS (SELECT name, count(*) FROM pods) FROM a", "error running common table expression 'a': field 'name' must be used in an aggregate function, there is no GROUP BY"},
+		{"WITH a AS (SELECT name FROM pods WHERE EXISTS (SELECT name FROM nodes)) FROM a WHERE name IN (SELECT name, namespace FROM a)", "the subquery of IN must select a single field"},
+		{"SELECT name FROM pods UNION SELECT name, namespace FROM deployments", "queries combined by UNION must select the same number of columns, the first query selects 1 and query 2 selects 2"},
+		{"SELECT name FROM pods EXCEPT SELECT name FROM deployments ORDER BY namespace", "ORDER BY key 'namespace' must be a column of the combined rows"},
+		{"SELECT name FROM pods UNION SELECT name FROM widgets", "unknown resource 'widgets'"},
+	}
+
+	dataset := testSnapshot(t)
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', unexpected parse error: %v", tc.input, err)
+		}
+		_, err = dataset.Execute(query, DefaultResolver())
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("For input '%s', expected error '%s', got %v", tc.input, tc.expected, err)
+		}
+	}
+}
@@ -0,0 +1,729 @@
+package kubesql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a node of a parsed TSL expression, as found in the SELECT, WHERE
+// and ORDER BY clauses. String returns the expression in canonical form.
+type Expr interface {
+	String() string
+	exprNode()
+}
+
+// LiteralKind identifies the kind of a literal value.
+type LiteralKind int
+
+const (
+	StringLiteral LiteralKind = iota // 'text'
+	NumberLiteral                    // 10, 1.5, 512Mi, 5m
+	BoolLiteral                      // TRUE, FALSE
+	NullLiteral                      // NULL
+)
+
+// Segment is one step of a field path: an object field or an array index.
+type Segment struct {
+	Name  string // Field name, for object fields
+	Index int    // Array index, or -1 for the [*] wildcard
+	Array bool   // Set for array index segments
+}
+
+// FieldRef is a reference to a field of the queried object,
+// e.g. metadata.labels.`app.kubernetes.io/name` or spec.containers[0].image.
+type FieldRef struct {
+	Path []Segment
+}
+
+// Literal is a constant value.
+type Literal struct {
+	Kind  LiteralKind
+	Value string // Unquoted value; numbers keep their unit suffix, booleans are TRUE or FALSE
+}
+
+// StarExpr is the * in SELECT * or COUNT(*).
+type StarExpr struct{}
+
+// ParenExpr is a parenthesized expression.
+type ParenExpr struct {
+	X Expr
+}
+
+// UnaryExpr is a NOT or unary minus expression.
+type UnaryExpr struct {
+	Op string // NOT or -
+	X  Expr
+}
+
+// BinaryExpr is a logical, comparison or arithmetic expression.
+type BinaryExpr struct {
+	Op string // AND, OR, LIKE, ILIKE, =, !=, <, <=, >, >=, ~=, !~, +, -, *, /, %
+	X  Expr
+	Y  Expr
+}
+
+// InExpr is an X [NOT] IN (a, b, ...) expression, or X [NOT] IN (SELECT
+// ...) matching the values of the single field a subquery selects.
+type InExpr struct {
+	X        Expr
+	List     []Expr
+	Subquery *Statement // Set instead of List for subqueries
+	Not      bool
+}
+
+// ExistsExpr is an EXISTS (SELECT ...) expression, true when the subquery
+// returns a row. NOT EXISTS is a NOT expression.
+type ExistsExpr struct {
+	Subquery *Statement
+}
+
+// BetweenExpr is an X [NOT] BETWEEN Low AND High expression.
+type BetweenExpr struct {
+	X    Expr
+	Low  Expr
+	High Expr
+	Not  bool
+}
+
+// IsNullExpr is an X IS [NOT] NULL expression.
+type IsNullExpr struct {
+	X   Expr
+	Not bool
+}
+
+// CallExpr is a function call, e.g. COUNT(*) or len(spec.containers).
+type CallExpr struct {
+	Func string
+	Args []Expr
+}
+
+func (*FieldRef) exprNode()    {}
+func (*Literal) exprNode()     {}
+func (*StarExpr) exprNode()    {}
+func (*ParenExpr) exprNode()   {}
+func (*UnaryExpr) exprNode()   {}
+func (*BinaryExpr) exprNode()  {}
+func (*InExpr) exprNode()      {}
+func (*ExistsExpr) exprNode()  {}
+func (*BetweenExpr) exprNode() {}
+func (*IsNullExpr) exprNode()  {}
+func (*CallExpr) exprNode()    {}
+
+// String returns the field path, quoting names that are not bare identifiers.
+func (f *FieldRef) String() string {
+	var result strings.Builder
+	for i, segment := range f.Path {
+		switch {
+		case segment.Array && segment.Index < 0:
+			result.WriteString("[*]")
+		case segment.Array:
+			fmt.Fprintf(&result, "[%d]", segment.Index)
+		default:
+			if i > 0 {
+				result.WriteByte('.')
+			}
+			result.WriteString(quoteIdentifier(segment.Name))
+		}
+	}
+	return result.String()
+}
+
+// Name returns the field path in dotted form without quoting, e.g.
+// "metadata.labels.app.kubernetes.io/name" or "spec.containers[0].image".
+func (f *FieldRef) Name() string {
+	var result strings.Builder
+	for i, segment := range f.Path {
+		switch {
+		case segment.Array && segment.Index < 0:
+			result.WriteString("[*]")
+		case segment.Array:
+			fmt.Fprintf(&result, "[%d]", segment.Index)
+		default:
+			if i > 0 {
+				result.WriteByte('.')
+			}
+			result.WriteString(segment.Name)
+		}
+	}
+	return result.String()
+}
+
+// String returns the literal as it is written in a query.
+func (l *Literal) String() string {
+	switch l.Kind {
+	case StringLiteral:
+		return quoteValue(l.Value, '\'')
+	default:
+		return l.Value
+	}
+}
+
+func (*StarExpr) String() string { return "*" }
+
+func (p *ParenExpr) String() string { return "(" + p.X.String() + ")" }
+
+func (u *UnaryExpr) String() string {
+	if u.Op == "-" {
+		// Keep nested negations apart, "--" starts a comment
+		x := u.X.String()
+		if strings.HasPrefix(x, "-") {
+			return "- " + x
+		}
+		return "-" + x
+	}
+	return u.Op + " " + u.X.String()
+}
+
+func (b *BinaryExpr) String() string {
+	return b.X.String() + " " + b.Op + " " + b.Y.String()
+}
+
+func (in *InExpr) String() string {
+	op := " IN "
+	if in.Not {
+		op = " NOT IN "
+	}
+	if in.Subquery != nil {
+		return in.X.String() + op + "(" + in.Subquery.String() + ")"
+	}
+	return in.X.String() + op + "(" + joinExprs(in.List) + ")"
+}
+
+func (e *ExistsExpr) String() string {
+	return "EXISTS (" + e.Subquery.String() + ")"
+}
+
+func (b *BetweenExpr) String() string {
+	op := " BETWEEN "
+	if b.Not {
+		op = " NOT BETWEEN "
+	}
+	return b.X.String() + op + b.Low.String() + " AND " + b.High.String()
+}
+
+func (n *IsNullExpr) String() string {
+	if n.Not {
+		return n.X.String() + " IS NOT NULL"
+	}
+	return n.X.String() + " IS NULL"
+}
+
+func (c *CallExpr) String() string {
+	return c.Func + "(" + joinExprs(c.Args) + ")"
+}
+
+// joinExprs renders a comma separated list of expressions.
+func joinExprs(exprs []Expr) string {
+	parts := make([]string, len(exprs))
+	for i, expr := range exprs {
+		parts[i] = expr.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseExpr parses a TSL expression, such as the content of a WHERE clause
+// or a SELECT field. Errors are *ParseError values positioned in expr.
+func ParseExpr(expr TSLQuery) (Expr, error) {
+	tokens, err := tokenize(string(expr))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{input: string(expr), tokens: tokens}
+	if len(tokens) == 0 {
+		return nil, p.errorf("expected an expression")
+	}
+
+	result, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, p.unexpected()
+	}
+	return result, nil
+}
+
+// exprParser is a recursive descent parser over the tokens of an expression.
+type exprParser struct {
+	input  string
+	tokens []token
+	pos    int
+}
+
+// comparisonOperators lists the binary comparison operators.
+var comparisonOperators = map[string]string{
+	"=": "=", "==": "=", "!=": "!=", "<>": "!=", "<": "<", "<=": "<=", ">": ">", ">=": ">=", "~=": "~=", "!~": "!~",
+}
+
+func (p *exprParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *exprParser) peek() token {
+	if p.done() {
+		return token{pos: len(p.input)}
+	}
+	return p.tokens[p.pos]
+}
+
+// peekKeyword reports whether the next token is the given keyword.
+func (p *exprParser) peekKeyword(keyword string) bool {
+	return !p.done() && p.tokens[p.pos].isKeyword(keyword)
+}
+
+// peekKind reports whether the next token is of the given kind and, for
+// operators, has the given text.
+func (p *exprParser) peekKind(kind tokenKind, text string) bool {
+	return !p.done() && p.tokens[p.pos].kind == kind && (text == "" || p.tokens[p.pos].text == text)
+}
+
+func (p *exprParser) next() token {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+	if p.done() {
+		message += " at end of expression"
+	}
+	return &ParseError{Pos: positionAt(p.input, p.peek().pos), Message: message}
+}
+
+// exprKeywords are the keywords suggested for misspelled words in expressions.
+var exprKeywords = []string{"AND", "OR", "NOT", "IN", "EXISTS", "LIKE", "ILIKE", "BETWEEN", "IS", "NULL", "TRUE", "FALSE", "ORDER", LimitKeyword}
+
+// unexpected reports the next token as unexpected, suggesting the keyword a
+// misspelled word most likely stands for, e.g. AND for ADN.
+func (p *exprParser) unexpected() error {
+	tok := p.peek()
+	parseErr := &ParseError{Pos: positionAt(p.input, tok.pos), Message: fmt.Sprintf("unexpected '%s'", tok.text)}
+	if tok.kind == tokenIdent {
+		if suggestion, ok := closestMatch(strings.ToUpper(tok.text), exprKeywords); ok {
+			if suggestion == "ORDER" {
+				suggestion = OrderByKeyword
+			}
+			parseErr.Message += fmt.Sprintf(", did you mean '%s'?", suggestion)
+			parseErr.Suggestion = suggestion
+		}
+	}
+	return parseErr
+}
+
+// expect consumes a token of the given kind or returns an error.
+func (p *exprParser) expect(kind tokenKind, what string) (token, error) {
+	if !p.peekKind(kind, "") {
+		return token{}, p.errorf("expected %s", what)
+	}
+	return p.next(), nil
+}
+
+func (p *exprParser) parseOr() (Expr, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("OR") {
+		p.next()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Op: "OR", X: x, Y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseAnd() (Expr, error) {
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("AND") {
+		p.next()
+		y, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Op: "AND", X: x, Y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseNot() (Expr, error) {
+	if p.peekKeyword("NOT") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "NOT", X: x}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *exprParser) parsePredicate() (Expr, error) {
+	x, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peekKind(tokenOperator, "") {
+		if op, ok := comparisonOperators[p.peek().text]; ok {
+			p.next()
+			y, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &BinaryExpr{Op: op, X: x, Y: y}, nil
+		}
+	}
+
+	if p.peekKeyword("IS") {
+		p.next()
+		not := false
+		if p.peekKeyword("NOT") {
+			p.next()
+			not = true
+		}
+		if !p.peekKeyword("NULL") {
+			return nil, p.errorf("expected NULL after IS")
+		}
+		p.next()
+		return &IsNullExpr{X: x, Not: not}, nil
+	}
+
+	not := false
+	if p.peekKeyword("NOT") {
+		p.next()
+		not = true
+	}
+
+	switch {
+	case p.peekKeyword("IN"):
+		p.next()
+		if p.peekSubquery() {
+			subquery, err := p.parseSubquery()
+			if err != nil {
+				return nil, err
+			}
+			return &InExpr{X: x, Subquery: subquery, Not: not}, nil
+		}
+		list, err := p.parseList()
+		if err != nil {
+			return nil, err
+		}
+		return &InExpr{X: x, List: list, Not: not}, nil
+	case p.peekKeyword("BETWEEN"):
+		p.next()
+		low, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peekKeyword("AND") {
+			return nil, p.errorf("expected AND in BETWEEN")
+		}
+		p.next()
+		high, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &BetweenExpr{X: x, Low: low, High: high, Not: not}, nil
+	case p.peekKeyword("LIKE"), p.peekKeyword("ILIKE"):
+		op := strings.ToUpper(p.next().text)
+		y, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		var result Expr = &BinaryExpr{Op: op, X: x, Y: y}
+		if not {
+			result = &UnaryExpr{Op: "NOT", X: result}
+		}
+		return result, nil
+	case not:
+		return nil, p.errorf("expected IN, BETWEEN, LIKE or ILIKE after NOT")
+	}
+
+	return x, nil
+}
+
+// parseList parses a parenthesized or bracketed list of expressions.
+func (p *exprParser) parseList() ([]Expr, error) {
+	closing := tokenRParen
+	switch {
+	case p.peekKind(tokenLParen, ""):
+	case p.peekKind(tokenLBracket, ""):
+		closing = tokenRBracket
+	default:
+		return nil, p.errorf("expected '(' to start a list")
+	}
+	p.next()
+
+	var list []Expr
+	for {
+		item, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+
+		if p.peekKind(tokenComma, "") {
+			p.next()
+			continue
+		}
+		if _, err := p.expect(closing, "',' or end of list"); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+}
+
+// peekSubquery reports whether the next tokens start a parenthesized
+// subquery, a SELECT or FROM statement. WITH starts one too, to report that
+// subqueries can not have common table expressions.
+func (p *exprParser) peekSubquery() bool {
+	if !p.peekKind(tokenLParen, "") || p.pos+1 == len(p.tokens) {
+		return false
+	}
+	tok := p.tokens[p.pos+1]
+	return tok.isKeyword(SelectKeyword) || tok.isKeyword(FromKeyword) || tok.isKeyword(WithKeyword)
+}
+
+// parseSubquery parses a parenthesized subquery with the statement parser,
+// nested subqueries being parsed in turn from its WHERE clause. Errors are
+// positioned in the expression.
+func (p *exprParser) parseSubquery() (*Statement, error) {
+	p.next() // (
+	start, depth := p.pos, 1
+	for ; p.pos < len(p.tokens); p.pos++ {
+		switch p.tokens[p.pos].kind {
+		case tokenLParen:
+			depth++
+		case tokenRParen:
+			depth--
+		}
+		if depth == 0 {
+			break
+		}
+	}
+	if p.done() {
+		return nil, p.errorf("expected ')' to end the subquery")
+	}
+
+	first, last := p.tokens[start], p.tokens[p.pos-1]
+	inner := &Parser{query: p.input[first.pos:last.end()], source: p.input, base: first.pos}
+	q, err := inner.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if q.Explain {
+		return nil, p.errorf("unexpected EXPLAIN in a subquery")
+	}
+	if len(q.With) > 0 {
+		return nil, p.errorf("unexpected WITH in a subquery, define common table expressions in the WITH clause of the statement")
+	}
+	if len(q.Compound) > 0 {
+		return nil, p.errorf("unexpected %s in a subquery", q.Compound[0].Op)
+	}
+	s, err := NewStatement(q)
+	if err != nil {
+		return nil, &ParseError{Pos: positionAt(p.input, first.pos), Message: fmt.Sprintf("error parsing subquery: %v", err)}
+	}
+	s.Pos, s.Comments = Position{}, nil
+	p.next() // )
+	return s, nil
+}
+
+func (p *exprParser) parseAdditive() (Expr, error) {
+	x, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKind(tokenOperator, "+") || p.peekKind(tokenOperator, "-") {
+		op := p.next().text
+		y, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Op: op, X: x, Y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseMultiplicative() (Expr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKind(tokenOperator, "*") || p.peekKind(tokenOperator, "/") || p.peekKind(tokenOperator, "%") {
+		op := p.next().text
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Op: op, X: x, Y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseUnary() (Expr, error) {
+	if p.peekKind(tokenOperator, "-") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpr{Op: "-", X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (Expr, error) {
+	if p.done() {
+		return nil, p.errorf("expected an expression")
+	}
+
+	tok := p.peek()
+	switch {
+	case tok.kind == tokenString:
+		p.next()
+		return &Literal{Kind: StringLiteral, Value: tok.value}, nil
+	case tok.kind == tokenNumber:
+		p.next()
+		return &Literal{Kind: NumberLiteral, Value: tok.text}, nil
+	case tok.isKeyword("TRUE"), tok.isKeyword("FALSE"):
+		p.next()
+		return &Literal{Kind: BoolLiteral, Value: strings.ToUpper(tok.text)}, nil
+	case tok.isKeyword("NULL"):
+		p.next()
+		return &Literal{Kind: NullLiteral, Value: "NULL"}, nil
+	case tok.isKeyword("EXISTS"):
+		p.next()
+		if !p.peekSubquery() {
+			return nil, p.errorf("expected '(SELECT' or '(FROM' after EXISTS")
+		}
+		subquery, err := p.parseSubquery()
+		if err != nil {
+			return nil, err
+		}
+		return &ExistsExpr{Subquery: subquery}, nil
+	case tok.kind == tokenOperator && tok.text == "*":
+		p.next()
+		return &StarExpr{}, nil
+	case tok.kind == tokenLParen:
+		p.next()
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return &ParenExpr{X: x}, nil
+	case isFunctionCall(p.tokens, p.pos):
+		return p.parseCall()
+	case tok.kind == tokenIdent && isReservedKeyword(tok.text):
+		return nil, p.errorf("unexpected keyword '%s', quote it to use it as a field name", tok.text)
+	case tok.kind == tokenIdent, tok.kind == tokenQuotedIdent:
+		return p.parseFieldRef()
+	}
+
+	return nil, p.unexpected()
+}
+
+// isFunctionCall reports whether tokens[i] is the name of a called function,
+// an identifier followed by a parenthesis. Keywords are only the names of
+// known functions, e.g. join.
+func isFunctionCall(tokens []token, i int) bool {
+	if tokens[i].kind != tokenIdent || i+1 == len(tokens) || tokens[i+1].kind != tokenLParen {
+		return false
+	}
+	_, known := functions[strings.ToLower(tokens[i].text)]
+	return known || !isReservedKeyword(tokens[i].text)
+}
+
+// parseCall parses a function call, the function name is not checked.
+func (p *exprParser) parseCall() (Expr, error) {
+	call := &CallExpr{Func: p.next().text}
+	p.next() // (
+
+	if p.peekKind(tokenRParen, "") {
+		p.next()
+		return call, nil
+	}
+
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+
+		if p.peekKind(tokenComma, "") {
+			p.next()
+			continue
+		}
+		if _, err := p.expect(tokenRParen, "',' or ')'"); err != nil {
+			return nil, err
+		}
+		return call, nil
+	}
+}
+
+// parseFieldRef parses a field path made of dot separated names and
+// bracketed array indexes. Names may be quoted, and numeric names are
+// accepted after a dot (e.g. spec.containers.0.image).
+func (p *exprParser) parseFieldRef() (Expr, error) {
+	field := &FieldRef{Path: []Segment{{Name: p.next().value}}}
+
+	for {
+		switch {
+		case p.peekKind(tokenDot, ""):
+			p.next()
+			tok := p.peek()
+			switch {
+			case p.done():
+				return nil, p.errorf("expected a field name after '.'")
+			case tok.kind == tokenIdent, tok.kind == tokenQuotedIdent:
+				field.Path = append(field.Path, Segment{Name: tok.value})
+			case tok.kind == tokenNumber:
+				index, err := strconv.Atoi(tok.text)
+				if err != nil {
+					return nil, p.errorf("invalid array index '%s'", tok.text)
+				}
+				field.Path = append(field.Path, Segment{Index: index, Array: true})
+			default:
+				return nil, p.errorf("expected a field name after '.'")
+			}
+			p.next()
+		case p.peekKind(tokenLBracket, ""):
+			p.next()
+			tok := p.peek()
+			switch {
+			case p.done():
+				return nil, p.errorf("expected an array index, '*' or a quoted key")
+			case tok.kind == tokenOperator && tok.text == "*":
+				field.Path = append(field.Path, Segment{Index: -1, Array: true})
+			case tok.kind == tokenNumber:
+				index, err := strconv.Atoi(tok.text)
+				if err != nil {
+					return nil, p.errorf("invalid array index '%s'", tok.text)
+				}
+				field.Path = append(field.Path, Segment{Index: index, Array: true})
+			case tok.kind == tokenString:
+				field.Path = append(field.Path, Segment{Name: tok.value})
+			default:
+				return nil, p.errorf("expected an array index, '*' or a quoted key")
+			}
+			p.next()
+			if _, err := p.expect(tokenRBracket, "']'"); err != nil {
+				return nil, err
+			}
+		default:
+			return field, nil
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package kubesql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	testCases := []struct {
+		input    TSLQuery
+		expected string
+	}{
+		{"name = 'nginx'", "name = 'nginx'"},
+		{"name == 'nginx' AND namespace <> 'default'", "name = 'nginx' AND namespace != 'default'"},
+		{"a = 1 OR b = 2 AND c = 3", "a = 1 OR b = 2 AND c = 3"},
+		{"NOT (a = 1 OR b = 2)", "NOT (a = 1 OR b = 2)"},
+		{"status.phase in ('Running', 'Pending')", "status.phase IN ('Running', 'Pending')"},
+		{"status.phase NOT IN ['Failed']", "status.phase NOT IN ('Failed')"},
+		{"spec.replicas between 1 and 3", "spec.replicas BETWEEN 1 AND 3"},
+		{"deleted is not null", "deleted IS NOT NULL"},
+		{"spec.containers[0].image like 'nginx%'", "spec.containers[0].image LIKE 'nginx%'"},
+		{"spec.containers.0.image ~= '^nginx'", "spec.containers[0].image ~= '^nginx'"},
+		{"spec.containers[*].name = 'app'", "spec.containers[*].name = 'app'"},
+		{"labels['app.kubernetes.io/name'] = 'web'", "labels.`app.kubernetes.io/name` = 'web'"},
+		{"`select`.`from` = true", "`select`.`from` = TRUE"},
+		{"spec.replicas * 2 + 1 > -3", "spec.replicas * 2 + 1 > -3"},
+		{"len(spec.containers) >= 2", "len(spec.containers) >= 2"},
+		{"resources.limits.memory > 512Mi", "resources.limits.memory > 512Mi"},
+		{"*", "*"},
+		{"spec.nodeName in (select name from nodes where spec.unschedulable = true)",
+			"spec.nodeName IN (SELECT name FROM nodes WHERE spec.unschedulable = TRUE)"},
+		{"name NOT IN ( select name\nfrom nodes )", "name NOT IN (SELECT name FROM nodes)"},
+		{"exists (select name from nodes n where n.name = p.spec.nodeName)",
+			"EXISTS (SELECT name FROM nodes n WHERE n.name = p.spec.nodeName)"},
+		{"NOT EXISTS (SELECT name FROM pods WHERE name IN (SELECT name FROM services)) OR a = 1",
+			"NOT EXISTS (SELECT name FROM pods WHERE name IN (SELECT name FROM services)) OR a = 1"},
+	}
+
+	for _, tc := range testCases {
+		expr, err := ParseExpr(tc.input)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if expr.String() != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, expr.String())
+		}
+
+		// The rendered expression must parse back to the same expression
+		again, err := ParseExpr(TSLQuery(expr.String()))
+		if err != nil {
+			t.Errorf("For input '%s', re-parsing '%s' failed: %v", tc.input, expr.String(), err)
+		} else if again.String() != expr.String() {
+			t.Errorf("For input '%s', round trip changed '%s' to '%s'", tc.input, expr.String(), again.String())
+		}
+	}
+}
+
+func TestParseExprPrecedence(t *testing.T) {
+	expr, err := ParseExpr("a = 1 OR b = 2 AND NOT c = 3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	or, ok := expr.(*BinaryExpr)
+	if !ok || or.Op != "OR" {
+		t.Fatalf("expected an OR expression, got %#v", expr)
+	}
+	and, ok := or.Y.(*BinaryExpr)
+	if !ok || and.Op != "AND" {
+		t.Fatalf("expected an AND expression on the right of OR, got %#v", or.Y)
+	}
+	if not, ok := and.Y.(*UnaryExpr); !ok || not.Op != "NOT" {
+		t.Errorf("expected a NOT expression on the right of AND, got %#v", and.Y)
+	}
+}
+
+func TestParseExprFieldRef(t *testing.T) {
+	expr, err := ParseExpr("spec.containers[2].\"env var\"")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ref, ok := expr.(*FieldRef)
+	if !ok {
+		t.Fatalf("expected a field reference, got %#v", expr)
+	}
+	expected := []Segment{{Name: "spec"}, {Name: "containers"}, {Index: 2, Array: true}, {Name: "env var"}}
+	if len(ref.Path) != len(expected) {
+		t.Fatalf("expected path %v, got %v", expected, ref.Path)
+	}
+	for i := range expected {
+		if ref.Path[i] != expected[i] {
+			t.Errorf("segment %d: expected %v, got %v", i, expected[i], ref.Path[i])
+		}
+	}
+	if ref.Name() != "spec.containers[2].env var" {
+		t.Errorf("unexpected name '%s'", ref.Name())
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	testCases := []struct {
+		input  TSLQuery
+		column int
+	}{
+		{"", 1},
+		{"name =", 7},
+		{"name = 'a' extra", 12},
+		{"(name = 'a'", 12},
+		{"status.phase IN ('a',", 22},
+		{"order = 1", 1},
+		{"spec.", 6},
+		{"spec[x]", 6},
+		{"name BETWEEN 1", 15},
+		{"name IS 'a'", 9},
+		{"EXISTS name", 8},
+		{"name IN (SELECT name FROM nodes", 32},
+		{"name IN (SELECT name FROM nodes WHERE)", 38},
+		{"name IN (WITH n AS (FROM nodes) SELECT name FROM n)", 51},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParseExpr(tc.input)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("For input '%s', expected a *ParseError, got %v", tc.input, err)
+			continue
+		}
+		if parseErr.Pos.Column != tc.column {
+			t.Errorf("For input '%s', expected error at column %d, got %d (%v)", tc.input, tc.column, parseErr.Pos.Column, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package kubesql
+
+import (
+	"sort"
+)
+
+// FieldSet is the set of fields a query reads from the objects it queries.
+type FieldSet struct {
+	All    bool        // The query reads whole objects, e.g. SELECT *
+	Fields []*FieldRef // Full field paths, sorted; nil when All is set
+}
+
+// ReferencedFields returns the fields read by the SELECT, ON, WHERE and
+// ORDER BY clauses of a query, and metadata.namespace when the FROM clause
+// names a namespace. Short field names are expanded, array indexes become
+// [*], and fields within another referenced field are omitted. A query
+// without SELECT, or with SELECT *, reads whole objects, and so does a
+// query with common table expressions, subqueries or set operations.
+// Fields of queries with joins start with the name of their table, e.g.
+// p.metadata.name.
+func ReferencedFields(q *Query) (*FieldSet, error) {
+	s, err := NewStatement(q)
+	if err != nil {
+		return nil, err
+	}
+	if s.Select == nil || len(s.With) > 0 || len(s.Compound) > 0 {
+		return &FieldSet{All: true}, nil
+	}
+	if s.Where != nil && len(subqueries(s.Where.Cond)) > 0 {
+		return &FieldSet{All: true}, nil
+	}
+
+	aliases := make(map[string]bool)
+	for _, field := range s.Select.Fields {
+		if _, ok := field.Expr.(*StarExpr); ok {
+			return &FieldSet{All: true}, nil
+		}
+		if field.Alias != "" {
+			aliases[field.Alias] = true
+		}
+	}
+
+	tables, err := queryTables(q)
+	if err != nil {
+		return nil, err
+	}
+	names := tableNames(tables)
+	var paths [][]Segment
+	for _, t := range tables {
+		if t.ref.Namespace != "" {
+			path := []Segment{{Name: "metadata"}, {Name: "namespace"}}
+			if len(tables) > 1 {
+				path = append([]Segment{{Name: t.name}}, path...)
+			}
+			paths = append(paths, path)
+		}
+	}
+	Inspect(s, func(node Node) bool {
+		switch n := node.(type) {
+		case *OrderByItem:
+			// Keys naming a SELECT alias read the column
+			if ref, ok := n.Expr.(*FieldRef); ok && len(ref.Path) == 1 && aliases[ref.Path[0].Name] {
+				return false
+			}
+		case *FieldRef:
+			var path []Segment
+			for _, segment := range rowPath(n.Path, names) {
+				if segment.Array {
+					segment.Index = -1
+				}
+				path = append(path, segment)
+			}
+			paths = append(paths, path)
+		}
+		return true
+	})
+
+	sort.Slice(paths, func(i, j int) bool {
+		return comparePaths(paths[i], paths[j]) < 0
+	})
+	set := &FieldSet{}
+	for _, path := range paths {
+		// Sorted paths follow the paths they start with
+		if n := len(set.Fields); n > 0 && hasPathPrefix(path, set.Fields[n-1].Path) {
+			continue
+		}
+		set.Fields = append(set.Fields, &FieldRef{Path: path})
+	}
+	return set, nil
+}
+
+// comparePaths orders field paths segment by segment, so that a path comes
+// right before the paths it is a prefix of.
+func comparePaths(x, y []Segment) int {
+	for i := 0; i < len(x) && i < len(y); i++ {
+		switch {
+		case x[i].Array != y[i].Array:
+			if x[i].Array {
+				return -1
+			}
+			return 1
+		case x[i].Name < y[i].Name:
+			return -1
+		case x[i].Name > y[i].Name:
+			return 1
+		}
+	}
+	return len(x) - len(y)
+}
+
+// hasPathPrefix reports whether a field path starts with prefix.
+func hasPathPrefix(path, prefix []Segment) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i, segment := range prefix {
+		if segment != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Prune returns a copy of an object keeping only the fields of the set,
+// and the apiVersion and kind identifying the object. Arrays keep all their
+// items, each pruned to the fields read from the items. Objects are returned
+// unchanged when the set holds whole objects.
+func (s *FieldSet) Prune(object map[string]any) map[string]any {
+	if s.All {
+		return object
+	}
+
+	result := make(map[string]any)
+	for _, key := range []string{"apiVersion", "kind"} {
+		if value, ok := object[key]; ok {
+			result[key] = value
+		}
+	}
+	for _, field := range s.Fields {
+		result = pruneValue(result, object, field.Path).(map[string]any)
+	}
+	return result
+}
+
+// pruneValue copies the value at path from src into dst, creating the
+// objects and arrays leading to it, and returns the new dst.
+func pruneValue(dst, src any, path []Segment) any {
+	if len(path) == 0 {
+		return src
+	}
+
+	if path[0].Array {
+		items, ok := src.([]any)
+		if !ok {
+			return dst
+		}
+		pruned, _ := dst.([]any)
+		if pruned == nil {
+			pruned = make([]any, len(items))
+		}
+		for i, item := range items {
+			pruned[i] = pruneValue(pruned[i], item, path[1:])
+		}
+		return pruned
+	}
+
+	object, ok := src.(map[string]any)
+	if !ok {
+		return dst
+	}
+	pruned, _ := dst.(map[string]any)
+	if pruned == nil {
+		pruned = make(map[string]any)
+	}
+	if value, ok := object[path[0].Name]; ok {
+		pruned[path[0].Name] = pruneValue(pruned[path[0].Name], value, path[1:])
+	}
+	return pruned
+}
@@ -0,0 +1,137 @@
+package kubesql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferencedFields(t *testing.T) {
+	testCases := []struct {
+		input    string
+		all      bool
+		expected []string
+	}{
+		{"SELECT * FROM pods WHERE name = 'a'", true, nil},
+		{"FROM pods WHERE name = 'a'", true, nil},
+		{"SELECT name, status.phase FROM pods", false, []string{"metadata.name", "status.phase"}},
+		{"SELECT name FROM default/pods WHERE labels.app = 'web' ORDER BY created", false, []string{"metadata.creationTimestamp", "metadata.labels.app", "metadata.name", "metadata.namespace"}},
+		{"SELECT labels, labels.app FROM pods WHERE metadata.labels.tier = 'db'", false, []string{"metadata.labels"}},
+		{"SELECT spec.containers[0].image FROM pods WHERE spec.containers[*].name = 'a'", false, []string{"spec.containers[*].image", "spec.containers[*].name"}},
+		{"SELECT count(*), sum(spec.replicas) FROM deployments", false, []string{"spec.replicas"}},
+		{"SELECT status.phase AS phase FROM pods ORDER BY phase, len(spec.containers)", false, []string{"spec.containers", "status.phase"}},
+		{"SELECT 1 + 2 FROM pods", false, nil},
+		{"SELECT metadata.labels.`app.kubernetes.io/name` FROM pods", false, []string{"metadata.labels.`app.kubernetes.io/name`"}},
+		{"SELECT p.name FROM pods AS p WHERE p.labels.app = 'web'", false, []string{"metadata.labels.app", "metadata.name"}},
+		{"SELECT name, n.labels FROM default/pods p JOIN nodes n ON spec.nodeName = n.name", false, []string{"n.metadata.labels", "n.metadata.name", "p.metadata.name", "p.metadata.namespace", "p.spec.nodeName"}},
+		{"SELECT spec.nodeName FROM pods WHERE spec.nodeName IN (SELECT metadata.name FROM nodes WHERE spec.unschedulable = true)", true, nil},
+		{"SELECT name FROM pods p WHERE NOT EXISTS (SELECT n.name FROM nodes n WHERE n.name = p.spec.nodeName)", true, nil},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		set, err := ReferencedFields(query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if set.All != tc.all {
+			t.Errorf("For input '%s', expected All %v, got %v", tc.input, tc.all, set.All)
+		}
+		var fields []string
+		for _, field := range set.Fields {
+			fields = append(fields, field.String())
+		}
+		if !reflect.DeepEqual(fields, tc.expected) {
+			t.Errorf("For input '%s', expected fields %v, got %v", tc.input, tc.expected, fields)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	pod := map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata":   map[string]any{"name": "web", "namespace": "default", "labels": map[string]any{"app": "web"}},
+		"spec": map[string]any{
+			"nodeName":   nil,
+			"containers": []any{map[string]any{"name": "a", "image": "nginx", "args": []any{"-v"}}, "invalid"},
+		},
+	}
+
+	testCases := []struct {
+		input    string
+		expected map[string]any
+	}{
+		{
+			"SELECT name FROM pods WHERE spec.containers[*].image = 'nginx'",
+			map[string]any{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata":   map[string]any{"name": "web"},
+				"spec":       map[string]any{"containers": []any{map[string]any{"image": "nginx"}, nil}},
+			},
+		},
+		{
+			"SELECT labels, spec.nodeName, status.phase FROM pods",
+			map[string]any{
+				"apiVersion": "v1",
+				"kind":       "Pod",
+				"metadata":   map[string]any{"labels": map[string]any{"app": "web"}},
+				"spec":       map[string]any{"nodeName": nil},
+			},
+		},
+		{"SELECT * FROM pods", pod},
+	}
+
+	for _, tc := range testCases {
+		query, _ := NewParser(tc.input).Parse()
+		set, err := ReferencedFields(query)
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		if pruned := set.Prune(pod); !reflect.DeepEqual(pruned, tc.expected) {
+			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, pruned)
+		}
+	}
+}
+
+func TestPruneResults(t *testing.T) {
+	dataset, err := LoadDataset("testdata/snapshot")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	objects := dataset.Objects(ResourceInfo{Kind: "Pod"})
+	if len(objects) == 0 {
+		t.Fatalf("Expected pods in the snapshot")
+	}
+
+	queries := []string{
+		"SELECT name, status.phase AS phase FROM default/pods WHERE labels.app IS NOT NULL ORDER BY phase, name",
+		"SELECT name, spec.containers[0].image FROM pods WHERE spec.containers[*].name LIKE '%a%'",
+		"SELECT count(*), max(len(spec.containers)) FROM pods WHERE status.phase != 'Running'",
+	}
+	for _, input := range queries {
+		query, err := NewParser(input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		set, _ := ReferencedFields(query)
+		pruned := make([]map[string]any, len(objects))
+		for i, object := range objects {
+			pruned[i] = set.Prune(object)
+		}
+
+		want, err1 := Execute(query, objects)
+		got, err2 := Execute(query, pruned)
+		if err1 != nil || err2 != nil {
+			t.Errorf("For input '%s', expected no error, got %v and %v", input, err1, err2)
+			continue
+		}
+		if !reflect.DeepEqual(want.Rows, got.Rows) {
+			t.Errorf("For input '%s', expected rows %v over pruned objects, got %v", input, want.Rows, got.Rows)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package kubesql
+
+import (
+	"strings"
+)
+
+// FormatScript renders queries as a KubeSQL script, one statement per line,
+// each terminated by a semicolon. The canonical form of each statement is
+// the one returned by Query.String.
+//
+// Comments are preserved on their own lines: comments written before a
+// statement are placed before it, and comments written inside or after a
+// statement are placed right after it, before its semicolon, so that they
+// stay with their statement when the script is parsed again.
+func FormatScript(queries []*Query) string {
+	var result strings.Builder
+
+	for _, query := range queries {
+		var trailing []Comment
+		for _, comment := range query.Comments {
+			if comment.Pos.Offset < query.Pos.Offset {
+				result.WriteString(comment.Text)
+				result.WriteByte('\n')
+			} else {
+				trailing = append(trailing, comment)
+			}
+		}
+
+		result.WriteString(query.String())
+		for _, comment := range trailing {
+			result.WriteByte('\n')
+			result.WriteString(comment.Text)
+		}
+		if len(trailing) > 0 {
+			result.WriteByte('\n')
+		}
+		result.WriteString(";\n")
+	}
+
+	return result.String()
+}
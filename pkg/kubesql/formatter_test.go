@@ -0,0 +1,81 @@
+package kubesql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatScript(t *testing.T) {
+	script := `-- Running pods
+select name from pods where status.phase='Running' -- inline
+;
+/* all nodes */ SELECT * FROM nodes`
+
+	queries, err := ParseScript(script)
+	if err != nil {
+		t.Fatalf("Failed to parse script: %v", err)
+	}
+
+	expected := `-- Running pods
+SELECT name FROM pods WHERE status.phase='Running'
+-- inline
+;
+/* all nodes */
+SELECT * FROM nodes;
+`
+	formatted := FormatScript(queries)
+	if formatted != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, formatted)
+	}
+
+	// Formatting is stable
+	reparsed, err := ParseScript(formatted)
+	if err != nil {
+		t.Fatalf("Failed to parse formatted script: %v", err)
+	}
+	if again := FormatScript(reparsed); again != formatted {
+		t.Errorf("Formatting is not stable:\n%s\nGot:\n%s", formatted, again)
+	}
+}
+
+func TestFormatScriptIdempotent(t *testing.T) {
+	scripts := []string{
+		"SELECT name -- pod name\nFROM pods;\nSELECT * FROM nodes;",
+		"-- first\nFROM pods /* inner */ WHERE a = 1; -- second\nFROM nodes -- last\n",
+		"FROM pods; FROM nodes; /* after */",
+	}
+
+	for _, script := range scripts {
+		queries, err := ParseScript(script)
+		if err != nil {
+			t.Fatalf("For script '%s', expected no error, got %v", script, err)
+		}
+		formatted := FormatScript(queries)
+		reparsed, err := ParseScript(formatted)
+		if err != nil {
+			t.Fatalf("For script '%s', expected no error parsing the formatted script, got %v", script, err)
+		}
+		if again := FormatScript(reparsed); again != formatted {
+			t.Errorf("For script '%s', expected formatting to be stable:\n%s\ngot:\n%s", script, formatted, again)
+		}
+
+		// Each comment stays with its statement
+		if len(reparsed) != len(queries) {
+			t.Fatalf("For script '%s', expected %d statements, got %d", script, len(queries), len(reparsed))
+		}
+		for i := range queries {
+			if expected, got := commentTexts(queries[i]), commentTexts(reparsed[i]); !reflect.DeepEqual(expected, got) {
+				t.Errorf("For script '%s', expected the comments %q on statement %d, got %q", script, expected, i+1, got)
+			}
+		}
+	}
+}
+
+// commentTexts returns the texts of the comments of a statement.
+func commentTexts(query *Query) []string {
+	var texts []string
+	for _, comment := range query.Comments {
+		texts = append(texts, comment.Text)
+	}
+	return texts
+}
@@ -0,0 +1,247 @@
+package kubesql
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// fuzzSeeds are valid and invalid queries used to seed every fuzz target.
+// Additional inputs, including minimized crashers, live in testdata/fuzz.
+var fuzzSeeds = []string{
+	"SELECT name FROM pods",
+	"SELECT name, namespace FROM pods WHERE status='Running' ORDER BY name ASC LIMIT 10",
+	"SELECT name AS pod_name, namespace AS ns FROM pods",
+	"SELECT * FROM mynamespace/services WHERE namespace='default'",
+	"SELECT COUNT(*) AS total FROM pods",
+	"select metadata.name from pods order by metadata.creationTimestamp desc, name limit 5",
+	"SELECT name FROM pods WHERE (a = 1 and (b = 2 or c = 3))",
+	"SELECT name FROM pods WHERE name = '('",
+	"SELECT a), b( FROM pods",
+	"SELECT name FROM pods LIMIT -1",
+	"SELECT name FROM pods ORDER BY name UP",
+	"SELECT name, namespace",
+	"",
+}
+
+// requireParseError checks the invariant that every error returned by Parse
+// is a *ParseError positioned inside the query text.
+func requireParseError(t *testing.T, query string, err error) *ParseError {
+	t.Helper()
+
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("For input %q, expected a *ParseError, got %T: %v", query, err, err)
+	}
+	if parseErr.Pos.Offset < 0 || parseErr.Pos.Offset > len(query) {
+		t.Fatalf("For input %q, error offset %d is outside of the query", query, parseErr.Pos.Offset)
+	}
+	if parseErr.Pos != positionAt(query, parseErr.Pos.Offset) {
+		t.Fatalf("For input %q, inconsistent error position %+v", query, parseErr.Pos)
+	}
+	return parseErr
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		result, err := NewParser(query).Parse()
+		if err != nil {
+			requireParseError(t, query, err)
+			return
+		}
+
+		tokens, err := tokenize(query)
+		if err != nil {
+			t.Fatalf("For input %q, parse succeeded but tokenizing failed: %v", query, err)
+		}
+		if tok, err := checkParentheses(tokens); err != nil {
+			t.Fatalf("For input %q, unbalanced parentheses at %d were accepted", query, tok.pos)
+		}
+		if result.From == "" {
+			t.Fatalf("For input %q, parse succeeded without a FROM resource", query)
+		}
+		if result.Limit < DefaultLimit {
+			t.Fatalf("For input %q, invalid limit %d", query, result.Limit)
+		}
+		for _, field := range result.OrderBy {
+			if field.Direction != "ASC" && field.Direction != "DESC" {
+				t.Fatalf("For input %q, invalid sort direction %q", query, field.Direction)
+			}
+		}
+	})
+}
+
+func FuzzQueryStringRoundTrip(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		result, err := NewParser(query).Parse()
+		if err != nil {
+			return
+		}
+
+		// The formatted query must parse back into the same formatted query
+		formatted := result.String()
+		reparsed, err := NewParser(formatted).Parse()
+		if err != nil {
+			t.Fatalf("For input %q, formatted query %q does not parse: %v", query, formatted, err)
+		}
+		if reformatted := reparsed.String(); reformatted != formatted {
+			t.Fatalf("For input %q, round trip changed the query:\nfirst:  %s\nsecond: %s",
+				query, formatted, reformatted)
+		}
+	})
+}
+
+func FuzzParseScript(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Add("-- pods\nSELECT name FROM pods;\n/* nodes */ SELECT * FROM nodes LIMIT 1; -- done")
+	f.Add(";;SELECT name FROM pods;;")
+
+	f.Fuzz(func(t *testing.T, script string) {
+		queries, err := ParseScript(script)
+		if err != nil {
+			requireParseError(t, script, err)
+			return
+		}
+
+		// Statements are in order and every comment is kept exactly once,
+		// a script without statements has nothing to attach comments to
+		_, comments, _ := tokenizeWithComments(script)
+		count := 0
+		for i, query := range queries {
+			if i > 0 && query.Pos.Offset <= queries[i-1].Pos.Offset {
+				t.Fatalf("For input %q, statement %d is out of order", script, i)
+			}
+			count += len(query.Comments)
+		}
+		if len(queries) > 0 && count != len(comments) {
+			t.Fatalf("For input %q, expected %d comments, got %d", script, len(comments), count)
+		}
+
+		// The formatted script parses back into the same formatted script
+		formatted := FormatScript(queries)
+		reparsed, err := ParseScript(formatted)
+		if err != nil {
+			t.Fatalf("For input %q, formatted script %q does not parse: %v", script, formatted, err)
+		}
+		if again := FormatScript(reparsed); again != formatted {
+			t.Fatalf("For input %q, round trip changed the script:\nfirst:  %s\nsecond: %s", script, formatted, again)
+		}
+		for i := range reparsed {
+			if i < len(queries) && !reflect.DeepEqual(commentTexts(queries[i]), commentTexts(reparsed[i])) {
+				t.Fatalf("For input %q, formatting moved the comments of statement %d to %q", script, i+1, formatted)
+			}
+		}
+	})
+}
+
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Add("a), b(")
+	f.Add(`'it''s', "say \"hi\"", ` + "`from`" + `, 'a\\b'`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		tokens, comments, err := tokenizeWithComments(input)
+		if err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || parseErr.Pos.Offset < 0 || parseErr.Pos.Offset > len(input) {
+				t.Fatalf("For input %q, expected a positioned *ParseError, got %v", input, err)
+			}
+			return
+		}
+
+		// Tokens and comments cover the input in order, separated only by whitespace
+		all := append(append([]token{}, tokens...), comments...)
+		sort.Slice(all, func(i, j int) bool { return all[i].pos < all[j].pos })
+		end := 0
+		for i, tok := range all {
+			if tok.pos < end || tok.end() > len(input) || input[tok.pos:tok.end()] != tok.text || tok.text == "" {
+				t.Fatalf("For input %q, token %d %+v does not match the input", input, i, tok)
+			}
+			if gap := input[end:tok.pos]; strings.TrimSpace(gap) != "" {
+				t.Fatalf("For input %q, text %q between tokens was dropped", input, gap)
+			}
+			end = tok.end()
+		}
+		if rest := input[end:]; strings.TrimSpace(rest) != "" {
+			t.Fatalf("For input %q, trailing text %q was dropped", input, rest)
+		}
+
+		// Splitting never loses or invents tokens
+		count := 0
+		for _, part := range splitTokens(tokens, tokenComma) {
+			count += len(part) + 1
+		}
+		if len(tokens) > 0 && count-1 != len(tokens) {
+			t.Fatalf("For input %q, split parts do not add up to %d tokens", input, len(tokens))
+		}
+	})
+}
+
+func FuzzParseExpr(f *testing.F) {
+	for _, seed := range []string{
+		"name = 'nginx' AND namespace != 'default'",
+		"NOT (a = 1 OR b IN (1, 2, 3))",
+		"spec.containers[0].image LIKE 'nginx%'",
+		"labels['app.kubernetes.io/name'] ~= '^web'",
+		"spec.replicas * 2 BETWEEN -1 AND 3 OR deleted IS NOT NULL",
+		"len(spec.containers) > 512Mi",
+		"`order`.\"from\" = TRUE",
+		"spec.",
+		"(a = 1",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		expr, err := ParseExpr(TSLQuery(input))
+		if err != nil {
+			requireParseError(t, input, err)
+			return
+		}
+
+		// String must render an expression that parses back to itself
+		rendered := expr.String()
+		again, err := ParseExpr(TSLQuery(rendered))
+		if err != nil {
+			t.Fatalf("For input %q, re-parsing %q failed: %v", input, rendered, err)
+		}
+		if again.String() != rendered {
+			t.Fatalf("For input %q, round trip changed %q to %q", input, rendered, again.String())
+		}
+
+		// Type inference and evaluation must handle any parsed expression
+		_, _ = InferType(expr, nil)
+		_, _ = Eval(expr, testPod())
+	})
+}
+
+func FuzzComplete(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed, len(seed)/2)
+	}
+
+	completer := NewCompleter(DefaultResolver(), nil)
+	f.Fuzz(func(t *testing.T, query string, cursor int) {
+		// Suggestions replace a word that ends at the cursor
+		for _, suggestion := range completer.Complete(query, cursor) {
+			end := max(0, min(cursor, len(query)))
+			if suggestion.Start < 0 || suggestion.Start > end {
+				t.Fatalf("For input %q at %d, suggestion %q starts at %d", query, cursor, suggestion.Text, suggestion.Start)
+			}
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package kubesql
+
+import (
+	"errors"
+)
+
+// TokenClass is the syntactic class of a highlighted token.
+type TokenClass int
+
+// Token classes.
+const (
+	KeywordToken  TokenClass = iota // A keyword, e.g. SELECT or AND
+	FieldToken                      // A field name or alias, e.g. status or phase
+	FunctionToken                   // A function name, e.g. len
+	ResourceToken                   // A namespace or resource name after FROM
+	StringToken                     // A string literal
+	NumberToken                     // A number, quantity or duration
+	OperatorToken                   // An operator, e.g. = or +
+	CommentToken                    // A comment
+)
+
+var tokenClassNames = []string{"keyword", "field", "function", "resource", "string", "number", "operator", "comment"}
+
+// String returns the lower case name of the token class.
+func (c TokenClass) String() string {
+	if c < 0 || int(c) >= len(tokenClassNames) {
+		return "unknown"
+	}
+	return tokenClassNames[c]
+}
+
+// HighlightToken is a classified span of a script.
+type HighlightToken struct {
+	Class  TokenClass // Syntactic class of the token
+	Offset int        // Byte offset of the token in the script
+	Length int        // Length of the token in bytes
+}
+
+// Highlight classifies the tokens and comments of a script for syntax
+// highlighting, in source order. Punctuation is not reported. A script with
+// a lexical error, such as an unterminated string, is classified up to the
+// error.
+func Highlight(script string) []HighlightToken {
+	tokens, comments, err := tokenizeWithComments(script)
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		tokens, comments, _ = tokenizeWithComments(script[:parseErr.Pos.Offset])
+	}
+
+	var result []HighlightToken
+	inFrom := false
+	for i, tok := range tokens {
+		// Comments come before the tokens that follow them
+		for len(comments) > 0 && comments[0].pos < tok.pos {
+			result = append(result, HighlightToken{Class: CommentToken, Offset: comments[0].pos, Length: len(comments[0].text)})
+			comments = comments[1:]
+		}
+
+		class, highlighted := FieldToken, true
+		switch tok.kind {
+		case tokenIdent:
+			switch {
+			case isFunctionCall(tokens, i) && !inFrom:
+				class = FunctionToken
+			case isReservedKeyword(tok.text):
+				class = KeywordToken
+				inFrom = tok.isKeyword(FromKeyword) || tok.isKeyword(JoinKeyword)
+			case inFrom:
+				class = ResourceToken
+			}
+		case tokenQuotedIdent:
+			if inFrom {
+				class = ResourceToken
+			}
+		case tokenString:
+			class = StringToken
+		case tokenNumber:
+			class = NumberToken
+		case tokenOperator:
+			class = OperatorToken
+		case tokenSemicolon:
+			inFrom, highlighted = false, false
+		default:
+			highlighted = false
+		}
+
+		if highlighted {
+			result = append(result, HighlightToken{Class: class, Offset: tok.pos, Length: len(tok.text)})
+		}
+	}
+
+	for _, comment := range comments {
+		result = append(result, HighlightToken{Class: CommentToken, Offset: comment.pos, Length: len(comment.text)})
+	}
+	return result
+}
@@ -0,0 +1,40 @@
+package kubesql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHighlight(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    "SELECT name, len(spec.containers) FROM kube-system/pods WHERE x > 1Gi",
+			expected: []string{"keyword SELECT", "field name", "function len", "field spec", "field containers", "keyword FROM", "resource kube-system", "operator /", "resource pods", "keyword WHERE", "field x", "operator >", "number 1Gi"},
+		},
+		{
+			input:    "-- pods\nFROM pods /* all */ WHERE phase = 'Running';\nFROM nodes",
+			expected: []string{"comment -- pods", "keyword FROM", "resource pods", "comment /* all */", "keyword WHERE", "field phase", "operator =", "string 'Running'", "keyword FROM", "resource nodes"},
+		},
+		{
+			input:    "SELECT `order` FROM pods WHERE name = 'unterminated",
+			expected: []string{"keyword SELECT", "field `order`", "keyword FROM", "resource pods", "keyword WHERE", "field name", "operator ="},
+		},
+		{
+			input:    "SELECT join(p.labels) FROM pods p LEFT JOIN nodes n ON n.name = p.spec.nodeName",
+			expected: []string{"keyword SELECT", "function join", "field p", "field labels", "keyword FROM", "resource pods", "resource p", "keyword LEFT", "keyword JOIN", "resource nodes", "resource n", "keyword ON", "field n", "field name", "operator =", "field p", "field spec", "field nodeName"},
+		},
+	}
+
+	for _, tc := range testCases {
+		var result []string
+		for _, tok := range Highlight(tc.input) {
+			result = append(result, tok.Class.String()+" "+tc.input[tok.Offset:tok.Offset+tok.Length])
+		}
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("For input '%s', expected %q, got %q", tc.input, tc.expected, result)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package kubesql
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// table is a resource of a query: the FROM resource or a joined one.
+type table struct {
+	name string      // Name qualifying the fields of the table, its alias or resource name
+	ref  ResourceRef // Resource of the table
+	join *Join       // Join of the table, nil for the FROM resource
+	on   Expr        // Parsed join condition, nil for the FROM resource
+	cte  string      // Name of the common table expression the table reads, empty for a resource
+}
+
+// queryTables returns the tables of a query, the FROM resource first. Table
+// names must be unique, and join conditions may only use the tables joined
+// before them.
+func queryTables(q *Query) ([]table, error) {
+	ref, err := ParseResourceRef(q.From)
+	if err != nil {
+		return nil, err
+	}
+	tables := []table{{name: tableName(ref, q.FromAlias), ref: ref}}
+	for i := range q.Joins {
+		join := &q.Joins[i]
+		ref, err := ParseResourceRef(join.From)
+		if err != nil {
+			return nil, err
+		}
+		if join.Type != InnerJoin && join.Type != LeftJoin {
+			return nil, fmt.Errorf("invalid join type '%s', must be %s or %s", join.Type, InnerJoin, LeftJoin)
+		}
+		t := table{name: tableName(ref, join.Alias), ref: ref, join: join}
+		if slices.ContainsFunc(tables, func(other table) bool { return other.name == t.name }) {
+			return nil, fmt.Errorf("table '%s' is used twice, give the joined resources different aliases", t.name)
+		}
+		if t.on, err = ParseExpr(join.On); err != nil {
+			return nil, fmt.Errorf("error parsing %s condition of '%s': %w", OnKeyword, t.name, err)
+		}
+		tables = append(tables, t)
+	}
+
+	// Join conditions can not see the tables joined after them
+	names := tableNames(tables)
+	for i, t := range tables[1:] {
+		for _, name := range exprTables(t.on, names) {
+			if slices.Index(names, name) > i+1 {
+				return nil, fmt.Errorf("%s condition of '%s' uses '%s', which is joined after it", OnKeyword, t.name, name)
+			}
+		}
+	}
+	return tables, nil
+}
+
+// tableName returns the name qualifying the fields of a table: its alias,
+// or the resource name as written.
+func tableName(ref ResourceRef, alias string) string {
+	if alias != "" {
+		return alias
+	}
+	return ref.Resource
+}
+
+// tableNames returns the names qualifying fields in the rows a query is
+// evaluated over: the name of every table of a join, the FROM alias of a
+// query without joins, and none otherwise.
+func tableNames(tables []table) []string {
+	if len(tables) == 1 && tables[0].ref.Resource == tables[0].name {
+		return nil
+	}
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = t.name
+	}
+	return names
+}
+
+// tableOf returns the index of the table qualifying a field path, -1 when
+// the field is not qualified and belongs to the FROM resource. Without
+// joins, the FROM alias alone is a field name.
+func tableOf(path []Segment, names []string) int {
+	if len(path) == 0 || path[0].Array || len(names) == 1 && len(path) == 1 {
+		return -1
+	}
+	return slices.Index(names, path[0].Name)
+}
+
+// rowPath returns the path of a field in the rows a query is evaluated
+// over, with short field names expanded. Rows of joins map table names to
+// objects, and fields not qualified by a table name belong to the FROM
+// resource. Other queries are evaluated over objects, and the FROM alias
+// qualifying a field is dropped.
+func rowPath(path []Segment, names []string) []Segment {
+	i := tableOf(path, names)
+	switch {
+	case len(names) <= 1 && i < 0:
+		return resolveAlias(path)
+	case len(names) <= 1:
+		return resolveAlias(path[1:])
+	case i < 0:
+		return append([]Segment{{Name: names[0]}}, resolveAlias(path)...)
+	}
+	return append([]Segment{path[0]}, resolveAlias(path[1:])...)
+}
+
+// exprTables returns the names of the tables whose fields an expression
+// uses, in the order of names, including the ones its subqueries use.
+func exprTables(expr Expr, names []string) []string {
+	used := make([]bool, len(names))
+	Inspect(expr, func(node Node) bool {
+		switch node := node.(type) {
+		case *FieldRef:
+			if len(names) > 0 {
+				used[max(tableOf(node.Path, names), 0)] = true
+			}
+		case *Statement:
+			outer, _ := outerTables(node, names)
+			for _, name := range outer {
+				used[slices.Index(names, name)] = true
+			}
+			return false
+		}
+		return true
+	})
+	var result []string
+	for i, name := range names {
+		if used[i] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// unqualify drops the table name qualifying the fields of an expression
+// using a single table, so it reads like a condition on its objects.
+func unqualify(expr Expr, names []string) Expr {
+	return rewriteScope(expr, func(node Node) Node {
+		if ref, ok := node.(*FieldRef); ok && tableOf(ref.Path, names) >= 0 {
+			ref.Path = ref.Path[1:]
+		}
+		return node
+	})
+}
+
+// qualify rewrites the fields of an expression to their row paths, so the
+// same field reads the same however it is written. Subqueries are left
+// unchanged.
+func qualify(expr Expr, names []string) Expr {
+	if names == nil {
+		return expr
+	}
+	return rewriteScope(expr, func(node Node) Node {
+		if ref, ok := node.(*FieldRef); ok {
+			ref.Path = rowPath(ref.Path, names)
+		}
+		return node
+	})
+}
+
+// hashKeys returns the sides of the equalities of a join condition that
+// can be used as hash keys: for each equality between an expression over
+// the tables joined before and an expression over the joined table, the
+// first one in left and the second one in right. Relationship function
+// calls between two tables add the keys of relationKeys.
+func hashKeys(on Expr, names []string, name string) (left, right []Expr) {
+	// The side of an expression: 1 for the tables joined before, 2 for
+	// the joined table, 0 for neither
+	side := func(expr Expr) int {
+		used := exprTables(expr, names)
+		switch {
+		case len(used) == 0 || isAggregateQuery([]Expr{expr}):
+			return 0
+		case !slices.Contains(used, name):
+			return 1
+		case len(used) == 1:
+			return 2
+		}
+		return 0
+	}
+	for _, conjunct := range conjuncts(on) {
+		keyX, keyY, ok := relationKeys(conjunct, names)
+		if equality, isEquality := conjunct.(*BinaryExpr); isEquality && equality.Op == "=" {
+			keyX, keyY, ok = equality.X, equality.Y, true
+		}
+		if !ok {
+			continue
+		}
+		switch x, y := side(keyX), side(keyY); {
+		case x == 1 && y == 2:
+			left, right = append(left, keyX), append(right, keyY)
+		case x == 2 && y == 1:
+			left, right = append(left, keyY), append(right, keyX)
+		}
+	}
+	return left, right
+}
+
+// joinRows joins rows, which map the names of the tables joined so far to
+// their objects, with the objects of the next table. The objects are
+// indexed by the values of the hash keys of the join condition, and each
+// row is only checked against the objects sharing one of its keys; without
+// hash keys, against every object. LEFT joins keep the rows matching no
+// object, without the joined table.
+func (e *evaluator) joinRows(rows []map[string]any, t table, objects []map[string]any) ([]map[string]any, error) {
+	left, right := hashKeys(t.on, e.tables, t.name)
+	every := make([]int, len(objects))
+	for i := range every {
+		every[i] = i
+	}
+
+	// Objects whose keys can not be hashed are checked against every row
+	index := make(map[string][]int)
+	var unhashed []int
+	if len(left) > 0 {
+		for i, object := range objects {
+			keys, ok, err := e.joinKeys(right, map[string]any{t.name: object})
+			if err != nil {
+				return nil, fmt.Errorf("error evaluating %s condition of '%s': %w", OnKeyword, t.name, err)
+			}
+			if !ok {
+				unhashed = append(unhashed, i)
+				continue
+			}
+			for _, key := range keys {
+				index[key] = append(index[key], i)
+			}
+		}
+	}
+
+	var result []map[string]any
+	for _, row := range rows {
+		candidates := every
+		if len(left) > 0 {
+			keys, ok, err := e.joinKeys(left, row)
+			if err != nil {
+				return nil, fmt.Errorf("error evaluating %s condition of '%s': %w", OnKeyword, t.name, err)
+			}
+			if ok {
+				candidates = slices.Clone(unhashed)
+				for _, key := range keys {
+					candidates = append(candidates, index[key]...)
+				}
+				// Keep the order of the objects, once each
+				slices.Sort(candidates)
+				candidates = slices.Compact(candidates)
+			}
+		}
+
+		matched := false
+		for _, i := range candidates {
+			joined := maps.Clone(row)
+			joined[t.name] = objects[i]
+			value, err := e.eval(t.on, joined)
+			if err != nil {
+				return nil, fmt.Errorf("error evaluating %s condition of '%s': %w", OnKeyword, t.name, err)
+			}
+			if truthy(value) {
+				result = append(result, joined)
+				matched = true
+			}
+		}
+		if !matched && t.join.Type == LeftJoin {
+			result = append(result, row)
+		}
+	}
+	return result, nil
+}
+
+// joinKeys returns the hash keys of a row, one for each combination of the
+// keys of the values of the key expressions. It reports false when a value
+// can not be hashed.
+func (e *evaluator) joinKeys(exprs []Expr, row map[string]any) ([]string, bool, error) {
+	keys := []string{""}
+	for _, expr := range exprs {
+		value, err := e.eval(expr, row)
+		if err != nil {
+			return nil, false, err
+		}
+		valueKeys, ok := hashValue(value)
+		if !ok {
+			return nil, false, nil
+		}
+		combined := make([]string, 0, len(keys)*len(valueKeys))
+		for _, key := range keys {
+			for _, valueKey := range valueKeys {
+				combined = append(combined, key+"\x00"+valueKey)
+			}
+		}
+		keys = combined
+	}
+	return keys, true, nil
+}
+
+// hashValue returns the hash keys of a value, such that values compareValues
+// finds equal share a key: strings have a key for each kind of value they
+// convert to, and wildcards the keys of all their values. It reports false
+// for values that can not be hashed, such as maps and lists.
+func hashValue(value any) ([]string, bool) {
+	switch value := value.(type) {
+	case nil:
+		return []string{"null"}, true
+	case bool:
+		return []string{"b:" + strconv.FormatBool(value)}, true
+	case float64:
+		return numberHash(value)
+	case time.Time:
+		return []string{timeHash(value)}, true
+	case time.Duration:
+		return []string{durationHash(value)}, true
+	case string:
+		keys := []string{"s:" + value}
+		if t, err := parseTimestamp(value); err == nil {
+			keys = append(keys, timeHash(t))
+		}
+		if q, err := parseQuantity(value); err == nil {
+			if key, ok := numberHash(q); ok {
+				keys = append(keys, key...)
+			}
+		}
+		if d, err := parseDuration(value); err == nil {
+			keys = append(keys, durationHash(d))
+		}
+		return keys, true
+	case wildcard:
+		var keys []string
+		for _, item := range value {
+			itemKeys, ok := hashValue(item)
+			if !ok {
+				return nil, false
+			}
+			keys = append(keys, itemKeys...)
+		}
+		return keys, true
+	}
+	return nil, false
+}
+
+// numberHash returns the hash key of a number. NaN compares equal to any
+// number, so it can not be hashed.
+func numberHash(value float64) ([]string, bool) {
+	if math.IsNaN(value) {
+		return nil, false
+	}
+	if value == 0 {
+		// -0 equals 0
+		value = 0
+	}
+	return []string{"n:" + strconv.FormatFloat(value, 'g', -1, 64)}, true
+}
+
+// timeHash returns the hash key of a timestamp.
+func timeHash(value time.Time) string {
+	return "t:" + value.UTC().Format(time.RFC3339Nano)
+}
+
+// durationHash returns the hash key of a duration.
+func durationHash(value time.Duration) string {
+	return "d:" + strconv.FormatInt(int64(value), 10)
+}
@@ -0,0 +1,373 @@
+package kubesql
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind identifies the lexical class of a token.
+type tokenKind int
+
+const (
+	tokenIdent       tokenKind = iota // Bare identifier or keyword, e.g. name, SELECT
+	tokenQuotedIdent                  // Backtick or double quoted identifier, e.g. `from`
+	tokenString                       // Single quoted string literal, e.g. 'it''s'
+	tokenNumber                       // Number with an optional unit suffix, e.g. 10, 1.5, 512Mi
+	tokenOperator                     // Operator, e.g. =, !=, <=, *
+	tokenComma                        // ,
+	tokenDot                          // .
+	tokenLParen                       // (
+	tokenRParen                       // )
+	tokenLBracket                     // [
+	tokenRBracket                     // ]
+	tokenSemicolon                    // ; separating statements in a script
+	tokenColon                        // : separating clusters from the resource in FROM
+	tokenComment                      // -- line comment or /* block comment */
+)
+
+// token is a single lexical element of a query.
+type token struct {
+	kind  tokenKind // The lexical class of the token
+	text  string    // The token exactly as written in the query
+	value string    // The unquoted and unescaped value for quoted tokens, text otherwise
+	pos   int       // Byte offset of the token in the query
+}
+
+// end returns the offset just past the end of the token.
+func (t token) end() int {
+	return t.pos + len(t.text)
+}
+
+// isKeyword reports whether the token is the given bare keyword, ignoring case.
+// Quoted identifiers are never keywords.
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+// twoCharOperators lists the operators made of two characters.
+var twoCharOperators = []string{"<=", ">=", "<>", "!=", "==", "~=", "!~"}
+
+// tokenize splits a query into tokens. Whitespace and comments separate
+// tokens and are otherwise ignored. Errors are returned as *ParseError values
+// positioned in the given input.
+func tokenize(input string) ([]token, error) {
+	tokens, _, err := tokenizeWithComments(input)
+	return tokens, err
+}
+
+// tokenizeWithComments works like tokenize, and also returns the comments
+// found in the input as tokenComment tokens.
+func tokenizeWithComments(input string) ([]token, []token, error) {
+	var tokens, comments []token
+
+	for i := 0; i < len(input); {
+		char, size := utf8.DecodeRuneInString(input[i:])
+
+		switch {
+		case unicode.IsSpace(char):
+			i += size
+			continue
+		case char == '\'' || char == '"' || char == '`':
+			tok, err := scanQuoted(input, i)
+			if err != nil {
+				return nil, nil, err
+			}
+			tokens = append(tokens, tok)
+			i = tok.end()
+			continue
+		case isIdentStart(char):
+			end := i + size
+			for end < len(input) {
+				next, nextSize := utf8.DecodeRuneInString(input[end:])
+				// A line comment may follow an identifier without a space
+				if !isIdentPart(next) || strings.HasPrefix(input[end:], "--") {
+					break
+				}
+				end += nextSize
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: input[i:end], value: input[i:end], pos: i})
+			i = end
+			continue
+		case char >= '0' && char <= '9':
+			end := scanNumber(input, i)
+			tokens = append(tokens, token{kind: tokenNumber, text: input[i:end], value: input[i:end], pos: i})
+			i = end
+			continue
+		case strings.HasPrefix(input[i:], "--"):
+			end := strings.IndexByte(input[i:], '\n')
+			if end < 0 {
+				end = len(input) - i
+			}
+			text := strings.TrimRight(input[i:i+end], "\r")
+			comments = append(comments, token{kind: tokenComment, text: text, value: text, pos: i})
+			i += end
+			continue
+		case strings.HasPrefix(input[i:], "/*"):
+			end := strings.Index(input[i+2:], "*/")
+			if end < 0 {
+				return nil, nil, &ParseError{
+					Pos:     positionAt(input, i),
+					Message: "unterminated block comment",
+				}
+			}
+			text := input[i : i+end+4]
+			comments = append(comments, token{kind: tokenComment, text: text, value: text, pos: i})
+			i += len(text)
+			continue
+		}
+
+		kind, length := tokenOperator, 0
+		switch char {
+		case ',':
+			kind, length = tokenComma, 1
+		case '.':
+			kind, length = tokenDot, 1
+		case '(':
+			kind, length = tokenLParen, 1
+		case ')':
+			kind, length = tokenRParen, 1
+		case '[':
+			kind, length = tokenLBracket, 1
+		case ']':
+			kind, length = tokenRBracket, 1
+		case ';':
+			kind, length = tokenSemicolon, 1
+		case ':':
+			kind, length = tokenColon, 1
+		default:
+			for _, op := range twoCharOperators {
+				if strings.HasPrefix(input[i:], op) {
+					length = len(op)
+					break
+				}
+			}
+			if length == 0 && strings.ContainsRune("=<>+-*/%!", char) {
+				length = 1
+			}
+		}
+
+		if length == 0 {
+			return nil, nil, &ParseError{
+				Pos:     positionAt(input, i),
+				Message: fmt.Sprintf("unexpected character %q", char),
+			}
+		}
+
+		text := input[i : i+length]
+		tokens = append(tokens, token{kind: kind, text: text, value: text, pos: i})
+		i += length
+	}
+
+	return tokens, comments, nil
+}
+
+// isIdentStart reports whether char can start a bare identifier.
+func isIdentStart(char rune) bool {
+	return char == '_' || unicode.IsLetter(char)
+}
+
+// isIdentPart reports whether char can continue a bare identifier.
+// Dashes are allowed so that Kubernetes names such as my-app need no quoting.
+func isIdentPart(char rune) bool {
+	return char == '_' || char == '-' || unicode.IsLetter(char) || unicode.IsDigit(char)
+}
+
+// scanNumber returns the end offset of the number starting at start.
+// Numbers may have a fraction, an exponent and a unit suffix (e.g. 512Mi, 5m).
+func scanNumber(input string, start int) int {
+	isDigit := func(i int) bool { return i < len(input) && input[i] >= '0' && input[i] <= '9' }
+	isLetter := func(i int) bool {
+		return i < len(input) && (input[i] >= 'a' && input[i] <= 'z' || input[i] >= 'A' && input[i] <= 'Z')
+	}
+
+	i := start
+	for isDigit(i) {
+		i++
+	}
+	if i < len(input) && input[i] == '.' && isDigit(i+1) {
+		i++
+		for isDigit(i) {
+			i++
+		}
+	}
+	if i < len(input) && (input[i] == 'e' || input[i] == 'E') {
+		exp := i + 1
+		if exp < len(input) && (input[exp] == '+' || input[exp] == '-') {
+			exp++
+		}
+		if isDigit(exp) {
+			i = exp
+			for isDigit(i) {
+				i++
+			}
+		}
+	}
+	for isLetter(i) {
+		i++
+	}
+	return i
+}
+
+// scanQuoted scans a quoted string or identifier starting at start.
+// The closing quote can be escaped either by doubling it or with a
+// backslash, which also escapes itself and introduces \n, \t and \r:
+//
+//	'it''s'
+//	'it\'s'
+func scanQuoted(input string, start int) (token, error) {
+	quote := input[start]
+	var value strings.Builder
+
+	for i := start + 1; i < len(input); i++ {
+		char := input[i]
+		switch {
+		case char == '\\' && i+1 < len(input):
+			i++
+			switch input[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'r':
+				value.WriteByte('\r')
+			default:
+				value.WriteByte(input[i])
+			}
+		case char == quote && i+1 < len(input) && input[i+1] == quote:
+			value.WriteByte(quote)
+			i++
+		case char == quote:
+			kind := tokenQuotedIdent
+			if quote == '\'' {
+				kind = tokenString
+			}
+			return token{kind: kind, text: input[start : i+1], value: value.String(), pos: start}, nil
+		default:
+			value.WriteByte(char)
+		}
+	}
+
+	what := "quoted identifier"
+	if quote == '\'' {
+		what = "string literal"
+	}
+	return token{}, &ParseError{
+		Pos:     positionAt(input, start),
+		Message: fmt.Sprintf("unterminated %s", what),
+	}
+}
+
+// joinTokens renders tokens with a single space wherever the
+// original text had whitespace between them. Quoted tokens are kept verbatim.
+func joinTokens(tokens []token) string {
+	var result strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && tok.pos > tokens[i-1].end() {
+			result.WriteByte(' ')
+		}
+		result.WriteString(tok.text)
+	}
+	return result.String()
+}
+
+// upperBooleans returns tokens with the boolean literals in upper case, as
+// expressions write them. Names next to a dot, slash or colon, such as
+// labels.true, keep their case.
+func upperBooleans(tokens []token) []token {
+	var result []token
+	for i, tok := range tokens {
+		if !tok.isKeyword("TRUE") && !tok.isKeyword("FALSE") || tok.text == strings.ToUpper(tok.text) {
+			continue
+		}
+		if i > 0 && isPathSeparator(tokens[i-1]) || i+1 < len(tokens) && isPathSeparator(tokens[i+1]) {
+			continue
+		}
+		if result == nil {
+			result = slices.Clone(tokens)
+		}
+		result[i].text = strings.ToUpper(tok.text)
+		result[i].value = result[i].text
+	}
+	if result == nil {
+		return tokens
+	}
+	return result
+}
+
+// isPathSeparator reports whether a token separates the parts of a field
+// path or resource name.
+func isPathSeparator(tok token) bool {
+	return tok.kind == tokenDot || tok.kind == tokenColon || tok.kind == tokenOperator && tok.text == "/"
+}
+
+// splitTokens splits tokens on every top level token of the given kind,
+// ignoring separators nested inside parentheses or brackets. Empty parts,
+// including a trailing one, are kept so callers can report them.
+func splitTokens(tokens []token, separator tokenKind) [][]token {
+	var parts [][]token
+	depth := 0
+	start := 0
+
+	for i, tok := range tokens {
+		switch {
+		case tok.kind == tokenLParen || tok.kind == tokenLBracket:
+			depth++
+		case tok.kind == tokenRParen || tok.kind == tokenRBracket:
+			// A stray closing parenthesis must not hide the separators that follow it
+			if depth > 0 {
+				depth--
+			}
+		case tok.kind == separator && depth == 0:
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+
+	if len(tokens) > 0 {
+		parts = append(parts, tokens[start:])
+	}
+	return parts
+}
+
+// splitWords groups tokens into words, runs of tokens written without
+// whitespace between them.
+func splitWords(tokens []token) [][]token {
+	var words [][]token
+	for i, tok := range tokens {
+		if i == 0 || tok.pos > tokens[i-1].end() {
+			words = append(words, nil)
+		}
+		words[len(words)-1] = append(words[len(words)-1], tok)
+	}
+	return words
+}
+
+// checkParentheses verifies that parentheses and brackets are balanced.
+// It returns the offending token and an error if they are not.
+func checkParentheses(tokens []token) (token, error) {
+	var open []token
+
+	for _, tok := range tokens {
+		switch tok.kind {
+		case tokenLParen, tokenLBracket:
+			open = append(open, tok)
+		case tokenRParen, tokenRBracket:
+			expected := tokenLParen
+			if tok.kind == tokenRBracket {
+				expected = tokenLBracket
+			}
+			if len(open) == 0 || open[len(open)-1].kind != expected {
+				return tok, fmt.Errorf("unexpected '%s' without matching opening bracket", tok.text)
+			}
+			open = open[:len(open)-1]
+		}
+	}
+
+	if len(open) > 0 {
+		return open[len(open)-1], fmt.Errorf("unclosed '%s'", open[len(open)-1].text)
+	}
+	return token{}, nil
+}
@@ -0,0 +1,222 @@
+package kubesql
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	testCases := []struct {
+		input    string
+		kinds    []tokenKind
+		expected []string // Token values
+	}{
+		{
+			"SELECT name FROM pods",
+			[]tokenKind{tokenIdent, tokenIdent, tokenIdent, tokenIdent},
+			[]string{"SELECT", "name", "FROM", "pods"},
+		},
+		{
+			"metadata.labels.`app.kubernetes.io/name`",
+			[]tokenKind{tokenIdent, tokenDot, tokenIdent, tokenDot, tokenQuotedIdent},
+			[]string{"metadata", ".", "labels", ".", "app.kubernetes.io/name"},
+		},
+		{
+			`"from", "say \"hi\"", "a""b"`,
+			[]tokenKind{tokenQuotedIdent, tokenComma, tokenQuotedIdent, tokenComma, tokenQuotedIdent},
+			[]string{"from", ",", `say "hi"`, ",", `a"b`},
+		},
+		{
+			`'it''s', 'it\'s', 'a\\b\n', 'x, y'`,
+			[]tokenKind{tokenString, tokenComma, tokenString, tokenComma, tokenString, tokenComma, tokenString},
+			[]string{"it's", ",", "it's", ",", "a\\b\n", ",", "x, y"},
+		},
+		{
+			"replicas>=2 AND memory<512Mi AND cpu != 1.5e3",
+			[]tokenKind{tokenIdent, tokenOperator, tokenNumber, tokenIdent, tokenIdent, tokenOperator,
+				tokenNumber, tokenIdent, tokenIdent, tokenOperator, tokenNumber},
+			[]string{"replicas", ">=", "2", "AND", "memory", "<", "512Mi", "AND", "cpu", "!=", "1.5e3"},
+		},
+		{
+			"COUNT(*), spec.containers[0].image",
+			[]tokenKind{tokenIdent, tokenLParen, tokenOperator, tokenRParen, tokenComma, tokenIdent, tokenDot,
+				tokenIdent, tokenLBracket, tokenNumber, tokenRBracket, tokenDot, tokenIdent},
+			[]string{"COUNT", "(", "*", ")", ",", "spec", ".", "containers", "[", "0", "]", ".", "image"},
+		},
+		{
+			"my-namespace/pods",
+			[]tokenKind{tokenIdent, tokenOperator, tokenIdent},
+			[]string{"my-namespace", "/", "pods"},
+		},
+		{
+			"SELECT name -- the name\nFROM /* all */ pods;",
+			[]tokenKind{tokenIdent, tokenIdent, tokenIdent, tokenIdent, tokenSemicolon},
+			[]string{"SELECT", "name", "FROM", "pods", ";"},
+		},
+		{
+			"'x -- y', `/* z */`, `a--b`",
+			[]tokenKind{tokenString, tokenComma, tokenQuotedIdent, tokenComma, tokenQuotedIdent},
+			[]string{"x -- y", ",", "/* z */", ",", "a--b"},
+		},
+		{
+			"SELECT name-- c\nFROM pods--all pods\nWHERE ns = my-ns-- x",
+			[]tokenKind{tokenIdent, tokenIdent, tokenIdent, tokenIdent, tokenIdent, tokenIdent, tokenOperator, tokenIdent},
+			[]string{"SELECT", "name", "FROM", "pods", "WHERE", "ns", "=", "my-ns"},
+		},
+		{"", nil, nil},
+	}
+
+	for _, tc := range testCases {
+		tokens, err := tokenize(tc.input)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+
+		if len(tokens) != len(tc.expected) {
+			t.Errorf("For input '%s', expected %d tokens, got %d: %+v",
+				tc.input, len(tc.expected), len(tokens), tokens)
+			continue
+		}
+
+		for i, tok := range tokens {
+			if tok.kind != tc.kinds[i] || tok.value != tc.expected[i] {
+				t.Errorf("For input '%s', token %d: expected %d %q, got %d %q",
+					tc.input, i, tc.kinds[i], tc.expected[i], tok.kind, tok.value)
+			}
+			if tok.text != tc.input[tok.pos:tok.end()] {
+				t.Errorf("For input '%s', token %d: text %q does not match its position", tc.input, i, tok.text)
+			}
+		}
+	}
+}
+
+func TestTokenizeErrors(t *testing.T) {
+	testCases := []struct {
+		input  string
+		offset int
+	}{
+		{"name = 'abc", 7},
+		{"`abc", 0},
+		{`"abc\"`, 0},
+		{"name = 'it''s", 7},
+		{"name # 1", 5},
+		{"name = @", 7},
+		{"name /* open", 5},
+	}
+
+	for _, tc := range testCases {
+		_, err := tokenize(tc.input)
+		if err == nil {
+			t.Errorf("For input '%s', expected error but got none", tc.input)
+			continue
+		}
+
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("For input '%s', expected *ParseError, got %T", tc.input, err)
+			continue
+		}
+		if parseErr.Pos.Offset != tc.offset {
+			t.Errorf("For input '%s', expected error at offset %d, got %d (%v)",
+				tc.input, tc.offset, parseErr.Pos.Offset, err)
+		}
+	}
+}
+
+func TestSplitTokens(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"a, b, c", []string{"a", "b", "c"}},
+		{"func(a, b), c", []string{"func(a, b)", "c"}},
+		{"nested(func(a, b), c), d", []string{"nested(func(a, b), c)", "d"}},
+		{"simple", []string{"simple"}},
+		{"", []string{}}, // Empty string should return empty slice
+		{"a,b,c", []string{"a", "b", "c"}},
+		{"func(a,b,c),d", []string{"func(a,b,c)", "d"}},
+		{"'a, b', c", []string{"'a, b'", "c"}},
+		{"`x,y`, 'it''s, here'", []string{"`x,y`", "'it''s, here'"}},
+		{"a), b(", []string{"a)", "b("}},
+		{"a,", []string{"a", ""}},
+	}
+
+	for _, tc := range testCases {
+		tokens, err := tokenize(tc.input)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+
+		result := splitTokens(tokens, tokenComma)
+		if len(result) != len(tc.expected) {
+			t.Errorf("For input '%s', expected %d parts, got %d",
+				tc.input, len(tc.expected), len(result))
+			continue
+		}
+
+		for i, part := range result {
+			if joinTokens(part) != tc.expected[i] {
+				t.Errorf("For input '%s', part %d: expected '%s', got '%s'",
+					tc.input, i, tc.expected[i], joinTokens(part))
+			}
+		}
+	}
+}
+
+func TestCheckParentheses(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int
+	}{
+		{"func(a, b), c", -1},
+		{"nested(func(a, b), c)", -1},
+		{"name = '('", -1},
+		{"items[0]", -1},
+		{"a), b(", 1},
+		{"(a, b", 0},
+		{"(a]", 2},
+		{"", -1},
+	}
+
+	for _, tc := range testCases {
+		tokens, err := tokenize(tc.input)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+
+		tok, err := checkParentheses(tokens)
+		if tc.expected == -1 {
+			if err != nil {
+				t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			}
+			continue
+		}
+		if err == nil || tok.pos != tc.expected {
+			t.Errorf("For input '%s', expected error at offset %d, got %d (%v)", tc.input, tc.expected, tok.pos, err)
+		}
+	}
+}
+
+func TestTokenizeComments(t *testing.T) {
+	input := "-- leading\r\nSELECT name /* inline */ FROM pods -- trailing"
+	_, comments, err := tokenizeWithComments(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []token{
+		{kind: tokenComment, text: "-- leading", pos: 0},
+		{kind: tokenComment, text: "/* inline */", pos: 24},
+		{kind: tokenComment, text: "-- trailing", pos: 47},
+	}
+	if len(comments) != len(expected) {
+		t.Fatalf("Expected %d comments, got %+v", len(expected), comments)
+	}
+	for i, comment := range comments {
+		if comment.kind != expected[i].kind || comment.text != expected[i].text || comment.pos != expected[i].pos {
+			t.Errorf("Comment %d: expected %+v, got %+v", i, expected[i], comment)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package kubesql
+
+import "strings"
+
+// querySource locates the clauses of a query in the source text of its
+// statement, to report where the validator found problems.
+type querySource struct {
+	text       string             // Source text of the statement, see Query.Source
+	pos        Position           // Location of the statement
+	clauses    map[string][]token // Tokens of the clauses by name, e.g. WHERE, ON or UNION
+	ctes       [][]token          // Tokens of the common table expression queries, in order
+	operands   [][]token          // Tokens of the queries following the set operations, in order
+	subqueries [][]token          // Tokens of the subqueries of the WHERE clause, in order
+}
+
+// newQuerySource locates the clauses of a query in its source text, nil
+// when the query has none or was changed after it was parsed.
+func newQuerySource(q *Query) *querySource {
+	if q.Source == "" {
+		return nil
+	}
+	if parsed, err := NewParser(q.Source).Parse(); err != nil || parsed.String() != q.String() {
+		return nil
+	}
+	tokens, err := tokenize(q.Source)
+	if err != nil {
+		return nil
+	}
+	return (&querySource{text: q.Source, pos: q.Pos}).nested(tokens)
+}
+
+// nested locates the clauses of a query written by tokens of the statement.
+// A query nested in a compound query is the first one, with the ORDER BY
+// and LIMIT clauses of the combined rows.
+func (s *querySource) nested(tokens []token) *querySource {
+	n := &querySource{text: s.text, pos: s.pos, clauses: make(map[string][]token)}
+	if len(tokens) > 0 && tokens[0].isKeyword(ExplainKeyword) {
+		tokens = tokens[1:]
+	}
+
+	if len(tokens) > 0 && tokens[0].isKeyword(WithKeyword) {
+		i := 1
+		for i+2 < len(tokens) && tokens[i+2].kind == tokenLParen {
+			end := closingParen(tokens, i+2)
+			n.ctes = append(n.ctes, tokens[i+3:end])
+			i = end + 1
+			if i >= len(tokens) || tokens[i].kind != tokenComma {
+				break
+			}
+			i++
+		}
+		i = min(i, len(tokens))
+		n.clauses[WithKeyword], tokens = tokens[:i], tokens[i:]
+	}
+
+	if ops := setOperations(tokens); len(ops) > 0 {
+		var trailing []token
+		for k, i := range ops {
+			start, end := i+1, len(tokens)
+			if start < end && tokens[start].isKeyword(AllKeyword) {
+				start++
+			}
+			if k+1 < len(ops) {
+				end = ops[k+1]
+			}
+			operand := tokens[start:end]
+			if j, _ := sortClause(operand); j >= 0 {
+				operand, trailing = operand[:j], operand[j:]
+			}
+			n.operands = append(n.operands, operand)
+			if name := strings.ToUpper(tokens[i].text); n.clauses[name] == nil {
+				n.clauses[name] = tokens[i : len(tokens)-len(trailing)]
+			}
+		}
+		tokens = append(tokens[:ops[0]:ops[0]], trailing...)
+	}
+
+	p := &Parser{query: s.text, source: s.text}
+	sections, err := p.splitIntoSections(tokens)
+	if err != nil {
+		return n
+	}
+	for name, section := range sections {
+		n.clauses[name] = section.tokens
+	}
+	n.clauses[OnKeyword] = n.clauses[FromKeyword]
+	n.subqueries = subqueryTokens(n.clauses[WhereKeyword])
+	return n
+}
+
+// cte locates the clauses of the query of the i-th common table
+// expression, nil when unknown.
+func (s *querySource) cte(i int) *querySource {
+	if s == nil {
+		return nil
+	}
+	return s.part(s.ctes, i)
+}
+
+// operand locates the clauses of the query following the i-th set
+// operation, nil when unknown.
+func (s *querySource) operand(i int) *querySource {
+	if s == nil {
+		return nil
+	}
+	return s.part(s.operands, i)
+}
+
+// subquery locates the clauses of the i-th subquery of the WHERE clause,
+// nil when unknown.
+func (s *querySource) subquery(i int) *querySource {
+	if s == nil {
+		return nil
+	}
+	return s.part(s.subqueries, i)
+}
+
+// part locates the clauses of the i-th of parts, nil when unknown.
+func (s *querySource) part(parts [][]token, i int) *querySource {
+	if i >= len(parts) || len(parts[i]) == 0 {
+		return nil
+	}
+	return s.nested(parts[i])
+}
+
+// find returns the first tokens of a clause written like text, nil if none
+// are. Identifiers and keywords match regardless of case and quoting.
+func (s *querySource) find(clause, text string) []token {
+	if s == nil {
+		return nil
+	}
+	want, err := tokenize(text)
+	if err != nil || len(want) == 0 {
+		return nil
+	}
+	tokens := s.clauses[clause]
+	for i := 0; i+len(want) <= len(tokens); i++ {
+		if sameTokens(tokens[i:i+len(want)], want) {
+			return tokens[i : i+len(want)]
+		}
+	}
+	return nil
+}
+
+// locate returns the start and end of the first tokens of a clause written
+// like text, or of the whole clause when none are. Both are zero when the
+// clause is unknown.
+func (s *querySource) locate(clause, text string) (Position, Position) {
+	if s == nil || len(s.clauses[clause]) == 0 {
+		return Position{}, Position{}
+	}
+	tokens := s.find(clause, text)
+	if tokens == nil {
+		tokens = s.clauses[clause]
+	}
+	return s.position(tokens[0].pos), s.position(tokens[len(tokens)-1].end())
+}
+
+// within converts an offset in text, written by tokens of the statement,
+// into a position in the query or script text.
+func (s *querySource) within(tokens []token, text string, offset int) Position {
+	written, _ := tokenize(text)
+	k := 0
+	for k+1 < len(written) && k+1 < len(tokens) && written[k+1].pos <= offset {
+		k++
+	}
+	delta := min(max(offset-written[k].pos, 0), len(tokens[k].text))
+	return s.position(tokens[k].pos + delta)
+}
+
+// position converts an offset in the statement into a position in the
+// query or script text.
+func (s *querySource) position(offset int) Position {
+	pos := positionAt(s.text, offset)
+	if pos.Line == 1 {
+		pos.Column += s.pos.Column - 1
+	}
+	pos.Line += s.pos.Line - 1
+	pos.Offset += s.pos.Offset
+	return pos
+}
+
+// sameTokens reports whether two token lists of the same length write the
+// same query text.
+func sameTokens(a, b []token) bool {
+	for i := range a {
+		x, y := a[i], b[i]
+		switch {
+		case isIdentToken(x) && isIdentToken(y):
+			if !strings.EqualFold(x.value, y.value) {
+				return false
+			}
+		case x.kind != y.kind || x.value != y.value:
+			return false
+		}
+	}
+	return true
+}
+
+// isIdentToken reports whether a token is a bare or quoted identifier.
+func isIdentToken(tok token) bool {
+	return tok.kind == tokenIdent || tok.kind == tokenQuotedIdent
+}
+
+// closingParen returns the index of the token closing the parenthesis at
+// tokens[i], or len(tokens) when it is not closed.
+func closingParen(tokens []token, i int) int {
+	depth := 0
+	for ; i < len(tokens); i++ {
+		switch tokens[i].kind {
+		case tokenLParen, tokenLBracket:
+			depth++
+		case tokenRParen, tokenRBracket:
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// subqueryTokens returns the tokens of the subqueries of a condition,
+// without their parentheses, in order. Nested subqueries are not included.
+func subqueryTokens(tokens []token) [][]token {
+	var result [][]token
+	for i := 0; i+1 < len(tokens); i++ {
+		next := tokens[i+1]
+		if tokens[i].kind != tokenLParen || !next.isKeyword(SelectKeyword) && !next.isKeyword(FromKeyword) && !next.isKeyword(WithKeyword) {
+			continue
+		}
+		end := closingParen(tokens, i)
+		result = append(result, tokens[i+1:end])
+		i = end
+	}
+	return result
+}
@@ -0,0 +1,238 @@
+package kubesql
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+// Placeholder replaces the literal values of a normalized query.
+const Placeholder = "?"
+
+// placeholderExpr stands for one or more literal values.
+type placeholderExpr struct{}
+
+func (*placeholderExpr) exprNode() {}
+
+func (*placeholderExpr) String() string { return Placeholder }
+
+// Normalize returns the canonical form of a query, grouping queries that
+// differ only in literal values, spacing, keyword and function case, field
+// aliases, parentheses, or the order of the operands of AND, OR, = and !=.
+// Literal values are replaced by ?, and lists of literals by a single ?,
+// so the WHERE, ON, SELECT and ORDER BY clauses of the result do not parse.
+// Comments and positions are dropped.
+func Normalize(q *Query) (*Query, error) {
+	return normalizeQuery(q, true)
+}
+
+// Fingerprint returns a stable hash of the normalized form of a query, e.g.
+// to group the queries of a log. Queries differing only in literal values
+// share their fingerprint.
+func Fingerprint(q *Query) (string, error) {
+	normalized, err := Normalize(q)
+	if err != nil {
+		return "", err
+	}
+	return hashQuery(normalized), nil
+}
+
+// CacheKey returns a stable hash of the canonical form of a query, keeping
+// its literal values, e.g. to cache query results. Queries returning the
+// same results for the same objects may share their key.
+func CacheKey(q *Query) (string, error) {
+	canonical, err := normalizeQuery(q, false)
+	if err != nil {
+		return "", err
+	}
+	return hashQuery(canonical), nil
+}
+
+// hashQuery returns the hex encoded SHA-256 hash of a query.
+func hashQuery(q *Query) string {
+	sum := sha256.Sum256([]byte(q.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeQuery returns the canonical form of a query, replacing its
+// literals with placeholders when placeholders is set.
+func normalizeQuery(q *Query, placeholders bool) (*Query, error) {
+	s, err := NewStatement(q)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeStatement(s, nil, placeholders).Query(), nil
+}
+
+// normalizeStatement returns the canonical form of a statement, or of a
+// subquery seeing the tables of enclosing queries named outer.
+func normalizeStatement(s *Statement, outer []string, placeholders bool) *Statement {
+	result := &Statement{From: s.From, Limit: s.Limit, Explain: s.Explain}
+	for _, cte := range s.With {
+		result.With = append(result.With, &CommonTable{Name: cte.Name, Query: normalizeStatement(cte.Query, nil, placeholders)})
+	}
+
+	// Conditions and sort keys read the same with or without table names
+	var names, scope []string
+	if tables, err := queryTables(s.Query()); err == nil {
+		names, scope = scopeNames(tables, outer), visibleNames(tables, outer)
+	}
+	for _, join := range s.Joins {
+		result.Joins = append(result.Joins, &JoinClause{
+			Type: join.Type,
+			From: join.From,
+			On:   normalizeExpr(qualify(join.On, names), placeholders, true, scope),
+		})
+	}
+
+	aliases := make(map[string]bool)
+	if s.Select != nil {
+		result.Select = &SelectClause{}
+		for _, field := range s.Select.Fields {
+			// Fields keep their alias, which names the result column
+			result.Select.Fields = append(result.Select.Fields, &SelectItem{
+				Expr:  normalizeExpr(field.Expr, placeholders, false, scope),
+				Alias: field.Alias,
+			})
+			if field.Alias != "" {
+				aliases[field.Alias] = true
+			}
+		}
+	}
+
+	if s.Where != nil {
+		result.Where = &WhereClause{Cond: normalizeExpr(qualify(s.Where.Cond, names), placeholders, true, scope)}
+	}
+
+	for _, c := range s.Compound {
+		result.Compound = append(result.Compound, &CompoundQuery{Op: c.Op, All: c.All, Query: normalizeStatement(c.Query, nil, placeholders)})
+	}
+
+	if s.OrderBy != nil {
+		result.OrderBy = &OrderByClause{}
+		for _, key := range s.OrderBy.Keys {
+			// Keys naming a SELECT alias, or sorting combined rows, refer
+			// to a column, not to a field
+			expr := key.Expr
+			ref, ok := expr.(*FieldRef)
+			resolve := len(s.Compound) == 0 && (!ok || len(ref.Path) != 1 || !aliases[ref.Path[0].Name])
+			if resolve {
+				expr = qualify(expr, names)
+			}
+			direction := strings.ToUpper(key.Direction)
+			if direction == "" {
+				direction = "ASC"
+			}
+			result.OrderBy.Keys = append(result.OrderBy.Keys, &OrderByItem{
+				Expr:      normalizeExpr(expr, placeholders, resolve, scope),
+				Direction: direction,
+			})
+		}
+	}
+	return result
+}
+
+// normalizeExpr returns the canonical form of an expression, with only the
+// parentheses the precedence of operators requires, sorted operands of
+// commutative operators, and lower case function names. Literals become
+// placeholders when placeholders is set, and field aliases are expanded
+// when resolve is set. Subqueries see the tables named scope.
+func normalizeExpr(expr Expr, placeholders, resolve bool, scope []string) Expr {
+	return groupExpr(canonicalExpr(expr, placeholders, resolve, scope))
+}
+
+// canonicalExpr returns the canonical form of an expression, without any
+// parentheses.
+func canonicalExpr(expr Expr, placeholders, resolve bool, scope []string) Expr {
+	normalize := func(expr Expr) Expr {
+		return canonicalExpr(expr, placeholders, resolve, scope)
+	}
+
+	switch e := expr.(type) {
+	case *ParenExpr:
+		return normalize(e.X)
+	case *FieldRef:
+		if resolve {
+			return &FieldRef{Path: resolveAlias(e.Path)}
+		}
+		return e
+	case *Literal:
+		if placeholders {
+			return &placeholderExpr{}
+		}
+		return e
+	case *UnaryExpr:
+		x := normalize(e.X)
+		if _, ok := x.(*placeholderExpr); ok && e.Op == "-" {
+			// Negative numbers are literals too
+			return x
+		}
+		return &UnaryExpr{Op: e.Op, X: x}
+	case *BinaryExpr:
+		if e.Op == "AND" || e.Op == "OR" {
+			var operands []Expr
+			for _, operand := range logicOperands(e, e.Op) {
+				operands = append(operands, normalize(operand))
+			}
+			sortExprs(operands)
+			return chain(e.Op, operands)
+		}
+		x, y := normalize(e.X), normalize(e.Y)
+		if (e.Op == "=" || e.Op == "!=") && exprLess(y, x) {
+			x, y = y, x
+		}
+		return &BinaryExpr{Op: e.Op, X: x, Y: y}
+	case *InExpr:
+		if e.Subquery != nil {
+			return &InExpr{X: normalize(e.X), Subquery: normalizeStatement(e.Subquery, scope, placeholders), Not: e.Not}
+		}
+		list := normalizeList(e.List, placeholders, resolve, scope)
+		return &InExpr{X: normalize(e.X), List: list, Not: e.Not}
+	case *ExistsExpr:
+		return &ExistsExpr{Subquery: normalizeStatement(e.Subquery, scope, placeholders)}
+	case *BetweenExpr:
+		return &BetweenExpr{X: normalize(e.X), Low: normalize(e.Low), High: normalize(e.High), Not: e.Not}
+	case *IsNullExpr:
+		return &IsNullExpr{X: normalize(e.X), Not: e.Not}
+	case *CallExpr:
+		args := make([]Expr, len(e.Args))
+		for i, arg := range e.Args {
+			args[i] = normalize(arg)
+		}
+		return &CallExpr{Func: strings.ToLower(e.Func), Args: args}
+	}
+	return expr
+}
+
+// normalizeList normalizes the items of an IN list. The items are sorted
+// and deduplicated, and a list of literals becomes a single placeholder.
+func normalizeList(list []Expr, placeholders, resolve bool, scope []string) []Expr {
+	var items []Expr
+	seen := make(map[string]bool)
+	for _, item := range list {
+		item = canonicalExpr(item, placeholders, resolve, scope)
+		if !seen[item.String()] {
+			seen[item.String()] = true
+			items = append(items, item)
+		}
+	}
+	sortExprs(items)
+	return items
+}
+
+// sortExprs sorts expressions with exprLess.
+func sortExprs(exprs []Expr) {
+	sort.SliceStable(exprs, func(i, j int) bool {
+		return exprLess(exprs[i], exprs[j])
+	})
+}
+
+// exprLess orders expressions by their text, constants last, so that
+// phase = 'a' and 'a' = phase both become phase = 'a'.
+func exprLess(x, y Expr) bool {
+	if isConstant(x) != isConstant(y) {
+		return isConstant(y)
+	}
+	return x.String() < y.String()
+}
@@ -0,0 +1,91 @@
+package kubesql
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"select name from pods where name = 'a'", "SELECT name FROM pods WHERE metadata.name = ?"},
+		{"SELECT name FROM default/pods WHERE 'Running' = status.phase AND labels.app = 'web'", "SELECT name FROM default/pods WHERE metadata.labels.app = ? AND status.phase = ?"},
+		{"FROM pods WHERE (b = 1 OR a = 2) AND (c IN (1, 2, 3))", "FROM pods WHERE (a = ? OR b = ?) AND c IN (?)"},
+		{"FROM pods WHERE replicas > -1 AND NOT (a = 1 AND b = 2)", "FROM pods WHERE NOT (a = ? AND b = ?) AND replicas > ?"},
+		{"FROM pods WHERE (replicas + 1) * 2 > LEN(spec.containers)", "FROM pods WHERE (replicas + ?) * ? > len(spec.containers)"},
+		{"FROM pods WHERE a - (b - c) = 0 AND x IN (y, 1, y)", "FROM pods WHERE a - (b - c) = ? AND x IN (y, ?)"},
+		{"SELECT name, replicas * 2 AS double FROM pods ORDER BY double, created DESC LIMIT 5", "SELECT name, replicas * ? AS double FROM pods ORDER BY double ASC, metadata.creationTimestamp DESC LIMIT 5"},
+		{"FROM pods WHERE name NOT LIKE 'a%' AND created BETWEEN now() - 1h AND now()", "FROM pods WHERE NOT metadata.name LIKE ? AND metadata.creationTimestamp BETWEEN now() - ? AND now()"},
+		{"SELECT p.name FROM pods p WHERE p.name = 'a' ORDER BY p.created", "SELECT p.name FROM pods p WHERE metadata.name = ? ORDER BY metadata.creationTimestamp ASC"},
+		{"SELECT name FROM pods p left outer join nodes n on n.name = spec.nodeName WHERE n.labels.role = 'x'", "SELECT name FROM pods p LEFT JOIN nodes n ON n.metadata.name = p.spec.nodeName WHERE n.metadata.labels.role = ?"},
+		{"FROM pods WHERE spec.nodeName IN (select name from nodes where spec.unschedulable = true and name in ('a', 'b'))", "FROM pods WHERE spec.nodeName IN (SELECT name FROM nodes WHERE nodes.metadata.name IN (?) AND nodes.spec.unschedulable = ?)"},
+		{"FROM pods p WHERE NOT EXISTS (SELECT name FROM nodes WHERE name = p.spec.nodeName)", "FROM pods p WHERE NOT EXISTS (SELECT name FROM nodes WHERE nodes.metadata.name = p.spec.nodeName)"},
+		{"with w as (select name from nodes where name = 'a') select name from w where name = 'b'", "WITH w AS (SELECT name FROM nodes WHERE metadata.name = ?) SELECT name FROM w WHERE metadata.name = ?"},
+		{"select name from pods where name = 'a' union all select name from nodes where labels.role = 'x' order by name limit 3", "SELECT name FROM pods WHERE metadata.name = ? UNION ALL SELECT name FROM nodes WHERE metadata.labels.role = ? ORDER BY name ASC LIMIT 3"},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		normalized, err := Normalize(query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if normalized.String() != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, normalized.String())
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	testCases := []struct {
+		x, y     string
+		sameKey  bool
+		sameHash bool
+	}{
+		{"SELECT name FROM pods WHERE name = 'a'", "select  name from pods where 'a' = metadata.name -- comment", true, true},
+		{"SELECT name FROM pods WHERE name = 'a'", "SELECT name FROM pods WHERE name = 'b'", false, true},
+		{"FROM pods WHERE a = 1 AND (b = 2 OR c = 3)", "FROM pods WHERE (c = 3 OR b = 2) AND a = 1", true, true},
+		{"FROM pods WHERE phase IN ('a', 'b')", "FROM pods WHERE phase IN ('b', 'a', 'c')", false, true},
+		{"FROM pods WHERE phase IN ('a', 'b')", "FROM pods WHERE phase IN ('b', 'a', 'a')", true, true},
+		{"SELECT name FROM pods", "SELECT name AS n FROM pods", false, false},
+		{"FROM pods WHERE a = 1", "FROM pods WHERE a != 1", false, false},
+		{"FROM pods WHERE a < 1", "FROM pods WHERE 1 < a", false, false},
+		{"FROM pods LIMIT 1", "FROM pods LIMIT 2", false, false},
+		{"FROM pods", "FROM deployments", false, false},
+		{"FROM pods WHERE a IN (SELECT b FROM nodes WHERE c = 1)", "FROM pods WHERE a IN (SELECT b FROM nodes WHERE c = 2)", false, true},
+	}
+
+	for _, tc := range testCases {
+		x, _ := NewParser(tc.x).Parse()
+		y, _ := NewParser(tc.y).Parse()
+		xHash, err1 := Fingerprint(x)
+		yHash, err2 := Fingerprint(y)
+		xKey, err3 := CacheKey(x)
+		yKey, err4 := CacheKey(y)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
+			t.Errorf("For input '%s' and '%s', expected no error", tc.x, tc.y)
+			continue
+		}
+		if len(xHash) != 64 || len(xKey) != 64 {
+			t.Errorf("For input '%s', expected 64 hex digits, got '%s' and '%s'", tc.x, xHash, xKey)
+		}
+		if (xHash == yHash) != tc.sameHash {
+			t.Errorf("For input '%s' and '%s', expected same fingerprint %v", tc.x, tc.y, tc.sameHash)
+		}
+		if (xKey == yKey) != tc.sameKey {
+			t.Errorf("For input '%s' and '%s', expected same cache key %v", tc.x, tc.y, tc.sameKey)
+		}
+	}
+
+	// Fingerprints are stable, as they are stored by their users
+	query, _ := NewParser("SELECT name FROM pods WHERE name = 'a'").Parse()
+	expected := "f2aab08d69d26b1b6b0f6ed026544d6e948f24eb6926e97b89ce7ded1f1c50fc"
+	if hash, _ := Fingerprint(query); hash != expected {
+		t.Errorf("Expected fingerprint %s, got %s", expected, hash)
+	}
+}
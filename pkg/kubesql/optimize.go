@@ -0,0 +1,638 @@
+package kubesql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// maxOptimizerPasses bounds the passes over the rules, in case rules keep
+// undoing each other.
+const maxOptimizerPasses = 10
+
+// Rule is a rewrite rule of the Optimizer. Rules must keep the results of
+// the query unchanged.
+type Rule interface {
+	// Name identifies the rule, e.g. "or-to-in".
+	Name() string
+
+	// Rewrite rewrites the query in place and reports whether it changed it.
+	Rewrite(q *Query) (bool, error)
+}
+
+// ConditionRule is a Rule rewriting the WHERE condition node by node,
+// children first. Func returns the node to use instead of expr, and false
+// when it leaves expr unchanged. A condition rewritten to TRUE is dropped.
+type ConditionRule struct {
+	RuleName string
+	Func     func(expr Expr) (Expr, bool)
+}
+
+// Name implements Rule.
+func (r *ConditionRule) Name() string {
+	return r.RuleName
+}
+
+// Rewrite implements Rule.
+func (r *ConditionRule) Rewrite(q *Query) (bool, error) {
+	return rewriteWhere(q, func(where Expr) Expr {
+		return rewriteExpr(where, r.Func)
+	})
+}
+
+// rewriteWhere replaces the WHERE condition of a query with the result of f.
+// A condition rewritten to TRUE is dropped.
+func rewriteWhere(q *Query, f func(where Expr) Expr) (bool, error) {
+	if q.Where == "" {
+		return false, nil
+	}
+	where, err := ParseExpr(q.Where)
+	if err != nil {
+		return false, fmt.Errorf("error parsing %s clause: %w", WhereKeyword, err)
+	}
+
+	where = f(where)
+	result := TSLQuery(where.String())
+	if value, ok := boolLiteral(where); ok && value {
+		result = ""
+	}
+	if result == q.Where {
+		return false, nil
+	}
+	q.Where = result
+	return true, nil
+}
+
+// QueryRule is a Rule rewriting the clauses of a query with Func, which
+// reports whether it changed the query.
+type QueryRule struct {
+	RuleName string
+	Func     func(q *Query) (bool, error)
+}
+
+// Name implements Rule.
+func (r *QueryRule) Name() string {
+	return r.RuleName
+}
+
+// Rewrite implements Rule.
+func (r *QueryRule) Rewrite(q *Query) (bool, error) {
+	return r.Func(q)
+}
+
+// DefaultRules returns the rules of the optimizer: constant folding, De
+// Morgan's laws, flattening of nested AND and OR, OR of equalities to IN,
+// removal of redundant ORDER BY keys, and of sorting for LIMIT 0.
+func DefaultRules() []Rule {
+	return []Rule{
+		&ConditionRule{RuleName: "constant-folding", Func: foldConstants},
+		&ConditionRule{RuleName: "de-morgan", Func: pushNot},
+		&QueryRule{RuleName: "flatten-logic", Func: flattenWhere},
+		&ConditionRule{RuleName: "or-to-in", Func: orToIn},
+		&QueryRule{RuleName: "redundant-order-by", Func: removeRedundantOrderBy},
+		&QueryRule{RuleName: "limit-zero-sort", Func: removeLimitZeroSort},
+	}
+}
+
+// Optimizer simplifies queries with rewrite rules, e.g. so that more of the
+// WHERE condition can be sent to the API server as selectors.
+type Optimizer struct {
+	Rules []Rule // Rules applied in order on each pass
+}
+
+// NewOptimizer creates an optimizer with the default rules followed by the
+// given rules.
+func NewOptimizer(rules ...Rule) *Optimizer {
+	return &Optimizer{Rules: append(DefaultRules(), rules...)}
+}
+
+// Optimize rewrites a copy of q, applying the rules in order until none of
+// them changes the query, and so do its common table expressions and the
+// queries of its set operations. It returns the rewritten query and the
+// names of the rules that changed it, in the order they first applied.
+func (o *Optimizer) Optimize(q *Query) (*Query, []string, error) {
+	result := *q
+	result.With = append([]CTE(nil), q.With...)
+	result.Compound = append([]SetOperation(nil), q.Compound...)
+	result.Select = append([]SelectField(nil), q.Select...)
+	result.OrderBy = append([]OrderByField(nil), q.OrderBy...)
+	result.Joins = append([]Join(nil), q.Joins...)
+	result.Comments = append([]Comment(nil), q.Comments...)
+	result.Columns = append([]Column(nil), q.Columns...)
+
+	var applied []string
+	seen := make(map[string]bool)
+	merge := func(rules []string) {
+		for _, rule := range rules {
+			if !seen[rule] {
+				seen[rule] = true
+				applied = append(applied, rule)
+			}
+		}
+	}
+	for i, cte := range result.With {
+		cq, err := cte.parse()
+		if err != nil {
+			return nil, nil, err
+		}
+		optimized, rules, err := o.Optimize(cq)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error in common table expression '%s': %w", cte.Name, err)
+		}
+		result.With[i].Query = TSLQuery(optimized.String())
+		merge(rules)
+	}
+	for i, op := range result.Compound {
+		operand, err := op.parse()
+		if err != nil {
+			return nil, nil, err
+		}
+		optimized, rules, err := o.Optimize(operand)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error in query %d: %w", i+2, err)
+		}
+		result.Compound[i].Query = TSLQuery(optimized.String())
+		merge(rules)
+	}
+	for pass := 0; pass < maxOptimizerPasses; pass++ {
+		changed := false
+		for _, rule := range o.Rules {
+			ruleChanged, err := rule.Rewrite(&result)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error applying rule %s: %w", rule.Name(), err)
+			}
+			if ruleChanged {
+				merge([]string{rule.Name()})
+			}
+			changed = changed || ruleChanged
+		}
+		if !changed {
+			break
+		}
+	}
+	return &result, applied, nil
+}
+
+// rewriteExpr rewrites every node of an expression with f, children first,
+// including the expressions of its subqueries.
+func rewriteExpr(expr Expr, f func(Expr) (Expr, bool)) Expr {
+	return Rewrite(expr, func(node Node) Node {
+		if expr, ok := node.(Expr); ok {
+			if result, ok := f(expr); ok {
+				return result
+			}
+		}
+		return node
+	}).(Expr)
+}
+
+// isPredicate reports whether an expression always evaluates to TRUE or
+// FALSE, never to NULL or another value.
+func isPredicate(expr Expr) bool {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		switch e.Op {
+		case "+", "-", "*", "/", "%":
+			return false
+		}
+		return true
+	case *InExpr, *BetweenExpr, *IsNullExpr, *ExistsExpr:
+		return true
+	case *UnaryExpr:
+		return e.Op == "NOT" && isPredicate(e.X)
+	case *ParenExpr:
+		return isPredicate(e.X)
+	case *Literal:
+		return e.Kind == BoolLiteral
+	}
+	return false
+}
+
+// hasWildcard reports whether an expression refers to the items of a list
+// with [*], whose comparisons match when any item matches.
+func hasWildcard(expr Expr) bool {
+	found := false
+	Inspect(expr, func(node Node) bool {
+		if ref, ok := node.(*FieldRef); ok {
+			for _, segment := range ref.Path {
+				found = found || (segment.Array && segment.Index < 0)
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// isConstant reports whether an expression only uses literals.
+func isConstant(expr Expr) bool {
+	constant := true
+	Inspect(expr, func(node Node) bool {
+		switch node.(type) {
+		case *FieldRef, *StarExpr, *CallExpr, *Statement:
+			constant = false
+		}
+		return constant
+	})
+	return constant
+}
+
+// boolLiteral returns the value of a TRUE or FALSE literal.
+func boolLiteral(expr Expr) (value, ok bool) {
+	if paren, isParen := expr.(*ParenExpr); isParen {
+		return boolLiteral(paren.X)
+	}
+	literal, isLiteral := expr.(*Literal)
+	if !isLiteral || literal.Kind != BoolLiteral {
+		return false, false
+	}
+	return literal.Value == "TRUE", true
+}
+
+// newBoolLiteral returns a TRUE or FALSE literal.
+func newBoolLiteral(value bool) *Literal {
+	if value {
+		return &Literal{Kind: BoolLiteral, Value: "TRUE"}
+	}
+	return &Literal{Kind: BoolLiteral, Value: "FALSE"}
+}
+
+// foldConstants evaluates expressions made of literals, and simplifies AND
+// and OR with a TRUE or FALSE operand.
+func foldConstants(expr Expr) (Expr, bool) {
+	if e, ok := expr.(*BinaryExpr); ok && (e.Op == "AND" || e.Op == "OR") {
+		for _, pair := range [][2]Expr{{e.X, e.Y}, {e.Y, e.X}} {
+			value, ok := boolLiteral(pair[0])
+			switch {
+			case !ok:
+			case value == (e.Op == "OR") && len(subqueries(pair[1])) == 0:
+				// x OR TRUE, x AND FALSE; the resources of subqueries are
+				// still listed, so they are kept
+				return newBoolLiteral(value), true
+			case isPredicate(pair[1]):
+				// x AND TRUE, x OR FALSE
+				return pair[1], true
+			}
+		}
+	}
+
+	switch e := expr.(type) {
+	case *Literal:
+		return expr, false
+	case *UnaryExpr:
+		if literal, ok := e.X.(*Literal); ok && e.Op == "-" && literal.Kind == NumberLiteral {
+			// Negative numbers are already folded
+			return expr, false
+		}
+	}
+	if !isConstant(expr) {
+		return expr, false
+	}
+
+	value, err := newEvaluator().eval(expr, nil)
+	if err != nil {
+		return expr, false
+	}
+	switch value := value.(type) {
+	case nil:
+		return &Literal{Kind: NullLiteral, Value: "NULL"}, true
+	case bool:
+		return newBoolLiteral(value), true
+	case string:
+		return &Literal{Kind: StringLiteral, Value: value}, true
+	case float64:
+		// Quantities, durations and timestamps keep their form
+		if t, err := InferType(expr, nil); err != nil || (t != TypeInt && t != TypeFloat) || math.IsInf(value, 0) || math.IsNaN(value) {
+			return expr, false
+		}
+		literal := &Literal{Kind: NumberLiteral, Value: strconv.FormatFloat(math.Abs(value), 'f', -1, 64)}
+		if value < 0 {
+			return &UnaryExpr{Op: "-", X: literal}, true
+		}
+		return literal, true
+	}
+	return expr, false
+}
+
+// negatedComparisons maps comparison operators to their negation, for the
+// operators whose negation also holds when a side is NULL.
+var negatedComparisons = map[string]string{"=": "!=", "!=": "="}
+
+// pushNot applies De Morgan's laws and moves NOT into the conditions it
+// negates, e.g. NOT (a = 1 OR b IS NULL) becomes (a != 1 AND b IS NOT NULL).
+// Only conditions that are TRUE or FALSE are rewritten, as NOT NULL is NULL,
+// and conditions on [*] items keep their NOT, as NOT (x = 1) means no item
+// is 1 while x != 1 means some item is not 1.
+func pushNot(expr Expr) (Expr, bool) {
+	not, ok := expr.(*UnaryExpr)
+	if !ok || not.Op != "NOT" {
+		return expr, false
+	}
+	x := not.X
+	for {
+		paren, ok := x.(*ParenExpr)
+		if !ok {
+			break
+		}
+		x = paren.X
+	}
+
+	switch e := x.(type) {
+	case *BinaryExpr:
+		switch {
+		case (e.Op == "AND" || e.Op == "OR") && isPredicate(e.X) && isPredicate(e.Y):
+			op := "OR"
+			if e.Op == "OR" {
+				op = "AND"
+			}
+			x, _ := pushNot(&UnaryExpr{Op: "NOT", X: parenthesize(e.X)})
+			y, _ := pushNot(&UnaryExpr{Op: "NOT", X: parenthesize(e.Y)})
+			return &ParenExpr{X: &BinaryExpr{Op: op, X: x, Y: y}}, true
+		case negatedComparisons[e.Op] != "" && !hasWildcard(e):
+			return &BinaryExpr{Op: negatedComparisons[e.Op], X: e.X, Y: e.Y}, true
+		}
+	case *UnaryExpr:
+		if e.Op == "NOT" && isPredicate(e.X) {
+			return e.X, true
+		}
+	case *InExpr:
+		if !hasWildcard(e.X) {
+			return &InExpr{X: e.X, List: e.List, Subquery: e.Subquery, Not: !e.Not}, true
+		}
+	case *BetweenExpr:
+		if !hasWildcard(e.X) {
+			return &BetweenExpr{X: e.X, Low: e.Low, High: e.High, Not: !e.Not}, true
+		}
+	case *IsNullExpr:
+		return &IsNullExpr{X: e.X, Not: !e.Not}, true
+	}
+	return expr, false
+}
+
+// parenthesize wraps AND and OR conditions in parentheses, so they can be
+// used as an operand.
+func parenthesize(expr Expr) Expr {
+	if e, ok := expr.(*BinaryExpr); ok && (e.Op == "AND" || e.Op == "OR") {
+		return &ParenExpr{X: expr}
+	}
+	return expr
+}
+
+// logicOperands returns the operands of a chain of the AND or OR operator
+// op, looking into the parentheses that do not change its meaning.
+func logicOperands(expr Expr, op string) []Expr {
+	switch e := expr.(type) {
+	case *ParenExpr:
+		inner := e.X
+		for {
+			paren, ok := inner.(*ParenExpr)
+			if !ok {
+				break
+			}
+			inner = paren.X
+		}
+		// AND binds tighter than OR, so OR operands need no parentheses
+		if b, ok := inner.(*BinaryExpr); ok && (b.Op == op || (op == "OR" && b.Op == "AND")) {
+			if b.Op == op {
+				return logicOperands(b, op)
+			}
+			return []Expr{b}
+		}
+		if isAtom(inner) || isComparison(inner) {
+			return []Expr{inner}
+		}
+	case *BinaryExpr:
+		if e.Op == op {
+			return append(logicOperands(e.X, op), logicOperands(e.Y, op)...)
+		}
+	}
+	return []Expr{expr}
+}
+
+// isAtom reports whether an expression never needs parentheses.
+func isAtom(expr Expr) bool {
+	switch e := expr.(type) {
+	case *FieldRef, *Literal, *CallExpr, *StarExpr:
+		return true
+	case *UnaryExpr:
+		_, ok := e.X.(*Literal)
+		return e.Op == "-" && ok
+	}
+	return false
+}
+
+// isComparison reports whether an expression is a comparison, which binds
+// tighter than AND and OR.
+func isComparison(expr Expr) bool {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		switch e.Op {
+		case "AND", "OR", "+", "-", "*", "/", "%":
+			return false
+		}
+		return true
+	case *InExpr, *BetweenExpr, *IsNullExpr:
+		return true
+	}
+	return false
+}
+
+// chain joins operands with an AND or OR operator, left to right.
+func chain(op string, operands []Expr) Expr {
+	result := operands[0]
+	for _, operand := range operands[1:] {
+		result = &BinaryExpr{Op: op, X: result, Y: operand}
+	}
+	return result
+}
+
+// flattenWhere removes the parentheses of the WHERE condition that do not
+// change its meaning, e.g. (a AND (b AND c)) becomes a AND b AND c.
+func flattenWhere(q *Query) (bool, error) {
+	return rewriteWhere(q, func(where Expr) Expr {
+		where = rewriteExpr(where, flattenLogic)
+		for {
+			paren, ok := where.(*ParenExpr)
+			if !ok {
+				return where
+			}
+			where = paren.X
+		}
+	})
+}
+
+// flattenLogic flattens nested AND and OR conditions, and removes the
+// parentheses around single values.
+func flattenLogic(expr Expr) (Expr, bool) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if e.Op == "AND" || e.Op == "OR" {
+			flat := chain(e.Op, logicOperands(e, e.Op))
+			return flat, flat.String() != e.String()
+		}
+	case *ParenExpr:
+		if isAtom(e.X) {
+			return e.X, true
+		}
+		if inner, ok := e.X.(*ParenExpr); ok {
+			return inner, true
+		}
+	}
+	return expr, false
+}
+
+// orToIn turns equalities of the same field joined by OR into IN, e.g.
+// phase = 'Failed' OR phase = 'Unknown' becomes phase IN ('Failed', 'Unknown').
+func orToIn(expr Expr) (Expr, bool) {
+	e, ok := expr.(*BinaryExpr)
+	if !ok || e.Op != "OR" {
+		return expr, false
+	}
+
+	var operands []Expr
+	lists := make(map[string]*InExpr) // IN conditions by field
+	changed := false
+	for _, operand := range logicOperands(e, "OR") {
+		ref, values := fieldValues(operand)
+		if ref == nil {
+			operands = append(operands, operand)
+			continue
+		}
+
+		in, ok := lists[ref.String()]
+		if !ok {
+			in = &InExpr{X: ref}
+			lists[ref.String()] = in
+			operands = append(operands, in)
+		} else {
+			changed = true
+		}
+		for _, value := range values {
+			duplicate := false
+			for _, item := range in.List {
+				duplicate = duplicate || item.String() == value.String()
+			}
+			if !duplicate {
+				in.List = append(in.List, value)
+			}
+		}
+	}
+	if !changed {
+		return expr, false
+	}
+
+	for i, operand := range operands {
+		if in, ok := operand.(*InExpr); ok && len(in.List) == 1 {
+			operands[i] = &BinaryExpr{Op: "=", X: in.X, Y: in.List[0]}
+		}
+	}
+	return chain("OR", operands), true
+}
+
+// fieldValues returns the field and the values of a condition matching a
+// field equal to one of several literals, e.g. a = 1 or a IN (1, 2).
+func fieldValues(expr Expr) (*FieldRef, []Expr) {
+	isValue := func(expr Expr) bool {
+		literal, ok := expr.(*Literal)
+		return ok && literal.Kind != NullLiteral
+	}
+
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if e.Op != "=" {
+			return nil, nil
+		}
+		if ref, ok := e.X.(*FieldRef); ok && isValue(e.Y) {
+			return ref, []Expr{e.Y}
+		}
+		if ref, ok := e.Y.(*FieldRef); ok && isValue(e.X) {
+			return ref, []Expr{e.X}
+		}
+	case *InExpr:
+		ref, ok := e.X.(*FieldRef)
+		if !ok || e.Not || e.Subquery != nil {
+			return nil, nil
+		}
+		for _, item := range e.List {
+			if !isValue(item) {
+				return nil, nil
+			}
+		}
+		return ref, e.List
+	}
+	return nil, nil
+}
+
+// removeRedundantOrderBy removes the ORDER BY keys that can not change the
+// order of the rows: constants, keys repeating an earlier key, keys after the
+// name of objects listed from one namespace of one cluster without joins,
+// where names are unique, and every key of an aggregate query, which returns a single row.
+// The keys of set operations, sorting the combined rows, are kept.
+func removeRedundantOrderBy(q *Query) (bool, error) {
+	if len(q.OrderBy) == 0 || len(q.Compound) > 0 {
+		return false, nil
+	}
+	if selects, err := parseSelect(q); err == nil && isAggregateQuery(selects) {
+		q.OrderBy = nil
+		return true, nil
+	}
+
+	ref, err := ParseResourceRef(q.From)
+	oneCluster := err == nil && (len(ref.Clusters) == 0 || len(ref.Clusters) == 1 && !strings.Contains(ref.Clusters[0], "*"))
+	unique := oneCluster && ref.Namespace != "" && len(q.Joins) == 0
+
+	var kept []OrderByField
+	seen := make(map[string]bool)
+	for _, field := range q.OrderBy {
+		if unique && seen["metadata.name"] {
+			break
+		}
+		expr, err := ParseExpr(field.Field)
+		if err != nil {
+			kept = append(kept, field)
+			continue
+		}
+		key := orderKey(q, expr)
+		if isConstant(expr) || seen[key] {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, field)
+	}
+
+	if len(kept) == len(q.OrderBy) {
+		return false, nil
+	}
+	q.OrderBy = kept
+	return true, nil
+}
+
+// orderKey identifies what an ORDER BY key sorts by: the SELECT field of an
+// alias, or the full path of a field.
+func orderKey(q *Query, expr Expr) string {
+	if ref, ok := expr.(*FieldRef); ok {
+		if len(ref.Path) == 1 && !ref.Path[0].Array {
+			for _, field := range q.Select {
+				if field.Alias != "" && field.Alias == ref.Path[0].Name {
+					if selected, err := ParseExpr(field.Field); err == nil {
+						expr = selected
+					}
+				}
+			}
+		}
+	}
+	if ref, ok := expr.(*FieldRef); ok {
+		return (&FieldRef{Path: resolveAlias(ref.Path)}).String()
+	}
+	return expr.String()
+}
+
+// removeLimitZeroSort removes ORDER BY from queries with LIMIT 0, which
+// return no rows.
+func removeLimitZeroSort(q *Query) (bool, error) {
+	if q.Limit != 0 || len(q.OrderBy) == 0 {
+		return false, nil
+	}
+	q.OrderBy = nil
+	return true, nil
+}
@@ -0,0 +1,165 @@
+package kubesql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOptimizeWhere(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		rules    []string
+	}{
+		// Constant folding
+		{"1 + 2 * 3 = 7 AND name = 'a'", "name = 'a'", []string{"constant-folding"}},
+		{"name = 'a' AND 1 > 2", "FALSE", []string{"constant-folding"}},
+		{"name = 'a' OR 'x' = 'x'", "", []string{"constant-folding"}},
+		{"replicas > 10 - 12", "replicas > -2", []string{"constant-folding"}},
+		{"name = 'web-' + 'api'", "name = 'web-api'", []string{"constant-folding"}},
+		{"spec.cpu > 250m * 2", "spec.cpu > 250m * 2", nil},
+		{"replicas = 1 / 0", "replicas = NULL", []string{"constant-folding"}},
+		{"replicas + 0 AND TRUE", "replicas + 0 AND TRUE", nil},
+		{"created < now() - 1h", "created < now() - 1h", nil},
+
+		// De Morgan
+		{"NOT (name = 'a' AND phase IN ('x', 'y'))", "name != 'a' OR phase NOT IN ('x', 'y')", []string{"de-morgan", "flatten-logic"}},
+		{"NOT (name = 'a' OR replicas BETWEEN 1 AND 3) AND ready", "name != 'a' AND replicas NOT BETWEEN 1 AND 3 AND ready", []string{"de-morgan", "flatten-logic"}},
+		{"NOT NOT labels.app IS NULL", "labels.app IS NULL", []string{"de-morgan"}},
+		{"NOT (replicas > 1)", "NOT (replicas > 1)", nil},
+		{"NOT (spec.containers[*].name = 'a')", "NOT (spec.containers[*].name = 'a')", nil},
+		{"NOT (ready AND name = 'a')", "NOT (ready AND name = 'a')", nil},
+
+		// Flattening
+		{"(a = 1 AND (b = 2 AND c = 3))", "a = 1 AND b = 2 AND c = 3", []string{"flatten-logic"}},
+		{"a = 1 OR (b = 2 AND c = 3) OR ((d = 4))", "a = 1 OR b = 2 AND c = 3 OR d = 4", []string{"flatten-logic"}},
+		{"a = 1 AND (b = 2 OR c = 3)", "a = 1 AND (b = 2 OR c = 3)", nil},
+		{"(replicas) > (1)", "replicas > 1", []string{"constant-folding", "flatten-logic"}},
+
+		// OR to IN
+		{"phase = 'a' OR phase = 'b' OR 'c' = phase", "phase IN ('a', 'b', 'c')", []string{"or-to-in"}},
+		{"phase IN ('a', 'b') OR ready OR phase = 'b'", "phase IN ('a', 'b') OR ready", []string{"or-to-in"}},
+		{"status.phase = 'a' OR phase = 'b'", "status.phase = 'a' OR phase = 'b'", nil},
+		{"phase = 'a' OR phase = 'a'", "phase = 'a'", []string{"or-to-in"}},
+		{"phase = 'a' OR phase = NULL", "phase = 'a' OR phase = NULL", nil},
+		{"(phase = 'a' OR phase = 'b') AND ready", "phase IN ('a', 'b') AND ready", []string{"or-to-in", "flatten-logic"}},
+	}
+
+	optimizer := NewOptimizer()
+	for _, tc := range testCases {
+		query := &Query{From: "pods", Where: TSLQuery(tc.input), Limit: -1}
+		optimized, rules, err := optimizer.Optimize(query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if string(optimized.Where) != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, optimized.Where)
+		}
+		if !reflect.DeepEqual(rules, tc.rules) {
+			t.Errorf("For input '%s', expected rules %v, got %v", tc.input, tc.rules, rules)
+		}
+		if string(query.Where) != tc.input {
+			t.Errorf("For input '%s', expected the query to be unchanged, got '%s'", tc.input, query.Where)
+		}
+	}
+}
+
+func TestOptimizeOrderBy(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"SELECT name FROM pods ORDER BY name, metadata.name DESC, created", "SELECT name FROM pods ORDER BY name ASC, created ASC"},
+		{"SELECT status.phase AS phase FROM pods ORDER BY phase, status.phase, 1", "SELECT status.phase AS phase FROM pods ORDER BY phase ASC"},
+		{"SELECT count(*) FROM pods ORDER BY name", "SELECT count(*) FROM pods"},
+		{"SELECT name FROM pods ORDER BY name LIMIT 0", "SELECT name FROM pods LIMIT 0"},
+		{"SELECT name FROM pods ORDER BY name, namespace LIMIT 1", "SELECT name FROM pods ORDER BY name ASC, namespace ASC LIMIT 1"},
+		{"SELECT name FROM default/pods ORDER BY created, name, namespace", "SELECT name FROM default/pods ORDER BY created ASC, name ASC"},
+		{"SELECT cluster, name FROM prod:default/pods ORDER BY name, cluster", "SELECT cluster, name FROM prod:default/pods ORDER BY name ASC"},
+		{"SELECT cluster, name FROM prod-*:kube-system/pods ORDER BY name, cluster", "SELECT cluster, name FROM prod-*:kube-system/pods ORDER BY name ASC, cluster ASC"},
+		{"SELECT cluster, name FROM prod,staging:default/pods ORDER BY name, cluster", "SELECT cluster, name FROM prod,staging:default/pods ORDER BY name ASC, cluster ASC"},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		optimized, _, err := NewOptimizer().Optimize(query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if optimized.String() != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, optimized.String())
+		}
+	}
+}
+
+func TestOptimizeSemantics(t *testing.T) {
+	objects := []map[string]any{
+		{"metadata": map[string]any{"name": "a", "labels": map[string]any{"app": "web"}}, "spec": map[string]any{"replicas": 1.0}},
+		{"metadata": map[string]any{"name": "b"}, "spec": map[string]any{"replicas": 3.0}},
+		{"metadata": map[string]any{"name": "c", "labels": map[string]any{"app": "api"}}},
+	}
+	conditions := []string{
+		"NOT (labels.app = 'web' OR spec.replicas IN (1, 2))",
+		"NOT (labels.app IS NULL AND name BETWEEN 'a' AND 'b')",
+		"labels.app = 'web' OR labels.app = 'api' OR name = 'b'",
+		"NOT NOT (spec.replicas > 2) OR 1 = 2",
+		"NOT (labels.app != 'web' AND (name = 'c' OR name = 'b'))",
+	}
+
+	for _, condition := range conditions {
+		optimized, _, err := NewOptimizer().Optimize(&Query{From: "pods", Where: TSLQuery(condition), Limit: -1})
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", condition, err)
+		}
+		before, _ := ParseExpr(TSLQuery(condition))
+		after, _ := ParseExpr(optimized.Where)
+		for _, object := range objects {
+			want, err1 := newEvaluator().eval(before, object)
+			got, err2 := newEvaluator().eval(after, object)
+			if err1 != nil || err2 != nil || truthy(want) != truthy(got) {
+				t.Errorf("For input '%s', expected '%s' to match the same objects, got %v and %v", condition, optimized.Where, want, got)
+			}
+		}
+	}
+}
+
+type limitRule struct{}
+
+func (limitRule) Name() string {
+	return "default-limit"
+}
+
+func (limitRule) Rewrite(q *Query) (bool, error) {
+	if q.Limit >= 0 {
+		return false, nil
+	}
+	q.Limit = 100
+	return true, nil
+}
+
+func TestOptimizerRules(t *testing.T) {
+	optimizer := NewOptimizer(limitRule{})
+	query, _ := NewParser("SELECT name FROM pods WHERE (name = 'a')").Parse()
+	optimized, rules, err := optimizer.Optimize(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if optimized.String() != "SELECT name FROM pods WHERE name = 'a' LIMIT 100" {
+		t.Errorf("Expected a custom rule to apply, got '%s'", optimized)
+	}
+	if !reflect.DeepEqual(rules, []string{"flatten-logic", "default-limit"}) {
+		t.Errorf("Expected rules [flatten-logic default-limit], got %v", rules)
+	}
+
+	query, _ = NewParser("SELECT name FROM pods WHERE name = 'a'").Parse()
+	query.Where = "name = = 'a'"
+	if _, _, err := optimizer.Optimize(query); err == nil || !strings.Contains(err.Error(), "constant-folding") {
+		t.Errorf("Expected an error naming the rule, got %v", err)
+	}
+}
@@ -4,79 +4,433 @@
 package kubesql
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"unicode"
 )
 
 // NewParser creates a new parser instance for the given KubeSQL query string.
 func NewParser(query string) *Parser {
-	return &Parser{query: strings.TrimSpace(query)}
+	trimmed := strings.TrimLeftFunc(query, unicode.IsSpace)
+	return &Parser{
+		query:  strings.TrimSpace(query),
+		source: query,
+		base:   len(query) - len(trimmed),
+	}
 }
 
 // Parse parses the KubeSQL query into structured components.
+// The query may end with a semicolon; use ParseScript for several statements.
+// Syntax errors are reported as *ParseError values carrying the error position.
 func (p *Parser) Parse() (*Query, error) {
+	tokens, comments, err := p.tokenize()
+	if err != nil {
+		return nil, err
+	}
+
+	statements := splitStatements(tokens, len(p.query))
+	if len(statements) > 1 {
+		return nil, p.errorf(statements[1].tokens[0].pos,
+			"unexpected statement after ';', use ParseScript to parse multiple statements")
+	}
+
+	var stmt statement
+	if len(statements) == 1 {
+		stmt = statements[0]
+	}
+
+	result, err := p.parseStatement(stmt.tokens)
+	if err != nil {
+		return nil, err
+	}
+	result.Comments = p.comments(comments)
+
+	return result, nil
+}
+
+// tokenize splits the parser query into tokens and comments, reporting
+// lexical errors at their position in the original query text.
+func (p *Parser) tokenize() ([]token, []token, error) {
+	// Quoted strings and identifiers are kept as single tokens so their
+	// content can not be mistaken for keywords
+	tokens, comments, err := tokenizeWithComments(p.query)
+	if err != nil {
+		var lexErr *ParseError
+		if errors.As(err, &lexErr) {
+			return nil, nil, p.errorf(lexErr.Pos.Offset, "%s", lexErr.Message)
+		}
+		return nil, nil, p.errorf(0, "%v", err)
+	}
+	return tokens, comments, nil
+}
+
+// parseStatement parses the tokens of a single statement, without its
+// terminating semicolon.
+func (p *Parser) parseStatement(tokens []token) (*Query, error) {
 	result := &Query{
 		Limit: DefaultLimit, // -1 indicates no limit
 	}
 
-	// Normalize the query by removing extra whitespace
-	normalizedQuery := p.normalizeQuery(p.query)
+	end := len(p.query)
+	if len(tokens) > 0 {
+		result.Pos = p.position(tokens[0].pos)
+		end = tokens[len(tokens)-1].end()
+		result.Source = p.query[tokens[0].pos:end]
+	} else {
+		result.Pos = p.position(0)
+	}
+
+	// EXPLAIN modifies the whole statement
+	if len(tokens) > 0 && tokens[0].isKeyword(ExplainKeyword) {
+		result.Explain = true
+		tokens = tokens[1:]
+	}
+
+	// Reject unbalanced parentheses before splitting on them
+	if tok, err := checkParentheses(tokens); err != nil {
+		return nil, p.errorf(tok.pos, "%v", err)
+	}
+
+	// WITH names the queries the statement reads like resources
+	if len(tokens) > 0 && tokens[0].isKeyword(WithKeyword) {
+		var err error
+		if result.With, tokens, err = p.parseWith(tokens); err != nil {
+			return nil, err
+		}
+		if len(tokens) == 0 {
+			return nil, p.errorf(end, "expected a query after the %s clause", WithKeyword)
+		}
+	}
 
-	// Split into sections using regex
-	sections, err := p.splitIntoSections(normalizedQuery)
+	// UNION, INTERSECT and EXCEPT combine the rows of the first query with
+	// the ones of the queries following them
+	compound, tokens, err := p.parseCompound(tokens)
+	if err != nil {
+		return nil, err
+	}
+	result.Compound = compound
+
+	// Split into sections on the top level clause keywords
+	sections, err := p.splitIntoSections(tokens)
 	if err != nil {
 		return nil, err
 	}
 
 	// Parse each section with detailed error handling
 	if selectClause, exists := sections[SelectKeyword]; exists {
-		result.Select, err = p.parseSelectClause(selectClause)
+		result.Select, err = p.parseSelectClause(selectClause.text)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing SELECT clause: %w", err)
+			return nil, p.clauseError(selectClause.pos, SelectKeyword, err)
 		}
 	}
 
 	if fromClause, exists := sections[FromKeyword]; exists {
-		result.From, err = p.parseFromClause(fromClause)
+		result.From, result.FromAlias, result.Joins, err = p.parseFromClause(fromClause.text)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing FROM clause: %w", err)
+			return nil, p.clauseError(fromClause.pos, FromKeyword, err)
 		}
 	} else {
-		return nil, fmt.Errorf("FROM clause is mandatory")
+		return nil, p.missingFromError(tokens, end)
 	}
 
 	if whereClause, exists := sections[WhereKeyword]; exists {
-		result.Where = TSLQuery(p.parseWhereClause(whereClause))
+		where, err := p.parseWhereClause(whereClause.text)
+		if err != nil {
+			return nil, p.clauseError(whereClause.pos, WhereKeyword, err)
+		}
+		result.Where = TSLQuery(where)
 	}
 
 	if orderByClause, exists := sections[OrderByKeyword]; exists {
-		result.OrderBy, err = p.parseOrderByClause(orderByClause)
+		result.OrderBy, err = p.parseOrderByClause(orderByClause.text)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing ORDER BY clause: %w", err)
+			return nil, p.clauseError(orderByClause.pos, OrderByKeyword, err)
 		}
 	}
 
 	if limitClause, exists := sections[LimitKeyword]; exists {
-		result.Limit, err = p.parseLimitClause(limitClause)
+		result.Limit, err = p.parseLimitClause(limitClause.text)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing LIMIT clause: %w", err)
+			return nil, p.clauseError(limitClause.pos, LimitKeyword, err)
 		}
 	}
 
 	return result, nil
 }
 
+// parseWith parses the common table expressions of a WITH clause, e.g.
+// "WITH a AS (SELECT ...), b AS (FROM a ...)", and returns them with the
+// tokens of the query following them.
+func (p *Parser) parseWith(tokens []token) ([]CTE, []token, error) {
+	var ctes []CTE
+	i := 1
+	for {
+		if i >= len(tokens) {
+			return nil, nil, p.errorf(tokens[i-1].end(), "expected the name of a common table expression")
+		}
+		tok := tokens[i]
+		if (tok.kind != tokenIdent || isReservedKeyword(tok.text)) && tok.kind != tokenQuotedIdent {
+			return nil, nil, p.errorf(tok.pos, "expected the name of a common table expression, found '%s'", tok.text)
+		}
+		if slices.ContainsFunc(ctes, func(cte CTE) bool { return strings.EqualFold(cte.Name, tok.value) }) {
+			return nil, nil, p.errorf(tok.pos, "common table expression '%s' is defined twice", tok.value)
+		}
+		if i+1 >= len(tokens) || !tokens[i+1].isKeyword("AS") {
+			return nil, nil, p.errorf(tok.end(), "expected AS after '%s'", tok.text)
+		}
+		if i+2 >= len(tokens) || tokens[i+2].kind != tokenLParen {
+			return nil, nil, p.errorf(tokens[i+1].end(), "expected '(' after AS")
+		}
+
+		// Parentheses are balanced, find the one closing the query
+		start, end := i+3, i+3
+		for depth := 1; ; end++ {
+			if kind := tokens[end].kind; kind == tokenLParen || kind == tokenLBracket {
+				depth++
+			} else if kind == tokenRParen || kind == tokenRBracket {
+				if depth--; depth == 0 {
+					break
+				}
+			}
+		}
+		if start == end {
+			return nil, nil, p.errorf(tokens[end].pos, "common table expression '%s' is empty", tok.value)
+		}
+		q, err := p.parseCTE(tokens[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		ctes = append(ctes, CTE{Name: tok.value, Query: TSLQuery(q.String())})
+
+		i = end + 1
+		if i >= len(tokens) || tokens[i].kind != tokenComma {
+			return ctes, tokens[i:], nil
+		}
+		i++
+	}
+}
+
+// parseCTE parses the query of a common table expression, reporting errors
+// at their position in the statement.
+func (p *Parser) parseCTE(tokens []token) (*Query, error) {
+	q, err := p.parseInner(tokens)
+	if err != nil {
+		return nil, err
+	}
+	switch pos := tokens[0].pos; {
+	case q.Explain:
+		return nil, p.errorf(pos, "unexpected %s in a common table expression", ExplainKeyword)
+	case len(q.With) > 0:
+		return nil, p.errorf(pos, "unexpected %s in a common table expression, define every common table expression in the WITH clause of the statement", WithKeyword)
+	case len(q.Compound) > 0:
+		return nil, p.errorf(pos, "unexpected %s in a common table expression", q.Compound[0].Op)
+	}
+	return q, nil
+}
+
+// parseCompound parses the queries that UNION, INTERSECT and EXCEPT
+// combine with the first query of a statement, e.g. "FROM a UNION ALL
+// FROM b ORDER BY name". It returns them with the tokens of the first query
+// followed by the ORDER BY and LIMIT clauses of the last one, which apply
+// to the combined rows.
+func (p *Parser) parseCompound(tokens []token) ([]SetOperation, []token, error) {
+	ops := setOperations(tokens)
+	if len(ops) == 0 {
+		return nil, tokens, nil
+	}
+
+	first := tokens[:ops[0]]
+	if len(first) == 0 {
+		return nil, nil, p.errorf(tokens[0].pos, "expected a query before %s", strings.ToUpper(tokens[0].text))
+	}
+	if i, name := sortClause(first); i >= 0 {
+		return nil, nil, p.errorf(first[i].pos, "%s clause must follow the last query combined by %s, it applies to the combined rows",
+			name, strings.ToUpper(tokens[ops[0]].text))
+	}
+
+	var compound []SetOperation
+	var trailing []token
+	for k, i := range ops {
+		op, keyword, start := SetOperation{Op: strings.ToUpper(tokens[i].text)}, tokens[i], i+1
+		if start < len(tokens) && tokens[start].isKeyword(AllKeyword) {
+			op.All, keyword = true, tokens[start]
+			start++
+		}
+		end := len(tokens)
+		if k+1 < len(ops) {
+			end = ops[k+1]
+		}
+		operand := tokens[start:end]
+		if j, name := sortClause(operand); j >= 0 {
+			if k+1 < len(ops) {
+				return nil, nil, p.errorf(operand[j].pos, "%s clause must follow the last query combined by %s, it applies to the combined rows",
+					name, strings.ToUpper(tokens[end].text))
+			}
+			operand, trailing = operand[:j], operand[j:]
+		}
+		if len(operand) == 0 {
+			return nil, nil, p.errorf(keyword.end(), "expected a query after %s", op.keyword())
+		}
+
+		q, err := p.parseInner(operand)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch pos := operand[0].pos; {
+		case q.Explain:
+			return nil, nil, p.errorf(pos, "unexpected %s after %s, %s goes before the first query", ExplainKeyword, op.keyword(), ExplainKeyword)
+		case len(q.With) > 0:
+			return nil, nil, p.errorf(pos, "unexpected %s after %s, define common table expressions before the first query", WithKeyword, op.keyword())
+		}
+		op.Query = TSLQuery(q.String())
+		compound = append(compound, op)
+	}
+	return compound, append(first[:len(first):len(first)], trailing...), nil
+}
+
+// setOperations returns the indexes of the top level set operation
+// keywords of a statement.
+func setOperations(tokens []token) []int {
+	var ops []int
+	depth := 0
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokenLParen, tokenLBracket:
+			depth++
+		case tokenRParen, tokenRBracket:
+			depth--
+		}
+		if depth == 0 && isSetOperation(tok) {
+			ops = append(ops, i)
+		}
+	}
+	return ops
+}
+
+// isSetOperation reports whether a token is a set operation keyword.
+func isSetOperation(tok token) bool {
+	return tok.isKeyword(UnionKeyword) || tok.isKeyword(IntersectKeyword) || tok.isKeyword(ExceptKeyword)
+}
+
+// sortClause returns the index of the first top level ORDER BY or LIMIT
+// keyword of a query, with the clause name, or -1 when there is none.
+func sortClause(tokens []token) (int, string) {
+	depth := 0
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokenLParen, tokenLBracket:
+			depth++
+		case tokenRParen, tokenRBracket:
+			depth--
+		}
+		if name, _ := clauseKeywordAt(tokens, i); depth == 0 && (name == OrderByKeyword || name == LimitKeyword) {
+			return i, name
+		}
+	}
+	return -1, ""
+}
+
+// parseInner parses a query nested in the statement, reporting errors at
+// their position in the statement.
+func (p *Parser) parseInner(tokens []token) (*Query, error) {
+	first, last := tokens[0], tokens[len(tokens)-1]
+	inner := &Parser{query: p.query[first.pos:last.end()], source: p.source, base: p.base + first.pos}
+	q, err := inner.Parse()
+	if err != nil {
+		return nil, err
+	}
+	q.Pos, q.Comments, q.Source = Position{}, nil, ""
+	return q, nil
+}
+
+// missingFromError reports a statement without a FROM clause. A word that
+// looks like a misspelled FROM keyword is pointed at, e.g. FORM.
+func (p *Parser) missingFromError(tokens []token, end int) *ParseError {
+	depth := 0
+	for _, tok := range tokens {
+		switch tok.kind {
+		case tokenLParen, tokenLBracket:
+			depth++
+		case tokenRParen, tokenRBracket:
+			depth--
+		case tokenIdent:
+			if suggestion, ok := closestMatch(strings.ToUpper(tok.text), []string{FromKeyword}); ok && depth == 0 {
+				parseErr := p.errorf(tok.pos, "FROM clause is mandatory, did you mean '%s' instead of '%s'?", suggestion, tok.text)
+				parseErr.Suggestion = suggestion
+				return parseErr
+			}
+		}
+	}
+	return p.errorf(end, "FROM clause is mandatory")
+}
+
+// position converts an offset in the parsed query into a position in the
+// original query text.
+func (p *Parser) position(offset int) Position {
+	return positionAt(p.source, p.base+offset)
+}
+
+// errorf builds a ParseError for an offset in the parsed query, reporting
+// its position in the original query text.
+func (p *Parser) errorf(offset int, format string, args ...any) *ParseError {
+	return &ParseError{
+		Pos:     p.position(offset),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// clauseError reports an error returned by a clause parser at offset,
+// keeping the suggestion of misspelled keywords.
+func (p *Parser) clauseError(offset int, clause string, err error) *ParseError {
+	parseErr := p.errorf(offset, "error parsing %s clause: %v", clause, err)
+	parseErr.Suggestion = suggestionOf(err)
+	return parseErr
+}
+
+// comments converts comment tokens into Comments positioned in the original
+// query text.
+func (p *Parser) comments(tokens []token) []Comment {
+	var comments []Comment
+	for _, tok := range tokens {
+		comments = append(comments, Comment{Text: tok.text, Pos: p.position(tok.pos)})
+	}
+	return comments
+}
+
+// keyword returns the keywords of a set operation, e.g. "UNION ALL".
+func (op SetOperation) keyword() string {
+	if op.All {
+		return op.Op + " " + AllKeyword
+	}
+	return op.Op
+}
+
 // String returns a string representation of the parsed query.
 // It reconstructs the KubeSQL syntax from the parsed components.
 func (q *Query) String() string {
 	var parts []string
 
+	if q.Explain {
+		parts = append(parts, ExplainKeyword)
+	}
+
+	// Add WITH clause if present
+	if len(q.With) > 0 {
+		ctes := make([]string, len(q.With))
+		for i, cte := range q.With {
+			ctes[i] = fmt.Sprintf("%s AS (%s)", quoteIdentifier(cte.Name), cte.Query)
+		}
+		parts = append(parts, WithKeyword+" "+strings.Join(ctes, ", "))
+	}
+
 	// Build SELECT clause
 	if len(q.Select) > 0 {
 		var selectParts []string
 		for _, field := range q.Select {
 			if field.Alias != "" {
-				selectParts = append(selectParts, fmt.Sprintf("%s AS %s", field.Field, field.Alias))
+				selectParts = append(selectParts, fmt.Sprintf("%s AS %s", field.Field, quoteIdentifier(field.Alias)))
 			} else {
 				selectParts = append(selectParts, string(field.Field))
 			}
@@ -84,9 +438,22 @@ func (q *Query) String() string {
 		parts = append(parts, fmt.Sprintf("SELECT %s", strings.Join(selectParts, ", ")))
 	}
 
-	// Add FROM clause (required)
+	// Add FROM clause (required) with its joins
 	if q.From != "" {
 		parts = append(parts, fmt.Sprintf("FROM %s", q.From))
+		if q.FromAlias != "" {
+			parts = append(parts, quoteIdentifier(q.FromAlias))
+		}
+	}
+	for _, join := range q.Joins {
+		if join.Type == LeftJoin {
+			parts = append(parts, LeftJoin)
+		}
+		parts = append(parts, fmt.Sprintf("JOIN %s", join.From))
+		if join.Alias != "" {
+			parts = append(parts, quoteIdentifier(join.Alias))
+		}
+		parts = append(parts, fmt.Sprintf("ON %s", join.On))
 	}
 
 	// Add WHERE clause if present
@@ -94,6 +461,11 @@ func (q *Query) String() string {
 		parts = append(parts, fmt.Sprintf("WHERE %s", q.Where))
 	}
 
+	// Add the combined queries
+	for _, op := range q.Compound {
+		parts = append(parts, op.keyword(), string(op.Query))
+	}
+
 	// Add ORDER BY clause if present
 	if len(q.OrderBy) > 0 {
 		var orderParts []string
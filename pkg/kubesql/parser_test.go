@@ -1,6 +1,9 @@
 package kubesql
 
 import (
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -94,3 +97,423 @@ func TestQueryString(t *testing.T) {
 		t.Errorf("Expected: %s\nGot: %s", expected, reconstructed)
 	}
 }
+
+func TestParseErrorPosition(t *testing.T) {
+	testCases := []struct {
+		query  string
+		line   int
+		column int
+	}{
+		{"SELECT name FROM pods ORDER BY name UP", 1, 32},
+		{"SELECT name\nFROM pods\nLIMIT abc", 3, 7},
+		{"  SELECT a), b( FROM pods", 1, 11},
+		{"SELECT name FROM pods WHERE name = 'x", 1, 36},
+		{"SELECT name, namespace", 1, 23},
+		{"FROM pods LIMIT 5 WHERE a = 1", 1, 19},
+		{"SELEC name FROM pods", 1, 1},
+	}
+
+	for _, tc := range testCases {
+		_, err := NewParser(tc.query).Parse()
+		if err == nil {
+			t.Errorf("For input '%s', expected error but got none", tc.query)
+			continue
+		}
+
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("For input '%s', expected *ParseError, got %T", tc.query, err)
+			continue
+		}
+
+		if parseErr.Pos.Line != tc.line || parseErr.Pos.Column != tc.column {
+			t.Errorf("For input '%s', expected error at %d:%d, got %s (%v)",
+				tc.query, tc.line, tc.column, parseErr.Pos, err)
+		}
+	}
+}
+
+func TestParseQuotedIdentifiersAndStrings(t *testing.T) {
+	query := "SELECT `from`, \"my label\" AS \"pod name\", concat(name, ', ') AS label " +
+		"FROM pods WHERE `order` = 'it''s' AND note = 'a from b limit 3' ORDER BY `from` DESC"
+	parser := NewParser(query)
+
+	result, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+
+	expectedSelect := []SelectField{
+		{Field: "`from`"},
+		{Field: `"my label"`, Alias: "pod name"},
+		{Field: "concat(name, ', ')", Alias: "label"},
+	}
+	if len(result.Select) != len(expectedSelect) {
+		t.Fatalf("Expected %d SELECT fields, got: %+v", len(expectedSelect), result.Select)
+	}
+	for i, field := range result.Select {
+		if field != expectedSelect[i] {
+			t.Errorf("SELECT field %d: expected %+v, got %+v", i, expectedSelect[i], field)
+		}
+	}
+
+	if result.Where != "`order` = 'it''s' AND note = 'a from b limit 3'" {
+		t.Errorf("Unexpected WHERE: %s", result.Where)
+	}
+
+	if len(result.OrderBy) != 1 || result.OrderBy[0].Field != "`from`" || result.OrderBy[0].Direction != "DESC" {
+		t.Errorf("Unexpected ORDER BY: %+v", result.OrderBy)
+	}
+
+	expected := "SELECT `from`, \"my label\" AS `pod name`, concat(name, ', ') AS label " +
+		"FROM pods WHERE `order` = 'it''s' AND note = 'a from b limit 3' ORDER BY `from` DESC"
+	if result.String() != expected {
+		t.Errorf("Expected: %s\nGot: %s", expected, result.String())
+	}
+}
+
+func TestParseClauseOrder(t *testing.T) {
+	invalidQueries := []string{
+		"SELECT name FROM pods WHERE a = 1 WHERE b = 2",
+		"SELECT name FROM pods LIMIT 5 ORDER BY name",
+		"WHERE a = 1 FROM pods",
+		"SELECT name FROM pods WHERE",
+		"SELECT name FROM pods extra words",
+		"SELECT name AS FROM pods",
+		"SELECT name FROM pods AS",
+		"SELECT name FROM pods p JOIN nodes n",
+		"SELECT name FROM pods p JOIN nodes n ON",
+		"SELECT name FROM pods p JOIN ON p.a = p.b",
+		"SELECT name FROM pods p JOIN nodes p ON p.a = p.b",
+		"SELECT name FROM pods JOIN kube-system/pods ON a = b",
+		"SELECT name FROM JOIN nodes ON a = b",
+		"SELECT name FROM pods OUTER JOIN nodes ON a = b",
+		"SELECT name FROM pods p LEFT nodes n ON a = b",
+	}
+
+	for _, query := range invalidQueries {
+		_, err := NewParser(query).Parse()
+		if err == nil {
+			t.Errorf("For input '%s', expected error but got none", query)
+		}
+	}
+}
+
+func TestParseJoin(t *testing.T) {
+	testCases := []struct {
+		input     string
+		from      string
+		fromAlias string
+		joins     []Join
+		output    string
+	}{
+		{
+			input:     "SELECT p.name, n.name FROM pods p JOIN nodes n ON p.spec.nodeName = n.name",
+			from:      "pods",
+			fromAlias: "p",
+			joins:     []Join{{Type: "INNER", From: "nodes", Alias: "n", On: "p.spec.nodeName = n.name"}},
+			output:    "SELECT p.name, n.name FROM pods p JOIN nodes n ON p.spec.nodeName = n.name",
+		},
+		{
+			input:     "SELECT * FROM default/pods AS p inner join nodes AS n ON (p.spec.nodeName = n.name) WHERE p.name = 'a'",
+			from:      "default/pods",
+			fromAlias: "p",
+			joins:     []Join{{Type: "INNER", From: "nodes", Alias: "n", On: "(p.spec.nodeName = n.name)"}},
+			output:    "SELECT * FROM default/pods p JOIN nodes n ON (p.spec.nodeName = n.name) WHERE p.name = 'a'",
+		},
+		{
+			input: "FROM pods LEFT OUTER JOIN nodes ON spec.nodeName = nodes.name LEFT JOIN services s ON s.namespace = namespace ORDER BY name",
+			from:  "pods",
+			joins: []Join{
+				{Type: "LEFT", From: "nodes", On: "spec.nodeName = nodes.name"},
+				{Type: "LEFT", From: "services", Alias: "s", On: "s.namespace = namespace"},
+			},
+			output: "FROM pods LEFT JOIN nodes ON spec.nodeName = nodes.name LEFT JOIN services s ON s.namespace = namespace ORDER BY name ASC",
+		},
+		{
+			input:     "SELECT join(p.spec.containers[*].name) FROM pods p JOIN `my pods` ON join(p.labels[*]) = `my pods`.name",
+			from:      "pods",
+			fromAlias: "p",
+			joins:     []Join{{Type: "INNER", From: "`my pods`", On: "join(p.labels[*]) = `my pods`.name"}},
+			output:    "SELECT join(p.spec.containers[*].name) FROM pods p JOIN `my pods` ON join(p.labels[*]) = `my pods`.name",
+		},
+		{
+			input:     "SELECT name FROM pods `select`",
+			from:      "pods",
+			fromAlias: "select",
+			output:    "SELECT name FROM pods `select`",
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if result.From != tc.from || result.FromAlias != tc.fromAlias {
+			t.Errorf("For input '%s', expected FROM '%s' alias '%s', got '%s' alias '%s'", tc.input, tc.from, tc.fromAlias, result.From, result.FromAlias)
+		}
+		if !reflect.DeepEqual(result.Joins, tc.joins) {
+			t.Errorf("For input '%s', expected joins %+v, got %+v", tc.input, tc.joins, result.Joins)
+		}
+		if result.String() != tc.output {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.output, result.String())
+		}
+	}
+}
+
+func TestParseWith(t *testing.T) {
+	testCases := []struct {
+		input  string
+		with   []CTE
+		from   string
+		output string
+	}{
+		{
+			input:  "WITH failing AS (SELECT name FROM pods WHERE status.phase = 'Failed') SELECT count(*) FROM failing",
+			with:   []CTE{{Name: "failing", Query: "SELECT name FROM pods WHERE status.phase = 'Failed'"}},
+			from:   "failing",
+			output: "WITH failing AS (SELECT name FROM pods WHERE status.phase = 'Failed') SELECT count(*) FROM failing",
+		},
+		{
+			input: "with a as (from kube-system/pods limit 5), `b c` AS (SELECT name FROM a ORDER BY name) SELECT * FROM `b c` JOIN a ON a.name = `b c`.name",
+			with: []CTE{
+				{Name: "a", Query: "FROM kube-system/pods LIMIT 5"},
+				{Name: "b c", Query: "SELECT name FROM a ORDER BY name ASC"},
+			},
+			from:   "`b c`",
+			output: "WITH a AS (FROM kube-system/pods LIMIT 5), `b c` AS (SELECT name FROM a ORDER BY name ASC) SELECT * FROM `b c` JOIN a ON a.name = `b c`.name",
+		},
+		{
+			input:  "EXPLAIN WITH w AS (SELECT name FROM pods WHERE name IN (SELECT name FROM nodes)) FROM w",
+			with:   []CTE{{Name: "w", Query: "SELECT name FROM pods WHERE name IN (SELECT name FROM nodes)"}},
+			from:   "w",
+			output: "EXPLAIN WITH w AS (SELECT name FROM pods WHERE name IN (SELECT name FROM nodes)) FROM w",
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.With, tc.with) {
+			t.Errorf("For input '%s', expected common table expressions %+v, got %+v", tc.input, tc.with, result.With)
+		}
+		if result.From != tc.from {
+			t.Errorf("For input '%s', expected FROM '%s', got '%s'", tc.input, tc.from, result.From)
+		}
+		if result.String() != tc.output {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.output, result.String())
+		}
+	}
+}
+
+func TestParseWithErrors(t *testing.T) {
+	testCases := []struct {
+		input   string
+		message string
+		column  int
+	}{
+		{"WITH", "expected the name of a common table expression", 5},
+		{"WITH FROM pods", "expected the name of a common table expression, found 'FROM'", 6},
+		{"WITH a (FROM pods) FROM a", "expected AS after 'a'", 7},
+		{"WITH a AS FROM pods", "expected '(' after AS", 10},
+		{"WITH a AS () FROM a", "common table expression 'a' is empty", 12},
+		{"WITH a AS (FROM pods), A AS (FROM nodes) FROM a", "common table expression 'A' is defined twice", 24},
+		{"WITH a AS (FROM pods)", "expected a query after the WITH clause", 22},
+		{"WITH a AS (FROM pods WHERE) FROM a", "WHERE clause cannot be empty", 27},
+		{"WITH a AS (EXPLAIN FROM pods) FROM a", "unexpected EXPLAIN in a common table expression", 12},
+		{"WITH a AS (WITH b AS (FROM pods) FROM b) FROM a", "unexpected WITH in a common table expression", 12},
+	}
+
+	for _, tc := range testCases {
+		_, err := NewParser(tc.input).Parse()
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("For input '%s', expected a parse error, got %v", tc.input, err)
+			continue
+		}
+		if !strings.Contains(parseErr.Message, tc.message) || parseErr.Pos.Column != tc.column {
+			t.Errorf("For input '%s', expected '%s' at column %d, got '%s' at column %d", tc.input, tc.message, tc.column, parseErr.Message, parseErr.Pos.Column)
+		}
+	}
+}
+
+func TestParseCompound(t *testing.T) {
+	testCases := []struct {
+		input    string
+		compound []SetOperation
+		orderBy  []OrderByField
+		limit    int
+		output   string
+	}{
+		{
+			input:    "SELECT name FROM deployments UNION SELECT name FROM statefulsets",
+			compound: []SetOperation{{Op: "UNION", Query: "SELECT name FROM statefulsets"}},
+			limit:    DefaultLimit,
+			output:   "SELECT name FROM deployments UNION SELECT name FROM statefulsets",
+		},
+		{
+			input: "select name from pods where status.phase = 'Running' union all select name from nodes intersect select name from services order by name desc limit 3",
+			compound: []SetOperation{
+				{Op: "UNION", All: true, Query: "SELECT name FROM nodes"},
+				{Op: "INTERSECT", Query: "SELECT name FROM services"},
+			},
+			orderBy: []OrderByField{{Field: "name", Direction: "DESC"}},
+			limit:   3,
+			output:  "SELECT name FROM pods WHERE status.phase = 'Running' UNION ALL SELECT name FROM nodes INTERSECT SELECT name FROM services ORDER BY name DESC LIMIT 3",
+		},
+		{
+			input:    "EXPLAIN WITH a AS (FROM pods) SELECT name FROM a EXCEPT SELECT name FROM pods WHERE name IN (SELECT name FROM a UNION SELECT name FROM nodes)",
+			compound: []SetOperation{{Op: "EXCEPT", Query: "SELECT name FROM pods WHERE name IN (SELECT name FROM a UNION SELECT name FROM nodes)"}},
+			limit:    DefaultLimit,
+			output:   "EXPLAIN WITH a AS (FROM pods) SELECT name FROM a EXCEPT SELECT name FROM pods WHERE name IN (SELECT name FROM a UNION SELECT name FROM nodes)",
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Compound, tc.compound) {
+			t.Errorf("For input '%s', expected set operations %+v, got %+v", tc.input, tc.compound, result.Compound)
+		}
+		if !reflect.DeepEqual(result.OrderBy, tc.orderBy) || result.Limit != tc.limit {
+			t.Errorf("For input '%s', expected ORDER BY %+v LIMIT %d, got %+v LIMIT %d", tc.input, tc.orderBy, tc.limit, result.OrderBy, result.Limit)
+		}
+		if result.String() != tc.output {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.output, result.String())
+		}
+	}
+}
+
+func TestParseCompoundErrors(t *testing.T) {
+	testCases := []struct {
+		input   string
+		message string
+		column  int
+	}{
+		{"UNION SELECT name FROM pods", "expected a query before UNION", 1},
+		{"SELECT name FROM pods UNION", "expected a query after UNION", 28},
+		{"SELECT name FROM pods EXCEPT ALL", "expected a query after EXCEPT ALL", 33},
+		{"SELECT name FROM pods UNION INTERSECT SELECT name FROM nodes", "expected a query after UNION", 28},
+		{"SELECT name FROM pods ORDER BY name UNION SELECT name FROM nodes", "ORDER BY clause must follow the last query combined by UNION", 23},
+		{"SELECT name FROM pods UNION SELECT name FROM nodes LIMIT 2 EXCEPT SELECT name FROM services", "LIMIT clause must follow the last query combined by EXCEPT", 52},
+		{"SELECT name FROM pods UNION EXPLAIN SELECT name FROM nodes", "unexpected EXPLAIN after UNION", 29},
+		{"SELECT name FROM pods UNION WITH a AS (FROM nodes) SELECT name FROM a", "unexpected WITH after UNION", 29},
+		{"SELECT name FROM pods INTERSECT SELECT name FROM nodes WHERE", "WHERE clause cannot be empty", 61},
+		{"WITH a AS (FROM pods UNION FROM nodes) FROM a", "unexpected UNION in a common table expression", 12},
+	}
+
+	for _, tc := range testCases {
+		_, err := NewParser(tc.input).Parse()
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("For input '%s', expected a parse error, got %v", tc.input, err)
+			continue
+		}
+		if !strings.Contains(parseErr.Message, tc.message) || parseErr.Pos.Column != tc.column {
+			t.Errorf("For input '%s', expected '%s' at column %d, got '%s' at column %d", tc.input, tc.message, tc.column, parseErr.Message, parseErr.Pos.Column)
+		}
+	}
+}
+
+func TestParseSemicolon(t *testing.T) {
+	result, err := NewParser("SELECT name FROM pods; -- done").Parse()
+	if err != nil {
+		t.Fatalf("Failed to parse query: %v", err)
+	}
+	if result.String() != "SELECT name FROM pods" {
+		t.Errorf("Unexpected query: %s", result.String())
+	}
+	if len(result.Comments) != 1 || result.Comments[0].Text != "-- done" {
+		t.Errorf("Unexpected comments: %+v", result.Comments)
+	}
+
+	_, err = NewParser("SELECT name FROM pods; SELECT name FROM nodes").Parse()
+	if err == nil {
+		t.Error("Expected error for multiple statements")
+	}
+}
+
+func TestParseCommentAfterIdentifier(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"SELECT name-- c\nFROM pods", "SELECT name FROM pods"},
+		{"SELECT name FROM pods--all pods\nWHERE a = 1", "SELECT name FROM pods WHERE a = 1"},
+		{"SELECT name FROM pods WHERE ns = default-- x\nLIMIT 1", "SELECT name FROM pods WHERE ns = default LIMIT 1"},
+		{"SELECT name FROM my-ns/pods WHERE name = `my--app`", "SELECT name FROM my-ns/pods WHERE name = `my--app`"},
+	}
+
+	for _, tc := range testCases {
+		result, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if result.String() != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, result.String())
+		}
+		if result.FromAlias != "" {
+			t.Errorf("For input '%s', expected no alias, got '%s'", tc.input, result.FromAlias)
+		}
+	}
+}
+
+func TestParseBooleans(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"SELECT x = true FROM pods WHERE ready = false OR NOT True", "SELECT x = TRUE FROM pods WHERE ready = FALSE OR NOT TRUE"},
+		{"FROM pods p JOIN nodes n ON n.name = p.spec.nodeName AND n.ready = true ORDER BY p.ok = false", "FROM pods p JOIN nodes n ON n.name = p.spec.nodeName AND n.ready = TRUE ORDER BY p.ok = FALSE ASC"},
+		{"FROM pods WHERE labels.true = 'x' AND true.x = 1", "FROM pods WHERE labels.true = 'x' AND true.x = 1"},
+		{"FROM true/pods WHERE name IN (SELECT name FROM false/pods WHERE ok = true)", "FROM true/pods WHERE name IN (SELECT name FROM false/pods WHERE ok = TRUE)"},
+	}
+
+	for _, tc := range testCases {
+		result, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if result.String() != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, result.String())
+		}
+	}
+}
+
+func TestParseOrderByExpressions(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []OrderByField
+	}{
+		{"FROM pods ORDER BY a + b DESC", []OrderByField{{Field: "a + b", Direction: "DESC"}}},
+		{"FROM pods ORDER BY spec.replicas * 2, len(name) desc", []OrderByField{{Field: "spec.replicas * 2", Direction: "ASC"}, {Field: "len(name)", Direction: "DESC"}}},
+		{"FROM pods ORDER BY status.phase = 'Running' ASC, labels['app name']", []OrderByField{{Field: "status.phase = 'Running'", Direction: "ASC"}, {Field: "labels['app name']", Direction: "ASC"}}},
+		{"FROM pods ORDER BY desc", []OrderByField{{Field: "desc", Direction: "ASC"}}},
+	}
+
+	for _, tc := range testCases {
+		result, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.OrderBy, tc.expected) {
+			t.Errorf("For input '%s', expected %+v, got %+v", tc.input, tc.expected, result.OrderBy)
+		}
+	}
+
+	_, err := NewParser("FROM pods ORDER BY a + b ASC DESC").Parse()
+	if err == nil || !strings.Contains(err.Error(), "found multiple directions") {
+		t.Errorf("Expected an error for multiple directions, got %v", err)
+	}
+}
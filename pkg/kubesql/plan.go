@@ -0,0 +1,832 @@
+package kubesql
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// PlanOp is the operation of a query plan node.
+type PlanOp string
+
+// Plan operations, from the API server request to the returned rows.
+const (
+	ListOp      PlanOp = "List"      // Lists the objects of a resource from the API server
+	FilterOp    PlanOp = "Filter"    // Evaluates a condition over every object, client side
+	ProjectOp   PlanOp = "Project"   // Evaluates the SELECT fields of every object
+	AggregateOp PlanOp = "Aggregate" // Computes the single row of an aggregate query
+	SortOp      PlanOp = "Sort"      // Sorts the rows in memory
+	LimitOp     PlanOp = "Limit"     // Keeps the first rows
+	JoinOp      PlanOp = "Join"      // Joins the rows of its first input with the objects of its second
+	SubqueryOp  PlanOp = "Subquery"  // Runs a subquery of the condition of its parent Filter
+	CTEOp       PlanOp = "CTE"       // Runs a common table expression of the WITH clause
+	ScanOp      PlanOp = "Scan"      // Reads the rows of a common table expression
+	UnionOp     PlanOp = "Union"     // Returns the rows of every input
+	IntersectOp PlanOp = "Intersect" // Returns the rows of its first input found in every other input
+	ExceptOp    PlanOp = "Except"    // Returns the rows of its first input found in no other input
+)
+
+// PlanNode is a step of a query plan. Children are the inputs of the step,
+// and the Subquery steps of a Filter follow its input.
+type PlanNode struct {
+	Op            PlanOp      `json:"op" yaml:"op"`
+	Resource      string      `json:"resource,omitempty" yaml:"resource,omitempty"`           // List: resource name, e.g. "pods"; Scan: common table expression name
+	APIVersion    string      `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`       // List: API group and version, e.g. "apps/v1"
+	Kind          string      `json:"kind,omitempty" yaml:"kind,omitempty"`                   // List: object kind, e.g. "Deployment"
+	Clusters      []string    `json:"clusters,omitempty" yaml:"clusters,omitempty"`           // List: cluster name patterns, empty for the default cluster
+	Namespace     string      `json:"namespace,omitempty" yaml:"namespace,omitempty"`         // List: namespace, empty for all namespaces
+	LabelSelector string      `json:"labelSelector,omitempty" yaml:"labelSelector,omitempty"` // List: label selector sent to the API server
+	FieldSelector string      `json:"fieldSelector,omitempty" yaml:"fieldSelector,omitempty"` // List: field selector sent to the API server
+	Table         string      `json:"table,omitempty" yaml:"table,omitempty"`                 // List and Scan: name qualifying the fields of the table in joins; CTE: name
+	Condition     string      `json:"condition,omitempty" yaml:"condition,omitempty"`         // Filter: condition evaluated client side; Join: ON condition
+	JoinType      string      `json:"joinType,omitempty" yaml:"joinType,omitempty"`           // Join: INNER or LEFT
+	HashKeys      []string    `json:"hashKeys,omitempty" yaml:"hashKeys,omitempty"`           // Join: equalities used as hash keys, none for a nested loop join
+	Correlated    bool        `json:"correlated,omitempty" yaml:"correlated,omitempty"`       // Subquery: uses the rows of the Filter, and runs for each of them
+	All           bool        `json:"all,omitempty" yaml:"all,omitempty"`                     // Union, Intersect and Except: keeps duplicate rows
+	Fields        []string    `json:"fields,omitempty" yaml:"fields,omitempty"`               // Project and Aggregate: selected fields
+	SortKeys      []string    `json:"sortKeys,omitempty" yaml:"sortKeys,omitempty"`           // Sort: keys with their direction
+	Limit         *int        `json:"limit,omitempty" yaml:"limit,omitempty"`                 // Limit: maximum rows; List: page size sent to the API server
+	EstimatedRows int         `json:"estimatedRows" yaml:"estimatedRows"`                     // Estimated number of output rows
+	EstimatedCost float64     `json:"estimatedCost" yaml:"estimatedCost"`                     // Estimated cost of the step and its inputs
+	Children      []*PlanNode `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+// Plan describes how a query runs: what the API server is asked for, and
+// what is done client side.
+type Plan struct {
+	Query    string      `json:"query" yaml:"query"`                           // Canonical form of the planned query
+	With     []*PlanNode `json:"with,omitempty" yaml:"with,omitempty"`         // CTE steps of the common table expressions, in order
+	Root     *PlanNode   `json:"root" yaml:"root"`                             // Last step of the plan, returning the rows
+	Rewrites []string    `json:"rewrites,omitempty" yaml:"rewrites,omitempty"` // Optimizer rules that rewrote the query
+	Warnings []string    `json:"warnings,omitempty" yaml:"warnings,omitempty"` // Costly steps, e.g. listing every object of the cluster
+}
+
+// Costs and selectivities used to estimate plans. Costs are in units of
+// one object transferred from the API server.
+const (
+	defaultObjectCount    = 1000 // Objects of a resource in the cluster, when no count is known
+	namespaceSelectivity  = 0.1  // Fraction of the objects in one namespace
+	equalitySelectivity   = 0.1  // Fraction of the objects matching an equality
+	inequalitySelectivity = 0.9  // Fraction of the objects matching an inequality
+	conditionSelectivity  = 0.5  // Fraction of the objects matching another condition
+	evaluationCost        = 0.01 // Cost of evaluating an expression over an object
+	comparisonCost        = 0.01 // Cost of comparing two rows when sorting
+)
+
+// labelValueMaxLength is the maximum length of a label value.
+const labelValueMaxLength = 63
+
+// fieldSelectors lists the fields the API server can select on besides
+// metadata.name and metadata.namespace, by resource name and group.
+var fieldSelectors = map[string][]string{
+	"pods": {"spec.nodeName", "spec.restartPolicy", "spec.schedulerName", "spec.serviceAccountName",
+		"status.phase", "status.podIP", "status.nominatedNodeName"},
+	"events": {"involvedObject.kind", "involvedObject.namespace", "involvedObject.name", "involvedObject.uid",
+		"involvedObject.apiVersion", "involvedObject.resourceVersion", "involvedObject.fieldPath",
+		"reason", "reportingComponent", "source", "type"},
+	"secrets":    {"type"},
+	"namespaces": {"status.phase"},
+	"certificatesigningrequests.certificates.k8s.io": {"spec.signerName"},
+}
+
+var (
+	labelKeyPattern   = regexp.MustCompile(`^([a-z0-9]([-a-z0-9.]*[a-z0-9])?/)?[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?)?$`)
+)
+
+// Planner builds the plans of queries.
+type Planner struct {
+	Resolver  Resolver                        // Resolves the FROM resource, required
+	Counts    func(resource ResourceInfo) int // Number of objects of a resource in the cluster, optional
+	Optimizer *Optimizer                      // Rewrites queries before planning, optional
+}
+
+// NewPlanner creates a planner using the given resolver and the default
+// optimizer rules.
+func NewPlanner(resolver Resolver) *Planner {
+	return &Planner{Resolver: resolver, Optimizer: NewOptimizer()}
+}
+
+// Explain plans a query using the built in resources.
+func Explain(q *Query) (*Plan, error) {
+	return NewPlanner(DefaultResolver()).Plan(q)
+}
+
+// Plan builds the plan of a query. Conditions of the WHERE clause joined by
+// AND that the API server can evaluate become label and field selectors;
+// the rest is filtered client side. Without a client side filter, sort or
+// aggregate, LIMIT is sent to the API server as the page size.
+//
+// Queries with joins list every table, each with the selectors of the
+// conditions using it alone, then join them in order. Joins use the
+// equalities of their ON condition as hash keys when there are some.
+//
+// Subqueries of the WHERE condition are planned as the Subquery steps of
+// the Filter evaluating it. Conditions of subqueries using the tables of
+// enclosing queries are not pushed into selectors.
+//
+// Common table expressions of the WITH clause are planned first, each as a
+// CTE step of With; the tables reading them are Scan steps.
+//
+// Queries combined by UNION, INTERSECT and EXCEPT are planned in turn as
+// the inputs of Union, Intersect and Except steps, followed by the Sort and
+// Limit steps of the combined rows.
+//
+// Estimates use Counts when set, and a fixed number of objects otherwise.
+// When Optimizer is set, the optimized query is planned.
+func (p *Planner) Plan(q *Query) (*Plan, error) {
+	var rewrites []string
+	if p.Optimizer != nil {
+		optimized, applied, err := p.Optimizer.Optimize(q)
+		if err != nil {
+			return nil, err
+		}
+		q, rewrites = optimized, applied
+	}
+
+	plan := &Plan{Query: q.String(), Rewrites: rewrites}
+	for i, cte := range q.With {
+		cq, err := cte.parse()
+		if err != nil {
+			return nil, err
+		}
+		root, err := p.planQuery(plan, cq, nil, plan.With[:i])
+		if err != nil {
+			return nil, fmt.Errorf("error in common table expression '%s': %w", cte.Name, err)
+		}
+		plan.With = append(plan.With, &PlanNode{Op: CTEOp, Table: cte.Name, Children: []*PlanNode{root},
+			EstimatedRows: root.EstimatedRows, EstimatedCost: root.EstimatedCost})
+	}
+	queries, err := operands(q)
+	if err != nil {
+		return nil, err
+	}
+	roots := make([]*PlanNode, len(queries))
+	for i, operand := range queries {
+		if roots[i], err = p.planQuery(plan, operand, nil, plan.With); err != nil {
+			if len(queries) > 1 {
+				return nil, fmt.Errorf("error in query %d: %w", i+1, err)
+			}
+			return nil, err
+		}
+	}
+	plan.Root = roots[0]
+	if len(q.Compound) > 0 {
+		plan.Root = addSortLimit(combine(q.Compound, roots, setOpNode), q)
+	}
+	return plan, nil
+}
+
+// setOps maps the set operations to their plan steps.
+var setOps = map[string]PlanOp{UnionKeyword: UnionOp, IntersectKeyword: IntersectOp, ExceptKeyword: ExceptOp}
+
+// setOpNode returns the step of a set operation over the rows of two
+// inputs. Steps of the same operation are merged.
+func setOpNode(op SetOperation, x, y *PlanNode) *PlanNode {
+	node := &PlanNode{Op: setOps[op.Op], All: op.All, Children: []*PlanNode{x, y}}
+	if x.Op == node.Op && x.All == node.All {
+		node.Children = append(x.Children[:len(x.Children):len(x.Children)], y)
+	}
+
+	cost, rows := 0.0, 0
+	for i, child := range node.Children {
+		cost += child.EstimatedCost
+		rows += child.EstimatedRows
+		switch {
+		case i == 0, node.Op == UnionOp:
+			node.EstimatedRows += child.EstimatedRows
+		case node.Op == IntersectOp:
+			node.EstimatedRows = min(node.EstimatedRows, child.EstimatedRows)
+		}
+	}
+	if node.Op != UnionOp || !node.All {
+		// Rows are hashed to find the duplicates
+		cost += float64(rows) * evaluationCost
+	}
+	node.EstimatedCost = math.Round(cost*100) / 100
+	return node
+}
+
+// planQuery plans a query, or a subquery seeing the tables of enclosing
+// queries named outer, whose tables may read the common table expressions
+// planned as with, adding its warnings to plan, and returns its last step.
+func (p *Planner) planQuery(plan *Plan, q *Query, outer []string, with []*PlanNode) (*PlanNode, error) {
+	s, err := NewStatement(q)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSubqueries(s); err != nil {
+		return nil, err
+	}
+	tables, err := queryTables(q)
+	if err != nil {
+		return nil, err
+	}
+	bindTables(tables, cteSteps(with))
+	names := scopeNames(tables, outer)
+	selects, err := parseSelect(q)
+	if err != nil {
+		return nil, err
+	}
+
+	lists := make([]*listPlan, len(tables))
+	for i, t := range tables {
+		if t.cte != "" {
+			lists[i] = scanPlan(t, with, len(tables) > 1)
+		} else if lists[i], err = p.listPlan(t, len(tables) > 1); err != nil {
+			return nil, err
+		}
+	}
+
+	// Push the conditions the API server can evaluate into selectors
+	var remaining []Expr
+	remainingRows := 1.0
+	if q.Where != "" {
+		where, err := ParseExpr(q.Where)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s clause: %w", WhereKeyword, err)
+		}
+		for _, conjunct := range conjuncts(where) {
+			i := pushTable(conjunct, tables, names)
+			selectivity := estimateSelectivity(unqualify(conjunct, names), lists[max(i, 0)].total)
+			if i >= 0 {
+				if pushed, exact := lists[i].push(unqualify(conjunct, names)); exact {
+					continue
+				} else if pushed {
+					// The selector returns a superset, e.g. != also matches
+					// objects without the label, so the condition is checked
+					// again
+					selectivity = 1
+				}
+			}
+			remaining = append(remaining, conjunct)
+			remainingRows *= selectivity
+		}
+	}
+	// Join conditions are checked again when joining, so any selector of
+	// the joined table alone applies
+	for i, t := range tables[1:] {
+		for _, conjunct := range conjuncts(t.on) {
+			if used := exprTables(conjunct, names); len(used) == 1 && used[0] == t.name {
+				lists[i+1].push(unqualify(conjunct, names))
+			}
+		}
+	}
+
+	aggregate := isAggregateQuery(selects)
+	for _, l := range lists {
+		list := l.node
+		if list.Op == ScanOp {
+			continue
+		}
+		list.LabelSelector = strings.Join(l.labels, ",")
+		list.FieldSelector = strings.Join(l.fields, ",")
+		list.EstimatedRows = estimateRows(l.rows)
+		if len(tables) == 1 && q.Limit >= 0 && len(remaining) == 0 && len(q.OrderBy) == 0 && !aggregate {
+			// Rows are not filtered or reordered client side, the API server
+			// pages the list
+			list.Limit = &q.Limit
+			list.EstimatedRows = min(list.EstimatedRows, q.Limit)
+		}
+		list.EstimatedCost = float64(list.EstimatedRows)
+
+		if len(l.labels) == 0 && len(l.fields) == 0 && list.Limit == nil {
+			switch {
+			case !l.resource.Namespaced:
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("lists every object of %s, no label or field selector applies", l.resource.Name))
+			case list.Namespace == "":
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("lists every object of %s in all namespaces, no namespace, label or field selector applies", l.resource.Name))
+			}
+		}
+	}
+
+	node := lists[0].node
+	for i, t := range tables[1:] {
+		node = addJoinNode(node, lists[i+1].node, t, names)
+	}
+	if len(remaining) > 0 {
+		condition := make([]string, len(remaining))
+		var steps []*PlanNode
+		cost := float64(node.EstimatedRows) * evaluationCost
+		for i, conjunct := range remaining {
+			condition[i] = conjunct.String()
+			for _, s := range subqueries(conjunct) {
+				step, err := p.subqueryNode(plan, s, visibleNames(tables, outer), node.EstimatedRows, with)
+				if err != nil {
+					return nil, err
+				}
+				steps = append(steps, step)
+				cost += step.EstimatedCost
+			}
+		}
+		node = addPlanNode(node, &PlanNode{Op: FilterOp, Condition: strings.Join(condition, " AND ")},
+			estimateRows(float64(node.EstimatedRows)*remainingRows), cost)
+		node.Children = append(node.Children, steps...)
+	}
+
+	// Aggregates return a single row, which is neither sorted nor paged
+	if aggregate {
+		node = addPlanNode(node, &PlanNode{Op: AggregateOp, Fields: selectFieldNames(q)},
+			1, float64(node.EstimatedRows)*evaluationCost)
+		if q.Limit == 0 {
+			node = addPlanNode(node, &PlanNode{Op: LimitOp, Limit: &q.Limit}, 0, 0)
+		}
+		return node, nil
+	}
+
+	if _, star := selects[0].(*StarExpr); len(selects) > 1 || !star {
+		node = addPlanNode(node, &PlanNode{Op: ProjectOp, Fields: selectFieldNames(q)},
+			node.EstimatedRows, float64(node.EstimatedRows)*evaluationCost)
+	}
+
+	return addSortLimit(node, q), nil
+}
+
+// addSortLimit adds the Sort and Limit steps of the ORDER BY and LIMIT
+// clauses of a query to its rows.
+func addSortLimit(node *PlanNode, q *Query) *PlanNode {
+	if len(q.OrderBy) > 0 {
+		keys := make([]string, len(q.OrderBy))
+		for i, field := range q.OrderBy {
+			keys[i] = fmt.Sprintf("%s %s", field.Field, field.Direction)
+		}
+		n := float64(node.EstimatedRows)
+		node = addPlanNode(node, &PlanNode{Op: SortOp, SortKeys: keys},
+			node.EstimatedRows, n*math.Log2(max(n, 2))*comparisonCost)
+	}
+
+	if q.Limit >= 0 {
+		node = addPlanNode(node, &PlanNode{Op: LimitOp, Limit: &q.Limit}, min(node.EstimatedRows, q.Limit), 0)
+	}
+	return node
+}
+
+// subqueryNode plans a subquery of a Filter over rows rows, seeing the
+// tables named outer and the common table expressions planned as with.
+// Subqueries using the tables run for every row, but their objects are
+// listed once.
+func (p *Planner) subqueryNode(plan *Plan, s *Statement, outer []string, rows int, with []*PlanNode) (*PlanNode, error) {
+	input, err := p.planQuery(plan, s.Query(), outer, with)
+	if err != nil {
+		return nil, err
+	}
+	used, err := outerTables(s, outer)
+	if err != nil {
+		return nil, err
+	}
+	node := &PlanNode{Op: SubqueryOp, Correlated: len(used) > 0, Children: []*PlanNode{input},
+		EstimatedRows: input.EstimatedRows, EstimatedCost: input.EstimatedCost}
+	if node.Correlated {
+		lists := listCost(input)
+		node.EstimatedCost = math.Round((lists+(input.EstimatedCost-lists)*float64(rows))*100) / 100
+	}
+	return node, nil
+}
+
+// listCost returns the estimated cost of the List steps of a plan.
+func listCost(node *PlanNode) float64 {
+	if node.Op == ListOp {
+		return node.EstimatedCost
+	}
+	cost := 0.0
+	for _, child := range node.Children {
+		cost += listCost(child)
+	}
+	return cost
+}
+
+// listPlan is the List step of a table being planned.
+type listPlan struct {
+	node     *PlanNode
+	resource ResourceInfo
+	total    int      // Objects of the resource in the cluster
+	rows     float64  // Estimated objects listed
+	labels   []string // Requirements of the label selector
+	fields   []string // Requirements of the field selector
+}
+
+// listPlan starts the List step of a table, listing its namespace.
+func (p *Planner) listPlan(t table, joined bool) (*listPlan, error) {
+	resource, err := p.Resolver.Resolve(t.ref.Resource)
+	if err != nil {
+		return nil, err
+	}
+	list := &PlanNode{Op: ListOp, Resource: resource.Name, APIVersion: resource.Version, Kind: resource.Kind, Clusters: t.ref.Clusters}
+	if resource.Group != "" {
+		list.APIVersion = resource.Group + "/" + resource.Version
+	}
+	if joined {
+		list.Table = t.name
+	}
+	total := defaultObjectCount
+	if p.Counts != nil {
+		total = p.Counts(resource)
+	}
+	rows := float64(total)
+	if resource.Namespaced && t.ref.Namespace != "" {
+		list.Namespace = t.ref.Namespace
+		rows *= namespaceSelectivity
+	}
+	return &listPlan{node: list, resource: resource, total: total, rows: rows}, nil
+}
+
+// scanPlan starts the Scan step of a table reading a common table
+// expression planned among with. Its rows are in memory, no selector
+// applies.
+func scanPlan(t table, with []*PlanNode, joined bool) *listPlan {
+	i := slices.IndexFunc(with, func(step *PlanNode) bool { return step.Table == t.cte })
+	rows := with[i].EstimatedRows
+	scan := &PlanNode{Op: ScanOp, Resource: t.cte, EstimatedRows: rows, EstimatedCost: float64(rows) * evaluationCost}
+	if joined {
+		scan.Table = t.name
+	}
+	return &listPlan{node: scan, total: rows, rows: float64(rows)}
+}
+
+// cteSteps returns the names of the common table expressions of CTE steps.
+func cteSteps(with []*PlanNode) []string {
+	names := make([]string, len(with))
+	for i, step := range with {
+		names[i] = step.Table
+	}
+	return names
+}
+
+// push converts a condition on the objects of the table into a selector
+// requirement. pushed reports whether it did, and exact whether the
+// requirement matches the same objects as the condition.
+func (l *listPlan) push(conjunct Expr) (pushed, exact bool) {
+	if l.node.Op == ScanOp {
+		return false, false
+	}
+	requirement, exact := labelRequirement(conjunct)
+	if requirement != "" {
+		l.labels = append(l.labels, requirement)
+	} else if requirement, exact = fieldRequirement(conjunct, l.resource); requirement != "" {
+		l.fields = append(l.fields, requirement)
+	} else {
+		return false, false
+	}
+	l.rows *= estimateSelectivity(conjunct, l.total)
+	return true, exact
+}
+
+// pushTable returns the index of the table whose List a condition of the
+// WHERE clause can be pushed into: the only table it uses, unless a LEFT
+// join may leave rows without it or it is a table of an enclosing query.
+// It returns -1 otherwise, and for conditions with subqueries.
+func pushTable(conjunct Expr, tables []table, names []string) int {
+	switch {
+	case len(subqueries(conjunct)) > 0:
+		return -1
+	case len(names) <= 1:
+		return 0
+	}
+	used := exprTables(conjunct, names)
+	if len(used) != 1 {
+		return -1
+	}
+	i := slices.Index(names, used[0])
+	if i >= len(tables) || tables[i].join != nil && tables[i].join.Type == LeftJoin {
+		return -1
+	}
+	return i
+}
+
+// addJoinNode joins the rows of left with the objects listed by right. Hash
+// joins are expected to match each row with about one object, and nested
+// loop joins to compare every row with every object.
+func addJoinNode(left, right *PlanNode, t table, names []string) *PlanNode {
+	node := &PlanNode{Op: JoinOp, JoinType: t.join.Type, Condition: t.on.String(), Children: []*PlanNode{left, right}}
+	leftRows, rightRows := float64(left.EstimatedRows), float64(right.EstimatedRows)
+	rows, cost := leftRows*rightRows*conditionSelectivity, leftRows*rightRows*evaluationCost
+	leftKeys, rightKeys := hashKeys(t.on, names, t.name)
+	if len(leftKeys) > 0 {
+		for i := range leftKeys {
+			node.HashKeys = append(node.HashKeys, leftKeys[i].String()+" = "+rightKeys[i].String())
+		}
+		rows, cost = leftRows, (leftRows+rightRows)*evaluationCost
+	}
+	if t.join.Type == LeftJoin {
+		// Rows matching no object are kept
+		rows = max(rows, leftRows)
+	}
+	node.EstimatedRows = estimateRows(rows)
+	node.EstimatedCost = math.Round((left.EstimatedCost+right.EstimatedCost+cost)*100) / 100
+	return node
+}
+
+// addPlanNode makes node the parent of input, with the given estimated
+// rows and cost of its own.
+func addPlanNode(input, node *PlanNode, rows int, cost float64) *PlanNode {
+	node.Children = []*PlanNode{input}
+	node.EstimatedRows = rows
+	node.EstimatedCost = math.Round((input.EstimatedCost+cost)*100) / 100
+	return node
+}
+
+// estimateRows rounds an estimated number of rows, at least one row is
+// expected from any non empty input.
+func estimateRows(rows float64) int {
+	if rows <= 0 {
+		return 0
+	}
+	return max(1, int(math.Round(rows)))
+}
+
+// selectFieldNames returns the SELECT fields as written, with their aliases.
+func selectFieldNames(q *Query) []string {
+	names := make([]string, len(q.Select))
+	for i, field := range q.Select {
+		names[i] = string(field.Field)
+		if field.Alias != "" {
+			names[i] += " AS " + quoteIdentifier(field.Alias)
+		}
+	}
+	return names
+}
+
+// conjuncts splits a condition into the conditions joined by its top level
+// AND operators, looking into parentheses.
+func conjuncts(expr Expr) []Expr {
+	switch e := expr.(type) {
+	case *ParenExpr:
+		if inner := conjuncts(e.X); len(inner) > 1 {
+			return inner
+		}
+	case *BinaryExpr:
+		if e.Op == "AND" {
+			return append(conjuncts(e.X), conjuncts(e.Y)...)
+		}
+	}
+	return []Expr{expr}
+}
+
+// estimateSelectivity estimates the fraction of the objects matching a
+// condition.
+func estimateSelectivity(expr Expr, total int) float64 {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		switch e.Op {
+		case "=":
+			for _, side := range []Expr{e.X, e.Y} {
+				if ref, ok := side.(*FieldRef); ok && isNameField(ref) && total > 0 {
+					return 1 / float64(total)
+				}
+			}
+			return equalitySelectivity
+		case "!=":
+			return inequalitySelectivity
+		case "AND":
+			return estimateSelectivity(e.X, total) * estimateSelectivity(e.Y, total)
+		case "OR":
+			return min(1, estimateSelectivity(e.X, total)+estimateSelectivity(e.Y, total))
+		}
+	case *InExpr:
+		if e.Subquery != nil {
+			return conditionSelectivity
+		}
+		if e.Not {
+			return max(0, 1-equalitySelectivity*float64(len(e.List)))
+		}
+		return min(1, equalitySelectivity*float64(len(e.List)))
+	case *IsNullExpr:
+		if e.Not {
+			return inequalitySelectivity
+		}
+		return 1 - inequalitySelectivity
+	case *ParenExpr:
+		return estimateSelectivity(e.X, total)
+	}
+	return conditionSelectivity
+}
+
+// isNameField reports whether ref is the object name.
+func isNameField(ref *FieldRef) bool {
+	return (&FieldRef{Path: resolveAlias(ref.Path)}).Name() == "metadata.name"
+}
+
+// comparedField returns the field and the string value of a comparison
+// between a field and a string literal, in any order.
+func comparedField(expr *BinaryExpr) (*FieldRef, string, bool) {
+	if ref, ok := expr.X.(*FieldRef); ok {
+		if value, ok := stringLiteral(expr.Y); ok {
+			return ref, value, true
+		}
+	}
+	if ref, ok := expr.Y.(*FieldRef); ok {
+		if value, ok := stringLiteral(expr.X); ok {
+			return ref, value, true
+		}
+	}
+	return nil, "", false
+}
+
+// stringLiteral returns the value of a string literal.
+func stringLiteral(expr Expr) (string, bool) {
+	literal, ok := expr.(*Literal)
+	if !ok || literal.Kind != StringLiteral {
+		return "", false
+	}
+	return literal.Value, true
+}
+
+// labelKey returns the label key a field refers to, e.g. app for
+// metadata.labels.app or labels.app.
+func labelKey(ref *FieldRef) (string, bool) {
+	path := resolveAlias(ref.Path)
+	if len(path) != 3 || path[0].Name != "metadata" || path[1].Name != "labels" || path[2].Array {
+		return "", false
+	}
+	return path[2].Name, labelKeyPattern.MatchString(path[2].Name)
+}
+
+// isLabelValue reports whether value can be used in a label selector.
+func isLabelValue(value string) bool {
+	return len(value) <= labelValueMaxLength && labelValuePattern.MatchString(value)
+}
+
+// labelRequirement converts a condition into a label selector requirement.
+// exact reports whether the requirement matches the same objects as the
+// condition, rather than a superset of them.
+func labelRequirement(expr Expr) (requirement string, exact bool) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if e.Op != "=" && e.Op != "!=" {
+			return "", false
+		}
+		ref, value, ok := comparedField(e)
+		if !ok {
+			return "", false
+		}
+		key, ok := labelKey(ref)
+		if !ok || !isLabelValue(value) {
+			return "", false
+		}
+		// The API server also matches objects without the label for !=
+		return key + e.Op + value, e.Op == "="
+	case *InExpr:
+		ref, ok := e.X.(*FieldRef)
+		if !ok || e.Subquery != nil {
+			return "", false
+		}
+		key, ok := labelKey(ref)
+		if !ok {
+			return "", false
+		}
+		values := make([]string, len(e.List))
+		for i, item := range e.List {
+			value, ok := stringLiteral(item)
+			if !ok || !isLabelValue(value) {
+				return "", false
+			}
+			values[i] = value
+		}
+		sort.Strings(values)
+		if e.Not {
+			return fmt.Sprintf("%s notin (%s)", key, strings.Join(values, ",")), false
+		}
+		return fmt.Sprintf("%s in (%s)", key, strings.Join(values, ",")), true
+	case *IsNullExpr:
+		ref, ok := e.X.(*FieldRef)
+		if !ok {
+			return "", false
+		}
+		key, ok := labelKey(ref)
+		if !ok {
+			return "", false
+		}
+		if e.Not {
+			return key, true
+		}
+		return "!" + key, true
+	}
+	return "", false
+}
+
+// fieldRequirement converts a condition into a field selector requirement
+// of the resource. exact reports whether the requirement matches the same
+// objects as the condition, rather than a superset of them.
+func fieldRequirement(expr Expr, resource ResourceInfo) (requirement string, exact bool) {
+	e, ok := expr.(*BinaryExpr)
+	if !ok || (e.Op != "=" && e.Op != "!=") {
+		return "", false
+	}
+	ref, value, ok := comparedField(e)
+	if !ok || value == "" || strings.ContainsAny(value, `,=!\\`) {
+		return "", false
+	}
+
+	path := resolveAlias(ref.Path)
+	name := (&FieldRef{Path: path}).Name()
+	selectable := name == "metadata.name" || (name == "metadata.namespace" && resource.Namespaced)
+	qualified := resource.Name
+	if resource.Group != "" {
+		qualified += "." + resource.Group
+	}
+	for _, field := range fieldSelectors[qualified] {
+		selectable = selectable || field == name
+	}
+	if !selectable {
+		return "", false
+	}
+
+	// The API server also matches objects with an empty field for !=
+	return name + e.Op + value, e.Op == "="
+}
+
+// String renders the plan as an indented tree, the last step first.
+func (p *Plan) String() string {
+	var result strings.Builder
+	for _, step := range p.With {
+		writePlanNode(&result, step, 0)
+	}
+	writePlanNode(&result, p.Root, 0)
+	if len(p.Rewrites) > 0 {
+		fmt.Fprintf(&result, "Rewrites: %s\n", strings.Join(p.Rewrites, ", "))
+	}
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(&result, "Warning: %s\n", warning)
+	}
+	return result.String()
+}
+
+// writePlanNode renders a node and its children.
+func writePlanNode(out *strings.Builder, node *PlanNode, depth int) {
+	if depth > 0 {
+		out.WriteString(strings.Repeat("  ", depth-1) + "-> ")
+	}
+	out.WriteString(node.String())
+	out.WriteByte('\n')
+	for _, child := range node.Children {
+		writePlanNode(out, child, depth+1)
+	}
+}
+
+// String describes the step on one line with its estimates.
+func (n *PlanNode) String() string {
+	var detail string
+	switch n.Op {
+	case ListOp:
+		var parts []string
+		if len(n.Clusters) > 0 {
+			parts = append(parts, "clusters="+strings.Join(n.Clusters, ","))
+		}
+		if n.Namespace != "" {
+			parts = append(parts, "namespace="+n.Namespace)
+		}
+		if n.LabelSelector != "" {
+			parts = append(parts, fmt.Sprintf("labelSelector=%q", n.LabelSelector))
+		}
+		if n.FieldSelector != "" {
+			parts = append(parts, fmt.Sprintf("fieldSelector=%q", n.FieldSelector))
+		}
+		if n.Limit != nil {
+			parts = append(parts, fmt.Sprintf("limit=%d", *n.Limit))
+		}
+		detail = fmt.Sprintf(" %s (%s %s)", n.Resource, n.APIVersion, n.Kind)
+		if n.Table != "" {
+			detail += " as " + n.Table
+		}
+		if len(parts) > 0 {
+			detail += " " + strings.Join(parts, " ")
+		}
+	case ScanOp:
+		detail = " " + n.Resource
+		if n.Table != "" {
+			detail += " as " + n.Table
+		}
+	case CTEOp:
+		detail = " " + quoteIdentifier(n.Table)
+	case FilterOp:
+		detail = " client side: " + n.Condition
+	case JoinOp:
+		method := "nested loop"
+		if len(n.HashKeys) > 0 {
+			method = "hash"
+		}
+		detail = fmt.Sprintf(" %s (%s) on %s", n.JoinType, method, n.Condition)
+	case ProjectOp, AggregateOp:
+		detail = ": " + strings.Join(n.Fields, ", ")
+	case SortOp:
+		detail = " in memory: " + strings.Join(n.SortKeys, ", ")
+	case SubqueryOp:
+		detail = " runs once"
+		if n.Correlated {
+			detail = " runs for every row"
+		}
+	case UnionOp, IntersectOp, ExceptOp:
+		detail = " distinct"
+		if n.All {
+			detail = " all"
+		}
+	case LimitOp:
+		if n.Limit != nil {
+			detail = fmt.Sprintf(": %d", *n.Limit)
+		}
+	}
+	return fmt.Sprintf("%s%s (rows=%d cost=%.2f)", n.Op, detail, n.EstimatedRows, n.EstimatedCost)
+}
@@ -0,0 +1,378 @@
+package kubesql
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseExplain(t *testing.T) {
+	testCases := []struct {
+		input    string
+		explain  bool
+		expected string
+	}{
+		{"EXPLAIN SELECT name FROM pods", true, "EXPLAIN SELECT name FROM pods"},
+		{"explain from pods where name = 'a'", true, "EXPLAIN FROM pods WHERE name = 'a'"},
+		{"SELECT name FROM pods", false, "SELECT name FROM pods"},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if query.Explain != tc.explain {
+			t.Errorf("For input '%s', expected Explain %v, got %v", tc.input, tc.explain, query.Explain)
+		}
+		if query.String() != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, query.String())
+		}
+	}
+
+	errorCases := []struct {
+		input      string
+		suggestion string
+	}{
+		{"EXPLAIN", ""},
+		{"EXPLAIN EXPLAIN SELECT name FROM pods", ""},
+		{"SELECT name FROM pods EXPLAIN", ""},
+		{"EXPLIAN SELECT name FROM pods", "EXPLAIN"},
+	}
+	for _, tc := range errorCases {
+		_, err := NewParser(tc.input).Parse()
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("For input '%s', expected a ParseError, got %v", tc.input, err)
+			continue
+		}
+		if parseErr.Suggestion != tc.suggestion {
+			t.Errorf("For input '%s', expected suggestion '%s', got '%s'", tc.input, tc.suggestion, parseErr.Suggestion)
+		}
+	}
+}
+
+func TestPlanPushdown(t *testing.T) {
+	testCases := []struct {
+		query         string
+		labelSelector string
+		fieldSelector string
+		condition     string
+	}{
+		{
+			query:         "SELECT name FROM pods WHERE labels.app = 'web' AND status.phase = 'Running'",
+			labelSelector: "app=web",
+			fieldSelector: "status.phase=Running",
+		},
+		{
+			query:         "SELECT name FROM pods WHERE metadata.labels.`app.kubernetes.io/name` IN ('web', 'api') AND labels.canary IS NULL AND labels.tier IS NOT NULL",
+			labelSelector: "app.kubernetes.io/name in (api,web),!canary,tier",
+		},
+		{
+			query:         "SELECT name FROM pods WHERE labels.tier != 'db' AND labels.env NOT IN ('dev')",
+			labelSelector: "tier!=db,env notin (dev)",
+			condition:     "labels.tier != 'db' AND labels.env NOT IN ('dev')",
+		},
+		{
+			query:         "SELECT name FROM pods WHERE 'node-1' = spec.nodeName AND (name = 'a' AND namespace = 'b')",
+			fieldSelector: "spec.nodeName=node-1,metadata.name=a,metadata.namespace=b",
+		},
+		{
+			query:         "SELECT name FROM pods WHERE labels.app = 'web' OR labels.app = 'api'",
+			labelSelector: "app in (api,web)",
+		},
+		{
+			query:         "SELECT name FROM pods WHERE NOT (labels.app = 'web' OR status.phase = 'Failed') AND TRUE",
+			labelSelector: "app!=web",
+			fieldSelector: "status.phase!=Failed",
+			condition:     "labels.app != 'web' AND status.phase != 'Failed'",
+		},
+		{
+			query:     "SELECT name FROM pods WHERE labels.app = 'web' OR status.phase = 'Failed'",
+			condition: "labels.app = 'web' OR status.phase = 'Failed'",
+		},
+		{
+			query:     "SELECT name FROM pods WHERE status.phase LIKE 'Run%' AND len(spec.containers) > 1",
+			condition: "status.phase LIKE 'Run%' AND len(spec.containers) > 1",
+		},
+		{
+			query:     "SELECT name FROM pods WHERE labels.app = 'not valid' AND labels.version = 2 AND spec.nodeName = ''",
+			condition: "labels.app = 'not valid' AND labels.version = 2 AND spec.nodeName = ''",
+		},
+		{
+			query:     "SELECT name FROM deployments WHERE status.phase = 'Running' AND type = 'x'",
+			condition: "status.phase = 'Running' AND type = 'x'",
+		},
+		{
+			query:         "SELECT name FROM secrets WHERE type = 'kubernetes.io/tls'",
+			fieldSelector: "type=kubernetes.io/tls",
+		},
+		{
+			query:     "SELECT name FROM nodes WHERE namespace = 'a'",
+			condition: "namespace = 'a'",
+		},
+		{
+			query:         "SELECT p.name FROM pods p WHERE p.labels.app = 'web' AND p.status.phase = 'Running'",
+			labelSelector: "app=web",
+			fieldSelector: "status.phase=Running",
+		},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.query).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.query, err)
+		}
+		plan, err := Explain(query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.query, err)
+			continue
+		}
+
+		list, condition := plan.Root, ""
+		for len(list.Children) > 0 {
+			if list.Op == FilterOp {
+				condition = list.Condition
+			}
+			list = list.Children[0]
+		}
+		if list.LabelSelector != tc.labelSelector {
+			t.Errorf("For input '%s', expected label selector '%s', got '%s'", tc.query, tc.labelSelector, list.LabelSelector)
+		}
+		if list.FieldSelector != tc.fieldSelector {
+			t.Errorf("For input '%s', expected field selector '%s', got '%s'", tc.query, tc.fieldSelector, list.FieldSelector)
+		}
+		if condition != tc.condition {
+			t.Errorf("For input '%s', expected client side condition '%s', got '%s'", tc.query, tc.condition, condition)
+		}
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query: "SELECT name, status.phase AS phase FROM default/pods WHERE labels.app = 'web' AND name LIKE 'web%' ORDER BY created DESC LIMIT 10",
+			expected: `Limit: 10 (rows=5 cost=10.27)
+-> Sort in memory: created DESC (rows=5 cost=10.27)
+  -> Project: name, status.phase AS phase (rows=5 cost=10.15)
+    -> Filter client side: name LIKE 'web%' (rows=5 cost=10.10)
+      -> List pods (v1 Pod) namespace=default labelSelector="app=web" (rows=10 cost=10.00)
+`,
+		},
+		{
+			query: "SELECT * FROM pods",
+			expected: `List pods (v1 Pod) (rows=1000 cost=1000.00)
+Warning: lists every object of pods in all namespaces, no namespace, label or field selector applies
+`,
+		},
+		{
+			query: "SELECT name FROM nodes LIMIT 5",
+			expected: `Limit: 5 (rows=5 cost=5.05)
+-> Project: name (rows=5 cost=5.05)
+  -> List nodes (v1 Node) limit=5 (rows=5 cost=5.00)
+`,
+		},
+		{
+			query: "SELECT count(*) FROM deployments WHERE labels.app = 'web' LIMIT 0",
+			expected: `Limit: 0 (rows=0 cost=101.00)
+-> Aggregate: count(*) (rows=1 cost=101.00)
+  -> List deployments (apps/v1 Deployment) labelSelector="app=web" (rows=100 cost=100.00)
+`,
+		},
+		{
+			query: "SELECT name FROM default/pods WHERE NOT (labels.app != 'web' OR labels.tier = 'db') ORDER BY name, name LIMIT 0",
+			expected: `Limit: 0 (rows=0 cost=9.18)
+-> Project: name (rows=9 cost=9.18)
+  -> Filter client side: labels.tier != 'db' (rows=9 cost=9.09)
+    -> List pods (v1 Pod) namespace=default labelSelector="app=web,tier!=db" (rows=9 cost=9.00)
+Rewrites: de-morgan, flatten-logic, redundant-order-by, limit-zero-sort
+`,
+		},
+		{
+			query: "SELECT p.name, n.name FROM default/pods p JOIN nodes n ON p.spec.nodeName = n.name AND n.labels.role = 'worker' LEFT JOIN services s ON s.spec.selector.app = p.labels.app WHERE p.labels.app = 'web' AND s.name IS NULL ORDER BY p.name",
+			expected: `Sort in memory: p.name ASC (rows=1 cost=1121.32)
+-> Project: p.name, n.name (rows=1 cost=1121.31)
+  -> Filter client side: s.name IS NULL (rows=1 cost=1121.30)
+    -> Join LEFT (hash) on s.spec.selector.app = p.labels.app (rows=10 cost=1121.20)
+      -> Join INNER (hash) on p.spec.nodeName = n.name AND n.labels.role = 'worker' (rows=10 cost=111.10)
+        -> List pods (v1 Pod) as p namespace=default labelSelector="app=web" (rows=10 cost=10.00)
+        -> List nodes (v1 Node) as n labelSelector="role=worker" (rows=100 cost=100.00)
+      -> List services (v1 Service) as s (rows=1000 cost=1000.00)
+Warning: lists every object of services in all namespaces, no namespace, label or field selector applies
+`,
+		},
+		{
+			query: "SELECT p.name FROM pods p JOIN nodes n ON p.spec.nodeName != n.name",
+			expected: `Project: p.name (rows=500000 cost=17000.00)
+-> Join INNER (nested loop) on p.spec.nodeName != n.name (rows=500000 cost=12000.00)
+  -> List pods (v1 Pod) as p (rows=1000 cost=1000.00)
+  -> List nodes (v1 Node) as n (rows=1000 cost=1000.00)
+Warning: lists every object of pods in all namespaces, no namespace, label or field selector applies
+Warning: lists every object of nodes, no label or field selector applies
+`,
+		}, {
+			// Relationship functions are hash joined
+			query: "SELECT p.name FROM default/pods p JOIN replicasets rs ON owns(rs, p)",
+			expected: `Project: p.name (rows=100 cost=1112.00)
+-> Join INNER (hash) on owns(rs, p) (rows=100 cost=1111.00)
+  -> List pods (v1 Pod) as p namespace=default (rows=100 cost=100.00)
+  -> List replicasets (apps/v1 ReplicaSet) as rs (rows=1000 cost=1000.00)
+Warning: lists every object of replicasets in all namespaces, no namespace, label or field selector applies
+`,
+		},
+		{
+			// Subqueries using no enclosing table run once
+			query: "SELECT name FROM default/pods WHERE spec.nodeName IN (SELECT name FROM nodes WHERE labels.role = 'worker')",
+			expected: `Project: name (rows=50 cost=202.50)
+-> Filter client side: spec.nodeName IN (SELECT name FROM nodes WHERE labels.role = 'worker') (rows=50 cost=202.00)
+  -> List pods (v1 Pod) namespace=default (rows=100 cost=100.00)
+  -> Subquery runs once (rows=100 cost=101.00)
+    -> Project: name (rows=100 cost=101.00)
+      -> List nodes (v1 Node) labelSelector="role=worker" (rows=100 cost=100.00)
+`,
+		},
+		{
+			query: "SELECT name FROM default/pods p WHERE NOT EXISTS (SELECT name FROM nodes n WHERE n.name = p.spec.nodeName)",
+			expected: `Project: name (rows=50 cost=2102.50)
+-> Filter client side: NOT EXISTS (SELECT name FROM nodes n WHERE n.name = p.spec.nodeName) (rows=50 cost=2102.00)
+  -> List pods (v1 Pod) namespace=default (rows=100 cost=100.00)
+  -> Subquery runs for every row (rows=1 cost=2001.00)
+    -> Project: name (rows=1 cost=1010.01)
+      -> Filter client side: n.name = p.spec.nodeName (rows=1 cost=1010.00)
+        -> List nodes (v1 Node) (rows=1000 cost=1000.00)
+Warning: lists every object of nodes, no label or field selector applies
+`,
+		},
+		{
+			// Common table expressions are planned first, and optimized
+			query: "WITH bad AS (SELECT name, namespace FROM pods WHERE status.phase != 'Running' AND NOT (labels.app != 'web')), ns AS (SELECT name FROM namespaces WHERE name = 'default') SELECT b.name FROM bad b JOIN ns ON ns.name = b.namespace WHERE b.name LIKE 'web%' AND b.namespace IN (SELECT name FROM ns) LIMIT 5",
+			expected: `CTE bad (rows=90 cost=91.80)
+-> Project: name, namespace (rows=90 cost=91.80)
+  -> Filter client side: status.phase != 'Running' (rows=90 cost=90.90)
+    -> List pods (v1 Pod) labelSelector="app=web" fieldSelector="status.phase!=Running" (rows=90 cost=90.00)
+CTE ns (rows=1 cost=1.01)
+-> Project: name (rows=1 cost=1.01)
+  -> List namespaces (v1 Namespace) fieldSelector="metadata.name=default" (rows=1 cost=1.00)
+Limit: 5 (rows=5 cost=2.97)
+-> Project: b.name (rows=23 cost=2.97)
+  -> Filter client side: b.name LIKE 'web%' AND b.namespace IN (SELECT name FROM ns) (rows=23 cost=2.74)
+    -> Join INNER (hash) on ns.name = b.namespace (rows=90 cost=1.82)
+      -> Scan bad as b (rows=90 cost=0.90)
+      -> Scan ns as ns (rows=1 cost=0.01)
+    -> Subquery runs once (rows=1 cost=0.02)
+      -> Project: name (rows=1 cost=0.02)
+        -> Scan ns (rows=1 cost=0.01)
+Rewrites: de-morgan
+`,
+		},
+		{
+			// Only conditions of resource tables become selectors
+			query: "WITH pods AS (SELECT * FROM kube-system/pods) SELECT name FROM pods WHERE labels.app = 'dns' LIMIT 1",
+			expected: `CTE pods (rows=100 cost=100.00)
+-> List pods (v1 Pod) namespace=kube-system (rows=100 cost=100.00)
+Limit: 1 (rows=1 cost=2.10)
+-> Project: name (rows=10 cost=2.10)
+  -> Filter client side: labels.app = 'dns' (rows=10 cost=2.00)
+    -> Scan pods (rows=100 cost=1.00)
+`,
+		},
+		{
+			// INTERSECT binds tighter, ORDER BY and LIMIT apply to the combined rows
+			query: "SELECT name FROM default/deployments UNION ALL SELECT name FROM default/statefulsets EXCEPT SELECT name FROM default/poddisruptionbudgets INTERSECT SELECT name FROM default/pods WHERE labels.app = 'web' ORDER BY name DESC LIMIT 3",
+			expected: `Limit: 3 (rows=3 cost=331.59)
+-> Sort in memory: name DESC (rows=200 cost=331.59)
+  -> Except distinct (rows=200 cost=316.30)
+    -> Union all (rows=200 cost=202.00)
+      -> Project: name (rows=100 cost=101.00)
+        -> List deployments (apps/v1 Deployment) namespace=default (rows=100 cost=100.00)
+      -> Project: name (rows=100 cost=101.00)
+        -> List statefulsets (apps/v1 StatefulSet) namespace=default (rows=100 cost=100.00)
+    -> Intersect distinct (rows=10 cost=112.20)
+      -> Project: name (rows=100 cost=101.00)
+        -> List poddisruptionbudgets (policy/v1 PodDisruptionBudget) namespace=default (rows=100 cost=100.00)
+      -> Project: name (rows=10 cost=10.10)
+        -> List pods (v1 Pod) namespace=default labelSelector="app=web" (rows=10 cost=10.00)
+`,
+		},
+		{
+			// Set operations of the same kind are merged
+			query: "SELECT name FROM nodes WHERE labels.role = 'worker' UNION SELECT name FROM nodes WHERE labels.role = 'infra' UNION SELECT name FROM nodes LIMIT 1",
+			expected: `Limit: 1 (rows=1 cost=1224.00)
+-> Union distinct (rows=1200 cost=1224.00)
+  -> Project: name (rows=100 cost=101.00)
+    -> List nodes (v1 Node) labelSelector="role=worker" (rows=100 cost=100.00)
+  -> Project: name (rows=100 cost=101.00)
+    -> List nodes (v1 Node) labelSelector="role=infra" (rows=100 cost=100.00)
+  -> Project: name (rows=1000 cost=1010.00)
+    -> List nodes (v1 Node) (rows=1000 cost=1000.00)
+Warning: lists every object of nodes, no label or field selector applies
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.query).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.query, err)
+		}
+		plan, err := Explain(query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.query, err)
+			continue
+		}
+		if plan.String() != tc.expected {
+			t.Errorf("For input '%s', expected plan:\n%s\ngot:\n%s", tc.query, tc.expected, plan.String())
+		}
+	}
+}
+
+func TestPlanCounts(t *testing.T) {
+	planner := NewPlanner(DefaultResolver())
+	planner.Counts = func(resource ResourceInfo) int {
+		if resource.Name == "pods" {
+			return 20
+		}
+		return 0
+	}
+
+	query, _ := NewParser("SELECT name FROM pods WHERE name = 'web-1'").Parse()
+	plan, err := planner.Plan(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if plan.Root.EstimatedRows != 1 || plan.Root.Children[0].EstimatedRows != 1 {
+		t.Errorf("Expected 1 row for a name lookup, got %s", plan)
+	}
+
+	query, _ = NewParser("SELECT name FROM pods").Parse()
+	if plan, _ = planner.Plan(query); plan.Root.EstimatedRows != 20 {
+		t.Errorf("Expected 20 rows, got %s", plan)
+	}
+
+	query, _ = NewParser("SELECT name FROM podz").Parse()
+	if _, err := planner.Plan(query); err == nil || !strings.Contains(err.Error(), "unknown resource 'podz'") {
+		t.Errorf("Expected an unknown resource error, got %v", err)
+	}
+}
+
+func TestPlanJSON(t *testing.T) {
+	query, _ := NewParser("EXPLAIN SELECT name FROM pods LIMIT 0").Parse()
+	plan, err := Explain(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, want := range []string{`"query":"EXPLAIN SELECT name FROM pods LIMIT 0"`, `"op":"Limit","limit":0`, `"op":"List","resource":"pods","apiVersion":"v1","kind":"Pod","limit":0`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected JSON to contain %s, got %s", want, data)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package kubesql
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// relations lists the relationship functions by lower case name. They take
+// two objects, usually table names of a join, and report whether the first
+// one is related to the second one:
+//
+//	owns(owner, object)       object has an owner reference to owner
+//	selects(selector, object) the label selector of selector matches object
+//	mounts(pod, object)       a volume of pod uses the configmap, secret or pvc
+var relations = map[string]func(x, y map[string]any) bool{
+	"owns":    owns,
+	"selects": selects,
+	"mounts":  mounts,
+}
+
+// relationKeys returns hash keys of a relationship function call between
+// two tables of a join: an expression over each table, equal when the
+// tables can be related. It reports false for other expressions.
+func relationKeys(expr Expr, names []string) (x, y Expr, ok bool) {
+	call, ok := expr.(*CallExpr)
+	if !ok || relations[strings.ToLower(call.Func)] == nil || len(call.Args) != 2 || len(names) < 2 {
+		return nil, nil, false
+	}
+	tables := make([]string, 2)
+	for i, arg := range call.Args {
+		ref, ok := arg.(*FieldRef)
+		if !ok || len(ref.Path) != 1 || !slices.Contains(names, ref.Path[0].Name) {
+			return nil, nil, false
+		}
+		tables[i] = ref.Path[0].Name
+	}
+	field := func(table string, path ...Segment) Expr {
+		return &FieldRef{Path: append([]Segment{{Name: table}, {Name: "metadata"}}, path...)}
+	}
+
+	// Owners are matched by name, the other relations by namespace
+	if strings.ToLower(call.Func) == "owns" {
+		return field(tables[0], Segment{Name: "name"}),
+			field(tables[1], Segment{Name: "ownerReferences"}, Segment{Index: -1, Array: true}, Segment{Name: "name"}), true
+	}
+	return field(tables[0], Segment{Name: "namespace"}), field(tables[1], Segment{Name: "namespace"}), true
+}
+
+// relationResult types the relationship functions, of two objects.
+func relationResult(args []Type) (Type, error) {
+	for _, t := range args {
+		switch t {
+		case TypeMap, TypeUnknown, TypeNull:
+		default:
+			return TypeBool, fmt.Errorf("expected an object, got %s", article(t.String()))
+		}
+	}
+	return TypeBool, nil
+}
+
+// owns reports whether object has an owner reference to owner, with its
+// kind and name, and its uid when both are known. Namespaced owners must be
+// in the namespace of the object.
+func owns(owner, object map[string]any) bool {
+	name := stringAt(owner, "metadata", "name")
+	if name == "" {
+		return false
+	}
+	if namespace := stringAt(owner, "metadata", "namespace"); namespace != "" && namespace != stringAt(object, "metadata", "namespace") {
+		return false
+	}
+	kind := stringAt(owner, "kind")
+	uid := stringAt(owner, "metadata", "uid")
+	references, _ := fieldValue(object, segments("metadata", "ownerReferences")).([]any)
+	for _, item := range references {
+		reference, _ := item.(map[string]any)
+		switch {
+		case stringAt(reference, "name") != name:
+		case kind != "" && stringAt(reference, "kind") != kind:
+		case uid != "" && stringAt(reference, "uid") != "" && stringAt(reference, "uid") != uid:
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// selects reports whether the label selector of selector matches the labels
+// of object, in the same namespace. The selector is the spec.selector of
+// services and workloads, the spec.podSelector of network policies. Label
+// selectors with matchLabels and matchExpressions follow the Kubernetes
+// rules, an empty one matching every object; selectors that are maps of
+// labels, like the ones of services, match nothing when empty.
+func selects(selector, object map[string]any) bool {
+	if stringAt(selector, "metadata", "namespace") != stringAt(object, "metadata", "namespace") {
+		return false
+	}
+	field := "selector"
+	if stringAt(selector, "kind") == "NetworkPolicy" {
+		field = "podSelector"
+	}
+	spec, _ := fieldValue(selector, segments("spec", field)).(map[string]any)
+	if spec == nil {
+		return false
+	}
+	labels, _ := fieldValue(object, segments("metadata", "labels")).(map[string]any)
+
+	_, hasLabels := spec["matchLabels"]
+	_, hasExpressions := spec["matchExpressions"]
+	if !hasLabels && !hasExpressions && (len(spec) > 0 || stringAt(selector, "kind") == "Service") {
+		// A map of labels
+		return len(spec) > 0 && matchLabels(spec, labels)
+	}
+	matches, _ := spec["matchLabels"].(map[string]any)
+	if !matchLabels(matches, labels) {
+		return false
+	}
+	expressions, _ := spec["matchExpressions"].([]any)
+	for _, item := range expressions {
+		expression, _ := item.(map[string]any)
+		if !matchExpression(expression, labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLabels reports whether labels have every label of matches.
+func matchLabels(matches, labels map[string]any) bool {
+	for key, value := range matches {
+		if label, ok := labels[key]; !ok || label != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchExpression reports whether labels match a label selector
+// requirement: In, NotIn, Exists or DoesNotExist.
+func matchExpression(expression, labels map[string]any) bool {
+	label, ok := labels[stringAt(expression, "key")]
+	values, _ := expression["values"].([]any)
+	switch stringAt(expression, "operator") {
+	case "In":
+		return ok && slices.Contains(values, label)
+	case "NotIn":
+		return !ok || !slices.Contains(values, label)
+	case "Exists":
+		return ok
+	case "DoesNotExist":
+		return !ok
+	}
+	return false
+}
+
+// mounts reports whether a volume of pod uses object, a configmap, secret
+// or persistent volume claim in its namespace, directly or in a projected
+// volume. Workloads and cron jobs mount the volumes of their pod template.
+func mounts(pod, object map[string]any) bool {
+	if stringAt(pod, "metadata", "namespace") != stringAt(object, "metadata", "namespace") {
+		return false
+	}
+	name := stringAt(object, "metadata", "name")
+	if name == "" {
+		return false
+	}
+
+	// Volume fields naming an object of each kind
+	var references [][]string
+	switch stringAt(object, "kind") {
+	case "ConfigMap":
+		references = [][]string{{"configMap", "name"}}
+	case "Secret":
+		references = [][]string{{"secret", "secretName"}, {"secret", "name"}}
+	case "PersistentVolumeClaim":
+		references = [][]string{{"persistentVolumeClaim", "claimName"}}
+	default:
+		return false
+	}
+
+	spec, _ := pod["spec"].(map[string]any)
+	for _, path := range [][]string{{"template", "spec"}, {"jobTemplate", "spec", "template", "spec"}} {
+		if template, ok := fieldValue(spec, segments(path...)).(map[string]any); ok {
+			spec = template
+			break
+		}
+	}
+	volumes, _ := fieldValue(spec, segments("volumes")).([]any)
+	for _, item := range volumes {
+		volume, _ := item.(map[string]any)
+		sources, _ := fieldValue(volume, segments("projected", "sources")).([]any)
+		for _, source := range append([]any{volume}, sources...) {
+			source, _ := source.(map[string]any)
+			for _, reference := range references {
+				if stringAt(source, reference...) == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// segments returns the path of nested object fields.
+func segments(names ...string) []Segment {
+	path := make([]Segment, len(names))
+	for i, name := range names {
+		path[i] = Segment{Name: name}
+	}
+	return path
+}
+
+// stringAt returns the string at a path of nested object fields, empty if
+// missing.
+func stringAt(object map[string]any, names ...string) string {
+	value, _ := fieldValue(object, segments(names...)).(string)
+	return value
+}
@@ -0,0 +1,76 @@
+package kubesql
+
+import "testing"
+
+func TestRelations(t *testing.T) {
+	pod := func(labels map[string]any, owners ...any) map[string]any {
+		return map[string]any{"kind": "Pod", "metadata": map[string]any{"name": "web-1", "namespace": "default", "labels": labels, "ownerReferences": owners}}
+	}
+	object := func(kind, namespace, name string, fields map[string]any) map[string]any {
+		object := map[string]any{"kind": kind, "metadata": map[string]any{"name": name, "namespace": namespace, "uid": name + "-uid"}}
+		for key, value := range fields {
+			object[key] = value
+		}
+		return object
+	}
+	owner := func(kind, name, uid string) map[string]any {
+		return map[string]any{"kind": kind, "name": name, "uid": uid}
+	}
+	web := map[string]any{"app": "web", "tier": "front"}
+	volumes := func(volumes ...any) map[string]any {
+		return map[string]any{"volumes": volumes}
+	}
+
+	testCases := []struct {
+		name     string
+		relation string
+		x, y     map[string]any
+		expected bool
+	}{
+		{"owner", "owns", object("ReplicaSet", "default", "web", nil), pod(web, owner("ReplicaSet", "web", "web-uid")), true},
+		{"owner without uid", "owns", object("ReplicaSet", "default", "web", nil), pod(web, owner("ReplicaSet", "web", "")), true},
+		{"other uid", "owns", object("ReplicaSet", "default", "web", nil), pod(web, owner("ReplicaSet", "web", "old-uid")), false},
+		{"other kind", "owns", object("StatefulSet", "default", "web", nil), pod(web, owner("ReplicaSet", "web", "")), false},
+		{"other namespace", "owns", object("ReplicaSet", "prod", "web", nil), pod(web, owner("ReplicaSet", "web", "")), false},
+		{"cluster scoped owner", "owns", object("Node", "", "web", nil), pod(web, owner("Node", "web", "")), true},
+		{"no owner", "owns", object("ReplicaSet", "default", "web", nil), pod(web), false},
+
+		{"service", "selects", object("Service", "default", "web", map[string]any{"spec": map[string]any{"selector": map[string]any{"app": "web"}}}), pod(web), true},
+		{"service other label", "selects", object("Service", "default", "web", map[string]any{"spec": map[string]any{"selector": map[string]any{"app": "db"}}}), pod(web), false},
+		{"service empty selector", "selects", object("Service", "default", "web", map[string]any{"spec": map[string]any{"selector": map[string]any{}}}), pod(web), false},
+		{"service other namespace", "selects", object("Service", "prod", "web", map[string]any{"spec": map[string]any{"selector": map[string]any{"app": "web"}}}), pod(web), false},
+		{"match labels", "selects", object("Deployment", "default", "web", map[string]any{"spec": map[string]any{"selector": map[string]any{"matchLabels": map[string]any{"app": "web"}}}}), pod(web), true},
+		{"empty label selector", "selects", object("PodDisruptionBudget", "default", "web", map[string]any{"spec": map[string]any{"selector": map[string]any{}}}), pod(web), true},
+		{"no selector", "selects", object("PodDisruptionBudget", "default", "web", nil), pod(web), false},
+		{"in", "selects", object("Deployment", "default", "web", map[string]any{"spec": map[string]any{"selector": map[string]any{"matchExpressions": []any{
+			map[string]any{"key": "app", "operator": "In", "values": []any{"web", "api"}},
+			map[string]any{"key": "tier", "operator": "Exists"},
+		}}}}), pod(web), true},
+		{"not in", "selects", object("Deployment", "default", "web", map[string]any{"spec": map[string]any{"selector": map[string]any{"matchExpressions": []any{
+			map[string]any{"key": "app", "operator": "NotIn", "values": []any{"web"}},
+		}}}}), pod(web), false},
+		{"does not exist", "selects", object("Deployment", "default", "web", map[string]any{"spec": map[string]any{"selector": map[string]any{
+			"matchLabels":      map[string]any{"app": "web"},
+			"matchExpressions": []any{map[string]any{"key": "tier", "operator": "DoesNotExist"}},
+		}}}), pod(web), false},
+		{"network policy", "selects", object("NetworkPolicy", "default", "web", map[string]any{"spec": map[string]any{"podSelector": map[string]any{"matchLabels": map[string]any{"tier": "front"}}}}), pod(web), true},
+
+		{"configmap", "mounts", object("Pod", "default", "web-1", map[string]any{"spec": volumes(map[string]any{"configMap": map[string]any{"name": "config"}})}), object("ConfigMap", "default", "config", nil), true},
+		{"other configmap", "mounts", object("Pod", "default", "web-1", map[string]any{"spec": volumes(map[string]any{"configMap": map[string]any{"name": "other"}})}), object("ConfigMap", "default", "config", nil), false},
+		{"secret", "mounts", object("Pod", "default", "web-1", map[string]any{"spec": volumes(map[string]any{"secret": map[string]any{"secretName": "tls"}})}), object("Secret", "default", "tls", nil), true},
+		{"secret named like a configmap", "mounts", object("Pod", "default", "web-1", map[string]any{"spec": volumes(map[string]any{"configMap": map[string]any{"name": "tls"}})}), object("Secret", "default", "tls", nil), false},
+		{"projected secret", "mounts", object("Pod", "default", "web-1", map[string]any{"spec": volumes(map[string]any{"projected": map[string]any{"sources": []any{
+			map[string]any{"secret": map[string]any{"name": "tls"}},
+		}}})}), object("Secret", "default", "tls", nil), true},
+		{"claim", "mounts", object("Pod", "default", "db-1", map[string]any{"spec": volumes(map[string]any{"persistentVolumeClaim": map[string]any{"claimName": "data"}})}), object("PersistentVolumeClaim", "default", "data", nil), true},
+		{"claim other namespace", "mounts", object("Pod", "default", "db-1", map[string]any{"spec": volumes(map[string]any{"persistentVolumeClaim": map[string]any{"claimName": "data"}})}), object("PersistentVolumeClaim", "prod", "data", nil), false},
+		{"cron job template", "mounts", object("CronJob", "default", "backup", map[string]any{"spec": map[string]any{"jobTemplate": map[string]any{"spec": map[string]any{"template": map[string]any{"spec": volumes(map[string]any{"configMap": map[string]any{"name": "config"}})}}}}}), object("ConfigMap", "default", "config", nil), true},
+		{"not a volume kind", "mounts", object("Pod", "default", "web-1", map[string]any{"spec": volumes(map[string]any{"configMap": map[string]any{"name": "config"}})}), object("Service", "default", "config", nil), false},
+	}
+
+	for _, tc := range testCases {
+		if result := relations[tc.relation](tc.x, tc.y); result != tc.expected {
+			t.Errorf("For input '%s', expected %s to be %v, got %v", tc.name, tc.relation, tc.expected, result)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package kubesql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ResourceInfo describes a Kubernetes resource type that can appear in a
+// FROM clause.
+type ResourceInfo struct {
+	Name       string   // Plural resource name, e.g. "pods"
+	Singular   string   // Singular resource name, e.g. "pod"
+	ShortNames []string // Short names, e.g. "po"
+	Kind       string   // Object kind, e.g. "Pod"
+	Group      string   // API group, empty for the core group
+	Version    string   // Preferred API version, e.g. "v1"
+	Namespaced bool     // Whether objects of this resource live in a namespace
+}
+
+// Names returns every name the resource can be referred to by: the plural,
+// singular and short names, and the plural qualified by the API group.
+func (r ResourceInfo) Names() []string {
+	names := []string{r.Name}
+	if r.Singular != "" {
+		names = append(names, r.Singular)
+	}
+	names = append(names, r.ShortNames...)
+	if r.Group != "" {
+		names = append(names, r.Name+"."+r.Group)
+	}
+	return names
+}
+
+// ResourceRef is the resource named in a FROM clause.
+type ResourceRef struct {
+	Clusters  []string // Cluster name patterns to query, e.g. "prod-*", empty for the default cluster
+	Namespace string   // Namespace to query, empty for the default scope
+	Resource  string   // Resource name as written, e.g. "pods", "po" or "deployments.apps"
+}
+
+// String returns the reference in FROM clause form.
+func (r ResourceRef) String() string {
+	text := quoteResourceName(r.Resource)
+	if r.Namespace != "" {
+		text = quoteResourceName(r.Namespace) + "/" + text
+	}
+	if len(r.Clusters) > 0 {
+		patterns := make([]string, len(r.Clusters))
+		for i, pattern := range r.Clusters {
+			patterns[i] = quoteClusterPattern(pattern)
+		}
+		text = strings.Join(patterns, ",") + ":" + text
+	}
+	return text
+}
+
+// quoteResourceName quotes a namespace or resource name if it can not be
+// written bare in a FROM clause.
+func quoteResourceName(name string) string {
+	tokens, err := tokenize(name)
+	if err == nil && len(splitWords(tokens)) == 1 && !strings.ContainsAny(name, "/`\"'") && !isReservedKeyword(name) {
+		return name
+	}
+	return quoteIdentifier(name)
+}
+
+// quoteClusterPattern quotes a cluster name pattern if it can not be
+// written bare in a FROM clause.
+func quoteClusterPattern(pattern string) string {
+	for _, char := range pattern {
+		if char != '*' && char != '.' && !isIdentPart(char) {
+			return quoteIdentifier(pattern)
+		}
+	}
+	if pattern == "" || isReservedKeyword(pattern) {
+		return quoteIdentifier(pattern)
+	}
+	return pattern
+}
+
+// ParseResourceRef parses the content of a FROM clause, a resource name
+// optionally prefixed by a namespace, e.g. "pods" or "kube-system/pods",
+// and by a comma separated list of cluster name patterns, e.g.
+// "prod-*,staging:kube-system/pods". Names may be quoted identifiers.
+func ParseResourceRef(from string) (ResourceRef, error) {
+	tokens, err := tokenize(from)
+	if err != nil {
+		return ResourceRef{}, err
+	}
+
+	// Cluster patterns come before the first colon
+	var clusters []string
+	for i, tok := range tokens {
+		if tok.kind != tokenColon {
+			continue
+		}
+		if i == 0 {
+			return ResourceRef{}, fmt.Errorf("invalid cluster in '%s': missing name", from)
+		}
+		for _, part := range splitTokens(tokens[:i], tokenComma) {
+			pattern, err := clusterPattern(part)
+			if err != nil {
+				return ResourceRef{}, fmt.Errorf("invalid cluster in '%s': %v", from, err)
+			}
+			clusters = append(clusters, pattern)
+		}
+		tokens = tokens[i+1:]
+		break
+	}
+
+	// Split on the first top level slash
+	parts := [][]token{tokens}
+	for i, tok := range tokens {
+		if tok.kind == tokenOperator && tok.text == "/" {
+			parts = [][]token{tokens[:i], tokens[i+1:]}
+			break
+		}
+	}
+
+	var names []string
+	for _, part := range parts {
+		name, err := resourceName(part)
+		if err != nil {
+			return ResourceRef{}, fmt.Errorf("invalid resource '%s': %v", from, err)
+		}
+		names = append(names, name)
+	}
+
+	if len(names) == 1 {
+		return ResourceRef{Clusters: clusters, Resource: names[0]}, nil
+	}
+	return ResourceRef{Clusters: clusters, Namespace: names[0], Resource: names[1]}, nil
+}
+
+// clusterPattern returns the cluster name pattern spelled by tokens, a
+// quoted identifier or a name where * matches any characters.
+func clusterPattern(tokens []token) (string, error) {
+	if len(tokens) == 1 && tokens[0].kind == tokenQuotedIdent {
+		return tokens[0].value, nil
+	}
+
+	var pattern strings.Builder
+	for _, tok := range tokens {
+		switch {
+		case tok.kind == tokenIdent, tok.kind == tokenNumber, tok.kind == tokenDot:
+			pattern.WriteString(tok.text)
+		case tok.kind == tokenOperator && (tok.text == "*" || tok.text == "-"):
+			pattern.WriteString(tok.text)
+		default:
+			return "", fmt.Errorf("unexpected '%s'", tok.text)
+		}
+	}
+	if pattern.Len() == 0 {
+		return "", fmt.Errorf("missing name")
+	}
+	return pattern.String(), nil
+}
+
+// MatchCluster reports whether a cluster name matches a pattern of a FROM
+// clause, where * matches any characters, including none.
+func MatchCluster(pattern, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, parts[0]) || !strings.HasSuffix(name[len(parts[0]):], parts[len(parts)-1]) {
+		return false
+	}
+	rest := name[len(parts[0]) : len(name)-len(parts[len(parts)-1])]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	return true
+}
+
+// resourceName returns the name spelled by the tokens of a namespace or
+// resource, e.g. "deployments.apps" or a single quoted identifier.
+func resourceName(tokens []token) (string, error) {
+	if len(tokens) == 1 && tokens[0].kind == tokenQuotedIdent {
+		return tokens[0].value, nil
+	}
+
+	var name strings.Builder
+	for _, tok := range tokens {
+		switch tok.kind {
+		case tokenIdent, tokenNumber, tokenDot:
+			name.WriteString(tok.text)
+		default:
+			return "", fmt.Errorf("unexpected '%s'", tok.text)
+		}
+	}
+	if name.Len() == 0 {
+		return "", fmt.Errorf("missing name")
+	}
+	return name.String(), nil
+}
+
+// Resolver maps resource names used in FROM clauses to resource types.
+type Resolver interface {
+	// Resolve returns the resource known by name, which may be a plural,
+	// singular, short or group qualified name.
+	Resolve(name string) (ResourceInfo, error)
+
+	// Resources lists all known resources.
+	Resources() []ResourceInfo
+}
+
+// StaticResolver is a Resolver over a fixed list of resources.
+type StaticResolver struct {
+	resources []ResourceInfo
+	byName    map[string]int // Lower case name to index in resources
+}
+
+// NewResolver creates a resolver for the given resources. Later resources
+// take precedence when names collide.
+func NewResolver(resources ...ResourceInfo) *StaticResolver {
+	r := &StaticResolver{byName: make(map[string]int)}
+	r.Add(resources...)
+	return r
+}
+
+// DefaultResolver returns a resolver for the built-in Kubernetes resources.
+func DefaultResolver() *StaticResolver {
+	return NewResolver(builtinResources...)
+}
+
+// Add registers additional resources, e.g. custom resource definitions.
+func (r *StaticResolver) Add(resources ...ResourceInfo) {
+	for _, resource := range resources {
+		r.resources = append(r.resources, resource)
+		for _, name := range resource.Names() {
+			r.byName[strings.ToLower(name)] = len(r.resources) - 1
+		}
+	}
+}
+
+// Resolve implements Resolver.
+func (r *StaticResolver) Resolve(name string) (ResourceInfo, error) {
+	if index, ok := r.byName[strings.ToLower(name)]; ok {
+		return r.resources[index], nil
+	}
+	return ResourceInfo{}, fmt.Errorf("unknown resource '%s'", name)
+}
+
+// Resources implements Resolver. Resources are sorted by name.
+func (r *StaticResolver) Resources() []ResourceInfo {
+	var resources []ResourceInfo
+	for i, resource := range r.resources {
+		// Skip resources shadowed by a later registration
+		if r.byName[strings.ToLower(resource.Name)] == i {
+			resources = append(resources, resource)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].Name < resources[j].Name })
+	return resources
+}
+
+// builtinResources lists the commonly used built-in Kubernetes resources.
+var builtinResources = []ResourceInfo{
+	{Name: "configmaps", Singular: "configmap", ShortNames: []string{"cm"}, Kind: "ConfigMap", Version: "v1", Namespaced: true},
+	{Name: "endpoints", Singular: "endpoints", ShortNames: []string{"ep"}, Kind: "Endpoints", Version: "v1", Namespaced: true},
+	{Name: "events", Singular: "event", ShortNames: []string{"ev"}, Kind: "Event", Version: "v1", Namespaced: true},
+	{Name: "limitranges", Singular: "limitrange", ShortNames: []string{"limits"}, Kind: "LimitRange", Version: "v1", Namespaced: true},
+	{Name: "namespaces", Singular: "namespace", ShortNames: []string{"ns"}, Kind: "Namespace", Version: "v1"},
+	{Name: "nodes", Singular: "node", ShortNames: []string{"no"}, Kind: "Node", Version: "v1"},
+	{Name: "persistentvolumeclaims", Singular: "persistentvolumeclaim", ShortNames: []string{"pvc"}, Kind: "PersistentVolumeClaim", Version: "v1", Namespaced: true},
+	{Name: "persistentvolumes", Singular: "persistentvolume", ShortNames: []string{"pv"}, Kind: "PersistentVolume", Version: "v1"},
+	{Name: "pods", Singular: "pod", ShortNames: []string{"po"}, Kind: "Pod", Version: "v1", Namespaced: true},
+	{Name: "replicationcontrollers", Singular: "replicationcontroller", ShortNames: []string{"rc"}, Kind: "ReplicationController", Version: "v1", Namespaced: true},
+	{Name: "resourcequotas", Singular: "resourcequota", ShortNames: []string{"quota"}, Kind: "ResourceQuota", Version: "v1", Namespaced: true},
+	{Name: "secrets", Singular: "secret", Kind: "Secret", Version: "v1", Namespaced: true},
+	{Name: "serviceaccounts", Singular: "serviceaccount", ShortNames: []string{"sa"}, Kind: "ServiceAccount", Version: "v1", Namespaced: true},
+	{Name: "services", Singular: "service", ShortNames: []string{"svc"}, Kind: "Service", Version: "v1", Namespaced: true},
+	{Name: "daemonsets", Singular: "daemonset", ShortNames: []string{"ds"}, Kind: "DaemonSet", Group: "apps", Version: "v1", Namespaced: true},
+	{Name: "deployments", Singular: "deployment", ShortNames: []string{"deploy"}, Kind: "Deployment", Group: "apps", Version: "v1", Namespaced: true},
+	{Name: "replicasets", Singular: "replicaset", ShortNames: []string{"rs"}, Kind: "ReplicaSet", Group: "apps", Version: "v1", Namespaced: true},
+	{Name: "statefulsets", Singular: "statefulset", ShortNames: []string{"sts"}, Kind: "StatefulSet", Group: "apps", Version: "v1", Namespaced: true},
+	{Name: "horizontalpodautoscalers", Singular: "horizontalpodautoscaler", ShortNames: []string{"hpa"}, Kind: "HorizontalPodAutoscaler", Group: "autoscaling", Version: "v2", Namespaced: true},
+	{Name: "cronjobs", Singular: "cronjob", ShortNames: []string{"cj"}, Kind: "CronJob", Group: "batch", Version: "v1", Namespaced: true},
+	{Name: "jobs", Singular: "job", Kind: "Job", Group: "batch", Version: "v1", Namespaced: true},
+	{Name: "ingresses", Singular: "ingress", ShortNames: []string{"ing"}, Kind: "Ingress", Group: "networking.k8s.io", Version: "v1", Namespaced: true},
+	{Name: "networkpolicies", Singular: "networkpolicy", ShortNames: []string{"netpol"}, Kind: "NetworkPolicy", Group: "networking.k8s.io", Version: "v1", Namespaced: true},
+	{Name: "poddisruptionbudgets", Singular: "poddisruptionbudget", ShortNames: []string{"pdb"}, Kind: "PodDisruptionBudget", Group: "policy", Version: "v1", Namespaced: true},
+	{Name: "clusterrolebindings", Singular: "clusterrolebinding", Kind: "ClusterRoleBinding", Group: "rbac.authorization.k8s.io", Version: "v1"},
+	{Name: "clusterroles", Singular: "clusterrole", Kind: "ClusterRole", Group: "rbac.authorization.k8s.io", Version: "v1"},
+	{Name: "rolebindings", Singular: "rolebinding", Kind: "RoleBinding", Group: "rbac.authorization.k8s.io", Version: "v1", Namespaced: true},
+	{Name: "roles", Singular: "role", Kind: "Role", Group: "rbac.authorization.k8s.io", Version: "v1", Namespaced: true},
+	{Name: "storageclasses", Singular: "storageclass", ShortNames: []string{"sc"}, Kind: "StorageClass", Group: "storage.k8s.io", Version: "v1"},
+	{Name: "customresourcedefinitions", Singular: "customresourcedefinition", ShortNames: []string{"crd", "crds"}, Kind: "CustomResourceDefinition", Group: "apiextensions.k8s.io", Version: "v1"},
+}
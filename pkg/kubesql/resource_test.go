@@ -0,0 +1,102 @@
+package kubesql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResourceRef(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected ResourceRef
+		str      string
+	}{
+		{"pods", ResourceRef{Resource: "pods"}, "pods"},
+		{"kube-system/pods", ResourceRef{Namespace: "kube-system", Resource: "pods"}, "kube-system/pods"},
+		{"deployments.apps", ResourceRef{Resource: "deployments.apps"}, "deployments.apps"},
+		{"`my ns`/pods", ResourceRef{Namespace: "my ns", Resource: "pods"}, "`my ns`/pods"},
+		{"default / \"pods\"", ResourceRef{Namespace: "default", Resource: "pods"}, "default/pods"},
+		{"prod:pods", ResourceRef{Clusters: []string{"prod"}, Resource: "pods"}, "prod:pods"},
+		{"prod-*:kube-system/pods", ResourceRef{Clusters: []string{"prod-*"}, Namespace: "kube-system", Resource: "pods"}, "prod-*:kube-system/pods"},
+		{"*-eu,staging.us:nodes", ResourceRef{Clusters: []string{"*-eu", "staging.us"}, Resource: "nodes"}, "*-eu,staging.us:nodes"},
+		{"*:pods", ResourceRef{Clusters: []string{"*"}, Resource: "pods"}, "*:pods"},
+		{"`arn:aws:eks:prod`:default/pods", ResourceRef{Clusters: []string{"arn:aws:eks:prod"}, Namespace: "default", Resource: "pods"}, "`arn:aws:eks:prod`:default/pods"},
+	}
+
+	for _, tc := range testCases {
+		result, err := ParseResourceRef(tc.input)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("For input '%s', expected %+v, got %+v", tc.input, tc.expected, result)
+		}
+		if result.String() != tc.str {
+			t.Errorf("For input '%s', expected string '%s', got '%s'", tc.input, tc.str, result.String())
+		}
+	}
+
+	for _, input := range []string{"", "/pods", "default/", "pods, nodes", "a/b/c", ":pods", "prod,:pods", "prod:", "prod+1:pods"} {
+		if _, err := ParseResourceRef(input); err == nil {
+			t.Errorf("For input '%s', expected an error", input)
+		}
+	}
+}
+
+func TestMatchCluster(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"prod", "prod", true},
+		{"prod", "prod-eu", false},
+		{"prod-*", "prod-eu", true},
+		{"prod-*", "prod-", true},
+		{"prod-*", "staging-eu", false},
+		{"*-eu", "prod-eu", true},
+		{"*-eu", "prod-us", false},
+		{"*", "arn:aws:eks:eu-west-1:123:cluster/prod", true},
+		{"p*d-*-1", "prod-eu-1", true},
+		{"p*d-*-1", "prod-eu-2", false},
+		{"a*a", "a", false},
+	}
+
+	for _, tc := range testCases {
+		if result := MatchCluster(tc.pattern, tc.name); result != tc.expected {
+			t.Errorf("For pattern '%s' and name '%s', expected %v, got %v", tc.pattern, tc.name, tc.expected, result)
+		}
+	}
+}
+
+func TestResolver(t *testing.T) {
+	resolver := DefaultResolver()
+
+	for _, name := range []string{"pods", "pod", "po", "PODS", "deployments.apps", "deploy"} {
+		if _, err := resolver.Resolve(name); err != nil {
+			t.Errorf("For name '%s', unexpected error: %v", name, err)
+		}
+	}
+	if resource, _ := resolver.Resolve("deploy"); resource.Kind != "Deployment" || resource.Group != "apps" {
+		t.Errorf("unexpected resource for 'deploy': %+v", resource)
+	}
+	if _, err := resolver.Resolve("widgets"); err == nil {
+		t.Errorf("expected an error for an unknown resource")
+	}
+
+	// Added resources are resolvable and listed once
+	resolver.Add(ResourceInfo{Name: "widgets", ShortNames: []string{"wd"}, Kind: "Widget", Group: "example.com", Version: "v1", Namespaced: true})
+	if resource, err := resolver.Resolve("wd"); err != nil || resource.Kind != "Widget" {
+		t.Errorf("expected 'wd' to resolve to Widget, got %+v, %v", resource, err)
+	}
+	count := 0
+	for _, resource := range resolver.Resources() {
+		if resource.Name == "widgets" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected widgets to be listed once, got %d", count)
+	}
+}
@@ -0,0 +1,114 @@
+package kubesql
+
+import (
+	"sort"
+)
+
+// Schema types, as used by OpenAPI.
+const (
+	SchemaObject  = "object"
+	SchemaArray   = "array"
+	SchemaString  = "string"
+	SchemaInteger = "integer"
+	SchemaNumber  = "number"
+	SchemaBoolean = "boolean"
+)
+
+// Schema describes the structure of an object field, following the subset
+// of OpenAPI v3 used by Kubernetes resource and CRD schemas.
+type Schema struct {
+	Type                 string             // One of the Schema* types, empty when unknown
+	Format               string             // Type format, e.g. "date-time" or "int-or-string"
+	Description          string             // Human readable description of the field
+	Properties           map[string]*Schema // Known fields of an object
+	AdditionalProperties *Schema            // Value schema of a map, e.g. labels
+	Items                *Schema            // Item schema of an array
+	IntOrString          bool               // Accepts both integers and strings, e.g. quantities
+	PreserveUnknown      bool               // Accepts fields that are not listed in Properties
+}
+
+// Field returns the schema of the named property of an object or map
+// schema. It returns false if the schema is known not to have the field.
+// A nil result with true means that the field is allowed but its schema is
+// unknown.
+func (s *Schema) Field(name string) (*Schema, bool) {
+	switch {
+	case s == nil:
+		return nil, true
+	case s.Properties[name] != nil:
+		return s.Properties[name], true
+	case s.AdditionalProperties != nil:
+		return s.AdditionalProperties, true
+	case s.Type == "" || s.PreserveUnknown:
+		return nil, true
+	case s.Type == SchemaObject && len(s.Properties) == 0:
+		// Free form object
+		return nil, true
+	}
+	return nil, false
+}
+
+// FieldNames returns the names of the known properties, sorted.
+func (s *Schema) FieldNames() []string {
+	if s == nil {
+		return nil
+	}
+	var names []string
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IsScalar reports whether the schema describes a value that is not an
+// object or an array. Unknown schemas are considered scalar.
+func (s *Schema) IsScalar() bool {
+	return s == nil || (s.Type != SchemaObject && s.Type != SchemaArray)
+}
+
+// SchemaProvider returns the schemas of the objects of a resource type.
+type SchemaProvider interface {
+	// Schema returns the schema of objects of the given resource, or nil if
+	// the provider has no schema for it.
+	Schema(resource ResourceInfo) (*Schema, error)
+}
+
+// ClusterField is the synthetic field holding the name of the cluster an
+// object was listed from, set on the objects of queries naming clusters in
+// their FROM clause.
+const ClusterField = "cluster"
+
+// fieldAliases maps the short field names accepted in queries to the full
+// object field paths they stand for.
+var fieldAliases = map[string][]Segment{
+	"name":        {{Name: "metadata"}, {Name: "name"}},
+	"namespace":   {{Name: "metadata"}, {Name: "namespace"}},
+	"labels":      {{Name: "metadata"}, {Name: "labels"}},
+	"annotations": {{Name: "metadata"}, {Name: "annotations"}},
+	"created":     {{Name: "metadata"}, {Name: "creationTimestamp"}},
+	"deleted":     {{Name: "metadata"}, {Name: "deletionTimestamp"}},
+}
+
+// fieldAliasNames returns the short field names, sorted.
+func fieldAliasNames() []string {
+	names := make([]string, 0, len(fieldAliases))
+	for name := range fieldAliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveAlias expands a field path starting with a short field name into
+// the full field path. Other paths are returned unchanged.
+func resolveAlias(path []Segment) []Segment {
+	if len(path) == 0 || path[0].Array {
+		return path
+	}
+	alias, ok := fieldAliases[path[0].Name]
+	if !ok {
+		return path
+	}
+	return append(append([]Segment{}, alias...), path[1:]...)
+}
@@ -0,0 +1,391 @@
+package kubesql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPISchemas is a SchemaProvider backed by OpenAPI documents and
+// CustomResourceDefinition manifests loaded from local JSON or YAML files.
+//
+// OpenAPI v3 documents (as served by /openapi/v3/...) and Swagger v2
+// documents (as served by /openapi/v2) are indexed by the
+// x-kubernetes-group-version-kind extension of their schemas. CRD manifests
+// contribute both a schema and a resource type, see Resources.
+type OpenAPISchemas struct {
+	schemas   map[string]*Schema // Schemas by group and kind, see schemaKey
+	resources []ResourceInfo     // Resources defined by loaded CRDs
+}
+
+// NewOpenAPISchemas creates an empty schema provider.
+func NewOpenAPISchemas() *OpenAPISchemas {
+	return &OpenAPISchemas{schemas: make(map[string]*Schema)}
+}
+
+// LoadSchemas creates a schema provider from the given files. Directories
+// are searched for .json, .yaml and .yml files, without recursion.
+func LoadSchemas(paths ...string) (*OpenAPISchemas, error) {
+	provider := NewOpenAPISchemas()
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		files := []string{path}
+		if info.IsDir() {
+			files = nil
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				switch strings.ToLower(filepath.Ext(entry.Name())) {
+				case ".json", ".yaml", ".yml":
+					if !entry.IsDir() {
+						files = append(files, filepath.Join(path, entry.Name()))
+					}
+				}
+			}
+		}
+
+		for _, file := range files {
+			if err := provider.LoadFile(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return provider, nil
+}
+
+// LoadFile loads an OpenAPI document or CRD manifest from a JSON or YAML
+// file. YAML files may contain several documents.
+func (o *OpenAPISchemas) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := o.Load(data); err != nil {
+		return fmt.Errorf("error loading schemas from %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load loads OpenAPI documents or CRD manifests from JSON or YAML data.
+func (o *OpenAPISchemas) Load(data []byte) error {
+	// JSON documents are decoded directly, it is much faster for the large
+	// documents served by the API server
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var document map[string]any
+		if err := json.Unmarshal(trimmed, &document); err != nil {
+			return err
+		}
+		return o.loadDocument(document)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var document map[string]any
+		err := decoder.Decode(&document)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := o.loadDocument(document); err != nil {
+			return err
+		}
+	}
+}
+
+// Schema implements SchemaProvider.
+func (o *OpenAPISchemas) Schema(resource ResourceInfo) (*Schema, error) {
+	return o.schemas[schemaKey(resource.Group, resource.Kind)], nil
+}
+
+// Resources returns the resources defined by the loaded CRDs, so they can
+// be added to a resolver.
+func (o *OpenAPISchemas) Resources() []ResourceInfo {
+	return o.resources
+}
+
+// schemaKey returns the key schemas are indexed by.
+func schemaKey(group, kind string) string {
+	return strings.ToLower(group) + "/" + strings.ToLower(kind)
+}
+
+// loadDocument loads a single decoded document.
+func (o *OpenAPISchemas) loadDocument(document map[string]any) error {
+	switch {
+	case document == nil:
+		return nil
+	case document["kind"] == "CustomResourceDefinition":
+		return o.loadCRD(document)
+	case document["kind"] == "List" || document["kind"] == "CustomResourceDefinitionList":
+		for _, item := range asSlice(document["items"]) {
+			if err := o.loadDocument(asMap(item)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case asMap(document["components"])["schemas"] != nil:
+		o.loadDefinitions(asMap(asMap(document["components"])["schemas"]), "#/components/schemas/")
+		return nil
+	case document["definitions"] != nil:
+		o.loadDefinitions(asMap(document["definitions"]), "#/definitions/")
+		return nil
+	}
+	return fmt.Errorf("unsupported document, expected an OpenAPI document or a CustomResourceDefinition")
+}
+
+// loadDefinitions indexes the named schemas of an OpenAPI document by the
+// kinds listed in their x-kubernetes-group-version-kind extension.
+func (o *OpenAPISchemas) loadDefinitions(definitions map[string]any, refPrefix string) {
+	converter := &schemaConverter{definitions: definitions, refPrefix: refPrefix, converted: make(map[string]*Schema)}
+
+	for name, definition := range definitions {
+		for _, gvk := range asSlice(asMap(definition)["x-kubernetes-group-version-kind"]) {
+			group, _ := asMap(gvk)["group"].(string)
+			kind, _ := asMap(gvk)["kind"].(string)
+			if kind != "" {
+				o.schemas[schemaKey(group, kind)] = converter.convertRef(refPrefix + name)
+			}
+		}
+	}
+}
+
+// loadCRD loads the schema and resource type of a CustomResourceDefinition.
+func (o *OpenAPISchemas) loadCRD(crd map[string]any) error {
+	spec := asMap(crd["spec"])
+	names := asMap(spec["names"])
+
+	resource := ResourceInfo{
+		Group:      asString(spec["group"]),
+		Kind:       asString(names["kind"]),
+		Name:       asString(names["plural"]),
+		Singular:   asString(names["singular"]),
+		Namespaced: asString(spec["scope"]) != "Cluster",
+	}
+	for _, shortName := range asSlice(names["shortNames"]) {
+		resource.ShortNames = append(resource.ShortNames, asString(shortName))
+	}
+	if resource.Kind == "" || resource.Name == "" {
+		return fmt.Errorf("CustomResourceDefinition %s has no kind or plural name", asString(asMap(crd["metadata"])["name"]))
+	}
+
+	// Use the storage version, or the first served version
+	var version map[string]any
+	for _, item := range asSlice(spec["versions"]) {
+		candidate := asMap(item)
+		if version == nil || candidate["storage"] == true {
+			version = candidate
+		}
+	}
+	resource.Version = asString(version["name"])
+
+	raw := asMap(asMap(version["schema"])["openAPIV3Schema"])
+	if raw == nil {
+		// Pre v1 CRDs have a single schema for all versions
+		raw = asMap(asMap(spec["validation"])["openAPIV3Schema"])
+	}
+
+	converter := &schemaConverter{converted: make(map[string]*Schema)}
+	schema := converter.convert(raw)
+	if schema == nil {
+		schema = &Schema{Type: SchemaObject, PreserveUnknown: true}
+	}
+	addObjectMeta(schema)
+
+	o.schemas[schemaKey(resource.Group, resource.Kind)] = schema
+	o.resources = append(o.resources, resource)
+	return nil
+}
+
+// addObjectMeta completes a top level CRD schema with the standard fields
+// every object has, as CRD schemas usually leave them out.
+func addObjectMeta(schema *Schema) {
+	if schema.Properties == nil {
+		schema.Properties = make(map[string]*Schema)
+	}
+	if schema.Type == "" {
+		schema.Type = SchemaObject
+	}
+
+	for _, name := range []string{"apiVersion", "kind"} {
+		if schema.Properties[name] == nil {
+			schema.Properties[name] = &Schema{Type: SchemaString}
+		}
+	}
+	if metadata := schema.Properties["metadata"]; metadata == nil || len(metadata.Properties) == 0 {
+		schema.Properties["metadata"] = objectMetaSchema()
+	}
+}
+
+// objectMetaSchema returns the schema of the commonly used ObjectMeta fields.
+func objectMetaSchema() *Schema {
+	str := func() *Schema { return &Schema{Type: SchemaString} }
+	stringMap := func() *Schema { return &Schema{Type: SchemaObject, AdditionalProperties: str()} }
+	timestamp := func() *Schema { return &Schema{Type: SchemaString, Format: "date-time"} }
+
+	return &Schema{
+		Type: SchemaObject,
+		Properties: map[string]*Schema{
+			"name":              str(),
+			"namespace":         str(),
+			"generateName":      str(),
+			"uid":               str(),
+			"resourceVersion":   str(),
+			"generation":        {Type: SchemaInteger, Format: "int64"},
+			"creationTimestamp": timestamp(),
+			"deletionTimestamp": timestamp(),
+			"labels":            stringMap(),
+			"annotations":       stringMap(),
+			"finalizers":        {Type: SchemaArray, Items: str()},
+			"ownerReferences": {Type: SchemaArray, Items: &Schema{
+				Type: SchemaObject,
+				Properties: map[string]*Schema{
+					"apiVersion":         str(),
+					"kind":               str(),
+					"name":               str(),
+					"uid":                str(),
+					"controller":         {Type: SchemaBoolean},
+					"blockOwnerDeletion": {Type: SchemaBoolean},
+				},
+			}},
+		},
+	}
+}
+
+// schemaConverter converts decoded OpenAPI schemas into Schema values,
+// resolving references to named definitions.
+type schemaConverter struct {
+	definitions map[string]any     // Named definitions references point to
+	refPrefix   string             // Prefix of references to definitions
+	converted   map[string]*Schema // Definitions already converted, by reference
+}
+
+// convertRef converts the definition a reference points to. Recursive
+// definitions, such as JSONSchemaProps, share the same Schema value.
+func (c *schemaConverter) convertRef(ref string) *Schema {
+	if schema, ok := c.converted[ref]; ok {
+		return schema
+	}
+
+	definition := asMap(c.definitions[strings.TrimPrefix(ref, c.refPrefix)])
+	if definition == nil {
+		return nil
+	}
+
+	// Register the schema before converting it to break reference cycles
+	schema := &Schema{}
+	c.converted[ref] = schema
+	if converted := c.convert(definition); converted != nil {
+		*schema = *converted
+	}
+
+	// Quantities are plain strings in OpenAPI, recognize them by name
+	if strings.HasSuffix(ref, ".api.resource.Quantity") {
+		schema.Format = "quantity"
+	}
+	return schema
+}
+
+// convert converts a decoded schema.
+func (c *schemaConverter) convert(raw map[string]any) *Schema {
+	if raw == nil {
+		return nil
+	}
+
+	if ref := asString(raw["$ref"]); ref != "" {
+		return c.convertRef(ref)
+	}
+
+	// Kubernetes OpenAPI v3 wraps references with a description in allOf
+	for _, item := range asSlice(raw["allOf"]) {
+		if schema := c.convert(asMap(item)); schema != nil {
+			result := *schema
+			if description := asString(raw["description"]); description != "" {
+				result.Description = description
+			}
+			return &result
+		}
+	}
+
+	schema := &Schema{
+		Type:            asString(raw["type"]),
+		Format:          asString(raw["format"]),
+		Description:     asString(raw["description"]),
+		IntOrString:     raw["x-kubernetes-int-or-string"] == true || asString(raw["format"]) == "int-or-string",
+		PreserveUnknown: raw["x-kubernetes-preserve-unknown-fields"] == true,
+	}
+
+	// anyOf of an integer and a string is the long form of int-or-string
+	for _, item := range asSlice(raw["anyOf"]) {
+		if t := asString(asMap(item)["type"]); t == SchemaInteger || t == SchemaString {
+			schema.IntOrString = true
+		}
+	}
+
+	// CRDs generated by controller-gen mark quantities with their pattern
+	if schema.IntOrString && strings.Contains(asString(raw["pattern"]), "KMGTPE") {
+		schema.Format = "quantity"
+	}
+
+	if properties := asMap(raw["properties"]); properties != nil {
+		schema.Properties = make(map[string]*Schema)
+		for name, property := range properties {
+			if converted := c.convert(asMap(property)); converted != nil {
+				schema.Properties[name] = converted
+			} else {
+				schema.Properties[name] = &Schema{}
+			}
+		}
+		if schema.Type == "" {
+			schema.Type = SchemaObject
+		}
+	}
+
+	switch additional := raw["additionalProperties"].(type) {
+	case map[string]any:
+		schema.AdditionalProperties = c.convert(additional)
+		if schema.AdditionalProperties == nil {
+			schema.AdditionalProperties = &Schema{}
+		}
+	case bool:
+		if additional {
+			schema.AdditionalProperties = &Schema{}
+		}
+	}
+
+	schema.Items = c.convert(asMap(raw["items"]))
+	return schema
+}
+
+// asMap returns value as a map, or nil if it is not one.
+func asMap(value any) map[string]any {
+	m, _ := value.(map[string]any)
+	return m
+}
+
+// asSlice returns value as a slice, or nil if it is not one.
+func asSlice(value any) []any {
+	s, _ := value.([]any)
+	return s
+}
+
+// asString returns value as a string, or an empty string if it is not one.
+func asString(value any) string {
+	s, _ := value.(string)
+	return s
+}
@@ -0,0 +1,104 @@
+package kubesql
+
+import (
+	"testing"
+)
+
+func TestLoadSchemasOpenAPI(t *testing.T) {
+	schemas, err := LoadSchemas("testdata/schemas/pod-openapi-v3.json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pod, err := schemas.Schema(ResourceInfo{Name: "pods", Kind: "Pod", Version: "v1"})
+	if err != nil || pod == nil {
+		t.Fatalf("expected a Pod schema, got %v, %v", pod, err)
+	}
+
+	testCases := []struct {
+		path       string
+		schemaType string
+		format     string
+	}{
+		{"metadata.name", SchemaString, ""},
+		{"metadata.labels.app", SchemaString, ""},
+		{"spec.containers", SchemaArray, ""},
+		{"spec.containers[0].image", SchemaString, ""},
+		{"spec.containers[0].resources.limits.memory", SchemaString, "quantity"},
+		{"status.startTime", SchemaString, "date-time"},
+		{"spec.priority", SchemaInteger, "int32"},
+	}
+
+	for _, tc := range testCases {
+		expr, err := ParseExpr(TSLQuery(tc.path))
+		if err != nil {
+			t.Fatalf("For path '%s', unexpected error: %v", tc.path, err)
+		}
+		field, err := lookupField(pod, expr.(*FieldRef).Path)
+		if err != nil || field == nil {
+			t.Errorf("For path '%s', expected a schema, got %v, %v", tc.path, field, err)
+			continue
+		}
+		if field.Type != tc.schemaType || field.Format != tc.format {
+			t.Errorf("For path '%s', expected type %s (format '%s'), got %s ('%s')",
+				tc.path, tc.schemaType, tc.format, field.Type, field.Format)
+		}
+	}
+
+	if pod.Properties["metadata"].Description != "Standard object's metadata." {
+		t.Errorf("expected the allOf description to be kept, got '%s'", pod.Properties["metadata"].Description)
+	}
+	if missing, _ := schemas.Schema(ResourceInfo{Name: "nodes", Kind: "Node", Version: "v1"}); missing != nil {
+		t.Errorf("expected no schema for nodes")
+	}
+}
+
+func TestLoadSchemasCRD(t *testing.T) {
+	schemas, err := LoadSchemas("testdata/schemas")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resources := schemas.Resources()
+	if len(resources) != 2 {
+		t.Fatalf("expected 2 resources from CRDs, got %+v", resources)
+	}
+	widget := resources[0]
+	if widget.Name != "widgets" || widget.Kind != "Widget" || widget.Group != "example.com" ||
+		widget.Version != "v1" || !widget.Namespaced || len(widget.ShortNames) != 1 {
+		t.Errorf("unexpected widget resource: %+v", widget)
+	}
+	if resources[1].Namespaced {
+		t.Errorf("expected gadgets to be cluster scoped")
+	}
+
+	schema, _ := schemas.Schema(widget)
+	if schema == nil {
+		t.Fatalf("expected a Widget schema")
+	}
+	if schema.Properties["spec"].Properties["size"].Type != SchemaInteger {
+		t.Errorf("expected the schema of the storage version")
+	}
+	if memory := schema.Properties["spec"].Properties["memory"]; !memory.IntOrString || memory.Format != "quantity" {
+		t.Errorf("expected memory to be an int-or-string quantity, got %+v", memory)
+	}
+	if port := schema.Properties["spec"].Properties["port"]; !port.IntOrString || port.Format != "" {
+		t.Errorf("expected port to be a plain int-or-string, got %+v", port)
+	}
+	if _, ok := schema.Properties["metadata"].Field("labels"); !ok {
+		t.Errorf("expected standard metadata fields to be added")
+	}
+}
+
+func TestLoadSchemasErrors(t *testing.T) {
+	schemas := NewOpenAPISchemas()
+	if err := schemas.Load([]byte("kind: Pod\n")); err == nil {
+		t.Errorf("expected an error for a document that is not a schema")
+	}
+	if err := schemas.Load([]byte("{")); err == nil {
+		t.Errorf("expected an error for invalid JSON")
+	}
+	if _, err := LoadSchemas("testdata/schemas/missing.json"); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,82 @@
+package kubesql
+
+// statement holds the tokens of a single statement in a script.
+type statement struct {
+	tokens []token // Tokens of the statement, without the terminating semicolon
+	end    int     // Offset of the terminating semicolon, or of the end of the script
+}
+
+// splitStatements splits tokens into statements on every semicolon. Empty
+// statements, such as the one after a trailing semicolon, are dropped.
+// length is the length of the tokenized text, used as the end of the last
+// statement when it is not terminated by a semicolon.
+func splitStatements(tokens []token, length int) []statement {
+	var statements []statement
+	start := 0
+
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i].kind != tokenSemicolon {
+			continue
+		}
+
+		end := length
+		if i < len(tokens) {
+			end = tokens[i].pos
+		}
+		if i > start {
+			statements = append(statements, statement{tokens: tokens[start:i], end: end})
+		}
+		start = i + 1
+	}
+
+	return statements
+}
+
+// ParseScript parses a script of KubeSQL statements separated by semicolons,
+// such as the content of a .ksql file. Statement positions and errors refer
+// to locations in the script text.
+//
+// Each comment is attached to the statement that follows it, or that it is
+// part of. Comments after the last statement are attached to the last one.
+// A script without statements returns an empty slice.
+func ParseScript(script string) ([]*Query, error) {
+	p := NewParser(script)
+
+	tokens, comments, err := p.tokenize()
+	if err != nil {
+		return nil, err
+	}
+
+	queries := []*Query{}
+	statements := splitStatements(tokens, len(p.query))
+	for i, stmt := range statements {
+		query, err := p.parseStatement(stmt.tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		// Take the comments that appear before the end of this statement
+		n := 0
+		for n < len(comments) && (comments[n].pos < stmt.end || i == len(statements)-1) {
+			n++
+		}
+		query.Comments = p.comments(comments[:n])
+		comments = comments[n:]
+
+		queries = append(queries, query)
+	}
+
+	return queries, nil
+}
+
+// ScriptComplete reports whether script ends with a semicolon terminated
+// statement, ignoring trailing comments and whitespace. Interactive shells
+// use it to decide when multi-line input is ready to run. A script with an
+// unterminated quote or comment is not complete.
+func ScriptComplete(script string) bool {
+	tokens, _, err := tokenizeWithComments(script)
+	if err != nil || len(tokens) == 0 {
+		return false
+	}
+	return tokens[len(tokens)-1].kind == tokenSemicolon
+}
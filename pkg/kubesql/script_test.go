@@ -0,0 +1,92 @@
+package kubesql
+
+import (
+	"testing"
+)
+
+func TestParseScript(t *testing.T) {
+	script := `-- Running pods
+SELECT name, status.phase FROM pods WHERE status.phase = 'Running';
+
+/* Services in the default namespace,
+   ordered by name */
+SELECT name FROM default/services -- only names
+ORDER BY name;;
+
+SELECT * FROM nodes LIMIT 3
+-- end of runbook
+`
+
+	queries, err := ParseScript(script)
+	if err != nil {
+		t.Fatalf("Failed to parse script: %v", err)
+	}
+
+	expected := []struct {
+		query    string
+		line     int
+		source   string
+		comments []string
+	}{
+		{"SELECT name, status.phase FROM pods WHERE status.phase = 'Running'", 2,
+			"SELECT name, status.phase FROM pods WHERE status.phase = 'Running'", []string{"-- Running pods"}},
+		{"SELECT name FROM default/services ORDER BY name ASC", 6, "SELECT name FROM default/services -- only names\nORDER BY name",
+			[]string{"/* Services in the default namespace,\n   ordered by name */", "-- only names"}},
+		{"SELECT * FROM nodes LIMIT 3", 9, "SELECT * FROM nodes LIMIT 3", []string{"-- end of runbook"}},
+	}
+
+	if len(queries) != len(expected) {
+		t.Fatalf("Expected %d queries, got %d", len(expected), len(queries))
+	}
+
+	for i, query := range queries {
+		if query.String() != expected[i].query {
+			t.Errorf("Query %d: expected '%s', got '%s'", i, expected[i].query, query.String())
+		}
+		if query.Pos.Line != expected[i].line || query.Pos.Column != 1 {
+			t.Errorf("Query %d: expected position %d:1, got %s", i, expected[i].line, query.Pos)
+		}
+		if query.Source != expected[i].source {
+			t.Errorf("Query %d: expected source %q, got %q", i, expected[i].source, query.Source)
+		}
+		if len(query.Comments) != len(expected[i].comments) {
+			t.Errorf("Query %d: expected comments %q, got %+v", i, expected[i].comments, query.Comments)
+			continue
+		}
+		for j, comment := range query.Comments {
+			if comment.Text != expected[i].comments[j] {
+				t.Errorf("Query %d comment %d: expected %q, got %q", i, j, expected[i].comments[j], comment.Text)
+			}
+		}
+	}
+}
+
+func TestParseScriptErrorPosition(t *testing.T) {
+	script := "SELECT name FROM pods;\n\nSELECT name FROM pods ORDER BY name UP;"
+
+	_, err := ParseScript(script)
+	if err == nil {
+		t.Fatal("Expected error for invalid second statement")
+	}
+
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Expected *ParseError, got %T", err)
+	}
+	if parseErr.Pos.Line != 3 || parseErr.Pos.Column != 32 {
+		t.Errorf("Expected error at 3:32, got %s", parseErr.Pos)
+	}
+}
+
+func TestParseScriptEmpty(t *testing.T) {
+	for _, script := range []string{"", "  ;; ", "-- only a comment\n"} {
+		queries, err := ParseScript(script)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", script, err)
+			continue
+		}
+		if len(queries) != 0 {
+			t.Errorf("For input '%s', expected no queries, got %d", script, len(queries))
+		}
+	}
+}
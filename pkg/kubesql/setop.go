@@ -0,0 +1,267 @@
+package kubesql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// parse parses the query of a set operation.
+func (op SetOperation) parse() (*Query, error) {
+	q, err := NewParser(string(op.Query)).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing query after %s: %w", op.keyword(), err)
+	}
+	if q.Explain || len(q.With) > 0 || len(q.Compound) > 0 || len(q.OrderBy) > 0 || q.Limit >= 0 {
+		return nil, fmt.Errorf("query after %s must be a plain query without ORDER BY or LIMIT", op.keyword())
+	}
+	return q, nil
+}
+
+// operands returns the queries a query combines: the query itself for a
+// plain query, otherwise the query without its common table expressions,
+// set operations, ORDER BY and LIMIT, then the query of each set operation.
+func operands(q *Query) ([]*Query, error) {
+	if len(q.Compound) == 0 {
+		return []*Query{q}, nil
+	}
+	first := *q
+	first.With, first.Compound, first.OrderBy, first.Limit = nil, nil, nil, DefaultLimit
+	first.Explain, first.Comments, first.Source, first.Columns = false, nil, "", nil
+	queries := []*Query{&first}
+	for _, op := range q.Compound {
+		operand, err := op.parse()
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, operand)
+	}
+	return queries, nil
+}
+
+// combine combines the results of the queries a compound query combines
+// with apply, INTERSECT first as it binds tighter, then UNION and EXCEPT
+// from left to right.
+func combine[T any](ops []SetOperation, results []T, apply func(op SetOperation, x, y T) T) T {
+	terms, termOps := results[:1:1], []SetOperation(nil)
+	for i, op := range ops {
+		if op.Op == IntersectKeyword {
+			terms[len(terms)-1] = apply(op, terms[len(terms)-1], results[i+1])
+			continue
+		}
+		terms, termOps = append(terms, results[i+1]), append(termOps, op)
+	}
+	result := terms[0]
+	for i, op := range termOps {
+		result = apply(op, result, terms[i+1])
+	}
+	return result
+}
+
+// checkColumnCount checks that the query at index i of a compound query,
+// the first one being 1, selects as many columns as the first one.
+func checkColumnCount(op SetOperation, first, columns []Column, i int) error {
+	if len(columns) != len(first) {
+		return fmt.Errorf("queries combined by %s must select the same number of columns, the first query selects %d and query %d selects %d",
+			op.Op, len(first), i, len(columns))
+	}
+	return nil
+}
+
+// combineType returns the type of a column of the combined rows, from its
+// types in two queries.
+func combineType(a, b Type) Type {
+	switch {
+	case a == b, b == TypeNull:
+		return a
+	case a == TypeNull:
+		return b
+	case a == TypeQuantity && b.isNumeric(), b == TypeQuantity && a.isNumeric():
+		return TypeQuantity
+	case a.isNumeric() && b.isNumeric():
+		return TypeFloat
+	}
+	return TypeUnknown
+}
+
+// runCompound runs the queries of a compound query, combines their rows,
+// then sorts them and applies LIMIT. The columns are named after the ones
+// of the first query.
+func runCompound(q *Query, xs []*execution) (*Result, error) {
+	rows := make([][][]any, len(xs))
+	var columns []Column
+	for i, x := range xs {
+		result, err := x.run(nil)
+		if err != nil {
+			return nil, fmt.Errorf("error running query %d: %w", i+1, err)
+		}
+		rows[i] = result.Rows
+		if i == 0 {
+			columns = append([]Column(nil), result.Columns...)
+			continue
+		}
+		if err := checkColumnCount(q.Compound[i-1], columns, result.Columns, i+1); err != nil {
+			return nil, err
+		}
+		for j, column := range result.Columns {
+			columns[j].Type = combineType(columns[j].Type, column.Type)
+		}
+	}
+	if len(q.Columns) == len(columns) {
+		columns = q.Columns
+	}
+
+	result := &Result{Columns: columns, Rows: combine(q.Compound, rows, setRows)}
+	order, err := compoundOrder(q, columns)
+	if err != nil {
+		return nil, err
+	}
+	if err := xs[0].e.sortByKeys(order, nil, result.Rows); err != nil {
+		return nil, err
+	}
+	if q.Limit >= 0 && q.Limit < len(result.Rows) {
+		result.Rows = result.Rows[:q.Limit]
+	}
+	return result, nil
+}
+
+// setRows applies a set operation to the rows of two queries. Without ALL,
+// the result has no duplicate rows. With ALL, UNION keeps every row,
+// INTERSECT keeps a row as many times as both have it, and EXCEPT as many
+// times as the first has it more than the second.
+func setRows(op SetOperation, x, y [][]any) [][]any {
+	var rows [][]any
+	if op.Op == UnionKeyword {
+		rows = append(x[:len(x):len(x)], y...)
+	} else {
+		counts := make(map[string]int)
+		for _, row := range y {
+			counts[rowKey(row)]++
+		}
+		for _, row := range x {
+			key := rowKey(row)
+			found := counts[key] > 0
+			if found && op.All {
+				counts[key]--
+			}
+			if found == (op.Op == IntersectKeyword) {
+				rows = append(rows, row)
+			}
+		}
+	}
+	if op.All {
+		return rows
+	}
+
+	var distinct [][]any
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		if key := rowKey(row); !seen[key] {
+			seen[key] = true
+			distinct = append(distinct, row)
+		}
+	}
+	return distinct
+}
+
+// rowKey returns a key identifying the values of a row: rows have the same
+// key when their values, lists and maps included, are equal.
+func rowKey(row []any) string {
+	values := make([]any, len(row))
+	for i, value := range row {
+		if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			value = fmt.Sprint(f)
+		}
+		values[i] = value
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Sprint(values)
+	}
+	return string(data)
+}
+
+// compoundOrder parses the ORDER BY keys of a compound query, which sort
+// the combined rows and so must name one of their columns.
+func compoundOrder(q *Query, columns []Column) ([]sortField, error) {
+	order, err := parseOrderBy(q, columns)
+	if err != nil {
+		return nil, err
+	}
+	for k, field := range order {
+		if field.column < 0 {
+			if order[k].column = columnIndex(columns, field.expr); order[k].column < 0 {
+				return nil, fmt.Errorf("%s key '%s' must be a column of the combined rows", OrderByKeyword, field.expr)
+			}
+		}
+	}
+	return order, nil
+}
+
+// columnIndex returns the index of the column an expression names, by its
+// alias or its field expression, or -1 when there is none.
+func columnIndex(columns []Column, expr Expr) int {
+	name := expr.String()
+	if ref, ok := expr.(*FieldRef); ok && len(ref.Path) == 1 {
+		name = ref.Path[0].Name
+	}
+	for i, column := range columns {
+		if column.Name == name || column.Name == expr.String() {
+			return i
+		}
+	}
+	return -1
+}
+
+// compoundColumns returns the result columns of a query combined with
+// others: its validated columns, or the whole objects without SELECT.
+func compoundColumns(q *Query, columns []Column) []Column {
+	if len(q.Select) == 0 {
+		return []Column{{Name: (&StarExpr{}).String(), Type: TypeMap}}
+	}
+	return columns
+}
+
+// validateCompound checks the queries of a compound query, the first one
+// with check, and that they select compatible columns. It returns the
+// columns of the combined rows.
+func (v *Validator) validateCompound(check *validation, q *Query) []Column {
+	queries, err := operands(q)
+	if err != nil {
+		check.report(q.Compound[0].Op, "", "%v", err)
+		return nil
+	}
+	columns := compoundColumns(queries[0], v.validate(check, queries[0]))
+	for i, operand := range queries[1:] {
+		op := q.Compound[i]
+		inner := &validation{validator: v, seen: check.seen, with: check.with, source: check.source.operand(i)}
+		others := compoundColumns(operand, v.validate(inner, operand))
+		check.errors = append(check.errors, inner.errors...)
+		if err := checkColumnCount(op, columns, others, i+2); err != nil {
+			check.report(op.Op, string(op.Query), "%v", err)
+			continue
+		}
+		for j, column := range others {
+			if !comparableTypes(columns[j].Type, column.Type, nil) {
+				check.report(op.Op, string(op.Query), "column '%s' is %s in the first query but %s in query %d",
+					columns[j].Name, article(columns[j].Type.String()), article(column.Type.String()), i+2)
+			}
+			columns[j].Type = combineType(columns[j].Type, column.Type)
+		}
+	}
+
+	for _, field := range q.OrderBy {
+		expr := check.parse(OrderByKeyword, field.Field)
+		if expr == nil {
+			continue
+		}
+		i := columnIndex(columns, expr)
+		switch {
+		case i < 0:
+			check.report(OrderByKeyword, expr.String(), "'%s' must be a column of the combined rows", expr)
+		case columns[i].Type == TypeList || columns[i].Type == TypeMap:
+			check.report(OrderByKeyword, expr.String(), "can not order by %s, it is %s", describeExpr(expr), article(columns[i].Type.String()))
+		}
+	}
+	return columns
+}
@@ -0,0 +1,608 @@
+package kubesql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPageSize is the number of objects a Runner lists per request.
+const DefaultPageSize = 500
+
+// DefaultConcurrency is the number of clusters a Runner lists at a time.
+const DefaultConcurrency = 8
+
+// DefaultWatchBackoff is the delay before a Runner resumes a watch that
+// ended. It doubles for each watch ending without events, up to
+// MaxWatchBackoff.
+const DefaultWatchBackoff = time.Second
+
+// MaxWatchBackoff is the longest delay before a Runner resumes a watch.
+const MaxWatchBackoff = 30 * time.Second
+
+// ListOptions selects the objects a DataSource lists.
+type ListOptions struct {
+	Namespace     string // Namespace to list, empty for all namespaces
+	LabelSelector string // Label selector, e.g. "app=web,tier in (db)"
+	FieldSelector string // Field selector, e.g. "status.phase=Running"
+	Limit         int    // Maximum number of objects of the page, 0 for no limit
+	Continue      string // Token of the page to list, from the previous ObjectList
+
+	ResourceVersion string // Version to watch changes from, from an ObjectList or event
+}
+
+// ObjectList is a page of listed objects.
+type ObjectList struct {
+	Items    []map[string]any // Objects, with their kind and apiVersion
+	Continue string           // Token of the next page, empty for the last page
+
+	ResourceVersion string // Version of the list, to watch changes from
+}
+
+// DataSource lists the objects of resources matching label and field
+// selectors, e.g. from the API server of a cluster or from a snapshot.
+type DataSource interface {
+	List(ctx context.Context, resource ResourceInfo, opts ListOptions) (*ObjectList, error)
+}
+
+// Watcher is a DataSource that also watches the changes of objects after a
+// resource version. The channel is closed when the watch ends, e.g. after
+// the timeout of the server or when ctx is done.
+type Watcher interface {
+	DataSource
+	Watch(ctx context.Context, resource ResourceInfo, opts ListOptions) (<-chan Event, error)
+}
+
+// ClusterSet is the set of clusters queries can name in their FROM clause,
+// e.g. the contexts of kubeconfig files.
+type ClusterSet interface {
+	// Clusters returns the names of the clusters.
+	Clusters() []string
+
+	// Source returns the data source of a cluster.
+	Source(cluster string) (DataSource, error)
+}
+
+// StaticClusters is a ClusterSet of data sources by cluster name, e.g.
+// datasets of snapshots of the clusters.
+type StaticClusters map[string]DataSource
+
+// Clusters implements ClusterSet. Names are sorted.
+func (c StaticClusters) Clusters() []string {
+	return slices.Sorted(maps.Keys(c))
+}
+
+// Source implements ClusterSet.
+func (c StaticClusters) Source(cluster string) (DataSource, error) {
+	source, ok := c[cluster]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster '%s'", cluster)
+	}
+	return source, nil
+}
+
+// ClusterError is the error of a cluster of a query naming several
+// clusters. The rows of the cluster are missing from the result.
+type ClusterError struct {
+	Cluster string
+	Err     error
+}
+
+func (e *ClusterError) Error() string {
+	return fmt.Sprintf("cluster %s: %v", e.Cluster, e.Err)
+}
+
+func (e *ClusterError) Unwrap() error {
+	return e.Err
+}
+
+// List implements DataSource, listing the objects of a resource matching
+// the options. Continue tokens are offsets into the matching objects.
+func (d *Dataset) List(ctx context.Context, resource ResourceInfo, opts ListOptions) (*ObjectList, error) {
+	labels, err := parseSelector(opts.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid label selector '%s': %w", opts.LabelSelector, err)
+	}
+	fields, err := parseSelector(opts.FieldSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid field selector '%s': %w", opts.FieldSelector, err)
+	}
+
+	var objects []map[string]any
+	for _, object := range d.Objects(resource) {
+		if opts.Namespace != "" && objectNamespace(object) != opts.Namespace {
+			continue
+		}
+		metadata, _ := object["metadata"].(map[string]any)
+		objectLabels, _ := metadata["labels"].(map[string]any)
+		if !labels.matches(func(key string) (string, bool) {
+			value, ok := objectLabels[key].(string)
+			return value, ok
+		}) {
+			continue
+		}
+		if !fields.matches(func(path string) (string, bool) {
+			return fieldString(object, path), true
+		}) {
+			continue
+		}
+		objects = append(objects, object)
+	}
+
+	start := 0
+	if opts.Continue != "" {
+		offset, err := strconv.Atoi(opts.Continue)
+		if err != nil || offset < 0 || offset > len(objects) {
+			return nil, fmt.Errorf("invalid continue token '%s'", opts.Continue)
+		}
+		start = offset
+	}
+	list := &ObjectList{Items: objects[start:]}
+	if opts.Limit > 0 && len(list.Items) > opts.Limit {
+		list.Items = list.Items[:opts.Limit]
+		list.Continue = strconv.Itoa(start + opts.Limit)
+	}
+	return list, nil
+}
+
+// objectNamespace returns the metadata.namespace of an object.
+func objectNamespace(object map[string]any) string {
+	metadata, _ := object["metadata"].(map[string]any)
+	namespace, _ := metadata["namespace"].(string)
+	return namespace
+}
+
+// selector is a parsed label or field selector, a list of requirements that
+// must all match.
+type selector []selectorRequirement
+
+// selectorRequirement is a requirement of a selector, e.g. app=web,
+// tier notin (db,cache) or !canary.
+type selectorRequirement struct {
+	key    string
+	op     string // =, !=, in, notin, exists or !exists
+	values []string
+}
+
+// parseSelector parses a label or field selector in the syntax of the
+// Kubernetes API, as written by the planner.
+func parseSelector(text string) (selector, error) {
+	var result selector
+	for text = strings.TrimSpace(text); text != ""; {
+		// Requirements are comma separated, sets hold commas too
+		end := strings.IndexByte(text, ',')
+		if open := strings.IndexByte(text, '('); open >= 0 && (end < 0 || open < end) {
+			closing := strings.IndexByte(text, ')')
+			if closing < 0 {
+				return nil, fmt.Errorf("missing ')'")
+			}
+			end = closing + 1
+			if end < len(text) && text[end] != ',' {
+				return nil, fmt.Errorf("expected ',' after ')'")
+			}
+		}
+		if end < 0 {
+			end = len(text)
+		}
+		requirement, err := parseRequirement(strings.TrimSpace(text[:end]))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, requirement)
+		text = strings.TrimSpace(strings.TrimPrefix(text[end:], ","))
+	}
+	return result, nil
+}
+
+// parseRequirement parses a requirement of a selector.
+func parseRequirement(text string) (selectorRequirement, error) {
+	if key, values, ok := strings.Cut(text, " "); ok {
+		op, set, _ := strings.Cut(strings.TrimSpace(values), " ")
+		set = strings.TrimSpace(set)
+		if (op != "in" && op != "notin") || !strings.HasPrefix(set, "(") || !strings.HasSuffix(set, ")") {
+			return selectorRequirement{}, fmt.Errorf("invalid requirement '%s'", text)
+		}
+		requirement := selectorRequirement{key: key, op: op}
+		for _, value := range strings.Split(set[1:len(set)-1], ",") {
+			requirement.values = append(requirement.values, strings.TrimSpace(value))
+		}
+		return requirement, nil
+	}
+	if key, value, ok := strings.Cut(text, "!="); ok {
+		return selectorRequirement{key: key, op: "!=", values: []string{value}}, nil
+	}
+	if key, value, ok := strings.Cut(text, "="); ok {
+		return selectorRequirement{key: key, op: "=", values: []string{strings.TrimPrefix(value, "=")}}, nil
+	}
+	if key, ok := strings.CutPrefix(text, "!"); ok {
+		return selectorRequirement{key: key, op: "!exists"}, nil
+	}
+	return selectorRequirement{key: text, op: "exists"}, nil
+}
+
+// matches reports whether the values returned by lookup match every
+// requirement of the selector.
+func (s selector) matches(lookup func(key string) (string, bool)) bool {
+	for _, requirement := range s {
+		value, ok := lookup(requirement.key)
+		var match bool
+		switch requirement.op {
+		case "exists":
+			match = ok
+		case "!exists":
+			match = !ok
+		case "=", "in":
+			match = ok && slices.Contains(requirement.values, value)
+		case "!=", "notin":
+			match = !ok || !slices.Contains(requirement.values, value)
+		}
+		if !match {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldString returns the value of a dotted field path of an object as a
+// string, empty when the field is missing, as field selectors compare it.
+func fieldString(object map[string]any, path string) string {
+	var value any = object
+	for _, name := range strings.Split(path, ".") {
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		value = fields[name]
+	}
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+// Runner runs queries against a data source, or the clusters named in
+// their FROM clause.
+type Runner struct {
+	Source      DataSource // Lists the queried objects of queries naming no cluster
+	Clusters    ClusterSet // Clusters queries can name, optional
+	Planner     *Planner   // Plans the requests sent to the source, required
+	PageSize    int        // Objects listed per request, DefaultPageSize when 0
+	Concurrency int        // Clusters listed at a time, DefaultConcurrency when 0
+
+	WatchBackoff time.Duration // First delay before resuming a watch, DefaultWatchBackoff when 0
+}
+
+// NewRunner creates a runner listing objects from source, resolving
+// resources with resolver.
+func NewRunner(source DataSource, resolver Resolver) *Runner {
+	return &Runner{Source: source, Planner: NewPlanner(resolver)}
+}
+
+// Run runs a query. The objects are listed page by page with the namespace,
+// selectors and page size of the List step of the query plan, then the
+// query is evaluated over them. The resources of joins and subqueries are
+// listed in turn, each with its own List step.
+//
+// Queries naming clusters list the objects of every matching cluster
+// concurrently, with the name of their cluster in ClusterField. Clusters
+// that fail are reported in the Errors of the result, which holds the rows
+// of the other clusters; Run only fails when every cluster fails.
+func (r *Runner) Run(ctx context.Context, q *Query) (*Result, error) {
+	steps, err := r.listSteps(q)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([][]map[string]any, len(steps))
+	var errs []*ClusterError
+	for i, step := range steps {
+		// Only the first rows are returned when the source pages the query
+		remaining := -1
+		if step.list.Limit != nil {
+			remaining = *step.list.Limit
+		}
+		if len(step.list.Clusters) == 0 {
+			if r.Source == nil {
+				return nil, errors.New("no data source, name the clusters to query in the FROM clause")
+			}
+			if tables[i], _, err = r.list(ctx, r.Source, step.resource, step.list, remaining); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		objects, clusterErrs, err := r.listClusters(ctx, step.resource, step.list, remaining)
+		if err != nil {
+			return nil, err
+		}
+		tables[i] = objects
+		errs = append(errs, clusterErrs...)
+	}
+
+	result, err := ExecuteJoin(q, tables)
+	if err != nil {
+		return nil, err
+	}
+	result.Errors = errs
+	return result, nil
+}
+
+// listClusters lists the objects of a List step from the clusters matching
+// its patterns, at most Concurrency clusters at a time. Objects are copied
+// with the name of their cluster set, and returned in cluster name order.
+// Clusters that fail are returned as errors, unless they all fail.
+func (r *Runner) listClusters(ctx context.Context, resource ResourceInfo, list *PlanNode, remaining int) ([]map[string]any, []*ClusterError, error) {
+	if r.Clusters == nil {
+		return nil, nil, fmt.Errorf("no clusters to match '%s'", strings.Join(list.Clusters, ","))
+	}
+	var names []string
+	for _, name := range r.Clusters.Clusters() {
+		if slices.ContainsFunc(list.Clusters, func(pattern string) bool { return MatchCluster(pattern, name) }) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no cluster matches '%s'", strings.Join(list.Clusters, ","))
+	}
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	listed := make([][]map[string]any, len(names))
+	failures := make([]error, len(names))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Go(func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			source, err := r.Clusters.Source(name)
+			if err == nil {
+				listed[i], _, err = r.list(ctx, source, resource, list, remaining)
+			}
+			failures[i] = err
+		})
+	}
+	wg.Wait()
+
+	var objects []map[string]any
+	var errs []*ClusterError
+	for i, name := range names {
+		if failures[i] != nil {
+			errs = append(errs, &ClusterError{Cluster: name, Err: failures[i]})
+			continue
+		}
+		for _, object := range listed[i] {
+			// Sources may share their objects, e.g. datasets
+			object = maps.Clone(object)
+			object[ClusterField] = name
+			objects = append(objects, object)
+		}
+	}
+	if len(errs) == len(names) {
+		joined := make([]error, len(errs))
+		for i, err := range errs {
+			joined[i] = err
+		}
+		return nil, nil, errors.Join(joined...)
+	}
+	return objects, errs, nil
+}
+
+// Watch runs a query, then keeps its result up to date from the changes of
+// the objects, until ctx is done or handle returns an error. The changes
+// of the first result are handled as one batch of added rows, then the
+// changes of each event. The source must be a Watcher. Watches are resumed
+// when they end, after a backoff with jitter, and the objects are listed
+// again when their resource version expired.
+func (r *Runner) Watch(ctx context.Context, q *Query, handle func([]Change) error) error {
+	steps, err := r.listSteps(q)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(steps) > 1 && len(q.Compound) > 0:
+		return fmt.Errorf("can not watch queries with %s", q.Compound[0].Op)
+	case len(steps) > 1 && len(q.Joins) > 0:
+		return errors.New("can not watch queries joining resources")
+	case len(steps) > 1:
+		return errors.New("can not watch queries with subqueries")
+	}
+	resource, list := steps[0].resource, steps[0].list
+	if len(list.Clusters) > 0 {
+		return errors.New("can not watch queries naming clusters")
+	}
+	watcher, ok := r.Source.(Watcher)
+	if !ok {
+		return fmt.Errorf("data source %T can not watch objects", r.Source)
+	}
+	view, err := NewView(q)
+	if err != nil {
+		return err
+	}
+
+	backoff := r.WatchBackoff
+	if backoff <= 0 {
+		backoff = DefaultWatchBackoff
+	}
+	delay := backoff
+	opts := ListOptions{Namespace: list.Namespace, LabelSelector: list.LabelSelector, FieldSelector: list.FieldSelector}
+	for {
+		// Rows leaving the LIMIT window are replaced, so every object is listed
+		objects, version, err := r.list(ctx, watcher, resource, list, -1)
+		if err != nil {
+			return err
+		}
+		changes, err := view.Sync(objects)
+		if err != nil {
+			return err
+		}
+		if err := handle(changes); err != nil {
+			return err
+		}
+
+		opts.ResourceVersion = version
+		for expired := false; !expired; {
+			var events int
+			if expired, events, err = r.watch(ctx, watcher, resource, view, &opts, handle); err != nil {
+				return err
+			}
+
+			// Watches ending without events are resumed less and less often
+			if events > 0 {
+				delay = backoff
+			}
+			wait := delay/2 + rand.N(delay/2+1)
+			delay = min(2*delay, max(MaxWatchBackoff, backoff))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// watch applies the events of a watch to a view until the watch ends, and
+// reports whether it ended because its resource version expired, with the
+// number of events it received. The resource version of opts follows the
+// events.
+func (r *Runner) watch(ctx context.Context, watcher Watcher, resource ResourceInfo, view *View, opts *ListOptions, handle func([]Change) error) (expired bool, received int, err error) {
+	// Stop the watch when returning before it ends
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events, err := watcher.Watch(ctx, resource, *opts)
+	if err != nil {
+		return false, 0, fmt.Errorf("error watching %s: %w", resource.Name, err)
+	}
+	for event := range events {
+		if event.Type == EventError && isExpired(event.Object) {
+			return true, received, nil
+		}
+		received++
+		changes, err := view.Apply(event)
+		if err != nil {
+			return false, received, err
+		}
+		metadata, _ := event.Object["metadata"].(map[string]any)
+		if version, ok := metadata["resourceVersion"].(string); ok && version != "" {
+			opts.ResourceVersion = version
+		}
+		if len(changes) > 0 {
+			if err := handle(changes); err != nil {
+				return false, received, err
+			}
+		}
+	}
+	return false, received, nil
+}
+
+// isExpired reports whether the Status of an ERROR event means the watched
+// resource version is too old, and the objects must be listed again.
+func isExpired(status map[string]any) bool {
+	code, _ := status["code"].(float64)
+	return code == 410 || status["reason"] == "Expired" || status["reason"] == "Gone"
+}
+
+// listStep is a List step of a query plan, with the resource it lists.
+type listStep struct {
+	resource ResourceInfo
+	list     *PlanNode
+}
+
+// listSteps returns the List steps of the plan of a query, one for each of
+// its resources: the ones of its common table expressions first, then the
+// FROM resource, the joined ones in order, then the ones of its subqueries,
+// see allTables.
+func (r *Runner) listSteps(q *Query) ([]listStep, error) {
+	plan, err := r.Planner.Plan(q)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := allTables(q)
+	if err != nil {
+		return nil, err
+	}
+
+	// Joins list their first input before the joined resource, and filters
+	// their input before their subqueries
+	var steps []listStep
+	var visit func(node *PlanNode)
+	visit = func(node *PlanNode) {
+		if node.Op == ListOp {
+			steps = append(steps, listStep{list: node})
+		}
+		for _, child := range node.Children {
+			visit(child)
+		}
+	}
+	for _, step := range plan.With {
+		visit(step)
+	}
+	visit(plan.Root)
+	if len(steps) != len(tables) {
+		return nil, fmt.Errorf("plan lists %d resources, the query has %d", len(steps), len(tables))
+	}
+	for i, t := range tables {
+		if steps[i].resource, err = r.Planner.Resolver.Resolve(t.ref.Resource); err != nil {
+			return nil, err
+		}
+	}
+	return steps, nil
+}
+
+// list lists the objects of a List step from a source page by page, at
+// most remaining objects unless it is negative, and returns them with the
+// resource version of the first page.
+func (r *Runner) list(ctx context.Context, source DataSource, resource ResourceInfo, list *PlanNode, remaining int) ([]map[string]any, string, error) {
+	pageSize := r.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	opts := ListOptions{
+		Namespace:     list.Namespace,
+		LabelSelector: list.LabelSelector,
+		FieldSelector: list.FieldSelector,
+		Limit:         pageSize,
+	}
+
+	var objects []map[string]any
+	var version string
+	for remaining != 0 {
+		if remaining > 0 {
+			opts.Limit = min(pageSize, remaining)
+		}
+		page, err := source.List(ctx, resource, opts)
+		if err != nil {
+			return nil, "", fmt.Errorf("error listing %s: %w", resource.Name, err)
+		}
+		if opts.Continue == "" {
+			version = page.ResourceVersion
+		}
+		items := page.Items
+		if remaining > 0 {
+			items = items[:min(len(items), remaining)]
+			remaining -= len(items)
+		}
+		objects = append(objects, items...)
+		if page.Continue == "" {
+			break
+		}
+		opts.Continue = page.Continue
+	}
+	return objects, version, nil
+}
@@ -0,0 +1,306 @@
+package kubesql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingSource is a DataSource recording the options of its requests.
+type recordingSource struct {
+	DataSource
+	requests []ListOptions
+}
+
+func (s *recordingSource) List(ctx context.Context, resource ResourceInfo, opts ListOptions) (*ObjectList, error) {
+	s.requests = append(s.requests, opts)
+	return s.DataSource.List(ctx, resource, opts)
+}
+
+// objectNames returns the metadata.name of objects.
+func objectNames(objects []map[string]any) []string {
+	var names []string
+	for _, object := range objects {
+		metadata, _ := object["metadata"].(map[string]any)
+		name, _ := metadata["name"].(string)
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestDatasetList(t *testing.T) {
+	dataset, err := LoadDataset("testdata/snapshot")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	pods, _ := DefaultResolver().Resolve("pods")
+
+	testCases := []struct {
+		opts     ListOptions
+		expected []string
+		next     string
+	}{
+		{ListOptions{}, []string{"web-1", "web-2", "coredns"}, ""},
+		{ListOptions{Namespace: "kube-system"}, []string{"coredns"}, ""},
+		{ListOptions{LabelSelector: "app=web"}, []string{"web-1", "web-2"}, ""},
+		{ListOptions{LabelSelector: "app!=web"}, []string{"coredns"}, ""},
+		{ListOptions{LabelSelector: "app in (db,web),!k8s-app"}, []string{"web-1", "web-2"}, ""},
+		{ListOptions{LabelSelector: "k8s-app notin (kube-dns)"}, []string{"web-1", "web-2"}, ""},
+		{ListOptions{LabelSelector: "k8s-app"}, []string{"coredns"}, ""},
+		{ListOptions{FieldSelector: "status.phase=Running,spec.nodeName!=node-b"}, []string{"web-1", "coredns"}, ""},
+		{ListOptions{FieldSelector: "metadata.name==web-2"}, []string{"web-2"}, ""},
+		{ListOptions{Limit: 2}, []string{"web-1", "web-2"}, "2"},
+		{ListOptions{Limit: 2, Continue: "2"}, []string{"coredns"}, ""},
+	}
+
+	for _, tc := range testCases {
+		list, err := dataset.List(context.Background(), pods, tc.opts)
+		if err != nil {
+			t.Errorf("For options %+v, expected no error, got %v", tc.opts, err)
+			continue
+		}
+		if names := objectNames(list.Items); !reflect.DeepEqual(names, tc.expected) || list.Continue != tc.next {
+			t.Errorf("For options %+v, expected %v and continue '%s', got %v and '%s'", tc.opts, tc.expected, tc.next, names, list.Continue)
+		}
+	}
+
+	for _, opts := range []ListOptions{{Continue: "x"}, {Continue: "9"}, {LabelSelector: "app in (web"}, {LabelSelector: "app is (web)"}} {
+		if _, err := dataset.List(context.Background(), pods, opts); err == nil {
+			t.Errorf("For options %+v, expected an error", opts)
+		}
+	}
+}
+
+func TestRunner(t *testing.T) {
+	dataset, err := LoadDataset("testdata/snapshot")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected [][]any
+		requests []ListOptions
+	}{
+		{
+			"SELECT name FROM pods WHERE labels.app = 'web' ORDER BY name DESC",
+			[][]any{{"web-2"}, {"web-1"}},
+			[]ListOptions{{LabelSelector: "app=web", Limit: 2}},
+		},
+		{
+			"SELECT name FROM pods ORDER BY name",
+			[][]any{{"coredns"}, {"web-1"}, {"web-2"}},
+			[]ListOptions{{Limit: 2}, {Limit: 2, Continue: "2"}},
+		},
+		{
+			"SELECT name FROM kube-system/pods",
+			[][]any{{"coredns"}},
+			[]ListOptions{{Namespace: "kube-system", Limit: 2}},
+		},
+		{
+			"SELECT name FROM pods WHERE status.phase = 'Running' LIMIT 1",
+			[][]any{{"web-1"}},
+			[]ListOptions{{FieldSelector: "status.phase=Running", Limit: 1}},
+		},
+		{
+			"SELECT count(*) FROM pods WHERE spec.nodeName = 'node-a' AND name LIKE 'web%'",
+			[][]any{{float64(1)}},
+			[]ListOptions{{FieldSelector: "spec.nodeName=node-a", Limit: 2}},
+		},
+		{"SELECT name FROM pods LIMIT 0", [][]any{}, nil},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		source := &recordingSource{DataSource: dataset}
+		runner := NewRunner(source, DefaultResolver())
+		runner.PageSize = 2
+
+		result, err := runner.Run(context.Background(), query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Rows, tc.expected) {
+			t.Errorf("For input '%s', expected rows %v, got %v", tc.input, tc.expected, result.Rows)
+		}
+		if !reflect.DeepEqual(source.requests, tc.requests) {
+			t.Errorf("For input '%s', expected requests %+v, got %+v", tc.input, tc.requests, source.requests)
+		}
+	}
+}
+
+func TestRunnerJoin(t *testing.T) {
+	dataset, err := LoadDataset("testdata/cluster")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	testCases := []struct {
+		input    string
+		expected [][]any
+		requests []ListOptions
+	}{
+		{
+			"SELECT p.name, n.name FROM default/pods p JOIN nodes n ON p.spec.nodeName = n.name AND n.labels.role = 'worker' WHERE p.labels.app = 'web'",
+			[][]any{{"web-1", "node-a"}},
+			[]ListOptions{{Namespace: "default", LabelSelector: "app=web", Limit: 500}, {LabelSelector: "role=worker", Limit: 500}},
+		},
+		{
+			// Rows without a service are kept, its condition is not pushed down
+			"SELECT p.name FROM pods p LEFT JOIN services s ON s.spec.selector.app = p.labels.app AND s.namespace = p.namespace WHERE s.name IS NULL LIMIT 1",
+			[][]any{{"db-1"}},
+			[]ListOptions{{Limit: 500}, {Limit: 500}},
+		}, {
+			// Common table expressions list their resources first, with their selectors
+			"WITH web AS (SELECT name, spec.nodeName AS node FROM pods WHERE labels.app = 'web') SELECT w.name, n.name FROM web w JOIN nodes n ON n.name = w.node WHERE n.labels.role = 'worker'",
+			[][]any{{"web-1", "node-a"}},
+			[]ListOptions{{LabelSelector: "app=web", Limit: 500}, {LabelSelector: "role=worker", Limit: 500}},
+		},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		source := &recordingSource{DataSource: dataset}
+		result, err := NewRunner(source, DefaultResolver()).Run(context.Background(), query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Rows, tc.expected) {
+			t.Errorf("For input '%s', expected rows %v, got %v", tc.input, tc.expected, result.Rows)
+		}
+		if !reflect.DeepEqual(source.requests, tc.requests) {
+			t.Errorf("For input '%s', expected requests %+v, got %+v", tc.input, tc.requests, source.requests)
+		}
+	}
+}
+
+// failingSource is a DataSource failing every request, like an unreachable
+// cluster.
+type failingSource struct {
+	err error
+}
+
+func (s failingSource) List(ctx context.Context, resource ResourceInfo, opts ListOptions) (*ObjectList, error) {
+	return nil, s.err
+}
+
+func TestRunnerClusters(t *testing.T) {
+	dataset, err := LoadDataset("testdata/snapshot")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	unreachable := errors.New("connection refused")
+	clusters := StaticClusters{
+		"prod-eu":     dataset,
+		"prod-us":     dataset,
+		"prod-broken": failingSource{unreachable},
+		"staging":     dataset,
+	}
+
+	testCases := []struct {
+		input    string
+		expected [][]any
+		errors   []string
+	}{
+		{
+			"SELECT cluster, name FROM prod-*:kube-system/pods",
+			[][]any{{"prod-eu", "coredns"}, {"prod-us", "coredns"}},
+			[]string{"prod-broken"},
+		},
+		{
+			"SELECT count(*) FROM prod-eu,staging:pods",
+			[][]any{{float64(6)}},
+			nil,
+		},
+		{
+			"SELECT cluster, name FROM *:pods WHERE cluster != 'staging' AND labels.app = 'web' ORDER BY cluster DESC, name LIMIT 3",
+			[][]any{{"prod-us", "web-1"}, {"prod-us", "web-2"}, {"prod-eu", "web-1"}},
+			[]string{"prod-broken"},
+		},
+		{
+			"SELECT cluster, name FROM staging,prod-e*:default/pods LIMIT 3",
+			[][]any{{"prod-eu", "web-1"}, {"prod-eu", "web-2"}, {"staging", "web-1"}},
+			nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		runner := NewRunner(nil, DefaultResolver())
+		runner.Clusters = clusters
+		runner.Concurrency = 2
+
+		result, err := runner.Run(context.Background(), query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result.Rows, tc.expected) {
+			t.Errorf("For input '%s', expected rows %v, got %v", tc.input, tc.expected, result.Rows)
+		}
+		var failed []string
+		for _, err := range result.Errors {
+			failed = append(failed, err.Cluster)
+			if !errors.Is(err, unreachable) {
+				t.Errorf("For input '%s', expected the error of the source, got %v", tc.input, err)
+			}
+		}
+		if !reflect.DeepEqual(failed, tc.errors) {
+			t.Errorf("For input '%s', expected failed clusters %v, got %v", tc.input, tc.errors, failed)
+		}
+	}
+
+	// The objects of the sources are not changed
+	pods, _ := DefaultResolver().Resolve("pods")
+	for _, pod := range dataset.Objects(pods) {
+		if _, ok := pod[ClusterField]; ok {
+			t.Errorf("Expected the dataset objects to have no cluster field, got %v", pod)
+		}
+	}
+}
+
+func TestRunnerClustersErrors(t *testing.T) {
+	dataset, err := LoadDataset("testdata/snapshot")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	clusters := StaticClusters{"prod": dataset, "broken": failingSource{errors.New("connection refused")}}
+
+	testCases := []struct {
+		clusters ClusterSet
+		input    string
+		expected string
+	}{
+		{clusters, "SELECT name FROM broken:pods", "cluster broken: error listing pods: connection refused"},
+		{clusters, "SELECT name FROM dev-*,qa:pods", "no cluster matches 'dev-*,qa'"},
+		{nil, "SELECT name FROM prod:pods", "no clusters to match 'prod'"},
+		{clusters, "SELECT name FROM pods", "no data source"},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		runner := NewRunner(nil, DefaultResolver())
+		runner.Clusters = tc.clusters
+
+		_, err = runner.Run(context.Background(), query)
+		if err == nil || !strings.Contains(err.Error(), tc.expected) {
+			t.Errorf("For input '%s', expected error containing '%s', got %v", tc.input, tc.expected, err)
+		}
+	}
+}
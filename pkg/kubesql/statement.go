@@ -0,0 +1,345 @@
+package kubesql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Node is a node of the syntax tree of a query: a *Statement, one of its
+// clauses, or an Expr. String returns the node as it is written in a query.
+type Node interface {
+	String() string
+}
+
+// Statement is the syntax tree of a query, with its clauses parsed. It is
+// built from a Query with NewStatement and turned back into one with Query.
+type Statement struct {
+	With     []*CommonTable   // Common table expressions of the WITH clause, in order
+	Select   *SelectClause    // Nil when the query has no SELECT clause
+	From     *FromClause      // Queried resource
+	Joins    []*JoinClause    // Joined resources, in order
+	Where    *WhereClause     // Nil when the query has no WHERE clause
+	Compound []*CompoundQuery // Queries combined with the query by set operations, in order
+	OrderBy  *OrderByClause   // Nil when the query has no ORDER BY clause
+	Limit    int              // Maximum number of results (-1 means no limit)
+	Explain  bool             // Set for EXPLAIN statements
+	Pos      Position         // Location of the statement in the query or script text
+	Comments []Comment        // Comments belonging to the statement, in source order
+}
+
+// CommonTable is a common table expression of a WITH clause.
+type CommonTable struct {
+	Name  string     // Name reading the rows of the query in FROM clauses and joins
+	Query *Statement // The named query
+}
+
+// CompoundQuery is a query combined with the rows of the statement by a set
+// operation.
+type CompoundQuery struct {
+	Op    string     // UNION, INTERSECT or EXCEPT
+	All   bool       // Keeps duplicate rows
+	Query *Statement // The combined query
+}
+
+// SelectClause is the list of fields of a SELECT clause.
+type SelectClause struct {
+	Fields []*SelectItem
+}
+
+// SelectItem is a field of a SELECT clause.
+type SelectItem struct {
+	Expr  Expr
+	Alias string // Empty when the field has no alias
+}
+
+// FromClause is the resource of a FROM clause, or of a join.
+type FromClause struct {
+	Clusters  []string // Cluster name patterns to query, empty for the default cluster
+	Namespace string   // Namespace to query, empty for the default scope
+	Resource  string   // Resource name as written, e.g. "pods" or "deployments.apps"
+	Alias     string   // Name qualifying the fields of the resource, empty for the resource name
+}
+
+// JoinClause is a JOIN of the FROM clause.
+type JoinClause struct {
+	Type string      // INNER or LEFT
+	From *FromClause // Joined resource
+	On   Expr        // Join condition
+}
+
+// WhereClause is the condition of a WHERE clause.
+type WhereClause struct {
+	Cond Expr
+}
+
+// OrderByClause is the list of sort keys of an ORDER BY clause.
+type OrderByClause struct {
+	Keys []*OrderByItem
+}
+
+// OrderByItem is a sort key of an ORDER BY clause.
+type OrderByItem struct {
+	Expr      Expr
+	Direction string // ASC or DESC
+}
+
+// NewStatement parses the clauses of a query into a syntax tree.
+func NewStatement(q *Query) (*Statement, error) {
+	ref, err := ParseResourceRef(q.From)
+	if err != nil {
+		return nil, err
+	}
+	s := &Statement{
+		From:     &FromClause{Clusters: ref.Clusters, Namespace: ref.Namespace, Resource: ref.Resource, Alias: q.FromAlias},
+		Limit:    q.Limit,
+		Explain:  q.Explain,
+		Pos:      q.Pos,
+		Comments: q.Comments,
+	}
+
+	for _, cte := range q.With {
+		cq, err := cte.parse()
+		if err != nil {
+			return nil, err
+		}
+		query, err := NewStatement(cq)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing common table expression '%s': %w", cte.Name, err)
+		}
+		s.With = append(s.With, &CommonTable{Name: cte.Name, Query: query})
+	}
+
+	for _, join := range q.Joins {
+		ref, err := ParseResourceRef(join.From)
+		if err != nil {
+			return nil, err
+		}
+		on, err := ParseExpr(join.On)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s condition: %w", OnKeyword, err)
+		}
+		s.Joins = append(s.Joins, &JoinClause{
+			Type: join.Type,
+			From: &FromClause{Clusters: ref.Clusters, Namespace: ref.Namespace, Resource: ref.Resource, Alias: join.Alias},
+			On:   on,
+		})
+	}
+
+	if len(q.Select) > 0 {
+		s.Select = &SelectClause{}
+		for _, field := range q.Select {
+			expr, err := ParseExpr(field.Field)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s clause: %w", SelectKeyword, err)
+			}
+			s.Select.Fields = append(s.Select.Fields, &SelectItem{Expr: expr, Alias: field.Alias})
+		}
+	}
+
+	if q.Where != "" {
+		cond, err := ParseExpr(q.Where)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s clause: %w", WhereKeyword, err)
+		}
+		s.Where = &WhereClause{Cond: cond}
+	}
+
+	for _, op := range q.Compound {
+		operand, err := op.parse()
+		if err != nil {
+			return nil, err
+		}
+		query, err := NewStatement(operand)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing query after %s: %w", op.keyword(), err)
+		}
+		s.Compound = append(s.Compound, &CompoundQuery{Op: op.Op, All: op.All, Query: query})
+	}
+
+	if len(q.OrderBy) > 0 {
+		s.OrderBy = &OrderByClause{}
+		for _, field := range q.OrderBy {
+			expr, err := ParseExpr(field.Field)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s clause: %w", OrderByKeyword, err)
+			}
+			s.OrderBy.Keys = append(s.OrderBy.Keys, &OrderByItem{Expr: expr, Direction: field.Direction})
+		}
+	}
+	return s, nil
+}
+
+// Query returns the query of a statement. Expressions get the parentheses
+// the precedence of their operators requires, so rewritten trees keep their
+// meaning.
+func (s *Statement) Query() *Query {
+	q := &Query{Limit: s.Limit, Explain: s.Explain, Pos: s.Pos, Comments: s.Comments}
+	for _, cte := range s.With {
+		q.With = append(q.With, CTE{Name: cte.Name, Query: TSLQuery(cte.Query.String())})
+	}
+	if s.From != nil {
+		q.From, q.FromAlias = s.From.ref().String(), s.From.Alias
+	}
+	for _, join := range s.Joins {
+		q.Joins = append(q.Joins, Join{Type: join.Type, From: join.From.ref().String(), Alias: join.From.Alias, On: TSLQuery(groupExpr(join.On).String())})
+	}
+	if s.Select != nil {
+		for _, field := range s.Select.Fields {
+			q.Select = append(q.Select, SelectField{Field: TSLQuery(groupExpr(field.Expr).String()), Alias: field.Alias})
+		}
+	}
+	if s.Where != nil {
+		q.Where = TSLQuery(groupExpr(s.Where.Cond).String())
+	}
+	for _, c := range s.Compound {
+		q.Compound = append(q.Compound, SetOperation{Op: c.Op, All: c.All, Query: TSLQuery(c.Query.String())})
+	}
+	if s.OrderBy != nil {
+		for _, key := range s.OrderBy.Keys {
+			q.OrderBy = append(q.OrderBy, OrderByField{Field: TSLQuery(groupExpr(key.Expr).String()), Direction: key.Direction})
+		}
+	}
+	return q
+}
+
+func (s *Statement) String() string {
+	return s.Query().String()
+}
+
+func (c *CommonTable) String() string {
+	return quoteIdentifier(c.Name) + " AS (" + c.Query.String() + ")"
+}
+
+func (c *CompoundQuery) String() string {
+	return SetOperation{Op: c.Op, All: c.All}.keyword() + " " + c.Query.String()
+}
+
+func (c *SelectClause) String() string {
+	fields := make([]string, len(c.Fields))
+	for i, field := range c.Fields {
+		fields[i] = field.String()
+	}
+	return SelectKeyword + " " + strings.Join(fields, ", ")
+}
+
+func (i *SelectItem) String() string {
+	if i.Alias == "" {
+		return groupExpr(i.Expr).String()
+	}
+	return groupExpr(i.Expr).String() + " AS " + quoteIdentifier(i.Alias)
+}
+
+func (c *FromClause) String() string {
+	return FromKeyword + " " + c.table()
+}
+
+// table returns the resource of the clause with its alias.
+func (c *FromClause) table() string {
+	if c.Alias == "" {
+		return c.ref().String()
+	}
+	return c.ref().String() + " " + quoteIdentifier(c.Alias)
+}
+
+func (c *JoinClause) String() string {
+	text := JoinKeyword + " " + c.From.table() + " " + OnKeyword + " " + groupExpr(c.On).String()
+	if c.Type == LeftJoin {
+		return LeftJoin + " " + text
+	}
+	return text
+}
+
+// ref returns the resource reference of the clause.
+func (c *FromClause) ref() ResourceRef {
+	return ResourceRef{Clusters: c.Clusters, Namespace: c.Namespace, Resource: c.Resource}
+}
+
+func (c *WhereClause) String() string {
+	return WhereKeyword + " " + groupExpr(c.Cond).String()
+}
+
+func (c *OrderByClause) String() string {
+	keys := make([]string, len(c.Keys))
+	for i, key := range c.Keys {
+		keys[i] = key.String()
+	}
+	return OrderByKeyword + " " + strings.Join(keys, ", ")
+}
+
+func (i *OrderByItem) String() string {
+	return groupExpr(i.Expr).String() + " " + i.Direction
+}
+
+// Precedence levels of expressions, from the loosest to the tightest.
+const (
+	orPrecedence = iota + 1
+	andPrecedence
+	notPrecedence
+	comparisonPrecedence
+	additivePrecedence
+	multiplicativePrecedence
+	negationPrecedence
+	primaryPrecedence
+)
+
+// precedence returns the precedence level of an expression.
+func precedence(expr Expr) int {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		switch e.Op {
+		case "OR":
+			return orPrecedence
+		case "AND":
+			return andPrecedence
+		case "+", "-":
+			return additivePrecedence
+		case "*", "/", "%":
+			return multiplicativePrecedence
+		}
+		return comparisonPrecedence
+	case *InExpr, *BetweenExpr, *IsNullExpr:
+		return comparisonPrecedence
+	case *UnaryExpr:
+		if e.Op == "NOT" {
+			return notPrecedence
+		}
+		return negationPrecedence
+	}
+	return primaryPrecedence
+}
+
+// group parenthesizes an operand binding looser than level.
+func group(expr Expr, level int) Expr {
+	if precedence(expr) < level {
+		return &ParenExpr{X: expr}
+	}
+	return expr
+}
+
+// groupExpr adds the parentheses an expression needs to be read back as
+// the same tree, e.g. around an OR operand of AND.
+func groupExpr(expr Expr) Expr {
+	return Rewrite(expr, func(node Node) Node {
+		switch e := node.(type) {
+		case *UnaryExpr:
+			e.X = group(e.X, precedence(e))
+		case *BinaryExpr:
+			// Comparisons do not chain, the other operators associate left
+			level := precedence(e)
+			left := level
+			if level == comparisonPrecedence {
+				left = level + 1
+			}
+			e.X, e.Y = group(e.X, left), group(e.Y, level+1)
+		case *InExpr:
+			e.X = group(e.X, comparisonPrecedence+1)
+		case *BetweenExpr:
+			e.X = group(e.X, comparisonPrecedence+1)
+			e.Low = group(e.Low, comparisonPrecedence+1)
+			e.High = group(e.High, comparisonPrecedence+1)
+		case *IsNullExpr:
+			e.X = group(e.X, comparisonPrecedence+1)
+		}
+		return node
+	}).(Expr)
+}
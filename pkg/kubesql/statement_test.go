@@ -0,0 +1,92 @@
+package kubesql
+
+import (
+	"testing"
+)
+
+func TestNewStatement(t *testing.T) {
+	testCases := []string{
+		"SELECT name, status.phase AS phase FROM default/pods WHERE labels.app = 'web' ORDER BY phase DESC LIMIT 5",
+		"EXPLAIN FROM pods WHERE (a = 1 OR b = 2) AND NOT c IS NULL",
+		"SELECT count(*) FROM my-ns/deployments.apps",
+		"SELECT p.name FROM default/pods p LEFT JOIN nodes n ON p.spec.nodeName = n.name AND n.labels.role = 'worker' JOIN services AS s ON s.name = p.name",
+		"SELECT name FROM deployments UNION ALL SELECT name FROM statefulsets EXCEPT SELECT name FROM poddisruptionbudgets ORDER BY name ASC LIMIT 10",
+	}
+
+	for _, input := range testCases {
+		query, err := NewParser(input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		statement, err := NewStatement(query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", input, err)
+			continue
+		}
+		if statement.String() != query.String() {
+			t.Errorf("For input '%s', expected '%s', got '%s'", input, query.String(), statement.String())
+		}
+	}
+
+	query := &Query{From: "pods", Where: "a = = 1", Limit: -1}
+	if _, err := NewStatement(query); err == nil {
+		t.Errorf("Expected an error for an invalid WHERE clause")
+	}
+}
+
+func TestStatementClauses(t *testing.T) {
+	query, _ := NewParser("SELECT name AS n, len(spec.containers) FROM default/pods WHERE x > 1 ORDER BY n").Parse()
+	statement, err := NewStatement(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	testCases := []struct {
+		node     Node
+		expected string
+	}{
+		{statement.Select, "SELECT name AS n, len(spec.containers)"},
+		{statement.Select.Fields[0], "name AS n"},
+		{statement.From, "FROM default/pods"},
+		{statement.Where, "WHERE x > 1"},
+		{statement.OrderBy, "ORDER BY n ASC"},
+	}
+	for _, tc := range testCases {
+		if tc.node.String() != tc.expected {
+			t.Errorf("For node %T, expected '%s', got '%s'", tc.node, tc.expected, tc.node.String())
+		}
+	}
+}
+
+func TestGroupExpr(t *testing.T) {
+	a, b, c := &FieldRef{Path: []Segment{{Name: "a"}}}, &FieldRef{Path: []Segment{{Name: "b"}}}, &FieldRef{Path: []Segment{{Name: "c"}}}
+	one := &Literal{Kind: NumberLiteral, Value: "1"}
+
+	testCases := []struct {
+		expr     Expr
+		expected string
+	}{
+		{&BinaryExpr{Op: "AND", X: &BinaryExpr{Op: "OR", X: a, Y: b}, Y: c}, "(a OR b) AND c"},
+		{&BinaryExpr{Op: "OR", X: a, Y: &BinaryExpr{Op: "AND", X: b, Y: c}}, "a OR b AND c"},
+		{&BinaryExpr{Op: "-", X: a, Y: &BinaryExpr{Op: "-", X: b, Y: c}}, "a - (b - c)"},
+		{&BinaryExpr{Op: "-", X: &BinaryExpr{Op: "-", X: a, Y: b}, Y: c}, "a - b - c"},
+		{&BinaryExpr{Op: "*", X: &BinaryExpr{Op: "+", X: a, Y: one}, Y: b}, "(a + 1) * b"},
+		{&BinaryExpr{Op: "=", X: &BinaryExpr{Op: "=", X: a, Y: b}, Y: c}, "(a = b) = c"},
+		{&UnaryExpr{Op: "NOT", X: &BinaryExpr{Op: "AND", X: a, Y: b}}, "NOT (a AND b)"},
+		{&UnaryExpr{Op: "NOT", X: &BinaryExpr{Op: "=", X: a, Y: b}}, "NOT a = b"},
+		{&UnaryExpr{Op: "-", X: &BinaryExpr{Op: "+", X: a, Y: b}}, "-(a + b)"},
+		{&InExpr{X: &BinaryExpr{Op: "OR", X: a, Y: b}, List: []Expr{one}}, "(a OR b) IN (1)"},
+		{&IsNullExpr{X: &UnaryExpr{Op: "NOT", X: a}}, "(NOT a) IS NULL"},
+	}
+
+	for _, tc := range testCases {
+		grouped := groupExpr(tc.expr)
+		if grouped.String() != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.expr, tc.expected, grouped)
+		}
+		parsed, err := ParseExpr(TSLQuery(grouped.String()))
+		if err != nil || groupExpr(parsed).String() != grouped.String() {
+			t.Errorf("For input '%s', expected '%s' to parse back, got %v", tc.expr, grouped, err)
+		}
+	}
+}
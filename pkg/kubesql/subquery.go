@@ -0,0 +1,350 @@
+package kubesql
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// subqueries returns the subqueries of an expression in the order they are
+// written, without the subqueries nested in them.
+func subqueries(expr Expr) []*Statement {
+	var result []*Statement
+	if expr == nil {
+		return nil
+	}
+	Inspect(expr, func(node Node) bool {
+		if s, ok := node.(*Statement); ok {
+			result = append(result, s)
+			return false
+		}
+		return true
+	})
+	return result
+}
+
+// checkSubqueries checks where the subqueries of a statement are used:
+// only in its WHERE clause, and IN subqueries must select a single field.
+// Nested subqueries are not checked.
+func checkSubqueries(s *Statement) error {
+	var exprs []Expr
+	if s.Select != nil {
+		for _, field := range s.Select.Fields {
+			exprs = append(exprs, field.Expr)
+		}
+	}
+	for _, join := range s.Joins {
+		exprs = append(exprs, join.On)
+	}
+	if s.OrderBy != nil {
+		for _, key := range s.OrderBy.Keys {
+			exprs = append(exprs, key.Expr)
+		}
+	}
+	for _, expr := range exprs {
+		if len(subqueries(expr)) > 0 {
+			return errors.New("subqueries can only be used in the WHERE clause")
+		}
+	}
+
+	var err error
+	if s.Where != nil {
+		Inspect(s.Where.Cond, func(node Node) bool {
+			switch node := node.(type) {
+			case *InExpr:
+				if node.Subquery != nil && err == nil {
+					err = checkInSubquery(node.Subquery)
+				}
+			case *Statement:
+				return false
+			}
+			return err == nil
+		})
+	}
+	return err
+}
+
+// checkInSubquery checks that the subquery of an IN expression selects a
+// single field, whose values are matched.
+func checkInSubquery(s *Statement) error {
+	if s.Select == nil || len(s.Select.Fields) != 1 || isStar(s.Select.Fields[0].Expr) {
+		return errors.New("the subquery of IN must select a single field")
+	}
+	return nil
+}
+
+// allTables returns the tables reading resources of a query, of its common
+// table expressions, of the queries it combines and of their subqueries, in
+// the order ExecuteJoin takes their objects: the tables of each common table
+// expression in order, then the ones of each query it combines, the query
+// first, see scopeTables.
+func allTables(q *Query) ([]table, error) {
+	var tables []table
+	for i, cte := range q.With {
+		cq, err := cte.parse()
+		if err != nil {
+			return nil, err
+		}
+		inner, err := scopeTables(cq, withNames(q.With[:i]))
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, inner...)
+	}
+	queries, err := operands(q)
+	if err != nil {
+		return nil, err
+	}
+	for _, operand := range queries {
+		inner, err := scopeTables(operand, withNames(q.With))
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, inner...)
+	}
+	return tables, nil
+}
+
+// scopeTables returns the tables of a query and of its subqueries that read
+// resources rather than the common table expressions named with: the tables
+// of the query, then the tables of each subquery of its WHERE clause with its
+// own subqueries.
+func scopeTables(q *Query, with []string) ([]table, error) {
+	all, err := queryTables(q)
+	if err != nil {
+		return nil, err
+	}
+	var tables []table
+	for _, t := range all {
+		if cteName(t.ref, with) == "" {
+			tables = append(tables, t)
+		}
+	}
+	if q.Where == "" {
+		return tables, nil
+	}
+	where, err := ParseExpr(q.Where)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s clause: %w", WhereKeyword, err)
+	}
+	for _, s := range subqueries(where) {
+		inner, err := scopeTables(s.Query(), with)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, inner...)
+	}
+	return tables, nil
+}
+
+// visibleNames returns the names of the tables a query sees: the names of
+// its own tables, then the names outer of the tables of the enclosing
+// queries that its own tables do not hide.
+func visibleNames(tables []table, outer []string) []string {
+	names := make([]string, 0, len(tables)+len(outer))
+	for _, t := range tables {
+		names = append(names, t.name)
+	}
+	for _, name := range outer {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// scopeNames returns the names qualifying fields in the rows of a query,
+// see rowPath. Subqueries seeing the tables of enclosing queries, outer,
+// are evaluated over rows mapping every table they see to an object, and
+// their fields not qualified by a table name belong to their FROM resource.
+func scopeNames(tables []table, outer []string) []string {
+	if visible := visibleNames(tables, outer); len(visible) > len(tables) {
+		return visible
+	}
+	return tableNames(tables)
+}
+
+// outerTables returns the names, among outer, of the tables of enclosing
+// queries whose fields a subquery uses, directly or in its own subqueries.
+func outerTables(s *Statement, outer []string) ([]string, error) {
+	tables, err := queryTables(s.Query())
+	if err != nil {
+		return nil, err
+	}
+	names, visible := scopeNames(tables, outer), visibleNames(tables, outer)
+
+	used := make(map[string]bool)
+	Inspect(s, func(node Node) bool {
+		switch node := node.(type) {
+		case *FieldRef:
+			if i := tableOf(node.Path, names); i >= len(tables) {
+				used[names[i]] = true
+			}
+		case *Statement:
+			if node == s {
+				return true
+			}
+			inner, innerErr := outerTables(node, visible)
+			if innerErr != nil && err == nil {
+				err = innerErr
+			}
+			for _, name := range inner {
+				if !slices.ContainsFunc(tables, func(t table) bool { return t.name == name }) {
+					used[name] = true
+				}
+			}
+			return false
+		}
+		return true
+	})
+
+	var result []string
+	for _, name := range outer {
+		if used[name] {
+			result = append(result, name)
+		}
+	}
+	return result, err
+}
+
+// execution is a query compiled to run over the objects of its tables,
+// with its subqueries.
+type execution struct {
+	q          *Query
+	tables     []table
+	objects    [][]map[string]any // Objects of each table, in its namespace
+	e          *evaluator         // Evaluator of the rows of the query
+	selects    []Expr
+	where      Expr
+	outer      []string     // Tables of enclosing queries the query uses
+	subqueries []*execution // Subqueries of the WHERE clause, in order
+	result     *Result      // Result of a subquery using no enclosing table, once run
+}
+
+// newExecution compiles a query, or a subquery seeing the tables of the
+// enclosing queries named outer, whose tables may read the common table
+// expressions named with. Subqueries share the value of now(), the patterns
+// and the common table expression objects of the evaluator of the query
+// using them.
+func newExecution(q *Query, outer []string, parent *evaluator, with []string) (*execution, error) {
+	s, err := NewStatement(q)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSubqueries(s); err != nil {
+		return nil, err
+	}
+	x := &execution{q: q}
+	if x.tables, err = queryTables(q); err != nil {
+		return nil, err
+	}
+	bindTables(x.tables, with)
+	if x.selects, err = parseSelect(q); err != nil {
+		return nil, err
+	}
+	if q.Where != "" {
+		if x.where, err = ParseExpr(q.Where); err != nil {
+			return nil, fmt.Errorf("error parsing %s clause: %w", WhereKeyword, err)
+		}
+	}
+
+	visible := visibleNames(x.tables, outer)
+	x.e = &evaluator{now: parent.now, patterns: parent.patterns, ctes: parent.ctes, tables: scopeNames(x.tables, outer), scope: visible}
+	for _, s := range subqueries(x.where) {
+		sub, err := newExecution(s.Query(), visible, x.e, with)
+		if err != nil {
+			return nil, fmt.Errorf("error in subquery: %w", err)
+		}
+		if sub.outer, err = outerTables(s, visible); err != nil {
+			return nil, err
+		}
+		if x.e.subqueries == nil {
+			x.e.subqueries = make(map[*Statement]*execution)
+		}
+		x.e.subqueries[s] = sub
+		x.subqueries = append(x.subqueries, sub)
+	}
+	return x, nil
+}
+
+// setObjects sets the objects of the tables of the query and of its
+// subqueries reading resources, in the order of scopeTables, and returns
+// the objects left.
+func (x *execution) setObjects(tables [][]map[string]any) [][]map[string]any {
+	x.objects = make([][]map[string]any, len(x.tables))
+	for i, t := range x.tables {
+		if t.cte == "" && len(tables) > 0 {
+			x.objects[i], tables = inNamespace(tables[0], t.ref.Namespace), tables[1:]
+		}
+	}
+	for _, sub := range x.subqueries {
+		tables = sub.setObjects(tables)
+	}
+	return tables
+}
+
+// tableCount returns the number of tables of the query and its subqueries
+// reading resources.
+func (x *execution) tableCount() int {
+	count := 0
+	for _, t := range x.tables {
+		if t.cte == "" {
+			count++
+		}
+	}
+	for _, sub := range x.subqueries {
+		count += sub.tableCount()
+	}
+	return count
+}
+
+// subquery runs a subquery of the WHERE condition for a row. Subqueries
+// using no table of the enclosing queries run once.
+func (e *evaluator) subquery(s *Statement, row map[string]any) (*Result, error) {
+	x, ok := e.subqueries[s]
+	if !ok {
+		return nil, errors.New("subqueries can only be evaluated by running a query")
+	}
+	if len(x.outer) > 0 {
+		result, err := x.run(e.outerRow(row))
+		if err != nil {
+			return nil, fmt.Errorf("error running subquery: %w", err)
+		}
+		return result, nil
+	}
+	if x.result == nil {
+		result, err := x.run(nil)
+		if err != nil {
+			return nil, fmt.Errorf("error running subquery: %w", err)
+		}
+		x.result = result
+	}
+	return x.result, nil
+}
+
+// outerRow returns the objects a row of the query sees by table name, the
+// outer row of its subqueries.
+func (e *evaluator) outerRow(row map[string]any) map[string]any {
+	if len(e.tables) > 1 {
+		return row
+	}
+	return map[string]any{e.scope[0]: row}
+}
+
+// subqueryValues returns the values of the field an IN subquery selects
+// for a row. The items of lists are values too.
+func (e *evaluator) subqueryValues(s *Statement, row map[string]any) ([]any, error) {
+	result, err := e.subquery(s, row)
+	if err != nil {
+		return nil, err
+	}
+	var values []any
+	for _, columns := range result.Rows {
+		if list, ok := columns[0].([]any); ok {
+			values = append(values, list...)
+		} else {
+			values = append(values, columns[0])
+		}
+	}
+	return values, nil
+}
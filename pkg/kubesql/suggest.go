@@ -0,0 +1,127 @@
+package kubesql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// suggestError is an error about a word that looks like a misspelling of a
+// known keyword or name. Its message ends with a "did you mean" hint.
+type suggestError struct {
+	message    string // Description of the problem, without the hint
+	suggestion string // The likely intended word
+}
+
+// Error implements the error interface.
+func (e *suggestError) Error() string {
+	return fmt.Sprintf("%s, did you mean '%s'?", e.message, e.suggestion)
+}
+
+// suggestf formats an error about word, adding the closest of candidates as a
+// suggestion when one is close enough to be a likely misspelling.
+func suggestf(word string, candidates []string, format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+	if suggestion, ok := closestMatch(word, candidates); ok {
+		return &suggestError{message: message, suggestion: suggestion}
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// suggestClausef formats an error about word found where a clause keyword
+// may have been intended, suggesting the closest clause keyword.
+func suggestClausef(word, format string, args ...any) error {
+	message := fmt.Sprintf(format, args...)
+	if strings.EqualFold(word, "ORDER") {
+		return &suggestError{message: message, suggestion: OrderByKeyword}
+	}
+	if suggestion, ok := closestMatch(strings.ToUpper(word), []string{SelectKeyword, FromKeyword, WhereKeyword, "ORDER", LimitKeyword}); ok {
+		if suggestion == "ORDER" {
+			suggestion = OrderByKeyword
+		}
+		return &suggestError{message: message, suggestion: suggestion}
+	}
+	return fmt.Errorf("%s", message)
+}
+
+// suggestionOf returns the suggestion carried by err, if any.
+func suggestionOf(err error) string {
+	var suggestErr *suggestError
+	if errors.As(err, &suggestErr) {
+		return suggestErr.suggestion
+	}
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Suggestion
+	}
+	return ""
+}
+
+// closestMatch returns the candidate that word most likely misspells: the
+// one with the smallest edit distance, ignoring case, within a limit that
+// grows with the word length. A candidate that word starts with also
+// matches, e.g. DESC for DESCENDING. Ties go to the earliest candidate.
+func closestMatch(word string, candidates []string) (string, bool) {
+	length := utf8.RuneCountInString(word)
+	limit := 0
+	switch {
+	case length > 8:
+		limit = 3
+	case length > 4:
+		limit = 2
+	case length > 2:
+		limit = 1
+	}
+
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		if candidate == word {
+			continue
+		}
+		distance := editDistance(word, candidate)
+		if distance > limit {
+			if utf8.RuneCountInString(candidate) < 3 || !strings.HasPrefix(strings.ToLower(word), strings.ToLower(candidate)) {
+				continue
+			}
+			// Prefix matches rank after every close misspelling
+			distance = limit + 1
+		}
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best, bestDistance >= 0
+}
+
+// editDistance returns the number of single character insertions, deletions,
+// substitutions and adjacent transpositions that turn a into b, ignoring
+// case (the optimal string alignment distance).
+func editDistance(a, b string) int {
+	x := []rune(strings.ToLower(a))
+	y := []rune(strings.ToLower(b))
+
+	// Rolling rows of the distance matrix: two rows back, previous and current
+	before := make([]int, len(y)+1)
+	previous := make([]int, len(y)+1)
+	current := make([]int, len(y)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(x); i++ {
+		current[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+			if i > 1 && j > 1 && x[i-1] == y[j-2] && x[i-2] == y[j-1] {
+				current[j] = min(current[j], before[j-2]+1)
+			}
+		}
+		before, previous, current = previous, current, before
+	}
+	return previous[len(y)]
+}
@@ -0,0 +1,163 @@
+package kubesql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"", "", 0},
+		{"pods", "pods", 0},
+		{"PODS", "pods", 0},
+		{"podz", "pods", 1},
+		{"SELEC", "SELECT", 1},
+		{"FORM", "FROM", 1},
+		{"ADN", "AND", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+
+	for _, tc := range testCases {
+		if result := editDistance(tc.a, tc.b); result != tc.expected {
+			t.Errorf("For '%s' and '%s', expected %d, got %d", tc.a, tc.b, tc.expected, result)
+		}
+	}
+}
+
+func TestClosestMatch(t *testing.T) {
+	keywords := []string{"SELECT", "FROM", "WHERE", "LIMIT"}
+
+	testCases := []struct {
+		word       string
+		candidates []string
+		expected   string
+	}{
+		{"SELEC", keywords, "SELECT"},
+		{"FORM", keywords, "FROM"},
+		{"WHER", keywords, "WHERE"},
+		{"LIMT", keywords, "LIMIT"},
+		{"DESCENDING", []string{"ASC", "DESC"}, "DESC"},
+		{"ASCENDING", []string{"ASC", "DESC"}, "ASC"},
+		{"DSC", []string{"ASC", "DESC"}, "ASC"},
+		{"nodename", []string{"hostNetwork", "nodeName", "nodeSelector"}, "nodeName"},
+		{"deploymnets", []string{"daemonsets", "deployments"}, "deployments"},
+		{"UP", []string{"ASC", "DESC"}, ""},
+		{"name", keywords, ""},
+		{"xyz", keywords, ""},
+	}
+
+	for _, tc := range testCases {
+		result, ok := closestMatch(tc.word, tc.candidates)
+		if ok != (tc.expected != "") || result != tc.expected {
+			t.Errorf("For '%s', expected '%s', got '%s' (%v)", tc.word, tc.expected, result, ok)
+		}
+	}
+}
+
+func TestParseSuggestions(t *testing.T) {
+	testCases := []struct {
+		query      string
+		suggestion string
+		column     int
+	}{
+		{"SELEC name FORM pods", "SELECT", 1},
+		{"FRM pods", "FROM", 1},
+		{"SELECT name FORM pods", "FROM", 13},
+		{"SELECT name FROM pods ORDER BY name DESCENDING", "DESC", 32},
+		{"SELECT name FROM pods ORDER BY name ACS", "ASC", 32},
+		{"SELECT name FROM pods WHER name = 'a'", "WHERE", 18},
+		{"SELECT name FROM pods ORDR BY name", "ORDER BY", 18},
+		{"SELECT name FROM pods ORDER name", "ORDER BY", 18},
+		{"FROM pods LIMT 5", "LIMIT", 6},
+		{"FROM pods ORDER BY name DESC LIMT 5", "LIMIT", 20},
+		{"SELECT name FROM pods ORDER BY name UP", "", 32},
+		{"SELECT name FROM pods ORDER BY a + b DESCENDING", "DESC", 32},
+		{"FROM pods ORDER BY len(name) ASC DESC", "", 20},
+	}
+
+	for _, tc := range testCases {
+		_, err := NewParser(tc.query).Parse()
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("For query '%s', expected a *ParseError, got %v", tc.query, err)
+			continue
+		}
+		if parseErr.Suggestion != tc.suggestion {
+			t.Errorf("For query '%s', expected suggestion '%s', got '%s' (%v)", tc.query, tc.suggestion, parseErr.Suggestion, err)
+		}
+		if parseErr.Pos.Column != tc.column {
+			t.Errorf("For query '%s', expected error at column %d, got %d", tc.query, tc.column, parseErr.Pos.Column)
+		}
+	}
+}
+
+func TestParseExprSuggestions(t *testing.T) {
+	testCases := []struct {
+		input      TSLQuery
+		suggestion string
+	}{
+		{"name = 'a' ADN namespace = 'b'", "AND"},
+		{"name = 'a' RO namespace = 'b'", ""},
+		{"name = 'a' ORDR BY name", "ORDER BY"},
+		{"name LIKEE 'a%'", "LIKE"},
+	}
+
+	for _, tc := range testCases {
+		_, err := ParseExpr(tc.input)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("For input '%s', expected a *ParseError, got %v", tc.input, err)
+			continue
+		}
+		if parseErr.Suggestion != tc.suggestion {
+			t.Errorf("For input '%s', expected suggestion '%s', got '%s' (%v)", tc.input, tc.suggestion, parseErr.Suggestion, err)
+		}
+	}
+}
+
+func TestValidateSuggestions(t *testing.T) {
+	validator := newTestValidator(t)
+
+	testCases := []struct {
+		query      string
+		suggestion string
+	}{
+		{"FROM deploymnets", "deployments"},
+		{"FROM widget", ""},
+		{"FROM widgts", "widgets"},
+		{"SELECT nam FROM pods", "name"},
+		{"SELECT status.phse FROM pods", "status.phase"},
+		{"FROM pods WHERE spec.containers[0].imag = 'nginx'", "spec.containers[0].image"},
+		{"FROM pods WHERE labels.app = 'a' AND sttus.phase = 'Running'", "status.phase"},
+		{"SELECT cout(*) FROM pods", "count"},
+		{"FROM pods WHERE name = 'a' ADN namespace = 'b'", "AND"},
+	}
+
+	for _, tc := range testCases {
+		query, err := NewParser(tc.query).Parse()
+		if err != nil {
+			t.Fatalf("For query '%s', unexpected parse error: %v", tc.query, err)
+		}
+
+		err = validator.Validate(query)
+		if tc.suggestion == "" {
+			if err != nil {
+				t.Errorf("For query '%s', unexpected error: %v", tc.query, err)
+			}
+			continue
+		}
+
+		var validationErrs ValidationErrors
+		if !errors.As(err, &validationErrs) || len(validationErrs) != 1 {
+			t.Errorf("For query '%s', expected a single validation error, got %v", tc.query, err)
+			continue
+		}
+		if validationErrs[0].Suggestion != tc.suggestion {
+			t.Errorf("For query '%s', expected suggestion '%s', got '%s' (%v)", tc.query, tc.suggestion, validationErrs[0].Suggestion, err)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package kubesql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Type is the static type of the value of an expression.
+type Type int
+
+// Expression value types.
+const (
+	TypeUnknown   Type = iota // Not known statically, e.g. a field without a schema
+	TypeString                // Text
+	TypeInt                   // Integer number
+	TypeFloat                 // Floating point number
+	TypeBool                  // TRUE or FALSE
+	TypeQuantity              // Resource quantity, e.g. 500m or 1Gi
+	TypeDuration              // Length of time, e.g. 30s
+	TypeTimestamp             // Point in time, e.g. metadata.creationTimestamp
+	TypeList                  // Array, e.g. spec.containers
+	TypeMap                   // Object or map, e.g. metadata.labels
+	TypeNull                  // The NULL literal
+)
+
+var typeNames = [...]string{"unknown", "string", "int", "float", "bool", "quantity", "duration", "timestamp", "list", "map", "null"}
+
+// String returns the type name, e.g. "quantity".
+func (t Type) String() string {
+	if t < 0 || int(t) >= len(typeNames) {
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+	return typeNames[t]
+}
+
+// ParseType returns the type with the given name.
+func ParseType(name string) (Type, error) {
+	for i, typeName := range typeNames {
+		if strings.EqualFold(name, typeName) {
+			return Type(i), nil
+		}
+	}
+	return TypeUnknown, fmt.Errorf("unknown type '%s'", name)
+}
+
+// MarshalText implements encoding.TextMarshaler, types are written by name.
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (t *Type) UnmarshalText(text []byte) error {
+	parsed, err := ParseType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// isNumeric reports whether values of the type are numbers, quantities
+// included.
+func (t Type) isNumeric() bool {
+	return t == TypeInt || t == TypeFloat || t == TypeQuantity
+}
+
+// Column describes a column of the query result.
+type Column struct {
+	Name string `json:"name" yaml:"name"` // The SELECT alias, or the field expression in canonical form
+	Type Type   `json:"type" yaml:"type"` // Inferred type of the column values
+}
+
+// schemaType returns the type of the values described by a schema.
+func schemaType(s *Schema) Type {
+	switch {
+	case s == nil:
+		return TypeUnknown
+	case s.Format == "quantity":
+		return TypeQuantity
+	case s.IntOrString:
+		// Ports and similar fields accept both numbers and names
+		return TypeUnknown
+	}
+
+	switch s.Type {
+	case SchemaString:
+		switch s.Format {
+		case "date-time", "date":
+			return TypeTimestamp
+		case "duration":
+			return TypeDuration
+		}
+		return TypeString
+	case SchemaInteger:
+		return TypeInt
+	case SchemaNumber:
+		return TypeFloat
+	case SchemaBoolean:
+		return TypeBool
+	case SchemaArray:
+		return TypeList
+	case SchemaObject:
+		return TypeMap
+	}
+	return TypeUnknown
+}
+
+// InferType returns the type of an expression, using schema to type field
+// references. Fields the schema does not describe, and all fields when schema
+// is nil, are of unknown type. The first type error found is returned.
+func InferType(expr Expr, schema *Schema) (Type, error) {
+	var first error
+	checker := &typeChecker{schemas: []*Schema{schema}, report: func(_ Expr, err error) {
+		if first == nil {
+			first = err
+		}
+	}}
+
+	t := checker.infer(expr)
+	return t, first
+}
+
+// typeChecker infers expression types and reports type errors.
+type typeChecker struct {
+	schemas    []*Schema                  // Schemas of the tables of the query, the FROM resource first, may be nil
+	tables     []string                   // Names qualifying fields, see rowPath
+	subqueries map[*Statement]Type        // Types of the fields IN subqueries select, unknown when missing
+	report     func(expr Expr, err error) // Called for every type error
+}
+
+// reportf reports a type error in expr.
+func (c *typeChecker) reportf(expr Expr, format string, args ...any) {
+	c.report(expr, fmt.Errorf(format, args...))
+}
+
+// infer returns the type of an expression. Field paths with a [*] wildcard
+// are typed by their items, as conditions on them match any item.
+func (c *typeChecker) infer(expr Expr) Type {
+	switch expr := expr.(type) {
+	case *Literal:
+		switch expr.Kind {
+		case StringLiteral:
+			return TypeString
+		case BoolLiteral:
+			return TypeBool
+		case NullLiteral:
+			return TypeNull
+		}
+		t, err := numberType(expr.Value)
+		if err != nil {
+			c.report(expr, err)
+		}
+		return t
+	case *StarExpr:
+		return TypeMap
+	case *FieldRef:
+		// Unknown fields are reported by the validator
+		schema, err := lookupTableField(c.schemas, c.tables, expr.Path)
+		if err != nil {
+			return TypeUnknown
+		}
+		return schemaType(schema)
+	case *ParenExpr:
+		return c.infer(expr.X)
+	case *UnaryExpr:
+		return c.inferUnary(expr)
+	case *BinaryExpr:
+		return c.inferBinary(expr)
+	case *InExpr:
+		for _, item := range expr.List {
+			c.compare("IN", expr, expr.X, item)
+		}
+		if expr.Subquery != nil && checkInSubquery(expr.Subquery) == nil {
+			// The items of a selected list are matched too
+			xt, yt := c.infer(expr.X), c.subqueries[expr.Subquery]
+			if yt != TypeList && !comparableTypes(xt, yt, nil) && !comparableTypes(yt, xt, expr.X) {
+				c.reportf(expr, "type mismatch: %s is %s but the subquery selects %s", describeExpr(expr.X), article(xt.String()), article(yt.String()))
+			}
+		}
+		return TypeBool
+	case *ExistsExpr:
+		return TypeBool
+	case *BetweenExpr:
+		c.compare("BETWEEN", expr, expr.X, expr.Low)
+		c.compare("BETWEEN", expr, expr.X, expr.High)
+		return TypeBool
+	case *IsNullExpr:
+		c.infer(expr.X)
+		return TypeBool
+	case *CallExpr:
+		return c.inferCall(expr)
+	}
+	return TypeUnknown
+}
+
+// expectBool reports operands of logical operators that are not booleans.
+func (c *typeChecker) expectBool(op string, expr Expr) {
+	if t := c.infer(expr); t != TypeBool && t != TypeUnknown && t != TypeNull {
+		c.reportf(expr, "%s expects a bool, but %s is %s", op, describeExpr(expr), article(t.String()))
+	}
+}
+
+func (c *typeChecker) inferUnary(expr *UnaryExpr) Type {
+	if expr.Op == "NOT" {
+		c.expectBool(expr.Op, expr.X)
+		return TypeBool
+	}
+
+	switch t := c.infer(expr.X); t {
+	case TypeUnknown, TypeNull, TypeInt, TypeFloat, TypeQuantity, TypeDuration:
+		return t
+	default:
+		c.reportf(expr, "can not negate %s %s", t, describeExpr(expr.X))
+		return TypeUnknown
+	}
+}
+
+func (c *typeChecker) inferBinary(expr *BinaryExpr) Type {
+	switch expr.Op {
+	case "AND", "OR":
+		c.expectBool(expr.Op, expr.X)
+		c.expectBool(expr.Op, expr.Y)
+		return TypeBool
+	case "LIKE", "ILIKE", "~=", "!~":
+		for _, operand := range []Expr{expr.X, expr.Y} {
+			if t := c.infer(operand); t != TypeString && t != TypeUnknown && t != TypeNull {
+				c.reportf(operand, "%s requires a string, but %s is %s", expr.Op, describeExpr(operand), article(t.String()))
+			}
+		}
+		return TypeBool
+	case "+", "-", "*", "/", "%":
+		x, y := c.infer(expr.X), c.infer(expr.Y)
+		t, ok := arithmeticType(expr.Op, x, y)
+		if !ok {
+			c.reportf(expr, "operator %s can not be applied to %s %s and %s %s",
+				expr.Op, article(x.String()), describeExpr(expr.X), article(y.String()), describeExpr(expr.Y))
+		}
+		return t
+	}
+
+	c.compare(expr.Op, expr, expr.X, expr.Y)
+	return TypeBool
+}
+
+// compare reports comparisons between values of incompatible types, and
+// ordering comparisons of values that have no order.
+func (c *typeChecker) compare(op string, expr, x, y Expr) {
+	xt, yt := c.infer(x), c.infer(y)
+
+	switch op {
+	case "<", "<=", ">", ">=", "BETWEEN":
+		for _, side := range []struct {
+			expr Expr
+			t    Type
+		}{{x, xt}, {y, yt}} {
+			if side.t == TypeBool || side.t == TypeList || side.t == TypeMap {
+				c.reportf(expr, "%s can not be used with %s %s", op, article(side.t.String()), describeExpr(side.expr))
+				return
+			}
+		}
+	}
+
+	if comparableTypes(xt, yt, y) || comparableTypes(yt, xt, x) {
+		return
+	}
+
+	// Describe the comparison from the point of view of the field
+	if _, ok := x.(*FieldRef); !ok {
+		if _, ok := y.(*FieldRef); ok {
+			x, y, xt, yt = y, x, yt, xt
+		}
+	}
+	message := fmt.Sprintf("type mismatch: %s is %s but is compared with %s %s",
+		describeExpr(x), article(xt.String()), article(yt.String()), describeExpr(y))
+	if literal, ok := y.(*Literal); ok && literal.Kind == StringLiteral {
+		switch xt {
+		case TypeTimestamp, TypeDuration, TypeQuantity:
+			message += fmt.Sprintf(" that is not a valid %s", xt)
+		}
+	}
+	c.reportf(expr, "%s", message)
+}
+
+// comparableTypes reports whether a value of type a can be compared with the
+// value of other, of type b. String literals compare with timestamps,
+// durations and quantities when they hold a valid value of that type.
+func comparableTypes(a, b Type, other Expr) bool {
+	switch {
+	case a == TypeUnknown, b == TypeUnknown, a == TypeNull, b == TypeNull:
+		return true
+	case a == b:
+		return true
+	case a.isNumeric() && b.isNumeric():
+		return true
+	}
+
+	literal, ok := other.(*Literal)
+	if !ok || literal.Kind != StringLiteral {
+		return false
+	}
+	var err error
+	switch a {
+	case TypeTimestamp:
+		_, err = parseTimestamp(literal.Value)
+	case TypeDuration:
+		_, err = parseDuration(literal.Value)
+	case TypeQuantity:
+		_, err = parseQuantity(literal.Value)
+	default:
+		return false
+	}
+	return err == nil
+}
+
+// arithmeticType returns the result type of an arithmetic operation, and
+// false if the operation is not defined for the operand types.
+func arithmeticType(op string, x, y Type) (Type, bool) {
+	for _, t := range []Type{x, y} {
+		if t == TypeBool || t == TypeList || t == TypeMap {
+			return TypeUnknown, false
+		}
+	}
+	switch {
+	case x == TypeNull || y == TypeNull:
+		return TypeNull, true
+	case x == TypeString || y == TypeString:
+		// Strings only concatenate
+		return TypeString, op == "+" && (x == y || x == TypeUnknown || y == TypeUnknown)
+	case x == TypeUnknown || y == TypeUnknown:
+		return TypeUnknown, true
+	}
+
+	switch x {
+	case TypeInt, TypeFloat:
+		switch {
+		case y == TypeInt && x == TypeInt && op != "/":
+			return TypeInt, true
+		case y == TypeInt || y == TypeFloat:
+			return TypeFloat, true
+		case (y == TypeQuantity || y == TypeDuration) && op == "*":
+			return y, true
+		}
+	case TypeQuantity, TypeDuration:
+		switch {
+		case y == x && (op == "+" || op == "-"):
+			return x, true
+		case y == x && op == "/":
+			return TypeFloat, true
+		case (y == TypeInt || y == TypeFloat) && (op == "*" || op == "/"):
+			return x, true
+		case x == TypeDuration && y == TypeTimestamp && op == "+":
+			return TypeTimestamp, true
+		}
+	case TypeTimestamp:
+		switch {
+		case y == TypeTimestamp && op == "-":
+			return TypeDuration, true
+		case y == TypeDuration && (op == "+" || op == "-"):
+			return TypeTimestamp, true
+		}
+	}
+	return TypeUnknown, false
+}
+
+// function describes the signature of a function usable in expressions.
+type function struct {
+	minArgs, maxArgs int
+	result           func(args []Type) (Type, error)
+}
+
+// functions lists the known functions by lower case name.
+var functions = map[string]function{
+	"count":  {0, 1, func([]Type) (Type, error) { return TypeInt, nil }},
+	"sum":    {1, 1, aggregateResult(false)},
+	"avg":    {1, 1, aggregateResult(true)},
+	"min":    {1, 1, scalarResult},
+	"max":    {1, 1, scalarResult},
+	"len":    {1, 1, lengthResult},
+	"length": {1, 1, lengthResult},
+	"lower":  {1, 1, stringResult},
+	"upper":  {1, 1, stringResult},
+	"trim":   {1, 1, stringResult},
+	"join": {1, 2, func(args []Type) (Type, error) {
+		if len(args) == 2 && args[1] != TypeString && args[1] != TypeUnknown {
+			return TypeString, fmt.Errorf("the separator must be a string, got %s", article(args[1].String()))
+		}
+		return TypeString, nil
+	}},
+	"now":     {0, 0, func([]Type) (Type, error) { return TypeTimestamp, nil }},
+	"owns":    {2, 2, relationResult},
+	"selects": {2, 2, relationResult},
+	"mounts":  {2, 2, relationResult},
+}
+
+// functionNames returns the names of the known functions, sorted.
+func functionNames() []string {
+	names := make([]string, 0, len(functions))
+	for name := range functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// aggregateResult types sum and, with average set, avg.
+func aggregateResult(average bool) func(args []Type) (Type, error) {
+	return func(args []Type) (Type, error) {
+		switch t := args[0]; t {
+		case TypeInt:
+			if average {
+				return TypeFloat, nil
+			}
+			return TypeInt, nil
+		case TypeFloat, TypeQuantity, TypeDuration, TypeUnknown, TypeNull:
+			return t, nil
+		default:
+			return TypeUnknown, fmt.Errorf("expected a number, quantity or duration, got %s", article(t.String()))
+		}
+	}
+}
+
+// scalarResult types functions returning a value of their argument type.
+func scalarResult(args []Type) (Type, error) {
+	if args[0] == TypeList || args[0] == TypeMap {
+		return TypeUnknown, fmt.Errorf("expected a scalar value, got %s", article(args[0].String()))
+	}
+	return args[0], nil
+}
+
+// lengthResult types the length of a string, list or map.
+func lengthResult(args []Type) (Type, error) {
+	switch args[0] {
+	case TypeString, TypeList, TypeMap, TypeUnknown, TypeNull:
+		return TypeInt, nil
+	}
+	return TypeInt, fmt.Errorf("expected a string, list or map, got %s", article(args[0].String()))
+}
+
+// stringResult types string functions of a single string argument.
+func stringResult(args []Type) (Type, error) {
+	switch args[0] {
+	case TypeString, TypeUnknown, TypeNull:
+		return TypeString, nil
+	}
+	return TypeString, fmt.Errorf("expected a string, got %s", article(args[0].String()))
+}
+
+func (c *typeChecker) inferCall(expr *CallExpr) Type {
+	args := make([]Type, len(expr.Args))
+	for i, arg := range expr.Args {
+		args[i] = c.infer(arg)
+	}
+
+	fn, ok := functions[strings.ToLower(expr.Func)]
+	if !ok {
+		c.report(expr, suggestf(strings.ToLower(expr.Func), functionNames(), "unknown function '%s'", expr.Func))
+		return TypeUnknown
+	}
+	if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+		expected := fmt.Sprintf("%d", fn.minArgs)
+		if fn.maxArgs != fn.minArgs {
+			expected = fmt.Sprintf("%d to %d", fn.minArgs, fn.maxArgs)
+		}
+		c.reportf(expr, "function %s expects %s arguments, got %d", expr.Func, expected, len(args))
+		return TypeUnknown
+	}
+
+	t, err := fn.result(args)
+	if err != nil {
+		c.reportf(expr, "function %s: %v", expr.Func, err)
+	}
+	return t
+}
+
+// columnType returns the type of a result column. Unlike conditions,
+// columns of field paths with a [*] wildcard hold the list of item values.
+func (c *typeChecker) columnType(expr Expr) Type {
+	if ref, ok := expr.(*FieldRef); ok {
+		for _, segment := range ref.Path {
+			if segment.Array && segment.Index < 0 {
+				return TypeList
+			}
+		}
+	}
+	return c.infer(expr)
+}
+
+// describeExpr describes an expression in error messages.
+func describeExpr(expr Expr) string {
+	switch expr := expr.(type) {
+	case *FieldRef:
+		return "'" + expr.Name() + "'"
+	case *Literal:
+		return expr.String()
+	}
+	return "'" + expr.String() + "'"
+}
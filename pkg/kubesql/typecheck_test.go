@@ -0,0 +1,184 @@
+package kubesql
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// testPodSchema returns the Pod schema from testdata.
+func testPodSchema(t *testing.T) *Schema {
+	t.Helper()
+
+	schemas, err := LoadSchemas("testdata/schemas/pod-openapi-v3.json")
+	if err != nil {
+		t.Fatalf("error loading schemas: %v", err)
+	}
+	schema, _ := schemas.Schema(ResourceInfo{Name: "pods", Kind: "Pod"})
+	return schema
+}
+
+func TestInferType(t *testing.T) {
+	schema := testPodSchema(t)
+
+	testCases := []struct {
+		input    TSLQuery
+		expected Type
+	}{
+		{"'text'", TypeString},
+		{"42", TypeInt},
+		{"4.2", TypeFloat},
+		{"1e3", TypeFloat},
+		{"512Mi", TypeQuantity},
+		{"500m", TypeQuantity},
+		{"30s", TypeDuration},
+		{"100ms", TypeDuration},
+		{"true", TypeBool},
+		{"NULL", TypeNull},
+		{"name", TypeString},
+		{"created", TypeTimestamp},
+		{"spec.priority", TypeInt},
+		{"spec.containers", TypeList},
+		{"spec.containers[*].name", TypeString},
+		{"spec.containers[0].resources.limits.cpu", TypeQuantity},
+		{"labels", TypeMap},
+		{"spec.unknown", TypeUnknown},
+		{"*", TypeMap},
+		{"spec.priority + 1", TypeInt},
+		{"spec.priority / 2", TypeFloat},
+		{"spec.priority * 1.5", TypeFloat},
+		{"-spec.priority", TypeInt},
+		{"spec.containers[0].resources.limits.memory * 2", TypeQuantity},
+		{"512Mi + 1Gi", TypeQuantity},
+		{"1Gi / 512Mi", TypeFloat},
+		{"now() - created", TypeDuration},
+		{"created + 1h", TypeTimestamp},
+		{"name + '-suffix'", TypeString},
+		{"name = 'a' AND NOT spec.hostNetwork", TypeBool},
+		{"spec.priority BETWEEN 1 AND 10", TypeBool},
+		{"status.phase IN ('Running', 'Pending')", TypeBool},
+		{"deleted IS NULL", TypeBool},
+		{"count(*)", TypeInt},
+		{"sum(spec.priority)", TypeInt},
+		{"avg(spec.priority)", TypeFloat},
+		{"max(created)", TypeTimestamp},
+		{"len(spec.containers)", TypeInt},
+		{"upper(name)", TypeString},
+		{"owns(metadata, spec)", TypeBool},
+	}
+
+	for _, tc := range testCases {
+		expr, err := ParseExpr(tc.input)
+		if err != nil {
+			t.Fatalf("For input '%s', unexpected parse error: %v", tc.input, err)
+		}
+		result, err := InferType(expr, schema)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if result != tc.expected {
+			t.Errorf("For input '%s', expected %s, got %s", tc.input, tc.expected, result)
+		}
+	}
+}
+
+func TestInferTypeErrors(t *testing.T) {
+	schema := testPodSchema(t)
+
+	testCases := []struct {
+		input    TSLQuery
+		expected string
+	}{
+		{"name = 1", "type mismatch: 'name' is a string but is compared with an int 1"},
+		{"spec.containers[0].resources.limits.memory > 'big'", "type mismatch: 'spec.containers[0].resources.limits.memory' is a quantity but is compared with a string 'big' that is not a valid quantity"},
+		{"created < '2024-13-45'", "type mismatch: 'created' is a timestamp but is compared with a string '2024-13-45' that is not a valid timestamp"},
+		{"spec.containers[0].resources.limits.memory = name", "type mismatch: 'spec.containers[0].resources.limits.memory' is a quantity but is compared with a string 'name'"},
+		{"spec.hostNetwork + spec.hostNetwork", "operator + can not be applied to a bool 'spec.hostNetwork' and a bool 'spec.hostNetwork'"},
+		{"name + 1", "operator + can not be applied to a string 'name' and an int 1"},
+		{"sum(spec.hostNetwork)", "function sum: expected a number, quantity or duration, got a bool"},
+		{"labels > 'a'", "> can not be used with a map 'labels'"},
+		{"name AND spec.hostNetwork", "AND expects a bool, but 'name' is a string"},
+		{"NOT spec.priority", "NOT expects a bool, but 'spec.priority' is an int"},
+		{"spec.priority LIKE '1%'", "LIKE requires a string, but 'spec.priority' is an int"},
+		{"-name", "can not negate string 'name'"},
+		{"12xyz", "invalid number '12xyz'"},
+		{"foo(name)", "unknown function 'foo'"},
+		{"len(name, name)", "function len expects 1 arguments, got 2"},
+		{"selects(spec, name)", "function selects: expected an object, got a string"},
+	}
+
+	for _, tc := range testCases {
+		expr, err := ParseExpr(tc.input)
+		if err != nil {
+			t.Fatalf("For input '%s', unexpected parse error: %v", tc.input, err)
+		}
+		_, err = InferType(expr, schema)
+		if err == nil {
+			t.Errorf("For input '%s', expected error '%s'", tc.input, tc.expected)
+			continue
+		}
+		if err.Error() != tc.expected {
+			t.Errorf("For input '%s', expected error '%s', got '%s'", tc.input, tc.expected, err.Error())
+		}
+	}
+}
+
+func TestInferTypeWithoutSchema(t *testing.T) {
+	// Fields have unknown types, so no field comparison is an error
+	for _, input := range []TSLQuery{"name = 1", "a + b > 'x'", "labels LIKE '%'"} {
+		expr, err := ParseExpr(input)
+		if err != nil {
+			t.Fatalf("For input '%s', unexpected parse error: %v", input, err)
+		}
+		if _, err := InferType(expr, nil); err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", input, err)
+		}
+	}
+}
+
+func TestTypeText(t *testing.T) {
+	for i := range typeNames {
+		typ := Type(i)
+		data, err := json.Marshal(typ)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var decoded Type
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded != typ {
+			t.Errorf("For type %s, round trip through %s gave %s (%v)", typ, data, decoded, err)
+		}
+	}
+
+	var decoded Type
+	if err := json.Unmarshal([]byte(`"decimal"`), &decoded); err == nil {
+		t.Errorf("expected an error for an unknown type name")
+	}
+}
+
+func TestValidateColumns(t *testing.T) {
+	validator := newTestValidator(t)
+
+	query, err := NewParser("SELECT name, spec.priority AS prio, spec.containers[*].image AS images, created, count(*) FROM pods").Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if err := validator.Validate(query); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	expected := []Column{
+		{Name: "name", Type: TypeString},
+		{Name: "prio", Type: TypeInt},
+		{Name: "images", Type: TypeList},
+		{Name: "created", Type: TypeTimestamp},
+		{Name: "count(*)", Type: TypeInt},
+	}
+	if len(query.Columns) != len(expected) {
+		t.Fatalf("expected columns %v, got %v", expected, query.Columns)
+	}
+	for i := range expected {
+		if query.Columns[i] != expected[i] {
+			t.Errorf("column %d: expected %+v, got %+v", i, expected[i], query.Columns[i])
+		}
+	}
+}
@@ -14,32 +14,98 @@ const (
 	WhereKeyword   = "WHERE"
 	OrderByKeyword = "ORDER BY"
 	LimitKeyword   = "LIMIT"
+	JoinKeyword    = "JOIN"
+	OnKeyword      = "ON"
+	WithKeyword    = "WITH"
+
+	// Set operations combining the rows of queries
+	UnionKeyword     = "UNION"
+	IntersectKeyword = "INTERSECT"
+	ExceptKeyword    = "EXCEPT"
+	AllKeyword       = "ALL"
+
+	// Join types
+	InnerJoin = "INNER"
+	LeftJoin  = "LEFT"
+
+	// ExplainKeyword prefixes a statement to show its plan instead of running it
+	ExplainKeyword = "EXPLAIN"
 )
 
+// reservedKeywords lists the words that must be quoted to be used as identifiers.
+var reservedKeywords = []string{
+	"SELECT", "FROM", "WHERE", "ORDER", "BY", "LIMIT", "AS", "ASC", "DESC",
+	"AND", "OR", "NOT", "IN", "EXISTS", "LIKE", "ILIKE", "BETWEEN", "IS", "NULL", "TRUE", "FALSE", "EXPLAIN",
+	"JOIN", "INNER", "LEFT", "OUTER", "ON", "WITH",
+	"UNION", "INTERSECT", "EXCEPT",
+}
+
 type TSLQuery string // TSLQuery represents a raw TSL query string
 
 // SelectField represents a field in the SELECT clause with optional alias support.
 type SelectField struct {
-	Field TSLQuery // The field expression (e.g., "metadata.name", "status.phase")
-	Alias string   // Optional alias for the field (empty if no alias)
+	Field TSLQuery `json:"field" yaml:"field"`                     // The field expression (e.g., "metadata.name", "status.phase")
+	Alias string   `json:"alias,omitempty" yaml:"alias,omitempty"` // Optional alias for the field (empty if no alias)
 }
 
 // OrderByField represents a field in the ORDER BY clause with sort direction.
 type OrderByField struct {
-	Field     TSLQuery // Field expression to sort by
-	Direction string   // Sort direction: "ASC" or "DESC"
+	Field     TSLQuery `json:"field" yaml:"field"`         // Field expression to sort by
+	Direction string   `json:"direction" yaml:"direction"` // Sort direction: "ASC" or "DESC"
+}
+
+// Join represents a JOIN of the FROM clause. Fields of the joined objects
+// are qualified by the alias of the join, or by its resource name.
+type Join struct {
+	Type  string   `json:"type" yaml:"type"`                       // Join type: "INNER" or "LEFT"
+	From  string   `json:"from" yaml:"from"`                       // Joined resource, like Query.From
+	Alias string   `json:"alias,omitempty" yaml:"alias,omitempty"` // Name qualifying the fields of the joined objects (empty for the resource name)
+	On    TSLQuery `json:"on" yaml:"on"`                           // Join condition (stored as raw TSL string)
+}
+
+// CTE is a common table expression of a WITH clause: a named query whose
+// result rows the statement, and the common table expressions after it,
+// read like the objects of a resource.
+type CTE struct {
+	Name  string   `json:"name" yaml:"name"`   // Name used in FROM clauses and joins instead of a resource
+	Query TSLQuery `json:"query" yaml:"query"` // The named query (stored as raw query text)
+}
+
+// SetOperation combines the rows of a query with the rows of another query
+// selecting as many columns. Without All, duplicate rows are removed.
+type SetOperation struct {
+	Op    string   `json:"op" yaml:"op"`                       // Operation: "UNION", "INTERSECT" or "EXCEPT"
+	All   bool     `json:"all,omitempty" yaml:"all,omitempty"` // Keeps duplicate rows, as in UNION ALL
+	Query TSLQuery `json:"query" yaml:"query"`                 // The combined query (stored as raw query text)
+}
+
+// Comment represents a comment found in the query text.
+type Comment struct {
+	Text string   `json:"text" yaml:"text"` // The comment including its markers (e.g., "-- note", "/* note */")
+	Pos  Position `json:"pos" yaml:"pos"`   // Location of the comment in the query text
 }
 
 // Query represents a parsed KubeSQL query with all its components.
 type Query struct {
-	Select  []SelectField  // Fields to select from the resource
-	From    string         // Kubernetes resource type (e.g., "pods", "mynamespace/services")
-	Where   TSLQuery       // Filter conditions (stored as raw TSL string)
-	OrderBy []OrderByField // Sorting specifications
-	Limit   int            // Maximum number of results (-1 means no limit)
+	With      []CTE          `json:"with,omitempty" yaml:"with,omitempty"`           // Common table expressions, in order
+	Select    []SelectField  `json:"select,omitempty" yaml:"select,omitempty"`       // Fields to select from the resource
+	From      string         `json:"from" yaml:"from"`                               // Kubernetes resource type (e.g., "pods", "mynamespace/services")
+	FromAlias string         `json:"fromAlias,omitempty" yaml:"fromAlias,omitempty"` // Name qualifying the fields of the FROM resource in joins (empty for the resource name)
+	Joins     []Join         `json:"joins,omitempty" yaml:"joins,omitempty"`         // Resources joined to the FROM resource, in order
+	Where     TSLQuery       `json:"where,omitempty" yaml:"where,omitempty"`         // Filter conditions (stored as raw TSL string)
+	Compound  []SetOperation `json:"compound,omitempty" yaml:"compound,omitempty"`   // Queries whose rows are combined with the rows of the query, in order; ORDER BY and LIMIT apply to the combined rows
+	OrderBy   []OrderByField `json:"orderBy,omitempty" yaml:"orderBy,omitempty"`     // Sorting specifications
+	Limit     int            `json:"limit" yaml:"limit"`                             // Maximum number of results (-1 means no limit)
+	Pos       Position       `json:"pos,omitzero" yaml:"pos,omitempty"`              // Location of the statement in the query or script text
+	Source    string         `json:"-" yaml:"-"`                                     // Text of the statement as written at Pos, without its semicolon, used to locate validation problems; not serialized
+	Comments  []Comment      `json:"comments,omitempty" yaml:"comments,omitempty"`   // Comments belonging to the statement, in source order
+	Columns   []Column       `json:"columns,omitempty" yaml:"columns,omitempty"`     // Result columns with their types, set by Validator.Validate
+	Explain   bool           `json:"explain,omitempty" yaml:"explain,omitempty"`     // Set for EXPLAIN statements, whose plan is shown instead of their results
 }
 
 // Parser handles the parsing of KubeSQL queries into structured components.
 type Parser struct {
-	query string // The original SQL-like query string
+	query  string // The SQL-like query string, trimmed of surrounding whitespace
+	source string // The query string exactly as passed to NewParser
+	base   int    // Offset of query within source, used to report error positions
 }
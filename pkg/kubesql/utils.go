@@ -1,49 +1,42 @@
 package kubesql
 
 import (
-	"regexp"
 	"strings"
 )
 
-// normalizeQuery removes extra whitespace and normalizes the query string.
-func (p *Parser) normalizeQuery(query string) string {
-	// Replace multiple whitespace with single space
-	re := regexp.MustCompile(`\s+`)
-	return re.ReplaceAllString(strings.TrimSpace(query), " ")
+// quoteIdentifier returns name unchanged if it can be written as a bare
+// identifier, and as a backtick quoted identifier otherwise.
+func quoteIdentifier(name string) string {
+	if isBareIdentifier(name) {
+		return name
+	}
+	return quoteValue(name, '`')
 }
 
-// smartSplit splits a string by delimiter while respecting parentheses nesting.
-func (p *Parser) smartSplit(s string, delimiter rune) []string {
-	var result []string
-	var current strings.Builder
-	parenDepth := 0
+// quoteValue wraps value in the given quote character, escaping backslashes and
+// doubling embedded quotes so that the lexer reads back the same value.
+func quoteValue(value string, char byte) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, string(char), string(char)+string(char))
+	return string(char) + value + string(char)
+}
 
-	for _, char := range s {
-		switch char {
-		case '(':
-			parenDepth++
-			current.WriteRune(char)
-		case ')':
-			parenDepth--
-			current.WriteRune(char)
-		case delimiter:
-			if parenDepth == 0 {
-				// We're not inside parentheses, so this is a real separator
-				result = append(result, current.String())
-				current.Reset()
-			} else {
-				// We're inside parentheses, so treat as regular character
-				current.WriteRune(char)
-			}
-		default:
-			current.WriteRune(char)
-		}
+// isBareIdentifier reports whether name lexes as a single identifier that
+// is not a reserved keyword.
+func isBareIdentifier(name string) bool {
+	tokens, err := tokenize(name)
+	if err != nil || len(tokens) != 1 || tokens[0].kind != tokenIdent || tokens[0].text != name {
+		return false
 	}
+	return !isReservedKeyword(name)
+}
 
-	// Add the last part if there's any content
-	if current.Len() > 0 {
-		result = append(result, current.String())
+// isReservedKeyword reports whether word is a keyword, ignoring case.
+func isReservedKeyword(word string) bool {
+	for _, keyword := range reservedKeywords {
+		if strings.EqualFold(word, keyword) {
+			return true
+		}
 	}
-
-	return result
+	return false
 }
@@ -4,58 +4,26 @@ import (
 	"testing"
 )
 
-func TestSmartSplit(t *testing.T) {
-	parser := NewParser("")
-
-	testCases := []struct {
-		input    string
-		expected []string
-	}{
-		{"a, b, c", []string{"a", " b", " c"}},
-		{"func(a, b), c", []string{"func(a, b)", " c"}},
-		{"nested(func(a, b), c), d", []string{"nested(func(a, b), c)", " d"}},
-		{"simple", []string{"simple"}},
-		{"", []string{}}, // Empty string should return empty slice
-		{"a,b,c", []string{"a", "b", "c"}},
-		{"func(a,b,c),d", []string{"func(a,b,c)", "d"}},
-	}
-
-	for _, tc := range testCases {
-		result := parser.smartSplit(tc.input, ',')
-		if len(result) != len(tc.expected) {
-			t.Errorf("For input '%s', expected %d parts, got %d",
-				tc.input, len(tc.expected), len(result))
-			continue
-		}
-
-		for i, part := range result {
-			if part != tc.expected[i] {
-				t.Errorf("For input '%s', part %d: expected '%s', got '%s'",
-					tc.input, i, tc.expected[i], part)
-			}
-		}
-	}
-}
-
-func TestNormalizeQuery(t *testing.T) {
-	parser := NewParser("")
-
+func TestQuoteIdentifier(t *testing.T) {
 	testCases := []struct {
 		input    string
 		expected string
 	}{
-		{"  SELECT  name  FROM  pods  ", "SELECT name FROM pods"},
-		{"SELECT\n\tname\nFROM\tpods", "SELECT name FROM pods"},
-		{"select name from pods", "select name from pods"},
-		{"", ""},
-		{"   ", ""},
+		{"pod_name", "pod_name"},
+		{"my-app", "my-app"},
+		{"from", "`from`"},
+		{"Order", "`Order`"},
+		{"pod name", "`pod name`"},
+		{"a`b", "`a``b`"},
+		{"9lives", "`9lives`"},
+		{`a\b`, "`a\\\\b`"},
+		{"", "``"},
 	}
 
 	for _, tc := range testCases {
-		result := parser.normalizeQuery(tc.input)
+		result := quoteIdentifier(tc.input)
 		if result != tc.expected {
-			t.Errorf("For input '%s', expected '%s', got '%s'",
-				tc.input, tc.expected, result)
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, result)
 		}
 	}
 }
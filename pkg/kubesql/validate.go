@@ -0,0 +1,396 @@
+package kubesql
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ValidationError describes a semantic problem found in a parsed query,
+// such as a field that does not exist in the queried resource.
+type ValidationError struct {
+	Clause     string   // The clause the problem was found in, e.g. "WHERE"
+	Expr       string   // The field or expression at fault
+	Message    string   // Human readable description of the problem
+	Suggestion string   // Likely intended resource, field, function or keyword, empty if none
+	Pos        Position // Location of Expr in the query or script text, zero when unknown
+	End        Position // End of Expr in the query or script text, zero when unknown
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s clause: %s", e.Clause, e.Message)
+}
+
+// ValidationErrors lists every problem found while validating a query.
+type ValidationErrors []*ValidationError
+
+// Error implements the error interface.
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validator checks parsed queries against the known resource types and
+// their schemas.
+type Validator struct {
+	Resolver Resolver       // Resolves the FROM resource, required
+	Schemas  SchemaProvider // Provides resource schemas, optional
+}
+
+// NewValidator creates a validator. schemas may be nil, in which case only
+// the resource names and expression syntax are checked.
+func NewValidator(resolver Resolver, schemas SchemaProvider) *Validator {
+	return &Validator{Resolver: resolver, Schemas: schemas}
+}
+
+// validation collects the problems found in a single query.
+type validation struct {
+	validator  *Validator          // Validator of subqueries
+	outer      *validation         // Validation of the enclosing query of a subquery, nil for others
+	schemas    []*Schema           // Schemas of the tables the query sees, in the order of scope, nil if unknown
+	tables     []string            // Names qualifying fields, see rowPath
+	scope      []string            // Names of the tables the query sees, see visibleNames
+	aliases    map[string]Type     // Types of the SELECT aliases, usable in ORDER BY
+	subqueries map[*Statement]Type // Types of the fields selected by the IN subqueries of WHERE
+	with       []cteSchema         // Common table expressions the query sees, in order
+	errors     ValidationErrors    // Problems found so far
+	seen       map[string]struct{} // Reported problems, to avoid duplicates
+	source     *querySource        // Clauses of the query in its source text, nil if unknown
+}
+
+// Validate checks that the query resources exist, that the fields used in
+// the SELECT, ON, WHERE and ORDER BY clauses exist in the schema of their
+// resource, and that every expression is well typed: values are compared
+// with values of a compatible type, ON and WHERE are conditions and ORDER BY
+// keys are sortable.
+// It returns nil, or ValidationErrors listing every problem found.
+//
+// Subqueries of the WHERE clause are checked in turn, seeing the tables of
+// the queries using them, and IN compares values with the field its
+// subquery selects.
+//
+// Common table expressions are checked first, in order. The tables reading
+// them see the fields they select, typed like their result columns.
+//
+// Queries combined by UNION, INTERSECT and EXCEPT are checked in turn, and
+// must select as many columns as the first one, of compatible types. ORDER
+// BY keys must then name columns of the combined rows.
+//
+// Validate also records the result columns and their inferred types in
+// q.Columns, even when problems are found. Problems are located in the
+// query or script text when the query has its source text, see
+// Query.Source.
+func (v *Validator) Validate(q *Query) error {
+	check := &validation{validator: v, seen: make(map[string]struct{}), source: newQuerySource(q)}
+	for i, cte := range q.With {
+		check.with = append(check.with, cteSchema{name: cte.Name, schema: v.validateCTE(check, cte, check.source.cte(i))})
+	}
+	if len(q.Compound) > 0 {
+		q.Columns = v.validateCompound(check, q)
+	} else {
+		q.Columns = v.validate(check, q)
+	}
+	if len(check.errors) == 0 {
+		return nil
+	}
+	return check.errors
+}
+
+// validate checks a query and returns its result columns.
+func (v *Validator) validate(check *validation, q *Query) []Column {
+	check.aliases = make(map[string]Type)
+
+	// FROM: the resources must be known, namespaces only apply to namespaced resources
+	tables, err := queryTables(q)
+	if err != nil {
+		// Join conditions that do not parse are problems of the ON clause
+		unparsed := false
+		for _, join := range q.Joins {
+			unparsed = check.parse(OnKeyword, join.On) == nil || unparsed
+		}
+		if !unparsed {
+			check.report(FromKeyword, q.From, "%v", err)
+		}
+		tables = nil
+		if ref, err := ParseResourceRef(q.From); err == nil {
+			// Check the fields of the FROM resource alone
+			tables = []table{{name: ref.Resource, ref: ref}}
+		}
+	}
+	bindTables(tables, check.withNames())
+	var outer []string
+	if check.outer != nil {
+		outer = check.outer.scope
+	}
+	check.tables, check.scope = scopeNames(tables, outer), visibleNames(tables, outer)
+	check.schemas = make([]*Schema, len(check.scope))
+	for i, name := range check.scope {
+		switch {
+		case i < len(tables) && tables[i].cte != "":
+			check.schemas[i] = check.withSchema(tables[i].cte)
+		case i < len(tables):
+			check.schemas[i] = v.tableSchema(check, tables[i])
+		default:
+			check.schemas[i] = check.outer.schemas[slices.Index(outer, name)]
+		}
+	}
+	for _, t := range tables[min(len(tables), 1):] {
+		if expr := check.parse(OnKeyword, t.join.On); expr != nil {
+			if t := check.checkExpr(OnKeyword, expr); t != TypeBool && t != TypeUnknown && t != TypeNull {
+				check.report(OnKeyword, expr.String(), "condition must be a bool, but %s is %s", describeExpr(expr), article(t.String()))
+			}
+		}
+	}
+
+	columns := make([]Column, 0, len(q.Select))
+	for _, field := range q.Select {
+		column := Column{Name: field.Alias, Type: TypeUnknown}
+		if expr := check.parse(SelectKeyword, field.Field); expr != nil {
+			check.checkExpr(SelectKeyword, expr)
+			column.Type = check.checker(SelectKeyword).columnType(expr)
+			if column.Name == "" {
+				column.Name = expr.String()
+			}
+		} else if column.Name == "" {
+			column.Name = string(field.Field)
+		}
+		if field.Alias != "" {
+			check.aliases[field.Alias] = column.Type
+		}
+		columns = append(columns, column)
+	}
+
+	if q.Where != "" {
+		if expr := check.parse(WhereKeyword, q.Where); expr != nil {
+			if t := check.checkExpr(WhereKeyword, expr); t != TypeBool && t != TypeUnknown && t != TypeNull {
+				check.report(WhereKeyword, expr.String(), "condition must be a bool, but %s is %s", describeExpr(expr), article(t.String()))
+			}
+		}
+	}
+
+	for _, field := range q.OrderBy {
+		expr := check.parse(OrderByKeyword, field.Field)
+		if expr == nil {
+			continue
+		}
+
+		// ORDER BY may refer to a SELECT alias
+		t, isAlias := TypeUnknown, false
+		if ref, ok := expr.(*FieldRef); ok && len(ref.Path) == 1 {
+			t, isAlias = check.aliases[ref.Path[0].Name]
+		}
+		if !isAlias {
+			check.checkExpr(OrderByKeyword, expr)
+			t = check.checker(OrderByKeyword).columnType(expr)
+		}
+
+		if t == TypeList || t == TypeMap {
+			check.report(OrderByKeyword, expr.String(), "can not order by %s, it is %s", describeExpr(expr), article(t.String()))
+		}
+	}
+	return columns
+}
+
+// subquery checks a subquery of the WHERE clause, and returns the type of
+// the field it selects.
+func (c *validation) subquery(s *Statement) Type {
+	inner := &validation{validator: c.validator, outer: c, seen: c.seen, with: c.with, source: c.source.subquery(len(c.subqueries))}
+	columns := c.validator.validate(inner, s.Query())
+	c.errors = append(c.errors, inner.errors...)
+	if len(columns) == 0 {
+		return TypeUnknown
+	}
+	return columns[0].Type
+}
+
+// tableSchema checks the resource of a table, and returns its schema, nil
+// when unknown.
+func (v *Validator) tableSchema(check *validation, t table) *Schema {
+	resource, err := v.Resolver.Resolve(t.ref.Resource)
+	if err != nil {
+		if suggestionOf(err) == "" {
+			var names []string
+			for _, resource := range v.Resolver.Resources() {
+				names = append(names, resource.Names()...)
+			}
+			err = suggestf(t.ref.Resource, names, "%v", err)
+		}
+		check.reportError(FromKeyword, t.ref.Resource, err)
+		return nil
+	}
+	if t.ref.Namespace != "" && !resource.Namespaced {
+		check.report(FromKeyword, t.ref.String(), "resource '%s' is not namespaced", resource.Name)
+	}
+	if v.Schemas == nil {
+		return nil
+	}
+	schema, err := v.Schemas.Schema(resource)
+	if err != nil {
+		check.report(FromKeyword, t.ref.String(), "error loading schema of '%s': %v", resource.Name, err)
+	}
+	return schema
+}
+
+// report records a problem, ignoring repeated reports of the same problem.
+func (c *validation) report(clause, expr, format string, args ...any) {
+	c.reportError(clause, expr, fmt.Errorf(format, args...))
+}
+
+// reportError records a problem described by err, keeping its suggestion.
+func (c *validation) reportError(clause, expr string, err error) {
+	validationErr := &ValidationError{Clause: clause, Expr: expr, Message: err.Error(), Suggestion: suggestionOf(err)}
+	validationErr.Pos, validationErr.End = c.source.locate(clause, expr)
+	key := validationErr.Error()
+	if _, ok := c.seen[key]; ok {
+		return
+	}
+	c.seen[key] = struct{}{}
+	c.errors = append(c.errors, validationErr)
+}
+
+// parse parses an expression of a clause, reporting syntax errors at
+// their position in the query or script text when it is known.
+func (c *validation) parse(clause string, text TSLQuery) Expr {
+	expr, err := ParseExpr(text)
+	if err != nil {
+		var parseErr *ParseError
+		if tokens := c.source.find(clause, string(text)); tokens != nil && errors.As(err, &parseErr) {
+			located := *parseErr
+			located.Pos = c.source.within(tokens, string(text), parseErr.Pos.Offset)
+			err = &located
+		}
+		c.reportError(clause, string(text), fmt.Errorf("invalid expression '%s': %w", text, err))
+		return nil
+	}
+	return expr
+}
+
+// checkExpr checks the field references, subqueries and types of an
+// expression, and returns its type.
+func (c *validation) checkExpr(clause string, expr Expr) Type {
+	Inspect(expr, func(node Node) bool {
+		switch node := node.(type) {
+		case *FieldRef:
+			if _, err := lookupTableField(c.schemas, c.tables, node.Path); err != nil {
+				c.reportError(clause, node.String(), err)
+			}
+		case *InExpr:
+			if node.Subquery != nil {
+				if err := checkInSubquery(node.Subquery); err != nil {
+					c.reportError(clause, node.String(), err)
+				}
+			}
+		case *Statement:
+			if clause != WhereKeyword {
+				c.report(clause, node.String(), "subqueries can only be used in the WHERE clause")
+				return false
+			}
+			if c.subqueries == nil {
+				c.subqueries = make(map[*Statement]Type)
+			}
+			c.subqueries[node] = c.subquery(node)
+			return false
+		}
+		return true
+	})
+
+	return c.checker(clause).infer(expr)
+}
+
+// checker returns a type checker reporting type errors in clause.
+func (c *validation) checker(clause string) *typeChecker {
+	return &typeChecker{schemas: c.schemas, tables: c.tables, subqueries: c.subqueries, report: func(expr Expr, err error) {
+		c.reportError(clause, expr.String(), err)
+	}}
+}
+
+// article prefixes a type name with "a" or "an".
+func article(typeName string) string {
+	if typeName != "" && strings.ContainsRune("aeiou", rune(typeName[0])) {
+		return "an " + typeName
+	}
+	return "a " + typeName
+}
+
+// lookupTableField looks a field up in the schema of the table qualifying
+// it, or of the FROM resource when it is not qualified. Suggestions keep
+// the table name.
+func lookupTableField(schemas []*Schema, names []string, path []Segment) (*Schema, error) {
+	i := tableOf(path, names)
+	if i < 0 {
+		var schema *Schema
+		if len(schemas) > 0 {
+			schema = schemas[0]
+		}
+		return lookupField(schema, path)
+	}
+	schema, err := lookupField(schemas[i], path[1:])
+	var suggestErr *suggestError
+	if errors.As(err, &suggestErr) {
+		err = &suggestError{message: suggestErr.message, suggestion: (&FieldRef{Path: path[:1]}).String() + "." + suggestErr.suggestion}
+	}
+	return schema, err
+}
+
+// lookupField walks a field path through a schema and returns the schema of
+// the field. A nil schema means that the field type is unknown. Short field
+// names such as name and namespace are expanded first.
+func lookupField(schema *Schema, path []Segment) (*Schema, error) {
+	path = resolveAlias(path)
+	if len(path) > 0 && !path[0].Array && path[0].Name == ClusterField {
+		switch {
+		case len(path) > 1 && path[1].Array:
+			return nil, fmt.Errorf("'%s' is a string, not an array", ClusterField)
+		case len(path) > 1:
+			return nil, fmt.Errorf("'%s' is a string and has no field '%s'", ClusterField, path[1].Name)
+		}
+		return &Schema{Type: SchemaString}, nil
+	}
+	current := schema
+
+	for i, segment := range path {
+		if current == nil {
+			return nil, nil
+		}
+		prefix := (&FieldRef{Path: path[:i]}).Name()
+
+		if segment.Array {
+			if current.Type != SchemaArray && current.Type != "" {
+				return nil, fmt.Errorf("'%s' is %s, not an array", prefix, article(current.Type))
+			}
+			current = current.Items
+			continue
+		}
+
+		if current.Type == SchemaArray {
+			return nil, fmt.Errorf("'%s' is an array, use an index such as %s[0] or %s[*]", prefix, prefix, prefix)
+		}
+		if current.Type != "" && current.Type != SchemaObject && !current.PreserveUnknown {
+			return nil, fmt.Errorf("'%s' is %s and has no field '%s'", prefix, article(current.Type), segment.Name)
+		}
+
+		next, ok := current.Field(segment.Name)
+		if !ok {
+			message := fmt.Sprintf("unknown field '%s'", (&FieldRef{Path: path[:i+1]}).Name())
+			names := current.FieldNames()
+			if i == 0 {
+				names = append(append(names, fieldAliasNames()...), ClusterField)
+			}
+			if name, ok := closestMatch(segment.Name, names); ok {
+				// Suggest the whole corrected path, e.g. status.phase for sttus.phase
+				suggestion := append(append([]Segment{}, path[:i]...), Segment{Name: name})
+				suggestion = append(suggestion, path[i+1:]...)
+				return nil, &suggestError{message: message, suggestion: (&FieldRef{Path: suggestion}).String()}
+			}
+			return nil, fmt.Errorf("%s", message)
+		}
+		current = next
+	}
+
+	return current, nil
+}
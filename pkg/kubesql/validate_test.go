@@ -0,0 +1,314 @@
+package kubesql
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newTestValidator returns a validator using the schemas in testdata.
+func newTestValidator(t *testing.T) *Validator {
+	t.Helper()
+
+	schemas, err := LoadSchemas("testdata/schemas")
+	if err != nil {
+		t.Fatalf("error loading schemas: %v", err)
+	}
+	resolver := DefaultResolver()
+	resolver.Add(schemas.Resources()...)
+	return NewValidator(resolver, schemas)
+}
+
+func TestValidateValid(t *testing.T) {
+	validator := newTestValidator(t)
+
+	queries := []string{
+		"SELECT name, namespace, status.phase FROM pods WHERE status.phase = 'Running'",
+		"SELECT * FROM default/po WHERE labels.app = 'web' ORDER BY created DESC",
+		"SELECT spec.containers[0].image AS image FROM pods ORDER BY image",
+		"FROM pods WHERE spec.containers[*].resources.limits.memory > 512Mi",
+		"FROM pods WHERE spec.containers[0].resources.limits.cpu = '500m'",
+		"FROM pods WHERE spec.priority BETWEEN 1 AND 10.5 AND spec.hostNetwork = false",
+		"FROM pods WHERE name LIKE 'web-%' AND status.podIP IS NOT NULL",
+		"FROM pods WHERE metadata.ownerReferences[0].controller = true",
+		"FROM pods WHERE spec.nodeName = status.podIP",
+		"FROM pods WHERE status.phase IN ('Running', 'Pending') AND deleted IS NULL",
+		"FROM pods WHERE created > '2024-01-01T00:00:00Z'",
+		"SELECT cluster, name FROM prod-*:pods WHERE cluster LIKE 'prod-eu%' ORDER BY cluster",
+		"FROM widgets WHERE spec.size > 3 AND spec.memory = '1Gi' AND spec.config.anything = 1",
+		"FROM gadgets WHERE spec.model ~= '^x'",
+		// No schema for nodes, only the resource name is checked
+		"SELECT status.whatever FROM nodes WHERE spec.anything = 1",
+		"SELECT p.name, w.spec.size FROM pods p JOIN widgets w ON w.spec.color = p.labels.color WHERE name LIKE 'web%' ORDER BY w.spec.size",
+		"SELECT p.status.phase FROM pods AS p WHERE p.spec.priority > 1",
+		"SELECT p.name FROM widgets w JOIN pods p ON selects(w, p) AND owns(w, p)",
+		"FROM pods WHERE spec.priority IN (SELECT spec.size FROM widgets WHERE spec.color = 'red')",
+		"FROM pods p WHERE EXISTS (SELECT name FROM widgets WHERE spec.size = p.spec.priority AND name IN (SELECT name FROM pods))",
+		"WITH big AS (SELECT name, spec.size, spec.size * 2 AS double FROM widgets WHERE spec.size > 10) SELECT name, spec.size + double FROM big WHERE metadata.name LIKE 'w%' ORDER BY double",
+		"WITH w AS (FROM widgets), p AS (SELECT p.name, w.spec.color FROM pods p JOIN w ON w.name = p.name) SELECT name, spec.color FROM p WHERE spec.color IN (SELECT spec.color FROM w)",
+		"WITH total AS (SELECT count(*) AS n FROM pods) SELECT n + 1 FROM total",
+		// The rows of SELECT * keep the schema of the resource
+		"WITH widgets AS (SELECT * FROM widgets) SELECT spec.size FROM widgets",
+		"SELECT name, spec.priority AS size FROM pods UNION ALL SELECT name, spec.size FROM widgets EXCEPT SELECT name, NULL FROM nodes ORDER BY size DESC, name",
+		"WITH w AS (SELECT name FROM widgets) SELECT name FROM pods INTERSECT SELECT name FROM w ORDER BY name",
+	}
+
+	for _, query := range queries {
+		parsed, err := NewParser(query).Parse()
+		if err != nil {
+			t.Fatalf("For query '%s', unexpected parse error: %v", query, err)
+		}
+		if err := validator.Validate(parsed); err != nil {
+			t.Errorf("For query '%s', unexpected validation error: %v", query, err)
+		}
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	validator := newTestValidator(t)
+
+	testCases := []struct {
+		query    string
+		expected []string
+	}{
+		{"FROM podz", []string{"FROM clause: unknown resource 'podz', did you mean 'pods'?"}},
+		{"FROM kube-system/nodes", []string{"FROM clause: resource 'nodes' is not namespaced"}},
+		{
+			"SELECT name, spec.nodename FROM pods",
+			[]string{"SELECT clause: unknown field 'spec.nodename', did you mean 'spec.nodeName'?"},
+		},
+		{
+			"FROM pods WHERE status.phase = 1",
+			[]string{"WHERE clause: type mismatch: 'status.phase' is a string but is compared with an int 1"},
+		},
+		{
+			"FROM pods WHERE 'x' < spec.priority",
+			[]string{"WHERE clause: type mismatch: 'spec.priority' is an int but is compared with a string 'x'"},
+		},
+		{
+			"FROM pods WHERE spec.hostNetwork IN (true, 'yes')",
+			[]string{"WHERE clause: type mismatch: 'spec.hostNetwork' is a bool but is compared with a string 'yes'"},
+		},
+		{
+			"FROM pods WHERE spec.priority LIKE '1%'",
+			[]string{"WHERE clause: LIKE requires a string, but 'spec.priority' is an int"},
+		},
+		{
+			"FROM pods WHERE spec.nodeName = spec.priority",
+			[]string{"WHERE clause: type mismatch: 'spec.nodeName' is a string but is compared with an int 'spec.priority'"},
+		},
+		{
+			"FROM pods WHERE spec.containers.image = 'nginx'",
+			[]string{"WHERE clause: 'spec.containers' is an array, use an index such as spec.containers[0] or spec.containers[*]"},
+		},
+		{
+			"FROM pods WHERE name.first = 'a'",
+			[]string{"WHERE clause: 'metadata.name' is a string and has no field 'first'"},
+		},
+		{
+			"FROM prod:pods WHERE cluster.name = 'a' OR cluster = 1",
+			[]string{
+				"WHERE clause: 'cluster' is a string and has no field 'name'",
+				"WHERE clause: type mismatch: 'cluster' is a string but is compared with an int 1",
+			},
+		},
+		{
+			"FROM pods ORDER BY spec.containers, labels, status.phase, missing",
+			[]string{
+				"ORDER BY clause: can not order by 'spec.containers', it is a list",
+				"ORDER BY clause: can not order by 'labels', it is a map",
+				"ORDER BY clause: unknown field 'missing'",
+			},
+		},
+		{
+			"FROM pods WHERE name = ",
+			[]string{"WHERE clause: invalid expression 'name =': expected an expression at end of expression (at line 1, column 23)"},
+		},
+		{
+			"SELECT name FROM pods\nWHERE a = = b",
+			[]string{"WHERE clause: invalid expression 'a = = b': unexpected '=' (at line 2, column 11)"},
+		},
+		{
+			"FROM pods p JOIN pods q\n  ON p.name =  = q.name",
+			[]string{"ON clause: invalid expression 'p.name = = q.name': unexpected '=' (at line 2, column 16)"},
+		},
+		{
+			"FROM widgets WHERE spec.size = 'big' AND spec.colour = 'red'",
+			[]string{
+				"WHERE clause: unknown field 'spec.colour', did you mean 'spec.color'?",
+				"WHERE clause: type mismatch: 'spec.size' is an int but is compared with a string 'big'",
+			},
+		},
+
+		{
+			"SELECT p.spec.nodename FROM pods p LEFT JOIN widgets w ON w.spec.size = p.name JOIN nodez n ON n.name = p.spec.nodeName",
+			[]string{
+				"FROM clause: unknown resource 'nodez', did you mean 'nodes'?",
+				"ON clause: type mismatch: 'w.spec.size' is an int but is compared with a string 'p.name'",
+				"SELECT clause: unknown field 'spec.nodename', did you mean 'p.spec.nodeName'?",
+			},
+		},
+		{
+			"FROM pods p JOIN widgets w ON w.spec.size",
+			[]string{"ON clause: condition must be a bool, but 'w.spec.size' is an int"},
+		},
+		{
+			"FROM pods p JOIN nodes n ON s.name = n.name JOIN services s ON s.name = p.name",
+			[]string{"FROM clause: ON condition of 'n' uses 's', which is joined after it"},
+		},
+		{
+			"FROM pods p JOIN widgets w ON mounts(p, w.spec.size)",
+			[]string{"ON clause: function mounts: expected an object, got an int"},
+		},
+		{
+			"FROM pods p WHERE EXISTS (SELECT name FROM widgets WHERE spec.colour = p.spec.nodename)",
+			[]string{
+				"WHERE clause: unknown field 'spec.colour', did you mean 'spec.color'?",
+				"WHERE clause: unknown field 'spec.nodename', did you mean 'p.spec.nodeName'?",
+			},
+		},
+		{
+			"FROM pods WHERE name IN (SELECT name, namespace FROM widgets) AND spec.nodeName IN (SELECT spec.size FROM widgets)",
+			[]string{
+				"WHERE clause: the subquery of IN must select a single field",
+				"WHERE clause: type mismatch: 'spec.nodeName' is a string but the subquery selects an int",
+			},
+		},
+		{
+			"SELECT name IN (SELECT name FROM podz) FROM pods",
+			[]string{"SELECT clause: subqueries can only be used in the WHERE clause"},
+		},
+		{
+			"WITH big AS (SELECT name, spec.colour FROM widgets), bigger AS (FROM big) SELECT spec.size FROM bigger WHERE name = 1",
+			[]string{
+				"SELECT clause: in common table expression 'big': unknown field 'spec.colour', did you mean 'spec.color'?",
+				"SELECT clause: unknown field 'spec.size'",
+				"WHERE clause: type mismatch: 'name' is a string but is compared with an int 1",
+			},
+		},
+		{
+			"WITH total AS (SELECT count(*) AS n FROM podz) SELECT n FROM total WHERE n = 'x'",
+			[]string{
+				"FROM clause: in common table expression 'total': unknown resource 'podz', did you mean 'pods'?",
+				"WHERE clause: type mismatch: 'n' is an int but is compared with a string 'x'",
+			},
+		},
+		{
+			"SELECT name, spec.priority FROM pods UNION SELECT name FROM widgets",
+			[]string{"UNION clause: queries combined by UNION must select the same number of columns, the first query selects 2 and query 2 selects 1"},
+		},
+		{
+			"SELECT name FROM pods EXCEPT SELECT spec.size FROM widgets INTERSECT SELECT spec.colour FROM widgets",
+			[]string{
+				"EXCEPT clause: column 'name' is a string in the first query but an int in query 2",
+				"SELECT clause: unknown field 'spec.colour', did you mean 'spec.color'?",
+			},
+		},
+		{
+			"SELECT name FROM pods UNION SELECT name FROM widgets ORDER BY namespace, labels",
+			[]string{
+				"ORDER BY clause: 'namespace' must be a column of the combined rows",
+				"ORDER BY clause: 'labels' must be a column of the combined rows",
+			},
+		},
+		{
+			"SELECT name, labels FROM pods UNION SELECT name, labels FROM widgets ORDER BY labels",
+			[]string{"ORDER BY clause: can not order by 'labels', it is a map"},
+		},
+	}
+
+	for _, tc := range testCases {
+		parsed, err := NewParser(tc.query).Parse()
+		if err != nil {
+			// Some invalid expressions are only detected by the validator
+			t.Fatalf("For query '%s', unexpected parse error: %v", tc.query, err)
+		}
+
+		err = validator.Validate(parsed)
+		var validationErrs ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			t.Errorf("For query '%s', expected ValidationErrors, got %v", tc.query, err)
+			continue
+		}
+
+		var messages []string
+		for _, validationErr := range validationErrs {
+			messages = append(messages, validationErr.Error())
+		}
+		if strings.Join(messages, "\n") != strings.Join(tc.expected, "\n") {
+			t.Errorf("For query '%s', expected errors:\n%s\ngot:\n%s",
+				tc.query, strings.Join(tc.expected, "\n"), strings.Join(messages, "\n"))
+		}
+	}
+}
+
+func TestValidateErrorPositions(t *testing.T) {
+	validator := newTestValidator(t)
+
+	testCases := []struct {
+		script   string
+		expected string // Source text of the first problem
+		line     int
+		column   int
+	}{
+		{"SELECT name, spec.nodename FROM pods", "spec.nodename", 1, 14},
+		{"select name from podz", "podz", 1, 18},
+		{"FROM pods;\nSELECT name FROM pods\nWHERE status.phase   =\n  1", "status.phase   =\n  1", 3, 7},
+		{"SELECT name -- spec.nodename\nFROM pods ORDER BY spec.nodename", "spec.nodename", 2, 20},
+		{"FROM pods p JOIN nodes n ON p.spec.nodename = n.name", "p.spec.nodename", 1, 29},
+		{"FROM pods WHERE name IN (SELECT name FROM pods WHERE spec.nodename = 'a')", "spec.nodename", 1, 54},
+		{"WITH p AS (FROM pods WHERE spec.nodename = 'a') FROM p", "spec.nodename", 1, 28},
+		{"SELECT name FROM pods UNION SELECT spec.nodename FROM pods", "spec.nodename", 1, 36},
+	}
+
+	for _, tc := range testCases {
+		queries, err := ParseScript(tc.script)
+		if err != nil {
+			t.Fatalf("For script '%s', unexpected parse error: %v", tc.script, err)
+		}
+
+		var validationErrs ValidationErrors
+		if err := validator.Validate(queries[len(queries)-1]); !errors.As(err, &validationErrs) {
+			t.Errorf("For script '%s', expected ValidationErrors, got %v", tc.script, err)
+			continue
+		}
+		first := validationErrs[0]
+		if text := tc.script[first.Pos.Offset:first.End.Offset]; text != tc.expected || first.Pos.Line != tc.line || first.Pos.Column != tc.column {
+			t.Errorf("For script '%s', expected '%s' at line %d, column %d, got '%s' at line %d, column %d",
+				tc.script, tc.expected, tc.line, tc.column, text, first.Pos.Line, first.Pos.Column)
+		}
+	}
+
+	// Queries without their source text have no positions
+	parsed, err := NewParser("SELECT spec.nodename FROM pods").Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	parsed.Source = ""
+	var validationErrs ValidationErrors
+	if err := validator.Validate(parsed); !errors.As(err, &validationErrs) || validationErrs[0].Pos != (Position{}) {
+		t.Errorf("Expected an unlocated problem, got %v", err)
+	}
+
+	// Nor do queries changed after they were parsed
+	parsed, err = NewParser("SELECT name FROM pods WHERE spec.nodename = 'a'").Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	parsed.Select = []SelectField{{Field: "spec.nodename"}}
+	if err := validator.Validate(parsed); !errors.As(err, &validationErrs) || validationErrs[0].Pos != (Position{}) {
+		t.Errorf("Expected an unlocated problem, got %v", err)
+	}
+}
+
+func TestValidateWithoutSchemas(t *testing.T) {
+	validator := NewValidator(DefaultResolver(), nil)
+
+	parsed, err := NewParser("SELECT anything FROM pods WHERE spec.x = 'y'").Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if err := validator.Validate(parsed); err != nil {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package kubesql
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quantitySuffixes maps Kubernetes quantity suffixes to their multipliers.
+var quantitySuffixes = map[string]float64{
+	"n":  1e-9,
+	"u":  1e-6,
+	"m":  1e-3,
+	"":   1,
+	"k":  1e3,
+	"M":  1e6,
+	"G":  1e9,
+	"T":  1e12,
+	"P":  1e15,
+	"E":  1e18,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"Pi": 1 << 50,
+	"Ei": 1 << 60,
+}
+
+// timestampLayouts are the accepted timestamp formats, most specific first.
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// splitNumber splits a number into its numeric part and unit suffix, e.g.
+// "512Mi" into "512" and "Mi".
+func splitNumber(s string) (string, string) {
+	end := len(s)
+	for end > 0 && (s[end-1] >= 'a' && s[end-1] <= 'z' || s[end-1] >= 'A' && s[end-1] <= 'Z') {
+		end--
+	}
+	return s[:end], s[end:]
+}
+
+// parseQuantity parses a Kubernetes resource quantity, such as "500m",
+// "1.5Gi" or "2", into its value in base units.
+func parseQuantity(s string) (float64, error) {
+	number, suffix := splitNumber(strings.TrimSpace(s))
+	multiplier, ok := quantitySuffixes[suffix]
+	if !ok || number == "" {
+		return 0, fmt.Errorf("invalid quantity '%s'", s)
+	}
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsInf(value, 0) || math.IsNaN(value) {
+		return 0, fmt.Errorf("invalid quantity '%s'", s)
+	}
+	return value * multiplier, nil
+}
+
+// parseDuration parses a duration such as "30s", "1h30m" or "100ms".
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+	return d, nil
+}
+
+// parseTimestamp parses an RFC 3339 timestamp or a date, e.g.
+// "2024-01-02T15:04:05Z" or "2024-01-02". Timestamps without a zone are UTC.
+func parseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp '%s'", s)
+}
+
+// numberType returns the type of a number literal: an int, a float, a
+// quantity when it has a quantity suffix (512Mi, 500m) or a duration when it
+// has a time unit (30s, 100ms). Ambiguous suffixes such as "m" are read as
+// quantities, like Kubernetes does.
+func numberType(s string) (Type, error) {
+	number, suffix := splitNumber(s)
+	switch {
+	case suffix == "":
+		if _, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return TypeInt, nil
+		}
+		if _, err := strconv.ParseFloat(number, 64); err == nil {
+			return TypeFloat, nil
+		}
+	case quantitySuffixes[suffix] != 0:
+		if _, err := parseQuantity(s); err == nil {
+			return TypeQuantity, nil
+		}
+	default:
+		if _, err := parseDuration(s); err == nil {
+			return TypeDuration, nil
+		}
+	}
+	return TypeUnknown, fmt.Errorf("invalid number '%s'", s)
+}
@@ -0,0 +1,95 @@
+package kubesql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuantity(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected float64
+	}{
+		{"2", 2},
+		{"500m", 0.5},
+		{"1.5k", 1500},
+		{"512Mi", 512 * 1024 * 1024},
+		{"1Gi", 1 << 30},
+		{"1e3", 1000},
+		{"-1M", -1e6},
+	}
+
+	for _, tc := range testCases {
+		result, err := parseQuantity(tc.input)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if result != tc.expected {
+			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
+		}
+	}
+
+	for _, input := range []string{"", "Mi", "1Xi", "big", "1.2.3"} {
+		if _, err := parseQuantity(input); err == nil {
+			t.Errorf("For input '%s', expected an error", input)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"2024-01-02T03:04:05Z", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"2024-01-02T03:04:05.5+02:00", time.Date(2024, 1, 2, 1, 4, 5, 5e8, time.UTC)},
+		{"2024-01-02 03:04:05", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"2024-01-02", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		result, err := parseTimestamp(tc.input)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if !result.Equal(tc.expected) {
+			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
+		}
+	}
+
+	if _, err := parseTimestamp("2024-13-01"); err == nil {
+		t.Errorf("expected an error for an invalid month")
+	}
+}
+
+func TestNumberType(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected Type
+	}{
+		{"1", TypeInt},
+		{"1.5", TypeFloat},
+		{"99999999999999999999", TypeFloat},
+		{"2Gi", TypeQuantity},
+		{"5m", TypeQuantity},
+		{"10s", TypeDuration},
+		{"3h", TypeDuration},
+	}
+
+	for _, tc := range testCases {
+		result, err := numberType(tc.input)
+		if err != nil {
+			t.Errorf("For input '%s', unexpected error: %v", tc.input, err)
+			continue
+		}
+		if result != tc.expected {
+			t.Errorf("For input '%s', expected %s, got %s", tc.input, tc.expected, result)
+		}
+	}
+
+	if _, err := numberType("3days"); err == nil {
+		t.Errorf("expected an error for an unknown unit")
+	}
+}
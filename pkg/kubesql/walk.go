@@ -0,0 +1,272 @@
+package kubesql
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Visitor visits the nodes of a syntax tree with Walk. Visit is called for
+// each node; when the returned visitor w is not nil, the children of the
+// node are walked with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth first order: it calls v.Visit(node),
+// then walks the children of node with the returned visitor, if any. The
+// children of a *Statement are its common table expressions, clauses and
+// combined queries, in the order they are written, and subqueries are the last child of their IN
+// or EXISTS expression.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Statement:
+		for _, cte := range n.With {
+			Walk(v, cte)
+		}
+		if n.Select != nil {
+			Walk(v, n.Select)
+		}
+		if n.From != nil {
+			Walk(v, n.From)
+		}
+		for _, join := range n.Joins {
+			Walk(v, join)
+		}
+		if n.Where != nil {
+			Walk(v, n.Where)
+		}
+		for _, c := range n.Compound {
+			Walk(v, c)
+		}
+		if n.OrderBy != nil {
+			Walk(v, n.OrderBy)
+		}
+	case *CommonTable:
+		Walk(v, n.Query)
+	case *CompoundQuery:
+		Walk(v, n.Query)
+	case *SelectClause:
+		for _, field := range n.Fields {
+			Walk(v, field)
+		}
+	case *SelectItem:
+		Walk(v, n.Expr)
+	case *FromClause:
+		// Leaf
+	case *JoinClause:
+		Walk(v, n.From)
+		Walk(v, n.On)
+	case *WhereClause:
+		Walk(v, n.Cond)
+	case *OrderByClause:
+		for _, key := range n.Keys {
+			Walk(v, key)
+		}
+	case *OrderByItem:
+		Walk(v, n.Expr)
+
+	case *FieldRef, *Literal, *StarExpr, *placeholderExpr:
+		// Leaves
+	case *ParenExpr:
+		Walk(v, n.X)
+	case *UnaryExpr:
+		Walk(v, n.X)
+	case *BinaryExpr:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+	case *InExpr:
+		Walk(v, n.X)
+		for _, item := range n.List {
+			Walk(v, item)
+		}
+		if n.Subquery != nil {
+			Walk(v, n.Subquery)
+		}
+	case *ExistsExpr:
+		Walk(v, n.Subquery)
+	case *BetweenExpr:
+		Walk(v, n.X)
+		Walk(v, n.Low)
+		Walk(v, n.High)
+	case *IsNullExpr:
+		Walk(v, n.X)
+	case *CallExpr:
+		for _, arg := range n.Args {
+			Walk(v, arg)
+		}
+	default:
+		panic(fmt.Sprintf("kubesql.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// inspector adapts a function to the Visitor interface.
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth first order: it calls f(node),
+// and while f returns true, Inspect is called for each child of node,
+// followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// Rewrite returns a copy of a syntax tree in which every node is replaced
+// by the result of f, children first. f receives a shallow copy of each node,
+// with its children already rewritten, which it may modify and return, or
+// it may return another node of the same kind: a clause for a clause, and
+// an Expr for an Expr. The tree given to Rewrite is left unchanged.
+func Rewrite(node Node, f func(Node) Node) Node {
+	return rewriter{f: f}.rewrite(node)
+}
+
+// rewriteScope rewrites an expression like Rewrite, leaving its subqueries,
+// which have a scope of their own, unchanged.
+func rewriteScope(expr Expr, f func(Node) Node) Expr {
+	return rewriteNode[Expr](expr, rewriter{f: f, scope: true})
+}
+
+// rewriter rewrites the nodes of a syntax tree with f.
+type rewriter struct {
+	f     func(Node) Node
+	scope bool // Whether subqueries are left unchanged
+}
+
+func (r rewriter) rewrite(node Node) Node {
+	switch n := node.(type) {
+	case *Statement:
+		c := *n
+		if n.With != nil {
+			c.With = make([]*CommonTable, len(n.With))
+			for i, cte := range n.With {
+				c.With[i] = rewriteNode[*CommonTable](cte, r)
+			}
+		}
+		if n.Select != nil {
+			c.Select = rewriteNode[*SelectClause](n.Select, r)
+		}
+		if n.From != nil {
+			c.From = rewriteNode[*FromClause](n.From, r)
+		}
+		if n.Joins != nil {
+			c.Joins = make([]*JoinClause, len(n.Joins))
+			for i, join := range n.Joins {
+				c.Joins[i] = rewriteNode[*JoinClause](join, r)
+			}
+		}
+		if n.Where != nil {
+			c.Where = rewriteNode[*WhereClause](n.Where, r)
+		}
+		if n.Compound != nil {
+			c.Compound = make([]*CompoundQuery, len(n.Compound))
+			for i, compound := range n.Compound {
+				c.Compound[i] = rewriteNode[*CompoundQuery](compound, r)
+			}
+		}
+		if n.OrderBy != nil {
+			c.OrderBy = rewriteNode[*OrderByClause](n.OrderBy, r)
+		}
+		node = &c
+	case *CommonTable:
+		node = &CommonTable{Name: n.Name, Query: rewriteNode[*Statement](n.Query, r)}
+	case *CompoundQuery:
+		node = &CompoundQuery{Op: n.Op, All: n.All, Query: rewriteNode[*Statement](n.Query, r)}
+	case *SelectClause:
+		c := &SelectClause{Fields: make([]*SelectItem, len(n.Fields))}
+		for i, field := range n.Fields {
+			c.Fields[i] = rewriteNode[*SelectItem](field, r)
+		}
+		node = c
+	case *SelectItem:
+		node = &SelectItem{Expr: rewriteNode[Expr](n.Expr, r), Alias: n.Alias}
+	case *FromClause:
+		c := *n
+		c.Clusters = slices.Clone(n.Clusters)
+		node = &c
+	case *JoinClause:
+		node = &JoinClause{Type: n.Type, From: rewriteNode[*FromClause](n.From, r), On: rewriteNode[Expr](n.On, r)}
+	case *WhereClause:
+		node = &WhereClause{Cond: rewriteNode[Expr](n.Cond, r)}
+	case *OrderByClause:
+		c := &OrderByClause{Keys: make([]*OrderByItem, len(n.Keys))}
+		for i, key := range n.Keys {
+			c.Keys[i] = rewriteNode[*OrderByItem](key, r)
+		}
+		node = c
+	case *OrderByItem:
+		node = &OrderByItem{Expr: rewriteNode[Expr](n.Expr, r), Direction: n.Direction}
+
+	case *FieldRef:
+		node = &FieldRef{Path: append([]Segment(nil), n.Path...)}
+	case *Literal:
+		c := *n
+		node = &c
+	case *StarExpr, *placeholderExpr:
+		// Stateless leaves
+	case *ParenExpr:
+		node = &ParenExpr{X: rewriteNode[Expr](n.X, r)}
+	case *UnaryExpr:
+		node = &UnaryExpr{Op: n.Op, X: rewriteNode[Expr](n.X, r)}
+	case *BinaryExpr:
+		node = &BinaryExpr{Op: n.Op, X: rewriteNode[Expr](n.X, r), Y: rewriteNode[Expr](n.Y, r)}
+	case *InExpr:
+		list := make([]Expr, len(n.List))
+		for i, item := range n.List {
+			list[i] = rewriteNode[Expr](item, r)
+		}
+		subquery := n.Subquery
+		if subquery != nil && !r.scope {
+			subquery = rewriteNode[*Statement](subquery, r)
+		}
+		node = &InExpr{X: rewriteNode[Expr](n.X, r), List: list, Subquery: subquery, Not: n.Not}
+	case *ExistsExpr:
+		subquery := n.Subquery
+		if !r.scope {
+			subquery = rewriteNode[*Statement](subquery, r)
+		}
+		node = &ExistsExpr{Subquery: subquery}
+	case *BetweenExpr:
+		node = &BetweenExpr{X: rewriteNode[Expr](n.X, r), Low: rewriteNode[Expr](n.Low, r), High: rewriteNode[Expr](n.High, r), Not: n.Not}
+	case *IsNullExpr:
+		node = &IsNullExpr{X: rewriteNode[Expr](n.X, r), Not: n.Not}
+	case *CallExpr:
+		args := make([]Expr, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = rewriteNode[Expr](arg, r)
+		}
+		node = &CallExpr{Func: n.Func, Args: args}
+	default:
+		panic(fmt.Sprintf("kubesql.Rewrite: unexpected node type %T", n))
+	}
+	return r.f(node)
+}
+
+// rewriteNode rewrites a child node, which must stay of type T.
+func rewriteNode[T Node](node T, r rewriter) T {
+	result, ok := r.rewrite(node).(T)
+	if !ok {
+		panic(fmt.Sprintf("kubesql.Rewrite: %T can not replace %T", result, node))
+	}
+	return result
+}
+
+// RewriteQuery rewrites the syntax tree of a query with Rewrite, and returns
+// the resulting query.
+func RewriteQuery(q *Query, f func(Node) Node) (*Query, error) {
+	s, err := NewStatement(q)
+	if err != nil {
+		return nil, err
+	}
+	return rewriteNode[*Statement](s, rewriter{f: f}).Query(), nil
+}
@@ -0,0 +1,201 @@
+package kubesql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recorder records the nodes it visits, with nil as ")".
+type recorder struct {
+	nodes []string
+}
+
+func (r *recorder) Visit(node Node) Visitor {
+	if node == nil {
+		r.nodes = append(r.nodes, ")")
+		return nil
+	}
+	r.nodes = append(r.nodes, fmt.Sprintf("%T", node))
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	query, _ := NewParser("SELECT name FROM pods WHERE NOT x IN (1) ORDER BY len(y)").Parse()
+	statement, err := NewStatement(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	r := &recorder{}
+	Walk(r, statement)
+	expected := []string{
+		"*kubesql.Statement",
+		"*kubesql.SelectClause", "*kubesql.SelectItem", "*kubesql.FieldRef", ")", ")", ")",
+		"*kubesql.FromClause", ")",
+		"*kubesql.WhereClause", "*kubesql.UnaryExpr", "*kubesql.InExpr", "*kubesql.FieldRef", ")", "*kubesql.Literal", ")", ")", ")", ")",
+		"*kubesql.OrderByClause", "*kubesql.OrderByItem", "*kubesql.CallExpr", "*kubesql.FieldRef", ")", ")", ")", ")",
+		")",
+	}
+	if !reflect.DeepEqual(r.nodes, expected) {
+		t.Errorf("Expected visits %v, got %v", expected, r.nodes)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+	}{
+		{"SELECT name, a + b FROM pods WHERE c = 1 ORDER BY d", []string{"name", "a", "b", "c", "d"}},
+		{"SELECT count(*) FROM pods WHERE x IN (y, 'z') AND w BETWEEN u AND v", []string{"x", "y", "w", "u", "v"}},
+		{"FROM pods", nil},
+		{"SELECT p.name FROM pods p JOIN nodes n ON p.spec.nodeName = n.name WHERE n.x = 1", []string{"p.name", "p.spec.nodeName", "n.name", "n.x"}},
+		{"SELECT name FROM pods WHERE a IN (SELECT b FROM nodes WHERE EXISTS (SELECT c FROM services))", []string{"name", "a", "b", "c"}},
+	}
+
+	for _, tc := range testCases {
+		query, _ := NewParser(tc.input).Parse()
+		statement, err := NewStatement(query)
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		var fields []string
+		Inspect(statement, func(node Node) bool {
+			if ref, ok := node.(*FieldRef); ok {
+				fields = append(fields, ref.String())
+			}
+			return true
+		})
+		if !reflect.DeepEqual(fields, tc.expected) {
+			t.Errorf("For input '%s', expected fields %v, got %v", tc.input, tc.expected, fields)
+		}
+	}
+
+	// Returning false skips the children of a node
+	expr, _ := ParseExpr("len(a) > b")
+	var visited []string
+	Inspect(expr, func(node Node) bool {
+		if node != nil {
+			visited = append(visited, node.String())
+		}
+		_, isCall := node.(*CallExpr)
+		return !isCall
+	})
+	if !reflect.DeepEqual(visited, []string{"len(a) > b", "len(a)", "b"}) {
+		t.Errorf("Expected the call arguments to be skipped, got %v", visited)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	testCases := []struct {
+		input    string
+		rewrite  func(Node) Node
+		expected string
+	}{
+		{
+			input: "SELECT name FROM default/pods WHERE labels.app = 'web'",
+			rewrite: func(node Node) Node {
+				if from, ok := node.(*FromClause); ok {
+					from.Namespace = "prod"
+				}
+				return node
+			},
+			expected: "SELECT name FROM prod/pods WHERE labels.app = 'web'",
+		},
+		{
+			input: "SELECT a * 2 FROM pods WHERE a > 1 ORDER BY a ASC",
+			rewrite: func(node Node) Node {
+				if ref, ok := node.(*FieldRef); ok && ref.String() == "a" {
+					return &BinaryExpr{Op: "+", X: &FieldRef{Path: []Segment{{Name: "b"}}}, Y: &FieldRef{Path: []Segment{{Name: "c"}}}}
+				}
+				return node
+			},
+			expected: "SELECT (b + c) * 2 FROM pods WHERE b + c > 1 ORDER BY b + c ASC",
+		},
+		{
+			input: "FROM pods WHERE a = 'x' AND b = 'y'",
+			rewrite: func(node Node) Node {
+				if literal, ok := node.(*Literal); ok {
+					literal.Value = strings.ToUpper(literal.Value)
+				}
+				return node
+			},
+			expected: "FROM pods WHERE a = 'X' AND b = 'Y'",
+		},
+		{
+			input: "FROM pods WHERE a = 1 AND b = 2",
+			rewrite: func(node Node) Node {
+				if b, ok := node.(*BinaryExpr); ok && b.Op == "AND" {
+					b.Op = "OR"
+				}
+				if where, ok := node.(*WhereClause); ok {
+					where.Cond = &BinaryExpr{Op: "AND", X: where.Cond, Y: &FieldRef{Path: []Segment{{Name: "c"}}}}
+				}
+				return node
+			},
+			expected: "FROM pods WHERE (a = 1 OR b = 2) AND c",
+		},
+		{
+			input: "FROM pods WHERE a IN (SELECT a FROM nodes WHERE EXISTS (SELECT a FROM services))",
+			rewrite: func(node Node) Node {
+				if from, ok := node.(*FromClause); ok {
+					from.Namespace = "prod"
+				}
+				return node
+			},
+			expected: "FROM prod/pods WHERE a IN (SELECT a FROM prod/nodes WHERE EXISTS (SELECT a FROM prod/services))",
+		},
+		{
+			input: "WITH w AS (SELECT name FROM nodes WHERE a = 1) SELECT name FROM w WHERE a = 2",
+			rewrite: func(node Node) Node {
+				if lit, ok := node.(*Literal); ok && lit.Value == "1" {
+					lit.Value = "3"
+				}
+				return node
+			},
+			expected: "WITH w AS (SELECT name FROM nodes WHERE a = 3) SELECT name FROM w WHERE a = 2",
+		},
+		{
+			input: "SELECT name FROM pods UNION SELECT name FROM nodes WHERE a = 1 ORDER BY name ASC",
+			rewrite: func(node Node) Node {
+				if from, ok := node.(*FromClause); ok {
+					from.Namespace = "prod"
+				}
+				return node
+			},
+			expected: "SELECT name FROM prod/pods UNION SELECT name FROM prod/nodes WHERE a = 1 ORDER BY name ASC",
+		},
+	}
+
+	for _, tc := range testCases {
+		query, _ := NewParser(tc.input).Parse()
+		rewritten, err := RewriteQuery(query, tc.rewrite)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if rewritten.String() != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, rewritten.String())
+		}
+		if query.String() != tc.input {
+			t.Errorf("For input '%s', expected the query to be unchanged, got '%s'", tc.input, query.String())
+		}
+	}
+}
+
+func TestRewriteKind(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic when replacing an expression with a clause")
+		}
+	}()
+	query, _ := NewParser("FROM pods WHERE a = 1").Parse()
+	_, _ = RewriteQuery(query, func(node Node) Node {
+		if _, ok := node.(*Literal); ok {
+			return &FromClause{Resource: "pods"}
+		}
+		return node
+	})
+}
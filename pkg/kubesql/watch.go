@@ -0,0 +1,377 @@
+package kubesql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+// EventType is the type of a watch event, as sent by the API server.
+type EventType string
+
+// Watch event types.
+const (
+	EventAdded    EventType = "ADDED"
+	EventModified EventType = "MODIFIED"
+	EventDeleted  EventType = "DELETED"
+	EventBookmark EventType = "BOOKMARK" // Only carries a resource version
+	EventError    EventType = "ERROR"    // The object is a Status
+)
+
+// Event is a change of an object of a watched resource.
+type Event struct {
+	Type   EventType
+	Object map[string]any
+}
+
+// ChangeType is the type of a change of the rows of a result.
+type ChangeType string
+
+// Row change types.
+const (
+	RowAdded   ChangeType = "ADDED"
+	RowUpdated ChangeType = "UPDATED"
+	RowRemoved ChangeType = "REMOVED"
+)
+
+// Change is a change of the rows of a query result. Applying the changes
+// in order to the rows of the previous result gives the new result: an
+// added row is inserted at Index, an updated row is moved to Index with its
+// new values, and a removed row is deleted from Index.
+type Change struct {
+	Type  ChangeType `json:"type" yaml:"type"`
+	Key   string     `json:"key" yaml:"key"`     // namespace/name of the object of the row, empty for aggregate rows
+	Index int        `json:"index" yaml:"index"` // Position of the row
+	Row   []any      `json:"row" yaml:"row"`     // Values of the row, the last ones for removed rows
+}
+
+// View is the result of a query kept up to date from watch events. Rows
+// are kept sorted, so an event only changes the rows of its object and the
+// rows entering or leaving the LIMIT window. Rows with equal ORDER BY keys
+// are ordered by namespace and name, like API lists. Aggregate rows are
+// recomputed over the matching objects.
+type View struct {
+	Columns []Column // Result columns, one per SELECT field
+
+	q          *Query
+	namespace  string
+	where      Expr
+	selects    []Expr
+	order      []sortField
+	aggregates bool // Whether the query selects aggregate functions
+	e          *evaluator
+
+	rows         []*viewRow          // Matching objects, in result order
+	byKey        map[string]*viewRow // Matching objects by key
+	aggregateRow []any               // Row of an aggregate query, nil until computed
+}
+
+// viewRow is a matching object of a view and its row.
+type viewRow struct {
+	key     string
+	object  map[string]any
+	row     []any
+	sortKey []any
+}
+
+// NewView creates an empty view of a query.
+func NewView(q *Query) (*View, error) {
+	tables, err := queryTables(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) > 1 {
+		return nil, errors.New("can not watch queries joining resources")
+	}
+	if len(q.With) > 0 {
+		return nil, errors.New("can not watch queries with common table expressions")
+	}
+	if len(q.Compound) > 0 {
+		return nil, fmt.Errorf("can not watch queries with %s", q.Compound[0].Op)
+	}
+	selects, err := parseSelect(q)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &View{q: q, namespace: tables[0].ref.Namespace, selects: selects, e: newEvaluator(), byKey: map[string]*viewRow{}}
+	v.e.tables = tableNames(tables)
+	if q.Where != "" {
+		if v.where, err = ParseExpr(q.Where); err != nil {
+			return nil, fmt.Errorf("error parsing %s clause: %w", WhereKeyword, err)
+		}
+		if len(subqueries(v.where)) > 0 {
+			return nil, errors.New("can not watch queries with subqueries")
+		}
+	}
+	v.Columns = resultColumns(q, selects)
+	if v.order, err = parseOrderBy(q, v.Columns); err != nil {
+		return nil, err
+	}
+	v.aggregates = isAggregateQuery(selects)
+	return v, nil
+}
+
+// Result returns the current result of the view.
+func (v *View) Result() *Result {
+	result := &Result{Columns: v.Columns, Rows: [][]any{}}
+	if v.aggregates {
+		if v.aggregateRow != nil {
+			result.Rows = append(result.Rows, v.aggregateRow)
+		}
+		return result
+	}
+	for _, r := range v.rows[:v.visible()] {
+		result.Rows = append(result.Rows, r.row)
+	}
+	return result
+}
+
+// visible returns the number of rows in the LIMIT window.
+func (v *View) visible() int {
+	if v.q.Limit >= 0 && v.q.Limit < len(v.rows) {
+		return v.q.Limit
+	}
+	return len(v.rows)
+}
+
+// Apply applies a watch event, and returns the changes of the result.
+// ADDED and MODIFIED events both add or update the object, bookmarks
+// change nothing, and ERROR events return the error of their status.
+func (v *View) Apply(event Event) ([]Change, error) {
+	switch event.Type {
+	case EventAdded, EventModified, EventDeleted:
+	case EventBookmark:
+		return nil, nil
+	case EventError:
+		message, _ := event.Object["message"].(string)
+		return nil, fmt.Errorf("watch error: %s", message)
+	default:
+		return nil, fmt.Errorf("unknown watch event type '%s'", event.Type)
+	}
+
+	key := objectKey(event.Object)
+	next, err := v.match(event)
+	if err != nil {
+		return nil, err
+	}
+	if v.aggregates {
+		v.update(key, next)
+		return v.aggregateChanges()
+	}
+
+	limit := v.q.Limit
+	shown := func(i int) bool { return i >= 0 && (limit < 0 || i < limit) }
+	before := v.position(v.byKey[key])
+	previous := v.update(key, next)
+	after := v.position(next)
+
+	var changes []Change
+	switch {
+	case shown(before) && shown(after):
+		if before != after || !reflect.DeepEqual(previous.row, next.row) {
+			changes = append(changes, Change{Type: RowUpdated, Key: key, Index: after, Row: next.row})
+		}
+	case shown(before):
+		changes = append(changes, Change{Type: RowRemoved, Key: key, Index: before, Row: previous.row})
+		if limit > 0 && len(v.rows) >= limit {
+			// The next row enters the window
+			entered := v.rows[limit-1]
+			changes = append(changes, Change{Type: RowAdded, Key: entered.key, Index: limit - 1, Row: entered.row})
+		}
+	case shown(after):
+		if limit >= 0 && len(v.rows) > limit {
+			// The last row leaves the window
+			left := v.rows[limit]
+			changes = append(changes, Change{Type: RowRemoved, Key: left.key, Index: limit - 1, Row: left.row})
+		}
+		changes = append(changes, Change{Type: RowAdded, Key: key, Index: after, Row: next.row})
+	}
+	return changes, nil
+}
+
+// Sync replaces the objects of the view, e.g. with the objects of a new
+// list after a watch expired, and returns the changes of the result.
+func (v *View) Sync(objects []map[string]any) ([]Change, error) {
+	oldRows := v.Result().Rows
+	var oldKeys []string
+	if !v.aggregates {
+		for _, r := range v.rows[:len(oldRows)] {
+			oldKeys = append(oldKeys, r.key)
+		}
+	}
+
+	listed := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		key := objectKey(object)
+		listed[key] = true
+		next, err := v.match(Event{Type: EventAdded, Object: object})
+		if err != nil {
+			return nil, err
+		}
+		v.update(key, next)
+	}
+	for _, r := range append([]*viewRow(nil), v.rows...) {
+		if !listed[r.key] {
+			v.update(r.key, nil)
+		}
+	}
+	if v.aggregates {
+		return v.aggregateChanges()
+	}
+
+	// Remove the rows that left the window, last first, then add and move
+	// rows in the order of the new window
+	var changes []Change
+	window := v.rows[:v.visible()]
+	kept := make(map[string]bool, len(window))
+	for _, r := range window {
+		kept[r.key] = true
+	}
+	current := []string{}
+	for i := len(oldKeys) - 1; i >= 0; i-- {
+		if !kept[oldKeys[i]] {
+			changes = append(changes, Change{Type: RowRemoved, Key: oldKeys[i], Index: i, Row: oldRows[i]})
+		}
+	}
+	oldRow := map[string][]any{}
+	for i, key := range oldKeys {
+		if kept[key] {
+			current = append(current, key)
+			oldRow[key] = oldRows[i]
+		}
+	}
+	for i, r := range window {
+		position := -1
+		for j, key := range current {
+			if key == r.key {
+				position = j
+				break
+			}
+		}
+		switch {
+		case position < 0:
+			changes = append(changes, Change{Type: RowAdded, Key: r.key, Index: i, Row: r.row})
+			current = append(current[:i], append([]string{r.key}, current[i:]...)...)
+		case position != i || !reflect.DeepEqual(oldRow[r.key], r.row):
+			changes = append(changes, Change{Type: RowUpdated, Key: r.key, Index: i, Row: r.row})
+			current = append(current[:position], current[position+1:]...)
+			current = append(current[:i], append([]string{r.key}, current[i:]...)...)
+		}
+	}
+	return changes, nil
+}
+
+// match returns the row of the object of an event, nil when the object
+// was deleted or does not match the namespace and WHERE condition.
+func (v *View) match(event Event) (*viewRow, error) {
+	object := event.Object
+	if event.Type == EventDeleted {
+		return nil, nil
+	}
+	if v.namespace != "" && objectNamespace(object) != v.namespace {
+		return nil, nil
+	}
+	if v.where != nil {
+		value, err := v.e.eval(v.where, object)
+		if err != nil {
+			return nil, fmt.Errorf("error evaluating %s clause: %w", WhereKeyword, err)
+		}
+		if !truthy(value) {
+			return nil, nil
+		}
+	}
+
+	r := &viewRow{key: objectKey(object), object: object}
+	if v.aggregates {
+		return r, nil
+	}
+	var err error
+	if r.row, err = v.e.row(v.selects, object); err != nil {
+		return nil, err
+	}
+	if r.sortKey, err = v.e.sortKey(v.order, object, r.row); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// update replaces the row of an object, removing it when next is nil, and
+// returns the previous row.
+func (v *View) update(key string, next *viewRow) *viewRow {
+	previous := v.byKey[key]
+	if previous != nil {
+		i := v.position(previous)
+		v.rows = append(v.rows[:i], v.rows[i+1:]...)
+		delete(v.byKey, key)
+	}
+	if next == nil {
+		return previous
+	}
+
+	i := sort.Search(len(v.rows), func(i int) bool { return v.less(next, v.rows[i]) })
+	v.rows = append(v.rows[:i], append([]*viewRow{next}, v.rows[i:]...)...)
+	v.byKey[key] = next
+	return previous
+}
+
+// position returns the index of a row in the result order, -1 for nil.
+func (v *View) position(r *viewRow) int {
+	if r == nil {
+		return -1
+	}
+	i := sort.Search(len(v.rows), func(i int) bool { return !v.less(v.rows[i], r) })
+	if i < len(v.rows) && v.rows[i] == r {
+		return i
+	}
+	// Values compared by their text may not be ordered consistently
+	return slices.Index(v.rows, r)
+}
+
+// less reports whether row a comes before row b: by the ORDER BY keys, then
+// by namespace and name, the order of the objects of API lists.
+func (v *View) less(a, b *viewRow) bool {
+	if c := compareSortKeys(v.order, a.sortKey, b.sortKey); c != 0 {
+		return c < 0
+	}
+	return a.key < b.key
+}
+
+// aggregateChanges recomputes the row of an aggregate query, and returns
+// its change.
+func (v *View) aggregateChanges() ([]Change, error) {
+	if v.q.Limit == 0 {
+		return nil, nil
+	}
+	objects := make([]map[string]any, len(v.rows))
+	for i, r := range v.rows {
+		objects[i] = r.object
+	}
+	row, err := v.e.aggregateRow(v.selects, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := v.aggregateRow
+	v.aggregateRow = row
+	switch {
+	case previous == nil:
+		return []Change{{Type: RowAdded, Index: 0, Row: row}}, nil
+	case !reflect.DeepEqual(previous, row):
+		return []Change{{Type: RowUpdated, Index: 0, Row: row}}, nil
+	}
+	return nil, nil
+}
+
+// objectKey returns the namespace/name of an object, or its name when it
+// has no namespace.
+func objectKey(object map[string]any) string {
+	metadata, _ := object["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+	if namespace := objectNamespace(object); namespace != "" {
+		return namespace + "/" + name
+	}
+	return name
+}
@@ -0,0 +1,393 @@
+package kubesql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// watchPod returns a pod object for watch events.
+func watchPod(name, phase string, restarts float64) map[string]any {
+	return map[string]any{
+		"kind":     "Pod",
+		"metadata": map[string]any{"name": name, "namespace": "default"},
+		"status": map[string]any{
+			"phase":    phase,
+			"restarts": restarts,
+		},
+	}
+}
+
+// watchEvents are the events of the watch tests, changing the phase and
+// restarts of pods, and deleting some.
+var watchEvents = []Event{
+	{EventAdded, watchPod("a", "Running", 3)},
+	{EventAdded, watchPod("b", "Pending", 0)},
+	{EventAdded, watchPod("c", "Running", 1)},
+	{EventAdded, watchPod("d", "Running", 5)},
+	{EventModified, watchPod("b", "Running", 0)},
+	{EventModified, watchPod("a", "Running", 3)},
+	{EventModified, watchPod("c", "Running", 7)},
+	{EventBookmark, map[string]any{"metadata": map[string]any{"resourceVersion": "12"}}},
+	{EventModified, watchPod("d", "Failed", 5)},
+	{EventAdded, watchPod("e", "Running", 2)},
+	{EventDeleted, watchPod("c", "Running", 7)},
+	{EventModified, watchPod("a", "Pending", 3)},
+	{EventDeleted, watchPod("x", "Running", 0)},
+	{EventModified, watchPod("d", "Running", 0)},
+	{EventDeleted, watchPod("b", "Running", 0)},
+}
+
+// applyChanges applies the changes of a result to its rows.
+func applyChanges(rows [][]any, keys []string, changes []Change) ([][]any, []string) {
+	for _, change := range changes {
+		switch change.Type {
+		case RowAdded:
+			rows = slices.Insert(rows, change.Index, change.Row)
+			keys = slices.Insert(keys, change.Index, change.Key)
+		case RowUpdated:
+			i := slices.Index(keys, change.Key)
+			rows, keys = slices.Delete(rows, i, i+1), slices.Delete(keys, i, i+1)
+			rows = slices.Insert(rows, change.Index, change.Row)
+			keys = slices.Insert(keys, change.Index, change.Key)
+		case RowRemoved:
+			rows, keys = slices.Delete(rows, change.Index, change.Index+1), slices.Delete(keys, change.Index, change.Index+1)
+		}
+	}
+	return rows, keys
+}
+
+// applyEvent applies a watch event to a list of objects, sorted by
+// namespace and name like API lists.
+func applyEvent(objects []map[string]any, event Event) []map[string]any {
+	i, found := slices.BinarySearchFunc(objects, objectKey(event.Object), func(object map[string]any, key string) int {
+		return strings.Compare(objectKey(object), key)
+	})
+	switch {
+	case event.Type == EventBookmark:
+		return objects
+	case event.Type == EventDeleted && found:
+		return slices.Delete(objects, i, i+1)
+	case event.Type == EventDeleted:
+		return objects
+	case found:
+		objects[i] = event.Object
+		return objects
+	}
+	return slices.Insert(objects, i, event.Object)
+}
+
+func TestViewApply(t *testing.T) {
+	testCases := []string{
+		"SELECT name, status.phase FROM pods",
+		"SELECT name FROM pods WHERE status.phase = 'Running'",
+		"SELECT name, status.restarts FROM pods ORDER BY status.restarts DESC",
+		"SELECT name, status.restarts FROM pods WHERE status.phase = 'Running' ORDER BY status.restarts DESC LIMIT 2",
+		"SELECT name, status.phase AS phase FROM pods ORDER BY phase, name LIMIT 3",
+		"SELECT name FROM pods LIMIT 1",
+		"SELECT name FROM pods LIMIT 0",
+		"SELECT name FROM kube-system/pods",
+		"SELECT count(*), sum(status.restarts) FROM pods WHERE status.phase = 'Running'",
+	}
+
+	for _, input := range testCases {
+		query, err := NewParser(input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		view, err := NewView(query)
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+
+		var objects []map[string]any
+		var rows [][]any
+		var keys []string
+		for n, event := range watchEvents {
+			changes, err := view.Apply(event)
+			if err != nil {
+				t.Fatalf("For input '%s', expected no error at event %d, got %v", input, n, err)
+			}
+			objects = applyEvent(objects, event)
+			rows, keys = applyChanges(rows, keys, changes)
+
+			expected, err := Execute(query, objects)
+			if err != nil {
+				t.Fatalf("For input '%s', expected no error, got %v", input, err)
+			}
+			if !reflect.DeepEqual(view.Result().Rows, expected.Rows) {
+				t.Errorf("For input '%s' at event %d, expected result %v, got %v", input, n, expected.Rows, view.Result().Rows)
+			}
+			if len(rows) > 0 || len(expected.Rows) > 0 {
+				if !reflect.DeepEqual(rows, expected.Rows) {
+					t.Errorf("For input '%s' at event %d, expected changes to give %v, got %v (changes %+v)", input, n, expected.Rows, rows, changes)
+				}
+			}
+		}
+	}
+}
+
+func TestViewChanges(t *testing.T) {
+	query, err := NewParser("SELECT name, status.restarts FROM pods ORDER BY status.restarts DESC LIMIT 2").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	view, err := NewView(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	testCases := []struct {
+		event    Event
+		expected []Change
+	}{
+		{Event{EventAdded, watchPod("a", "Running", 1)}, []Change{{RowAdded, "default/a", 0, []any{"a", float64(1)}}}},
+		{Event{EventAdded, watchPod("b", "Running", 2)}, []Change{{RowAdded, "default/b", 0, []any{"b", float64(2)}}}},
+		{Event{EventAdded, watchPod("c", "Running", 0)}, nil},
+		{Event{EventModified, watchPod("a", "Pending", 1)}, nil},
+		{
+			Event{EventModified, watchPod("c", "Running", 5)},
+			[]Change{{RowRemoved, "default/a", 1, []any{"a", float64(1)}}, {RowAdded, "default/c", 0, []any{"c", float64(5)}}},
+		},
+		{Event{EventModified, watchPod("b", "Running", 9)}, []Change{{RowUpdated, "default/b", 0, []any{"b", float64(9)}}}},
+		{
+			Event{EventDeleted, watchPod("b", "Running", 9)},
+			[]Change{{RowRemoved, "default/b", 0, []any{"b", float64(9)}}, {RowAdded, "default/a", 1, []any{"a", float64(1)}}},
+		},
+	}
+
+	for n, tc := range testCases {
+		changes, err := view.Apply(tc.event)
+		if err != nil {
+			t.Errorf("For event %d, expected no error, got %v", n, err)
+			continue
+		}
+		if !reflect.DeepEqual(changes, tc.expected) {
+			t.Errorf("For event %d, expected changes %+v, got %+v", n, tc.expected, changes)
+		}
+	}
+
+	if _, err := view.Apply(Event{EventError, map[string]any{"message": "too old resource version"}}); err == nil {
+		t.Errorf("Expected an error for an ERROR event")
+	}
+}
+
+func TestViewSync(t *testing.T) {
+	query, err := NewParser("SELECT name, status.restarts FROM pods ORDER BY status.restarts DESC LIMIT 3").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	view, err := NewView(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	lists := [][]map[string]any{
+		{watchPod("a", "Running", 1), watchPod("b", "Running", 2)},
+		{watchPod("a", "Running", 4), watchPod("c", "Running", 3), watchPod("d", "Running", 0), watchPod("e", "Running", 9)},
+		{watchPod("a", "Running", 4), watchPod("c", "Running", 3), watchPod("d", "Running", 0), watchPod("e", "Running", 9)},
+		{},
+	}
+
+	var rows [][]any
+	var keys []string
+	for n, objects := range lists {
+		changes, err := view.Sync(objects)
+		if err != nil {
+			t.Fatalf("For list %d, expected no error, got %v", n, err)
+		}
+		if n == 2 && len(changes) > 0 {
+			t.Errorf("For list %d, expected no changes, got %+v", n, changes)
+		}
+		rows, keys = applyChanges(rows, keys, changes)
+
+		expected, err := Execute(query, objects)
+		if err != nil {
+			t.Fatalf("For list %d, expected no error, got %v", n, err)
+		}
+		if len(rows) == 0 {
+			rows = [][]any{}
+		}
+		if !reflect.DeepEqual(rows, expected.Rows) {
+			t.Errorf("For list %d, expected changes to give %v, got %v (changes %+v)", n, expected.Rows, rows, changes)
+		}
+	}
+}
+
+// watchSource is a Watcher serving the objects of a dataset, then the
+// events of a channel.
+type watchSource struct {
+	*Dataset
+	watches []ListOptions
+	events  []chan Event
+}
+
+func (s *watchSource) Watch(ctx context.Context, resource ResourceInfo, opts ListOptions) (<-chan Event, error) {
+	s.watches = append(s.watches, opts)
+	if len(s.watches) > len(s.events) {
+		return nil, errors.New("no more watches")
+	}
+
+	// Forward the events until ctx is done, like a Watcher must
+	in, out := s.events[len(s.watches)-1], make(chan Event)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case event, ok := <-in:
+				if !ok {
+					return
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func TestRunnerWatch(t *testing.T) {
+	dataset, err := LoadDataset("testdata/snapshot")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	query, err := NewParser("SELECT name, status.phase FROM pods WHERE labels.app = 'web' ORDER BY name").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	web3 := map[string]any{
+		"kind": "Pod",
+		"metadata": map[string]any{
+			"name": "web-3", "namespace": "default", "resourceVersion": "7",
+			"labels": map[string]any{"app": "web"},
+		},
+		"status": map[string]any{"phase": "Pending"},
+	}
+	source := &watchSource{Dataset: dataset, events: []chan Event{make(chan Event, 3), make(chan Event, 1), make(chan Event, 1)}}
+	source.events[0] <- Event{EventAdded, web3}
+	source.events[0] <- Event{EventBookmark, map[string]any{"metadata": map[string]any{"resourceVersion": "8"}}}
+	close(source.events[0])
+	source.events[1] <- Event{EventError, map[string]any{"kind": "Status", "code": float64(410), "reason": "Expired"}}
+	close(source.events[1])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var batches [][]Change
+	runner := NewRunner(source, DefaultResolver())
+	runner.WatchBackoff = time.Millisecond
+	err = runner.Watch(ctx, query, func(changes []Change) error {
+		batches = append(batches, changes)
+		if len(batches) == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the watch to end with the context, got %v", err)
+	}
+
+	// The objects are listed again after the watch expired, web-3 is gone
+	expected := [][]Change{
+		{
+			{RowAdded, "default/web-1", 0, []any{"web-1", "Running"}},
+			{RowAdded, "default/web-2", 1, []any{"web-2", "Pending"}},
+		},
+		{{RowAdded, "default/web-3", 2, []any{"web-3", "Pending"}}},
+		{{RowRemoved, "default/web-3", 2, []any{"web-3", "Pending"}}},
+	}
+	if !reflect.DeepEqual(batches, expected) {
+		t.Errorf("Expected changes %+v, got %+v", expected, batches)
+	}
+	watches := []ListOptions{{LabelSelector: "app=web"}, {LabelSelector: "app=web", ResourceVersion: "8"}}
+	if !reflect.DeepEqual(source.watches[:2], watches) {
+		t.Errorf("Expected watches %+v, got %+v", watches, source.watches)
+	}
+
+	if err := NewRunner(dataset, DefaultResolver()).Watch(ctx, query, nil); err == nil {
+		t.Errorf("Expected an error watching a dataset")
+	}
+	query, err = NewParser("SELECT p.name FROM pods p JOIN nodes n ON p.spec.nodeName = n.name").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := NewRunner(source, DefaultResolver()).Watch(ctx, query, nil); err == nil || err.Error() != "can not watch queries joining resources" {
+		t.Errorf("Expected an error watching a join, got %v", err)
+	}
+	query, err = NewParser("SELECT name FROM pods WHERE spec.nodeName IN (SELECT name FROM nodes)").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := NewRunner(source, DefaultResolver()).Watch(ctx, query, nil); err == nil || err.Error() != "can not watch queries with subqueries" {
+		t.Errorf("Expected an error watching a subquery, got %v", err)
+	}
+	if _, err := NewView(query); err == nil || err.Error() != "can not watch queries with subqueries" {
+		t.Errorf("Expected an error making a view of a subquery, got %v", err)
+	}
+	query, err = NewParser("WITH workers AS (FROM nodes) SELECT name FROM workers").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := NewView(query); err == nil || err.Error() != "can not watch queries with common table expressions" {
+		t.Errorf("Expected an error making a view of a common table expression, got %v", err)
+	}
+	query, err = NewParser("SELECT name FROM pods EXCEPT SELECT name FROM pods WHERE labels.app = 'web'").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := NewRunner(source, DefaultResolver()).Watch(ctx, query, nil); err == nil || err.Error() != "can not watch queries with EXCEPT" {
+		t.Errorf("Expected an error watching a set operation, got %v", err)
+	}
+	if _, err := NewView(query); err == nil || err.Error() != "can not watch queries with EXCEPT" {
+		t.Errorf("Expected an error making a view of a set operation, got %v", err)
+	}
+}
+
+// closedWatcher is a Watcher whose watches end right away, counting them.
+type closedWatcher struct {
+	*Dataset
+	watches atomic.Int64
+}
+
+func (s *closedWatcher) Watch(ctx context.Context, resource ResourceInfo, opts ListOptions) (<-chan Event, error) {
+	s.watches.Add(1)
+	events := make(chan Event)
+	close(events)
+	return events, nil
+}
+
+func TestRunnerWatchBackoff(t *testing.T) {
+	dataset, err := LoadDataset("testdata/snapshot")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	query, err := NewParser("SELECT name FROM pods").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	source := &closedWatcher{Dataset: dataset}
+	runner := NewRunner(source, DefaultResolver())
+	runner.WatchBackoff = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	err = runner.Watch(ctx, query, func([]Change) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the watch to end with the context, got %v", err)
+	}
+
+	// The delay doubles from 5-10ms, so at most 6 watches start in 200ms
+	if watches := source.watches.Load(); watches < 2 || watches > 6 {
+		t.Errorf("Expected the ended watches to be resumed with a backoff, got %d watches", watches)
+	}
+}
@@ -0,0 +1,342 @@
+package kubesql
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// QueryAPIVersion is the version of the JSON and YAML form of queries. It
+// changes when fields are renamed or removed, not when fields are added.
+const QueryAPIVersion = "kubesql/v1"
+
+// queryAlias has the fields of Query without its marshaling methods.
+type queryAlias Query
+
+// versionedQuery is the JSON and YAML form of a query.
+type versionedQuery struct {
+	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
+	queryAlias `yaml:",inline"`
+}
+
+// MarshalJSON implements json.Marshaler, adding the apiVersion field.
+func (q Query) MarshalJSON() ([]byte, error) {
+	return json.Marshal(versionedQuery{APIVersion: QueryAPIVersion, queryAlias: queryAlias(q)})
+}
+
+// MarshalYAML implements yaml.Marshaler, adding the apiVersion field.
+func (q Query) MarshalYAML() (any, error) {
+	return versionedQuery{APIVersion: QueryAPIVersion, queryAlias: queryAlias(q)}, nil
+}
+
+// UnmarshalQuery decodes a query from its JSON or YAML form, as written by
+// the CLI, and checks it: the apiVersion must be QueryAPIVersion, unknown
+// fields and data after the query are rejected, FROM must name a resource,
+// and every expression must parse. A missing limit means no limit, a missing join type INNER, and a
+// missing sort direction ASC.
+func UnmarshalQuery(data []byte) (*Query, error) {
+	wire := versionedQuery{queryAlias: queryAlias{Limit: DefaultLimit}}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		decoder := json.NewDecoder(bytes.NewReader(trimmed))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&wire); err != nil {
+			return nil, fmt.Errorf("error decoding query: %w", err)
+		}
+		if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+			return nil, errors.New("error decoding query: unexpected data after the query")
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&wire); err != nil {
+			return nil, fmt.Errorf("error decoding query: %w", err)
+		}
+		// Empty documents may follow, e.g. after a trailing ---
+		for {
+			var rest yaml.Node
+			err := decoder.Decode(&rest)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil || len(rest.Content) > 0 && rest.Content[0].Tag != "!!null" {
+				return nil, errors.New("error decoding query: unexpected data after the query")
+			}
+		}
+	}
+
+	switch wire.APIVersion {
+	case QueryAPIVersion:
+	case "":
+		return nil, errors.New("invalid query: apiVersion is required")
+	default:
+		return nil, fmt.Errorf("invalid query: unsupported apiVersion '%s', expected '%s'", wire.APIVersion, QueryAPIVersion)
+	}
+
+	q := Query(wire.queryAlias)
+	if err := checkQuery(&q); err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	return &q, nil
+}
+
+// UnmarshalQueries decodes the queries of a document written by the CLI: a
+// single query, a JSON array or YAML sequence of queries, as written for
+// scripts, or a stream of YAML documents. Each query is checked like
+// UnmarshalQuery does.
+func UnmarshalQueries(data []byte) ([]*Query, error) {
+	var documents [][]byte
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(trimmed, &items); err != nil {
+			return nil, fmt.Errorf("error decoding queries: %w", err)
+		}
+		for _, item := range items {
+			documents = append(documents, item)
+		}
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		documents = append(documents, trimmed)
+	default:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		for {
+			var node yaml.Node
+			if err := decoder.Decode(&node); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("error decoding queries: %w", err)
+			}
+			items := []*yaml.Node{&node}
+			if len(node.Content) == 1 && node.Content[0].Kind == yaml.SequenceNode {
+				items = node.Content[0].Content
+			}
+			for _, item := range items {
+				document, err := yaml.Marshal(item)
+				if err != nil {
+					return nil, fmt.Errorf("error decoding queries: %w", err)
+				}
+				documents = append(documents, document)
+			}
+		}
+	}
+
+	queries := make([]*Query, len(documents))
+	for i, document := range documents {
+		query, err := UnmarshalQuery(document)
+		if err != nil {
+			return nil, fmt.Errorf("query %d: %w", i+1, err)
+		}
+		queries[i] = query
+	}
+	if len(queries) == 0 {
+		return nil, errors.New("no queries found")
+	}
+	return queries, nil
+}
+
+// checkQuery checks the clauses of a decoded query, and fills in the
+// default join type and sort direction. Names of set operations are made
+// upper case.
+func checkQuery(q *Query) error {
+	for i, cte := range q.With {
+		switch {
+		case cte.Name == "":
+			return fmt.Errorf("with[%d].name is required", i)
+		case slices.ContainsFunc(q.With[:i], func(other CTE) bool { return strings.EqualFold(other.Name, cte.Name) }):
+			return fmt.Errorf("with[%d].name: common table expression '%s' is defined twice", i, cte.Name)
+		case cte.Query == "":
+			return fmt.Errorf("with[%d].query is required", i)
+		}
+		if _, err := cte.parse(); err != nil {
+			return fmt.Errorf("with[%d].query: %w", i, err)
+		}
+	}
+	if q.From == "" {
+		return errors.New("from is required")
+	}
+	if _, err := ParseResourceRef(q.From); err != nil {
+		return fmt.Errorf("from: %w", err)
+	}
+	for i := range q.Joins {
+		join := &q.Joins[i]
+		switch joinType := strings.ToUpper(join.Type); joinType {
+		case "":
+			join.Type = InnerJoin
+		case InnerJoin, LeftJoin:
+			join.Type = joinType
+		default:
+			return fmt.Errorf("joins[%d].type: must be %s or %s, got '%s'", i, InnerJoin, LeftJoin, join.Type)
+		}
+		if _, err := ParseResourceRef(join.From); err != nil {
+			return fmt.Errorf("joins[%d].from: %w", i, err)
+		}
+		if _, err := ParseExpr(join.On); err != nil {
+			return fmt.Errorf("joins[%d].on: %w", i, err)
+		}
+	}
+	if _, err := queryTables(q); err != nil {
+		return fmt.Errorf("joins: %w", err)
+	}
+	for i, field := range q.Select {
+		if _, err := ParseExpr(field.Field); err != nil {
+			return fmt.Errorf("select[%d].field: %w", i, err)
+		}
+	}
+	if q.Where != "" {
+		if _, err := ParseExpr(q.Where); err != nil {
+			return fmt.Errorf("where: %w", err)
+		}
+	}
+	for i := range q.Compound {
+		op := &q.Compound[i]
+		switch name := strings.ToUpper(op.Op); name {
+		case UnionKeyword, IntersectKeyword, ExceptKeyword:
+			op.Op = name
+		default:
+			return fmt.Errorf("compound[%d].op: must be %s, %s or %s, got '%s'", i, UnionKeyword, IntersectKeyword, ExceptKeyword, op.Op)
+		}
+		if op.Query == "" {
+			return fmt.Errorf("compound[%d].query is required", i)
+		}
+		if _, err := op.parse(); err != nil {
+			return fmt.Errorf("compound[%d].query: %w", i, err)
+		}
+	}
+	for i := range q.OrderBy {
+		field := &q.OrderBy[i]
+		if _, err := ParseExpr(field.Field); err != nil {
+			return fmt.Errorf("orderBy[%d].field: %w", i, err)
+		}
+		switch direction := strings.ToUpper(field.Direction); direction {
+		case "":
+			field.Direction = DefaultSortDirection
+		case "ASC", "DESC":
+			field.Direction = direction
+		default:
+			return fmt.Errorf("orderBy[%d].direction: must be ASC or DESC, got '%s'", i, field.Direction)
+		}
+	}
+	if q.Limit < DefaultLimit {
+		return fmt.Errorf("limit: must be -1 for no limit, or at least 0, got %d", q.Limit)
+	}
+	return nil
+}
+
+// schemaConstraints adds to the generated JSON Schema, by type and field.
+var schemaConstraints = map[string]map[string]any{
+	"versionedQuery.apiVersion": {"const": QueryAPIVersion},
+	"Query.with":                {"description": "Common table expressions, named queries read like resources by the query and the common table expressions after them"},
+	"Query.from":                {"minLength": 1, "description": "Resource to query, e.g. pods, default/pods or prod-*:default/pods"},
+	"Query.fromAlias":           {"description": "Name qualifying the fields of the from resource, its name when missing"},
+	"Query.joins":               {"description": "Resources joined to the from resource, in order"},
+	"Query.select":              {"description": "Fields to select, all fields when missing"},
+	"Query.where":               {"description": "Condition the objects must match"},
+	"Query.compound":            {"description": "Queries whose rows are combined with the rows of the query, in order; orderBy and limit apply to the combined rows"},
+	"Query.limit":               {"minimum": DefaultLimit, "default": DefaultLimit, "description": "Maximum number of results, -1 for no limit"},
+	"Join.type":                 {"enum": []any{InnerJoin, LeftJoin}, "default": InnerJoin},
+	"Join.from":                 {"minLength": 1, "description": "Joined resource, like from"},
+	"Join.alias":                {"description": "Name qualifying the fields of the joined resource, its name when missing"},
+	"Join.on":                   {"minLength": 1, "description": "Condition the joined objects match"},
+	"CTE.name":                  {"minLength": 1, "description": "Name reading the rows of the query in from and joins"},
+	"CTE.query":                 {"minLength": 1, "description": "Query whose rows are read, e.g. SELECT name FROM pods WHERE status.phase != 'Running'"},
+	"SetOperation.op":           {"enum": []any{UnionKeyword, IntersectKeyword, ExceptKeyword}},
+	"SetOperation.all":          {"description": "Keeps duplicate rows, as in UNION ALL"},
+	"SetOperation.query":        {"minLength": 1, "description": "Query selecting as many columns as the first one, without orderBy or limit"},
+	"SelectField.field":         {"minLength": 1},
+	"OrderByField.field":        {"minLength": 1},
+	"OrderByField.direction":    {"enum": []any{"ASC", "DESC"}, "default": DefaultSortDirection},
+	"Column.type":               {"enum": typeNameList()},
+}
+
+// typeNameList returns the names of the types, as JSON Schema values.
+func typeNameList() []any {
+	names := make([]any, len(typeNames))
+	for i, name := range typeNames {
+		names[i] = name
+	}
+	return names
+}
+
+// QueryJSONSchema returns the JSON Schema of the JSON form of queries,
+// generated from the Query type and its json tags.
+func QueryJSONSchema() []byte {
+	schema := jsonSchema(reflect.TypeFor[versionedQuery](), "versionedQuery")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "KubeSQL query"
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return append(data, '\n')
+}
+
+// jsonSchema returns the JSON Schema of a type. Struct fields are named by
+// their json tags, and are required unless omitted when empty or defaulted.
+func jsonSchema(t reflect.Type, name string) map[string]any {
+	if t.Implements(reflect.TypeFor[encoding.TextMarshaler]()) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int:
+		return map[string]any{"type": "integer"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), t.Elem().Name())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+		addStructFields(t, name, properties, &required)
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	panic(fmt.Sprintf("kubesql: no JSON Schema for type %s", t))
+}
+
+// addStructFields adds the fields of a struct type, and of its embedded
+// structs, to the properties and required fields of its schema.
+func addStructFields(t reflect.Type, name string, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			typeName := field.Type.Name()
+			if field.Type == reflect.TypeFor[queryAlias]() {
+				typeName = "Query"
+			}
+			addStructFields(field.Type, typeName, properties, required)
+			continue
+		}
+		tag, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		property := jsonSchema(field.Type, field.Type.Name())
+		constraints := schemaConstraints[name+"."+tag]
+		for key, value := range constraints {
+			property[key] = value
+		}
+		properties[tag] = property
+
+		_, defaulted := constraints["default"]
+		if !strings.Contains(options, "omit") && !defaulted {
+			*required = append(*required, tag)
+		}
+	}
+}
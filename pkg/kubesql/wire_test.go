@@ -0,0 +1,199 @@
+package kubesql
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMarshalQuery(t *testing.T) {
+	query, err := NewParser("SELECT name AS n FROM default/pods WHERE x = 1 ORDER BY n DESC LIMIT 0 -- note").Parse()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data, err := json.Marshal(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := `{"apiVersion":"kubesql/v1","select":[{"field":"name","alias":"n"}],"from":"default/pods","where":"x = 1",` +
+		`"orderBy":[{"field":"n","direction":"DESC"}],"limit":0,"pos":{"offset":0,"line":1,"column":1},` +
+		`"comments":[{"text":"-- note","pos":{"offset":71,"line":1,"column":72}}]}`
+	if string(data) != expected {
+		t.Errorf("Expected JSON %s, got %s", expected, data)
+	}
+
+	yamlData, err := yaml.Marshal(query)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.HasPrefix(string(yamlData), "apiVersion: kubesql/v1\nselect:\n") {
+		t.Errorf("Expected YAML to start with the apiVersion, got %s", yamlData)
+	}
+
+	// The source text stays out of the serialized form
+	written := *query
+	written.Source = ""
+	for _, data := range [][]byte{data, yamlData} {
+		decoded, err := UnmarshalQuery(data)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", data, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, &written) {
+			t.Errorf("For input '%s', expected %+v, got %+v", data, written, decoded)
+		}
+	}
+}
+
+func TestUnmarshalQuery(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		err      string
+	}{
+		{`{"apiVersion": "kubesql/v1", "from": "pods"}`, "FROM pods", ""},
+		{"apiVersion: kubesql/v1\nfrom: pods\norderBy:\n- field: name\n  direction: desc\nlimit: 5\n", "FROM pods ORDER BY name DESC LIMIT 5", ""},
+		{"apiVersion: kubesql/v1\nfrom: pods\norderBy:\n- field: name\n", "FROM pods ORDER BY name ASC", ""},
+		{"apiVersion: kubesql/v1\nfrom: pods\nfromAlias: p\njoins:\n- type: left\n  from: nodes\n  alias: n\n  on: p.spec.nodeName = n.name\n- from: services\n  on: services.name = p.name\n", "FROM pods p LEFT JOIN nodes n ON p.spec.nodeName = n.name JOIN services ON services.name = p.name", ""},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "joins": [{"type": "RIGHT", "from": "nodes", "on": "x"}]}`, "", "joins[0].type: must be INNER or LEFT, got 'RIGHT'"},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "joins": [{"from": "nodes", "on": "x = = 1"}]}`, "", "joins[0].on: "},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "joins": [{"from": "pods", "on": "x = 1"}]}`, "", "joins: table 'pods' is used twice"},
+		{"apiVersion: kubesql/v1\nwith:\n- name: workers\n  query: FROM nodes WHERE labels.role = 'worker'\nfrom: workers\n", "WITH workers AS (FROM nodes WHERE labels.role = 'worker') FROM workers", ""},
+		{`{"apiVersion": "kubesql/v1", "with": [{"query": "FROM nodes"}], "from": "pods"}`, "", "with[0].name is required"},
+		{`{"apiVersion": "kubesql/v1", "with": [{"name": "a", "query": "FROM nodes"}, {"name": "A", "query": "FROM pods"}], "from": "a"}`, "", "with[1].name: common table expression 'A' is defined twice"},
+		{`{"apiVersion": "kubesql/v1", "with": [{"name": "a"}], "from": "a"}`, "", "with[0].query is required"},
+		{`{"apiVersion": "kubesql/v1", "with": [{"name": "a", "query": "FROM"}], "from": "a"}`, "", "with[0].query: "},
+		{"apiVersion: kubesql/v1\nselect:\n- field: name\nfrom: deployments\ncompound:\n- op: except\n  all: true\n  query: SELECT name FROM poddisruptionbudgets\norderBy:\n- field: name\nlimit: 5\n", "SELECT name FROM deployments EXCEPT ALL SELECT name FROM poddisruptionbudgets ORDER BY name ASC LIMIT 5", ""},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "compound": [{"op": "MINUS", "query": "FROM nodes"}]}`, "", "compound[0].op: must be UNION, INTERSECT or EXCEPT, got 'MINUS'"},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "compound": [{"op": "UNION"}]}`, "", "compound[0].query is required"},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "compound": [{"op": "UNION", "query": "FROM nodes LIMIT 1"}]}`, "", "compound[0].query: query after UNION must be a plain query without ORDER BY or LIMIT"},
+		{`{"from": "pods"}`, "", "apiVersion is required"},
+		{`{"apiVersion": "kubesql/v2", "from": "pods"}`, "", "unsupported apiVersion 'kubesql/v2'"},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "filter": "x"}`, "", `unknown field "filter"`},
+		{"apiVersion: kubesql/v1\nfrom: pods\nfilter: x\n", "", "field filter not found"},
+		{`{"apiVersion": "kubesql/v1"}`, "", "from is required"},
+		{`{"apiVersion": "kubesql/v1", "from": "a/b/c"}`, "", "from: "},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "where": "x = = 1"}`, "", "where: "},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "select": [{"field": ""}]}`, "", "select[0].field: "},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "orderBy": [{"field": "x", "direction": "UP"}]}`, "", "orderBy[0].direction: must be ASC or DESC"},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "limit": -2}`, "", "limit: must be -1"},
+		{`{"apiVersion": "kubesql/v1", "from": "pods", "limit": "5"}`, "", "error decoding query"},
+		{`{"apiVersion": "kubesql/v1", "from": "pods"}{"garbage"`, "", "unexpected data after the query"},
+		{`{"apiVersion": "kubesql/v1", "from": "pods"}}`, "", "unexpected data after the query"},
+		{"apiVersion: kubesql/v1\nfrom: pods\n---\nfrom: nodes\n", "", "unexpected data after the query"},
+		{"{\"apiVersion\": \"kubesql/v1\", \"from\": \"pods\"}\n\n", "FROM pods", ""},
+		{"apiVersion: kubesql/v1\nfrom: pods\n---\n", "FROM pods", ""},
+	}
+
+	for _, tc := range testCases {
+		query, err := UnmarshalQuery([]byte(tc.input))
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("For input '%s', expected error containing '%s', got %v", tc.input, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if query.String() != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got '%s'", tc.input, tc.expected, query.String())
+		}
+	}
+}
+
+func TestQueryJSONSchema(t *testing.T) {
+	// The published schema must be regenerated with make schema
+	published, err := os.ReadFile("../../api/query.schema.json")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(published, QueryJSONSchema()) {
+		t.Errorf("Expected api/query.schema.json to match QueryJSONSchema(), run make schema")
+	}
+
+	var schema struct {
+		Required   []string                   `json:"required"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(QueryJSONSchema(), &schema); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !reflect.DeepEqual(schema.Required, []string{"apiVersion", "from"}) {
+		t.Errorf("Expected required fields [apiVersion from], got %v", schema.Required)
+	}
+	for _, name := range []string{"apiVersion", "select", "from", "where", "orderBy", "limit", "pos", "comments", "columns", "explain"} {
+		if _, ok := schema.Properties[name]; !ok {
+			t.Errorf("Expected property %s in the schema", name)
+		}
+	}
+}
+
+func TestUnmarshalQueries(t *testing.T) {
+	queries, err := ParseScript("SELECT name FROM pods WHERE x = 1; FROM default/services LIMIT 3")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, query := range queries {
+		query.Source = "" // Not serialized
+	}
+	jsonData, _ := json.Marshal(queries)
+	yamlData, _ := yaml.Marshal(queries)
+	singleJSON, _ := json.Marshal(queries[0])
+	singleYAML, _ := yaml.Marshal(queries[0])
+	stream := append(append(append([]byte{}, singleYAML...), "---\n"...), mustMarshalYAML(t, queries[1])...)
+
+	testCases := []struct {
+		input    []byte
+		expected []*Query
+	}{
+		{jsonData, queries},
+		{yamlData, queries},
+		{singleJSON, queries[:1]},
+		{singleYAML, queries[:1]},
+		{stream, queries},
+	}
+
+	for _, tc := range testCases {
+		result, err := UnmarshalQueries(tc.input)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, result)
+		}
+	}
+
+	errorCases := []struct {
+		input string
+		err   string
+	}{
+		{"", "no queries found"},
+		{"[]", "no queries found"},
+		{`[{"apiVersion": "kubesql/v1", "from": "pods"}, {"from": "pods"}]`, "query 2: invalid query: apiVersion is required"},
+		{"- apiVersion: kubesql/v1\n  from: pods\n  limit: x\n", "query 1: error decoding query"},
+		{"[1, ", "error decoding queries"},
+	}
+	for _, tc := range errorCases {
+		if _, err := UnmarshalQueries([]byte(tc.input)); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("For input '%s', expected error containing '%s', got %v", tc.input, tc.err, err)
+		}
+	}
+}
+
+func mustMarshalYAML(t *testing.T, value any) []byte {
+	t.Helper()
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return data
+}
@@ -0,0 +1,704 @@
+// Package kubesqlpb holds the Protocol Buffers form of parsed KubeSQL
+// queries, generated from api/kubesql/v1/query.proto, and the converters
+// between it and kubesql.Query.
+package kubesqlpb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// binaryOps maps the operators of kubesql.BinaryExpr to their enum values.
+var binaryOps = map[string]BinaryOp{
+	"AND": BinaryOp_BINARY_OP_AND, "OR": BinaryOp_BINARY_OP_OR,
+	"=": BinaryOp_BINARY_OP_EQ, "!=": BinaryOp_BINARY_OP_NE,
+	"<": BinaryOp_BINARY_OP_LT, "<=": BinaryOp_BINARY_OP_LE,
+	">": BinaryOp_BINARY_OP_GT, ">=": BinaryOp_BINARY_OP_GE,
+	"LIKE": BinaryOp_BINARY_OP_LIKE, "ILIKE": BinaryOp_BINARY_OP_ILIKE,
+	"~=": BinaryOp_BINARY_OP_MATCH, "!~": BinaryOp_BINARY_OP_NOT_MATCH,
+	"+": BinaryOp_BINARY_OP_ADD, "-": BinaryOp_BINARY_OP_SUB,
+	"*": BinaryOp_BINARY_OP_MUL, "/": BinaryOp_BINARY_OP_DIV, "%": BinaryOp_BINARY_OP_MOD,
+}
+
+// unaryOps maps the operators of kubesql.UnaryExpr to their enum values.
+var unaryOps = map[string]UnaryOp{
+	"NOT": UnaryOp_UNARY_OP_NOT,
+	"-":   UnaryOp_UNARY_OP_NEG,
+}
+
+// setOperators maps the set operations of kubesql.SetOperation to their
+// enum values.
+var setOperators = map[string]SetOperator{
+	kubesql.UnionKeyword:     SetOperator_SET_OPERATOR_UNION,
+	kubesql.IntersectKeyword: SetOperator_SET_OPERATOR_INTERSECT,
+	kubesql.ExceptKeyword:    SetOperator_SET_OPERATOR_EXCEPT,
+}
+
+// FromQuery converts a query into its protobuf form. The clauses are parsed
+// into syntax trees, so the whitespace and keyword case of the query text
+// are not kept, except in the text of the select items.
+func FromQuery(q *kubesql.Query) (*Query, error) {
+	s, err := kubesql.NewStatement(q)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Query{
+		From:    fromFrom(s.From),
+		Explain: q.Explain,
+		Pos:     fromPosition(q.Pos),
+	}
+	for i, cte := range s.With {
+		query, err := FromQuery(cte.Query.Query())
+		if err != nil {
+			return nil, fmt.Errorf("with[%d]: %w", i, err)
+		}
+		m.With = append(m.With, &CTE{Name: cte.Name, Query: query})
+	}
+	for i, join := range s.Joins {
+		on, err := FromExpr(join.On)
+		if err != nil {
+			return nil, fmt.Errorf("joins[%d].on: %w", i, err)
+		}
+		joinType := JoinType_JOIN_TYPE_INNER
+		if join.Type == kubesql.LeftJoin {
+			joinType = JoinType_JOIN_TYPE_LEFT
+		}
+		m.Joins = append(m.Joins, &Join{Type: joinType, From: fromFrom(join.From), On: on})
+	}
+	if s.Select != nil {
+		for i, field := range s.Select.Fields {
+			expr, err := FromExpr(field.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("select[%d]: %w", i, err)
+			}
+			item := &SelectItem{Expr: expr, Alias: field.Alias}
+			if i < len(q.Select) {
+				item.Text = string(q.Select[i].Field)
+			}
+			m.Select = append(m.Select, item)
+		}
+	}
+	if s.Where != nil {
+		if m.Where, err = FromExpr(s.Where.Cond); err != nil {
+			return nil, fmt.Errorf("where: %w", err)
+		}
+	}
+	for i, c := range s.Compound {
+		query, err := FromQuery(c.Query.Query())
+		if err != nil {
+			return nil, fmt.Errorf("compound[%d]: %w", i, err)
+		}
+		m.Compound = append(m.Compound, &SetOperation{Op: setOperators[c.Op], All: c.All, Query: query})
+	}
+	if s.OrderBy != nil {
+		for i, key := range s.OrderBy.Keys {
+			expr, err := FromExpr(key.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("orderBy[%d]: %w", i, err)
+			}
+			direction := SortDirection_SORT_DIRECTION_ASC
+			if strings.EqualFold(key.Direction, "DESC") {
+				direction = SortDirection_SORT_DIRECTION_DESC
+			}
+			m.OrderBy = append(m.OrderBy, &OrderByItem{Expr: expr, Direction: direction})
+		}
+	}
+	if q.Limit != kubesql.DefaultLimit {
+		m.Limit = proto.Int64(int64(q.Limit))
+	}
+	for _, comment := range q.Comments {
+		m.Comments = append(m.Comments, &Comment{Text: comment.Text, Pos: fromPosition(comment.Pos)})
+	}
+	for _, column := range q.Columns {
+		m.Columns = append(m.Columns, &Column{Name: column.Name, Type: Type(column.Type)})
+	}
+	return m, nil
+}
+
+// ToQuery converts the protobuf form of a query back into a query, with its
+// clauses written in canonical form. Expressions get the parentheses the
+// precedence of their operators requires. Select fields keep their text, so
+// their result columns keep their names.
+func ToQuery(m *Query) (*kubesql.Query, error) {
+	if m.GetFrom().GetResource() == "" {
+		return nil, errors.New("from is required")
+	}
+
+	s := &kubesql.Statement{
+		From:    toFrom(m.From),
+		Limit:   kubesql.DefaultLimit,
+		Explain: m.Explain,
+		Pos:     toPosition(m.Pos),
+	}
+	for i, cte := range m.With {
+		query, err := toCTE(cte)
+		if err != nil {
+			return nil, fmt.Errorf("with[%d]: %w", i, err)
+		}
+		s.With = append(s.With, &kubesql.CommonTable{Name: cte.Name, Query: query})
+	}
+	for i, join := range m.Joins {
+		if join.GetFrom().GetResource() == "" {
+			return nil, fmt.Errorf("joins[%d].from is required", i)
+		}
+		if join.On == nil {
+			return nil, fmt.Errorf("joins[%d].on is required", i)
+		}
+		on, err := ToExpr(join.On)
+		if err != nil {
+			return nil, fmt.Errorf("joins[%d].on: %w", i, err)
+		}
+		var joinType string
+		switch join.Type {
+		case JoinType_JOIN_TYPE_UNSPECIFIED, JoinType_JOIN_TYPE_INNER:
+			joinType = kubesql.InnerJoin
+		case JoinType_JOIN_TYPE_LEFT:
+			joinType = kubesql.LeftJoin
+		default:
+			return nil, fmt.Errorf("joins[%d]: invalid type %d", i, join.Type)
+		}
+		s.Joins = append(s.Joins, &kubesql.JoinClause{Type: joinType, From: toFrom(join.From), On: on})
+	}
+	if len(m.Select) > 0 {
+		s.Select = &kubesql.SelectClause{}
+		for i, field := range m.Select {
+			expr, err := ToExpr(field.GetExpr())
+			if err != nil {
+				return nil, fmt.Errorf("select[%d]: %w", i, err)
+			}
+			s.Select.Fields = append(s.Select.Fields, &kubesql.SelectItem{Expr: expr, Alias: field.Alias})
+		}
+	}
+	if m.Where != nil {
+		cond, err := ToExpr(m.Where)
+		if err != nil {
+			return nil, fmt.Errorf("where: %w", err)
+		}
+		s.Where = &kubesql.WhereClause{Cond: cond}
+	}
+	for i, op := range m.Compound {
+		c, err := toCompound(op)
+		if err != nil {
+			return nil, fmt.Errorf("compound[%d]: %w", i, err)
+		}
+		s.Compound = append(s.Compound, c)
+	}
+	if len(m.OrderBy) > 0 {
+		s.OrderBy = &kubesql.OrderByClause{}
+		for i, key := range m.OrderBy {
+			expr, err := ToExpr(key.GetExpr())
+			if err != nil {
+				return nil, fmt.Errorf("orderBy[%d]: %w", i, err)
+			}
+			var direction string
+			switch key.Direction {
+			case SortDirection_SORT_DIRECTION_UNSPECIFIED, SortDirection_SORT_DIRECTION_ASC:
+				direction = "ASC"
+			case SortDirection_SORT_DIRECTION_DESC:
+				direction = "DESC"
+			default:
+				return nil, fmt.Errorf("orderBy[%d]: invalid direction %d", i, key.Direction)
+			}
+			s.OrderBy.Keys = append(s.OrderBy.Keys, &kubesql.OrderByItem{Expr: expr, Direction: direction})
+		}
+	}
+	if m.Limit != nil {
+		if *m.Limit < 0 || *m.Limit > math.MaxInt32 {
+			return nil, fmt.Errorf("invalid limit %d", *m.Limit)
+		}
+		s.Limit = int(*m.Limit)
+	}
+	for _, comment := range m.Comments {
+		s.Comments = append(s.Comments, kubesql.Comment{Text: comment.Text, Pos: toPosition(comment.Pos)})
+	}
+
+	q := s.Query()
+
+	// Select fields keep the text they were written with, which names their
+	// columns, when it still is the converted expression
+	for i, field := range m.Select {
+		if field.Text == "" || i >= len(q.Select) {
+			continue
+		}
+		if expr, err := kubesql.ParseExpr(kubesql.TSLQuery(field.Text)); err == nil && expr.String() == string(q.Select[i].Field) {
+			q.Select[i].Field = kubesql.TSLQuery(field.Text)
+		}
+	}
+	for i, column := range m.Columns {
+		if _, ok := Type_name[int32(column.Type)]; !ok {
+			return nil, fmt.Errorf("columns[%d]: invalid type %d", i, column.Type)
+		}
+		q.Columns = append(q.Columns, kubesql.Column{Name: column.Name, Type: kubesql.Type(column.Type)})
+	}
+	return q, nil
+}
+
+// fromFrom converts the resource of a FROM clause or join.
+func fromFrom(c *kubesql.FromClause) *From {
+	return &From{Clusters: c.Clusters, Namespace: c.Namespace, Resource: c.Resource, Alias: c.Alias}
+}
+
+// toFrom converts the resource of a FROM clause or join back.
+func toFrom(m *From) *kubesql.FromClause {
+	return &kubesql.FromClause{Clusters: m.Clusters, Namespace: m.Namespace, Resource: m.Resource, Alias: m.Alias}
+}
+
+// fromPosition converts a position, returning nil for the zero position.
+func fromPosition(pos kubesql.Position) *Position {
+	if pos == (kubesql.Position{}) {
+		return nil
+	}
+	return &Position{Offset: int32(pos.Offset), Line: int32(pos.Line), Column: int32(pos.Column)}
+}
+
+// toPosition converts a position, nil is the zero position.
+func toPosition(pos *Position) kubesql.Position {
+	return kubesql.Position{Offset: int(pos.GetOffset()), Line: int(pos.GetLine()), Column: int(pos.GetColumn())}
+}
+
+// FromExpr converts an expression into its protobuf form. Number literals
+// get a typed value, and keep their text.
+func FromExpr(expr kubesql.Expr) (*Expr, error) {
+	switch e := expr.(type) {
+	case *kubesql.FieldRef:
+		path := &FieldPath{}
+		for _, segment := range e.Path {
+			switch {
+			case segment.Array && segment.Index < 0:
+				path.Segments = append(path.Segments, &Segment{Segment: &Segment_Wildcard{Wildcard: true}})
+			case segment.Array:
+				path.Segments = append(path.Segments, &Segment{Segment: &Segment_Index{Index: int32(segment.Index)}})
+			default:
+				path.Segments = append(path.Segments, &Segment{Segment: &Segment_Name{Name: segment.Name}})
+			}
+		}
+		return &Expr{Expr: &Expr_Field{Field: path}}, nil
+	case *kubesql.Literal:
+		literal, err := fromLiteral(e)
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Expr: &Expr_Literal{Literal: literal}}, nil
+	case *kubesql.StarExpr:
+		return &Expr{Expr: &Expr_Star{Star: &Star{}}}, nil
+	case *kubesql.ParenExpr:
+		x, err := FromExpr(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Expr: &Expr_Paren{Paren: &ParenExpr{X: x}}}, nil
+	case *kubesql.UnaryExpr:
+		op, ok := unaryOps[e.Op]
+		if !ok {
+			return nil, fmt.Errorf("unknown unary operator '%s'", e.Op)
+		}
+		x, err := FromExpr(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Expr: &Expr_Unary{Unary: &UnaryExpr{Op: op, X: x}}}, nil
+	case *kubesql.BinaryExpr:
+		op, ok := binaryOps[e.Op]
+		if !ok {
+			return nil, fmt.Errorf("unknown binary operator '%s'", e.Op)
+		}
+		x, err := FromExpr(e.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := FromExpr(e.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Expr: &Expr_Binary{Binary: &BinaryExpr{Op: op, X: x, Y: y}}}, nil
+	case *kubesql.InExpr:
+		x, err := FromExpr(e.X)
+		if err != nil {
+			return nil, err
+		}
+		if e.Subquery != nil {
+			subquery, err := FromQuery(e.Subquery.Query())
+			if err != nil {
+				return nil, err
+			}
+			return &Expr{Expr: &Expr_In{In: &InExpr{X: x, Subquery: subquery, Not: e.Not}}}, nil
+		}
+		list, err := fromExprs(e.List)
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Expr: &Expr_In{In: &InExpr{X: x, List: list, Not: e.Not}}}, nil
+	case *kubesql.ExistsExpr:
+		subquery, err := FromQuery(e.Subquery.Query())
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Expr: &Expr_Exists{Exists: &ExistsExpr{Subquery: subquery}}}, nil
+	case *kubesql.BetweenExpr:
+		exprs, err := fromExprs([]kubesql.Expr{e.X, e.Low, e.High})
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Expr: &Expr_Between{Between: &BetweenExpr{X: exprs[0], Low: exprs[1], High: exprs[2], Not: e.Not}}}, nil
+	case *kubesql.IsNullExpr:
+		x, err := FromExpr(e.X)
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Expr: &Expr_IsNull{IsNull: &IsNullExpr{X: x, Not: e.Not}}}, nil
+	case *kubesql.CallExpr:
+		args, err := fromExprs(e.Args)
+		if err != nil {
+			return nil, err
+		}
+		return &Expr{Expr: &Expr_Call{Call: &CallExpr{Func: e.Func, Args: args}}}, nil
+	}
+	return nil, fmt.Errorf("unknown expression type %T", expr)
+}
+
+// fromExprs converts a list of expressions.
+func fromExprs(exprs []kubesql.Expr) ([]*Expr, error) {
+	result := make([]*Expr, len(exprs))
+	for i, expr := range exprs {
+		m, err := FromExpr(expr)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = m
+	}
+	return result, nil
+}
+
+// fromLiteral converts a literal, typing numbers as ints, floats,
+// quantities or durations.
+func fromLiteral(l *kubesql.Literal) (*Literal, error) {
+	switch l.Kind {
+	case kubesql.StringLiteral:
+		return &Literal{Value: &Literal_StringValue{StringValue: l.Value}}, nil
+	case kubesql.BoolLiteral:
+		return &Literal{Value: &Literal_BoolValue{BoolValue: strings.EqualFold(l.Value, "TRUE")}}, nil
+	case kubesql.NullLiteral:
+		return &Literal{Value: &Literal_NullValue{NullValue: structpb.NullValue_NULL_VALUE}}, nil
+	}
+
+	t, err := kubesql.InferType(l, nil)
+	if err != nil {
+		return nil, err
+	}
+	literal := &Literal{Text: l.Value}
+	switch t {
+	case kubesql.TypeInt:
+		value, err := strconv.ParseInt(l.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", l.Value)
+		}
+		literal.Value = &Literal_IntValue{IntValue: value}
+	case kubesql.TypeFloat:
+		value, err := strconv.ParseFloat(l.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", l.Value)
+		}
+		literal.Value = &Literal_FloatValue{FloatValue: value}
+	case kubesql.TypeQuantity:
+		literal.Value = &Literal_QuantityValue{QuantityValue: &Quantity{Value: l.Value}}
+	case kubesql.TypeDuration:
+		value, err := time.ParseDuration(l.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration '%s'", l.Value)
+		}
+		literal.Value = &Literal_DurationValue{DurationValue: durationpb.New(value)}
+	default:
+		return nil, fmt.Errorf("invalid number '%s'", l.Value)
+	}
+	return literal, nil
+}
+
+// ToExpr converts the protobuf form of an expression back into an
+// expression.
+func ToExpr(m *Expr) (kubesql.Expr, error) {
+	switch e := m.GetExpr().(type) {
+	case *Expr_Field:
+		if len(e.Field.GetSegments()) == 0 {
+			return nil, errors.New("field path is empty")
+		}
+		field := &kubesql.FieldRef{}
+		for _, segment := range e.Field.Segments {
+			switch s := segment.GetSegment().(type) {
+			case *Segment_Name:
+				field.Path = append(field.Path, kubesql.Segment{Name: s.Name})
+			case *Segment_Index:
+				if s.Index < 0 {
+					return nil, fmt.Errorf("invalid array index %d", s.Index)
+				}
+				field.Path = append(field.Path, kubesql.Segment{Index: int(s.Index), Array: true})
+			case *Segment_Wildcard:
+				field.Path = append(field.Path, kubesql.Segment{Index: -1, Array: true})
+			default:
+				return nil, errors.New("field path segment is not set")
+			}
+		}
+		if field.Path[0].Array {
+			return nil, errors.New("field path must start with a name")
+		}
+		return field, nil
+	case *Expr_Literal:
+		return toLiteral(e.Literal)
+	case *Expr_Star:
+		return &kubesql.StarExpr{}, nil
+	case *Expr_Paren:
+		x, err := ToExpr(e.Paren.GetX())
+		if err != nil {
+			return nil, err
+		}
+		return &kubesql.ParenExpr{X: x}, nil
+	case *Expr_Unary:
+		var op string
+		for name, value := range unaryOps {
+			if value == e.Unary.GetOp() {
+				op = name
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("invalid unary operator %d", e.Unary.GetOp())
+		}
+		x, err := ToExpr(e.Unary.GetX())
+		if err != nil {
+			return nil, err
+		}
+		return &kubesql.UnaryExpr{Op: op, X: x}, nil
+	case *Expr_Binary:
+		var op string
+		for name, value := range binaryOps {
+			if value == e.Binary.GetOp() {
+				op = name
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("invalid binary operator %d", e.Binary.GetOp())
+		}
+		exprs, err := toExprs([]*Expr{e.Binary.GetX(), e.Binary.GetY()})
+		if err != nil {
+			return nil, err
+		}
+		return &kubesql.BinaryExpr{Op: op, X: exprs[0], Y: exprs[1]}, nil
+	case *Expr_In:
+		x, err := ToExpr(e.In.GetX())
+		if err != nil {
+			return nil, err
+		}
+		if e.In.Subquery != nil {
+			if len(e.In.List) > 0 {
+				return nil, errors.New("IN has both a list and a subquery")
+			}
+			subquery, err := toSubquery(e.In.Subquery)
+			if err != nil {
+				return nil, err
+			}
+			return &kubesql.InExpr{X: x, Subquery: subquery, Not: e.In.Not}, nil
+		}
+		if len(e.In.GetList()) == 0 {
+			return nil, errors.New("IN list is empty")
+		}
+		list, err := toExprs(e.In.List)
+		if err != nil {
+			return nil, err
+		}
+		return &kubesql.InExpr{X: x, List: list, Not: e.In.Not}, nil
+	case *Expr_Between:
+		exprs, err := toExprs([]*Expr{e.Between.GetX(), e.Between.GetLow(), e.Between.GetHigh()})
+		if err != nil {
+			return nil, err
+		}
+		return &kubesql.BetweenExpr{X: exprs[0], Low: exprs[1], High: exprs[2], Not: e.Between.Not}, nil
+	case *Expr_IsNull:
+		x, err := ToExpr(e.IsNull.GetX())
+		if err != nil {
+			return nil, err
+		}
+		return &kubesql.IsNullExpr{X: x, Not: e.IsNull.Not}, nil
+	case *Expr_Exists:
+		if e.Exists.GetSubquery() == nil {
+			return nil, errors.New("EXISTS subquery is required")
+		}
+		subquery, err := toSubquery(e.Exists.Subquery)
+		if err != nil {
+			return nil, err
+		}
+		return &kubesql.ExistsExpr{Subquery: subquery}, nil
+	case *Expr_Call:
+		if e.Call.GetFunc() == "" {
+			return nil, errors.New("function name is empty")
+		}
+		args, err := toExprs(e.Call.Args)
+		if err != nil {
+			return nil, err
+		}
+		return &kubesql.CallExpr{Func: e.Call.Func, Args: args}, nil
+	}
+	return nil, errors.New("expression is not set")
+}
+
+// toSubquery converts the protobuf form of a subquery back into a
+// statement.
+func toSubquery(m *Query) (*kubesql.Statement, error) {
+	if m.Explain {
+		return nil, errors.New("subquery can not be explained")
+	}
+	if len(m.With) > 0 {
+		return nil, errors.New("subquery can not have common table expressions")
+	}
+	if len(m.Compound) > 0 {
+		return nil, errors.New("subquery can not have set operations")
+	}
+	q, err := ToQuery(m)
+	if err != nil {
+		return nil, fmt.Errorf("subquery: %w", err)
+	}
+	return kubesql.NewStatement(q)
+}
+
+// toCTE converts the query of a common table expression into a statement.
+func toCTE(m *CTE) (*kubesql.Statement, error) {
+	switch {
+	case m.Name == "":
+		return nil, errors.New("name is required")
+	case m.Query == nil:
+		return nil, errors.New("query is required")
+	case m.Query.Explain:
+		return nil, errors.New("common table expression can not be explained")
+	case len(m.Query.With) > 0:
+		return nil, errors.New("common table expression can not have common table expressions")
+	case len(m.Query.Compound) > 0:
+		return nil, errors.New("common table expression can not have set operations")
+	}
+	q, err := ToQuery(m.Query)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	return kubesql.NewStatement(q)
+}
+
+// toCompound converts a set operation into the query it combines.
+func toCompound(m *SetOperation) (*kubesql.CompoundQuery, error) {
+	var op string
+	switch m.Op {
+	case SetOperator_SET_OPERATOR_UNSPECIFIED, SetOperator_SET_OPERATOR_UNION:
+		op = kubesql.UnionKeyword
+	case SetOperator_SET_OPERATOR_INTERSECT:
+		op = kubesql.IntersectKeyword
+	case SetOperator_SET_OPERATOR_EXCEPT:
+		op = kubesql.ExceptKeyword
+	default:
+		return nil, fmt.Errorf("invalid op %d", m.Op)
+	}
+	switch {
+	case m.Query == nil:
+		return nil, errors.New("query is required")
+	case m.Query.Explain:
+		return nil, errors.New("combined query can not be explained")
+	case len(m.Query.With) > 0:
+		return nil, errors.New("combined query can not have common table expressions")
+	case len(m.Query.Compound) > 0:
+		return nil, errors.New("combined query can not have set operations")
+	case len(m.Query.OrderBy) > 0, m.Query.Limit != nil:
+		return nil, errors.New("combined query can not have order_by or limit, they apply to the combined rows")
+	}
+	q, err := ToQuery(m.Query)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	s, err := kubesql.NewStatement(q)
+	if err != nil {
+		return nil, err
+	}
+	return &kubesql.CompoundQuery{Op: op, All: m.All, Query: s}, nil
+}
+
+// toExprs converts a list of expressions.
+func toExprs(exprs []*Expr) ([]kubesql.Expr, error) {
+	result := make([]kubesql.Expr, len(exprs))
+	for i, m := range exprs {
+		expr, err := ToExpr(m)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = expr
+	}
+	return result, nil
+}
+
+// toLiteral converts a literal. Numbers are written as their text when it
+// is set and has the type of the value, and in canonical form otherwise;
+// negative numbers become a unary minus.
+func toLiteral(m *Literal) (kubesql.Expr, error) {
+	var text string
+	var t kubesql.Type
+	switch v := m.GetValue().(type) {
+	case *Literal_StringValue:
+		return &kubesql.Literal{Kind: kubesql.StringLiteral, Value: v.StringValue}, nil
+	case *Literal_BoolValue:
+		if v.BoolValue {
+			return &kubesql.Literal{Kind: kubesql.BoolLiteral, Value: "TRUE"}, nil
+		}
+		return &kubesql.Literal{Kind: kubesql.BoolLiteral, Value: "FALSE"}, nil
+	case *Literal_NullValue:
+		return &kubesql.Literal{Kind: kubesql.NullLiteral, Value: "NULL"}, nil
+	case *Literal_IntValue:
+		text, t = strconv.FormatInt(v.IntValue, 10), kubesql.TypeInt
+	case *Literal_FloatValue:
+		if math.IsInf(v.FloatValue, 0) || math.IsNaN(v.FloatValue) {
+			return nil, fmt.Errorf("invalid float %v", v.FloatValue)
+		}
+		text, t = strconv.FormatFloat(v.FloatValue, 'f', -1, 64), kubesql.TypeFloat
+		if !strings.Contains(text, ".") {
+			text += ".0"
+		}
+	case *Literal_QuantityValue:
+		text, t = v.QuantityValue.GetValue(), kubesql.TypeQuantity
+	case *Literal_DurationValue:
+		if err := v.DurationValue.CheckValid(); err != nil {
+			return nil, fmt.Errorf("invalid duration: %w", err)
+		}
+		text, t = durationText(v.DurationValue.AsDuration()), kubesql.TypeDuration
+	default:
+		return nil, errors.New("literal value is not set")
+	}
+	if m.Text != "" {
+		text = m.Text
+	}
+
+	negative := strings.HasPrefix(text, "-")
+	literal := &kubesql.Literal{Kind: kubesql.NumberLiteral, Value: strings.TrimPrefix(text, "-")}
+	if literalType, err := kubesql.InferType(literal, nil); err != nil || literalType != t {
+		return nil, fmt.Errorf("invalid %s '%s'", t, text)
+	}
+	if negative {
+		return &kubesql.UnaryExpr{Op: "-", X: literal}, nil
+	}
+	return literal, nil
+}
+
+// durationText writes a duration with a single unit, the only form the
+// lexer reads as one number. Minutes are written in seconds, as "m" is the
+// milli quantity suffix.
+func durationText(d time.Duration) string {
+	for _, unit := range []struct {
+		size time.Duration
+		name string
+	}{{time.Hour, "h"}, {time.Second, "s"}, {time.Millisecond, "ms"}} {
+		if d%unit.size == 0 {
+			return strconv.FormatInt(int64(d/unit.size), 10) + unit.name
+		}
+	}
+	return strconv.FormatInt(int64(d), 10) + "ns"
+}
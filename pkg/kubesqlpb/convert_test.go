@@ -0,0 +1,298 @@
+package kubesqlpb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestQueryRoundTrip(t *testing.T) {
+	testCases := []string{
+		"SELECT name, status.phase AS phase FROM default/pods WHERE status.phase = 'Running' ORDER BY phase DESC, name LIMIT 10",
+		"EXPLAIN SELECT * FROM `my-ns`/deployments.apps",
+		"SELECT cluster, name FROM prod-*,`arn:aws:eks:eu`:kube-system/pods ORDER BY cluster",
+		"SELECT p.name, n.name FROM pods p JOIN nodes n ON p.spec.nodeName = n.name LEFT JOIN default/services s ON s.labels.app = p.labels.app AND s.namespace = p.namespace",
+		"FROM pods WHERE NOT (a = 1 OR b != 2) AND c BETWEEN 1 AND 10 AND d NOT IN ('x', 'y') AND e IS NOT NULL",
+		"SELECT count(*), sum(spec.replicas) * 2 FROM deployments WHERE labels.`app.kubernetes.io/name` ~= '^web' LIMIT 0",
+		"SELECT spec.containers[0].image FROM pods WHERE spec.containers[*].resources.limits.memory > 512Mi AND age < 90s",
+		"SELECT name FROM pods WHERE x = 1.50 AND y = -3 AND z = - -2 AND w = 500m AND v = TRUE AND u = NULL -- note",
+		"SELECT (a + b) * c - d % 3 FROM pods WHERE name ILIKE 'web%' AND name NOT LIKE '%db' AND x !~ 'a' /* tail */",
+		"WITH workers AS (SELECT name FROM nodes WHERE labels.role = 'worker'), placed AS (SELECT name FROM pods WHERE spec.nodeName IN (SELECT name FROM workers)) SELECT count(*) FROM placed",
+		"SELECT name FROM pods p WHERE spec.nodeName NOT IN (SELECT name FROM nodes WHERE labels.role = 'worker' LIMIT 3) OR EXISTS (SELECT s.name FROM services s WHERE s.spec.selector.app = p.labels.app)",
+		"WITH a AS (FROM pods) SELECT name FROM deployments UNION ALL SELECT name FROM a INTERSECT SELECT name FROM pods WHERE name IN (SELECT name FROM a) EXCEPT SELECT name FROM nodes ORDER BY name DESC LIMIT 3",
+	}
+
+	for _, input := range testCases {
+		query, err := kubesql.NewParser(input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		query.Columns = []kubesql.Column{{Name: "name", Type: kubesql.TypeString}, {Name: "age", Type: kubesql.TypeDuration}}
+
+		m, err := FromQuery(query)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", input, err)
+			continue
+		}
+		data, err := proto.Marshal(m)
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		decoded := &Query{}
+		if err := proto.Unmarshal(data, decoded); err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+
+		result, err := ToQuery(decoded)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", input, err)
+			continue
+		}
+		s, _ := kubesql.NewStatement(query)
+		expected := s.Query()
+		expected.Columns = query.Columns
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("For input '%s', expected %+v, got %+v", input, expected, result)
+		}
+
+		again, err := FromQuery(result)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", input, err)
+			continue
+		}
+		if !proto.Equal(again, m) {
+			t.Errorf("For input '%s', expected %v, got %v", input, m, again)
+		}
+	}
+}
+
+func TestQueryRoundTripText(t *testing.T) {
+	testCases := []string{
+		"SELECT name, ready = true FROM pods WHERE ready = false OR NOT true ORDER BY ready = true DESC, name",
+		"WITH a AS (FROM pods WHERE ok = true) SELECT name FROM a p JOIN nodes n ON n.name = p.name AND n.ready = false",
+		"SELECT name FROM pods WHERE name IN (SELECT name FROM pods WHERE ok = false) UNION SELECT name FROM nodes WHERE ok = true",
+	}
+
+	for _, input := range testCases {
+		query, err := kubesql.NewParser(input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		m, err := FromQuery(query)
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		result, err := ToQuery(m)
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		if result.String() != query.String() {
+			t.Errorf("For input '%s', expected '%s', got '%s'", input, query.String(), result.String())
+		}
+	}
+}
+
+func TestQueryRoundTripColumnNames(t *testing.T) {
+	testCases := []string{
+		"SELECT (a+b)*c, x=1, labels['app'], not a, len(name) AS n FROM pods",
+		"SELECT name,spec.replicas*2 FROM deployments WHERE spec.replicas>1",
+	}
+
+	validator := kubesql.NewValidator(kubesql.DefaultResolver(), nil)
+	dataset := kubesql.NewDataset()
+	for _, input := range testCases {
+		query, err := kubesql.NewParser(input).Parse()
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		m, err := FromQuery(query)
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		result, err := ToQuery(m)
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+
+		for i, field := range result.Select {
+			if field.Field != query.Select[i].Field {
+				t.Errorf("For input '%s', expected select field '%s', got '%s'", input, query.Select[i].Field, field.Field)
+			}
+		}
+		for _, q := range []*kubesql.Query{query, result} {
+			if err := validator.Validate(q); err != nil {
+				t.Fatalf("For input '%s', expected no error, got %v", input, err)
+			}
+		}
+		if !reflect.DeepEqual(result.Columns, query.Columns) {
+			t.Errorf("For input '%s', expected columns %v, got %v", input, query.Columns, result.Columns)
+		}
+
+		// Queries run without validation name their columns too
+		query.Columns, result.Columns = nil, nil
+		expected, err := dataset.Execute(query, kubesql.DefaultResolver())
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		got, err := dataset.Execute(result, kubesql.DefaultResolver())
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", input, err)
+		}
+		if !reflect.DeepEqual(got.Columns, expected.Columns) {
+			t.Errorf("For input '%s', expected columns %v, got %v", input, expected.Columns, got.Columns)
+		}
+	}
+}
+
+func TestFromQueryLiterals(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected *Literal
+	}{
+		{"'512Mi'", &Literal{Value: &Literal_StringValue{StringValue: "512Mi"}}},
+		{"10", &Literal{Value: &Literal_IntValue{IntValue: 10}, Text: "10"}},
+		{"1.50", &Literal{Value: &Literal_FloatValue{FloatValue: 1.5}, Text: "1.50"}},
+		{"FALSE", &Literal{Value: &Literal_BoolValue{BoolValue: false}}},
+		{"512Mi", &Literal{Value: &Literal_QuantityValue{QuantityValue: &Quantity{Value: "512Mi"}}, Text: "512Mi"}},
+		{"500m", &Literal{Value: &Literal_QuantityValue{QuantityValue: &Quantity{Value: "500m"}}, Text: "500m"}},
+		{"90s", &Literal{Value: &Literal_DurationValue{DurationValue: durationpb.New(90 * time.Second)}, Text: "90s"}},
+	}
+
+	for _, tc := range testCases {
+		expr, err := kubesql.ParseExpr(kubesql.TSLQuery(tc.input))
+		if err != nil {
+			t.Fatalf("For input '%s', expected no error, got %v", tc.input, err)
+		}
+		m, err := FromExpr(expr)
+		if err != nil {
+			t.Errorf("For input '%s', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if !proto.Equal(m.GetLiteral(), tc.expected) {
+			t.Errorf("For input '%s', expected %v, got %v", tc.input, tc.expected, m)
+		}
+	}
+
+	if _, err := FromExpr(&kubesql.Literal{Kind: kubesql.NumberLiteral, Value: "3days"}); err == nil {
+		t.Errorf("Expected an error for an invalid number")
+	}
+}
+
+func TestToQuery(t *testing.T) {
+	field := func(names ...string) *Expr {
+		path := &FieldPath{}
+		for _, name := range names {
+			path.Segments = append(path.Segments, &Segment{Segment: &Segment_Name{Name: name}})
+		}
+		return &Expr{Expr: &Expr_Field{Field: path}}
+	}
+	literal := func(l *Literal) *Expr {
+		return &Expr{Expr: &Expr_Literal{Literal: l}}
+	}
+	binary := func(op BinaryOp, x, y *Expr) *Expr {
+		return &Expr{Expr: &Expr_Binary{Binary: &BinaryExpr{Op: op, X: x, Y: y}}}
+	}
+	from := &From{Resource: "pods"}
+
+	testCases := []struct {
+		input    *Query
+		expected string
+		err      string
+	}{
+		{&Query{From: from}, "FROM pods", ""},
+		{&Query{From: &From{Namespace: "kube-system", Resource: "pods"}, Limit: proto.Int64(5)}, "FROM kube-system/pods LIMIT 5", ""},
+		{
+			&Query{From: from, Where: binary(BinaryOp_BINARY_OP_AND, binary(BinaryOp_BINARY_OP_OR, field("a"), field("b")), field("c"))},
+			"FROM pods WHERE (a OR b) AND c", "",
+		},
+		{
+			&Query{From: from, Where: binary(BinaryOp_BINARY_OP_EQ, field("x"), literal(&Literal{Value: &Literal_IntValue{IntValue: -5}}))},
+			"FROM pods WHERE x = -5", "",
+		},
+		{
+			&Query{From: from, Where: binary(BinaryOp_BINARY_OP_EQ, field("x"), literal(&Literal{Value: &Literal_FloatValue{FloatValue: 2}}))},
+			"FROM pods WHERE x = 2.0", "",
+		},
+		{
+			&Query{From: from, Where: binary(BinaryOp_BINARY_OP_GT, field("age"), literal(&Literal{Value: &Literal_DurationValue{DurationValue: durationpb.New(90 * time.Second)}}))},
+			"FROM pods WHERE age > 90s", "",
+		},
+		{
+			&Query{From: from, Where: binary(BinaryOp_BINARY_OP_GT, field("age"), literal(&Literal{Value: &Literal_DurationValue{DurationValue: durationpb.New(1500 * time.Nanosecond)}}))},
+			"FROM pods WHERE age > 1500ns", "",
+		},
+		{
+			&Query{From: from, Where: binary(BinaryOp_BINARY_OP_LT, field("age"), literal(&Literal{Value: &Literal_DurationValue{DurationValue: durationpb.New(-2 * time.Hour)}}))},
+			"FROM pods WHERE age < -2h", "",
+		},
+		{
+			&Query{From: from, Select: []*SelectItem{{Expr: field("name"), Alias: "n"}}, OrderBy: []*OrderByItem{{Expr: field("n")}}},
+			"SELECT name AS n FROM pods ORDER BY n ASC", "",
+		},
+		{&Query{}, "", "from is required"},
+		{&Query{From: from, Select: []*SelectItem{{}}}, "", "select[0]: expression is not set"},
+		{&Query{From: from, Where: &Expr{Expr: &Expr_Field{Field: &FieldPath{}}}}, "", "where: field path is empty"},
+		{&Query{From: from, Where: binary(BinaryOp_BINARY_OP_UNSPECIFIED, field("a"), field("b"))}, "", "where: invalid binary operator 0"},
+		{&Query{From: from, Where: literal(&Literal{Value: &Literal_QuantityValue{QuantityValue: &Quantity{Value: "5x"}}})}, "", "where: invalid quantity '5x'"},
+		{&Query{From: from, Where: literal(&Literal{Value: &Literal_IntValue{IntValue: 1}, Text: "1.5"})}, "", "where: invalid int '1.5'"},
+		{&Query{From: from, OrderBy: []*OrderByItem{{Expr: field("a"), Direction: 7}}}, "", "orderBy[0]: invalid direction 7"},
+		{&Query{From: from, Limit: proto.Int64(-1)}, "", "invalid limit -1"},
+		{&Query{From: from, Columns: []*Column{{Name: "a", Type: 99}}}, "", "columns[0]: invalid type 99"},
+		{
+			&Query{From: from, Where: &Expr{Expr: &Expr_In{In: &InExpr{X: field("a"), Subquery: &Query{From: &From{Resource: "nodes"}, Select: []*SelectItem{{Expr: field("name")}}}}}}},
+			"FROM pods WHERE a IN (SELECT name FROM nodes)", "",
+		},
+		{&Query{From: from, Where: &Expr{Expr: &Expr_Exists{Exists: &ExistsExpr{}}}}, "", "where: EXISTS subquery is required"},
+		{&Query{With: []*CTE{{Query: &Query{From: from}}}, From: from}, "", "with[0]: name is required"},
+		{&Query{With: []*CTE{{Name: "w"}}, From: from}, "", "with[0]: query is required"},
+		{&Query{With: []*CTE{{Name: "w", Query: &Query{From: from, Explain: true}}}, From: from}, "", "with[0]: common table expression can not be explained"},
+		{&Query{From: from, Compound: []*SetOperation{{Query: &Query{From: &From{Resource: "nodes"}}}}}, "FROM pods UNION FROM nodes", ""},
+		{&Query{From: from, Compound: []*SetOperation{{Op: 9, Query: &Query{From: from}}}}, "", "compound[0]: invalid op 9"},
+		{&Query{From: from, Compound: []*SetOperation{{Op: SetOperator_SET_OPERATOR_EXCEPT}}}, "", "compound[0]: query is required"},
+		{&Query{From: from, Compound: []*SetOperation{{Query: &Query{From: from, Limit: proto.Int64(1)}}}}, "", "compound[0]: combined query can not have order_by or limit, they apply to the combined rows"},
+		{&Query{From: from, Compound: []*SetOperation{{Query: &Query{}}}}, "", "compound[0]: query: from is required"},
+		{&Query{From: from, With: []*CTE{{Name: "w", Query: &Query{From: from, Compound: []*SetOperation{{Query: &Query{From: from}}}}}}}, "", "with[0]: common table expression can not have set operations"},
+		{&Query{From: from, Where: &Expr{Expr: &Expr_Exists{Exists: &ExistsExpr{Subquery: &Query{With: []*CTE{{Name: "w", Query: &Query{From: from}}}, From: from}}}}}, "", "where: subquery can not have common table expressions"},
+		{&Query{From: from, Where: &Expr{Expr: &Expr_Exists{Exists: &ExistsExpr{Subquery: &Query{}}}}}, "", "where: subquery: from is required"},
+		{&Query{From: from, Where: &Expr{Expr: &Expr_Exists{Exists: &ExistsExpr{Subquery: &Query{From: from, Explain: true}}}}}, "", "where: subquery can not be explained"},
+		{
+			&Query{From: from, Where: &Expr{Expr: &Expr_In{In: &InExpr{X: field("a"), List: []*Expr{field("b")}, Subquery: &Query{From: from}}}}},
+			"", "where: IN has both a list and a subquery",
+		},
+	}
+
+	for _, tc := range testCases {
+		query, err := ToQuery(tc.input)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("For input '%v', expected error containing '%s', got %v", tc.input, tc.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("For input '%v', expected no error, got %v", tc.input, err)
+			continue
+		}
+		if query.String() != tc.expected {
+			t.Errorf("For input '%v', expected '%s', got '%s'", tc.input, tc.expected, query.String())
+		}
+		if _, err := kubesql.NewParser(query.String()).Parse(); err != nil {
+			t.Errorf("For input '%v', expected '%s' to parse, got %v", tc.input, query.String(), err)
+		}
+	}
+}
+
+func TestTypeNames(t *testing.T) {
+	// Type values are converted by casting, so the enums must stay in step
+	for value, name := range Type_name {
+		expected := "TYPE_" + strings.ToUpper(kubesql.Type(value).String())
+		if name != expected {
+			t.Errorf("For type %d, expected %s, got %s", value, expected, name)
+		}
+	}
+}
@@ -0,0 +1,2519 @@
+// Protocol Buffers form of parsed KubeSQL queries.
+//
+// Queries carry their clauses as syntax trees, and literals carry typed
+// values, so quantities (512Mi) and durations (30s) are not confused with
+// strings. Regenerate the Go code with make proto.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: kubesql/v1/query.proto
+
+package kubesqlpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SetOperator is the operation of a SetOperation.
+type SetOperator int32
+
+const (
+	// Union, the default.
+	SetOperator_SET_OPERATOR_UNSPECIFIED SetOperator = 0
+	SetOperator_SET_OPERATOR_UNION       SetOperator = 1
+	// Keeps the rows of the first query found in the other.
+	SetOperator_SET_OPERATOR_INTERSECT SetOperator = 2
+	// Keeps the rows of the first query not found in the other.
+	SetOperator_SET_OPERATOR_EXCEPT SetOperator = 3
+)
+
+// Enum value maps for SetOperator.
+var (
+	SetOperator_name = map[int32]string{
+		0: "SET_OPERATOR_UNSPECIFIED",
+		1: "SET_OPERATOR_UNION",
+		2: "SET_OPERATOR_INTERSECT",
+		3: "SET_OPERATOR_EXCEPT",
+	}
+	SetOperator_value = map[string]int32{
+		"SET_OPERATOR_UNSPECIFIED": 0,
+		"SET_OPERATOR_UNION":       1,
+		"SET_OPERATOR_INTERSECT":   2,
+		"SET_OPERATOR_EXCEPT":      3,
+	}
+)
+
+func (x SetOperator) Enum() *SetOperator {
+	p := new(SetOperator)
+	*p = x
+	return p
+}
+
+func (x SetOperator) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SetOperator) Descriptor() protoreflect.EnumDescriptor {
+	return file_kubesql_v1_query_proto_enumTypes[0].Descriptor()
+}
+
+func (SetOperator) Type() protoreflect.EnumType {
+	return &file_kubesql_v1_query_proto_enumTypes[0]
+}
+
+func (x SetOperator) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SetOperator.Descriptor instead.
+func (SetOperator) EnumDescriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{0}
+}
+
+// JoinType is the type of a join.
+type JoinType int32
+
+const (
+	// Inner, the default.
+	JoinType_JOIN_TYPE_UNSPECIFIED JoinType = 0
+	JoinType_JOIN_TYPE_INNER       JoinType = 1
+	// Keeps the rows matching no object of the joined resource.
+	JoinType_JOIN_TYPE_LEFT JoinType = 2
+)
+
+// Enum value maps for JoinType.
+var (
+	JoinType_name = map[int32]string{
+		0: "JOIN_TYPE_UNSPECIFIED",
+		1: "JOIN_TYPE_INNER",
+		2: "JOIN_TYPE_LEFT",
+	}
+	JoinType_value = map[string]int32{
+		"JOIN_TYPE_UNSPECIFIED": 0,
+		"JOIN_TYPE_INNER":       1,
+		"JOIN_TYPE_LEFT":        2,
+	}
+)
+
+func (x JoinType) Enum() *JoinType {
+	p := new(JoinType)
+	*p = x
+	return p
+}
+
+func (x JoinType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (JoinType) Descriptor() protoreflect.EnumDescriptor {
+	return file_kubesql_v1_query_proto_enumTypes[1].Descriptor()
+}
+
+func (JoinType) Type() protoreflect.EnumType {
+	return &file_kubesql_v1_query_proto_enumTypes[1]
+}
+
+func (x JoinType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use JoinType.Descriptor instead.
+func (JoinType) EnumDescriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{1}
+}
+
+// SortDirection is the direction of a sort key.
+type SortDirection int32
+
+const (
+	// Ascending, the default.
+	SortDirection_SORT_DIRECTION_UNSPECIFIED SortDirection = 0
+	SortDirection_SORT_DIRECTION_ASC         SortDirection = 1
+	SortDirection_SORT_DIRECTION_DESC        SortDirection = 2
+)
+
+// Enum value maps for SortDirection.
+var (
+	SortDirection_name = map[int32]string{
+		0: "SORT_DIRECTION_UNSPECIFIED",
+		1: "SORT_DIRECTION_ASC",
+		2: "SORT_DIRECTION_DESC",
+	}
+	SortDirection_value = map[string]int32{
+		"SORT_DIRECTION_UNSPECIFIED": 0,
+		"SORT_DIRECTION_ASC":         1,
+		"SORT_DIRECTION_DESC":        2,
+	}
+)
+
+func (x SortDirection) Enum() *SortDirection {
+	p := new(SortDirection)
+	*p = x
+	return p
+}
+
+func (x SortDirection) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SortDirection) Descriptor() protoreflect.EnumDescriptor {
+	return file_kubesql_v1_query_proto_enumTypes[2].Descriptor()
+}
+
+func (SortDirection) Type() protoreflect.EnumType {
+	return &file_kubesql_v1_query_proto_enumTypes[2]
+}
+
+func (x SortDirection) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SortDirection.Descriptor instead.
+func (SortDirection) EnumDescriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{2}
+}
+
+// Type is the type of expression values.
+type Type int32
+
+const (
+	// Not known statically, e.g. a field without a schema.
+	Type_TYPE_UNKNOWN   Type = 0
+	Type_TYPE_STRING    Type = 1
+	Type_TYPE_INT       Type = 2
+	Type_TYPE_FLOAT     Type = 3
+	Type_TYPE_BOOL      Type = 4
+	Type_TYPE_QUANTITY  Type = 5
+	Type_TYPE_DURATION  Type = 6
+	Type_TYPE_TIMESTAMP Type = 7
+	Type_TYPE_LIST      Type = 8
+	Type_TYPE_MAP       Type = 9
+	Type_TYPE_NULL      Type = 10
+)
+
+// Enum value maps for Type.
+var (
+	Type_name = map[int32]string{
+		0:  "TYPE_UNKNOWN",
+		1:  "TYPE_STRING",
+		2:  "TYPE_INT",
+		3:  "TYPE_FLOAT",
+		4:  "TYPE_BOOL",
+		5:  "TYPE_QUANTITY",
+		6:  "TYPE_DURATION",
+		7:  "TYPE_TIMESTAMP",
+		8:  "TYPE_LIST",
+		9:  "TYPE_MAP",
+		10: "TYPE_NULL",
+	}
+	Type_value = map[string]int32{
+		"TYPE_UNKNOWN":   0,
+		"TYPE_STRING":    1,
+		"TYPE_INT":       2,
+		"TYPE_FLOAT":     3,
+		"TYPE_BOOL":      4,
+		"TYPE_QUANTITY":  5,
+		"TYPE_DURATION":  6,
+		"TYPE_TIMESTAMP": 7,
+		"TYPE_LIST":      8,
+		"TYPE_MAP":       9,
+		"TYPE_NULL":      10,
+	}
+)
+
+func (x Type) Enum() *Type {
+	p := new(Type)
+	*p = x
+	return p
+}
+
+func (x Type) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Type) Descriptor() protoreflect.EnumDescriptor {
+	return file_kubesql_v1_query_proto_enumTypes[3].Descriptor()
+}
+
+func (Type) Type() protoreflect.EnumType {
+	return &file_kubesql_v1_query_proto_enumTypes[3]
+}
+
+func (x Type) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Type.Descriptor instead.
+func (Type) EnumDescriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{3}
+}
+
+// UnaryOp is the operator of a unary expression.
+type UnaryOp int32
+
+const (
+	UnaryOp_UNARY_OP_UNSPECIFIED UnaryOp = 0
+	UnaryOp_UNARY_OP_NOT         UnaryOp = 1
+	// Unary minus.
+	UnaryOp_UNARY_OP_NEG UnaryOp = 2
+)
+
+// Enum value maps for UnaryOp.
+var (
+	UnaryOp_name = map[int32]string{
+		0: "UNARY_OP_UNSPECIFIED",
+		1: "UNARY_OP_NOT",
+		2: "UNARY_OP_NEG",
+	}
+	UnaryOp_value = map[string]int32{
+		"UNARY_OP_UNSPECIFIED": 0,
+		"UNARY_OP_NOT":         1,
+		"UNARY_OP_NEG":         2,
+	}
+)
+
+func (x UnaryOp) Enum() *UnaryOp {
+	p := new(UnaryOp)
+	*p = x
+	return p
+}
+
+func (x UnaryOp) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (UnaryOp) Descriptor() protoreflect.EnumDescriptor {
+	return file_kubesql_v1_query_proto_enumTypes[4].Descriptor()
+}
+
+func (UnaryOp) Type() protoreflect.EnumType {
+	return &file_kubesql_v1_query_proto_enumTypes[4]
+}
+
+func (x UnaryOp) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use UnaryOp.Descriptor instead.
+func (UnaryOp) EnumDescriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{4}
+}
+
+// BinaryOp is the operator of a binary expression.
+type BinaryOp int32
+
+const (
+	BinaryOp_BINARY_OP_UNSPECIFIED BinaryOp = 0
+	BinaryOp_BINARY_OP_AND         BinaryOp = 1
+	BinaryOp_BINARY_OP_OR          BinaryOp = 2
+	BinaryOp_BINARY_OP_EQ          BinaryOp = 3
+	BinaryOp_BINARY_OP_NE          BinaryOp = 4
+	BinaryOp_BINARY_OP_LT          BinaryOp = 5
+	BinaryOp_BINARY_OP_LE          BinaryOp = 6
+	BinaryOp_BINARY_OP_GT          BinaryOp = 7
+	BinaryOp_BINARY_OP_GE          BinaryOp = 8
+	BinaryOp_BINARY_OP_LIKE        BinaryOp = 9
+	BinaryOp_BINARY_OP_ILIKE       BinaryOp = 10
+	// Regular expression match, ~=.
+	BinaryOp_BINARY_OP_MATCH BinaryOp = 11
+	// Regular expression mismatch, !~.
+	BinaryOp_BINARY_OP_NOT_MATCH BinaryOp = 12
+	BinaryOp_BINARY_OP_ADD       BinaryOp = 13
+	BinaryOp_BINARY_OP_SUB       BinaryOp = 14
+	BinaryOp_BINARY_OP_MUL       BinaryOp = 15
+	BinaryOp_BINARY_OP_DIV       BinaryOp = 16
+	BinaryOp_BINARY_OP_MOD       BinaryOp = 17
+)
+
+// Enum value maps for BinaryOp.
+var (
+	BinaryOp_name = map[int32]string{
+		0:  "BINARY_OP_UNSPECIFIED",
+		1:  "BINARY_OP_AND",
+		2:  "BINARY_OP_OR",
+		3:  "BINARY_OP_EQ",
+		4:  "BINARY_OP_NE",
+		5:  "BINARY_OP_LT",
+		6:  "BINARY_OP_LE",
+		7:  "BINARY_OP_GT",
+		8:  "BINARY_OP_GE",
+		9:  "BINARY_OP_LIKE",
+		10: "BINARY_OP_ILIKE",
+		11: "BINARY_OP_MATCH",
+		12: "BINARY_OP_NOT_MATCH",
+		13: "BINARY_OP_ADD",
+		14: "BINARY_OP_SUB",
+		15: "BINARY_OP_MUL",
+		16: "BINARY_OP_DIV",
+		17: "BINARY_OP_MOD",
+	}
+	BinaryOp_value = map[string]int32{
+		"BINARY_OP_UNSPECIFIED": 0,
+		"BINARY_OP_AND":         1,
+		"BINARY_OP_OR":          2,
+		"BINARY_OP_EQ":          3,
+		"BINARY_OP_NE":          4,
+		"BINARY_OP_LT":          5,
+		"BINARY_OP_LE":          6,
+		"BINARY_OP_GT":          7,
+		"BINARY_OP_GE":          8,
+		"BINARY_OP_LIKE":        9,
+		"BINARY_OP_ILIKE":       10,
+		"BINARY_OP_MATCH":       11,
+		"BINARY_OP_NOT_MATCH":   12,
+		"BINARY_OP_ADD":         13,
+		"BINARY_OP_SUB":         14,
+		"BINARY_OP_MUL":         15,
+		"BINARY_OP_DIV":         16,
+		"BINARY_OP_MOD":         17,
+	}
+)
+
+func (x BinaryOp) Enum() *BinaryOp {
+	p := new(BinaryOp)
+	*p = x
+	return p
+}
+
+func (x BinaryOp) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (BinaryOp) Descriptor() protoreflect.EnumDescriptor {
+	return file_kubesql_v1_query_proto_enumTypes[5].Descriptor()
+}
+
+func (BinaryOp) Type() protoreflect.EnumType {
+	return &file_kubesql_v1_query_proto_enumTypes[5]
+}
+
+func (x BinaryOp) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use BinaryOp.Descriptor instead.
+func (BinaryOp) EnumDescriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{5}
+}
+
+// Query is a parsed KubeSQL query.
+type Query struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Fields to select, all fields when empty.
+	Select []*SelectItem `protobuf:"bytes,1,rep,name=select,proto3" json:"select,omitempty"`
+	// Queried resource.
+	From *From `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	// Condition the objects must match, unset when the query has no WHERE.
+	Where *Expr `protobuf:"bytes,3,opt,name=where,proto3" json:"where,omitempty"`
+	// Sort keys, in order.
+	OrderBy []*OrderByItem `protobuf:"bytes,4,rep,name=order_by,json=orderBy,proto3" json:"order_by,omitempty"`
+	// Maximum number of results, unset for no limit.
+	Limit *int64 `protobuf:"varint,5,opt,name=limit,proto3,oneof" json:"limit,omitempty"`
+	// Set for EXPLAIN statements, whose plan is shown instead of their results.
+	Explain bool `protobuf:"varint,6,opt,name=explain,proto3" json:"explain,omitempty"`
+	// Location of the statement in the query or script text.
+	Pos *Position `protobuf:"bytes,7,opt,name=pos,proto3" json:"pos,omitempty"`
+	// Comments belonging to the statement, in source order.
+	Comments []*Comment `protobuf:"bytes,8,rep,name=comments,proto3" json:"comments,omitempty"`
+	// Result columns with their types, set when the query was validated.
+	Columns []*Column `protobuf:"bytes,9,rep,name=columns,proto3" json:"columns,omitempty"`
+	// Joined resources, in order.
+	Joins []*Join `protobuf:"bytes,10,rep,name=joins,proto3" json:"joins,omitempty"`
+	// Common table expressions of the WITH clause, in order.
+	With []*CTE `protobuf:"bytes,11,rep,name=with,proto3" json:"with,omitempty"`
+	// Queries combined with the query by set operations, in order; order_by
+	// and limit apply to the combined rows.
+	Compound      []*SetOperation `protobuf:"bytes,12,rep,name=compound,proto3" json:"compound,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Query) Reset() {
+	*x = Query{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Query) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Query) ProtoMessage() {}
+
+func (x *Query) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Query.ProtoReflect.Descriptor instead.
+func (*Query) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Query) GetSelect() []*SelectItem {
+	if x != nil {
+		return x.Select
+	}
+	return nil
+}
+
+func (x *Query) GetFrom() *From {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *Query) GetWhere() *Expr {
+	if x != nil {
+		return x.Where
+	}
+	return nil
+}
+
+func (x *Query) GetOrderBy() []*OrderByItem {
+	if x != nil {
+		return x.OrderBy
+	}
+	return nil
+}
+
+func (x *Query) GetLimit() int64 {
+	if x != nil && x.Limit != nil {
+		return *x.Limit
+	}
+	return 0
+}
+
+func (x *Query) GetExplain() bool {
+	if x != nil {
+		return x.Explain
+	}
+	return false
+}
+
+func (x *Query) GetPos() *Position {
+	if x != nil {
+		return x.Pos
+	}
+	return nil
+}
+
+func (x *Query) GetComments() []*Comment {
+	if x != nil {
+		return x.Comments
+	}
+	return nil
+}
+
+func (x *Query) GetColumns() []*Column {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *Query) GetJoins() []*Join {
+	if x != nil {
+		return x.Joins
+	}
+	return nil
+}
+
+func (x *Query) GetWith() []*CTE {
+	if x != nil {
+		return x.With
+	}
+	return nil
+}
+
+func (x *Query) GetCompound() []*SetOperation {
+	if x != nil {
+		return x.Compound
+	}
+	return nil
+}
+
+// CTE is a common table expression of a WITH clause.
+type CTE struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Name reading the rows of the query in FROM clauses and joins.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The named query.
+	Query         *Query `protobuf:"bytes,2,opt,name=query,proto3" json:"query,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CTE) Reset() {
+	*x = CTE{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CTE) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CTE) ProtoMessage() {}
+
+func (x *CTE) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CTE.ProtoReflect.Descriptor instead.
+func (*CTE) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CTE) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *CTE) GetQuery() *Query {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+// SetOperation combines the rows of the query with the rows of another
+// query selecting as many columns.
+type SetOperation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Op    SetOperator            `protobuf:"varint,1,opt,name=op,proto3,enum=kubesql.v1.SetOperator" json:"op,omitempty"`
+	// Keeps duplicate rows, as in UNION ALL.
+	All bool `protobuf:"varint,2,opt,name=all,proto3" json:"all,omitempty"`
+	// The combined query, without order_by or limit.
+	Query         *Query `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetOperation) Reset() {
+	*x = SetOperation{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetOperation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetOperation) ProtoMessage() {}
+
+func (x *SetOperation) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetOperation.ProtoReflect.Descriptor instead.
+func (*SetOperation) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SetOperation) GetOp() SetOperator {
+	if x != nil {
+		return x.Op
+	}
+	return SetOperator_SET_OPERATOR_UNSPECIFIED
+}
+
+func (x *SetOperation) GetAll() bool {
+	if x != nil {
+		return x.All
+	}
+	return false
+}
+
+func (x *SetOperation) GetQuery() *Query {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+// SelectItem is a field of a SELECT clause.
+type SelectItem struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Expr  *Expr                  `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
+	// Empty when the field has no alias.
+	Alias string `protobuf:"bytes,2,opt,name=alias,proto3" json:"alias,omitempty"`
+	// The expression as written in the query, which names the result column
+	// of a field without alias. Empty when unknown.
+	Text          string `protobuf:"bytes,3,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SelectItem) Reset() {
+	*x = SelectItem{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SelectItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SelectItem) ProtoMessage() {}
+
+func (x *SelectItem) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SelectItem.ProtoReflect.Descriptor instead.
+func (*SelectItem) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SelectItem) GetExpr() *Expr {
+	if x != nil {
+		return x.Expr
+	}
+	return nil
+}
+
+func (x *SelectItem) GetAlias() string {
+	if x != nil {
+		return x.Alias
+	}
+	return ""
+}
+
+func (x *SelectItem) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+// From is the resource of a FROM clause, or of a join.
+type From struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Namespace to query, empty for the default scope.
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// Resource name as written, e.g. "pods" or "deployments.apps".
+	Resource string `protobuf:"bytes,2,opt,name=resource,proto3" json:"resource,omitempty"`
+	// Cluster name patterns to query, e.g. "prod-*", empty for the default
+	// cluster.
+	Clusters []string `protobuf:"bytes,3,rep,name=clusters,proto3" json:"clusters,omitempty"`
+	// Name qualifying the fields of the resource, empty for the resource name.
+	Alias         string `protobuf:"bytes,4,opt,name=alias,proto3" json:"alias,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *From) Reset() {
+	*x = From{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *From) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*From) ProtoMessage() {}
+
+func (x *From) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use From.ProtoReflect.Descriptor instead.
+func (*From) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *From) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *From) GetResource() string {
+	if x != nil {
+		return x.Resource
+	}
+	return ""
+}
+
+func (x *From) GetClusters() []string {
+	if x != nil {
+		return x.Clusters
+	}
+	return nil
+}
+
+func (x *From) GetAlias() string {
+	if x != nil {
+		return x.Alias
+	}
+	return ""
+}
+
+// Join is a JOIN of the FROM clause.
+type Join struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Type  JoinType               `protobuf:"varint,1,opt,name=type,proto3,enum=kubesql.v1.JoinType" json:"type,omitempty"`
+	// Joined resource.
+	From *From `protobuf:"bytes,2,opt,name=from,proto3" json:"from,omitempty"`
+	// Join condition.
+	On            *Expr `protobuf:"bytes,3,opt,name=on,proto3" json:"on,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Join) Reset() {
+	*x = Join{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Join) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Join) ProtoMessage() {}
+
+func (x *Join) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Join.ProtoReflect.Descriptor instead.
+func (*Join) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Join) GetType() JoinType {
+	if x != nil {
+		return x.Type
+	}
+	return JoinType_JOIN_TYPE_UNSPECIFIED
+}
+
+func (x *Join) GetFrom() *From {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *Join) GetOn() *Expr {
+	if x != nil {
+		return x.On
+	}
+	return nil
+}
+
+// OrderByItem is a sort key of an ORDER BY clause.
+type OrderByItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Expr          *Expr                  `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
+	Direction     SortDirection          `protobuf:"varint,2,opt,name=direction,proto3,enum=kubesql.v1.SortDirection" json:"direction,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *OrderByItem) Reset() {
+	*x = OrderByItem{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *OrderByItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OrderByItem) ProtoMessage() {}
+
+func (x *OrderByItem) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OrderByItem.ProtoReflect.Descriptor instead.
+func (*OrderByItem) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *OrderByItem) GetExpr() *Expr {
+	if x != nil {
+		return x.Expr
+	}
+	return nil
+}
+
+func (x *OrderByItem) GetDirection() SortDirection {
+	if x != nil {
+		return x.Direction
+	}
+	return SortDirection_SORT_DIRECTION_UNSPECIFIED
+}
+
+// Position is a location in the query text.
+type Position struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Byte offset from the start of the query, starting at 0.
+	Offset int32 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Line number, starting at 1.
+	Line int32 `protobuf:"varint,2,opt,name=line,proto3" json:"line,omitempty"`
+	// Column number in bytes, starting at 1.
+	Column        int32 `protobuf:"varint,3,opt,name=column,proto3" json:"column,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Position) Reset() {
+	*x = Position{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Position) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Position) ProtoMessage() {}
+
+func (x *Position) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Position.ProtoReflect.Descriptor instead.
+func (*Position) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Position) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *Position) GetLine() int32 {
+	if x != nil {
+		return x.Line
+	}
+	return 0
+}
+
+func (x *Position) GetColumn() int32 {
+	if x != nil {
+		return x.Column
+	}
+	return 0
+}
+
+// Comment is a comment found in the query text.
+type Comment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The comment including its markers, e.g. "-- note".
+	Text          string    `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	Pos           *Position `protobuf:"bytes,2,opt,name=pos,proto3" json:"pos,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Comment) Reset() {
+	*x = Comment{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Comment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Comment) ProtoMessage() {}
+
+func (x *Comment) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Comment.ProtoReflect.Descriptor instead.
+func (*Comment) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Comment) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Comment) GetPos() *Position {
+	if x != nil {
+		return x.Pos
+	}
+	return nil
+}
+
+// Column is a result column with its inferred type.
+type Column struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type          Type                   `protobuf:"varint,2,opt,name=type,proto3,enum=kubesql.v1.Type" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Column) Reset() {
+	*x = Column{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Column) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Column) ProtoMessage() {}
+
+func (x *Column) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Column.ProtoReflect.Descriptor instead.
+func (*Column) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Column) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Column) GetType() Type {
+	if x != nil {
+		return x.Type
+	}
+	return Type_TYPE_UNKNOWN
+}
+
+// Expr is a node of an expression.
+type Expr struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Expr:
+	//
+	//	*Expr_Field
+	//	*Expr_Literal
+	//	*Expr_Star
+	//	*Expr_Paren
+	//	*Expr_Unary
+	//	*Expr_Binary
+	//	*Expr_In
+	//	*Expr_Between
+	//	*Expr_IsNull
+	//	*Expr_Call
+	//	*Expr_Exists
+	Expr          isExpr_Expr `protobuf_oneof:"expr"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Expr) Reset() {
+	*x = Expr{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Expr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Expr) ProtoMessage() {}
+
+func (x *Expr) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Expr.ProtoReflect.Descriptor instead.
+func (*Expr) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Expr) GetExpr() isExpr_Expr {
+	if x != nil {
+		return x.Expr
+	}
+	return nil
+}
+
+func (x *Expr) GetField() *FieldPath {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_Field); ok {
+			return x.Field
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetLiteral() *Literal {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_Literal); ok {
+			return x.Literal
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetStar() *Star {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_Star); ok {
+			return x.Star
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetParen() *ParenExpr {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_Paren); ok {
+			return x.Paren
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetUnary() *UnaryExpr {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_Unary); ok {
+			return x.Unary
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetBinary() *BinaryExpr {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_Binary); ok {
+			return x.Binary
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetIn() *InExpr {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_In); ok {
+			return x.In
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetBetween() *BetweenExpr {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_Between); ok {
+			return x.Between
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetIsNull() *IsNullExpr {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_IsNull); ok {
+			return x.IsNull
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetCall() *CallExpr {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_Call); ok {
+			return x.Call
+		}
+	}
+	return nil
+}
+
+func (x *Expr) GetExists() *ExistsExpr {
+	if x != nil {
+		if x, ok := x.Expr.(*Expr_Exists); ok {
+			return x.Exists
+		}
+	}
+	return nil
+}
+
+type isExpr_Expr interface {
+	isExpr_Expr()
+}
+
+type Expr_Field struct {
+	Field *FieldPath `protobuf:"bytes,1,opt,name=field,proto3,oneof"`
+}
+
+type Expr_Literal struct {
+	Literal *Literal `protobuf:"bytes,2,opt,name=literal,proto3,oneof"`
+}
+
+type Expr_Star struct {
+	Star *Star `protobuf:"bytes,3,opt,name=star,proto3,oneof"`
+}
+
+type Expr_Paren struct {
+	Paren *ParenExpr `protobuf:"bytes,4,opt,name=paren,proto3,oneof"`
+}
+
+type Expr_Unary struct {
+	Unary *UnaryExpr `protobuf:"bytes,5,opt,name=unary,proto3,oneof"`
+}
+
+type Expr_Binary struct {
+	Binary *BinaryExpr `protobuf:"bytes,6,opt,name=binary,proto3,oneof"`
+}
+
+type Expr_In struct {
+	In *InExpr `protobuf:"bytes,7,opt,name=in,proto3,oneof"`
+}
+
+type Expr_Between struct {
+	Between *BetweenExpr `protobuf:"bytes,8,opt,name=between,proto3,oneof"`
+}
+
+type Expr_IsNull struct {
+	IsNull *IsNullExpr `protobuf:"bytes,9,opt,name=is_null,json=isNull,proto3,oneof"`
+}
+
+type Expr_Call struct {
+	Call *CallExpr `protobuf:"bytes,10,opt,name=call,proto3,oneof"`
+}
+
+type Expr_Exists struct {
+	Exists *ExistsExpr `protobuf:"bytes,11,opt,name=exists,proto3,oneof"`
+}
+
+func (*Expr_Field) isExpr_Expr() {}
+
+func (*Expr_Literal) isExpr_Expr() {}
+
+func (*Expr_Star) isExpr_Expr() {}
+
+func (*Expr_Paren) isExpr_Expr() {}
+
+func (*Expr_Unary) isExpr_Expr() {}
+
+func (*Expr_Binary) isExpr_Expr() {}
+
+func (*Expr_In) isExpr_Expr() {}
+
+func (*Expr_Between) isExpr_Expr() {}
+
+func (*Expr_IsNull) isExpr_Expr() {}
+
+func (*Expr_Call) isExpr_Expr() {}
+
+func (*Expr_Exists) isExpr_Expr() {}
+
+// FieldPath is a reference to a field of the queried object, e.g.
+// spec.containers[0].image.
+type FieldPath struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Segments      []*Segment             `protobuf:"bytes,1,rep,name=segments,proto3" json:"segments,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FieldPath) Reset() {
+	*x = FieldPath{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FieldPath) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FieldPath) ProtoMessage() {}
+
+func (x *FieldPath) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FieldPath.ProtoReflect.Descriptor instead.
+func (*FieldPath) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *FieldPath) GetSegments() []*Segment {
+	if x != nil {
+		return x.Segments
+	}
+	return nil
+}
+
+// Segment is one step of a field path.
+type Segment struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Segment:
+	//
+	//	*Segment_Name
+	//	*Segment_Index
+	//	*Segment_Wildcard
+	Segment       isSegment_Segment `protobuf_oneof:"segment"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Segment) Reset() {
+	*x = Segment{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Segment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Segment) ProtoMessage() {}
+
+func (x *Segment) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Segment.ProtoReflect.Descriptor instead.
+func (*Segment) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Segment) GetSegment() isSegment_Segment {
+	if x != nil {
+		return x.Segment
+	}
+	return nil
+}
+
+func (x *Segment) GetName() string {
+	if x != nil {
+		if x, ok := x.Segment.(*Segment_Name); ok {
+			return x.Name
+		}
+	}
+	return ""
+}
+
+func (x *Segment) GetIndex() int32 {
+	if x != nil {
+		if x, ok := x.Segment.(*Segment_Index); ok {
+			return x.Index
+		}
+	}
+	return 0
+}
+
+func (x *Segment) GetWildcard() bool {
+	if x != nil {
+		if x, ok := x.Segment.(*Segment_Wildcard); ok {
+			return x.Wildcard
+		}
+	}
+	return false
+}
+
+type isSegment_Segment interface {
+	isSegment_Segment()
+}
+
+type Segment_Name struct {
+	// Object field name.
+	Name string `protobuf:"bytes,1,opt,name=name,proto3,oneof"`
+}
+
+type Segment_Index struct {
+	// Array index.
+	Index int32 `protobuf:"varint,2,opt,name=index,proto3,oneof"`
+}
+
+type Segment_Wildcard struct {
+	// The [*] wildcard, matching every array item.
+	Wildcard bool `protobuf:"varint,3,opt,name=wildcard,proto3,oneof"`
+}
+
+func (*Segment_Name) isSegment_Segment() {}
+
+func (*Segment_Index) isSegment_Segment() {}
+
+func (*Segment_Wildcard) isSegment_Segment() {}
+
+// Literal is a constant value.
+type Literal struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Value:
+	//
+	//	*Literal_StringValue
+	//	*Literal_IntValue
+	//	*Literal_FloatValue
+	//	*Literal_BoolValue
+	//	*Literal_NullValue
+	//	*Literal_QuantityValue
+	//	*Literal_DurationValue
+	Value isLiteral_Value `protobuf_oneof:"value"`
+	// Numbers as written in the query, e.g. "1.50" or "90s". Empty for
+	// literals built from their value, which are then written canonically.
+	Text          string `protobuf:"bytes,8,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Literal) Reset() {
+	*x = Literal{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Literal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Literal) ProtoMessage() {}
+
+func (x *Literal) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Literal.ProtoReflect.Descriptor instead.
+func (*Literal) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Literal) GetValue() isLiteral_Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Literal) GetStringValue() string {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_StringValue); ok {
+			return x.StringValue
+		}
+	}
+	return ""
+}
+
+func (x *Literal) GetIntValue() int64 {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_IntValue); ok {
+			return x.IntValue
+		}
+	}
+	return 0
+}
+
+func (x *Literal) GetFloatValue() float64 {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_FloatValue); ok {
+			return x.FloatValue
+		}
+	}
+	return 0
+}
+
+func (x *Literal) GetBoolValue() bool {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_BoolValue); ok {
+			return x.BoolValue
+		}
+	}
+	return false
+}
+
+func (x *Literal) GetNullValue() structpb.NullValue {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_NullValue); ok {
+			return x.NullValue
+		}
+	}
+	return structpb.NullValue(0)
+}
+
+func (x *Literal) GetQuantityValue() *Quantity {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_QuantityValue); ok {
+			return x.QuantityValue
+		}
+	}
+	return nil
+}
+
+func (x *Literal) GetDurationValue() *durationpb.Duration {
+	if x != nil {
+		if x, ok := x.Value.(*Literal_DurationValue); ok {
+			return x.DurationValue
+		}
+	}
+	return nil
+}
+
+func (x *Literal) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+type isLiteral_Value interface {
+	isLiteral_Value()
+}
+
+type Literal_StringValue struct {
+	StringValue string `protobuf:"bytes,1,opt,name=string_value,json=stringValue,proto3,oneof"`
+}
+
+type Literal_IntValue struct {
+	IntValue int64 `protobuf:"varint,2,opt,name=int_value,json=intValue,proto3,oneof"`
+}
+
+type Literal_FloatValue struct {
+	FloatValue float64 `protobuf:"fixed64,3,opt,name=float_value,json=floatValue,proto3,oneof"`
+}
+
+type Literal_BoolValue struct {
+	BoolValue bool `protobuf:"varint,4,opt,name=bool_value,json=boolValue,proto3,oneof"`
+}
+
+type Literal_NullValue struct {
+	NullValue structpb.NullValue `protobuf:"varint,5,opt,name=null_value,json=nullValue,proto3,enum=google.protobuf.NullValue,oneof"`
+}
+
+type Literal_QuantityValue struct {
+	// A Kubernetes resource quantity, e.g. 512Mi or 500m.
+	QuantityValue *Quantity `protobuf:"bytes,6,opt,name=quantity_value,json=quantityValue,proto3,oneof"`
+}
+
+type Literal_DurationValue struct {
+	DurationValue *durationpb.Duration `protobuf:"bytes,7,opt,name=duration_value,json=durationValue,proto3,oneof"`
+}
+
+func (*Literal_StringValue) isLiteral_Value() {}
+
+func (*Literal_IntValue) isLiteral_Value() {}
+
+func (*Literal_FloatValue) isLiteral_Value() {}
+
+func (*Literal_BoolValue) isLiteral_Value() {}
+
+func (*Literal_NullValue) isLiteral_Value() {}
+
+func (*Literal_QuantityValue) isLiteral_Value() {}
+
+func (*Literal_DurationValue) isLiteral_Value() {}
+
+// Quantity is a Kubernetes resource quantity.
+type Quantity struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The quantity with its suffix, e.g. "512Mi".
+	Value         string `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Quantity) Reset() {
+	*x = Quantity{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Quantity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Quantity) ProtoMessage() {}
+
+func (x *Quantity) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Quantity.ProtoReflect.Descriptor instead.
+func (*Quantity) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Quantity) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+// Star is the * in SELECT * or COUNT(*).
+type Star struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Star) Reset() {
+	*x = Star{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Star) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Star) ProtoMessage() {}
+
+func (x *Star) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Star.ProtoReflect.Descriptor instead.
+func (*Star) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{15}
+}
+
+// ParenExpr is a parenthesized expression.
+type ParenExpr struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             *Expr                  `protobuf:"bytes,1,opt,name=x,proto3" json:"x,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParenExpr) Reset() {
+	*x = ParenExpr{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParenExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParenExpr) ProtoMessage() {}
+
+func (x *ParenExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParenExpr.ProtoReflect.Descriptor instead.
+func (*ParenExpr) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ParenExpr) GetX() *Expr {
+	if x != nil {
+		return x.X
+	}
+	return nil
+}
+
+// UnaryExpr is a NOT or unary minus expression.
+type UnaryExpr struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Op            UnaryOp                `protobuf:"varint,1,opt,name=op,proto3,enum=kubesql.v1.UnaryOp" json:"op,omitempty"`
+	X             *Expr                  `protobuf:"bytes,2,opt,name=x,proto3" json:"x,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnaryExpr) Reset() {
+	*x = UnaryExpr{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnaryExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnaryExpr) ProtoMessage() {}
+
+func (x *UnaryExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnaryExpr.ProtoReflect.Descriptor instead.
+func (*UnaryExpr) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *UnaryExpr) GetOp() UnaryOp {
+	if x != nil {
+		return x.Op
+	}
+	return UnaryOp_UNARY_OP_UNSPECIFIED
+}
+
+func (x *UnaryExpr) GetX() *Expr {
+	if x != nil {
+		return x.X
+	}
+	return nil
+}
+
+// BinaryExpr is a logical, comparison or arithmetic expression.
+type BinaryExpr struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Op            BinaryOp               `protobuf:"varint,1,opt,name=op,proto3,enum=kubesql.v1.BinaryOp" json:"op,omitempty"`
+	X             *Expr                  `protobuf:"bytes,2,opt,name=x,proto3" json:"x,omitempty"`
+	Y             *Expr                  `protobuf:"bytes,3,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BinaryExpr) Reset() {
+	*x = BinaryExpr{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BinaryExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BinaryExpr) ProtoMessage() {}
+
+func (x *BinaryExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BinaryExpr.ProtoReflect.Descriptor instead.
+func (*BinaryExpr) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *BinaryExpr) GetOp() BinaryOp {
+	if x != nil {
+		return x.Op
+	}
+	return BinaryOp_BINARY_OP_UNSPECIFIED
+}
+
+func (x *BinaryExpr) GetX() *Expr {
+	if x != nil {
+		return x.X
+	}
+	return nil
+}
+
+func (x *BinaryExpr) GetY() *Expr {
+	if x != nil {
+		return x.Y
+	}
+	return nil
+}
+
+// InExpr is an x [NOT] IN (a, b, ...) expression, or x [NOT] IN (SELECT
+// ...) matching the values of the single field a subquery selects.
+type InExpr struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	X     *Expr                  `protobuf:"bytes,1,opt,name=x,proto3" json:"x,omitempty"`
+	List  []*Expr                `protobuf:"bytes,2,rep,name=list,proto3" json:"list,omitempty"`
+	Not   bool                   `protobuf:"varint,3,opt,name=not,proto3" json:"not,omitempty"`
+	// Set instead of list for subqueries.
+	Subquery      *Query `protobuf:"bytes,4,opt,name=subquery,proto3" json:"subquery,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InExpr) Reset() {
+	*x = InExpr{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InExpr) ProtoMessage() {}
+
+func (x *InExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InExpr.ProtoReflect.Descriptor instead.
+func (*InExpr) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *InExpr) GetX() *Expr {
+	if x != nil {
+		return x.X
+	}
+	return nil
+}
+
+func (x *InExpr) GetList() []*Expr {
+	if x != nil {
+		return x.List
+	}
+	return nil
+}
+
+func (x *InExpr) GetNot() bool {
+	if x != nil {
+		return x.Not
+	}
+	return false
+}
+
+func (x *InExpr) GetSubquery() *Query {
+	if x != nil {
+		return x.Subquery
+	}
+	return nil
+}
+
+// ExistsExpr is an EXISTS (SELECT ...) expression, true when the subquery
+// returns a row.
+type ExistsExpr struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Subquery      *Query                 `protobuf:"bytes,1,opt,name=subquery,proto3" json:"subquery,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExistsExpr) Reset() {
+	*x = ExistsExpr{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExistsExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExistsExpr) ProtoMessage() {}
+
+func (x *ExistsExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExistsExpr.ProtoReflect.Descriptor instead.
+func (*ExistsExpr) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *ExistsExpr) GetSubquery() *Query {
+	if x != nil {
+		return x.Subquery
+	}
+	return nil
+}
+
+// BetweenExpr is an x [NOT] BETWEEN low AND high expression.
+type BetweenExpr struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             *Expr                  `protobuf:"bytes,1,opt,name=x,proto3" json:"x,omitempty"`
+	Low           *Expr                  `protobuf:"bytes,2,opt,name=low,proto3" json:"low,omitempty"`
+	High          *Expr                  `protobuf:"bytes,3,opt,name=high,proto3" json:"high,omitempty"`
+	Not           bool                   `protobuf:"varint,4,opt,name=not,proto3" json:"not,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BetweenExpr) Reset() {
+	*x = BetweenExpr{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BetweenExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BetweenExpr) ProtoMessage() {}
+
+func (x *BetweenExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BetweenExpr.ProtoReflect.Descriptor instead.
+func (*BetweenExpr) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *BetweenExpr) GetX() *Expr {
+	if x != nil {
+		return x.X
+	}
+	return nil
+}
+
+func (x *BetweenExpr) GetLow() *Expr {
+	if x != nil {
+		return x.Low
+	}
+	return nil
+}
+
+func (x *BetweenExpr) GetHigh() *Expr {
+	if x != nil {
+		return x.High
+	}
+	return nil
+}
+
+func (x *BetweenExpr) GetNot() bool {
+	if x != nil {
+		return x.Not
+	}
+	return false
+}
+
+// IsNullExpr is an x IS [NOT] NULL expression.
+type IsNullExpr struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             *Expr                  `protobuf:"bytes,1,opt,name=x,proto3" json:"x,omitempty"`
+	Not           bool                   `protobuf:"varint,2,opt,name=not,proto3" json:"not,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IsNullExpr) Reset() {
+	*x = IsNullExpr{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IsNullExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IsNullExpr) ProtoMessage() {}
+
+func (x *IsNullExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IsNullExpr.ProtoReflect.Descriptor instead.
+func (*IsNullExpr) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *IsNullExpr) GetX() *Expr {
+	if x != nil {
+		return x.X
+	}
+	return nil
+}
+
+func (x *IsNullExpr) GetNot() bool {
+	if x != nil {
+		return x.Not
+	}
+	return false
+}
+
+// CallExpr is a function call, e.g. COUNT(*) or len(spec.containers).
+type CallExpr struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Func          string                 `protobuf:"bytes,1,opt,name=func,proto3" json:"func,omitempty"`
+	Args          []*Expr                `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CallExpr) Reset() {
+	*x = CallExpr{}
+	mi := &file_kubesql_v1_query_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CallExpr) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CallExpr) ProtoMessage() {}
+
+func (x *CallExpr) ProtoReflect() protoreflect.Message {
+	mi := &file_kubesql_v1_query_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CallExpr.ProtoReflect.Descriptor instead.
+func (*CallExpr) Descriptor() ([]byte, []int) {
+	return file_kubesql_v1_query_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *CallExpr) GetFunc() string {
+	if x != nil {
+		return x.Func
+	}
+	return ""
+}
+
+func (x *CallExpr) GetArgs() []*Expr {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+var File_kubesql_v1_query_proto protoreflect.FileDescriptor
+
+const file_kubesql_v1_query_proto_rawDesc = "" +
+	"\n" +
+	"\x16kubesql/v1/query.proto\x12\n" +
+	"kubesql.v1\x1a\x1egoogle/protobuf/duration.proto\x1a\x1cgoogle/protobuf/struct.proto\"\x82\x04\n" +
+	"\x05Query\x12.\n" +
+	"\x06select\x18\x01 \x03(\v2\x16.kubesql.v1.SelectItemR\x06select\x12$\n" +
+	"\x04from\x18\x02 \x01(\v2\x10.kubesql.v1.FromR\x04from\x12&\n" +
+	"\x05where\x18\x03 \x01(\v2\x10.kubesql.v1.ExprR\x05where\x122\n" +
+	"\border_by\x18\x04 \x03(\v2\x17.kubesql.v1.OrderByItemR\aorderBy\x12\x19\n" +
+	"\x05limit\x18\x05 \x01(\x03H\x00R\x05limit\x88\x01\x01\x12\x18\n" +
+	"\aexplain\x18\x06 \x01(\bR\aexplain\x12&\n" +
+	"\x03pos\x18\a \x01(\v2\x14.kubesql.v1.PositionR\x03pos\x12/\n" +
+	"\bcomments\x18\b \x03(\v2\x13.kubesql.v1.CommentR\bcomments\x12,\n" +
+	"\acolumns\x18\t \x03(\v2\x12.kubesql.v1.ColumnR\acolumns\x12&\n" +
+	"\x05joins\x18\n" +
+	" \x03(\v2\x10.kubesql.v1.JoinR\x05joins\x12#\n" +
+	"\x04with\x18\v \x03(\v2\x0f.kubesql.v1.CTER\x04with\x124\n" +
+	"\bcompound\x18\f \x03(\v2\x18.kubesql.v1.SetOperationR\bcompoundB\b\n" +
+	"\x06_limit\"B\n" +
+	"\x03CTE\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12'\n" +
+	"\x05query\x18\x02 \x01(\v2\x11.kubesql.v1.QueryR\x05query\"r\n" +
+	"\fSetOperation\x12'\n" +
+	"\x02op\x18\x01 \x01(\x0e2\x17.kubesql.v1.SetOperatorR\x02op\x12\x10\n" +
+	"\x03all\x18\x02 \x01(\bR\x03all\x12'\n" +
+	"\x05query\x18\x03 \x01(\v2\x11.kubesql.v1.QueryR\x05query\"\\\n" +
+	"\n" +
+	"SelectItem\x12$\n" +
+	"\x04expr\x18\x01 \x01(\v2\x10.kubesql.v1.ExprR\x04expr\x12\x14\n" +
+	"\x05alias\x18\x02 \x01(\tR\x05alias\x12\x12\n" +
+	"\x04text\x18\x03 \x01(\tR\x04text\"r\n" +
+	"\x04From\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x1a\n" +
+	"\bresource\x18\x02 \x01(\tR\bresource\x12\x1a\n" +
+	"\bclusters\x18\x03 \x03(\tR\bclusters\x12\x14\n" +
+	"\x05alias\x18\x04 \x01(\tR\x05alias\"x\n" +
+	"\x04Join\x12(\n" +
+	"\x04type\x18\x01 \x01(\x0e2\x14.kubesql.v1.JoinTypeR\x04type\x12$\n" +
+	"\x04from\x18\x02 \x01(\v2\x10.kubesql.v1.FromR\x04from\x12 \n" +
+	"\x02on\x18\x03 \x01(\v2\x10.kubesql.v1.ExprR\x02on\"l\n" +
+	"\vOrderByItem\x12$\n" +
+	"\x04expr\x18\x01 \x01(\v2\x10.kubesql.v1.ExprR\x04expr\x127\n" +
+	"\tdirection\x18\x02 \x01(\x0e2\x19.kubesql.v1.SortDirectionR\tdirection\"N\n" +
+	"\bPosition\x12\x16\n" +
+	"\x06offset\x18\x01 \x01(\x05R\x06offset\x12\x12\n" +
+	"\x04line\x18\x02 \x01(\x05R\x04line\x12\x16\n" +
+	"\x06column\x18\x03 \x01(\x05R\x06column\"E\n" +
+	"\aComment\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\x12&\n" +
+	"\x03pos\x18\x02 \x01(\v2\x14.kubesql.v1.PositionR\x03pos\"B\n" +
+	"\x06Column\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12$\n" +
+	"\x04type\x18\x02 \x01(\x0e2\x10.kubesql.v1.TypeR\x04type\"\x92\x04\n" +
+	"\x04Expr\x12-\n" +
+	"\x05field\x18\x01 \x01(\v2\x15.kubesql.v1.FieldPathH\x00R\x05field\x12/\n" +
+	"\aliteral\x18\x02 \x01(\v2\x13.kubesql.v1.LiteralH\x00R\aliteral\x12&\n" +
+	"\x04star\x18\x03 \x01(\v2\x10.kubesql.v1.StarH\x00R\x04star\x12-\n" +
+	"\x05paren\x18\x04 \x01(\v2\x15.kubesql.v1.ParenExprH\x00R\x05paren\x12-\n" +
+	"\x05unary\x18\x05 \x01(\v2\x15.kubesql.v1.UnaryExprH\x00R\x05unary\x120\n" +
+	"\x06binary\x18\x06 \x01(\v2\x16.kubesql.v1.BinaryExprH\x00R\x06binary\x12$\n" +
+	"\x02in\x18\a \x01(\v2\x12.kubesql.v1.InExprH\x00R\x02in\x123\n" +
+	"\abetween\x18\b \x01(\v2\x17.kubesql.v1.BetweenExprH\x00R\abetween\x121\n" +
+	"\ais_null\x18\t \x01(\v2\x16.kubesql.v1.IsNullExprH\x00R\x06isNull\x12*\n" +
+	"\x04call\x18\n" +
+	" \x01(\v2\x14.kubesql.v1.CallExprH\x00R\x04call\x120\n" +
+	"\x06exists\x18\v \x01(\v2\x16.kubesql.v1.ExistsExprH\x00R\x06existsB\x06\n" +
+	"\x04expr\"<\n" +
+	"\tFieldPath\x12/\n" +
+	"\bsegments\x18\x01 \x03(\v2\x13.kubesql.v1.SegmentR\bsegments\"`\n" +
+	"\aSegment\x12\x14\n" +
+	"\x04name\x18\x01 \x01(\tH\x00R\x04name\x12\x16\n" +
+	"\x05index\x18\x02 \x01(\x05H\x00R\x05index\x12\x1c\n" +
+	"\bwildcard\x18\x03 \x01(\bH\x00R\bwildcardB\t\n" +
+	"\asegment\"\xee\x02\n" +
+	"\aLiteral\x12#\n" +
+	"\fstring_value\x18\x01 \x01(\tH\x00R\vstringValue\x12\x1d\n" +
+	"\tint_value\x18\x02 \x01(\x03H\x00R\bintValue\x12!\n" +
+	"\vfloat_value\x18\x03 \x01(\x01H\x00R\n" +
+	"floatValue\x12\x1f\n" +
+	"\n" +
+	"bool_value\x18\x04 \x01(\bH\x00R\tboolValue\x12;\n" +
+	"\n" +
+	"null_value\x18\x05 \x01(\x0e2\x1a.google.protobuf.NullValueH\x00R\tnullValue\x12=\n" +
+	"\x0equantity_value\x18\x06 \x01(\v2\x14.kubesql.v1.QuantityH\x00R\rquantityValue\x12B\n" +
+	"\x0eduration_value\x18\a \x01(\v2\x19.google.protobuf.DurationH\x00R\rdurationValue\x12\x12\n" +
+	"\x04text\x18\b \x01(\tR\x04textB\a\n" +
+	"\x05value\" \n" +
+	"\bQuantity\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\"\x06\n" +
+	"\x04Star\"+\n" +
+	"\tParenExpr\x12\x1e\n" +
+	"\x01x\x18\x01 \x01(\v2\x10.kubesql.v1.ExprR\x01x\"P\n" +
+	"\tUnaryExpr\x12#\n" +
+	"\x02op\x18\x01 \x01(\x0e2\x13.kubesql.v1.UnaryOpR\x02op\x12\x1e\n" +
+	"\x01x\x18\x02 \x01(\v2\x10.kubesql.v1.ExprR\x01x\"r\n" +
+	"\n" +
+	"BinaryExpr\x12$\n" +
+	"\x02op\x18\x01 \x01(\x0e2\x14.kubesql.v1.BinaryOpR\x02op\x12\x1e\n" +
+	"\x01x\x18\x02 \x01(\v2\x10.kubesql.v1.ExprR\x01x\x12\x1e\n" +
+	"\x01y\x18\x03 \x01(\v2\x10.kubesql.v1.ExprR\x01y\"\x8f\x01\n" +
+	"\x06InExpr\x12\x1e\n" +
+	"\x01x\x18\x01 \x01(\v2\x10.kubesql.v1.ExprR\x01x\x12$\n" +
+	"\x04list\x18\x02 \x03(\v2\x10.kubesql.v1.ExprR\x04list\x12\x10\n" +
+	"\x03not\x18\x03 \x01(\bR\x03not\x12-\n" +
+	"\bsubquery\x18\x04 \x01(\v2\x11.kubesql.v1.QueryR\bsubquery\";\n" +
+	"\n" +
+	"ExistsExpr\x12-\n" +
+	"\bsubquery\x18\x01 \x01(\v2\x11.kubesql.v1.QueryR\bsubquery\"\x89\x01\n" +
+	"\vBetweenExpr\x12\x1e\n" +
+	"\x01x\x18\x01 \x01(\v2\x10.kubesql.v1.ExprR\x01x\x12\"\n" +
+	"\x03low\x18\x02 \x01(\v2\x10.kubesql.v1.ExprR\x03low\x12$\n" +
+	"\x04high\x18\x03 \x01(\v2\x10.kubesql.v1.ExprR\x04high\x12\x10\n" +
+	"\x03not\x18\x04 \x01(\bR\x03not\">\n" +
+	"\n" +
+	"IsNullExpr\x12\x1e\n" +
+	"\x01x\x18\x01 \x01(\v2\x10.kubesql.v1.ExprR\x01x\x12\x10\n" +
+	"\x03not\x18\x02 \x01(\bR\x03not\"D\n" +
+	"\bCallExpr\x12\x12\n" +
+	"\x04func\x18\x01 \x01(\tR\x04func\x12$\n" +
+	"\x04args\x18\x02 \x03(\v2\x10.kubesql.v1.ExprR\x04args*x\n" +
+	"\vSetOperator\x12\x1c\n" +
+	"\x18SET_OPERATOR_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12SET_OPERATOR_UNION\x10\x01\x12\x1a\n" +
+	"\x16SET_OPERATOR_INTERSECT\x10\x02\x12\x17\n" +
+	"\x13SET_OPERATOR_EXCEPT\x10\x03*N\n" +
+	"\bJoinType\x12\x19\n" +
+	"\x15JOIN_TYPE_UNSPECIFIED\x10\x00\x12\x13\n" +
+	"\x0fJOIN_TYPE_INNER\x10\x01\x12\x12\n" +
+	"\x0eJOIN_TYPE_LEFT\x10\x02*`\n" +
+	"\rSortDirection\x12\x1e\n" +
+	"\x1aSORT_DIRECTION_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12SORT_DIRECTION_ASC\x10\x01\x12\x17\n" +
+	"\x13SORT_DIRECTION_DESC\x10\x02*\xbc\x01\n" +
+	"\x04Type\x12\x10\n" +
+	"\fTYPE_UNKNOWN\x10\x00\x12\x0f\n" +
+	"\vTYPE_STRING\x10\x01\x12\f\n" +
+	"\bTYPE_INT\x10\x02\x12\x0e\n" +
+	"\n" +
+	"TYPE_FLOAT\x10\x03\x12\r\n" +
+	"\tTYPE_BOOL\x10\x04\x12\x11\n" +
+	"\rTYPE_QUANTITY\x10\x05\x12\x11\n" +
+	"\rTYPE_DURATION\x10\x06\x12\x12\n" +
+	"\x0eTYPE_TIMESTAMP\x10\a\x12\r\n" +
+	"\tTYPE_LIST\x10\b\x12\f\n" +
+	"\bTYPE_MAP\x10\t\x12\r\n" +
+	"\tTYPE_NULL\x10\n" +
+	"*G\n" +
+	"\aUnaryOp\x12\x18\n" +
+	"\x14UNARY_OP_UNSPECIFIED\x10\x00\x12\x10\n" +
+	"\fUNARY_OP_NOT\x10\x01\x12\x10\n" +
+	"\fUNARY_OP_NEG\x10\x02*\xec\x02\n" +
+	"\bBinaryOp\x12\x19\n" +
+	"\x15BINARY_OP_UNSPECIFIED\x10\x00\x12\x11\n" +
+	"\rBINARY_OP_AND\x10\x01\x12\x10\n" +
+	"\fBINARY_OP_OR\x10\x02\x12\x10\n" +
+	"\fBINARY_OP_EQ\x10\x03\x12\x10\n" +
+	"\fBINARY_OP_NE\x10\x04\x12\x10\n" +
+	"\fBINARY_OP_LT\x10\x05\x12\x10\n" +
+	"\fBINARY_OP_LE\x10\x06\x12\x10\n" +
+	"\fBINARY_OP_GT\x10\a\x12\x10\n" +
+	"\fBINARY_OP_GE\x10\b\x12\x12\n" +
+	"\x0eBINARY_OP_LIKE\x10\t\x12\x13\n" +
+	"\x0fBINARY_OP_ILIKE\x10\n" +
+	"\x12\x13\n" +
+	"\x0fBINARY_OP_MATCH\x10\v\x12\x17\n" +
+	"\x13BINARY_OP_NOT_MATCH\x10\f\x12\x11\n" +
+	"\rBINARY_OP_ADD\x10\r\x12\x11\n" +
+	"\rBINARY_OP_SUB\x10\x0e\x12\x11\n" +
+	"\rBINARY_OP_MUL\x10\x0f\x12\x11\n" +
+	"\rBINARY_OP_DIV\x10\x10\x12\x11\n" +
+	"\rBINARY_OP_MOD\x10\x11B5Z3github.com/yaacov/kubesql-interpreter/pkg/kubesqlpbb\x06proto3"
+
+var (
+	file_kubesql_v1_query_proto_rawDescOnce sync.Once
+	file_kubesql_v1_query_proto_rawDescData []byte
+)
+
+func file_kubesql_v1_query_proto_rawDescGZIP() []byte {
+	file_kubesql_v1_query_proto_rawDescOnce.Do(func() {
+		file_kubesql_v1_query_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_kubesql_v1_query_proto_rawDesc), len(file_kubesql_v1_query_proto_rawDesc)))
+	})
+	return file_kubesql_v1_query_proto_rawDescData
+}
+
+var file_kubesql_v1_query_proto_enumTypes = make([]protoimpl.EnumInfo, 6)
+var file_kubesql_v1_query_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
+var file_kubesql_v1_query_proto_goTypes = []any{
+	(SetOperator)(0),            // 0: kubesql.v1.SetOperator
+	(JoinType)(0),               // 1: kubesql.v1.JoinType
+	(SortDirection)(0),          // 2: kubesql.v1.SortDirection
+	(Type)(0),                   // 3: kubesql.v1.Type
+	(UnaryOp)(0),                // 4: kubesql.v1.UnaryOp
+	(BinaryOp)(0),               // 5: kubesql.v1.BinaryOp
+	(*Query)(nil),               // 6: kubesql.v1.Query
+	(*CTE)(nil),                 // 7: kubesql.v1.CTE
+	(*SetOperation)(nil),        // 8: kubesql.v1.SetOperation
+	(*SelectItem)(nil),          // 9: kubesql.v1.SelectItem
+	(*From)(nil),                // 10: kubesql.v1.From
+	(*Join)(nil),                // 11: kubesql.v1.Join
+	(*OrderByItem)(nil),         // 12: kubesql.v1.OrderByItem
+	(*Position)(nil),            // 13: kubesql.v1.Position
+	(*Comment)(nil),             // 14: kubesql.v1.Comment
+	(*Column)(nil),              // 15: kubesql.v1.Column
+	(*Expr)(nil),                // 16: kubesql.v1.Expr
+	(*FieldPath)(nil),           // 17: kubesql.v1.FieldPath
+	(*Segment)(nil),             // 18: kubesql.v1.Segment
+	(*Literal)(nil),             // 19: kubesql.v1.Literal
+	(*Quantity)(nil),            // 20: kubesql.v1.Quantity
+	(*Star)(nil),                // 21: kubesql.v1.Star
+	(*ParenExpr)(nil),           // 22: kubesql.v1.ParenExpr
+	(*UnaryExpr)(nil),           // 23: kubesql.v1.UnaryExpr
+	(*BinaryExpr)(nil),          // 24: kubesql.v1.BinaryExpr
+	(*InExpr)(nil),              // 25: kubesql.v1.InExpr
+	(*ExistsExpr)(nil),          // 26: kubesql.v1.ExistsExpr
+	(*BetweenExpr)(nil),         // 27: kubesql.v1.BetweenExpr
+	(*IsNullExpr)(nil),          // 28: kubesql.v1.IsNullExpr
+	(*CallExpr)(nil),            // 29: kubesql.v1.CallExpr
+	(structpb.NullValue)(0),     // 30: google.protobuf.NullValue
+	(*durationpb.Duration)(nil), // 31: google.protobuf.Duration
+}
+var file_kubesql_v1_query_proto_depIdxs = []int32{
+	9,  // 0: kubesql.v1.Query.select:type_name -> kubesql.v1.SelectItem
+	10, // 1: kubesql.v1.Query.from:type_name -> kubesql.v1.From
+	16, // 2: kubesql.v1.Query.where:type_name -> kubesql.v1.Expr
+	12, // 3: kubesql.v1.Query.order_by:type_name -> kubesql.v1.OrderByItem
+	13, // 4: kubesql.v1.Query.pos:type_name -> kubesql.v1.Position
+	14, // 5: kubesql.v1.Query.comments:type_name -> kubesql.v1.Comment
+	15, // 6: kubesql.v1.Query.columns:type_name -> kubesql.v1.Column
+	11, // 7: kubesql.v1.Query.joins:type_name -> kubesql.v1.Join
+	7,  // 8: kubesql.v1.Query.with:type_name -> kubesql.v1.CTE
+	8,  // 9: kubesql.v1.Query.compound:type_name -> kubesql.v1.SetOperation
+	6,  // 10: kubesql.v1.CTE.query:type_name -> kubesql.v1.Query
+	0,  // 11: kubesql.v1.SetOperation.op:type_name -> kubesql.v1.SetOperator
+	6,  // 12: kubesql.v1.SetOperation.query:type_name -> kubesql.v1.Query
+	16, // 13: kubesql.v1.SelectItem.expr:type_name -> kubesql.v1.Expr
+	1,  // 14: kubesql.v1.Join.type:type_name -> kubesql.v1.JoinType
+	10, // 15: kubesql.v1.Join.from:type_name -> kubesql.v1.From
+	16, // 16: kubesql.v1.Join.on:type_name -> kubesql.v1.Expr
+	16, // 17: kubesql.v1.OrderByItem.expr:type_name -> kubesql.v1.Expr
+	2,  // 18: kubesql.v1.OrderByItem.direction:type_name -> kubesql.v1.SortDirection
+	13, // 19: kubesql.v1.Comment.pos:type_name -> kubesql.v1.Position
+	3,  // 20: kubesql.v1.Column.type:type_name -> kubesql.v1.Type
+	17, // 21: kubesql.v1.Expr.field:type_name -> kubesql.v1.FieldPath
+	19, // 22: kubesql.v1.Expr.literal:type_name -> kubesql.v1.Literal
+	21, // 23: kubesql.v1.Expr.star:type_name -> kubesql.v1.Star
+	22, // 24: kubesql.v1.Expr.paren:type_name -> kubesql.v1.ParenExpr
+	23, // 25: kubesql.v1.Expr.unary:type_name -> kubesql.v1.UnaryExpr
+	24, // 26: kubesql.v1.Expr.binary:type_name -> kubesql.v1.BinaryExpr
+	25, // 27: kubesql.v1.Expr.in:type_name -> kubesql.v1.InExpr
+	27, // 28: kubesql.v1.Expr.between:type_name -> kubesql.v1.BetweenExpr
+	28, // 29: kubesql.v1.Expr.is_null:type_name -> kubesql.v1.IsNullExpr
+	29, // 30: kubesql.v1.Expr.call:type_name -> kubesql.v1.CallExpr
+	26, // 31: kubesql.v1.Expr.exists:type_name -> kubesql.v1.ExistsExpr
+	18, // 32: kubesql.v1.FieldPath.segments:type_name -> kubesql.v1.Segment
+	30, // 33: kubesql.v1.Literal.null_value:type_name -> google.protobuf.NullValue
+	20, // 34: kubesql.v1.Literal.quantity_value:type_name -> kubesql.v1.Quantity
+	31, // 35: kubesql.v1.Literal.duration_value:type_name -> google.protobuf.Duration
+	16, // 36: kubesql.v1.ParenExpr.x:type_name -> kubesql.v1.Expr
+	4,  // 37: kubesql.v1.UnaryExpr.op:type_name -> kubesql.v1.UnaryOp
+	16, // 38: kubesql.v1.UnaryExpr.x:type_name -> kubesql.v1.Expr
+	5,  // 39: kubesql.v1.BinaryExpr.op:type_name -> kubesql.v1.BinaryOp
+	16, // 40: kubesql.v1.BinaryExpr.x:type_name -> kubesql.v1.Expr
+	16, // 41: kubesql.v1.BinaryExpr.y:type_name -> kubesql.v1.Expr
+	16, // 42: kubesql.v1.InExpr.x:type_name -> kubesql.v1.Expr
+	16, // 43: kubesql.v1.InExpr.list:type_name -> kubesql.v1.Expr
+	6,  // 44: kubesql.v1.InExpr.subquery:type_name -> kubesql.v1.Query
+	6,  // 45: kubesql.v1.ExistsExpr.subquery:type_name -> kubesql.v1.Query
+	16, // 46: kubesql.v1.BetweenExpr.x:type_name -> kubesql.v1.Expr
+	16, // 47: kubesql.v1.BetweenExpr.low:type_name -> kubesql.v1.Expr
+	16, // 48: kubesql.v1.BetweenExpr.high:type_name -> kubesql.v1.Expr
+	16, // 49: kubesql.v1.IsNullExpr.x:type_name -> kubesql.v1.Expr
+	16, // 50: kubesql.v1.CallExpr.args:type_name -> kubesql.v1.Expr
+	51, // [51:51] is the sub-list for method output_type
+	51, // [51:51] is the sub-list for method input_type
+	51, // [51:51] is the sub-list for extension type_name
+	51, // [51:51] is the sub-list for extension extendee
+	0,  // [0:51] is the sub-list for field type_name
+}
+
+func init() { file_kubesql_v1_query_proto_init() }
+func file_kubesql_v1_query_proto_init() {
+	if File_kubesql_v1_query_proto != nil {
+		return
+	}
+	file_kubesql_v1_query_proto_msgTypes[0].OneofWrappers = []any{}
+	file_kubesql_v1_query_proto_msgTypes[10].OneofWrappers = []any{
+		(*Expr_Field)(nil),
+		(*Expr_Literal)(nil),
+		(*Expr_Star)(nil),
+		(*Expr_Paren)(nil),
+		(*Expr_Unary)(nil),
+		(*Expr_Binary)(nil),
+		(*Expr_In)(nil),
+		(*Expr_Between)(nil),
+		(*Expr_IsNull)(nil),
+		(*Expr_Call)(nil),
+		(*Expr_Exists)(nil),
+	}
+	file_kubesql_v1_query_proto_msgTypes[12].OneofWrappers = []any{
+		(*Segment_Name)(nil),
+		(*Segment_Index)(nil),
+		(*Segment_Wildcard)(nil),
+	}
+	file_kubesql_v1_query_proto_msgTypes[13].OneofWrappers = []any{
+		(*Literal_StringValue)(nil),
+		(*Literal_IntValue)(nil),
+		(*Literal_FloatValue)(nil),
+		(*Literal_BoolValue)(nil),
+		(*Literal_NullValue)(nil),
+		(*Literal_QuantityValue)(nil),
+		(*Literal_DurationValue)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_kubesql_v1_query_proto_rawDesc), len(file_kubesql_v1_query_proto_rawDesc)),
+			NumEnums:      6,
+			NumMessages:   24,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_kubesql_v1_query_proto_goTypes,
+		DependencyIndexes: file_kubesql_v1_query_proto_depIdxs,
+		EnumInfos:         file_kubesql_v1_query_proto_enumTypes,
+		MessageInfos:      file_kubesql_v1_query_proto_msgTypes,
+	}.Build()
+	File_kubesql_v1_query_proto = out.File
+	file_kubesql_v1_query_proto_goTypes = nil
+	file_kubesql_v1_query_proto_depIdxs = nil
+}
@@ -0,0 +1,128 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// JSON-RPC error codes used by the server.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// message is a JSON-RPC 2.0 request, response or notification. Requests
+// have an ID and a method, notifications only a method, and responses only
+// an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// ResponseError is the error of a failed request.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// conn reads and writes messages framed by Content-Length headers, as
+// used by the base protocol over stdio.
+type conn struct {
+	reader *bufio.Reader
+	writer io.Writer
+	mu     sync.Mutex // Serializes writes
+}
+
+// newConn creates a connection over a reader and a writer.
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{reader: bufio.NewReader(r), writer: w}
+}
+
+// read reads the next message. It returns io.EOF at the end of the input.
+func (c *conn) read() (*message, error) {
+	headers, err := textproto.NewReader(c.reader).ReadMIMEHeader()
+	if err != nil {
+		if len(headers) == 0 && errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("error reading message headers: %w", err)
+	}
+
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header '%s'", headers.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return nil, fmt.Errorf("error reading message body: %w", err)
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, &ResponseError{Code: codeParseError, Message: err.Error()}
+	}
+	return &msg, nil
+}
+
+// write writes a message.
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := fmt.Fprintf(c.writer, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.writer.Write(body)
+	return err
+}
+
+// notify sends a notification.
+func (c *conn) notify(method string, params any) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: data})
+}
+
+// reply sends the response to a request, an error response if err is not
+// nil.
+func (c *conn) reply(id *json.RawMessage, result any, err error) error {
+	if err != nil {
+		responseErr, ok := err.(*ResponseError)
+		if !ok {
+			responseErr = &ResponseError{Code: codeInternalError, Message: err.Error()}
+		}
+		return c.write(&message{ID: id, Error: responseErr})
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{ID: id, Result: data})
+}
@@ -0,0 +1,197 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// The subset of the Language Server Protocol types used by the server.
+
+// Position is a zero based line and UTF-16 character offset in a document.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is a span of a document, End is exclusive.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// TextDocumentIdentifier identifies a document by URI.
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// TextDocumentItem is an opened document.
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int    `json:"version"`
+	Text       string `json:"text"`
+}
+
+// DidOpenTextDocumentParams are the params of textDocument/didOpen.
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// TextDocumentContentChangeEvent is a change of a document. The server
+// synchronizes whole documents, so Text is the new document content.
+type TextDocumentContentChangeEvent struct {
+	Text string `json:"text"`
+}
+
+// DidChangeTextDocumentParams are the params of textDocument/didChange.
+type DidChangeTextDocumentParams struct {
+	TextDocument   TextDocumentIdentifier           `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+// DidCloseTextDocumentParams are the params of textDocument/didClose.
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// TextDocumentPositionParams are the params of requests about a position.
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// DocumentParams are the params of requests about a whole document, such
+// as textDocument/formatting and textDocument/semanticTokens/full.
+type DocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// Diagnostic severities.
+const (
+	SeverityError   = 1
+	SeverityWarning = 2
+)
+
+// Diagnostic is a problem found in a document.
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity"`
+	Source   string `json:"source"`
+	Message  string `json:"message"`
+}
+
+// PublishDiagnosticsParams are the params of textDocument/publishDiagnostics.
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Completion item kinds.
+const (
+	CompletionFunction = 3
+	CompletionField    = 5
+	CompletionModule   = 9
+	CompletionKeyword  = 14
+)
+
+// TextEdit replaces a range of a document.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// CompletionItem is a completion proposal.
+type CompletionItem struct {
+	Label    string    `json:"label"`
+	Kind     int       `json:"kind"`
+	Detail   string    `json:"detail,omitempty"`
+	TextEdit *TextEdit `json:"textEdit,omitempty"`
+}
+
+// CompletionList is the result of textDocument/completion.
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
+// MarkupContent is formatted documentation.
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of textDocument/hover.
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// SemanticTokens is the result of textDocument/semanticTokens/full.
+type SemanticTokens struct {
+	Data []int `json:"data"`
+}
+
+// document is the text of an open document with its line offsets, used to
+// convert between byte offsets and LSP positions.
+type document struct {
+	text  string
+	lines []int // Byte offset of the start of each line
+}
+
+// newDocument creates a document.
+func newDocument(text string) *document {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &document{text: text, lines: lines}
+}
+
+// offset converts a position into a byte offset, clamped to the document.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+
+	offset := d.lines[pos.Line]
+	for units := 0; units < pos.Character && offset < len(d.text) && d.text[offset] != '\n'; {
+		char, size := utf8.DecodeRuneInString(d.text[offset:])
+		units += utf16.RuneLen(char)
+		offset += size
+	}
+	return offset
+}
+
+// position converts a byte offset into a position.
+func (d *document) position(offset int) Position {
+	offset = max(0, min(offset, len(d.text)))
+	line := 0
+	for line+1 < len(d.lines) && d.lines[line+1] <= offset {
+		line++
+	}
+
+	character := 0
+	for _, char := range d.text[d.lines[line]:offset] {
+		character += utf16.RuneLen(char)
+	}
+	return Position{Line: line, Character: character}
+}
+
+// span converts a byte range into a range.
+func (d *document) span(start, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+// lineEnd returns the offset of the end of the line containing offset.
+func (d *document) lineEnd(offset int) int {
+	if end := strings.IndexByte(d.text[offset:], '\n'); end >= 0 {
+		return offset + end
+	}
+	return len(d.text)
+}
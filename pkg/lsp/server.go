@@ -0,0 +1,367 @@
+// Package lsp implements a Language Server Protocol server for KubeSQL
+// scripts, such as .ksql files. It offers diagnostics, completion, hover
+// documentation, formatting and semantic tokens over JSON-RPC, usually on
+// the standard input and output of the "kubesql lsp" command.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+)
+
+// diagnosticSource names the server in the diagnostics it publishes.
+const diagnosticSource = "kubesql"
+
+// Server is a language server for KubeSQL scripts. Documents are kept in
+// memory as they are opened and changed by the client.
+type Server struct {
+	validator *kubesql.Validator
+	completer *kubesql.Completer
+	documents map[string]*document // Open documents by URI
+	conn      *conn
+	shutdown  bool // The client asked the server to shut down
+}
+
+// NewServer creates a language server. schemas may be nil, in which case
+// fields are not checked against resource schemas.
+func NewServer(resolver kubesql.Resolver, schemas kubesql.SchemaProvider) *Server {
+	return &Server{
+		validator: kubesql.NewValidator(resolver, schemas),
+		completer: kubesql.NewCompleter(resolver, schemas),
+		documents: map[string]*document{},
+	}
+}
+
+// Serve reads requests from r and writes responses and notifications to w
+// until the client sends the exit notification or r ends.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.conn = newConn(r, w)
+	for {
+		msg, err := s.conn.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var responseErr *ResponseError
+		if errors.As(err, &responseErr) {
+			if err := s.conn.reply(nil, nil, responseErr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit notification received before shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle dispatches a request or a notification.
+func (s *Server) handle(msg *message) error {
+	if s.shutdown && msg.ID != nil {
+		return s.conn.reply(msg.ID, nil, &ResponseError{Code: codeInvalidRequest, Message: "server is shutting down"})
+	}
+
+	var result any
+	var err error
+
+	switch msg.Method {
+	case "initialize":
+		result = s.initialize()
+	case "initialized", "$/cancelRequest", "$/setTrace":
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err = decodeParams(msg, &params); err == nil {
+			err = s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err = decodeParams(msg, &params); err == nil && len(params.ContentChanges) > 0 {
+			err = s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err = decodeParams(msg, &params); err == nil {
+			delete(s.documents, params.TextDocument.URI)
+			err = s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		}
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err = decodeParams(msg, &params); err == nil {
+			result, err = s.completion(params)
+		}
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err = decodeParams(msg, &params); err == nil {
+			result, err = s.hover(params)
+		}
+	case "textDocument/formatting":
+		var params DocumentParams
+		if err = decodeParams(msg, &params); err == nil {
+			result, err = s.formatting(params)
+		}
+	case "textDocument/semanticTokens/full":
+		var params DocumentParams
+		if err = decodeParams(msg, &params); err == nil {
+			result, err = s.semanticTokens(params)
+		}
+	default:
+		err = &ResponseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method '%s' is not supported", msg.Method)}
+	}
+
+	if msg.ID == nil {
+		// Notifications have no response, failed ones are dropped
+		var responseErr *ResponseError
+		if errors.As(err, &responseErr) {
+			return nil
+		}
+		return err
+	}
+	return s.conn.reply(msg.ID, result, err)
+}
+
+// decodeParams decodes the params of a message.
+func decodeParams(msg *message, params any) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// initialize returns the server capabilities.
+func (s *Server) initialize() any {
+	legend := make([]string, 0, kubesql.CommentToken+1)
+	for class := kubesql.KeywordToken; class <= kubesql.CommentToken; class++ {
+		legend = append(legend, semanticTokenType(class))
+	}
+
+	return map[string]any{
+		"capabilities": map[string]any{
+			"textDocumentSync": 1, // Full documents
+			"completionProvider": map[string]any{
+				"triggerCharacters": []string{".", " "},
+			},
+			"hoverProvider":              true,
+			"documentFormattingProvider": true,
+			"semanticTokensProvider": map[string]any{
+				"legend": map[string]any{"tokenTypes": legend, "tokenModifiers": []string{}},
+				"full":   true,
+			},
+		},
+		"serverInfo": map[string]any{"name": "kubesql"},
+	}
+}
+
+// document returns an open document.
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &ResponseError{Code: codeInvalidParams, Message: fmt.Sprintf("document '%s' is not open", uri)}
+	}
+	return doc, nil
+}
+
+// update stores the new text of a document and publishes its diagnostics.
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(text)
+	s.documents[uri] = doc
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: s.diagnostics(doc)})
+}
+
+// diagnostics parses and validates a document. A syntax error is reported
+// alone, otherwise every validation problem of every statement is reported.
+func (s *Server) diagnostics(doc *document) []Diagnostic {
+	diagnostics := []Diagnostic{}
+
+	queries, err := kubesql.ParseScript(doc.text)
+	if err != nil {
+		var parseErr *kubesql.ParseError
+		if !errors.As(err, &parseErr) {
+			return append(diagnostics, Diagnostic{Severity: SeverityError, Source: diagnosticSource, Message: err.Error()})
+		}
+		message := parseErr.Message
+		if parseErr.Suggestion != "" && !strings.Contains(message, parseErr.Suggestion) {
+			message += fmt.Sprintf(", did you mean '%s'?", parseErr.Suggestion)
+		}
+		start := parseErr.Pos.Offset
+		return append(diagnostics, Diagnostic{
+			Range:    doc.span(start, wordEnd(doc.text, start)),
+			Severity: SeverityError,
+			Source:   diagnosticSource,
+			Message:  message,
+		})
+	}
+
+	for _, query := range queries {
+		err := s.validator.Validate(query)
+		if err == nil {
+			continue
+		}
+		var validationErrs kubesql.ValidationErrors
+		if !errors.As(err, &validationErrs) {
+			validationErrs = kubesql.ValidationErrors{{Message: err.Error()}}
+		}
+
+		// Problems without a location are reported on the first line of
+		// their statement
+		for _, validationErr := range validationErrs {
+			from, to := query.Pos.Offset, doc.lineEnd(query.Pos.Offset)
+			if validationErr.Pos.Line > 0 {
+				from, to = validationErr.Pos.Offset, validationErr.End.Offset
+			}
+			diagnostics = append(diagnostics, Diagnostic{
+				Range:    doc.span(from, to),
+				Severity: SeverityError,
+				Source:   diagnosticSource,
+				Message:  validationErr.Error(),
+			})
+		}
+	}
+	return diagnostics
+}
+
+// wordEnd returns the end of the word starting at offset, or offset when
+// there is no word there.
+func wordEnd(text string, offset int) int {
+	end := offset
+	for end < len(text) && !unicode.IsSpace(rune(text[end])) && !strings.ContainsRune(";,()", rune(text[end])) {
+		end++
+	}
+	return end
+}
+
+// completion proposes completions at a position.
+func (s *Server) completion(params TextDocumentPositionParams) (*CompletionList, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := doc.offset(params.Position)
+	list := &CompletionList{Items: []CompletionItem{}}
+	for _, suggestion := range s.completer.Complete(doc.text, cursor) {
+		list.Items = append(list.Items, CompletionItem{
+			Label:    suggestion.Text,
+			Kind:     completionKind(suggestion.Kind),
+			Detail:   suggestion.Detail,
+			TextEdit: &TextEdit{Range: doc.span(suggestion.Start, cursor), NewText: suggestion.Text},
+		})
+	}
+	return list, nil
+}
+
+// completionKind maps a suggestion kind to a completion item kind.
+func completionKind(kind kubesql.SuggestionKind) int {
+	switch kind {
+	case kubesql.ResourceSuggestion:
+		return CompletionModule
+	case kubesql.FieldSuggestion:
+		return CompletionField
+	case kubesql.FunctionSuggestion:
+		return CompletionFunction
+	}
+	return CompletionKeyword
+}
+
+// hover documents the word at a position, the result is nil when there is
+// nothing to document.
+func (s *Server) hover(params TextDocumentPositionParams) (*Hover, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	description, ok := s.completer.Describe(doc.text, doc.offset(params.Position))
+	if !ok {
+		return nil, nil
+	}
+
+	value := fmt.Sprintf("```\n%s %s\n```", description.Name, description.Detail)
+	if description.Kind == kubesql.FunctionSuggestion || description.Kind == kubesql.KeywordSuggestion {
+		value = fmt.Sprintf("```\n%s\n```", description.Detail)
+		if description.Kind == kubesql.KeywordSuggestion {
+			value = fmt.Sprintf("**%s** keyword", description.Name)
+		}
+	}
+	if description.Doc != "" {
+		value += "\n\n" + description.Doc
+	}
+
+	span := doc.span(description.Start, description.End)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: &span}, nil
+}
+
+// formatting replaces a document with its canonical form. A document with
+// syntax errors is left as is.
+func (s *Server) formatting(params DocumentParams) ([]TextEdit, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	queries, err := kubesql.ParseScript(doc.text)
+	if err != nil {
+		return []TextEdit{}, nil
+	}
+	formatted := kubesql.FormatScript(queries)
+	if formatted == doc.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: doc.span(0, len(doc.text)), NewText: formatted}}, nil
+}
+
+// semanticTokens classifies the tokens of a document, encoded as relative
+// line, relative start character, length, type and modifiers.
+func (s *Server) semanticTokens(params DocumentParams) (*SemanticTokens, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := &SemanticTokens{Data: []int{}}
+	var previous Position
+	for _, token := range kubesql.Highlight(doc.text) {
+		// Tokens spanning several lines, such as block comments, are split
+		for offset, end := token.Offset, token.Offset+token.Length; offset < end; {
+			lineEnd := min(doc.lineEnd(offset), end)
+			start, stop := doc.position(offset), doc.position(lineEnd)
+			if stop.Character > start.Character {
+				deltaStart := start.Character
+				if start.Line == previous.Line {
+					deltaStart -= previous.Character
+				}
+				tokens.Data = append(tokens.Data, start.Line-previous.Line, deltaStart, stop.Character-start.Character, int(token.Class), 0)
+				previous = start
+			}
+			offset = lineEnd + 1
+		}
+	}
+	return tokens, nil
+}
+
+// semanticTokenType maps a token class to a standard semantic token type.
+func semanticTokenType(class kubesql.TokenClass) string {
+	switch class {
+	case kubesql.FieldToken:
+		return "property"
+	case kubesql.ResourceToken:
+		return "type"
+	}
+	return class.String()
+}
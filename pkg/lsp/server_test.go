@@ -0,0 +1,421 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yaacov/kubesql-interpreter/pkg/kubesql"
+)
+
+// testClient is an in-process LSP client connected to a Server by pipes.
+type testClient struct {
+	t             *testing.T
+	conn          *conn
+	nextID        int
+	responses     chan *message
+	notifications chan *message
+	done          chan error
+}
+
+// newTestClient starts a server with the test schemas and connects a
+// client to it.
+func newTestClient(t *testing.T) *testClient {
+	t.Helper()
+
+	resolver := kubesql.DefaultResolver()
+	schemas, err := kubesql.LoadSchemas("../kubesql/testdata/schemas")
+	if err != nil {
+		t.Fatalf("Error loading schemas: %v", err)
+	}
+	resolver.Add(schemas.Resources()...)
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	client := &testClient{
+		t:             t,
+		conn:          newConn(clientReader, clientWriter),
+		responses:     make(chan *message, 16),
+		notifications: make(chan *message, 16),
+		done:          make(chan error, 1),
+	}
+
+	go func() {
+		client.done <- NewServer(resolver, schemas).Serve(serverReader, serverWriter)
+		serverWriter.Close()
+	}()
+	go func() {
+		for {
+			msg, err := client.conn.read()
+			if err != nil {
+				close(client.responses)
+				return
+			}
+			if msg.ID == nil {
+				client.notifications <- msg
+			} else {
+				client.responses <- msg
+			}
+		}
+	}()
+
+	t.Cleanup(func() { clientWriter.Close() })
+	return client
+}
+
+// call sends a request and decodes its result into result.
+func (c *testClient) call(method string, params, result any) *ResponseError {
+	c.t.Helper()
+
+	c.nextID++
+	id := json.RawMessage(mustMarshal(c.t, c.nextID))
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: json.RawMessage(mustMarshal(c.t, params))}); err != nil {
+		c.t.Fatalf("Error sending %s: %v", method, err)
+	}
+
+	select {
+	case msg := <-c.responses:
+		if msg == nil {
+			c.t.Fatalf("Connection closed waiting for %s", method)
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("For %s, expected response id %s, got %s", method, id, *msg.ID)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("Error decoding %s result: %v", method, err)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("Timeout waiting for %s", method)
+	}
+	return nil
+}
+
+// notify sends a notification.
+func (c *testClient) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.write(&message{Method: method, Params: json.RawMessage(mustMarshal(c.t, params))}); err != nil {
+		c.t.Fatalf("Error sending %s: %v", method, err)
+	}
+}
+
+// diagnostics waits for the next published diagnostics.
+func (c *testClient) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+
+	select {
+	case msg := <-c.notifications:
+		if msg.Method != "textDocument/publishDiagnostics" {
+			c.t.Fatalf("Expected publishDiagnostics notification, got %s", msg.Method)
+		}
+		var params PublishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatalf("Error decoding diagnostics: %v", err)
+		}
+		return params
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("Timeout waiting for diagnostics")
+	}
+	return PublishDiagnosticsParams{}
+}
+
+// open initializes the server and opens a document.
+func (c *testClient) open(uri, text string) PublishDiagnosticsParams {
+	c.t.Helper()
+	if err := c.call("initialize", map[string]any{}, nil); err != nil {
+		c.t.Fatalf("Error initializing: %v", err)
+	}
+	c.notify("initialized", map[string]any{})
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "kubesql", Text: text}})
+	return c.diagnostics()
+}
+
+func mustMarshal(t *testing.T, value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Error encoding %v: %v", value, err)
+	}
+	return string(data)
+}
+
+const testURI = "file:///queries.ksql"
+
+func TestInitialize(t *testing.T) {
+	client := newTestClient(t)
+
+	var result struct {
+		Capabilities struct {
+			TextDocumentSync       int  `json:"textDocumentSync"`
+			HoverProvider          bool `json:"hoverProvider"`
+			SemanticTokensProvider struct {
+				Legend struct {
+					TokenTypes []string `json:"tokenTypes"`
+				} `json:"legend"`
+			} `json:"semanticTokensProvider"`
+		} `json:"capabilities"`
+	}
+	if err := client.call("initialize", map[string]any{}, &result); err != nil {
+		t.Fatalf("Error initializing: %v", err)
+	}
+	if result.Capabilities.TextDocumentSync != 1 || !result.Capabilities.HoverProvider {
+		t.Errorf("Expected full sync and hover capabilities, got %+v", result.Capabilities)
+	}
+	if legend := result.Capabilities.SemanticTokensProvider.Legend.TokenTypes; len(legend) != 8 || legend[0] != "keyword" {
+		t.Errorf("Expected 8 semantic token types starting with keyword, got %v", legend)
+	}
+
+	if err := client.call("textDocument/unknown", map[string]any{}, nil); err == nil || err.Code != codeMethodNotFound {
+		t.Errorf("For an unknown method, expected error code %d, got %v", codeMethodNotFound, err)
+	}
+
+	if err := client.call("shutdown", nil, nil); err != nil {
+		t.Fatalf("Error shutting down: %v", err)
+	}
+	client.notify("exit", nil)
+	select {
+	case err := <-client.done:
+		if err != nil {
+			t.Errorf("Expected server to exit cleanly, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout waiting for the server to exit")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	testCases := []struct {
+		text    string
+		ranges  []Range
+		message string
+	}{
+		{
+			text:   "SELECT name FROM pods WHERE status.phase = 'Running';",
+			ranges: nil,
+		},
+		{
+			text:    "SELECT name FORM pods;",
+			ranges:  []Range{{Start: Position{0, 12}, End: Position{0, 16}}},
+			message: "did you mean 'FROM'",
+		},
+		{
+			text:    "SELECT name FROM pods;\nSELECT name FROM pods WHERE spec.nodeNmae = 'a';",
+			ranges:  []Range{{Start: Position{1, 28}, End: Position{1, 41}}},
+			message: "spec.nodeName",
+		},
+		{
+			text:    "SELECT name -- status.phase = 1\nFROM pods WHERE status.phase = 1;",
+			ranges:  []Range{{Start: Position{1, 16}, End: Position{1, 32}}},
+			message: "status.phase",
+		},
+		{
+			text:    "SELECT name FROM pods\nWHERE status.phase   =\n  1;",
+			ranges:  []Range{{Start: Position{1, 6}, End: Position{2, 3}}},
+			message: "status.phase",
+		},
+		{
+			text:    "SELECT name FROM podz;",
+			ranges:  []Range{{Start: Position{0, 17}, End: Position{0, 21}}},
+			message: "pods",
+		},
+	}
+
+	for _, tc := range testCases {
+		client := newTestClient(t)
+		params := client.open(testURI, tc.text)
+
+		if params.URI != testURI {
+			t.Errorf("For input '%s', expected diagnostics for %s, got %s", tc.text, testURI, params.URI)
+		}
+		if len(params.Diagnostics) != len(tc.ranges) {
+			t.Errorf("For input '%s', expected %d diagnostics, got %+v", tc.text, len(tc.ranges), params.Diagnostics)
+			continue
+		}
+		for i, diagnostic := range params.Diagnostics {
+			if diagnostic.Range != tc.ranges[i] {
+				t.Errorf("For input '%s', expected range %+v, got %+v", tc.text, tc.ranges[i], diagnostic.Range)
+			}
+			if diagnostic.Severity != SeverityError || diagnostic.Source != "kubesql" || !strings.Contains(diagnostic.Message, tc.message) {
+				t.Errorf("For input '%s', expected an error mentioning '%s', got %+v", tc.text, tc.message, diagnostic)
+			}
+		}
+	}
+}
+
+func TestDidChange(t *testing.T) {
+	client := newTestClient(t)
+	if params := client.open(testURI, "SELECT name FROM podz;"); len(params.Diagnostics) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %+v", params.Diagnostics)
+	}
+
+	client.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: testURI},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "SELECT name FROM pods;"}},
+	})
+	if params := client.diagnostics(); len(params.Diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics after the fix, got %+v", params.Diagnostics)
+	}
+
+	client.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}})
+	if params := client.diagnostics(); len(params.Diagnostics) != 0 {
+		t.Errorf("Expected diagnostics to be cleared on close, got %+v", params.Diagnostics)
+	}
+	if err := client.call("textDocument/hover", TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, nil); err == nil || err.Code != codeInvalidParams {
+		t.Errorf("For a closed document, expected error code %d, got %v", codeInvalidParams, err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	text := "SELECT name FROM pods WHERE spec.nod"
+	client := newTestClient(t)
+	client.open(testURI, text)
+
+	var list CompletionList
+	params := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: testURI}, Position: Position{0, len(text)}}
+	if err := client.call("textDocument/completion", params, &list); err != nil {
+		t.Fatalf("Error completing: %v", err)
+	}
+
+	var labels []string
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+		if item.Label == "nodeName" {
+			if item.Kind != CompletionField {
+				t.Errorf("Expected nodeName to be a field, got kind %d", item.Kind)
+			}
+			want := Range{Start: Position{0, 33}, End: Position{0, 36}}
+			if item.TextEdit == nil || item.TextEdit.Range != want {
+				t.Errorf("Expected nodeName to replace %+v, got %+v", want, item.TextEdit)
+			}
+		}
+	}
+	if !strings.Contains(strings.Join(labels, ","), "nodeName") {
+		t.Errorf("Expected nodeName to be proposed, got %v", labels)
+	}
+}
+
+func TestHover(t *testing.T) {
+	text := "SELECT len(spec.containers) FROM pods\nWHERE spec.nodeName = 'a';"
+	client := newTestClient(t)
+	client.open(testURI, text)
+
+	testCases := []struct {
+		position Position
+		contains []string
+	}{
+		{position: Position{0, 8}, contains: []string{"len(value) int", "Length of a string"}},
+		{position: Position{1, 13}, contains: []string{"spec.nodeName string"}},
+		{position: Position{0, 35}, contains: []string{"Pod (v1)", "Namespaced resource"}},
+		{position: Position{0, 2}, contains: []string{"**SELECT** keyword"}},
+	}
+
+	for _, tc := range testCases {
+		var hover *Hover
+		params := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: testURI}, Position: tc.position}
+		if err := client.call("textDocument/hover", params, &hover); err != nil {
+			t.Fatalf("Error hovering: %v", err)
+		}
+		if hover == nil {
+			t.Errorf("For position %+v, expected a hover, got none", tc.position)
+			continue
+		}
+		for _, want := range tc.contains {
+			if !strings.Contains(hover.Contents.Value, want) {
+				t.Errorf("For position %+v, expected hover to contain '%s', got '%s'", tc.position, want, hover.Contents.Value)
+			}
+		}
+	}
+
+	var hover *Hover
+	params := TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: testURI}, Position: Position{1, 23}}
+	if err := client.call("textDocument/hover", params, &hover); err != nil || hover != nil {
+		t.Errorf("For a string literal, expected no hover, got %+v, %v", hover, err)
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected string
+	}{
+		{text: "select name from pods where x=1", expected: "SELECT name FROM pods WHERE x=1;\n"},
+		{text: "SELECT name FROM pods;\n", expected: ""},
+		{text: "SELECT name FROM", expected: ""},
+	}
+
+	for _, tc := range testCases {
+		client := newTestClient(t)
+		client.open(testURI, tc.text)
+
+		var edits []TextEdit
+		if err := client.call("textDocument/formatting", DocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &edits); err != nil {
+			t.Fatalf("Error formatting: %v", err)
+		}
+		if tc.expected == "" {
+			if len(edits) != 0 {
+				t.Errorf("For input '%s', expected no edits, got %+v", tc.text, edits)
+			}
+			continue
+		}
+		if len(edits) != 1 || edits[0].NewText != tc.expected {
+			t.Errorf("For input '%s', expected '%s', got %+v", tc.text, tc.expected, edits)
+		}
+	}
+}
+
+func TestSemanticTokens(t *testing.T) {
+	text := "-- pods\nSELECT len(name) FROM pods WHERE x > 1;"
+	client := newTestClient(t)
+	client.open(testURI, text)
+
+	var tokens SemanticTokens
+	if err := client.call("textDocument/semanticTokens/full", DocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &tokens); err != nil {
+		t.Fatalf("Error getting semantic tokens: %v", err)
+	}
+
+	expected := []int{
+		0, 0, 7, int(kubesql.CommentToken), 0,
+		1, 0, 6, int(kubesql.KeywordToken), 0,
+		0, 7, 3, int(kubesql.FunctionToken), 0,
+		0, 4, 4, int(kubesql.FieldToken), 0,
+		0, 6, 4, int(kubesql.KeywordToken), 0,
+		0, 5, 4, int(kubesql.ResourceToken), 0,
+		0, 5, 5, int(kubesql.KeywordToken), 0,
+		0, 6, 1, int(kubesql.FieldToken), 0,
+		0, 2, 1, int(kubesql.OperatorToken), 0,
+		0, 2, 1, int(kubesql.NumberToken), 0,
+	}
+	if mustMarshal(t, tokens.Data) != mustMarshal(t, expected) {
+		t.Errorf("For input '%s', expected %v, got %v", text, expected, tokens.Data)
+	}
+}
+
+func TestDocumentPositions(t *testing.T) {
+	doc := newDocument("ab\nçé𝄞x\n")
+
+	testCases := []struct {
+		offset   int
+		position Position
+	}{
+		{offset: 0, position: Position{0, 0}},
+		{offset: 3, position: Position{1, 0}},
+		{offset: 7, position: Position{1, 2}},
+		{offset: 11, position: Position{1, 4}},
+		{offset: 12, position: Position{1, 5}},
+		{offset: 13, position: Position{2, 0}},
+	}
+
+	for _, tc := range testCases {
+		if got := doc.position(tc.offset); got != tc.position {
+			t.Errorf("For offset %d, expected position %+v, got %+v", tc.offset, tc.position, got)
+		}
+		if got := doc.offset(tc.position); got != tc.offset {
+			t.Errorf("For position %+v, expected offset %d, got %d", tc.position, tc.offset, got)
+		}
+	}
+}